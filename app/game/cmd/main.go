@@ -1,14 +1,19 @@
 package main
 
 import (
+	"github.com/lk2023060901/xdooria/app/game/internal/handler"
 	"github.com/lk2023060901/xdooria/app/game/internal/metrics"
+	"github.com/lk2023060901/xdooria/app/game/internal/service"
 	"github.com/lk2023060901/xdooria/pkg/app"
 	"github.com/lk2023060901/xdooria/pkg/database/postgres"
 	"github.com/lk2023060901/xdooria/pkg/database/redis"
 	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/network/framer"
 	"github.com/lk2023060901/xdooria/pkg/network/grpc/server"
+	"github.com/lk2023060901/xdooria/pkg/network/session"
 	"github.com/lk2023060901/xdooria/pkg/prometheus"
 	"github.com/lk2023060901/xdooria/pkg/registry/etcd"
+	"github.com/lk2023060901/xdooria/pkg/scheduler"
 )
 
 // GameConfigConfig 游戏配置表加载配置
@@ -24,6 +29,27 @@ type Config struct {
 	// 游戏配置表
 	GameConfig GameConfigConfig `mapstructure:"gameconfig"`
 
+	// 熔炼配置
+	Smelt service.SmeltConfig `mapstructure:"smelt"`
+
+	// 商店配置
+	Shop service.ShopConfig `mapstructure:"shop"`
+
+	// 背包配置
+	Bag service.BagConfig `mapstructure:"bag"`
+
+	// 邮件配置
+	Mail service.MailConfig `mapstructure:"mail"`
+
+	// 场景配置
+	Scene service.SceneConfig `mapstructure:"scene"`
+
+	// 运营后台 HTTP 接口配置
+	Admin handler.AdminConfig `mapstructure:"admin"`
+
+	// 定时任务调度器配置（未配置时使用默认配置）
+	Scheduler *scheduler.Config `mapstructure:"scheduler"`
+
 	// Database 配置
 	Database postgres.Config `mapstructure:"database"`
 
@@ -33,6 +59,12 @@ type Config struct {
 	// gRPC Server 配置
 	GRPC server.Config `mapstructure:"grpc"`
 
+	// Gateway 双向流会话配置（服务端主动推送）
+	GatewayStream session.Config `mapstructure:"gateway_stream"`
+
+	// Framer 配置（需与 Gateway 保持一致）
+	Framer framer.Config `mapstructure:"framer"`
+
 	// Prometheus 配置
 	Prometheus prometheus.Config `mapstructure:"prometheus"`
 
@@ -5,6 +5,7 @@ package main
 
 import (
 	gamepb "github.com/lk2023060901/xdooria-proto-internal/game"
+	common "github.com/lk2023060901/xdooria-proto-common"
 	"context"
 
 	"github.com/google/wire"
@@ -18,11 +19,16 @@ import (
 	"github.com/lk2023060901/xdooria/pkg/database/postgres"
 	"github.com/lk2023060901/xdooria/pkg/database/redis"
 	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/network/framer"
+	grpcpkg "github.com/lk2023060901/xdooria/pkg/network/grpc"
 	"github.com/lk2023060901/xdooria/pkg/network/grpc/server"
+	"github.com/lk2023060901/xdooria/pkg/network/session"
 	"github.com/lk2023060901/xdooria/pkg/prometheus"
 	"github.com/lk2023060901/xdooria/pkg/registry"
 	"github.com/lk2023060901/xdooria/pkg/registry/etcd"
 	"github.com/lk2023060901/xdooria/pkg/router"
+	"github.com/lk2023060901/xdooria/pkg/scheduler"
+	"github.com/lk2023060901/xdooria/pkg/web"
 )
 
 func InitApp(cfg *Config, l logger.Logger) (app.Application, func(), error) {
@@ -43,12 +49,18 @@ func InitApp(cfg *Config, l logger.Logger) (app.Application, func(), error) {
 		dao.NewDollDAO,
 		dao.NewGachaDAO,
 		dao.NewBagDAO,
+		dao.NewShopDAO,
+		dao.NewMailDAO,
 		dao.NewCacheDAO,
 		provideGameConfigConfig,
 		dao.NewConfigDAO,
 
 		// 仓储层
 		repository.NewPlayerRepository,
+		repository.NewShopRepository,
+		repository.NewMailRepository,
+		repository.NewRoleRepository,
+		repository.NewUnitOfWork,
 
 		// 5. 指标收集
 		provideMetricsConfig,
@@ -58,29 +70,54 @@ func InitApp(cfg *Config, l logger.Logger) (app.Application, func(), error) {
 		// 6. 管理层 (Manager)
 		manager.NewRoleManager,
 		manager.NewSessionManager,
+		manager.NewGatewayManager,
+		provideAOIConfig,
 		manager.NewSceneManager,
 
 		// 7. 服务层 (Service)
 		service.NewRoleService,
+		provideSceneConfig,
 		service.NewSceneService,
 		service.NewMessageService,
 		service.NewDollService,
 		service.NewDropService,
+		provideBagConfig,
 		service.NewBagService,
+		service.NewBagExpiryJob,
 		service.NewGachaService,
+		provideSmeltConfig,
 		service.NewSmeltService,
+		provideShopConfig,
+		service.NewShopService,
+		provideMailConfig,
+		service.NewMailService,
+		service.NewMailPurgeJob,
+		service.NewLevelService,
 
 		// 8. 接口层 (Handler)
 		handler.NewGameHandler,
 		handler.NewDollHandler,
 		handler.NewGachaHandler,
 		handler.NewSmeltHandler,
+		handler.NewShopHandler,
+		handler.NewBagHandler,
+		handler.NewMailHandler,
+		provideAdminConfig,
+		handler.NewAdminHandler,
+
+		// 定时任务
+		provideScheduler,
 
 		// 9. gRPC Server 配置和选项
 		provideGRPCServerConfig,
 		provideGRPCServerOptions,
 		server.New,
 
+		// Gateway 双向流（Framer 需与 Gateway 一致）
+		wire.FieldsOf(new(*Config), "Framer"),
+		framer.New,
+		provideGatewaySessionConfig,
+
 		// Router (消息路由器)
 		provideRouter,
 
@@ -118,6 +155,70 @@ func provideGameConfigConfig(cfg *Config) *dao.GameConfigConfig {
 	}
 }
 
+// provideSmeltConfig 提供熔炼配置
+func provideSmeltConfig(cfg *Config) *service.SmeltConfig {
+	return &cfg.Smelt
+}
+
+// provideShopConfig 提供商店配置
+func provideShopConfig(cfg *Config) *service.ShopConfig {
+	return &cfg.Shop
+}
+
+// provideBagConfig 提供背包配置
+func provideBagConfig(cfg *Config) *service.BagConfig {
+	return &cfg.Bag
+}
+
+// provideMailConfig 提供邮件配置
+func provideMailConfig(cfg *Config) *service.MailConfig {
+	return &cfg.Mail
+}
+
+// provideSceneConfig 提供场景配置
+func provideSceneConfig(cfg *Config) *service.SceneConfig {
+	return &cfg.Scene
+}
+
+// provideAOIConfig 提供场景 AOI 配置
+func provideAOIConfig(cfg *Config) *manager.AOIConfig {
+	return &cfg.Scene.AOI
+}
+
+// provideAdminConfig 提供运营后台配置
+func provideAdminConfig(cfg *Config) *handler.AdminConfig {
+	return &cfg.Admin
+}
+
+// provideScheduler 提供定时任务调度器并注册业务定时任务
+func provideScheduler(
+	cfg *Config,
+	l logger.Logger,
+	bagExpiryJob *service.BagExpiryJob,
+	mailPurgeJob *service.MailPurgeJob,
+) (*scheduler.Scheduler, error) {
+	s, err := scheduler.New(cfg.Scheduler, scheduler.WithLogger(l.Named("scheduler")))
+	if err != nil {
+		return nil, err
+	}
+
+	// 过期物品清理：失败的角色会在下次执行或加载背包时再次清理，不整体重试
+	if cfg.Bag.SweepSpec != "" {
+		if _, err := s.AddJob(bagExpiryJob.Name(), cfg.Bag.SweepSpec, bagExpiryJob, scheduler.WithNoRetry()); err != nil {
+			return nil, err
+		}
+	}
+
+	// 过期邮件清理：过期邮件本身不会展示，清理失败等待下次执行即可
+	if cfg.Mail.PurgeSpec != "" {
+		if _, err := s.AddJob(mailPurgeJob.Name(), cfg.Mail.PurgeSpec, mailPurgeJob, scheduler.WithNoRetry()); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
 // provideGRPCServerConfig 提供 gRPC Server 配置
 func provideGRPCServerConfig(cfg *Config) *server.Config {
 	return &cfg.GRPC
@@ -128,6 +229,13 @@ func provideGRPCServerOptions() []server.Option {
 	return nil // 暂时不需要额外选项
 }
 
+// provideGatewaySessionConfig 提供 Gateway 流会话配置（注入 Framer）
+func provideGatewaySessionConfig(cfg *Config, fr framer.Framer) *session.Config {
+	sessCfg := cfg.GatewayStream
+	sessCfg.Framer = fr
+	return &sessCfg
+}
+
 // provideRouter 提供消息路由器
 func provideRouter() router.Router {
 	return router.New()
@@ -174,9 +282,16 @@ func provideAppComponents(
 	grpcServer *server.Server,
 	messageSvc *service.MessageService,
 	gameHandler *handler.GameHandler,
+	gatewayMgr *manager.GatewayManager,
+	gatewaySessCfg *session.Config,
 	dollHandler *handler.DollHandler,
 	gachaHandler *handler.GachaHandler,
 	smeltHandler *handler.SmeltHandler,
+	shopHandler *handler.ShopHandler,
+	bagHandler *handler.BagHandler,
+	mailHandler *handler.MailHandler,
+	adminHandler *handler.AdminHandler,
+	jobScheduler *scheduler.Scheduler,
 	promClient *prometheus.Client,
 	gameMetrics *metrics.GameMetrics,
 	reporter *metrics.Reporter,
@@ -191,11 +306,17 @@ func provideAppComponents(
 	// 注册 gRPC 服务
 	gamepb.RegisterGameServiceServer(grpcServer.GetGRPCServer(), gameHandler)
 
+	// 注册 Gateway 双向流（服务端主动推送）
+	common.RegisterCommonServiceServer(grpcServer.GetGRPCServer(), grpcpkg.NewAcceptor(gatewaySessCfg, gatewayMgr))
+
 	// 获取消息路由器并注册业务 Handler
 	router := messageSvc.RoleRouter()
 	dollHandler.RegisterHandlers(router)
 	gachaHandler.RegisterHandlers(router)
 	smeltHandler.RegisterHandlers(router)
+	shopHandler.RegisterHandlers(router)
+	bagHandler.RegisterHandlers(router)
+	mailHandler.RegisterHandlers(router)
 
 	// 注册 Game 指标到 Prometheus
 	_ = gameMetrics.Register(promClient.Registry())
@@ -211,11 +332,24 @@ func provideAppComponents(
 		logger:      baseApp.AppLogger(),
 	}
 
+	servers := []app.Server{
+		grpcServer, // gRPC Server 实现了 app.Server
+		serviceStarter,
+		&schedulerServer{scheduler: jobScheduler},
+	}
+
+	// 运营后台 HTTP 服务（发送补偿邮件等）
+	if cfg.Admin.Enabled {
+		adminWeb := web.NewServer(&cfg.Admin.Web, baseApp.AppLogger())
+		adminHandler.Register(adminWeb.Router())
+		servers = append(servers, &adminServer{
+			server: adminWeb,
+			logger: baseApp.AppLogger(),
+		})
+	}
+
 	return app.AppComponents{
-		Servers: []app.Server{
-			grpcServer, // gRPC Server 实现了 app.Server
-			serviceStarter,
-		},
+		Servers: servers,
 		Closers: []app.Closer{
 			&metricsCloser{
 				reporter:    reporter,
@@ -285,4 +419,52 @@ func (s *serviceRegistrar) Start() error {
 
 func (s *serviceRegistrar) Stop() error {
 	return nil // Deregister 由 registrarCloser 处理
-}
\ No newline at end of file
+}
+
+// schedulerServer 定时任务调度器启动器，实现 app.Server 接口
+type schedulerServer struct {
+	scheduler *scheduler.Scheduler
+}
+
+func (s *schedulerServer) Start() error {
+	s.scheduler.Start()
+	return nil
+}
+
+func (s *schedulerServer) Stop() error {
+	// 等待正在执行的任务结束
+	<-s.scheduler.Stop().Done()
+	return nil
+}
+
+// adminServer 运营后台 HTTP 服务启动器，实现 app.Server 接口
+type adminServer struct {
+	server *web.Server
+	logger logger.Logger
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (s *adminServer) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		if err := s.server.Run(ctx); err != nil {
+			s.logger.Error("admin server exited with error", "error", err)
+		}
+	}()
+	return nil
+}
+
+func (s *adminServer) Stop() error {
+	if s.cancel == nil {
+		return nil
+	}
+	// 等待 HTTP 服务优雅关闭
+	s.cancel()
+	<-s.done
+	return nil
+}
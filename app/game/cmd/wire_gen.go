@@ -8,6 +8,7 @@ package main
 
 import (
 	"context"
+	"github.com/lk2023060901/xdooria-proto-common"
 	"github.com/lk2023060901/xdooria-proto-internal/game"
 	"github.com/lk2023060901/xdooria/app/game/internal/dao"
 	"github.com/lk2023060901/xdooria/app/game/internal/handler"
@@ -19,11 +20,16 @@ import (
 	"github.com/lk2023060901/xdooria/pkg/database/postgres"
 	"github.com/lk2023060901/xdooria/pkg/database/redis"
 	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/network/framer"
+	"github.com/lk2023060901/xdooria/pkg/network/grpc"
 	"github.com/lk2023060901/xdooria/pkg/network/grpc/server"
+	"github.com/lk2023060901/xdooria/pkg/network/session"
 	"github.com/lk2023060901/xdooria/pkg/prometheus"
 	"github.com/lk2023060901/xdooria/pkg/registry"
 	"github.com/lk2023060901/xdooria/pkg/registry/etcd"
 	"github.com/lk2023060901/xdooria/pkg/router"
+	"github.com/lk2023060901/xdooria/pkg/scheduler"
+	"github.com/lk2023060901/xdooria/pkg/web"
 )
 
 // Injectors from wire.go:
@@ -56,24 +62,57 @@ func InitApp(cfg *Config, l logger.Logger) (app.Application, func(), error) {
 	}
 	cacheDAO := dao.NewCacheDAO(redisClient, l, gameMetrics)
 	roleManager := manager.NewRoleManager(l, roleDAO, cacheDAO, gameMetrics)
-	sceneManager := manager.NewSceneManager(l)
-	sceneService := service.NewSceneService(l, roleManager, sceneManager, gameMetrics)
-	messageService := service.NewMessageService(l, router, roleManager, sceneService, gameMetrics)
+	gatewayManager := manager.NewGatewayManager(l)
+	sceneConfig := provideSceneConfig(cfg)
+	aoiConfig := provideAOIConfig(cfg)
+	sceneManager := manager.NewSceneManager(l, aoiConfig)
+	sceneService := service.NewSceneService(l, sceneConfig, roleManager, sceneManager, gatewayManager, gameMetrics)
+	messageService := service.NewMessageService(l, router, roleManager, gatewayManager, sceneService, gameMetrics)
 	sessionManager := manager.NewSessionManager(l, cacheDAO)
 	roleService := service.NewRoleService(l, roleManager, sessionManager, roleDAO, gameMetrics)
 	gameHandler := handler.NewGameHandler(l, roleService, messageService)
+	framerConfig := &cfg.Framer
+	framerFramer, err := framer.New(framerConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	sessionConfig := provideGatewaySessionConfig(cfg, framerFramer)
 	dollDAO := dao.NewDollDAO(client, l, gameMetrics)
 	gachaDAO := dao.NewGachaDAO(client, l, gameMetrics)
 	bagDAO := dao.NewBagDAO(client, l, gameMetrics)
 	playerRepository := repository.NewPlayerRepository(dollDAO, gachaDAO, bagDAO, cacheDAO, l)
+	unitOfWork := repository.NewUnitOfWork(client, l)
 	dollService := service.NewDollService(l, playerRepository, gameMetrics)
 	dollHandler := handler.NewDollHandler(l, dollService)
 	dropService := service.NewDropService(l)
-	bagService := service.NewBagService(l, playerRepository)
-	gachaService := service.NewGachaService(l, playerRepository, dropService, dollService, bagService)
+	bagConfig := provideBagConfig(cfg)
+	bagService := service.NewBagService(l, bagConfig, playerRepository, unitOfWork)
+	gachaService := service.NewGachaService(l, playerRepository, unitOfWork, dropService, dollService, bagService)
 	gachaHandler := handler.NewGachaHandler(l, gachaService)
-	smeltService := service.NewSmeltService(l, playerRepository, dropService, dollService, bagService)
+	smeltConfig := provideSmeltConfig(cfg)
+	smeltService := service.NewSmeltService(l, smeltConfig, playerRepository, unitOfWork, dropService, dollService, bagService)
 	smeltHandler := handler.NewSmeltHandler(l, smeltService)
+	shopConfig := provideShopConfig(cfg)
+	shopDAO := dao.NewShopDAO(client, l, gameMetrics)
+	shopRepository := repository.NewShopRepository(shopDAO, l)
+	shopService := service.NewShopService(l, shopConfig, shopRepository, unitOfWork, roleManager, dollService, bagService)
+	shopHandler := handler.NewShopHandler(l, shopService)
+	bagHandler := handler.NewBagHandler(l, bagService)
+	mailConfig := provideMailConfig(cfg)
+	mailDAO := dao.NewMailDAO(client, l, gameMetrics)
+	mailRepository := repository.NewMailRepository(mailDAO, l)
+	mailService := service.NewMailService(l, mailConfig, mailRepository, unitOfWork, roleManager, dollService, bagService)
+	mailHandler := handler.NewMailHandler(l, mailService)
+	adminConfig := provideAdminConfig(cfg)
+	roleRepository := repository.NewRoleRepository(roleDAO, cacheDAO, l)
+	levelService := service.NewLevelService(l, roleRepository, unitOfWork, roleManager, dollService, bagService, mailService, messageService)
+	adminHandler := handler.NewAdminHandler(l, adminConfig, mailService, levelService, gachaService)
+	bagExpiryJob := service.NewBagExpiryJob(l, bagService, roleManager)
+	mailPurgeJob := service.NewMailPurgeJob(l, mailService)
+	schedulerScheduler, err := provideScheduler(cfg, l, bagExpiryJob, mailPurgeJob)
+	if err != nil {
+		return nil, nil, err
+	}
 	prometheusConfig := providePrometheusConfig(cfg)
 	prometheusClient, err := prometheus.New(prometheusConfig)
 	if err != nil {
@@ -97,7 +136,7 @@ func InitApp(cfg *Config, l logger.Logger) (app.Application, func(), error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	appComponents := provideAppComponents(baseApp, serverServer, messageService, gameHandler, dollHandler, gachaHandler, smeltHandler, prometheusClient, gameMetrics, reporter, registrar, resolver, client, redisClient, configDAO, cfg, v)
+	appComponents := provideAppComponents(baseApp, serverServer, messageService, gameHandler, gatewayManager, sessionConfig, dollHandler, gachaHandler, smeltHandler, shopHandler, bagHandler, mailHandler, adminHandler, schedulerScheduler, prometheusClient, gameMetrics, reporter, registrar, resolver, client, redisClient, configDAO, cfg, v)
 	application := app.InitApp(baseApp, appComponents)
 	return application, func() {
 	}, nil
@@ -122,6 +161,70 @@ func provideGameConfigConfig(cfg *Config) *dao.GameConfigConfig {
 	}
 }
 
+// provideSmeltConfig 提供熔炼配置
+func provideSmeltConfig(cfg *Config) *service.SmeltConfig {
+	return &cfg.Smelt
+}
+
+// provideShopConfig 提供商店配置
+func provideShopConfig(cfg *Config) *service.ShopConfig {
+	return &cfg.Shop
+}
+
+// provideBagConfig 提供背包配置
+func provideBagConfig(cfg *Config) *service.BagConfig {
+	return &cfg.Bag
+}
+
+// provideMailConfig 提供邮件配置
+func provideMailConfig(cfg *Config) *service.MailConfig {
+	return &cfg.Mail
+}
+
+// provideSceneConfig 提供场景配置
+func provideSceneConfig(cfg *Config) *service.SceneConfig {
+	return &cfg.Scene
+}
+
+// provideAOIConfig 提供场景 AOI 配置
+func provideAOIConfig(cfg *Config) *manager.AOIConfig {
+	return &cfg.Scene.AOI
+}
+
+// provideAdminConfig 提供运营后台配置
+func provideAdminConfig(cfg *Config) *handler.AdminConfig {
+	return &cfg.Admin
+}
+
+// provideScheduler 提供定时任务调度器并注册业务定时任务
+func provideScheduler(
+	cfg *Config,
+	l logger.Logger,
+	bagExpiryJob *service.BagExpiryJob,
+	mailPurgeJob *service.MailPurgeJob,
+) (*scheduler.Scheduler, error) {
+	s, err := scheduler.New(cfg.Scheduler, scheduler.WithLogger(l.Named("scheduler")))
+	if err != nil {
+		return nil, err
+	}
+
+	// 过期物品清理：失败的角色会在下次执行或加载背包时再次清理，不整体重试
+	if cfg.Bag.SweepSpec != "" {
+		if _, err := s.AddJob(bagExpiryJob.Name(), cfg.Bag.SweepSpec, bagExpiryJob, scheduler.WithNoRetry()); err != nil {
+			return nil, err
+		}
+	}
+
+	// 过期邮件清理：过期邮件本身不会展示，清理失败等待下次执行即可
+	if cfg.Mail.PurgeSpec != "" {
+		if _, err := s.AddJob(mailPurgeJob.Name(), cfg.Mail.PurgeSpec, mailPurgeJob, scheduler.WithNoRetry()); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
 // provideGRPCServerConfig 提供 gRPC Server 配置
 func provideGRPCServerConfig(cfg *Config) *server.Config {
 	return &cfg.GRPC
@@ -132,6 +235,13 @@ func provideGRPCServerOptions() []server.Option {
 	return nil
 }
 
+// provideGatewaySessionConfig 提供 Gateway 流会话配置（注入 Framer）
+func provideGatewaySessionConfig(cfg *Config, fr framer.Framer) *session.Config {
+	sessCfg := cfg.GatewayStream
+	sessCfg.Framer = fr
+	return &sessCfg
+}
+
 // provideRouter 提供消息路由器
 func provideRouter() router.Router {
 	return router.New()
@@ -173,9 +283,16 @@ func provideAppComponents(
 	grpcServer *server.Server,
 	messageSvc *service.MessageService,
 	gameHandler *handler.GameHandler,
+	gatewayMgr *manager.GatewayManager,
+	gatewaySessCfg *session.Config,
 	dollHandler *handler.DollHandler,
 	gachaHandler *handler.GachaHandler,
 	smeltHandler *handler.SmeltHandler,
+	shopHandler *handler.ShopHandler,
+	bagHandler *handler.BagHandler,
+	mailHandler *handler.MailHandler,
+	adminHandler *handler.AdminHandler,
+	jobScheduler *scheduler.Scheduler,
 	promClient *prometheus.Client,
 	gameMetrics *metrics.GameMetrics,
 	reporter *metrics.Reporter,
@@ -188,10 +305,14 @@ func provideAppComponents(
 	opts []app.Option,
 ) app.AppComponents {
 	gamepb.RegisterGameServiceServer(grpcServer.GetGRPCServer(), gameHandler)
+	common.RegisterCommonServiceServer(grpcServer.GetGRPCServer(), grpc.NewAcceptor(gatewaySessCfg, gatewayMgr))
 	router2 := messageSvc.RoleRouter()
 	dollHandler.RegisterHandlers(router2)
 	gachaHandler.RegisterHandlers(router2)
 	smeltHandler.RegisterHandlers(router2)
+	shopHandler.RegisterHandlers(router2)
+	bagHandler.RegisterHandlers(router2)
+	mailHandler.RegisterHandlers(router2)
 
 	_ = gameMetrics.Register(promClient.Registry())
 
@@ -204,11 +325,23 @@ func provideAppComponents(
 		logger:      baseApp.AppLogger(),
 	}
 
+	servers := []app.Server{
+		grpcServer,
+		serviceStarter,
+		&schedulerServer{scheduler: jobScheduler},
+	}
+
+	if cfg.Admin.Enabled {
+		adminWeb := web.NewServer(&cfg.Admin.Web, baseApp.AppLogger())
+		adminHandler.Register(adminWeb.Router())
+		servers = append(servers, &adminServer{
+			server: adminWeb,
+			logger: baseApp.AppLogger(),
+		})
+	}
+
 	return app.AppComponents{
-		Servers: []app.Server{
-			grpcServer,
-			serviceStarter,
-		},
+		Servers: servers,
 		Closers: []app.Closer{
 			&metricsCloser{
 				reporter:    reporter,
@@ -279,3 +412,51 @@ func (s *serviceRegistrar) Start() error {
 func (s *serviceRegistrar) Stop() error {
 	return nil
 }
+
+// schedulerServer 定时任务调度器启动器，实现 app.Server 接口
+type schedulerServer struct {
+	scheduler *scheduler.Scheduler
+}
+
+func (s *schedulerServer) Start() error {
+	s.scheduler.Start()
+	return nil
+}
+
+func (s *schedulerServer) Stop() error {
+	// 等待正在执行的任务结束
+	<-s.scheduler.Stop().Done()
+	return nil
+}
+
+// adminServer 运营后台 HTTP 服务启动器，实现 app.Server 接口
+type adminServer struct {
+	server *web.Server
+	logger logger.Logger
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (s *adminServer) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		if err := s.server.Run(ctx); err != nil {
+			s.logger.Error("admin server exited with error", "error", err)
+		}
+	}()
+	return nil
+}
+
+func (s *adminServer) Stop() error {
+	if s.cancel == nil {
+		return nil
+	}
+	// 等待 HTTP 服务优雅关闭
+	s.cancel()
+	<-s.done
+	return nil
+}
@@ -1,9 +1,11 @@
 package dao
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/Masterminds/squirrel"
@@ -35,34 +37,84 @@ func (d *BagDAO) GetBag(ctx context.Context, roleID int64, bagType int32) (*mode
 		d.metrics.RecordDBQuery("select", true, time.Since(start).Seconds())
 	}()
 
-	query, args, err := squirrel.
-		Select("items").
+	builder := squirrel.
+		Select("capacity", "items").
 		From("player_bags").
 		Where(squirrel.Eq{"role_id": roleID, "bag_type": bagType}).
-		PlaceholderFormat(squirrel.Dollar).
-		ToSql()
+		PlaceholderFormat(squirrel.Dollar)
+
+	// 事务内读取时加行锁，避免并发修改同一背包（如定时清理与业务操作）互相覆盖
+	if _, ok := TxFromContext(ctx); ok {
+		if err := ensureRow(ctx, d.db, "player_bags", []string{"role_id", "bag_type"}, roleID, bagType); err != nil {
+			return nil, err
+		}
+		builder = builder.Suffix("FOR UPDATE")
+	}
 
+	query, args, err := builder.ToSql()
 	if err != nil {
 		return nil, err
 	}
 
+	bag := model.NewPlayerBag(roleID, bagType)
 	var itemsJSON []byte
-	err = d.db.QueryRow(ctx, query, args...).Scan(&itemsJSON)
+	err = executor(ctx, d.db).QueryRow(ctx, query, args...).Scan(&bag.Capacity, &itemsJSON)
 	if err != nil {
-		if err == postgres.ErrNoRows {
-			return model.NewPlayerBag(roleID, bagType), nil
+		if isNoRows(err) {
+			return bag, nil
 		}
 		return nil, fmt.Errorf("failed to get player bag: %w", err)
 	}
 
-	bag := model.NewPlayerBag(roleID, bagType)
-	if err := json.Unmarshal(itemsJSON, &bag.Items); err != nil {
+	items, err := decodeBagItems(roleID, itemsJSON)
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal bag items: %w", err)
 	}
+	bag.Items = items
 
 	return bag, nil
 }
 
+// decodeBagItems 解析背包物品数据
+// 兼容旧格式 {"配置ID": 数量}：每个配置ID还原为一个永久、未绑定的槽位
+func decodeBagItems(roleID int64, data []byte) ([]*model.Item, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return make([]*model.Item, 0), nil
+	}
+
+	if data[0] != '{' {
+		items := make([]*model.Item, 0)
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+
+	var legacy map[int32]int32
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+	configIDs := make([]int32, 0, len(legacy))
+	for configID, count := range legacy {
+		if count > 0 {
+			configIDs = append(configIDs, configID)
+		}
+	}
+	sort.Slice(configIDs, func(i, j int) bool { return configIDs[i] < configIDs[j] })
+
+	items := make([]*model.Item, 0, len(configIDs))
+	for i, configID := range configIDs {
+		items = append(items, &model.Item{
+			RoleID:    roleID,
+			ConfigID:  configID,
+			SlotIndex: int32(i),
+			Count:     legacy[configID],
+		})
+	}
+	return items, nil
+}
+
 // SaveBag 保存玩家背包 (Upsert)
 func (d *BagDAO) SaveBag(ctx context.Context, bag *model.PlayerBag) error {
 	start := time.Now()
@@ -77,9 +129,9 @@ func (d *BagDAO) SaveBag(ctx context.Context, bag *model.PlayerBag) error {
 
 	query, args, err := squirrel.
 		Insert("player_bags").
-		Columns("role_id", "bag_type", "items", "updated_at").
-		Values(bag.RoleID, bag.BagType, itemsJSON, time.Now()).
-		Suffix("ON CONFLICT (role_id, bag_type) DO UPDATE SET items = EXCLUDED.items, updated_at = EXCLUDED.updated_at").
+		Columns("role_id", "bag_type", "capacity", "items", "updated_at").
+		Values(bag.RoleID, bag.BagType, bag.Capacity, itemsJSON, time.Now()).
+		Suffix("ON CONFLICT (role_id, bag_type) DO UPDATE SET capacity = EXCLUDED.capacity, items = EXCLUDED.items, updated_at = EXCLUDED.updated_at").
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 
@@ -87,7 +139,7 @@ func (d *BagDAO) SaveBag(ctx context.Context, bag *model.PlayerBag) error {
 		return err
 	}
 
-	if _, err := d.db.Exec(ctx, query, args...); err != nil {
+	if _, err := executor(ctx, d.db).Exec(ctx, query, args...); err != nil {
 		return fmt.Errorf("failed to save player bag: %w", err)
 	}
 
@@ -6,6 +6,7 @@ import (
 
 	"github.com/lk2023060901/xdooria/pkg/app"
 	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/levelconfig"
 )
 
 // GameConfigConfig 游戏配置表加载配置
@@ -36,5 +37,10 @@ func NewConfigDAO(gameCfg *GameConfigConfig, a *app.BaseApp) (*ConfigDAO, error)
 		return nil, fmt.Errorf("failed to load global gameconfig: %w", err)
 	}
 
+	// 4. 加载手工维护的等级配置到 levelconfig.T
+	if err := levelconfig.Load(dataDir, l); err != nil {
+		return nil, fmt.Errorf("failed to load level config: %w", err)
+	}
+
 	return &ConfigDAO{}, nil
 }
@@ -34,32 +34,43 @@ type dollBagData struct {
 	Dolls []*model.Doll `json:"dolls"`
 }
 
-// ListByPlayerID 获取玩家所有玩偶
+// ListByPlayerID 获取玩家所有玩偶，事务中会锁定玩偶背包行直到提交
 func (d *DollDAO) ListByPlayerID(ctx context.Context, playerID int64) ([]*model.Doll, error) {
 	start := time.Now()
 	defer func() {
 		d.metrics.RecordDBQuery("select", true, time.Since(start).Seconds())
 	}()
 
-	query, args, err := squirrel.
+	builder := squirrel.
 		Select("data").
 		From("player_bags").
 		Where(squirrel.Eq{"role_id": playerID, "bag_type": gameconfig.BagType_Costume}).
-		PlaceholderFormat(squirrel.Dollar).
-		ToSql()
+		PlaceholderFormat(squirrel.Dollar)
 
+	// 玩偶列表整体读出、修改后写回，事务内读取时加行锁，避免并发修改互相覆盖
+	if _, ok := TxFromContext(ctx); ok {
+		if err := ensureRow(ctx, d.db, "player_bags", []string{"role_id", "bag_type"}, playerID, gameconfig.BagType_Costume); err != nil {
+			return nil, err
+		}
+		builder = builder.Suffix("FOR UPDATE")
+	}
+
+	query, args, err := builder.ToSql()
 	if err != nil {
 		return nil, err
 	}
 
 	var data []byte
-	err = d.db.QueryRow(ctx, query, args...).Scan(&data)
+	err = executor(ctx, d.db).QueryRow(ctx, query, args...).Scan(&data)
 	if err != nil {
-		if err == postgres.ErrNoRows {
+		if isNoRows(err) {
 			return []*model.Doll{}, nil
 		}
 		return nil, fmt.Errorf("failed to get dolls: %w", err)
 	}
+	if len(data) == 0 {
+		return []*model.Doll{}, nil
+	}
 
 	var bagData dollBagData
 	if err := json.Unmarshal(data, &bagData); err != nil {
@@ -94,7 +105,7 @@ func (d *DollDAO) saveDolls(ctx context.Context, playerID int64, dolls []*model.
 		return err
 	}
 
-	if _, err := d.db.Exec(ctx, query, args...); err != nil {
+	if _, err := executor(ctx, d.db).Exec(ctx, query, args...); err != nil {
 		return fmt.Errorf("failed to save dolls: %w", err)
 	}
 
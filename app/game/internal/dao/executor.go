@@ -0,0 +1,66 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/lk2023060901/xdooria/pkg/database/postgres"
+)
+
+// Executor 数据库执行器（*postgres.Client 与 postgres.Tx 均实现该接口）
+type Executor interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (int64, error)
+}
+
+// txContextKey 事务在 context 中的 key
+type txContextKey struct{}
+
+// WithTx 将事务绑定到 context，之后使用该 context 的 DAO 调用都会在此事务内执行
+func WithTx(ctx context.Context, tx postgres.Tx) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// TxFromContext 从 context 中获取事务
+func TxFromContext(ctx context.Context) (postgres.Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(postgres.Tx)
+	return tx, ok
+}
+
+// executor 获取当前应使用的执行器：context 中有事务时走事务，否则直接走连接池
+func executor(ctx context.Context, db *postgres.Client) Executor {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return db
+}
+
+// ensureRow 事务内加锁读取前插入只含主键的默认行，行已存在时不做任何事。
+// 行不存在时 SELECT ... FOR UPDATE 锁不到任何行，并发的首次写入仍会互相覆盖；
+// 先插入后，并发事务会在主键冲突处等待先插入的事务提交，随后在行锁处排队
+func ensureRow(ctx context.Context, db *postgres.Client, table string, columns []string, values ...any) error {
+	query, args, err := squirrel.
+		Insert(table).
+		Columns(columns...).
+		Values(values...).
+		Suffix("ON CONFLICT DO NOTHING").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := executor(ctx, db).Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to ensure %s row: %w", table, err)
+	}
+	return nil
+}
+
+// isNoRows 判断是否为未查询到记录
+// QueryRow().Scan() 返回的是 pgx.ErrNoRows，这里同时兼容 postgres.ErrNoRows
+func isNoRows(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows) || errors.Is(err, postgres.ErrNoRows)
+}
@@ -29,7 +29,7 @@ func NewGachaDAO(db *postgres.Client, l logger.Logger, m *metrics.GameMetrics) *
 	}
 }
 
-// GetByRoleID 查询玩家抽卡记录
+// GetByRoleID 查询玩家抽卡记录，事务中会锁定该行直到提交
 func (d *GachaDAO) GetByRoleID(ctx context.Context, roleID int64) (*model.PlayerGacha, error) {
 	start := time.Now()
 	defer func() {
@@ -37,21 +37,29 @@ func (d *GachaDAO) GetByRoleID(ctx context.Context, roleID int64) (*model.Player
 		d.metrics.RecordDBQuery("select", true, duration)
 	}()
 
-	query, args, err := squirrel.
+	builder := squirrel.
 		Select("records").
 		From("player_gacha").
 		Where(squirrel.Eq{"role_id": roleID}).
-		PlaceholderFormat(squirrel.Dollar).
-		ToSql()
+		PlaceholderFormat(squirrel.Dollar)
+
+	// 事务内读取时加行锁，避免同一角色并发抽卡互相覆盖保底计数
+	if _, ok := TxFromContext(ctx); ok {
+		if err := ensureRow(ctx, d.db, "player_gacha", []string{"role_id"}, roleID); err != nil {
+			return nil, err
+		}
+		builder = builder.Suffix("FOR UPDATE")
+	}
 
+	query, args, err := builder.ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("failed to build query: %w", err)
 	}
 
 	var recordsJSON []byte
-	err = d.db.QueryRow(ctx, query, args...).Scan(&recordsJSON)
+	err = executor(ctx, d.db).QueryRow(ctx, query, args...).Scan(&recordsJSON)
 	if err != nil {
-		if err == postgres.ErrNoRows {
+		if isNoRows(err) {
 			return &model.PlayerGacha{RoleID: roleID, Records: []*model.GachaRecord{}}, nil
 		}
 		return nil, fmt.Errorf("failed to get player gacha: %w", err)
@@ -93,9 +101,251 @@ func (d *GachaDAO) Save(ctx context.Context, gacha *model.PlayerGacha) error {
 		return fmt.Errorf("failed to build query: %w", err)
 	}
 
-	if _, err := d.db.Exec(ctx, query, args...); err != nil {
+	if _, err := executor(ctx, d.db).Exec(ctx, query, args...); err != nil {
 		return fmt.Errorf("failed to save player gacha: %w", err)
 	}
 
 	return nil
 }
+
+// InsertDrawLogs 批量追加抽卡流水
+func (d *GachaDAO) InsertDrawLogs(ctx context.Context, logs []*model.GachaDrawLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		d.metrics.RecordDBQuery("insert", true, duration)
+	}()
+
+	builder := squirrel.
+		Insert("gacha_draw_logs").
+		Columns("role_id", "pool_id", "drop_id", "seed", "is_pity", "pity_count", "results", "created_at").
+		PlaceholderFormat(squirrel.Dollar)
+
+	for _, l := range logs {
+		resultsJSON, err := json.Marshal(l.Results)
+		if err != nil {
+			return fmt.Errorf("failed to marshal gacha draw results: %w", err)
+		}
+		builder = builder.Values(l.RoleID, l.PoolID, l.DropID, l.Seed, l.IsPity, l.PityCount, resultsJSON, time.Unix(l.CreatedAt, 0))
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := executor(ctx, d.db).Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to insert gacha draw logs: %w", err)
+	}
+
+	return nil
+}
+
+// ListDrawLogs 按时间倒序分页查询玩家抽卡流水，poolID 为 0 时查询全部池子
+// 返回当前页流水与总条数
+func (d *GachaDAO) ListDrawLogs(ctx context.Context, roleID int64, poolID int32, offset, limit uint64) ([]*model.GachaDrawLog, int64, error) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		d.metrics.RecordDBQuery("select", true, duration)
+	}()
+
+	where := squirrel.Eq{"role_id": roleID}
+	if poolID != 0 {
+		where["pool_id"] = poolID
+	}
+
+	countQuery, countArgs, err := squirrel.
+		Select("COUNT(*)").
+		From("gacha_draw_logs").
+		Where(where).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var total int64
+	if err := d.db.QueryRow(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count gacha draw logs: %w", err)
+	}
+	if total == 0 || offset >= uint64(total) {
+		return []*model.GachaDrawLog{}, total, nil
+	}
+
+	query, args, err := squirrel.
+		Select("id", "role_id", "pool_id", "drop_id", "seed", "is_pity", "pity_count", "results", "created_at").
+		From("gacha_draw_logs").
+		Where(where).
+		OrderBy("id DESC").
+		Offset(offset).
+		Limit(limit).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := d.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list gacha draw logs: %w", err)
+	}
+	defer rows.Close()
+
+	logs := make([]*model.GachaDrawLog, 0, limit)
+	for rows.Next() {
+		var (
+			l           model.GachaDrawLog
+			resultsJSON []byte
+			createdAt   time.Time
+		)
+		if err := rows.Scan(&l.ID, &l.RoleID, &l.PoolID, &l.DropID, &l.Seed, &l.IsPity, &l.PityCount, &resultsJSON, &createdAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan gacha draw log: %w", err)
+		}
+		if err := json.Unmarshal(resultsJSON, &l.Results); err != nil {
+			return nil, 0, fmt.Errorf("failed to unmarshal gacha draw results: %w", err)
+		}
+		l.CreatedAt = createdAt.Unix()
+		logs = append(logs, &l)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to iterate gacha draw logs: %w", err)
+	}
+
+	return logs, total, nil
+}
+
+// GetDrawLog 按ID查询抽卡流水，不存在时返回 nil
+func (d *GachaDAO) GetDrawLog(ctx context.Context, id int64) (*model.GachaDrawLog, error) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		d.metrics.RecordDBQuery("select", true, duration)
+	}()
+
+	query, args, err := squirrel.
+		Select("id", "role_id", "pool_id", "drop_id", "seed", "is_pity", "pity_count", "results", "created_at").
+		From("gacha_draw_logs").
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var (
+		l           model.GachaDrawLog
+		resultsJSON []byte
+		createdAt   time.Time
+	)
+	err = d.db.QueryRow(ctx, query, args...).Scan(&l.ID, &l.RoleID, &l.PoolID, &l.DropID, &l.Seed, &l.IsPity, &l.PityCount, &resultsJSON, &createdAt)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get gacha draw log: %w", err)
+	}
+	if err := json.Unmarshal(resultsJSON, &l.Results); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal gacha draw results: %w", err)
+	}
+	l.CreatedAt = createdAt.Unix()
+
+	return &l, nil
+}
+
+// GetPoolStats 按掉落ID聚合池子在 [from, to) 内的实际产出
+func (d *GachaDAO) GetPoolStats(ctx context.Context, poolID int32, from, to time.Time) ([]*model.GachaDropStats, error) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		d.metrics.RecordDBQuery("select", true, duration)
+	}()
+
+	where := squirrel.And{
+		squirrel.Eq{"l.pool_id": poolID},
+		squirrel.GtOrEq{"l.created_at": from},
+		squirrel.Lt{"l.created_at": to},
+	}
+
+	// 1. 每个掉落ID的抽取次数
+	drawQuery, drawArgs, err := squirrel.
+		Select("l.drop_id", "COUNT(*)").
+		From("gacha_draw_logs l").
+		Where(where).
+		GroupBy("l.drop_id").
+		OrderBy("l.drop_id").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := d.db.Query(ctx, drawQuery, drawArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query gacha draw counts: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []*model.GachaDropStats
+	byDrop := make(map[int32]*model.GachaDropStats)
+	for rows.Next() {
+		s := &model.GachaDropStats{}
+		if err := rows.Scan(&s.DropID, &s.Draws); err != nil {
+			return nil, fmt.Errorf("failed to scan gacha draw count: %w", err)
+		}
+		stats = append(stats, s)
+		byDrop[s.DropID] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate gacha draw counts: %w", err)
+	}
+	rows.Close()
+
+	// 2. 每个掉落ID下各道具的命中次数与产出总量
+	itemQuery, itemArgs, err := squirrel.
+		Select("l.drop_id", "(r->>'item_id')::INT", "COUNT(*)", "SUM((r->>'count')::BIGINT)").
+		From("gacha_draw_logs l").
+		CrossJoin("LATERAL jsonb_array_elements(l.results) r").
+		Where(where).
+		GroupBy("1", "2").
+		OrderBy("1", "2").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	itemRows, err := d.db.Query(ctx, itemQuery, itemArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query gacha item stats: %w", err)
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var (
+			dropID int32
+			item   model.GachaItemStats
+		)
+		if err := itemRows.Scan(&dropID, &item.ItemID, &item.Hits, &item.Total); err != nil {
+			return nil, fmt.Errorf("failed to scan gacha item stats: %w", err)
+		}
+		if s, ok := byDrop[dropID]; ok {
+			s.Items = append(s.Items, &item)
+		}
+	}
+	if err := itemRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate gacha item stats: %w", err)
+	}
+
+	return stats, nil
+}
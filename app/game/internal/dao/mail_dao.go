@@ -0,0 +1,371 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lk2023060901/xdooria/app/game/internal/metrics"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/database/postgres"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// mailColumns 角色邮件查询列（顺序与 scanMail 一致）
+var mailColumns = []string{
+	"id", "role_id", "global_id", "mail_type", "sender", "title", "content",
+	"attachments", "is_read", "is_claimed", "send_time", "expire_time",
+}
+
+// MailDAO 邮件数据访问对象
+type MailDAO struct {
+	db      *postgres.Client
+	logger  logger.Logger
+	metrics *metrics.GameMetrics
+}
+
+// NewMailDAO 创建邮件 DAO
+func NewMailDAO(db *postgres.Client, l logger.Logger, m *metrics.GameMetrics) *MailDAO {
+	return &MailDAO{
+		db:      db,
+		logger:  l.Named("dao.mail"),
+		metrics: m,
+	}
+}
+
+// InsertMails 批量写入角色邮件
+// 同一封全服邮件对同一角色只写入一次，重复投递时忽略
+func (d *MailDAO) InsertMails(ctx context.Context, mails []*model.Mail) error {
+	if len(mails) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDBQuery("insert", true, time.Since(start).Seconds())
+	}()
+
+	builder := squirrel.
+		Insert("role_mails").
+		Columns("role_id", "global_id", "mail_type", "sender", "title", "content", "attachments", "send_time", "expire_time").
+		Suffix("ON CONFLICT (role_id, global_id) WHERE global_id > 0 DO NOTHING").
+		PlaceholderFormat(squirrel.Dollar)
+
+	for _, m := range mails {
+		attachmentsJSON, err := marshalAttachments(m.Attachments)
+		if err != nil {
+			return err
+		}
+		builder = builder.Values(m.RoleID, m.GlobalID, m.MailType, m.Sender, m.Title, m.Content, attachmentsJSON, m.SendTime, m.ExpireTime)
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := executor(ctx, d.db).Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to insert mails: %w", err)
+	}
+
+	return nil
+}
+
+// InsertGlobalMail 写入全服邮件并回填邮件ID
+func (d *MailDAO) InsertGlobalMail(ctx context.Context, mail *model.GlobalMail) error {
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDBQuery("insert", true, time.Since(start).Seconds())
+	}()
+
+	attachmentsJSON, err := marshalAttachments(mail.Attachments)
+	if err != nil {
+		return err
+	}
+
+	roleIDs := mail.Target.RoleIDs
+	if roleIDs == nil {
+		roleIDs = []int64{}
+	}
+
+	query, args, err := squirrel.
+		Insert("global_mails").
+		Columns("sender", "title", "content", "attachments", "target_type", "min_level", "max_level", "role_ids", "send_time", "expire_time").
+		Values(mail.Sender, mail.Title, mail.Content, attachmentsJSON,
+			mail.Target.Type, mail.Target.MinLevel, mail.Target.MaxLevel, roleIDs,
+			mail.SendTime, mail.ExpireTime).
+		Suffix("RETURNING id").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if err := executor(ctx, d.db).QueryRow(ctx, query, args...).Scan(&mail.ID); err != nil {
+		return fmt.Errorf("failed to insert global mail: %w", err)
+	}
+
+	return nil
+}
+
+// ListPendingGlobalMails 查询尚未投递给角色且未过期的全服邮件（不做投放目标筛选）
+func (d *MailDAO) ListPendingGlobalMails(ctx context.Context, roleID int64, now int64) ([]*model.GlobalMail, error) {
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDBQuery("select", true, time.Since(start).Seconds())
+	}()
+
+	query, args, err := squirrel.
+		Select("g.id", "g.sender", "g.title", "g.content", "g.attachments",
+			"g.target_type", "g.min_level", "g.max_level", "g.role_ids", "g.send_time", "g.expire_time").
+		From("global_mails g").
+		Where(squirrel.LtOrEq{"g.send_time": now}).
+		Where(squirrel.Or{squirrel.Eq{"g.expire_time": 0}, squirrel.Gt{"g.expire_time": now}}).
+		Where("NOT EXISTS (SELECT 1 FROM role_mails m WHERE m.role_id = ? AND m.global_id = g.id)", roleID).
+		OrderBy("g.id").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := d.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list global mails: %w", err)
+	}
+	defer rows.Close()
+
+	mails := make([]*model.GlobalMail, 0)
+	for rows.Next() {
+		var (
+			g               model.GlobalMail
+			attachmentsJSON []byte
+		)
+		if err := rows.Scan(&g.ID, &g.Sender, &g.Title, &g.Content, &attachmentsJSON,
+			&g.Target.Type, &g.Target.MinLevel, &g.Target.MaxLevel, &g.Target.RoleIDs,
+			&g.SendTime, &g.ExpireTime); err != nil {
+			return nil, fmt.Errorf("failed to scan global mail: %w", err)
+		}
+		if err := json.Unmarshal(attachmentsJSON, &g.Attachments); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal mail attachments: %w", err)
+		}
+		mails = append(mails, &g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate global mails: %w", err)
+	}
+
+	return mails, nil
+}
+
+// ListByRoleID 按发送时间倒序查询角色未删除、未过期的邮件，最多 limit 封
+func (d *MailDAO) ListByRoleID(ctx context.Context, roleID int64, now int64, limit uint64) ([]*model.Mail, error) {
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDBQuery("select", true, time.Since(start).Seconds())
+	}()
+
+	query, args, err := squirrel.
+		Select(mailColumns...).
+		From("role_mails").
+		Where(squirrel.Eq{"role_id": roleID, "is_deleted": false}).
+		Where(squirrel.Or{squirrel.Eq{"expire_time": 0}, squirrel.Gt{"expire_time": now}}).
+		OrderBy("send_time DESC", "id DESC").
+		Limit(limit).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := d.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mails: %w", err)
+	}
+	defer rows.Close()
+
+	mails := make([]*model.Mail, 0)
+	for rows.Next() {
+		m, err := scanMail(rows)
+		if err != nil {
+			return nil, err
+		}
+		mails = append(mails, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate mails: %w", err)
+	}
+
+	return mails, nil
+}
+
+// GetByID 查询角色的指定邮件（不含已删除邮件），不存在时返回 nil
+// 事务内读取时加行锁，避免并发领取同一封邮件
+func (d *MailDAO) GetByID(ctx context.Context, roleID int64, mailID int64) (*model.Mail, error) {
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDBQuery("select", true, time.Since(start).Seconds())
+	}()
+
+	builder := squirrel.
+		Select(mailColumns...).
+		From("role_mails").
+		Where(squirrel.Eq{"id": mailID, "role_id": roleID, "is_deleted": false}).
+		PlaceholderFormat(squirrel.Dollar)
+
+	if _, ok := TxFromContext(ctx); ok {
+		builder = builder.Suffix("FOR UPDATE")
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	m, err := scanMail(executor(ctx, d.db).QueryRow(ctx, query, args...))
+	if err != nil {
+		if isNoRows(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// UpdateState 更新邮件的已读与领取状态
+func (d *MailDAO) UpdateState(ctx context.Context, mail *model.Mail) error {
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDBQuery("update", true, time.Since(start).Seconds())
+	}()
+
+	query, args, err := squirrel.
+		Update("role_mails").
+		Set("is_read", mail.IsRead).
+		Set("is_claimed", mail.IsClaimed).
+		Where(squirrel.Eq{"id": mail.ID, "role_id": mail.RoleID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := executor(ctx, d.db).Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to update mail state: %w", err)
+	}
+
+	return nil
+}
+
+// MarkDeleted 将角色的邮件标记为已删除
+// 记录保留到邮件过期，防止全服邮件被重新投递
+func (d *MailDAO) MarkDeleted(ctx context.Context, roleID int64, mailIDs []int64) error {
+	if len(mailIDs) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDBQuery("update", true, time.Since(start).Seconds())
+	}()
+
+	query, args, err := squirrel.
+		Update("role_mails").
+		Set("is_deleted", true).
+		Where(squirrel.Eq{"role_id": roleID, "id": mailIDs}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := executor(ctx, d.db).Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete mails: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired 物理删除已过期的角色邮件与全服邮件，返回删除的角色邮件数量
+func (d *MailDAO) DeleteExpired(ctx context.Context, now int64) (int64, error) {
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDBQuery("delete", true, time.Since(start).Seconds())
+	}()
+
+	expired := squirrel.And{squirrel.Gt{"expire_time": 0}, squirrel.LtOrEq{"expire_time": now}}
+
+	query, args, err := squirrel.
+		Delete("role_mails").
+		Where(expired).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	deleted, err := executor(ctx, d.db).Exec(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired mails: %w", err)
+	}
+
+	query, args, err = squirrel.
+		Delete("global_mails").
+		Where(expired).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := executor(ctx, d.db).Exec(ctx, query, args...); err != nil {
+		return 0, fmt.Errorf("failed to delete expired global mails: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// rowScanner pgx.Row 与 pgx.Rows 共有的 Scan 方法
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanMail 按 mailColumns 的顺序扫描一行角色邮件
+func scanMail(row rowScanner) (*model.Mail, error) {
+	var (
+		m               model.Mail
+		attachmentsJSON []byte
+	)
+	if err := row.Scan(&m.ID, &m.RoleID, &m.GlobalID, &m.MailType, &m.Sender, &m.Title, &m.Content,
+		&attachmentsJSON, &m.IsRead, &m.IsClaimed, &m.SendTime, &m.ExpireTime); err != nil {
+		return nil, fmt.Errorf("failed to scan mail: %w", err)
+	}
+
+	if err := json.Unmarshal(attachmentsJSON, &m.Attachments); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mail attachments: %w", err)
+	}
+
+	return &m, nil
+}
+
+// marshalAttachments 序列化邮件附件，nil 序列化为空数组
+func marshalAttachments(attachments []*model.MailAttachment) ([]byte, error) {
+	if attachments == nil {
+		attachments = []*model.MailAttachment{}
+	}
+	data, err := json.Marshal(attachments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal mail attachments: %w", err)
+	}
+	return data, nil
+}
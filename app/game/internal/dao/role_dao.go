@@ -322,6 +322,73 @@ func (d *RoleDAO) UpdateLastLogin(ctx context.Context, roleID int64) error {
 	return nil
 }
 
+// GetGrowth 获取角色等级成长数据，事务中会锁定该行直到提交
+func (d *RoleDAO) GetGrowth(ctx context.Context, roleID int64) (*model.RoleGrowth, error) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		d.metrics.RecordDBQuery("select", true, duration)
+	}()
+
+	builder := squirrel.
+		Select("level", "exp", "vip_exp").
+		From("roles").
+		Where(squirrel.Eq{"id": roleID}).
+		PlaceholderFormat(squirrel.Dollar)
+
+	if _, ok := TxFromContext(ctx); ok {
+		builder = builder.Suffix("FOR UPDATE")
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var g model.RoleGrowth
+	if err := executor(ctx, d.db).QueryRow(ctx, query, args...).Scan(&g.Level, &g.Exp, &g.VIPExp); err != nil {
+		d.logger.Error("failed to get role growth",
+			"role_id", roleID,
+			"error", err,
+		)
+		return nil, fmt.Errorf("failed to get role growth: %w", err)
+	}
+
+	return &g, nil
+}
+
+// UpdateGrowth 更新角色等级、经验与 VIP 经验（支持在事务中执行）
+func (d *RoleDAO) UpdateGrowth(ctx context.Context, roleID int64, g *model.RoleGrowth) error {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start).Seconds()
+		d.metrics.RecordDBQuery("update", true, duration)
+	}()
+
+	query, args, err := squirrel.
+		Update("roles").
+		Set("level", g.Level).
+		Set("exp", g.Exp).
+		Set("vip_exp", g.VIPExp).
+		Where(squirrel.Eq{"id": roleID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := executor(ctx, d.db).Exec(ctx, query, args...); err != nil {
+		d.logger.Error("failed to update role growth",
+			"role_id", roleID,
+			"error", err,
+		)
+		return fmt.Errorf("failed to update role growth: %w", err)
+	}
+
+	return nil
+}
+
 // CheckNicknameExists 检查昵称是否已存在
 func (d *RoleDAO) CheckNicknameExists(ctx context.Context, nickname string) (bool, error) {
 	start := time.Now()
@@ -0,0 +1,184 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lk2023060901/xdooria/app/game/internal/metrics"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/database/postgres"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// ShopDAO 商店数据访问对象
+type ShopDAO struct {
+	db      *postgres.Client
+	logger  logger.Logger
+	metrics *metrics.GameMetrics
+}
+
+// NewShopDAO 创建商店 DAO
+func NewShopDAO(db *postgres.Client, l logger.Logger, m *metrics.GameMetrics) *ShopDAO {
+	return &ShopDAO{
+		db:      db,
+		logger:  l.Named("dao.shop"),
+		metrics: m,
+	}
+}
+
+// GetByRoleID 查询玩家商店数据，事务中会锁定该行直到提交
+func (d *ShopDAO) GetByRoleID(ctx context.Context, roleID int64) (*model.PlayerShop, error) {
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDBQuery("select", true, time.Since(start).Seconds())
+	}()
+
+	builder := squirrel.
+		Select("purchases", "mystery").
+		From("player_shop").
+		Where(squirrel.Eq{"role_id": roleID}).
+		PlaceholderFormat(squirrel.Dollar)
+
+	// 事务内读取时加行锁，避免同一角色并发购买都通过限购检查后互相覆盖购买次数
+	if _, ok := TxFromContext(ctx); ok {
+		if err := ensureRow(ctx, d.db, "player_shop", []string{"role_id"}, roleID); err != nil {
+			return nil, err
+		}
+		builder = builder.Suffix("FOR UPDATE")
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	shop := &model.PlayerShop{
+		RoleID:    roleID,
+		Purchases: []*model.ShopPurchase{},
+		Mystery:   []*model.MysteryShop{},
+	}
+
+	var purchasesJSON, mysteryJSON []byte
+	err = executor(ctx, d.db).QueryRow(ctx, query, args...).Scan(&purchasesJSON, &mysteryJSON)
+	if err != nil {
+		if isNoRows(err) {
+			return shop, nil
+		}
+		return nil, fmt.Errorf("failed to get player shop: %w", err)
+	}
+
+	if err := json.Unmarshal(purchasesJSON, &shop.Purchases); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal shop purchases: %w", err)
+	}
+	if err := json.Unmarshal(mysteryJSON, &shop.Mystery); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mystery shops: %w", err)
+	}
+
+	return shop, nil
+}
+
+// Save 保存玩家商店数据 (Upsert)
+func (d *ShopDAO) Save(ctx context.Context, shop *model.PlayerShop) error {
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDBQuery("upsert", true, time.Since(start).Seconds())
+	}()
+
+	purchasesJSON, err := json.Marshal(shop.Purchases)
+	if err != nil {
+		return fmt.Errorf("failed to marshal shop purchases: %w", err)
+	}
+	mysteryJSON, err := json.Marshal(shop.Mystery)
+	if err != nil {
+		return fmt.Errorf("failed to marshal mystery shops: %w", err)
+	}
+
+	query, args, err := squirrel.
+		Insert("player_shop").
+		Columns("role_id", "purchases", "mystery", "updated_at").
+		Values(shop.RoleID, purchasesJSON, mysteryJSON, time.Now()).
+		Suffix("ON CONFLICT (role_id) DO UPDATE SET purchases = EXCLUDED.purchases, mystery = EXCLUDED.mystery, updated_at = EXCLUDED.updated_at").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := executor(ctx, d.db).Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to save player shop: %w", err)
+	}
+
+	return nil
+}
+
+// GetGlobalSold 查询商品在指定周期内的全服已售数量
+func (d *ShopDAO) GetGlobalSold(ctx context.Context, shopItemID int32, periodStart int64) (int32, error) {
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDBQuery("select", true, time.Since(start).Seconds())
+	}()
+
+	query, args, err := squirrel.
+		Select("sold").
+		From("shop_global_stock").
+		Where(squirrel.Eq{"shop_item_id": shopItemID, "period_start": periodStart}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var sold int32
+	err = executor(ctx, d.db).QueryRow(ctx, query, args...).Scan(&sold)
+	if err != nil {
+		if isNoRows(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get global stock: %w", err)
+	}
+
+	return sold, nil
+}
+
+// AddGlobalSold 原子地增加全服已售数量
+// 累加后超过 limit 时不做修改并返回 false
+func (d *ShopDAO) AddGlobalSold(ctx context.Context, shopItemID int32, periodStart int64, count int32, limit int32) (bool, error) {
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDBQuery("upsert", true, time.Since(start).Seconds())
+	}()
+
+	if count > limit {
+		return false, nil
+	}
+
+	query, args, err := squirrel.
+		Insert("shop_global_stock").
+		Columns("shop_item_id", "period_start", "sold", "updated_at").
+		Values(shopItemID, periodStart, count, time.Now()).
+		Suffix("ON CONFLICT (shop_item_id, period_start) DO UPDATE SET sold = shop_global_stock.sold + EXCLUDED.sold, updated_at = EXCLUDED.updated_at "+
+			"WHERE shop_global_stock.sold + EXCLUDED.sold <= ? RETURNING sold", limit).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return false, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var sold int32
+	err = executor(ctx, d.db).QueryRow(ctx, query, args...).Scan(&sold)
+	if err != nil {
+		if isNoRows(err) {
+			// WHERE 条件不满足，库存不足
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to add global stock: %w", err)
+	}
+
+	return true, nil
+}
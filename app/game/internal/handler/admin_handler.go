@@ -0,0 +1,391 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/app/game/internal/service"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/web"
+)
+
+// AdminConfig 运营后台 HTTP 接口配置
+type AdminConfig struct {
+	// Enabled 是否启动运营后台 HTTP 服务
+	Enabled bool `mapstructure:"enabled"`
+
+	// Token 访问令牌，请求需携带 "Authorization: Bearer <token>"；为空时拒绝所有请求
+	Token string `mapstructure:"token"`
+
+	// Web HTTP 服务配置
+	Web web.Config `mapstructure:"web"`
+}
+
+// defaultGachaStatsRange 抽卡概率审计未指定起始时间时统计的时长
+const defaultGachaStatsRange = 24 * time.Hour
+
+// AdminHandler 运营后台 HTTP 接口（发送补偿邮件、发放经验、抽卡概率审计等）
+type AdminHandler struct {
+	logger   logger.Logger
+	token    string
+	mailSvc  *service.MailService
+	levelSvc *service.LevelService
+	gachaSvc *service.GachaService
+}
+
+// NewAdminHandler 创建运营后台处理器
+func NewAdminHandler(l logger.Logger, cfg *AdminConfig, mailSvc *service.MailService, levelSvc *service.LevelService, gachaSvc *service.GachaService) *AdminHandler {
+	return &AdminHandler{
+		logger:   l.Named("handler.admin"),
+		token:    cfg.Token,
+		mailSvc:  mailSvc,
+		levelSvc: levelSvc,
+		gachaSvc: gachaSvc,
+	}
+}
+
+// AdminMailAttachment 邮件附件
+type AdminMailAttachment struct {
+	ItemID int32 `json:"item_id"`
+	Count  int32 `json:"count"`
+}
+
+// AdminMailContent 邮件内容
+type AdminMailContent struct {
+	Sender      string                `json:"sender"`
+	Title       string                `json:"title" binding:"required"`
+	Content     string                `json:"content"`
+	Attachments []AdminMailAttachment `json:"attachments"`
+	ExpireTime  int64                 `json:"expire_time"` // 过期时间 (Unix)，0 使用默认有效期
+}
+
+// AdminSendMailRequest 向指定角色发送邮件请求
+type AdminSendMailRequest struct {
+	AdminMailContent
+	RoleIDs []int64 `json:"role_ids" binding:"required"`
+}
+
+// AdminSendMailResponse 向指定角色发送邮件响应
+type AdminSendMailResponse struct {
+	Sent int `json:"sent"`
+}
+
+// AdminSendGlobalMailRequest 发送全服邮件请求
+type AdminSendGlobalMailRequest struct {
+	AdminMailContent
+	TargetType int32   `json:"target_type"` // 0全部 1等级区间 2角色列表
+	MinLevel   int32   `json:"min_level"`
+	MaxLevel   int32   `json:"max_level"`
+	RoleIDs    []int64 `json:"role_ids"`
+}
+
+// AdminSendGlobalMailResponse 发送全服邮件响应
+type AdminSendGlobalMailResponse struct {
+	GlobalID   int64 `json:"global_id"`
+	ExpireTime int64 `json:"expire_time"`
+}
+
+// AdminAddExpRequest 发放角色经验请求
+type AdminAddExpRequest struct {
+	RoleID int64  `json:"role_id" binding:"required"`
+	Exp    int64  `json:"exp" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// AdminAddExpResponse 发放角色经验响应
+type AdminAddExpResponse struct {
+	OldLevel     int32 `json:"old_level"`
+	Level        int32 `json:"level"`
+	Exp          int64 `json:"exp"`
+	VIPExp       int64 `json:"vip_exp"`
+	VIPLevel     int32 `json:"vip_level"`
+	RewardByMail bool  `json:"reward_by_mail"`
+}
+
+// AdminGachaItemStats 单个道具的实际与理论产出
+type AdminGachaItemStats struct {
+	ItemID        int32   `json:"item_id"`
+	Hits          int64   `json:"hits"`
+	Total         int64   `json:"total"`
+	ActualRate    float64 `json:"actual_rate"`
+	ExpectedRate  float64 `json:"expected_rate"`
+	ActualCount   float64 `json:"actual_count"`
+	ExpectedCount float64 `json:"expected_count"`
+	ZScore        float64 `json:"z_score"`
+}
+
+// AdminGachaDropStats 池子下某个掉落ID的产出统计
+type AdminGachaDropStats struct {
+	DropID int32                 `json:"drop_id"`
+	IsPity bool                  `json:"is_pity"`
+	Draws  int64                 `json:"draws"`
+	Items  []AdminGachaItemStats `json:"items"`
+}
+
+// AdminGachaPoolStatsResponse 池子概率审计响应
+type AdminGachaPoolStatsResponse struct {
+	PoolID int32                 `json:"pool_id"`
+	From   int64                 `json:"from"` // 统计起始时间 (Unix，含)
+	To     int64                 `json:"to"`   // 统计结束时间 (Unix，不含)
+	Drops  []AdminGachaDropStats `json:"drops"`
+}
+
+// AdminGachaDrawItem 抽卡产出
+type AdminGachaDrawItem struct {
+	ItemID int32 `json:"item_id"`
+	Count  int32 `json:"count"`
+}
+
+// AdminReplayGachaDrawResponse 抽卡流水重放响应
+type AdminReplayGachaDrawResponse struct {
+	ID        int64                `json:"id"`
+	RoleID    int64                `json:"role_id"`
+	PoolID    int32                `json:"pool_id"`
+	DropID    int32                `json:"drop_id"`
+	Seed      int64                `json:"seed"`
+	CreatedAt int64                `json:"created_at"`
+	Recorded  []AdminGachaDrawItem `json:"recorded"` // 流水记录的产出
+	Replayed  []AdminGachaDrawItem `json:"replayed"` // 按当前配置重放的产出
+	Match     bool                 `json:"match"`
+}
+
+// Register 注册路由
+func (h *AdminHandler) Register(r *gin.Engine) {
+	admin := r.Group("/admin/v1", h.auth)
+	{
+		admin.POST("/mails", h.SendMail)
+		admin.POST("/global-mails", h.SendGlobalMail)
+		admin.POST("/roles/exp", h.AddExp)
+		admin.GET("/gacha/pools/:id/stats", h.GetGachaPoolStats)
+		admin.GET("/gacha/draws/:id/replay", h.ReplayGachaDraw)
+	}
+}
+
+// auth 校验访问令牌
+func (h *AdminHandler) auth(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || h.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		h.logger.Warn("admin request unauthorized", "path", c.Request.URL.Path, "client_ip", c.ClientIP())
+		web.AbortWithError(c, http.StatusUnauthorized, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	c.Next()
+}
+
+// SendMail 向指定角色发送系统邮件（角色离线也可送达）
+// @Router /admin/v1/mails [post]
+func (h *AdminHandler) SendMail(c *gin.Context) {
+	var req AdminSendMailRequest
+	if !web.BindAndValidate(c, &req) {
+		return
+	}
+
+	sent, err := h.mailSvc.SendMail(c.Request.Context(), req.RoleIDs, req.draft())
+	if err != nil {
+		h.writeError(c, "send mail failed", err)
+		return
+	}
+
+	h.logger.Info("admin mail sent", "title", req.Title, "recipients", sent, "client_ip", c.ClientIP())
+	web.Success(c, AdminSendMailResponse{Sent: sent})
+}
+
+// SendGlobalMail 发送全服邮件
+// @Router /admin/v1/global-mails [post]
+func (h *AdminHandler) SendGlobalMail(c *gin.Context) {
+	var req AdminSendGlobalMailRequest
+	if !web.BindAndValidate(c, &req) {
+		return
+	}
+
+	target := model.MailTarget{
+		Type:     req.TargetType,
+		MinLevel: req.MinLevel,
+		MaxLevel: req.MaxLevel,
+		RoleIDs:  req.RoleIDs,
+	}
+	mail, err := h.mailSvc.SendGlobalMail(c.Request.Context(), req.draft(), target)
+	if err != nil {
+		h.writeError(c, "send global mail failed", err)
+		return
+	}
+
+	h.logger.Info("admin global mail sent", "global_id", mail.ID, "title", mail.Title, "target_type", target.Type, "client_ip", c.ClientIP())
+	web.Success(c, AdminSendGlobalMailResponse{GlobalID: mail.ID, ExpireTime: mail.ExpireTime})
+}
+
+// AddExp 为角色发放经验（可触发升级与升级奖励，角色离线也可发放）
+// @Router /admin/v1/roles/exp [post]
+func (h *AdminHandler) AddExp(c *gin.Context) {
+	var req AdminAddExpRequest
+	if !web.BindAndValidate(c, &req) {
+		return
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "admin"
+	}
+
+	event, err := h.levelSvc.AddExp(c.Request.Context(), req.RoleID, req.Exp, reason)
+	if err != nil {
+		h.writeError(c, "add exp failed", err)
+		return
+	}
+
+	h.logger.Info("admin exp added", "role_id", req.RoleID, "exp", req.Exp, "level", event.Level, "client_ip", c.ClientIP())
+	web.Success(c, AdminAddExpResponse{
+		OldLevel:     event.OldLevel,
+		Level:        event.Level,
+		Exp:          event.Exp,
+		VIPExp:       event.VIPExp,
+		VIPLevel:     event.VIPLevel,
+		RewardByMail: event.RewardByMail,
+	})
+}
+
+// GetGachaPoolStats 统计池子在 [from, to) 内的实际产出并与配置概率对比
+// from/to 为 Unix 时间戳，to 未指定时为当前时间，from 未指定时为 to 之前 24 小时
+// @Router /admin/v1/gacha/pools/{id}/stats [get]
+func (h *AdminHandler) GetGachaPoolStats(c *gin.Context) {
+	poolID, err := strconv.ParseInt(c.Param("id"), 10, 32)
+	if err != nil {
+		web.Error(c, http.StatusBadRequest, http.StatusBadRequest, "invalid pool id")
+		return
+	}
+
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		ts, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			web.Error(c, http.StatusBadRequest, http.StatusBadRequest, "invalid to")
+			return
+		}
+		to = time.Unix(ts, 0)
+	}
+	from := to.Add(-defaultGachaStatsRange)
+	if v := c.Query("from"); v != "" {
+		ts, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			web.Error(c, http.StatusBadRequest, http.StatusBadRequest, "invalid from")
+			return
+		}
+		from = time.Unix(ts, 0)
+	}
+	if !from.Before(to) {
+		web.Error(c, http.StatusBadRequest, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	report, err := h.gachaSvc.GetPoolStats(c.Request.Context(), int32(poolID), from, to)
+	if err != nil {
+		h.writeError(c, "get gacha pool stats failed", err)
+		return
+	}
+
+	resp := AdminGachaPoolStatsResponse{
+		PoolID: report.PoolID,
+		From:   report.From.Unix(),
+		To:     report.To.Unix(),
+		Drops:  make([]AdminGachaDropStats, 0, len(report.Drops)),
+	}
+	for _, drop := range report.Drops {
+		stats := AdminGachaDropStats{
+			DropID: drop.DropID,
+			IsPity: drop.IsPity,
+			Draws:  drop.Draws,
+			Items:  make([]AdminGachaItemStats, 0, len(drop.Items)),
+		}
+		for _, item := range drop.Items {
+			stats.Items = append(stats.Items, AdminGachaItemStats{
+				ItemID:        item.ItemID,
+				Hits:          item.Hits,
+				Total:         item.Total,
+				ActualRate:    item.ActualRate,
+				ExpectedRate:  item.ExpectedRate,
+				ActualCount:   item.ActualCount,
+				ExpectedCount: item.ExpectedCount,
+				ZScore:        item.ZScore,
+			})
+		}
+		resp.Drops = append(resp.Drops, stats)
+	}
+	web.Success(c, resp)
+}
+
+// ReplayGachaDraw 用抽卡流水记录的种子重放抽取，返回重放结果以及是否与记录一致
+// 重放依赖当前加载的配置表，配置在抽取后被修改时结果可能不一致
+// @Router /admin/v1/gacha/draws/{id}/replay [get]
+func (h *AdminHandler) ReplayGachaDraw(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		web.Error(c, http.StatusBadRequest, http.StatusBadRequest, "invalid draw log id")
+		return
+	}
+
+	log, replayed, match, err := h.gachaSvc.ReplayDrawLogByID(c.Request.Context(), id)
+	if err != nil {
+		h.writeError(c, "replay gacha draw failed", err)
+		return
+	}
+
+	h.logger.Info("admin gacha draw replayed", "id", id, "match", match, "client_ip", c.ClientIP())
+	web.Success(c, AdminReplayGachaDrawResponse{
+		ID:        log.ID,
+		RoleID:    log.RoleID,
+		PoolID:    log.PoolID,
+		DropID:    log.DropID,
+		Seed:      log.Seed,
+		CreatedAt: log.CreatedAt,
+		Recorded:  toAdminGachaDrawItems(log.Results),
+		Replayed:  toAdminGachaDrawItems(replayed),
+		Match:     match,
+	})
+}
+
+func toAdminGachaDrawItems(items []*model.GachaDrawItem) []AdminGachaDrawItem {
+	result := make([]AdminGachaDrawItem, 0, len(items))
+	for _, item := range items {
+		result = append(result, AdminGachaDrawItem{ItemID: item.ItemID, Count: item.Count})
+	}
+	return result
+}
+
+// draft 转换为待发送的邮件内容
+func (r *AdminMailContent) draft() *service.MailDraft {
+	attachments := make([]*model.MailAttachment, 0, len(r.Attachments))
+	for _, a := range r.Attachments {
+		attachments = append(attachments, &model.MailAttachment{ItemID: a.ItemID, Count: a.Count})
+	}
+
+	return &service.MailDraft{
+		Sender:      r.Sender,
+		Title:       r.Title,
+		Content:     r.Content,
+		Attachments: attachments,
+		ExpireTime:  r.ExpireTime,
+	}
+}
+
+// writeError 参数错误返回 400，资源不存在返回 404，其余返回 500
+func (h *AdminHandler) writeError(c *gin.Context, msg string, err error) {
+	if errors.Is(err, service.ErrGachaPoolNotFound) || errors.Is(err, service.ErrGachaDrawLogNotFound) {
+		h.logger.Warn(msg, "error", err)
+		web.Error(c, http.StatusNotFound, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, service.ErrMailInvalid) || errors.Is(err, service.ErrLevelInvalidExp) {
+		h.logger.Warn(msg, "error", err)
+		web.Error(c, http.StatusBadRequest, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.logger.Error(msg, "error", err)
+	web.Error(c, http.StatusInternalServerError, http.StatusInternalServerError, "internal error")
+}
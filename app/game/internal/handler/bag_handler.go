@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	api "github.com/lk2023060901/xdooria-proto-api"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	gamerouter "github.com/lk2023060901/xdooria/app/game/internal/router"
+	"github.com/lk2023060901/xdooria/app/game/internal/service"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+type BagHandler struct {
+	logger logger.Logger
+	bagSvc *service.BagService
+}
+
+func NewBagHandler(l logger.Logger, bagSvc *service.BagService) *BagHandler {
+	return &BagHandler{
+		logger: l.Named("handler.bag"),
+		bagSvc: bagSvc,
+	}
+}
+
+func (h *BagHandler) RegisterHandlers(roleRouter *gamerouter.RoleRouter) {
+	gamerouter.RegisterHandler(roleRouter,
+		uint32(api.OpCode_OP_BAG_LIST_REQ),
+		uint32(api.OpCode_OP_BAG_LIST_RES),
+		h.HandleList)
+
+	gamerouter.RegisterHandler(roleRouter,
+		uint32(api.OpCode_OP_BAG_SORT_REQ),
+		uint32(api.OpCode_OP_BAG_SORT_RES),
+		h.HandleSort)
+
+	gamerouter.RegisterHandler(roleRouter,
+		uint32(api.OpCode_OP_BAG_MERGE_REQ),
+		uint32(api.OpCode_OP_BAG_MERGE_RES),
+		h.HandleMerge)
+
+	gamerouter.RegisterHandler(roleRouter,
+		uint32(api.OpCode_OP_BAG_SPLIT_REQ),
+		uint32(api.OpCode_OP_BAG_SPLIT_RES),
+		h.HandleSplit)
+
+	gamerouter.RegisterHandler(roleRouter,
+		uint32(api.OpCode_OP_BAG_MOVE_REQ),
+		uint32(api.OpCode_OP_BAG_MOVE_RES),
+		h.HandleMove)
+
+	gamerouter.RegisterHandler(roleRouter,
+		uint32(api.OpCode_OP_BAG_EXPAND_REQ),
+		uint32(api.OpCode_OP_BAG_EXPAND_RES),
+		h.HandleExpand)
+}
+
+func (h *BagHandler) HandleList(ctx context.Context, roleID int64, req *api.BagListRequest) (*api.BagListResponse, error) {
+	bags, err := h.bagSvc.GetBags(ctx, roleID, req.BagType)
+	if err != nil {
+		h.logger.Error("list bags failed", "role_id", roleID, "bag_type", req.BagType, "error", err)
+		return &api.BagListResponse{Code: bagErrorCode(err)}, nil
+	}
+
+	bagInfos := make([]*api.BagInfo, 0, len(bags))
+	for _, bag := range bags {
+		bagInfos = append(bagInfos, toBagInfo(bag))
+	}
+
+	return &api.BagListResponse{
+		Code: api.ErrorCode_ERR_SUCCESS,
+		Bags: bagInfos,
+	}, nil
+}
+
+func (h *BagHandler) HandleSort(ctx context.Context, roleID int64, req *api.BagSortRequest) (*api.BagSortResponse, error) {
+	bag, err := h.bagSvc.SortBag(ctx, roleID, req.BagType, req.SortType, req.Ascending)
+	if err != nil {
+		h.logger.Warn("sort bag failed", "role_id", roleID, "bag_type", req.BagType, "sort_type", req.SortType, "error", err)
+		return &api.BagSortResponse{Code: bagErrorCode(err)}, nil
+	}
+
+	return &api.BagSortResponse{
+		Code: api.ErrorCode_ERR_SUCCESS,
+		Bag:  toBagInfo(bag),
+	}, nil
+}
+
+func (h *BagHandler) HandleMerge(ctx context.Context, roleID int64, req *api.BagMergeRequest) (*api.BagMergeResponse, error) {
+	bag, err := h.bagSvc.MergeStack(ctx, roleID, req.BagType, req.FromSlot, req.ToSlot)
+	if err != nil {
+		h.logger.Warn("merge stack failed", "role_id", roleID, "bag_type", req.BagType, "from", req.FromSlot, "to", req.ToSlot, "error", err)
+		return &api.BagMergeResponse{Code: bagErrorCode(err)}, nil
+	}
+
+	return &api.BagMergeResponse{
+		Code: api.ErrorCode_ERR_SUCCESS,
+		Bag:  toBagInfo(bag),
+	}, nil
+}
+
+func (h *BagHandler) HandleSplit(ctx context.Context, roleID int64, req *api.BagSplitRequest) (*api.BagSplitResponse, error) {
+	newSlot, bag, err := h.bagSvc.SplitStack(ctx, roleID, req.BagType, req.Slot, req.Count)
+	if err != nil {
+		h.logger.Warn("split stack failed", "role_id", roleID, "bag_type", req.BagType, "slot", req.Slot, "count", req.Count, "error", err)
+		return &api.BagSplitResponse{Code: bagErrorCode(err)}, nil
+	}
+
+	return &api.BagSplitResponse{
+		Code:    api.ErrorCode_ERR_SUCCESS,
+		NewSlot: newSlot,
+		Bag:     toBagInfo(bag),
+	}, nil
+}
+
+func (h *BagHandler) HandleMove(ctx context.Context, roleID int64, req *api.BagMoveRequest) (*api.BagMoveResponse, error) {
+	bag, err := h.bagSvc.MoveItem(ctx, roleID, req.BagType, req.FromSlot, req.ToSlot)
+	if err != nil {
+		h.logger.Warn("move item failed", "role_id", roleID, "bag_type", req.BagType, "from", req.FromSlot, "to", req.ToSlot, "error", err)
+		return &api.BagMoveResponse{Code: bagErrorCode(err)}, nil
+	}
+
+	return &api.BagMoveResponse{
+		Code: api.ErrorCode_ERR_SUCCESS,
+		Bag:  toBagInfo(bag),
+	}, nil
+}
+
+func (h *BagHandler) HandleExpand(ctx context.Context, roleID int64, req *api.BagExpandRequest) (*api.BagExpandResponse, error) {
+	bag, err := h.bagSvc.ExpandBag(ctx, roleID, req.BagType, req.Count)
+	if err != nil {
+		h.logger.Warn("expand bag failed", "role_id", roleID, "bag_type", req.BagType, "count", req.Count, "error", err)
+		return &api.BagExpandResponse{Code: bagErrorCode(err)}, nil
+	}
+
+	return &api.BagExpandResponse{
+		Code: api.ErrorCode_ERR_SUCCESS,
+		Bag:  toBagInfo(bag),
+	}, nil
+}
+
+// toBagInfo 将背包快照转换为协议结构
+func toBagInfo(bag *model.PlayerBag) *api.BagInfo {
+	items := make([]*api.BagItem, 0, len(bag.Items))
+	for _, item := range bag.Items {
+		items = append(items, &api.BagItem{
+			SlotIndex:  item.SlotIndex,
+			ItemId:     item.ConfigID,
+			Count:      item.Count,
+			IsBound:    item.IsBound,
+			ExpireTime: item.ExpireTime,
+			IsFavorite: item.IsFavorite,
+			IsNew:      item.IsNew,
+		})
+	}
+
+	return &api.BagInfo{
+		BagType:  bag.BagType,
+		Capacity: bag.Capacity,
+		Items:    items,
+	}
+}
+
+// bagErrorCode 将背包业务错误映射为错误码
+func bagErrorCode(err error) api.ErrorCode {
+	switch {
+	case errors.Is(err, service.ErrBagInvalidOperation):
+		return api.ErrorCode_ERR_BAG_INVALID_OPERATION
+	case errors.Is(err, service.ErrBagFull):
+		return api.ErrorCode_ERR_BAG_FULL
+	case errors.Is(err, service.ErrBagCapacityLimit):
+		return api.ErrorCode_ERR_BAG_CAPACITY_LIMIT
+	case errors.Is(err, service.ErrBagInsufficientItem):
+		return api.ErrorCode_ERR_INSUFFICIENT_CURRENCY
+	}
+	return api.ErrorCode_ERR_INTERNAL
+}
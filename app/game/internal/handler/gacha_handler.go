@@ -26,6 +26,10 @@ func (h *GachaHandler) RegisterHandlers(roleRouter *gamerouter.RoleRouter) {
 		uint32(api.OpCode_OP_GACHA_DRAW_REQ),
 		uint32(api.OpCode_OP_GACHA_DRAW_RES),
 		h.HandleDraw)
+	gamerouter.RegisterHandler(roleRouter,
+		uint32(api.OpCode_OP_GACHA_HISTORY_REQ),
+		uint32(api.OpCode_OP_GACHA_HISTORY_RES),
+		h.HandleHistory)
 }
 
 func (h *GachaHandler) HandleDraw(ctx context.Context, roleID int64, req *api.GachaDrawRequest) (*api.GachaDrawResponse, error) {
@@ -49,3 +53,36 @@ func (h *GachaHandler) HandleDraw(ctx context.Context, roleID int64, req *api.Ga
 		DrawCount: totalCount,
 	}, nil
 }
+
+func (h *GachaHandler) HandleHistory(ctx context.Context, roleID int64, req *api.GachaHistoryRequest) (*api.GachaHistoryResponse, error) {
+	logs, total, err := h.gachaSvc.GetDrawHistory(ctx, roleID, req.PoolId, req.Page, req.PageSize)
+	if err != nil {
+		h.logger.Error("get gacha history failed", "role_id", roleID, "pool_id", req.PoolId, "error", err)
+		return &api.GachaHistoryResponse{Code: api.ErrorCode_ERR_INTERNAL}, nil
+	}
+
+	records := make([]*api.GachaHistoryRecord, 0, len(logs))
+	for _, l := range logs {
+		rewards := make([]*api.GachaReward, 0, len(l.Results))
+		for _, r := range l.Results {
+			rewards = append(rewards, &api.GachaReward{
+				Id:    r.ItemID,
+				Count: r.Count,
+			})
+		}
+		records = append(records, &api.GachaHistoryRecord{
+			Id:        l.ID,
+			PoolId:    l.PoolID,
+			IsPity:    l.IsPity,
+			PityCount: l.PityCount,
+			Rewards:   rewards,
+			Time:      l.CreatedAt,
+		})
+	}
+
+	return &api.GachaHistoryResponse{
+		Code:    api.ErrorCode_ERR_SUCCESS,
+		Records: records,
+		Total:   total,
+	}, nil
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	api "github.com/lk2023060901/xdooria-proto-api"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	gamerouter "github.com/lk2023060901/xdooria/app/game/internal/router"
+	"github.com/lk2023060901/xdooria/app/game/internal/service"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+type MailHandler struct {
+	logger  logger.Logger
+	mailSvc *service.MailService
+}
+
+func NewMailHandler(l logger.Logger, mailSvc *service.MailService) *MailHandler {
+	return &MailHandler{
+		logger:  l.Named("handler.mail"),
+		mailSvc: mailSvc,
+	}
+}
+
+func (h *MailHandler) RegisterHandlers(roleRouter *gamerouter.RoleRouter) {
+	gamerouter.RegisterHandler(roleRouter,
+		uint32(api.OpCode_OP_MAIL_LIST_REQ),
+		uint32(api.OpCode_OP_MAIL_LIST_RES),
+		h.HandleList)
+
+	gamerouter.RegisterHandler(roleRouter,
+		uint32(api.OpCode_OP_MAIL_READ_REQ),
+		uint32(api.OpCode_OP_MAIL_READ_RES),
+		h.HandleRead)
+
+	gamerouter.RegisterHandler(roleRouter,
+		uint32(api.OpCode_OP_MAIL_CLAIM_REQ),
+		uint32(api.OpCode_OP_MAIL_CLAIM_RES),
+		h.HandleClaim)
+
+	gamerouter.RegisterHandler(roleRouter,
+		uint32(api.OpCode_OP_MAIL_DELETE_REQ),
+		uint32(api.OpCode_OP_MAIL_DELETE_RES),
+		h.HandleDelete)
+}
+
+func (h *MailHandler) HandleList(ctx context.Context, roleID int64, req *api.MailListRequest) (*api.MailListResponse, error) {
+	mails, err := h.mailSvc.ListMails(ctx, roleID)
+	if err != nil {
+		h.logger.Error("list mails failed", "role_id", roleID, "error", err)
+		return &api.MailListResponse{Code: mailErrorCode(err)}, nil
+	}
+
+	mailInfos := make([]*api.MailInfo, 0, len(mails))
+	for _, mail := range mails {
+		mailInfos = append(mailInfos, toMailInfo(mail))
+	}
+
+	return &api.MailListResponse{
+		Code:  api.ErrorCode_ERR_SUCCESS,
+		Mails: mailInfos,
+	}, nil
+}
+
+func (h *MailHandler) HandleRead(ctx context.Context, roleID int64, req *api.MailReadRequest) (*api.MailReadResponse, error) {
+	mail, err := h.mailSvc.ReadMail(ctx, roleID, req.MailId)
+	if err != nil {
+		h.logger.Warn("read mail failed", "role_id", roleID, "mail_id", req.MailId, "error", err)
+		return &api.MailReadResponse{Code: mailErrorCode(err)}, nil
+	}
+
+	return &api.MailReadResponse{
+		Code: api.ErrorCode_ERR_SUCCESS,
+		Mail: toMailInfo(mail),
+	}, nil
+}
+
+func (h *MailHandler) HandleClaim(ctx context.Context, roleID int64, req *api.MailClaimRequest) (*api.MailClaimResponse, error) {
+	result, err := h.mailSvc.ClaimMail(ctx, roleID, req.MailId)
+	if err != nil {
+		h.logger.Warn("claim mail failed", "role_id", roleID, "mail_id", req.MailId, "error", err)
+		return &api.MailClaimResponse{Code: mailErrorCode(err)}, nil
+	}
+
+	return &api.MailClaimResponse{
+		Code:    api.ErrorCode_ERR_SUCCESS,
+		MailIds: result.MailIDs,
+		Rewards: toMailAttachments(result.Rewards),
+	}, nil
+}
+
+func (h *MailHandler) HandleDelete(ctx context.Context, roleID int64, req *api.MailDeleteRequest) (*api.MailDeleteResponse, error) {
+	mailIDs, err := h.mailSvc.DeleteMail(ctx, roleID, req.MailId)
+	if err != nil {
+		h.logger.Warn("delete mail failed", "role_id", roleID, "mail_id", req.MailId, "error", err)
+		return &api.MailDeleteResponse{Code: mailErrorCode(err)}, nil
+	}
+
+	return &api.MailDeleteResponse{
+		Code:    api.ErrorCode_ERR_SUCCESS,
+		MailIds: mailIDs,
+	}, nil
+}
+
+// toMailInfo 将邮件转换为协议结构
+func toMailInfo(mail *model.Mail) *api.MailInfo {
+	return &api.MailInfo{
+		MailId:      mail.ID,
+		MailType:    mail.MailType,
+		Sender:      mail.Sender,
+		Title:       mail.Title,
+		Content:     mail.Content,
+		Attachments: toMailAttachments(mail.Attachments),
+		IsRead:      mail.IsRead,
+		IsClaimed:   mail.IsClaimed,
+		SendTime:    mail.SendTime,
+		ExpireTime:  mail.ExpireTime,
+	}
+}
+
+// toMailAttachments 将邮件附件转换为协议结构
+func toMailAttachments(attachments []*model.MailAttachment) []*api.MailAttachment {
+	out := make([]*api.MailAttachment, 0, len(attachments))
+	for _, a := range attachments {
+		out = append(out, &api.MailAttachment{
+			ItemId: a.ItemID,
+			Count:  a.Count,
+		})
+	}
+	return out
+}
+
+// mailErrorCode 将邮件业务错误映射为错误码
+func mailErrorCode(err error) api.ErrorCode {
+	switch {
+	case errors.Is(err, service.ErrMailNotFound):
+		return api.ErrorCode_ERR_MAIL_NOT_FOUND
+	case errors.Is(err, service.ErrMailExpired):
+		return api.ErrorCode_ERR_MAIL_EXPIRED
+	case errors.Is(err, service.ErrMailNoAttachment):
+		return api.ErrorCode_ERR_MAIL_NO_ATTACHMENT
+	case errors.Is(err, service.ErrMailAlreadyClaimed):
+		return api.ErrorCode_ERR_MAIL_ALREADY_CLAIMED
+	case errors.Is(err, service.ErrMailNotClaimed):
+		return api.ErrorCode_ERR_MAIL_NOT_CLAIMED
+	case errors.Is(err, service.ErrMailRoleOffline):
+		return api.ErrorCode_ERR_INVALID_ROLE
+	case errors.Is(err, service.ErrBagFull):
+		return api.ErrorCode_ERR_BAG_FULL
+	}
+	return api.ErrorCode_ERR_INTERNAL
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	api "github.com/lk2023060901/xdooria-proto-api"
+	gamerouter "github.com/lk2023060901/xdooria/app/game/internal/router"
+	"github.com/lk2023060901/xdooria/app/game/internal/service"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+type ShopHandler struct {
+	logger  logger.Logger
+	shopSvc *service.ShopService
+}
+
+func NewShopHandler(l logger.Logger, shopSvc *service.ShopService) *ShopHandler {
+	return &ShopHandler{
+		logger:  l.Named("handler.shop"),
+		shopSvc: shopSvc,
+	}
+}
+
+func (h *ShopHandler) RegisterHandlers(roleRouter *gamerouter.RoleRouter) {
+	gamerouter.RegisterHandler(roleRouter,
+		uint32(api.OpCode_OP_SHOP_LIST_REQ),
+		uint32(api.OpCode_OP_SHOP_LIST_RES),
+		h.HandleList)
+
+	gamerouter.RegisterHandler(roleRouter,
+		uint32(api.OpCode_OP_SHOP_BUY_REQ),
+		uint32(api.OpCode_OP_SHOP_BUY_RES),
+		h.HandleBuy)
+}
+
+func (h *ShopHandler) HandleList(ctx context.Context, roleID int64, req *api.ShopListRequest) (*api.ShopListResponse, error) {
+	shops, err := h.shopSvc.ListShops(ctx, roleID, req.ShopId)
+	if err != nil {
+		h.logger.Error("list shops failed", "role_id", roleID, "shop_id", req.ShopId, "error", err)
+		return &api.ShopListResponse{Code: api.ErrorCode_ERR_INTERNAL}, nil
+	}
+
+	shopInfos := make([]*api.ShopInfo, 0, len(shops))
+	for _, shop := range shops {
+		items := make([]*api.ShopItemInfo, 0, len(shop.Items))
+		for _, item := range shop.Items {
+			items = append(items, &api.ShopItemInfo{
+				Id:           item.ID,
+				ItemId:       item.ItemID,
+				CurrencyType: item.CurrencyType,
+				Price:        item.Price,
+				FinalPrice:   item.FinalPrice,
+				Discount:     item.Discount,
+				StockType:    item.StockType,
+				StockLimit:   item.StockLimit,
+				Bought:       item.Bought,
+				GlobalStock:  item.GlobalStock,
+				UnlockLevel:  item.UnlockLevel,
+				StartTime:    item.StartTime,
+				EndTime:      item.EndTime,
+			})
+		}
+		shopInfos = append(shopInfos, &api.ShopInfo{
+			ShopId:          shop.ShopID,
+			Items:           items,
+			NextRefreshTime: shop.NextRefreshTime,
+		})
+	}
+
+	return &api.ShopListResponse{
+		Code:  api.ErrorCode_ERR_SUCCESS,
+		Shops: shopInfos,
+	}, nil
+}
+
+func (h *ShopHandler) HandleBuy(ctx context.Context, roleID int64, req *api.ShopBuyRequest) (*api.ShopBuyResponse, error) {
+	bought, err := h.shopSvc.Buy(ctx, roleID, req.Id, req.Count)
+	if err != nil {
+		h.logger.Warn("buy failed", "role_id", roleID, "shop_item_id", req.Id, "count", req.Count, "error", err)
+		return &api.ShopBuyResponse{Code: shopErrorCode(err), Id: req.Id}, nil
+	}
+
+	return &api.ShopBuyResponse{
+		Code:   api.ErrorCode_ERR_SUCCESS,
+		Id:     req.Id,
+		Count:  req.Count,
+		Bought: bought,
+	}, nil
+}
+
+// shopErrorCode 将商店业务错误映射为错误码
+func shopErrorCode(err error) api.ErrorCode {
+	switch {
+	case errors.Is(err, service.ErrShopItemNotFound):
+		return api.ErrorCode_ERR_CONFIG_NOT_FOUND
+	case errors.Is(err, service.ErrShopRoleOffline):
+		return api.ErrorCode_ERR_INVALID_ROLE
+	case errors.Is(err, service.ErrShopLevelNotEnough):
+		return api.ErrorCode_ERR_LEVEL_NOT_ENOUGH
+	case errors.Is(err, service.ErrShopNotOnSale):
+		return api.ErrorCode_ERR_SHOP_NOT_ON_SALE
+	case errors.Is(err, service.ErrShopLimitExceeded):
+		return api.ErrorCode_ERR_SHOP_LIMIT_EXCEEDED
+	case errors.Is(err, service.ErrShopSoldOut):
+		return api.ErrorCode_ERR_SHOP_SOLD_OUT
+	case errors.Is(err, service.ErrShopInsufficientCurrency):
+		return api.ErrorCode_ERR_INSUFFICIENT_CURRENCY
+	}
+	return api.ErrorCode_ERR_INTERNAL
+}
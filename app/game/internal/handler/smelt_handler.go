@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"errors"
 
 	api "github.com/lk2023060901/xdooria-proto-api"
 	gamerouter "github.com/lk2023060901/xdooria/app/game/internal/router"
@@ -32,7 +33,7 @@ func (h *SmeltHandler) HandleSmelt(ctx context.Context, roleID int64, req *api.S
 	newDoll, upgraded, err := h.smeltSvc.Smelt(ctx, roleID, req.DollIds)
 	if err != nil {
 		h.logger.Error("smelt failed", "role_id", roleID, "error", err)
-		return &api.SmeltDoResponse{Code: api.ErrorCode_ERR_INTERNAL}, nil
+		return &api.SmeltDoResponse{Code: smeltErrorCode(err)}, nil
 	}
 
 	var result *api.SmeltResult
@@ -55,3 +56,16 @@ func (h *SmeltHandler) HandleSmelt(ctx context.Context, roleID int64, req *api.S
 		Result: result,
 	}, nil
 }
+
+// smeltErrorCode 将熔炼业务错误映射为错误码
+func smeltErrorCode(err error) api.ErrorCode {
+	switch {
+	case errors.Is(err, service.ErrSmeltNoActivity):
+		return api.ErrorCode_ERR_SMELT_NO_ACTIVITY
+	case errors.Is(err, service.ErrSmeltInvalidRecipe):
+		return api.ErrorCode_ERR_SMELT_INVALID_RECIPE
+	case errors.Is(err, service.ErrSmeltDollUnavailable):
+		return api.ErrorCode_ERR_SMELT_DOLL_UNAVAILABLE
+	}
+	return api.ErrorCode_ERR_INTERNAL
+}
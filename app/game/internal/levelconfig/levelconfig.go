@@ -0,0 +1,96 @@
+package levelconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// 角色等级与 VIP 等级配置不经过 Luban 生成，由配置数据目录下的 JSON 文件手工维护
+const (
+	roleLevelFile = "tbrolelevel.json"
+	vipLevelFile  = "tbviplevel.json"
+)
+
+var (
+	// T 是全局等级配置实例，加载后可在任何地方直接引用
+	T *Tables
+)
+
+// RoleLevel 角色等级配置
+type RoleLevel struct {
+	Id            int32   `json:"id"`
+	Exp           int64   `json:"exp"`             // 从本级升到下一级所需经验，0 表示满级
+	RewardItemIds []int32 `json:"reward_item_ids"` // 升到本级时发放的奖励道具
+	RewardCounts  []int32 `json:"reward_counts"`   // 与 RewardItemIds 一一对应的数量
+}
+
+// VipLevel VIP 等级配置
+type VipLevel struct {
+	Id     int32 `json:"id"`
+	VipExp int64 `json:"vip_exp"` // 达到该等级所需的累计经验
+}
+
+// Tables 等级配置表
+type Tables struct {
+	roleLevels map[int32]*RoleLevel
+	vipLevels  []*VipLevel
+}
+
+// NewTables 由配置列表构造等级配置表
+func NewTables(roleLevels []*RoleLevel, vipLevels []*VipLevel) *Tables {
+	t := &Tables{
+		roleLevels: make(map[int32]*RoleLevel, len(roleLevels)),
+		vipLevels:  vipLevels,
+	}
+	for _, cfg := range roleLevels {
+		t.roleLevels[cfg.Id] = cfg
+	}
+	return t
+}
+
+// RoleLevel 获取指定等级的配置，不存在时返回 nil
+func (t *Tables) RoleLevel(level int32) *RoleLevel {
+	return t.roleLevels[level]
+}
+
+// VipLevels 获取全部 VIP 等级配置
+func (t *Tables) VipLevels() []*VipLevel {
+	return t.vipLevels
+}
+
+// Load 从配置数据目录加载全局等级配置实例
+func Load(dataDir string, l logger.Logger) error {
+	var roleLevels []*RoleLevel
+	if err := loadFile(dataDir, roleLevelFile, &roleLevels, l); err != nil {
+		return err
+	}
+
+	var vipLevels []*VipLevel
+	if err := loadFile(dataDir, vipLevelFile, &vipLevels, l); err != nil {
+		return err
+	}
+
+	T = NewTables(roleLevels, vipLevels)
+	return nil
+}
+
+// loadFile 读取并解析单个 JSON 配置文件
+func loadFile[E any](dataDir, name string, out *[]E, l logger.Logger) error {
+	filePath := filepath.Join(dataDir, name)
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read required config file %s: %w", filePath, err)
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to unmarshal config file %s: %w", filePath, err)
+	}
+
+	l.Debug("config file loaded successfully", "path", filePath, "records", len(*out))
+	return nil
+}
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"math"
+
+	api "github.com/lk2023060901/xdooria-proto-api"
+)
+
+// DefaultAOICellSize 默认 AOI 格子边长（即视野半径）
+const DefaultAOICellSize float32 = 32
+
+// AOIConfig AOI（Area of Interest）配置
+type AOIConfig struct {
+	// CellSize 九宫格 AOI 的格子边长，玩家能看到所在格子及周围 8 格内的玩家，未配置时为 32
+	CellSize float32 `mapstructure:"cell_size"`
+}
+
+// aoiCell 格子坐标（XZ 平面，Y 为高度不参与划分）
+type aoiCell struct {
+	x, z int32
+}
+
+// near 两个格子是否互为九宫格邻居（包括同一格子）
+func (c aoiCell) near(o aoiCell) bool {
+	dx, dz := c.x-o.x, c.z-o.z
+	return dx >= -1 && dx <= 1 && dz >= -1 && dz <= 1
+}
+
+// aoiGrid 九宫格 AOI 索引
+// 场景按 cellSize 划分为格子，可见关系是对称的：A 能看到 B 当且仅当 B 能看到 A，
+// 查询和移动只涉及周围 9 个格子，与场景总人数无关
+type aoiGrid struct {
+	cellSize float32
+	cells    map[aoiCell]map[int64]*PlayerInScene
+}
+
+func newAOIGrid(cellSize float32) *aoiGrid {
+	if cellSize <= 0 {
+		cellSize = DefaultAOICellSize
+	}
+	return &aoiGrid{
+		cellSize: cellSize,
+		cells:    make(map[aoiCell]map[int64]*PlayerInScene),
+	}
+}
+
+// cellOf 计算坐标所在的格子
+func (g *aoiGrid) cellOf(pos *api.Position) aoiCell {
+	return aoiCell{
+		x: int32(math.Floor(float64(pos.GetX() / g.cellSize))),
+		z: int32(math.Floor(float64(pos.GetZ() / g.cellSize))),
+	}
+}
+
+// add 将玩家加入其坐标所在的格子
+func (g *aoiGrid) add(p *PlayerInScene) {
+	p.cell = g.cellOf(p.Position)
+	players, ok := g.cells[p.cell]
+	if !ok {
+		players = make(map[int64]*PlayerInScene)
+		g.cells[p.cell] = players
+	}
+	players[p.RoleID] = p
+}
+
+// remove 将玩家从所在格子移除，格子为空时回收
+func (g *aoiGrid) remove(p *PlayerInScene) {
+	players := g.cells[p.cell]
+	delete(players, p.RoleID)
+	if len(players) == 0 {
+		delete(g.cells, p.cell)
+	}
+}
+
+// move 更新玩家坐标，跨格子时迁移，返回迁移前后的格子
+func (g *aoiGrid) move(p *PlayerInScene, pos *api.Position) (from, to aoiCell) {
+	from = p.cell
+	p.Position = pos
+	to = g.cellOf(pos)
+	if from != to {
+		g.remove(p)
+		g.add(p)
+	}
+	return from, to
+}
+
+// visit 遍历以 center 为中心的九宫格内的所有玩家
+func (g *aoiGrid) visit(center aoiCell, fn func(p *PlayerInScene)) {
+	for dx := int32(-1); dx <= 1; dx++ {
+		for dz := int32(-1); dz <= 1; dz++ {
+			for _, p := range g.cells[aoiCell{x: center.x + dx, z: center.z + dz}] {
+				fn(p)
+			}
+		}
+	}
+}
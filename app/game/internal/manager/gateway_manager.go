@@ -0,0 +1,251 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	common "github.com/lk2023060901/xdooria-proto-common"
+	internal "github.com/lk2023060901/xdooria-proto-internal"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/network/session"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrRoleNotConnected 角色没有绑定到任何 Gateway 流（未上线或 Gateway 已断开）
+var ErrRoleNotConnected = errors.New("role is not connected to any gateway")
+
+// GatewayManager Gateway 流管理器
+// 作为 Gateway -> Game 双向流的会话处理器（实现 session.SessionHandler），
+// 维护 Gateway 流与在线角色的绑定关系，用于服务端主动向客户端推送消息
+type GatewayManager struct {
+	logger logger.Logger
+
+	mu       sync.RWMutex
+	gateways map[string]session.Session // gatewayID -> 流会话
+	sessions map[string]string          // 流会话ID -> gatewayID
+	roles    map[int64]roleBinding      // roleID -> 所在 Gateway 与会话
+
+	// 角色下线监听（Gateway 通知下线或 Gateway 流断开时触发）
+	offlineListeners []func(roleID int64)
+}
+
+// roleBinding 角色所在的 Gateway 与客户端会话
+type roleBinding struct {
+	gatewayID string
+	sessionID string // Gateway 上的客户端会话 ID，会话恢复到新连接后由 Gateway 重新通知上线
+}
+
+// NewGatewayManager 创建 Gateway 流管理器
+func NewGatewayManager(l logger.Logger) *GatewayManager {
+	return &GatewayManager{
+		logger:   l.Named("manager.gateway"),
+		gateways: make(map[string]session.Session),
+		sessions: make(map[string]string),
+		roles:    make(map[int64]roleBinding),
+	}
+}
+
+// SendToRole 通过角色所在 Gateway 的流推送消息给客户端
+func (m *GatewayManager) SendToRole(ctx context.Context, roleID int64, opCode uint32, payload []byte) error {
+	m.mu.RLock()
+	sess, ok := m.gateways[m.roles[roleID].gatewayID]
+	m.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w: role %d", ErrRoleNotConnected, roleID)
+	}
+
+	req, err := proto.Marshal(&internal.SendToClientRequest{
+		RoleId:  roleID,
+		Op:      opCode,
+		Payload: payload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal send to client request: %w", err)
+	}
+
+	return sess.Send(ctx, &common.Envelope{
+		Header:  &common.MessageHeader{Op: uint32(internal.OpCode_OP_GAME_SEND_TO_CLIENT)},
+		Payload: req,
+	})
+}
+
+// Broadcast 将同一条消息推送给多个角色
+// 按角色所在 Gateway 分组，每个 Gateway 只发送一次 OP_GAME_BROADCAST，未绑定 Gateway 的角色直接跳过
+func (m *GatewayManager) Broadcast(ctx context.Context, roleIDs []int64, opCode uint32, payload []byte) error {
+	if len(roleIDs) == 0 {
+		// 空列表在 Gateway 侧表示全服广播，这里不允许误用
+		return nil
+	}
+
+	groups := make(map[session.Session][]int64)
+	m.mu.RLock()
+	for _, roleID := range roleIDs {
+		if sess, ok := m.gateways[m.roles[roleID].gatewayID]; ok {
+			groups[sess] = append(groups[sess], roleID)
+		}
+	}
+	m.mu.RUnlock()
+
+	var errs []error
+	for sess, ids := range groups {
+		req, err := proto.Marshal(&internal.BroadcastRequest{
+			Op:      opCode,
+			Payload: payload,
+			RoleIds: ids,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal broadcast request: %w", err)
+		}
+
+		if err := sess.Send(ctx, &common.Envelope{
+			Header:  &common.MessageHeader{Op: uint32(internal.OpCode_OP_GAME_BROADCAST)},
+			Payload: req,
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("gateway session %s: %w", sess.ID(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// OnRoleOffline 注册角色下线监听，需在 Gateway 流建立前注册
+func (m *GatewayManager) OnRoleOffline(fn func(roleID int64)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.offlineListeners = append(m.offlineListeners, fn)
+}
+
+// IsConnected 判断角色是否绑定到 Gateway 流
+func (m *GatewayManager) IsConnected(roleID int64) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.gateways[m.roles[roleID].gatewayID]
+	return ok
+}
+
+// ============================================================================
+// session.SessionHandler 接口实现（处理来自 Gateway 的消息）
+// ============================================================================
+
+func (m *GatewayManager) OnOpened(s session.Session) {
+	m.logger.Info("gateway stream opened", "session_id", s.ID(), "remote_addr", s.RemoteAddr())
+}
+
+func (m *GatewayManager) OnClosed(s session.Session, err error) {
+	var offline []int64
+
+	m.mu.Lock()
+	gatewayID, ok := m.sessions[s.ID()]
+	delete(m.sessions, s.ID())
+	if ok && m.gateways[gatewayID] == s {
+		delete(m.gateways, gatewayID)
+		for roleID, b := range m.roles {
+			if b.gatewayID == gatewayID {
+				delete(m.roles, roleID)
+				offline = append(offline, roleID)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	m.logger.Warn("gateway stream closed", "session_id", s.ID(), "gateway_id", gatewayID, "roles", len(offline), "error", err)
+
+	for _, roleID := range offline {
+		m.notifyOffline(roleID)
+	}
+}
+
+func (m *GatewayManager) OnMessage(s session.Session, env *common.Envelope) {
+	switch internal.OpCode(env.Header.Op) {
+	case internal.OpCode_OP_GATEWAY_PLAYER_ONLINE:
+		var notify internal.PlayerOnlineNotify
+		if err := proto.Unmarshal(env.Payload, &notify); err != nil {
+			m.logger.Error("unmarshal player online notify failed", "error", err)
+			return
+		}
+		m.bindGateway(s, notify.GatewayId)
+
+		m.mu.Lock()
+		m.roles[notify.RoleId] = roleBinding{gatewayID: notify.GatewayId, sessionID: notify.SessionId}
+		m.mu.Unlock()
+
+		m.logger.Debug("role bound to gateway", "role_id", notify.RoleId, "gateway_id", notify.GatewayId, "session_id", notify.SessionId)
+
+	case internal.OpCode_OP_GATEWAY_PLAYER_OFFLINE:
+		var notify internal.PlayerOfflineNotify
+		if err := proto.Unmarshal(env.Payload, &notify); err != nil {
+			m.logger.Error("unmarshal player offline notify failed", "error", err)
+			return
+		}
+
+		// 角色可能已经在其他 Gateway 或同一 Gateway 的其他会话重新上线（通知可能晚于新的上线通知到达），
+		// 只解绑发出通知的会话
+		m.mu.Lock()
+		unbound := m.roles[notify.RoleId] == roleBinding{gatewayID: notify.GatewayId, sessionID: notify.SessionId}
+		if unbound {
+			delete(m.roles, notify.RoleId)
+		}
+		m.mu.Unlock()
+
+		if !unbound {
+			return
+		}
+
+		m.logger.Debug("role unbound from gateway", "role_id", notify.RoleId, "gateway_id", notify.GatewayId)
+		m.notifyOffline(notify.RoleId)
+
+	case internal.OpCode_OP_GATEWAY_HEARTBEAT:
+		var hb internal.GatewayHeartbeat
+		if err := proto.Unmarshal(env.Payload, &hb); err != nil {
+			m.logger.Error("unmarshal gateway heartbeat failed", "error", err)
+			return
+		}
+		m.bindGateway(s, hb.GatewayId)
+
+		// 心跳以 RPC 请求发出时，应答带回关联 ID，Gateway 据此确认 Game 仍在处理消息
+		ack := session.Reply(env, uint32(internal.OpCode_OP_GAME_HEARTBEAT_ACK), nil)
+		if err := s.Send(s.Context(), ack); err != nil {
+			m.logger.Warn("send heartbeat ack failed", "gateway_id", hb.GatewayId, "error", err)
+		}
+
+	default:
+		// 客户端请求仍经由 GameService.ForwardMessage 一元调用转发
+		m.logger.Warn("unsupported opcode from gateway stream", "op", env.Header.Op, "session_id", s.ID())
+	}
+}
+
+func (m *GatewayManager) OnError(s session.Session, err error) {
+	m.logger.Error("gateway stream error", "session_id", s.ID(), "error", err)
+}
+
+// bindGateway 记录流会话所属的 Gateway（Gateway 重连后以新的流为准）
+func (m *GatewayManager) bindGateway(s session.Session, gatewayID string) {
+	if gatewayID == "" {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if old, ok := m.gateways[gatewayID]; ok && old != s {
+		delete(m.sessions, old.ID())
+	}
+	m.gateways[gatewayID] = s
+	m.sessions[s.ID()] = gatewayID
+}
+
+// notifyOffline 通知角色下线监听（不持有锁调用）
+func (m *GatewayManager) notifyOffline(roleID int64) {
+	m.mu.RLock()
+	listeners := m.offlineListeners
+	m.mu.RUnlock()
+
+	for _, fn := range listeners {
+		fn(roleID)
+	}
+}
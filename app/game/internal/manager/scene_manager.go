@@ -1,6 +1,7 @@
 package manager
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 
@@ -8,22 +9,40 @@ import (
 	"github.com/lk2023060901/xdooria/pkg/logger"
 )
 
+// 场景错误
+var (
+	ErrSceneNotFound  = errors.New("scene not found")
+	ErrRoleNotInScene = errors.New("role not in any scene")
+)
+
 // Scene 场景实例
 type Scene struct {
 	MapID   int32
 	Players map[int64]*PlayerInScene // roleID -> PlayerInScene
+	aoi     *aoiGrid
 	mu      sync.RWMutex
 }
 
 // PlayerInScene 场景中的玩家信息
+// Position 只会被整体替换，不会原地修改，持有快照的调用方可以安全读取
 type PlayerInScene struct {
 	RoleID   int64
 	Position *api.Position
+	cell     aoiCell
+}
+
+// SceneView 一次进入、移动或离开场景引起的视野变化（可见关系是对称的）
+type SceneView struct {
+	MapID       int32
+	Appeared    []*PlayerInScene // 新进入视野的玩家（快照），双方需要互相通知进入
+	Disappeared []int64          // 离开视野的玩家，双方需要互相通知离开
+	Watchers    []int64          // 变化前后一直能看到该玩家的玩家，需要通知其移动
 }
 
 // SceneManager 场景管理器
 type SceneManager struct {
 	logger logger.Logger
+	aoiCfg AOIConfig
 
 	mu     sync.RWMutex
 	scenes map[int32]*Scene // mapID -> Scene
@@ -33,9 +52,18 @@ type SceneManager struct {
 }
 
 // NewSceneManager 创建场景管理器
-func NewSceneManager(l logger.Logger) *SceneManager {
+func NewSceneManager(l logger.Logger, cfg *AOIConfig) *SceneManager {
+	var aoiCfg AOIConfig
+	if cfg != nil {
+		aoiCfg = *cfg
+	}
+	if aoiCfg.CellSize <= 0 {
+		aoiCfg.CellSize = DefaultAOICellSize
+	}
+
 	return &SceneManager{
 		logger:     l.Named("manager.scene"),
+		aoiCfg:     aoiCfg,
 		scenes:     make(map[int32]*Scene),
 		roleScenes: make(map[int64]int32),
 	}
@@ -51,44 +79,58 @@ func (m *SceneManager) GetOrCreateScene(mapID int32) (*Scene, error) {
 		scene = &Scene{
 			MapID:   mapID,
 			Players: make(map[int64]*PlayerInScene),
+			aoi:     newAOIGrid(m.aoiCfg.CellSize),
 		}
 		m.scenes[mapID] = scene
-		m.logger.Info("scene created", "map_id", mapID)
+		m.logger.Info("scene created", "map_id", mapID, "aoi_cell_size", m.aoiCfg.CellSize)
 	}
 
 	return scene, nil
 }
 
-// EnterScene 角色进入场景
-func (m *SceneManager) EnterScene(roleID int64, mapID int32, position *api.Position) error {
+// EnterScene 角色进入场景，返回进入后视野内的玩家（Appeared）
+// 角色已在其他场景时会先静默离开，需要通知旧场景的调用方应先调用 LeaveScene；
+// 重复进入同一场景视为重新放置到新坐标
+func (m *SceneManager) EnterScene(roleID int64, mapID int32, position *api.Position) (*SceneView, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// 如果角色已经在其他场景，先离开
+	// 如果角色已经在场景中，先离开
 	if oldMapID, exists := m.roleScenes[roleID]; exists {
 		if oldScene, ok := m.scenes[oldMapID]; ok {
 			oldScene.mu.Lock()
-			delete(oldScene.Players, roleID)
+			oldScene.removePlayer(roleID)
 			oldScene.mu.Unlock()
 			m.logger.Debug("role left old scene",
 				"role_id", roleID,
 				"old_map_id", oldMapID,
 			)
 		}
+		delete(m.roleScenes, roleID)
 	}
 
 	// 获取目标场景
 	scene, exists := m.scenes[mapID]
 	if !exists {
-		return fmt.Errorf("scene not found: %d", mapID)
+		return nil, fmt.Errorf("%w: %d", ErrSceneNotFound, mapID)
 	}
 
 	// 加入新场景
-	scene.mu.Lock()
-	scene.Players[roleID] = &PlayerInScene{
+	player := &PlayerInScene{
 		RoleID:   roleID,
 		Position: position,
 	}
+
+	scene.mu.Lock()
+	scene.Players[roleID] = player
+	scene.aoi.add(player)
+	view := &SceneView{MapID: mapID}
+	scene.aoi.visit(player.cell, func(p *PlayerInScene) {
+		if p.RoleID != roleID {
+			view.Appeared = append(view.Appeared, p.snapshot())
+		}
+	})
+	playerCount := len(scene.Players)
 	scene.mu.Unlock()
 
 	// 更新映射
@@ -97,30 +139,95 @@ func (m *SceneManager) EnterScene(roleID int64, mapID int32, position *api.Posit
 	m.logger.Info("role entered scene",
 		"role_id", roleID,
 		"map_id", mapID,
-		"player_count", len(scene.Players),
+		"player_count", playerCount,
+		"nearby", len(view.Appeared),
 	)
 
-	return nil
+	return view, nil
+}
+
+// MoveInScene 角色在当前场景内移动，返回视野变化
+// 跨格子时只对比新旧九宫格的差集，同格子内移动只需通知周围的玩家
+func (m *SceneManager) MoveInScene(roleID int64, position *api.Position) (*SceneView, error) {
+	m.mu.RLock()
+	mapID, exists := m.roleScenes[roleID]
+	scene, ok := m.scenes[mapID]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %d", ErrRoleNotInScene, roleID)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrSceneNotFound, mapID)
+	}
+
+	scene.mu.Lock()
+	defer scene.mu.Unlock()
+
+	// 获取场景后角色可能已经切换场景
+	player, ok := scene.Players[roleID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrRoleNotInScene, roleID)
+	}
+
+	view := &SceneView{MapID: mapID}
+	from, to := scene.aoi.move(player, position)
+
+	if from == to {
+		scene.aoi.visit(to, func(p *PlayerInScene) {
+			if p.RoleID != roleID {
+				view.Watchers = append(view.Watchers, p.RoleID)
+			}
+		})
+		return view, nil
+	}
+
+	scene.aoi.visit(from, func(p *PlayerInScene) {
+		if p.RoleID == roleID {
+			return
+		}
+		if p.cell.near(to) {
+			view.Watchers = append(view.Watchers, p.RoleID)
+		} else {
+			view.Disappeared = append(view.Disappeared, p.RoleID)
+		}
+	})
+	scene.aoi.visit(to, func(p *PlayerInScene) {
+		if p.RoleID != roleID && !p.cell.near(from) {
+			view.Appeared = append(view.Appeared, p.snapshot())
+		}
+	})
+
+	return view, nil
 }
 
-// LeaveScene 角色离开场景
-func (m *SceneManager) LeaveScene(roleID int64) error {
+// LeaveScene 角色离开场景，返回离开前视野内的玩家（Disappeared）
+func (m *SceneManager) LeaveScene(roleID int64) (*SceneView, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	mapID, exists := m.roleScenes[roleID]
 	if !exists {
-		return fmt.Errorf("role not in any scene: %d", roleID)
+		return nil, fmt.Errorf("%w: %d", ErrRoleNotInScene, roleID)
 	}
 
 	scene, ok := m.scenes[mapID]
 	if !ok {
 		delete(m.roleScenes, roleID)
-		return fmt.Errorf("scene not found: %d", mapID)
+		return nil, fmt.Errorf("%w: %d", ErrSceneNotFound, mapID)
 	}
 
+	view := &SceneView{MapID: mapID}
+
 	scene.mu.Lock()
-	delete(scene.Players, roleID)
+	if player, ok := scene.Players[roleID]; ok {
+		scene.aoi.visit(player.cell, func(p *PlayerInScene) {
+			if p.RoleID != roleID {
+				view.Disappeared = append(view.Disappeared, p.RoleID)
+			}
+		})
+		scene.removePlayer(roleID)
+	}
 	playerCount := len(scene.Players)
 	scene.mu.Unlock()
 
@@ -132,7 +239,7 @@ func (m *SceneManager) LeaveScene(roleID int64) error {
 		"remaining_players", playerCount,
 	)
 
-	return nil
+	return view, nil
 }
 
 // GetRoleScene 获取角色所在场景
@@ -144,6 +251,27 @@ func (m *SceneManager) GetRoleScene(roleID int64) (int32, bool) {
 	return mapID, exists
 }
 
+// GetPlayer 获取角色在场景中的信息（快照）及所在场景
+func (m *SceneManager) GetPlayer(roleID int64) (*PlayerInScene, int32, bool) {
+	m.mu.RLock()
+	mapID, exists := m.roleScenes[roleID]
+	scene, ok := m.scenes[mapID]
+	m.mu.RUnlock()
+
+	if !exists || !ok {
+		return nil, 0, false
+	}
+
+	scene.mu.RLock()
+	defer scene.mu.RUnlock()
+
+	player, ok := scene.Players[roleID]
+	if !ok {
+		return nil, 0, false
+	}
+	return player.snapshot(), mapID, true
+}
+
 // GetScene 获取场景实例
 func (m *SceneManager) GetScene(mapID int32) (*Scene, bool) {
 	m.mu.RLock()
@@ -153,7 +281,7 @@ func (m *SceneManager) GetScene(mapID int32) (*Scene, bool) {
 	return scene, exists
 }
 
-// GetPlayersInScene 获取场景中的所有玩家
+// GetPlayersInScene 获取场景中的所有玩家（快照）
 func (m *SceneManager) GetPlayersInScene(mapID int32) []*PlayerInScene {
 	m.mu.RLock()
 	scene, exists := m.scenes[mapID]
@@ -168,8 +296,55 @@ func (m *SceneManager) GetPlayersInScene(mapID int32) []*PlayerInScene {
 
 	players := make([]*PlayerInScene, 0, len(scene.Players))
 	for _, player := range scene.Players {
-		players = append(players, player)
+		players = append(players, player.snapshot())
 	}
 
 	return players
 }
+
+// GetNearbyPlayers 获取角色视野内的其他玩家（快照）
+func (m *SceneManager) GetNearbyPlayers(roleID int64) []*PlayerInScene {
+	m.mu.RLock()
+	mapID, exists := m.roleScenes[roleID]
+	scene, ok := m.scenes[mapID]
+	m.mu.RUnlock()
+
+	if !exists || !ok {
+		return nil
+	}
+
+	scene.mu.RLock()
+	defer scene.mu.RUnlock()
+
+	player, ok := scene.Players[roleID]
+	if !ok {
+		return nil
+	}
+
+	var players []*PlayerInScene
+	scene.aoi.visit(player.cell, func(p *PlayerInScene) {
+		if p.RoleID != roleID {
+			players = append(players, p.snapshot())
+		}
+	})
+	return players
+}
+
+// removePlayer 从场景和 AOI 索引中移除玩家（调用方需持有 s.mu）
+func (s *Scene) removePlayer(roleID int64) {
+	player, ok := s.Players[roleID]
+	if !ok {
+		return
+	}
+	s.aoi.remove(player)
+	delete(s.Players, roleID)
+}
+
+// snapshot 复制玩家信息，避免调用方在锁外读取被移动修改的字段
+func (p *PlayerInScene) snapshot() *PlayerInScene {
+	return &PlayerInScene{
+		RoleID:   p.RoleID,
+		Position: p.Position,
+		cell:     p.cell,
+	}
+}
@@ -0,0 +1,303 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	api "github.com/lk2023060901/xdooria-proto-api"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+const (
+	testMapID    int32   = 1001
+	testCellSize float32 = 10
+)
+
+func newTestSceneManager(t testing.TB) *SceneManager {
+	t.Helper()
+
+	m := NewSceneManager(logger.Noop(), &AOIConfig{CellSize: testCellSize})
+	if _, err := m.GetOrCreateScene(testMapID); err != nil {
+		t.Fatalf("failed to create scene: %v", err)
+	}
+	return m
+}
+
+func pos(x, z float32) *api.Position {
+	return &api.Position{X: x, Z: z}
+}
+
+// visibleTo 暴力计算 roleID 视野内的玩家，用于校验 AOI 索引
+func visibleTo(m *SceneManager, roleID int64) []int64 {
+	players := m.GetPlayersInScene(testMapID)
+	grid := newAOIGrid(testCellSize)
+
+	var self aoiCell
+	for _, p := range players {
+		if p.RoleID == roleID {
+			self = grid.cellOf(p.Position)
+		}
+	}
+
+	var ids []int64
+	for _, p := range players {
+		if p.RoleID != roleID && grid.cellOf(p.Position).near(self) {
+			ids = append(ids, p.RoleID)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func sortedIDs(players []*PlayerInScene) []int64 {
+	ids := playerIDsOf(players)
+	slices.Sort(ids)
+	return ids
+}
+
+func playerIDsOf(players []*PlayerInScene) []int64 {
+	ids := make([]int64, 0, len(players))
+	for _, p := range players {
+		ids = append(ids, p.RoleID)
+	}
+	return ids
+}
+
+func TestSceneManager_EnterReturnsNearby(t *testing.T) {
+	m := newTestSceneManager(t)
+
+	mustEnter(t, m, 1, pos(5, 5))
+	mustEnter(t, m, 2, pos(15, 5))  // 相邻格子
+	mustEnter(t, m, 3, pos(35, 35)) // 远处
+
+	view := mustEnter(t, m, 4, pos(12, 12))
+	if got := sortedIDs(view.Appeared); !slices.Equal(got, []int64{1, 2}) {
+		t.Fatalf("appeared = %v, want [1 2]", got)
+	}
+	if got := sortedIDs(m.GetNearbyPlayers(3)); len(got) != 0 {
+		t.Fatalf("role 3 should see nobody, got %v", got)
+	}
+}
+
+func TestSceneManager_MoveWithinCell(t *testing.T) {
+	m := newTestSceneManager(t)
+
+	mustEnter(t, m, 1, pos(5, 5))
+	mustEnter(t, m, 2, pos(15, 5))
+
+	view, err := m.MoveInScene(1, pos(6, 6))
+	if err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	if !slices.Equal(view.Watchers, []int64{2}) || len(view.Appeared) != 0 || len(view.Disappeared) != 0 {
+		t.Fatalf("unexpected view: watchers=%v appeared=%v disappeared=%v",
+			view.Watchers, playerIDsOf(view.Appeared), view.Disappeared)
+	}
+
+	player, mapID, ok := m.GetPlayer(1)
+	if !ok || mapID != testMapID || player.Position.X != 6 {
+		t.Fatalf("player not moved: %+v %d %v", player, mapID, ok)
+	}
+}
+
+func TestSceneManager_MoveAcrossCells(t *testing.T) {
+	m := newTestSceneManager(t)
+
+	mustEnter(t, m, 1, pos(5, 5))
+	mustEnter(t, m, 2, pos(-5, 5))  // 只在旧视野内
+	mustEnter(t, m, 3, pos(15, 5))  // 新旧视野都在
+	mustEnter(t, m, 4, pos(25, 5))  // 只在新视野内
+	mustEnter(t, m, 5, pos(95, 95)) // 都不在
+
+	view, err := m.MoveInScene(1, pos(15, 5))
+	if err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	if got := sortedIDs(view.Appeared); !slices.Equal(got, []int64{4}) {
+		t.Fatalf("appeared = %v, want [4]", got)
+	}
+	if !slices.Equal(view.Disappeared, []int64{2}) {
+		t.Fatalf("disappeared = %v, want [2]", view.Disappeared)
+	}
+	if !slices.Equal(view.Watchers, []int64{3}) {
+		t.Fatalf("watchers = %v, want [3]", view.Watchers)
+	}
+}
+
+func TestSceneManager_Leave(t *testing.T) {
+	m := newTestSceneManager(t)
+
+	mustEnter(t, m, 1, pos(5, 5))
+	mustEnter(t, m, 2, pos(15, 5))
+	mustEnter(t, m, 3, pos(95, 95))
+
+	view, err := m.LeaveScene(1)
+	if err != nil {
+		t.Fatalf("leave failed: %v", err)
+	}
+	if !slices.Equal(view.Disappeared, []int64{2}) {
+		t.Fatalf("disappeared = %v, want [2]", view.Disappeared)
+	}
+	if got := m.GetNearbyPlayers(2); len(got) != 0 {
+		t.Fatalf("role 2 still sees %v", playerIDsOf(got))
+	}
+
+	if _, err := m.LeaveScene(1); !errors.Is(err, ErrRoleNotInScene) {
+		t.Fatalf("second leave err = %v, want ErrRoleNotInScene", err)
+	}
+	if _, err := m.MoveInScene(1, pos(0, 0)); !errors.Is(err, ErrRoleNotInScene) {
+		t.Fatalf("move after leave err = %v, want ErrRoleNotInScene", err)
+	}
+}
+
+func TestSceneManager_EnterOtherScene(t *testing.T) {
+	m := newTestSceneManager(t)
+	if _, err := m.GetOrCreateScene(2001); err != nil {
+		t.Fatalf("failed to create scene: %v", err)
+	}
+
+	mustEnter(t, m, 1, pos(5, 5))
+	mustEnter(t, m, 2, pos(5, 5))
+
+	if _, err := m.EnterScene(1, 2001, pos(5, 5)); err != nil {
+		t.Fatalf("enter failed: %v", err)
+	}
+	if got := m.GetNearbyPlayers(2); len(got) != 0 {
+		t.Fatalf("role 2 still sees %v after role 1 switched scene", playerIDsOf(got))
+	}
+	if mapID, _ := m.GetRoleScene(1); mapID != 2001 {
+		t.Fatalf("role scene = %d, want 2001", mapID)
+	}
+
+	if _, err := m.EnterScene(3, 3001, pos(0, 0)); !errors.Is(err, ErrSceneNotFound) {
+		t.Fatalf("enter missing scene err = %v, want ErrSceneNotFound", err)
+	}
+}
+
+// TestSceneManager_RandomWalk 随机移动后，每次的视野变化都应与暴力计算的结果一致
+func TestSceneManager_RandomWalk(t *testing.T) {
+	m := newTestSceneManager(t)
+	r := rand.New(rand.NewPCG(1, 2))
+
+	const players = 200
+	const size = 200
+	for id := int64(1); id <= players; id++ {
+		mustEnter(t, m, id, pos(r.Float32()*size, r.Float32()*size))
+	}
+
+	for i := 0; i < 2000; i++ {
+		id := r.Int64N(players) + 1
+		before := visibleTo(m, id)
+
+		// 偶尔瞬移到很远的地方，其余为小步移动
+		p, _, _ := m.GetPlayer(id)
+		next := pos(p.Position.X+r.Float32()*16-8, p.Position.Z+r.Float32()*16-8)
+		if i%50 == 0 {
+			next = pos(r.Float32()*size, r.Float32()*size)
+		}
+
+		view, err := m.MoveInScene(id, next)
+		if err != nil {
+			t.Fatalf("move failed: %v", err)
+		}
+		after := visibleTo(m, id)
+
+		watchers := slices.Sorted(slices.Values(view.Watchers))
+		disappeared := slices.Sorted(slices.Values(view.Disappeared))
+		appeared := sortedIDs(view.Appeared)
+
+		if got := slices.Sorted(slices.Values(append(slices.Clone(watchers), disappeared...))); !slices.Equal(got, before) {
+			t.Fatalf("step %d: watchers+disappeared = %v, want %v", i, got, before)
+		}
+		if got := slices.Sorted(slices.Values(append(slices.Clone(watchers), appeared...))); !slices.Equal(got, after) {
+			t.Fatalf("step %d: watchers+appeared = %v, want %v", i, got, after)
+		}
+	}
+}
+
+func mustEnter(t testing.TB, m *SceneManager, roleID int64, p *api.Position) *SceneView {
+	t.Helper()
+
+	view, err := m.EnterScene(roleID, testMapID, p)
+	if err != nil {
+		t.Fatalf("enter scene failed: %v", err)
+	}
+	return view
+}
+
+// benchmarkScene 构造一个 players 人、边长 size 的场景（默认格子大小）
+func benchmarkScene(b *testing.B, players int, size float32) (*SceneManager, []*api.Position) {
+	b.Helper()
+
+	m := NewSceneManager(logger.Noop(), nil)
+	if _, err := m.GetOrCreateScene(testMapID); err != nil {
+		b.Fatalf("failed to create scene: %v", err)
+	}
+
+	r := rand.New(rand.NewPCG(1, 2))
+	positions := make([]*api.Position, players)
+	for i := range positions {
+		positions[i] = pos(r.Float32()*size, r.Float32()*size)
+		if _, err := m.EnterScene(int64(i+1), testMapID, positions[i]); err != nil {
+			b.Fatalf("enter scene failed: %v", err)
+		}
+	}
+	return m, positions
+}
+
+func BenchmarkSceneManager_Move(b *testing.B) {
+	for _, players := range []int{1000, 5000, 10000} {
+		b.Run(fmt.Sprintf("players=%d", players), func(b *testing.B) {
+			m, positions := benchmarkScene(b, players, 2048)
+			r := rand.New(rand.NewPCG(3, 4))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				idx := r.IntN(players)
+				p := positions[idx]
+				p = pos(p.X+r.Float32()*4-2, p.Z+r.Float32()*4-2)
+				positions[idx] = p
+				if _, err := m.MoveInScene(int64(idx+1), p); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSceneManager_MoveParallel(b *testing.B) {
+	m, positions := benchmarkScene(b, 5000, 2048)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+		for pb.Next() {
+			idx := r.IntN(len(positions))
+			if _, err := m.MoveInScene(int64(idx+1), pos(r.Float32()*2048, r.Float32()*2048)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkSceneManager_EnterLeave(b *testing.B) {
+	m, _ := benchmarkScene(b, 5000, 2048)
+	r := rand.New(rand.NewPCG(5, 6))
+	const roleID = 1 << 40
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.EnterScene(roleID, testMapID, pos(r.Float32()*2048, r.Float32()*2048)); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := m.LeaveScene(roleID); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
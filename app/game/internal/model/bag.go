@@ -1,19 +1,77 @@
 package model
 
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+)
+
+// ErrBagFull 背包没有足够的空槽位
+var ErrBagFull = errors.New("bag is full")
+
 // Item 物品实例（数据库实体 + 运行时对象）
 type Item struct {
-	ID         int64 // 物品唯一ID
-	RoleID     int64 // 所属角色ID
-	ConfigID   int32 // 配置ID（关联item配置表）
-	SlotIndex  int32 // 槽位索引
-	Count      int32 // 数量（堆叠用）
-	BindType   int32 // 绑定类型
-	ExpireTime int64 // 过期时间（时间戳，0表示永久）
-	IsFavorite bool  // 是否收藏（所有背包类型）
-	IsNew      bool  // 是否新获得/数量变化（所有背包类型）
-	IsEquipped bool  // 是否已装备（仅装备栏、装扮背包）
-	CreateTime int64 // 创建时间
-	UpdateTime int64 // 更新时间
+	ID         int64 `json:"id"`          // 物品唯一ID
+	RoleID     int64 `json:"role_id"`     // 所属角色ID
+	ConfigID   int32 `json:"config_id"`   // 配置ID（关联item配置表）
+	SlotIndex  int32 `json:"slot_index"`  // 槽位索引
+	Count      int32 `json:"count"`       // 数量（堆叠用）
+	BindType   int32 `json:"bind_type"`   // 绑定类型
+	IsBound    bool  `json:"is_bound"`    // 是否已绑定（已绑定物品不可交易、不可邮寄）
+	ExpireTime int64 `json:"expire_time"` // 过期时间（时间戳，0表示永久）
+	IsFavorite bool  `json:"is_favorite"` // 是否收藏（所有背包类型）
+	IsNew      bool  `json:"is_new"`      // 是否新获得/数量变化（所有背包类型）
+	IsEquipped bool  `json:"is_equipped"` // 是否已装备（仅装备栏、装扮背包）
+	CreateTime int64 `json:"create_time"` // 创建时间
+	UpdateTime int64 `json:"update_time"` // 更新时间
+}
+
+// NewItem 根据配置创建物品实例，按配置的过期类型计算过期时间，拾取绑定的物品直接绑定
+func NewItem(roleID int64, itemConfigID int32, count int32, now int64) (*Item, error) {
+	cfg := getItemConfig(itemConfigID)
+	if cfg == nil {
+		return nil, fmt.Errorf("item config not found: %d", itemConfigID)
+	}
+
+	return &Item{
+		RoleID:     roleID,
+		ConfigID:   itemConfigID,
+		Count:      count,
+		BindType:   cfg.BindType,
+		IsBound:    cfg.BindType == gameconfig.BindType_PickupBind,
+		ExpireTime: CalcExpireTime(cfg, now),
+		IsNew:      true,
+		CreateTime: now,
+		UpdateTime: now,
+	}, nil
+}
+
+// CalcExpireTime 按配置的过期类型计算获得时刻的过期时间，0 表示永久
+// 时长类型的 ExpireTime 为秒数，固定日期类型的 ExpireTime 为 Unix 时间戳
+func CalcExpireTime(cfg *gameconfig.Item, now int64) int64 {
+	switch cfg.ExpireType {
+	case gameconfig.ExpireType_Duration:
+		return now + int64(cfg.ExpireTime)
+	case gameconfig.ExpireType_FixedDate:
+		return int64(cfg.ExpireTime)
+	default:
+		return 0
+	}
+}
+
+// IsExpired 判断物品在 now 时刻是否已过期
+func (i *Item) IsExpired(now int64) bool {
+	return i.ExpireTime > 0 && i.ExpireTime <= now
+}
+
+// IsTradable 判断物品是否可交易：配置允许交易且未绑定
+func (i *Item) IsTradable() bool {
+	if i.IsBound {
+		return false
+	}
+	cfg := getItemConfig(i.ConfigID)
+	return cfg != nil && cfg.CanTrade
 }
 
 // Bag 背包接口（所有类型背包的统一接口）
@@ -39,16 +97,17 @@ type Bag interface {
 	AutoAdd(item *Item) (int32, error)        // 自动寻找空位添加，返回槽位索引
 
 	// ===== 添加物品（仅配置ID和数量） =====
-	AddItemByConfigID(itemConfigID int32, count int32) error // 根据配置ID添加物品（自动堆叠）
+	AddItemByConfigID(itemConfigID int32, count int32, now int64) error // 根据配置ID添加物品（自动堆叠，按配置设置过期与绑定）
 
 	// ===== 移除物品 =====
 	Remove(slotIndex int32) (*Item, error)              // 移除整个槽位的物品
 	ReduceCount(slotIndex int32, count int32) error     // 减少指定槽位的数量（堆叠物品用）
-	RemoveItemByConfigID(itemConfigID int32, count int32) error // 根据配置ID移除指定数量的物品
+	RemoveItemByConfigID(itemConfigID int32, count int32) error // 根据配置ID移除指定数量的物品（优先移除最早过期的）
 
 	// ===== 查询物品数量 =====
 	GetItemCountByConfigID(itemConfigID int32) int32           // 获取指定配置ID物品的总数量
 	HasItemByConfigID(itemConfigID int32, count int32) bool    // 检查是否拥有足够数量
+	GetTradableCountByConfigID(itemConfigID int32) int32       // 获取指定配置ID可交易物品的总数量（不含已绑定物品）
 
 	// ===== 移动物品 =====
 	Move(fromSlot, toSlot int32) error // 移动物品到新槽位
@@ -57,6 +116,7 @@ type Bag interface {
 	// ===== 堆叠整理 =====
 	CanStack(itemA, itemB *Item) bool      // 判断两个物品是否可堆叠
 	Stack(fromSlot, toSlot int32) error    // 堆叠物品（fromSlot堆叠到toSlot）
+	Split(slotIndex int32, count int32) (int32, error) // 拆分堆叠，将 count 个物品移到新的空槽位，返回新槽位索引
 	Sort(sortType int32, ascending bool) error // 排序背包：sortType=排序类型，ascending=true升序/false降序
 	Compact() ([]*Item, error)             // 压缩背包（合并可堆叠物品，消除空隙），返回需要删除的物品
 
@@ -68,5 +128,6 @@ type Bag interface {
 	ClearNew(slotIndex int32) error                   // 清除新获得标记
 
 	// ===== 过期物品处理 =====
-	GetExpiredItems(currentTime int64) []*Item // 获取所有过期物品（ExpireTime > 0 且 <= currentTime）
+	GetExpiredItems(currentTime int64) []*Item    // 获取所有过期物品（ExpireTime > 0 且 <= currentTime）
+	RemoveExpiredItems(currentTime int64) []*Item // 移除并返回所有过期物品
 }
@@ -1,10 +1,13 @@
 package model
 
-// PlayerBag 玩家单个类型的背包数据
+import "sort"
+
+// PlayerBag 玩家单个类型的背包数据（持久化结构，按槽位存储物品实例）
 type PlayerBag struct {
-	RoleID  int64           `json:"role_id"`
-	BagType int32           `json:"bag_type"`
-	Items   map[int32]int32 `json:"items"` // Key: ItemID (配置ID), Value: Count
+	RoleID   int64   `json:"role_id"`
+	BagType  int32   `json:"bag_type"`
+	Capacity int32   `json:"capacity"` // 背包容量，0 表示尚未初始化，使用默认容量
+	Items    []*Item `json:"items"`    // 按槽位排序的物品实例
 }
 
 // NewPlayerBag 创建一个新的背包实例
@@ -12,6 +15,61 @@ func NewPlayerBag(roleID int64, bagType int32) *PlayerBag {
 	return &PlayerBag{
 		RoleID:  roleID,
 		BagType: bagType,
-		Items:   make(map[int32]int32),
+		Items:   make([]*Item, 0),
+	}
+}
+
+// ToBaseBag 将持久化数据还原为运行时背包
+// 容量未初始化时使用 defaultCapacity；槽位越界或重复的物品放入空槽位，没有空槽位时扩展容量，保证数据不丢失
+func (p *PlayerBag) ToBaseBag(defaultCapacity int32) *BaseBag {
+	capacity := p.Capacity
+	if capacity <= 0 {
+		capacity = defaultCapacity
+	}
+
+	bag := NewBaseBag(0, p.RoleID, p.BagType, capacity)
+	misplaced := make([]*Item, 0)
+	for _, item := range p.Items {
+		if item == nil || item.Count <= 0 {
+			continue
+		}
+		if _, exists := bag.items[item.SlotIndex]; exists || item.SlotIndex < 0 || item.SlotIndex >= capacity {
+			misplaced = append(misplaced, item)
+			continue
+		}
+		bag.items[item.SlotIndex] = item
+	}
+
+	for _, item := range misplaced {
+		slot := bag.FindEmptySlot()
+		if slot < 0 {
+			slot = bag.capacity
+			bag.capacity++
+		}
+		item.SlotIndex = slot
+		bag.items[slot] = item
+	}
+
+	return bag
+}
+
+// ToStorage 将运行时背包转换为持久化数据
+func (b *BaseBag) ToStorage() *PlayerBag {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	items := make([]*Item, 0, len(b.items))
+	for _, item := range b.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].SlotIndex < items[j].SlotIndex
+	})
+
+	return &PlayerBag{
+		RoleID:   b.roleID,
+		BagType:  b.bagType,
+		Capacity: b.capacity,
+		Items:    items,
 	}
 }
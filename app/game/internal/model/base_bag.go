@@ -167,12 +167,12 @@ func (b *BaseBag) AutoAdd(item *Item) (int32, error) {
 		}
 	}
 
-	return -1, fmt.Errorf("bag is full")
+	return -1, ErrBagFull
 }
 
 // ===== 添加物品（仅配置ID和数量） =====
 
-func (b *BaseBag) AddItemByConfigID(itemConfigID int32, count int32) error {
+func (b *BaseBag) AddItemByConfigID(itemConfigID int32, count int32, now int64) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -186,18 +186,25 @@ func (b *BaseBag) AddItemByConfigID(itemConfigID int32, count int32) error {
 		return err
 	}
 
+	// 按配置生成本次获得物品的模板（过期时间、绑定状态）
+	template, err := NewItem(b.roleID, itemConfigID, count, now)
+	if err != nil {
+		return err
+	}
+
 	// 根据背包类型判断是否可堆叠
 	canStack := b.canStackByBagType()
 
 	remaining := count
 
 	if canStack {
-		// 1. 先尝试堆叠到已有物品（考虑最大堆叠数量）
+		// 1. 先尝试堆叠到已有物品（考虑最大堆叠数量，过期时间与绑定状态相同才可堆叠）
 		for _, item := range b.items {
-			if item.ConfigID == itemConfigID && remaining > 0 {
+			if b.CanStack(item, template) && remaining > 0 {
 				// 计算当前格子还能堆叠多少
 				canAdd := maxStack - item.Count
 				if canAdd > 0 {
+					item.UpdateTime = now
 					if canAdd >= remaining {
 						item.Count += remaining
 						item.IsNew = true // 数量变化，标记为新获得
@@ -217,22 +224,16 @@ func (b *BaseBag) AddItemByConfigID(itemConfigID int32, count int32) error {
 		if _, exists := b.items[i]; !exists {
 			stackCount := min(remaining, maxStack)
 
-			newItem := &Item{
-				RoleID:     b.roleID,
-				ConfigID:   itemConfigID,
-				SlotIndex:  i,
-				Count:      stackCount,
-				IsNew:      true, // 新物品，标记为新获得
-				CreateTime: 0,    // TODO: 使用实际时间
-				UpdateTime: 0,
-			}
-			b.items[i] = newItem
+			newItem := *template
+			newItem.SlotIndex = i
+			newItem.Count = stackCount
+			b.items[i] = &newItem
 			remaining -= stackCount
 		}
 	}
 
 	if remaining > 0 {
-		return fmt.Errorf("bag is full, %d items not added", remaining)
+		return fmt.Errorf("%w, %d items not added", ErrBagFull, remaining)
 	}
 
 	return nil
@@ -294,26 +295,57 @@ func (b *BaseBag) RemoveItemByConfigID(itemConfigID int32, count int32) error {
 		return fmt.Errorf("insufficient item count: have %d, need %d", totalCount, count)
 	}
 
-	// 开始扣除
+	// 开始扣除（优先扣除最早过期的物品）
 	remaining := count
-	for slotIndex, item := range b.items {
-		if item.ConfigID == itemConfigID {
-			if item.Count >= remaining {
-				item.Count -= remaining
-				if item.Count <= 0 {
-					delete(b.items, slotIndex)
-				}
-				return nil
-			} else {
-				remaining -= item.Count
-				delete(b.items, slotIndex)
+	for _, item := range b.sortedItemsByExpire(itemConfigID) {
+		if item.Count >= remaining {
+			item.Count -= remaining
+			if item.Count <= 0 {
+				delete(b.items, item.SlotIndex)
 			}
+			return nil
 		}
+		remaining -= item.Count
+		delete(b.items, item.SlotIndex)
 	}
 
 	return nil
 }
 
+// sortedItemsBySlot 按槽位顺序返回所有物品
+func (b *BaseBag) sortedItemsBySlot() []*Item {
+	items := make([]*Item, 0, len(b.items))
+	for _, item := range b.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].SlotIndex < items[j].SlotIndex
+	})
+	return items
+}
+
+// sortedItemsByExpire 按过期时间升序返回指定配置ID的物品，永久物品排在最后，过期时间相同按槽位排序
+func (b *BaseBag) sortedItemsByExpire(itemConfigID int32) []*Item {
+	items := make([]*Item, 0)
+	for _, item := range b.items {
+		if item.ConfigID == itemConfigID {
+			items = append(items, item)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		x, y := items[i], items[j]
+		if x.ExpireTime != y.ExpireTime {
+			if x.ExpireTime == 0 || y.ExpireTime == 0 {
+				return y.ExpireTime == 0
+			}
+			return x.ExpireTime < y.ExpireTime
+		}
+		return x.SlotIndex < y.SlotIndex
+	})
+	return items
+}
+
 // ===== 查询物品数量 =====
 
 func (b *BaseBag) GetItemCountByConfigID(itemConfigID int32) int32 {
@@ -333,6 +365,20 @@ func (b *BaseBag) HasItemByConfigID(itemConfigID int32, count int32) bool {
 	return b.GetItemCountByConfigID(itemConfigID) >= count
 }
 
+// GetTradableCountByConfigID 获取指定配置ID可交易物品的总数量（不含已绑定物品）
+func (b *BaseBag) GetTradableCountByConfigID(itemConfigID int32) int32 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	totalCount := int32(0)
+	for _, item := range b.items {
+		if item.ConfigID == itemConfigID && item.IsTradable() {
+			totalCount += item.Count
+		}
+	}
+	return totalCount
+}
+
 // ===== 移动物品 =====
 
 func (b *BaseBag) Move(fromSlot, toSlot int32) error {
@@ -403,14 +449,21 @@ func (b *BaseBag) CanStack(itemA, itemB *Item) bool {
 		return false
 	}
 
-	// 相同配置ID且绑定类型相同才可堆叠
-	return itemA.ConfigID == itemB.ConfigID && itemA.BindType == itemB.BindType
+	// 相同配置ID、绑定状态与过期时间都相同才可堆叠
+	return itemA.ConfigID == itemB.ConfigID &&
+		itemA.BindType == itemB.BindType &&
+		itemA.IsBound == itemB.IsBound &&
+		itemA.ExpireTime == itemB.ExpireTime
 }
 
 func (b *BaseBag) Stack(fromSlot, toSlot int32) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if fromSlot == toSlot {
+		return fmt.Errorf("cannot stack slot %d onto itself", fromSlot)
+	}
+
 	itemFrom, existsFrom := b.items[fromSlot]
 	itemTo, existsTo := b.items[toSlot]
 
@@ -448,16 +501,54 @@ func (b *BaseBag) Stack(fromSlot, toSlot int32) error {
 	return nil
 }
 
-func (b *BaseBag) Sort(sortType int32, ascending bool) error {
+func (b *BaseBag) Split(slotIndex int32, count int32) (int32, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	// 收集所有物品
-	items := make([]*Item, 0, len(b.items))
-	for _, item := range b.items {
-		items = append(items, item)
+	item, exists := b.items[slotIndex]
+	if !exists {
+		return -1, fmt.Errorf("slot %d is empty", slotIndex)
+	}
+
+	if !b.canStackByBagType() {
+		return -1, fmt.Errorf("items in bag type %d cannot be split", b.bagType)
+	}
+
+	// 拆分数量必须小于原堆叠数量，否则等同于移动
+	if count <= 0 || count >= item.Count {
+		return -1, fmt.Errorf("invalid split count: %d (have %d)", count, item.Count)
+	}
+
+	// 查找空槽位
+	targetSlot := int32(-1)
+	for i := int32(0); i < b.capacity; i++ {
+		if _, exists := b.items[i]; !exists {
+			targetSlot = i
+			break
+		}
+	}
+	if targetSlot == -1 {
+		return -1, ErrBagFull
 	}
 
+	// 新堆叠继承原物品的绑定状态与过期时间
+	newItem := *item
+	newItem.ID = 0
+	newItem.SlotIndex = targetSlot
+	newItem.Count = count
+	b.items[targetSlot] = &newItem
+	item.Count -= count
+
+	return targetSlot, nil
+}
+
+func (b *BaseBag) Sort(sortType int32, ascending bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 收集所有物品（按槽位顺序，保证排序键相同的物品保持原有相对位置）
+	items := b.sortedItemsBySlot()
+
 	// 获取排序策略
 	comparator, err := getSortComparator(sortType, ascending)
 	if err != nil {
@@ -465,7 +556,7 @@ func (b *BaseBag) Sort(sortType int32, ascending bool) error {
 	}
 
 	// 执行排序
-	sort.Slice(items, func(i, j int) bool {
+	sort.SliceStable(items, func(i, j int) bool {
 		return comparator(items[i], items[j])
 	})
 
@@ -488,20 +579,28 @@ func (b *BaseBag) Compact() ([]*Item, error) {
 	toDelete := make([]*Item, 0)
 
 	if canStack {
-		// 1. 按 ConfigID + BindType 分组合并可堆叠物品
+		// 1. 按 ConfigID + 绑定状态 + 过期时间分组合并可堆叠物品
 		type stackKey struct {
-			ConfigID int32
-			BindType int32
+			ConfigID   int32
+			BindType   int32
+			IsBound    bool
+			ExpireTime int64
 		}
 
-		// 用于合并的临时数据结构
+		// 用于合并的临时数据结构（按分组首次出现的槽位顺序合并，结果稳定）
 		stackGroups := make(map[stackKey][]*Item)
+		groupOrder := make([]stackKey, 0)
 
 		// 收集所有物品并分组
-		for _, item := range b.items {
+		for _, item := range b.sortedItemsBySlot() {
 			key := stackKey{
-				ConfigID: item.ConfigID,
-				BindType: item.BindType,
+				ConfigID:   item.ConfigID,
+				BindType:   item.BindType,
+				IsBound:    item.IsBound,
+				ExpireTime: item.ExpireTime,
+			}
+			if _, exists := stackGroups[key]; !exists {
+				groupOrder = append(groupOrder, key)
 			}
 			stackGroups[key] = append(stackGroups[key], item)
 		}
@@ -510,10 +609,13 @@ func (b *BaseBag) Compact() ([]*Item, error) {
 		mergedItems := make([]*Item, 0)
 
 		// 对每组进行堆叠合并
-		for _, group := range stackGroups {
-			if len(group) == 0 {
-				continue
-			}
+		for _, key := range groupOrder {
+			group := stackGroups[key]
+
+			// 收藏的物品优先保留，合并后仍为收藏
+			sort.SliceStable(group, func(i, j int) bool {
+				return group[i].IsFavorite && !group[j].IsFavorite
+			})
 
 			// 获取最大堆叠数量
 			maxStack, err := getMaxStack(group[0].ConfigID)
@@ -559,10 +661,7 @@ func (b *BaseBag) Compact() ([]*Item, error) {
 		}
 	} else {
 		// 装扮类物品不可堆叠，只消除空隙
-		items := make([]*Item, 0, len(b.items))
-		for _, item := range b.items {
-			items = append(items, item)
-		}
+		items := b.sortedItemsBySlot()
 
 		b.items = make(map[int32]*Item)
 		for i, item := range items {
@@ -787,3 +886,21 @@ func (b *BaseBag) GetExpiredItems(currentTime int64) []*Item {
 
 	return expiredItems
 }
+
+// RemoveExpiredItems 移除所有过期物品
+// currentTime: 当前时间戳
+// 返回被移除的物品
+func (b *BaseBag) RemoveExpiredItems(currentTime int64) []*Item {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	expiredItems := make([]*Item, 0)
+	for slotIndex, item := range b.items {
+		if item.IsExpired(currentTime) {
+			expiredItems = append(expiredItems, item)
+			delete(b.items, slotIndex)
+		}
+	}
+
+	return expiredItems
+}
@@ -77,7 +77,16 @@ func (b *EquipmentBag) Add(item *Item, slotIndex int32) error {
 	}
 
 	// 调用基类方法
-	return b.BaseBag.Add(item, slotIndex)
+	if err := b.BaseBag.Add(item, slotIndex); err != nil {
+		return err
+	}
+
+	// 装备绑定的物品在穿戴时绑定
+	item.IsEquipped = true
+	if item.BindType == gameconfig.BindType_EquipBind {
+		item.IsBound = true
+	}
+	return nil
 }
 
 func (b *EquipmentBag) AutoAdd(item *Item) (int32, error) {
@@ -120,7 +129,7 @@ func (b *EquipmentBag) AutoAdd(item *Item) (int32, error) {
 
 // ===== 装备栏不支持的操作 =====
 
-func (b *EquipmentBag) AddItemByConfigID(itemConfigID int32, count int32) error {
+func (b *EquipmentBag) AddItemByConfigID(itemConfigID int32, count int32, now int64) error {
 	return fmt.Errorf("equipment bag does not support AddItemByConfigID")
 }
 
@@ -140,3 +149,7 @@ func (b *EquipmentBag) CanStack(itemA, itemB *Item) bool {
 func (b *EquipmentBag) Stack(fromSlot, toSlot int32) error {
 	return fmt.Errorf("equipment cannot be stacked")
 }
+
+func (b *EquipmentBag) Split(slotIndex int32, count int32) (int32, error) {
+	return -1, fmt.Errorf("equipment cannot be split")
+}
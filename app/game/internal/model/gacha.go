@@ -13,3 +13,36 @@ type PlayerGacha struct {
 	RoleID  int64          `json:"role_id"`
 	Records []*GachaRecord `json:"records"`
 }
+
+// GachaDrawLog 单次抽取流水（只追加）
+type GachaDrawLog struct {
+	ID        int64            `json:"id"`
+	RoleID    int64            `json:"role_id"`
+	PoolID    int32            `json:"pool_id"`
+	DropID    int32            `json:"drop_id"`    // 实际使用的掉落ID
+	Seed      int64            `json:"seed"`       // 掉落随机种子，用于重放
+	IsPity    bool             `json:"is_pity"`    // 是否触发保底
+	PityCount int32            `json:"pity_count"` // 本次抽取时的保底计数
+	Results   []*GachaDrawItem `json:"results"`
+	CreatedAt int64            `json:"created_at"` // 抽取时间 (Unix)
+}
+
+// GachaDrawItem 单次抽取的产出
+type GachaDrawItem struct {
+	ItemID int32 `json:"item_id"`
+	Count  int32 `json:"count"`
+}
+
+// GachaDropStats 某池子在统计区间内按掉落ID聚合的实际产出
+type GachaDropStats struct {
+	DropID int32
+	Draws  int64 // 使用该掉落ID的抽取次数
+	Items  []*GachaItemStats
+}
+
+// GachaItemStats 某道具的实际产出统计
+type GachaItemStats struct {
+	ItemID int32
+	Hits   int64 // 出现次数（每次掉落判定命中计一次）
+	Total  int64 // 产出总数量
+}
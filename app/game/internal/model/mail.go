@@ -0,0 +1,111 @@
+package model
+
+import "slices"
+
+// 邮件类型
+const (
+	MailTypeSystem = 1 // 系统邮件（直接发给指定角色）
+	MailTypeGlobal = 2 // 全服邮件（角色拉取邮箱时投递）
+)
+
+// 全服邮件投放目标类型
+const (
+	MailTargetAll   = 0 // 全部角色
+	MailTargetLevel = 1 // 等级区间内的角色
+	MailTargetRoles = 2 // 指定角色列表
+)
+
+// MailAttachment 邮件附件
+type MailAttachment struct {
+	ItemID int32 `json:"item_id"` // 道具或玩偶配置ID
+	Count  int32 `json:"count"`   // 数量
+}
+
+// Mail 角色邮件，对应 role_mails 表
+type Mail struct {
+	ID          int64             // 邮件ID
+	RoleID      int64             // 收件角色ID
+	GlobalID    int64             // 来源全服邮件ID，系统邮件为 0
+	MailType    int32             // 邮件类型 (MailType*)
+	Sender      string            // 发件人
+	Title       string            // 标题
+	Content     string            // 正文
+	Attachments []*MailAttachment // 附件
+	IsRead      bool              // 是否已读
+	IsClaimed   bool              // 附件是否已领取
+	SendTime    int64             // 发送时间 (Unix)
+	ExpireTime  int64             // 过期时间 (Unix)，0 表示永不过期
+}
+
+// IsExpired 判断邮件是否已过期
+func (m *Mail) IsExpired(now int64) bool {
+	return m.ExpireTime > 0 && now >= m.ExpireTime
+}
+
+// HasAttachments 判断邮件是否带附件
+func (m *Mail) HasAttachments() bool {
+	return len(m.Attachments) > 0
+}
+
+// CanClaim 判断邮件是否有未领取的附件
+func (m *Mail) CanClaim() bool {
+	return m.HasAttachments() && !m.IsClaimed
+}
+
+// MailTarget 全服邮件投放目标
+type MailTarget struct {
+	Type     int32   // 目标类型 (MailTarget*)
+	MinLevel int32   // 最低等级（含），仅 MailTargetLevel 使用
+	MaxLevel int32   // 最高等级（含），0 表示不限，仅 MailTargetLevel 使用
+	RoleIDs  []int64 // 角色列表，仅 MailTargetRoles 使用
+}
+
+// Matches 判断角色是否属于投放目标，等级按判断时的角色等级计算
+func (t *MailTarget) Matches(role *Role) bool {
+	switch t.Type {
+	case MailTargetAll:
+		return true
+	case MailTargetLevel:
+		return role.Level >= t.MinLevel && (t.MaxLevel == 0 || role.Level <= t.MaxLevel)
+	case MailTargetRoles:
+		return slices.Contains(t.RoleIDs, role.ID)
+	}
+	return false
+}
+
+// GlobalMail 全服邮件，对应 global_mails 表
+// 角色拉取邮箱时按投放目标筛选，复制一份 Mail 到角色邮箱
+type GlobalMail struct {
+	ID          int64
+	Sender      string
+	Title       string
+	Content     string
+	Attachments []*MailAttachment
+	Target      MailTarget
+	SendTime    int64 // 发送时间 (Unix)
+	ExpireTime  int64 // 过期时间 (Unix)，0 表示永不过期
+}
+
+// Matches 判断角色是否应收到该全服邮件
+// 发送之后创建的角色不补发（避免补偿邮件被新角色领取）
+func (g *GlobalMail) Matches(role *Role) bool {
+	if !role.CreatedAt.IsZero() && role.CreatedAt.Unix() > g.SendTime {
+		return false
+	}
+	return g.Target.Matches(role)
+}
+
+// ToMail 生成投递到角色邮箱的邮件副本
+func (g *GlobalMail) ToMail(roleID int64) *Mail {
+	return &Mail{
+		RoleID:      roleID,
+		GlobalID:    g.ID,
+		MailType:    MailTypeGlobal,
+		Sender:      g.Sender,
+		Title:       g.Title,
+		Content:     g.Content,
+		Attachments: g.Attachments,
+		SendTime:    g.SendTime,
+		ExpireTime:  g.ExpireTime,
+	}
+}
@@ -83,3 +83,31 @@ func (r *Role) UpdateLastLogin() {
 		Valid: true,
 	}
 }
+
+// RoleGrowth 角色等级成长数据
+type RoleGrowth struct {
+	Level  int32
+	Exp    int64 // 当前等级内的经验值
+	VIPExp int64 // 累计获得的总经验值（用于计算VIP等级）
+}
+
+// Growth 获取角色等级成长数据
+func (r *Role) Growth() *RoleGrowth {
+	return &RoleGrowth{
+		Level:  r.Level,
+		Exp:    r.Exp,
+		VIPExp: r.VIPExp,
+	}
+}
+
+// ApplyGrowth 更新角色等级成长数据
+// VIPExp 只增不减，若 g 比当前数据更旧（并发写入时晚到的结果）则忽略
+func (r *Role) ApplyGrowth(g *RoleGrowth) bool {
+	if g.VIPExp < r.VIPExp {
+		return false
+	}
+	r.Level = g.Level
+	r.Exp = g.Exp
+	r.VIPExp = g.VIPExp
+	return true
+}
@@ -0,0 +1,53 @@
+package model
+
+// ShopPurchase 商品限购记录
+type ShopPurchase struct {
+	ID          int32 `json:"id"`           // 商品ID (TbShopItem.Id)
+	Count       int32 `json:"count"`        // 当前限购周期内已购数量
+	PeriodStart int64 `json:"period_start"` // 限购周期起始时间 (Unix)，终身限购为 0
+	LastTime    int64 `json:"last_time"`    // 最后购买时间 (Unix)
+}
+
+// MysteryShop 神秘商店货架
+type MysteryShop struct {
+	ShopID      int32   `json:"shop_id"`      // 商店ID
+	ItemIDs     []int32 `json:"item_ids"`     // 当前上架的商品ID
+	RefreshTime int64   `json:"refresh_time"` // 货架所属刷新周期起始时间 (Unix)
+}
+
+// PlayerShop 玩家商店数据
+type PlayerShop struct {
+	RoleID    int64           `json:"role_id"`
+	Purchases []*ShopPurchase `json:"purchases"`
+	Mystery   []*MysteryShop  `json:"mystery"`
+}
+
+// GetPurchase 获取商品限购记录，不存在时返回 nil
+func (p *PlayerShop) GetPurchase(shopItemID int32) *ShopPurchase {
+	for _, r := range p.Purchases {
+		if r.ID == shopItemID {
+			return r
+		}
+	}
+	return nil
+}
+
+// GetMystery 获取神秘商店货架，不存在时返回 nil
+func (p *PlayerShop) GetMystery(shopID int32) *MysteryShop {
+	for _, m := range p.Mystery {
+		if m.ShopID == shopID {
+			return m
+		}
+	}
+	return nil
+}
+
+// HasItem 判断商品是否在货架上
+func (m *MysteryShop) HasItem(shopItemID int32) bool {
+	for _, id := range m.ItemIDs {
+		if id == shopItemID {
+			return true
+		}
+	}
+	return false
+}
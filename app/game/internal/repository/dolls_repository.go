@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/lk2023060901/xdooria/app/game/internal/model"
 )
@@ -11,6 +12,15 @@ import (
 
 // GetDolls 获取玩家所有玩偶（优先从缓存）
 func (r *playerRepositoryImpl) GetDolls(ctx context.Context, playerID int64) ([]*model.Doll, error) {
+	// 工作单元内缓存可能落后于事务中的未提交修改，直接读事务
+	if inUnitOfWork(ctx) {
+		dolls, err := r.dollDAO.ListByPlayerID(ctx, playerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load dolls from db: %w", err)
+		}
+		return dolls, nil
+	}
+
 	// 1. 先尝试从缓存获取
 	dolls, err := r.cacheDAO.GetDolls(ctx, playerID)
 	if err != nil {
@@ -72,11 +82,13 @@ func (r *playerRepositoryImpl) AddDoll(ctx context.Context, doll *model.Doll) er
 	}
 
 	// 2. 删除缓存，下次查询时重新加载
-	if err := r.cacheDAO.DeleteDolls(ctx, doll.PlayerID); err != nil {
-		r.logger.Warn("failed to delete dolls cache after add",
-			"player_id", doll.PlayerID,
-			"error", err,
-		)
+	if !r.deferDollsInvalidation(ctx, doll.PlayerID) {
+		if err := r.cacheDAO.DeleteDolls(ctx, doll.PlayerID); err != nil {
+			r.logger.Warn("failed to delete dolls cache after add",
+				"player_id", doll.PlayerID,
+				"error", err,
+			)
+		}
 	}
 
 	r.logger.Info("doll added",
@@ -106,6 +118,9 @@ func (r *playerRepositoryImpl) AddDolls(ctx context.Context, dolls []*model.Doll
 	}
 
 	for playerID := range playerIDs {
+		if r.deferDollsInvalidation(ctx, playerID) {
+			continue
+		}
 		if err := r.cacheDAO.DeleteDolls(ctx, playerID); err != nil {
 			r.logger.Warn("failed to delete dolls cache after batch add",
 				"player_id", playerID,
@@ -129,20 +144,22 @@ func (r *playerRepositoryImpl) UpdateDollLock(ctx context.Context, playerID int6
 		return fmt.Errorf("failed to update lock status: %w", err)
 	}
 
-	// 2. 更新缓存中的数据
-	dolls, err := r.cacheDAO.GetDolls(ctx, playerID)
-	if err == nil && dolls != nil {
-		for _, doll := range dolls {
-			if doll.ID == dollID {
-				doll.IsLocked = isLocked
-				break
+	// 2. 更新缓存中的数据（工作单元内改为提交后删除缓存）
+	if !r.deferDollsInvalidation(ctx, playerID) {
+		dolls, err := r.cacheDAO.GetDolls(ctx, playerID)
+		if err == nil && dolls != nil {
+			for _, doll := range dolls {
+				if doll.ID == dollID {
+					doll.IsLocked = isLocked
+					break
+				}
+			}
+			if err := r.cacheDAO.SetDolls(ctx, playerID, dolls, 0); err != nil {
+				r.logger.Warn("failed to update dolls cache after lock change",
+					"player_id", playerID,
+					"error", err,
+				)
 			}
-		}
-		if err := r.cacheDAO.SetDolls(ctx, playerID, dolls, 0); err != nil {
-			r.logger.Warn("failed to update dolls cache after lock change",
-				"player_id", playerID,
-				"error", err,
-			)
 		}
 	}
 
@@ -162,20 +179,22 @@ func (r *playerRepositoryImpl) UpdateDollRedeem(ctx context.Context, playerID in
 		return fmt.Errorf("failed to update redeem status: %w", err)
 	}
 
-	// 2. 更新缓存中的数据
-	dolls, err := r.cacheDAO.GetDolls(ctx, playerID)
-	if err == nil && dolls != nil {
-		for _, doll := range dolls {
-			if doll.ID == dollID {
-				doll.IsRedeemed = isRedeemed
-				break
+	// 2. 更新缓存中的数据（工作单元内改为提交后删除缓存）
+	if !r.deferDollsInvalidation(ctx, playerID) {
+		dolls, err := r.cacheDAO.GetDolls(ctx, playerID)
+		if err == nil && dolls != nil {
+			for _, doll := range dolls {
+				if doll.ID == dollID {
+					doll.IsRedeemed = isRedeemed
+					break
+				}
+			}
+			if err := r.cacheDAO.SetDolls(ctx, playerID, dolls, 0); err != nil {
+				r.logger.Warn("failed to update dolls cache after redeem change",
+					"player_id", playerID,
+					"error", err,
+				)
 			}
-		}
-		if err := r.cacheDAO.SetDolls(ctx, playerID, dolls, 0); err != nil {
-			r.logger.Warn("failed to update dolls cache after redeem change",
-				"player_id", playerID,
-				"error", err,
-			)
 		}
 	}
 
@@ -195,20 +214,22 @@ func (r *playerRepositoryImpl) UpdateDollQuality(ctx context.Context, playerID i
 		return fmt.Errorf("failed to update quality: %w", err)
 	}
 
-	// 2. 更新缓存中的数据
-	dolls, err := r.cacheDAO.GetDolls(ctx, playerID)
-	if err == nil && dolls != nil {
-		for _, doll := range dolls {
-			if doll.ID == dollID {
-				doll.Quality = quality
-				break
+	// 2. 更新缓存中的数据（工作单元内改为提交后删除缓存）
+	if !r.deferDollsInvalidation(ctx, playerID) {
+		dolls, err := r.cacheDAO.GetDolls(ctx, playerID)
+		if err == nil && dolls != nil {
+			for _, doll := range dolls {
+				if doll.ID == dollID {
+					doll.Quality = quality
+					break
+				}
+			}
+			if err := r.cacheDAO.SetDolls(ctx, playerID, dolls, 0); err != nil {
+				r.logger.Warn("failed to update dolls cache after quality change",
+					"player_id", playerID,
+					"error", err,
+				)
 			}
-		}
-		if err := r.cacheDAO.SetDolls(ctx, playerID, dolls, 0); err != nil {
-			r.logger.Warn("failed to update dolls cache after quality change",
-				"player_id", playerID,
-				"error", err,
-			)
 		}
 	}
 
@@ -228,20 +249,22 @@ func (r *playerRepositoryImpl) DeleteDoll(ctx context.Context, playerID int64, d
 		return fmt.Errorf("failed to delete doll: %w", err)
 	}
 
-	// 2. 从缓存中移除
-	dolls, err := r.cacheDAO.GetDolls(ctx, playerID)
-	if err == nil && dolls != nil {
-		newDolls := make([]*model.Doll, 0, len(dolls))
-		for _, doll := range dolls {
-			if doll.ID != dollID {
-				newDolls = append(newDolls, doll)
+	// 2. 从缓存中移除（工作单元内改为提交后删除缓存）
+	if !r.deferDollsInvalidation(ctx, playerID) {
+		dolls, err := r.cacheDAO.GetDolls(ctx, playerID)
+		if err == nil && dolls != nil {
+			newDolls := make([]*model.Doll, 0, len(dolls))
+			for _, doll := range dolls {
+				if doll.ID != dollID {
+					newDolls = append(newDolls, doll)
+				}
+			}
+			if err := r.cacheDAO.SetDolls(ctx, playerID, newDolls, 0); err != nil {
+				r.logger.Warn("failed to update dolls cache after delete",
+					"player_id", playerID,
+					"error", err,
+				)
 			}
-		}
-		if err := r.cacheDAO.SetDolls(ctx, playerID, newDolls, 0); err != nil {
-			r.logger.Warn("failed to update dolls cache after delete",
-				"player_id", playerID,
-				"error", err,
-			)
 		}
 	}
 
@@ -264,26 +287,28 @@ func (r *playerRepositoryImpl) DeleteDolls(ctx context.Context, playerID int64,
 		return fmt.Errorf("failed to batch delete dolls: %w", err)
 	}
 
-	// 2. 从缓存中移除
-	dolls, err := r.cacheDAO.GetDolls(ctx, playerID)
-	if err == nil && dolls != nil {
-		idSet := make(map[int64]bool)
-		for _, id := range dollIDs {
-			idSet[id] = true
-		}
+	// 2. 从缓存中移除（工作单元内改为提交后删除缓存）
+	if !r.deferDollsInvalidation(ctx, playerID) {
+		dolls, err := r.cacheDAO.GetDolls(ctx, playerID)
+		if err == nil && dolls != nil {
+			idSet := make(map[int64]bool)
+			for _, id := range dollIDs {
+				idSet[id] = true
+			}
 
-		newDolls := make([]*model.Doll, 0, len(dolls))
-		for _, doll := range dolls {
-			if !idSet[doll.ID] {
-				newDolls = append(newDolls, doll)
+			newDolls := make([]*model.Doll, 0, len(dolls))
+			for _, doll := range dolls {
+				if !idSet[doll.ID] {
+					newDolls = append(newDolls, doll)
+				}
 			}
-		}
 
-		if err := r.cacheDAO.SetDolls(ctx, playerID, newDolls, 0); err != nil {
-			r.logger.Warn("failed to update dolls cache after batch delete",
-				"player_id", playerID,
-				"error", err,
-			)
+			if err := r.cacheDAO.SetDolls(ctx, playerID, newDolls, 0); err != nil {
+				r.logger.Warn("failed to update dolls cache after batch delete",
+					"player_id", playerID,
+					"error", err,
+				)
+			}
 		}
 	}
 
@@ -295,6 +320,25 @@ func (r *playerRepositoryImpl) DeleteDolls(ctx context.Context, playerID int64,
 	return nil
 }
 
+// deferDollsInvalidation 处于工作单元中时，将玩偶缓存失效延迟到事务提交之后
+// 返回 true 表示已延迟，调用方不应再直接操作缓存
+func (r *playerRepositoryImpl) deferDollsInvalidation(ctx context.Context, playerID int64) bool {
+	scope, ok := scopeFromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	scope.afterCommit(fmt.Sprintf("dolls:%d", playerID), func(ctx context.Context) {
+		if err := r.cacheDAO.DeleteDolls(ctx, playerID); err != nil {
+			r.logger.Warn("failed to delete dolls cache after commit",
+				"player_id", playerID,
+				"error", err,
+			)
+		}
+	})
+	return true
+}
+
 // ============ 抽卡相关实现 ============
 
 // GetGachaRecords 获取玩家抽卡记录
@@ -307,6 +351,26 @@ func (r *playerRepositoryImpl) SaveGachaRecords(ctx context.Context, gacha *mode
 	return r.gachaDAO.Save(ctx, gacha)
 }
 
+// AddGachaDrawLogs 追加抽卡流水
+func (r *playerRepositoryImpl) AddGachaDrawLogs(ctx context.Context, logs []*model.GachaDrawLog) error {
+	return r.gachaDAO.InsertDrawLogs(ctx, logs)
+}
+
+// ListGachaDrawLogs 分页查询玩家抽卡流水
+func (r *playerRepositoryImpl) ListGachaDrawLogs(ctx context.Context, roleID int64, poolID int32, offset, limit uint64) ([]*model.GachaDrawLog, int64, error) {
+	return r.gachaDAO.ListDrawLogs(ctx, roleID, poolID, offset, limit)
+}
+
+// GetGachaDrawLog 按ID查询抽卡流水，不存在时返回 nil
+func (r *playerRepositoryImpl) GetGachaDrawLog(ctx context.Context, id int64) (*model.GachaDrawLog, error) {
+	return r.gachaDAO.GetDrawLog(ctx, id)
+}
+
+// GetGachaPoolStats 查询池子在时间区间内的实际产出统计
+func (r *playerRepositoryImpl) GetGachaPoolStats(ctx context.Context, poolID int32, from, to time.Time) ([]*model.GachaDropStats, error) {
+	return r.gachaDAO.GetPoolStats(ctx, poolID, from, to)
+}
+
 // ============ 背包相关实现 ============
 
 // GetBag 获取玩家指定类型的背包
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/dao"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// MailRepository 邮件仓储接口
+type MailRepository interface {
+	// ===== 角色邮件 =====
+	AddMails(ctx context.Context, mails []*model.Mail) error
+	ListMails(ctx context.Context, roleID int64, now int64, limit uint64) ([]*model.Mail, error)
+	GetMail(ctx context.Context, roleID int64, mailID int64) (*model.Mail, error)
+	UpdateMailState(ctx context.Context, mail *model.Mail) error
+	DeleteMails(ctx context.Context, roleID int64, mailIDs []int64) error
+
+	// ===== 全服邮件 =====
+	AddGlobalMail(ctx context.Context, mail *model.GlobalMail) error
+	ListPendingGlobalMails(ctx context.Context, roleID int64, now int64) ([]*model.GlobalMail, error)
+
+	// ===== 清理 =====
+	PurgeExpiredMails(ctx context.Context, now int64) (int64, error)
+}
+
+// mailRepositoryImpl 邮件仓储实现
+type mailRepositoryImpl struct {
+	mailDAO *dao.MailDAO
+	logger  logger.Logger
+}
+
+// NewMailRepository 创建邮件仓储
+func NewMailRepository(mailDAO *dao.MailDAO, l logger.Logger) MailRepository {
+	return &mailRepositoryImpl{
+		mailDAO: mailDAO,
+		logger:  l.Named("repository.mail"),
+	}
+}
+
+// AddMails 写入角色邮件（全服邮件副本重复写入时忽略）
+func (r *mailRepositoryImpl) AddMails(ctx context.Context, mails []*model.Mail) error {
+	return r.mailDAO.InsertMails(ctx, mails)
+}
+
+// ListMails 获取角色邮件列表（邮件状态需与领取事务一致，直接查库）
+func (r *mailRepositoryImpl) ListMails(ctx context.Context, roleID int64, now int64, limit uint64) ([]*model.Mail, error) {
+	return r.mailDAO.ListByRoleID(ctx, roleID, now, limit)
+}
+
+// GetMail 获取角色的指定邮件，不存在时返回 nil
+func (r *mailRepositoryImpl) GetMail(ctx context.Context, roleID int64, mailID int64) (*model.Mail, error) {
+	return r.mailDAO.GetByID(ctx, roleID, mailID)
+}
+
+// UpdateMailState 保存邮件已读与领取状态
+func (r *mailRepositoryImpl) UpdateMailState(ctx context.Context, mail *model.Mail) error {
+	return r.mailDAO.UpdateState(ctx, mail)
+}
+
+// DeleteMails 删除角色邮件
+func (r *mailRepositoryImpl) DeleteMails(ctx context.Context, roleID int64, mailIDs []int64) error {
+	return r.mailDAO.MarkDeleted(ctx, roleID, mailIDs)
+}
+
+// AddGlobalMail 写入全服邮件
+func (r *mailRepositoryImpl) AddGlobalMail(ctx context.Context, mail *model.GlobalMail) error {
+	return r.mailDAO.InsertGlobalMail(ctx, mail)
+}
+
+// ListPendingGlobalMails 获取尚未投递给角色的有效全服邮件
+func (r *mailRepositoryImpl) ListPendingGlobalMails(ctx context.Context, roleID int64, now int64) ([]*model.GlobalMail, error) {
+	return r.mailDAO.ListPendingGlobalMails(ctx, roleID, now)
+}
+
+// PurgeExpiredMails 清理已过期的邮件，返回清理的角色邮件数量
+func (r *mailRepositoryImpl) PurgeExpiredMails(ctx context.Context, now int64) (int64, error) {
+	return r.mailDAO.DeleteExpired(ctx, now)
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/lk2023060901/xdooria/app/game/internal/dao"
 	"github.com/lk2023060901/xdooria/app/game/internal/model"
@@ -24,6 +25,10 @@ type PlayerRepository interface {
 	// ===== 抽卡相关 =====
 	GetGachaRecords(ctx context.Context, roleID int64) (*model.PlayerGacha, error)
 	SaveGachaRecords(ctx context.Context, gacha *model.PlayerGacha) error
+	AddGachaDrawLogs(ctx context.Context, logs []*model.GachaDrawLog) error
+	ListGachaDrawLogs(ctx context.Context, roleID int64, poolID int32, offset, limit uint64) ([]*model.GachaDrawLog, int64, error)
+	GetGachaDrawLog(ctx context.Context, id int64) (*model.GachaDrawLog, error)
+	GetGachaPoolStats(ctx context.Context, poolID int32, from, to time.Time) ([]*model.GachaDropStats, error)
 
 	// ===== 背包(堆叠道具)相关 =====
 	GetBag(ctx context.Context, roleID int64, bagType int32) (*model.PlayerBag, error)
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/dao"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// RoleRepository 角色仓储接口
+type RoleRepository interface {
+	// ===== 等级成长 =====
+	GetRoleGrowth(ctx context.Context, roleID int64) (*model.RoleGrowth, error)
+	SaveRoleGrowth(ctx context.Context, roleID int64, growth *model.RoleGrowth) error
+}
+
+// roleRepositoryImpl 角色仓储实现
+type roleRepositoryImpl struct {
+	roleDAO  *dao.RoleDAO
+	cacheDAO *dao.CacheDAO
+	logger   logger.Logger
+}
+
+// NewRoleRepository 创建角色仓储
+func NewRoleRepository(roleDAO *dao.RoleDAO, cacheDAO *dao.CacheDAO, l logger.Logger) RoleRepository {
+	return &roleRepositoryImpl{
+		roleDAO:  roleDAO,
+		cacheDAO: cacheDAO,
+		logger:   l.Named("repository.role"),
+	}
+}
+
+// GetRoleGrowth 获取角色等级成长数据（直接查库，工作单元内加行锁串行化同一角色的经验变更）
+func (r *roleRepositoryImpl) GetRoleGrowth(ctx context.Context, roleID int64) (*model.RoleGrowth, error) {
+	return r.roleDAO.GetGrowth(ctx, roleID)
+}
+
+// SaveRoleGrowth 保存角色等级成长数据，并使角色缓存失效
+func (r *roleRepositoryImpl) SaveRoleGrowth(ctx context.Context, roleID int64, growth *model.RoleGrowth) error {
+	if err := r.roleDAO.UpdateGrowth(ctx, roleID, growth); err != nil {
+		return err
+	}
+
+	if r.deferRoleInvalidation(ctx, roleID) {
+		return nil
+	}
+
+	if err := r.cacheDAO.DeleteRole(ctx, roleID); err != nil {
+		r.logger.Warn("failed to delete role cache",
+			"role_id", roleID,
+			"error", err,
+		)
+	}
+	return nil
+}
+
+// deferRoleInvalidation 处于工作单元中时，将角色缓存失效延迟到事务提交之后
+// 返回 true 表示已延迟，调用方不应再直接操作缓存
+func (r *roleRepositoryImpl) deferRoleInvalidation(ctx context.Context, roleID int64) bool {
+	scope, ok := scopeFromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	scope.afterCommit(fmt.Sprintf("role:%d", roleID), func(ctx context.Context) {
+		if err := r.cacheDAO.DeleteRole(ctx, roleID); err != nil {
+			r.logger.Warn("failed to delete role cache after commit",
+				"role_id", roleID,
+				"error", err,
+			)
+		}
+	})
+	return true
+}
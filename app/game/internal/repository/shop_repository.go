@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/dao"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// ShopRepository 商店仓储接口
+type ShopRepository interface {
+	// ===== 玩家商店数据 =====
+	GetPlayerShop(ctx context.Context, roleID int64) (*model.PlayerShop, error)
+	SavePlayerShop(ctx context.Context, shop *model.PlayerShop) error
+
+	// ===== 全服库存 =====
+	GetGlobalSold(ctx context.Context, shopItemID int32, periodStart int64) (int32, error)
+	AddGlobalSold(ctx context.Context, shopItemID int32, periodStart int64, count int32, limit int32) (bool, error)
+}
+
+// shopRepositoryImpl 商店仓储实现
+type shopRepositoryImpl struct {
+	shopDAO *dao.ShopDAO
+	logger  logger.Logger
+}
+
+// NewShopRepository 创建商店仓储
+func NewShopRepository(shopDAO *dao.ShopDAO, l logger.Logger) ShopRepository {
+	return &shopRepositoryImpl{
+		shopDAO: shopDAO,
+		logger:  l.Named("repository.shop"),
+	}
+}
+
+// GetPlayerShop 获取玩家商店数据（限购与货架变更频繁且需事务一致，直接查库）
+func (r *shopRepositoryImpl) GetPlayerShop(ctx context.Context, roleID int64) (*model.PlayerShop, error) {
+	return r.shopDAO.GetByRoleID(ctx, roleID)
+}
+
+// SavePlayerShop 保存玩家商店数据
+func (r *shopRepositoryImpl) SavePlayerShop(ctx context.Context, shop *model.PlayerShop) error {
+	return r.shopDAO.Save(ctx, shop)
+}
+
+// GetGlobalSold 获取商品本周期全服已售数量
+func (r *shopRepositoryImpl) GetGlobalSold(ctx context.Context, shopItemID int32, periodStart int64) (int32, error) {
+	return r.shopDAO.GetGlobalSold(ctx, shopItemID, periodStart)
+}
+
+// AddGlobalSold 扣减全服库存，库存不足时返回 false
+func (r *shopRepositoryImpl) AddGlobalSold(ctx context.Context, shopItemID int32, periodStart int64, count int32, limit int32) (bool, error) {
+	return r.shopDAO.AddGlobalSold(ctx, shopItemID, periodStart, count, limit)
+}
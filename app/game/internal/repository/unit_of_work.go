@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/dao"
+	"github.com/lk2023060901/xdooria/pkg/database/postgres"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// UnitOfWork 工作单元
+// 将多次仓储写操作（背包、玩偶、抽卡记录等）合并到同一个 PostgreSQL 事务中，
+// 要么全部生效，要么全部回滚；缓存失效操作延迟到事务提交成功后执行
+type UnitOfWork interface {
+	// Do 在事务中执行 fn，fn 内必须使用传入的 ctx 调用仓储方法
+	// fn 返回错误时事务回滚，已登记的缓存操作全部丢弃
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// unitOfWorkImpl 基于 postgres.Client.WithTx 的工作单元实现
+type unitOfWorkImpl struct {
+	db     *postgres.Client
+	logger logger.Logger
+}
+
+// NewUnitOfWork 创建工作单元
+func NewUnitOfWork(db *postgres.Client, l logger.Logger) UnitOfWork {
+	return &unitOfWorkImpl{
+		db:     db,
+		logger: l.Named("repository.uow"),
+	}
+}
+
+// Do 在事务中执行 fn
+func (u *unitOfWorkImpl) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	// 嵌套调用直接加入外层事务，由最外层负责提交/回滚
+	if _, ok := scopeFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	scope := newUOWScope()
+	err := u.db.WithTx(ctx, func(tx postgres.Tx) error {
+		txCtx := context.WithValue(dao.WithTx(ctx, tx), uowScopeKey{}, scope)
+		return fn(txCtx)
+	})
+	if err != nil {
+		return err
+	}
+
+	// 事务已提交，执行延迟的缓存操作（失败只会导致缓存短暂不一致，由各 hook 自行记录日志）
+	for _, hook := range scope.hooks {
+		hook(ctx)
+	}
+
+	u.logger.Debug("unit of work committed", "hooks", len(scope.hooks))
+	return nil
+}
+
+// uowScopeKey 工作单元作用域在 context 中的 key
+type uowScopeKey struct{}
+
+// uowScope 工作单元作用域，记录提交后需要执行的缓存操作
+type uowScope struct {
+	keys  map[string]struct{}
+	hooks []func(ctx context.Context)
+}
+
+func newUOWScope() *uowScope {
+	return &uowScope{
+		keys: make(map[string]struct{}),
+	}
+}
+
+// afterCommit 登记提交后执行的操作，相同 key 只登记一次
+func (s *uowScope) afterCommit(key string, fn func(ctx context.Context)) {
+	if _, ok := s.keys[key]; ok {
+		return
+	}
+	s.keys[key] = struct{}{}
+	s.hooks = append(s.hooks, fn)
+}
+
+// scopeFromContext 获取当前工作单元作用域
+func scopeFromContext(ctx context.Context) (*uowScope, bool) {
+	scope, ok := ctx.Value(uowScopeKey{}).(*uowScope)
+	return scope, ok
+}
+
+// inUnitOfWork 判断当前是否处于工作单元中
+func inUnitOfWork(ctx context.Context) bool {
+	_, ok := scopeFromContext(ctx)
+	return ok
+}
@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/dao"
+	"github.com/lk2023060901/xdooria/app/game/internal/metrics"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/database/postgres"
+	"github.com/lk2023060901/xdooria/pkg/database/redis"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// 测试配置，与 pkg/database 的集成测试使用同一套本地环境
+var (
+	testPostgresConfig = &postgres.Config{
+		Standalone: &postgres.DBConfig{
+			Host:     "localhost",
+			Port:     25432,
+			User:     "xdooria",
+			Password: "xdooria_pass",
+			DBName:   "xdooria_test",
+			SSLMode:  "disable",
+		},
+		ConnectTimeout: 5 * time.Second,
+		QueryTimeout:   30 * time.Second,
+	}
+
+	testRedisConfig = &redis.Config{
+		Standalone: &redis.NodeConfig{
+			Host: "localhost",
+			Port: 16379,
+		},
+		Pool: redis.PoolConfig{
+			MaxIdleConns: 10,
+			MaxOpenConns: 100,
+			DialTimeout:  5 * time.Second,
+			ReadTimeout:  3 * time.Second,
+			WriteTimeout: 3 * time.Second,
+			PoolTimeout:  5 * time.Second,
+		},
+	}
+)
+
+// testRoleID 测试使用的角色ID，测试前后都会清理该角色的数据
+const testRoleID int64 = 9_000_000_001
+
+// uowTestEnv 基于真实 PostgreSQL/Redis 的工作单元测试环境
+type uowTestEnv struct {
+	db    *postgres.Client
+	uow   UnitOfWork
+	repo  PlayerRepository
+	cache *dao.CacheDAO
+}
+
+func newUOWTestEnv(t *testing.T) *uowTestEnv {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	db, err := postgres.New(testPostgresConfig)
+	if err != nil {
+		t.Skipf("postgres not available: %v", err)
+	}
+	t.Cleanup(db.Close)
+	if err := db.Ping(ctx); err != nil {
+		t.Skipf("postgres not available: %v", err)
+	}
+
+	rdb, err := redis.NewClient(testRedisConfig)
+	if err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+	t.Cleanup(func() { rdb.Close() })
+	if err := rdb.Ping(ctx); err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+
+	for _, file := range []string{"player_gacha.sql", "player_bags.sql"} {
+		ddl, err := os.ReadFile("../../../../schema/" + file)
+		if err != nil {
+			t.Fatalf("read schema %s: %v", file, err)
+		}
+		if _, err := db.Exec(ctx, string(ddl)); err != nil {
+			t.Fatalf("apply schema %s: %v", file, err)
+		}
+	}
+
+	m, err := metrics.New(metrics.DefaultConfig())
+	if err != nil {
+		t.Fatalf("create metrics: %v", err)
+	}
+
+	l := logger.Default()
+	cache := dao.NewCacheDAO(rdb, l, m)
+	env := &uowTestEnv{
+		db:    db,
+		uow:   NewUnitOfWork(db, l),
+		repo:  NewPlayerRepository(dao.NewDollDAO(db, l, m), dao.NewGachaDAO(db, l, m), dao.NewBagDAO(db, l, m), cache, l),
+		cache: cache,
+	}
+	env.cleanup(t)
+	t.Cleanup(func() { env.cleanup(t) })
+	return env
+}
+
+func (e *uowTestEnv) cleanup(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := e.db.Exec(ctx, "DELETE FROM player_gacha WHERE role_id = $1", testRoleID); err != nil {
+		t.Fatalf("cleanup player_gacha: %v", err)
+	}
+	if _, err := e.db.Exec(ctx, "DELETE FROM player_bags WHERE role_id = $1", testRoleID); err != nil {
+		t.Fatalf("cleanup player_bags: %v", err)
+	}
+	if err := e.cache.DeleteDolls(ctx, testRoleID); err != nil {
+		t.Fatalf("cleanup dolls cache: %v", err)
+	}
+}
+
+// cachedDolls 返回玩偶缓存，未命中时为 nil
+func (e *uowTestEnv) cachedDolls(t *testing.T) []*model.Doll {
+	t.Helper()
+	dolls, err := e.cache.GetDolls(context.Background(), testRoleID)
+	if err != nil {
+		t.Fatalf("get dolls cache: %v", err)
+	}
+	return dolls
+}
+
+// TestUnitOfWork_ConcurrentUpdates 并发事务读改写同一角色的保底计数和玩偶列表，不应丢失更新
+func TestUnitOfWork_ConcurrentUpdates(t *testing.T) {
+	env := newUOWTestEnv(t)
+	ctx := context.Background()
+
+	const workers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- env.uow.Do(ctx, func(ctx context.Context) error {
+				gacha, err := env.repo.GetGachaRecords(ctx, testRoleID)
+				if err != nil {
+					return err
+				}
+				if len(gacha.Records) == 0 {
+					gacha.Records = append(gacha.Records, &model.GachaRecord{ID: 1, Type: 1})
+				}
+				gacha.Records[0].TotalCount++
+				if err := env.repo.SaveGachaRecords(ctx, gacha); err != nil {
+					return err
+				}
+				return env.repo.AddDoll(ctx, &model.Doll{ID: int64(i + 1), PlayerID: testRoleID, DollID: 1})
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("uow.Do: %v", err)
+		}
+	}
+
+	gacha, err := env.repo.GetGachaRecords(ctx, testRoleID)
+	if err != nil {
+		t.Fatalf("GetGachaRecords: %v", err)
+	}
+	if len(gacha.Records) != 1 || gacha.Records[0].TotalCount != workers {
+		t.Errorf("gacha records = %+v, want one record with total_count %d", gacha.Records, workers)
+	}
+
+	dolls, err := env.repo.GetDolls(ctx, testRoleID)
+	if err != nil {
+		t.Fatalf("GetDolls: %v", err)
+	}
+	if len(dolls) != workers {
+		t.Errorf("dolls = %d, want %d", len(dolls), workers)
+	}
+}
+
+// TestUnitOfWork_CacheInvalidation 玩偶缓存只在事务提交后删除，回滚时保留
+func TestUnitOfWork_CacheInvalidation(t *testing.T) {
+	env := newUOWTestEnv(t)
+	ctx := context.Background()
+
+	// 事务外读取，回写缓存
+	if _, err := env.repo.GetDolls(ctx, testRoleID); err != nil {
+		t.Fatalf("GetDolls: %v", err)
+	}
+	if env.cachedDolls(t) == nil {
+		t.Fatal("dolls cache not populated")
+	}
+
+	// 回滚：缓存保留，数据库未变化
+	errRollback := errors.New("rollback")
+	err := env.uow.Do(ctx, func(ctx context.Context) error {
+		if err := env.repo.AddDoll(ctx, &model.Doll{ID: 1, PlayerID: testRoleID, DollID: 1}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("uow.Do = %v, want %v", err, errRollback)
+	}
+	if env.cachedDolls(t) == nil {
+		t.Error("dolls cache deleted after rollback")
+	}
+
+	// 提交：事务内缓存仍在，提交后被删除
+	err = env.uow.Do(ctx, func(ctx context.Context) error {
+		if err := env.repo.AddDoll(ctx, &model.Doll{ID: 2, PlayerID: testRoleID, DollID: 1}); err != nil {
+			return err
+		}
+		if env.cachedDolls(t) == nil {
+			t.Error("dolls cache deleted before commit")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("uow.Do: %v", err)
+	}
+	if dolls := env.cachedDolls(t); dolls != nil {
+		t.Errorf("dolls cache = %d dolls after commit, want deleted", len(dolls))
+	}
+
+	dolls, err := env.repo.GetDolls(ctx, testRoleID)
+	if err != nil {
+		t.Fatalf("GetDolls: %v", err)
+	}
+	if len(dolls) != 1 || dolls[0].ID != 2 {
+		t.Errorf("dolls = %+v, want only the committed doll", dolls)
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/manager"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// BagExpiryJobName 过期物品清理任务名称
+const BagExpiryJobName = "bag.expire_sweep"
+
+// onlineRoleLister 在线角色列表（由 manager.RoleManager 实现）
+type onlineRoleLister interface {
+	GetAllOnlineRoleIDs() []int64
+}
+
+// BagExpiryJob 定时清理在线玩家背包中的过期物品（实现 scheduler.Job）
+// 离线玩家在下次加载背包时清理
+type BagExpiryJob struct {
+	logger logger.Logger
+	bagSvc *BagService
+	roles  onlineRoleLister
+}
+
+// NewBagExpiryJob 创建过期物品清理任务
+func NewBagExpiryJob(l logger.Logger, bagSvc *BagService, roleMgr *manager.RoleManager) *BagExpiryJob {
+	return newBagExpiryJob(l, bagSvc, roleMgr)
+}
+
+func newBagExpiryJob(l logger.Logger, bagSvc *BagService, roles onlineRoleLister) *BagExpiryJob {
+	return &BagExpiryJob{
+		logger: l.Named("job.bag_expiry"),
+		bagSvc: bagSvc,
+		roles:  roles,
+	}
+}
+
+// Name 任务名称
+func (j *BagExpiryJob) Name() string {
+	return BagExpiryJobName
+}
+
+// Run 逐个清理在线玩家的过期物品，单个玩家失败不影响其他玩家
+func (j *BagExpiryJob) Run() error {
+	ctx := context.Background()
+
+	var errs []error
+	swept := 0
+	for _, roleID := range j.roles.GetAllOnlineRoleIDs() {
+		removed, err := j.bagSvc.SweepExpiredItems(ctx, roleID)
+		if err != nil {
+			j.logger.Error("failed to sweep expired items", "role_id", roleID, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		swept += len(removed)
+	}
+
+	if swept > 0 {
+		j.logger.Info("expired items swept", "count", swept)
+	}
+	return errors.Join(errs...)
+}
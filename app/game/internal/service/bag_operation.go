@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+)
+
+// checkBagType 校验背包类型
+func checkBagType(bagType int32) error {
+	if !slices.Contains(bagTypes, bagType) {
+		return fmt.Errorf("%w: unknown bag type %d", ErrBagInvalidOperation, bagType)
+	}
+	return nil
+}
+
+// modifyBag 在事务内加载背包（同时清理过期物品）、执行修改并保存，返回修改后的背包快照
+func (s *BagService) modifyBag(ctx context.Context, roleID int64, bagType int32, fn func(ctx context.Context, bag *model.BaseBag) error) (*model.PlayerBag, error) {
+	if err := checkBagType(bagType); err != nil {
+		return nil, err
+	}
+
+	var result *model.PlayerBag
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		bag, _, err := s.loadBag(ctx, roleID, bagType, s.now().Unix())
+		if err != nil {
+			return err
+		}
+		if err := fn(ctx, bag); err != nil {
+			return err
+		}
+
+		result = bag.ToStorage()
+		return s.playerRepo.SaveBag(ctx, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// invalidBagOperation 将背包模型返回的错误包装为 ErrBagInvalidOperation
+func invalidBagOperation(err error) error {
+	return fmt.Errorf("%w: %v", ErrBagInvalidOperation, err)
+}
+
+// GetBags 获取玩家背包快照，bagType 为 0 时返回全部背包
+// 过期物品不会出现在结果中
+func (s *BagService) GetBags(ctx context.Context, roleID int64, bagType int32) ([]*model.PlayerBag, error) {
+	types := bagTypes
+	if bagType != 0 {
+		if err := checkBagType(bagType); err != nil {
+			return nil, err
+		}
+		types = []int32{bagType}
+	}
+
+	now := s.now().Unix()
+	bags := make([]*model.PlayerBag, 0, len(types))
+	for _, typ := range types {
+		bag, _, err := s.loadBag(ctx, roleID, typ, now)
+		if err != nil {
+			return nil, err
+		}
+		bags = append(bags, bag.ToStorage())
+	}
+	return bags, nil
+}
+
+// SortBag 整理背包：先合并可堆叠的物品（按最大堆叠数量拆分），再按 sortType (gameconfig.BagSortType_*) 排序并消除空隙
+// 收藏物品始终排在最前
+func (s *BagService) SortBag(ctx context.Context, roleID int64, bagType, sortType int32, ascending bool) (*model.PlayerBag, error) {
+	return s.modifyBag(ctx, roleID, bagType, func(_ context.Context, bag *model.BaseBag) error {
+		if _, err := bag.Compact(); err != nil {
+			return invalidBagOperation(err)
+		}
+		if err := bag.Sort(sortType, ascending); err != nil {
+			return invalidBagOperation(err)
+		}
+		return nil
+	})
+}
+
+// MergeStack 将 fromSlot 的物品堆叠到 toSlot，超出最大堆叠数量的部分保留在 fromSlot
+// 只有配置ID、绑定状态与过期时间都相同的物品才可合并
+func (s *BagService) MergeStack(ctx context.Context, roleID int64, bagType, fromSlot, toSlot int32) (*model.PlayerBag, error) {
+	return s.modifyBag(ctx, roleID, bagType, func(_ context.Context, bag *model.BaseBag) error {
+		if err := bag.Stack(fromSlot, toSlot); err != nil {
+			return invalidBagOperation(err)
+		}
+		return nil
+	})
+}
+
+// SplitStack 从 slot 拆出 count 个物品放入第一个空槽位，返回新槽位索引
+func (s *BagService) SplitStack(ctx context.Context, roleID int64, bagType, slot, count int32) (int32, *model.PlayerBag, error) {
+	newSlot := int32(-1)
+	bag, err := s.modifyBag(ctx, roleID, bagType, func(_ context.Context, bag *model.BaseBag) error {
+		if bag.HasItem(slot) && bag.IsFull() {
+			return ErrBagFull
+		}
+		slotIndex, err := bag.Split(slot, count)
+		if err != nil {
+			return invalidBagOperation(err)
+		}
+		newSlot = slotIndex
+		return nil
+	})
+	if err != nil {
+		return -1, nil, err
+	}
+	return newSlot, bag, nil
+}
+
+// MoveItem 将 fromSlot 的物品移动到 toSlot，目标槽位有物品时两者交换位置
+func (s *BagService) MoveItem(ctx context.Context, roleID int64, bagType, fromSlot, toSlot int32) (*model.PlayerBag, error) {
+	return s.modifyBag(ctx, roleID, bagType, func(_ context.Context, bag *model.BaseBag) error {
+		if !bag.HasItem(fromSlot) {
+			return fmt.Errorf("%w: slot %d is empty", ErrBagInvalidOperation, fromSlot)
+		}
+
+		var err error
+		if bag.HasItem(toSlot) {
+			err = bag.Swap(fromSlot, toSlot)
+		} else {
+			err = bag.Move(fromSlot, toSlot)
+		}
+		if err != nil {
+			return invalidBagOperation(err)
+		}
+		return nil
+	})
+}
+
+// ExpandBag 扩容背包 count 格，不能超过配置的最大容量
+// 配置了扩容消耗时，按格数扣除道具，扣除与扩容在同一事务内完成
+func (s *BagService) ExpandBag(ctx context.Context, roleID int64, bagType, count int32) (*model.PlayerBag, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("%w: invalid expand count %d", ErrBagInvalidOperation, count)
+	}
+
+	return s.modifyBag(ctx, roleID, bagType, func(ctx context.Context, bag *model.BaseBag) error {
+		// 用减法比较，避免 capacity + count 溢出 int32
+		maxCapacity := s.cfg.MaxCapacity[bagType]
+		if count > maxCapacity-bag.GetCapacity() {
+			return fmt.Errorf("%w: capacity %d + %d exceeds %d", ErrBagCapacityLimit, bag.GetCapacity(), count, maxCapacity)
+		}
+
+		if s.cfg.ExpandCostItem != 0 && s.cfg.ExpandCostCount > 0 {
+			// 消耗按 int64 计算，超出 int32 的数量不可能持有
+			cost := int64(s.cfg.ExpandCostCount) * int64(count)
+			if cost > math.MaxInt32 {
+				return fmt.Errorf("%w %d: need %d", ErrBagInsufficientItem, s.cfg.ExpandCostItem, cost)
+			}
+			costBag, err := s.costBag(ctx, roleID, bag)
+			if err != nil {
+				return err
+			}
+			if err := costBag.RemoveItemByConfigID(s.cfg.ExpandCostItem, int32(cost)); err != nil {
+				return fmt.Errorf("%w %d: %v", ErrBagInsufficientItem, s.cfg.ExpandCostItem, err)
+			}
+			if costBag != bag {
+				if err := s.playerRepo.SaveBag(ctx, costBag.ToStorage()); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := bag.Expand(count); err != nil {
+			return invalidBagOperation(err)
+		}
+		return nil
+	})
+}
+
+// costBag 获取扩容消耗道具所在的背包，与被扩容背包相同时直接复用，避免覆盖未保存的修改
+func (s *BagService) costBag(ctx context.Context, roleID int64, bag *model.BaseBag) (*model.BaseBag, error) {
+	cfg := gameconfig.T.TbItem.Get(s.cfg.ExpandCostItem)
+	if cfg == nil {
+		return nil, fmt.Errorf("item config %d not found", s.cfg.ExpandCostItem)
+	}
+	if cfg.Type == bag.GetType() {
+		return bag, nil
+	}
+
+	costBag, _, err := s.loadBag(ctx, roleID, cfg.Type, s.now().Unix())
+	return costBag, err
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+)
+
+// setBagSlots 直接设置道具背包的槽位内容
+func setBagSlots(repo *fakePlayerRepo, items ...model.Item) {
+	for i := range items {
+		items[i].RoleID = testRoleID
+	}
+	repo.state.bags[gameconfig.BagType_Item] = items
+}
+
+// bagSlots 返回背包快照的槽位内容，格式 slot:itemID:count
+func bagSlots(bag *model.PlayerBag) []string {
+	out := make([]string, 0, len(bag.Items))
+	for _, item := range bag.Items {
+		out = append(out, fmt.Sprintf("%d:%d:%d", item.SlotIndex, item.ConfigID, item.Count))
+	}
+	return out
+}
+
+// TestBagSort 测试整理背包合并零散堆叠、按最大堆叠数量拆分并消除空隙
+func TestBagSort(t *testing.T) {
+	svc, repo, _ := newTestBagService(t)
+	setBagSlots(repo,
+		model.Item{ConfigID: testSmallStackID, SlotIndex: 7, Count: 6},
+		model.Item{ConfigID: testCostItemID, SlotIndex: 4, Count: 1},
+		model.Item{ConfigID: testSmallStackID, SlotIndex: 2, Count: 7},
+		model.Item{ConfigID: testCostItemID, SlotIndex: 9, Count: 2, IsFavorite: true},
+		model.Item{ConfigID: testCostItemID, SlotIndex: 5, Count: 3, IsBound: true},
+	)
+
+	bag, err := svc.SortBag(context.Background(), testRoleID, gameconfig.BagType_Item, gameconfig.BagSortType_Id, true)
+	if err != nil {
+		t.Fatalf("SortBag() error = %v", err)
+	}
+
+	// 收藏的 1001 排在最前；绑定的 1001 与未绑定的不合并；3004 合并为 10 + 3
+	got := bagSlots(bag)
+	if len(got) != 4 || got[0] != "0:1001:3" {
+		t.Fatalf("SortBag() = %v, want 4 slots with the merged favorite stack first", got)
+	}
+	if want := []string{"2:3004:10", "3:3004:3"}; !reflect.DeepEqual(got[2:], want) {
+		t.Errorf("SortBag() = %v, want small stacks %v", got, want)
+	}
+	if got := bagItems(repo, gameconfig.BagType_Item); !reflect.DeepEqual(got, []string{"1001:6", "3004:13"}) {
+		t.Errorf("saved bag = %v, want counts preserved", got)
+	}
+
+	if _, err := svc.SortBag(context.Background(), testRoleID, gameconfig.BagType_Item, 99, true); !errors.Is(err, ErrBagInvalidOperation) {
+		t.Errorf("SortBag() with invalid sort type error = %v, want ErrBagInvalidOperation", err)
+	}
+}
+
+// TestBagMergeAndSplit 测试合并堆叠不超过最大堆叠数量，拆分继承绑定与过期时间
+func TestBagMergeAndSplit(t *testing.T) {
+	svc, repo, _ := newTestBagService(t)
+	ctx := context.Background()
+	setBagSlots(repo,
+		model.Item{ConfigID: testSmallStackID, SlotIndex: 0, Count: 8, IsBound: true, ExpireTime: 1e10},
+		model.Item{ConfigID: testSmallStackID, SlotIndex: 1, Count: 5, IsBound: true, ExpireTime: 1e10},
+		model.Item{ConfigID: testSmallStackID, SlotIndex: 2, Count: 5},
+	)
+
+	bag, err := svc.MergeStack(ctx, testRoleID, gameconfig.BagType_Item, 1, 0)
+	if err != nil {
+		t.Fatalf("MergeStack() error = %v", err)
+	}
+	if got, want := bagSlots(bag), []string{"0:3004:10", "1:3004:3", "2:3004:5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeStack() = %v, want %v", got, want)
+	}
+
+	// 绑定状态不同不可合并
+	if _, err := svc.MergeStack(ctx, testRoleID, gameconfig.BagType_Item, 2, 1); !errors.Is(err, ErrBagInvalidOperation) {
+		t.Errorf("MergeStack() of different binding error = %v, want ErrBagInvalidOperation", err)
+	}
+	if _, err := svc.MergeStack(ctx, testRoleID, gameconfig.BagType_Item, 1, 1); !errors.Is(err, ErrBagInvalidOperation) {
+		t.Errorf("MergeStack() onto itself error = %v, want ErrBagInvalidOperation", err)
+	}
+
+	newSlot, bag, err := svc.SplitStack(ctx, testRoleID, gameconfig.BagType_Item, 0, 4)
+	if err != nil {
+		t.Fatalf("SplitStack() error = %v", err)
+	}
+	if newSlot != 3 {
+		t.Errorf("SplitStack() new slot = %d, want 3", newSlot)
+	}
+	split := bag.Items[3]
+	if split.Count != 4 || !split.IsBound || split.ExpireTime != 1e10 || bag.Items[0].Count != 6 {
+		t.Errorf("SplitStack() = %+v from %+v, want 4 bound items split from 10", split, bag.Items[0])
+	}
+
+	for _, count := range []int32{0, 6} {
+		if _, _, err := svc.SplitStack(ctx, testRoleID, gameconfig.BagType_Item, 0, count); !errors.Is(err, ErrBagInvalidOperation) {
+			t.Errorf("SplitStack(count=%d) error = %v, want ErrBagInvalidOperation", count, err)
+		}
+	}
+}
+
+// TestBagSplit_Full 测试背包已满时不能拆分
+func TestBagSplit_Full(t *testing.T) {
+	svc, repo, _ := newTestBagService(t)
+	svc.cfg = &BagConfig{Capacity: map[int32]int32{gameconfig.BagType_Item: 2}}
+	setBagSlots(repo,
+		model.Item{ConfigID: testSmallStackID, SlotIndex: 0, Count: 8},
+		model.Item{ConfigID: testCostItemID, SlotIndex: 1, Count: 1},
+	)
+
+	if _, _, err := svc.SplitStack(context.Background(), testRoleID, gameconfig.BagType_Item, 0, 4); !errors.Is(err, ErrBagFull) {
+		t.Errorf("SplitStack() error = %v, want ErrBagFull", err)
+	}
+}
+
+// TestBagMoveItem 测试移动到空槽位与交换两个槽位
+func TestBagMoveItem(t *testing.T) {
+	svc, repo, _ := newTestBagService(t)
+	ctx := context.Background()
+	setBagSlots(repo,
+		model.Item{ConfigID: testCostItemID, SlotIndex: 0, Count: 1},
+		model.Item{ConfigID: testRewardItemID, SlotIndex: 1, Count: 2},
+	)
+
+	bag, err := svc.MoveItem(ctx, testRoleID, gameconfig.BagType_Item, 0, 5)
+	if err != nil {
+		t.Fatalf("MoveItem() error = %v", err)
+	}
+	if got, want := bagSlots(bag), []string{"1:1002:2", "5:1001:1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MoveItem() to empty slot = %v, want %v", got, want)
+	}
+
+	bag, err = svc.MoveItem(ctx, testRoleID, gameconfig.BagType_Item, 1, 5)
+	if err != nil {
+		t.Fatalf("MoveItem() error = %v", err)
+	}
+	if got, want := bagSlots(bag), []string{"1:1001:1", "5:1002:2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MoveItem() to occupied slot = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name     string
+		bagType  int32
+		from, to int32
+	}{
+		{"empty source", gameconfig.BagType_Item, 0, 1},
+		{"out of range", gameconfig.BagType_Item, 1, defaultBagCapacity},
+		{"unknown bag type", 99, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.MoveItem(ctx, testRoleID, tt.bagType, tt.from, tt.to); !errors.Is(err, ErrBagInvalidOperation) {
+				t.Errorf("MoveItem() error = %v, want ErrBagInvalidOperation", err)
+			}
+		})
+	}
+}
+
+// TestBagExpand 测试扩容扣除道具、不超过最大容量，失败时不扣费
+func TestBagExpand(t *testing.T) {
+	svc, repo, _ := newTestBagService(t)
+	ctx := context.Background()
+	svc.cfg = &BagConfig{
+		Capacity:        map[int32]int32{gameconfig.BagType_Item: 10, gameconfig.BagType_Costume: 10},
+		MaxCapacity:     map[int32]int32{gameconfig.BagType_Item: 15, gameconfig.BagType_Costume: 15},
+		ExpandCostItem:  testCostItemID,
+		ExpandCostCount: 2,
+	}
+	setBagCount(repo, gameconfig.BagType_Item, testCostItemID, 9)
+
+	// 消耗道具与被扩容背包相同
+	bag, err := svc.ExpandBag(ctx, testRoleID, gameconfig.BagType_Item, 3)
+	if err != nil {
+		t.Fatalf("ExpandBag() error = %v", err)
+	}
+	if bag.Capacity != 13 {
+		t.Errorf("ExpandBag() capacity = %d, want 13", bag.Capacity)
+	}
+	if got := bagItems(repo, gameconfig.BagType_Item); !reflect.DeepEqual(got, []string{"1001:3"}) {
+		t.Errorf("bag after expand = %v, want 6 cost items consumed", got)
+	}
+
+	// 消耗道具在其他背包
+	bag, err = svc.ExpandBag(ctx, testRoleID, gameconfig.BagType_Costume, 1)
+	if err != nil {
+		t.Fatalf("ExpandBag(costume) error = %v", err)
+	}
+	if bag.Capacity != 11 {
+		t.Errorf("ExpandBag(costume) capacity = %d, want 11", bag.Capacity)
+	}
+	if got := bagItems(repo, gameconfig.BagType_Item); !reflect.DeepEqual(got, []string{"1001:1"}) {
+		t.Errorf("item bag after costume expand = %v, want 2 cost items consumed", got)
+	}
+
+	tests := []struct {
+		name    string
+		bagType int32
+		count   int32
+		wantErr error
+	}{
+		{"insufficient cost", gameconfig.BagType_Item, 1, ErrBagInsufficientItem},
+		{"exceeds max capacity", gameconfig.BagType_Item, 3, ErrBagCapacityLimit},
+		{"capacity overflow", gameconfig.BagType_Item, math.MaxInt32, ErrBagCapacityLimit},
+		{"not expandable", gameconfig.BagType_Equipment, 1, ErrBagCapacityLimit},
+		{"invalid count", gameconfig.BagType_Item, 0, ErrBagInvalidOperation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := repo.state.clone()
+			if _, err := svc.ExpandBag(ctx, testRoleID, tt.bagType, tt.count); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ExpandBag() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(repo.state, before) {
+				t.Error("ExpandBag() failure changed bag state")
+			}
+		})
+	}
+
+	// 单格消耗乘以扩容数量超出 int32
+	svc.cfg.MaxCapacity[gameconfig.BagType_Item] = math.MaxInt32
+	svc.cfg.ExpandCostCount = math.MaxInt32
+	before := repo.state.clone()
+	if _, err := svc.ExpandBag(ctx, testRoleID, gameconfig.BagType_Item, 2); !errors.Is(err, ErrBagInsufficientItem) {
+		t.Errorf("ExpandBag(cost overflow) error = %v, want ErrBagInsufficientItem", err)
+	}
+	if !reflect.DeepEqual(repo.state, before) {
+		t.Error("ExpandBag(cost overflow) changed bag state")
+	}
+}
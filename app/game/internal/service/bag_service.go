@@ -2,27 +2,105 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
 	"github.com/lk2023060901/xdooria/app/game/internal/repository"
 	"github.com/lk2023060901/xdooria/pkg/logger"
 )
 
+// 背包业务错误，Handler 据此映射错误码
+var (
+	ErrBagInvalidOperation = errors.New("invalid bag operation")
+	ErrBagFull             = model.ErrBagFull
+	ErrBagCapacityLimit    = errors.New("bag capacity limit reached")
+	ErrBagInsufficientItem = errors.New("insufficient item")
+)
+
+// defaultBagCapacity 未配置时的背包默认容量
+const defaultBagCapacity = 200
+
+// bagTypes 玩家拥有的背包类型
+var bagTypes = []int32{
+	gameconfig.BagType_Costume,
+	gameconfig.BagType_Item,
+	gameconfig.BagType_Equipment,
+}
+
+// BagConfig 背包配置
+type BagConfig struct {
+	// Capacity 各背包类型 (gameconfig.BagType_*) 的初始容量，未配置时使用 defaultBagCapacity
+	Capacity map[int32]int32 `mapstructure:"capacity"`
+
+	// MaxCapacity 各背包类型可扩容到的最大容量，未配置的背包类型不可扩容
+	MaxCapacity map[int32]int32 `mapstructure:"max_capacity"`
+
+	// ExpandCostItem 扩容消耗的道具ID，0 表示扩容免费
+	ExpandCostItem int32 `mapstructure:"expand_cost_item"`
+
+	// ExpandCostCount 每扩容一格消耗的道具数量
+	ExpandCostCount int32 `mapstructure:"expand_cost_count"`
+
+	// SweepSpec 过期物品清理任务的 Cron 表达式，为空时不启用定时清理（加载背包时仍会清理）
+	SweepSpec string `mapstructure:"sweep_spec"`
+}
+
 // BagService 背包服务
 type BagService struct {
 	logger     logger.Logger
+	cfg        *BagConfig
 	playerRepo repository.PlayerRepository
+	uow        repository.UnitOfWork
+	now        func() time.Time
 }
 
-func NewBagService(l logger.Logger, playerRepo repository.PlayerRepository) *BagService {
+func NewBagService(l logger.Logger, cfg *BagConfig, playerRepo repository.PlayerRepository, uow repository.UnitOfWork) *BagService {
+	if cfg == nil {
+		cfg = &BagConfig{}
+	}
+
 	return &BagService{
 		logger:     l.Named("service.bag"),
+		cfg:        cfg,
 		playerRepo: playerRepo,
+		uow:        uow,
+		now:        time.Now,
+	}
+}
+
+// capacity 获取背包类型的初始容量
+func (s *BagService) capacity(bagType int32) int32 {
+	if capacity := s.cfg.Capacity[bagType]; capacity > 0 {
+		return capacity
+	}
+	return defaultBagCapacity
+}
+
+// loadBag 加载背包并移除已过期物品，返回背包与被移除的过期物品
+func (s *BagService) loadBag(ctx context.Context, roleID int64, bagType int32, now int64) (*model.BaseBag, []*model.Item, error) {
+	stored, err := s.playerRepo.GetBag(ctx, roleID, bagType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bag := stored.ToBaseBag(s.capacity(bagType))
+	expired := bag.RemoveExpiredItems(now)
+	for _, item := range expired {
+		s.logger.Info("item expired",
+			"role_id", roleID,
+			"item_id", item.ConfigID,
+			"count", item.Count,
+			"expire_time", item.ExpireTime,
+		)
 	}
+	return bag, expired, nil
 }
 
 // AddItem 添加道具
+// 按道具配置的过期类型设置过期时间，拾取绑定的道具获得即绑定
 func (s *BagService) AddItem(ctx context.Context, roleID int64, itemID int32, count int32) error {
 	if count <= 0 {
 		return nil
@@ -34,20 +112,23 @@ func (s *BagService) AddItem(ctx context.Context, roleID int64, itemID int32, co
 		return fmt.Errorf("item config %d not found", itemID)
 	}
 
-	// 2. 加载对应背包
-	bag, err := s.playerRepo.GetBag(ctx, roleID, cfg.Type)
+	// 2. 加载对应背包（同时清理过期物品）
+	now := s.now().Unix()
+	bag, _, err := s.loadBag(ctx, roleID, cfg.Type, now)
 	if err != nil {
 		return err
 	}
 
-	// 3. 更新数量
-	bag.Items[itemID] += count
+	// 3. 放入背包
+	if err := bag.AddItemByConfigID(itemID, count, now); err != nil {
+		return fmt.Errorf("failed to add item %d: %w", itemID, err)
+	}
 
 	// 4. 保存
-	return s.playerRepo.SaveBag(ctx, bag)
+	return s.playerRepo.SaveBag(ctx, bag.ToStorage())
 }
 
-// ConsumeItem 消耗道具
+// ConsumeItem 消耗道具（过期物品不计入，优先消耗最早过期的）
 func (s *BagService) ConsumeItem(ctx context.Context, roleID int64, itemID int32, count int32) error {
 	if count <= 0 {
 		return nil
@@ -58,22 +139,81 @@ func (s *BagService) ConsumeItem(ctx context.Context, roleID int64, itemID int32
 		return fmt.Errorf("item config %d not found", itemID)
 	}
 
-	bag, err := s.playerRepo.GetBag(ctx, roleID, cfg.Type)
+	bag, _, err := s.loadBag(ctx, roleID, cfg.Type, s.now().Unix())
 	if err != nil {
 		return err
 	}
 
 	// 检查余额
-	current := bag.Items[itemID]
+	current := bag.GetItemCountByConfigID(itemID)
 	if current < count {
-		return fmt.Errorf("insufficient item %d: have %d, need %d", itemID, current, count)
+		return fmt.Errorf("%w %d: have %d, need %d", ErrBagInsufficientItem, itemID, current, count)
 	}
 
 	// 更新并保存
-	bag.Items[itemID] -= count
-	if bag.Items[itemID] == 0 {
-		delete(bag.Items, itemID)
+	if err := bag.RemoveItemByConfigID(itemID, count); err != nil {
+		return err
+	}
+
+	return s.playerRepo.SaveBag(ctx, bag.ToStorage())
+}
+
+// GetItemCount 获取道具数量（不含已过期物品）
+func (s *BagService) GetItemCount(ctx context.Context, roleID int64, itemID int32) (int32, error) {
+	cfg := gameconfig.T.TbItem.Get(itemID)
+	if cfg == nil {
+		return 0, fmt.Errorf("item config %d not found", itemID)
+	}
+
+	bag, _, err := s.loadBag(ctx, roleID, cfg.Type, s.now().Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return bag.GetItemCountByConfigID(itemID), nil
+}
+
+// GetTradableCount 获取道具可交易数量（不含已绑定、已过期物品，配置不可交易的道具恒为 0）
+// 交易、邮寄等功能转移道具前应以此校验，拒绝转移绑定物品
+func (s *BagService) GetTradableCount(ctx context.Context, roleID int64, itemID int32) (int32, error) {
+	cfg := gameconfig.T.TbItem.Get(itemID)
+	if cfg == nil {
+		return 0, fmt.Errorf("item config %d not found", itemID)
+	}
+
+	bag, _, err := s.loadBag(ctx, roleID, cfg.Type, s.now().Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return bag.GetTradableCountByConfigID(itemID), nil
+}
+
+// SweepExpiredItems 清理玩家所有背包中的过期物品并保存，返回被移除的物品
+func (s *BagService) SweepExpiredItems(ctx context.Context, roleID int64) ([]*model.Item, error) {
+	now := s.now().Unix()
+
+	var removed []*model.Item
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		removed = nil
+		for _, bagType := range bagTypes {
+			bag, expired, err := s.loadBag(ctx, roleID, bagType, now)
+			if err != nil {
+				return err
+			}
+			if len(expired) == 0 {
+				continue
+			}
+			if err := s.playerRepo.SaveBag(ctx, bag.ToStorage()); err != nil {
+				return err
+			}
+			removed = append(removed, expired...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sweep expired items of role %d: %w", roleID, err)
 	}
 
-	return s.playerRepo.SaveBag(ctx, bag)
+	return removed, nil
 }
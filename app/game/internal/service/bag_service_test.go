@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// 背包测试用道具
+const (
+	testTimedItemID    = 3001 // 限时 1 小时，拾取绑定
+	testDatedItemID    = 3002 // 固定日期过期，可交易
+	testNoTradeItemID  = 3003 // 永久，配置不可交易
+	testSmallStackID   = 3004 // 最大堆叠 10
+	testTimedItemHours = 1
+)
+
+var (
+	testBagNow        = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	testDatedItemTime = testBagNow.Add(24 * time.Hour)
+)
+
+// fakeOnlineRoles 固定的在线角色列表
+type fakeOnlineRoles []int64
+
+func (r fakeOnlineRoles) GetAllOnlineRoleIDs() []int64 { return r }
+
+// setupBagConfig 在基础测试配置上增加限时、绑定、不可交易道具
+func setupBagConfig(t *testing.T) {
+	t.Helper()
+	setupTestConfig(t)
+
+	timed := testItemConfig(testTimedItemID, gameconfig.ItemType_Item)
+	timed["expire_type"] = float64(gameconfig.ExpireType_Duration)
+	timed["expire_time"] = float64(testTimedItemHours * 3600)
+	timed["bind_type"] = float64(gameconfig.BindType_PickupBind)
+
+	dated := testItemConfig(testDatedItemID, gameconfig.ItemType_Item)
+	dated["expire_type"] = float64(gameconfig.ExpireType_FixedDate)
+	dated["expire_time"] = float64(testDatedItemTime.Unix())
+
+	noTrade := testItemConfig(testNoTradeItemID, gameconfig.ItemType_Item)
+	noTrade["can_trade"] = false
+
+	smallStack := testItemConfig(testSmallStackID, gameconfig.ItemType_Item)
+	smallStack["max_stack"] = float64(10)
+
+	items, err := gameconfig.NewTbItem([]map[string]interface{}{
+		testItemConfig(testCostItemID, gameconfig.ItemType_Item),
+		testItemConfig(testRewardItemID, gameconfig.ItemType_Item),
+		timed, dated, noTrade, smallStack,
+	})
+	if err != nil {
+		t.Fatalf("failed to build item config: %v", err)
+	}
+	gameconfig.T.TbItem = items
+}
+
+func newTestBagService(t *testing.T) (*BagService, *fakePlayerRepo, *testClock) {
+	t.Helper()
+	setupBagConfig(t)
+
+	repo := newFakePlayerRepo()
+	svc := NewBagService(logger.Noop(), &BagConfig{}, repo, &fakeUnitOfWork{repo: repo})
+	clock := &testClock{now: testBagNow}
+	svc.now = clock.Now
+	return svc, repo, clock
+}
+
+// findSlots 返回背包中指定道具的所有槽位
+func findSlots(repo *fakePlayerRepo, itemID int32) []model.Item {
+	var out []model.Item
+	for _, item := range repo.state.bags[gameconfig.BagType_Item] {
+		if item.ConfigID == itemID {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// TestBagAddItem_StampsExpiryAndBinding 测试获得道具时按配置设置过期时间与绑定状态
+func TestBagAddItem_StampsExpiryAndBinding(t *testing.T) {
+	svc, repo, _ := newTestBagService(t)
+	ctx := context.Background()
+
+	for _, itemID := range []int32{testTimedItemID, testDatedItemID, testCostItemID} {
+		if err := svc.AddItem(ctx, testRoleID, itemID, 2); err != nil {
+			t.Fatalf("AddItem(%d) error = %v", itemID, err)
+		}
+	}
+
+	tests := []struct {
+		itemID     int32
+		expireTime int64
+		bound      bool
+	}{
+		{testTimedItemID, testBagNow.Add(testTimedItemHours * time.Hour).Unix(), true},
+		{testDatedItemID, testDatedItemTime.Unix(), false},
+		{testCostItemID, 0, false},
+	}
+	for _, tt := range tests {
+		slots := findSlots(repo, tt.itemID)
+		if len(slots) != 1 {
+			t.Fatalf("item %d slots = %d, want 1", tt.itemID, len(slots))
+		}
+		got := slots[0]
+		if got.Count != 2 || got.ExpireTime != tt.expireTime || got.IsBound != tt.bound || got.CreateTime != testBagNow.Unix() {
+			t.Errorf("item %d = count %d, expire %d, bound %v, created %d; want 2, %d, %v, %d",
+				tt.itemID, got.Count, got.ExpireTime, got.IsBound, got.CreateTime, tt.expireTime, tt.bound, testBagNow.Unix())
+		}
+	}
+}
+
+// TestBagAddItem_StacksByExpireTime 测试过期时间不同的道具分开堆叠，消耗时优先扣除最早过期的
+func TestBagAddItem_StacksByExpireTime(t *testing.T) {
+	svc, repo, clock := newTestBagService(t)
+	ctx := context.Background()
+
+	if err := svc.AddItem(ctx, testRoleID, testTimedItemID, 3); err != nil {
+		t.Fatalf("AddItem() error = %v", err)
+	}
+	clock.now = testBagNow.Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		if err := svc.AddItem(ctx, testRoleID, testTimedItemID, 1); err != nil {
+			t.Fatalf("AddItem() error = %v", err)
+		}
+	}
+
+	if got := len(findSlots(repo, testTimedItemID)); got != 2 {
+		t.Fatalf("slots = %d, want 2 (one per expire time)", got)
+	}
+
+	if err := svc.ConsumeItem(ctx, testRoleID, testTimedItemID, 4); err != nil {
+		t.Fatalf("ConsumeItem() error = %v", err)
+	}
+	slots := findSlots(repo, testTimedItemID)
+	wantExpire := testBagNow.Add(time.Minute + testTimedItemHours*time.Hour).Unix()
+	if len(slots) != 1 || slots[0].Count != 1 || slots[0].ExpireTime != wantExpire {
+		t.Errorf("after consume slots = %+v, want the later-expiring stack with 1 left", slots)
+	}
+}
+
+// TestBagLoad_RemovesExpiredItems 测试加载背包时过期道具不计入且在下次保存时移除
+func TestBagLoad_RemovesExpiredItems(t *testing.T) {
+	svc, repo, clock := newTestBagService(t)
+	ctx := context.Background()
+
+	if err := svc.AddItem(ctx, testRoleID, testTimedItemID, 5); err != nil {
+		t.Fatalf("AddItem() error = %v", err)
+	}
+
+	clock.now = testBagNow.Add(testTimedItemHours * time.Hour)
+	count, err := svc.GetItemCount(ctx, testRoleID, testTimedItemID)
+	if err != nil {
+		t.Fatalf("GetItemCount() error = %v", err)
+	}
+	if count != 0 {
+		t.Errorf("GetItemCount() at expire time = %d, want 0", count)
+	}
+	if err := svc.ConsumeItem(ctx, testRoleID, testTimedItemID, 1); err == nil {
+		t.Error("ConsumeItem() of expired item expected error")
+	}
+
+	if err := svc.AddItem(ctx, testRoleID, testCostItemID, 1); err != nil {
+		t.Fatalf("AddItem() error = %v", err)
+	}
+	if got := bagItems(repo, gameconfig.BagType_Item); !reflect.DeepEqual(got, []string{"1001:1"}) {
+		t.Errorf("bag = %v, want expired item removed", got)
+	}
+}
+
+// TestBagExpiryJob 测试定时任务清理在线玩家的过期道具，无过期道具时不写库
+func TestBagExpiryJob(t *testing.T) {
+	svc, repo, clock := newTestBagService(t)
+	ctx := context.Background()
+
+	for _, itemID := range []int32{testTimedItemID, testDatedItemID, testCostItemID} {
+		if err := svc.AddItem(ctx, testRoleID, itemID, 1); err != nil {
+			t.Fatalf("AddItem(%d) error = %v", itemID, err)
+		}
+	}
+
+	job := newBagExpiryJob(logger.Noop(), svc, fakeOnlineRoles{testRoleID})
+	if job.Name() != BagExpiryJobName {
+		t.Errorf("Name() = %q, want %q", job.Name(), BagExpiryJobName)
+	}
+
+	clock.now = testBagNow.Add(2 * time.Hour)
+	saves := repo.calls["SaveBag"]
+	if err := job.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := bagItems(repo, gameconfig.BagType_Item); !reflect.DeepEqual(got, []string{"1001:1", "3002:1"}) {
+		t.Errorf("bag after sweep = %v, want timed item removed", got)
+	}
+	if got := repo.calls["SaveBag"] - saves; got != 1 {
+		t.Errorf("SaveBag calls = %d, want 1", got)
+	}
+
+	saves = repo.calls["SaveBag"]
+	if err := job.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := repo.calls["SaveBag"] - saves; got != 0 {
+		t.Errorf("SaveBag calls without expired items = %d, want 0", got)
+	}
+
+	clock.now = testDatedItemTime
+	removed, err := svc.SweepExpiredItems(ctx, testRoleID)
+	if err != nil {
+		t.Fatalf("SweepExpiredItems() error = %v", err)
+	}
+	if len(removed) != 1 || removed[0].ConfigID != testDatedItemID {
+		t.Errorf("SweepExpiredItems() removed %+v, want the dated item", removed)
+	}
+}
+
+// TestBagExpiryJob_SaveFailure 测试清理失败时回滚并返回错误
+func TestBagExpiryJob_SaveFailure(t *testing.T) {
+	svc, repo, clock := newTestBagService(t)
+	if err := svc.AddItem(context.Background(), testRoleID, testTimedItemID, 1); err != nil {
+		t.Fatalf("AddItem() error = %v", err)
+	}
+
+	clock.now = testBagNow.Add(2 * time.Hour)
+	repo.failAt("SaveBag", repo.calls["SaveBag"]+1)
+	job := newBagExpiryJob(logger.Noop(), svc, fakeOnlineRoles{testRoleID})
+	if err := job.Run(); err == nil {
+		t.Fatal("Run() expected error")
+	}
+	if got := bagItems(repo, gameconfig.BagType_Item); !reflect.DeepEqual(got, []string{"3001:1"}) {
+		t.Errorf("bag after failed sweep = %v, want unchanged", got)
+	}
+}
+
+// TestBagGetTradableCount 测试绑定与不可交易道具不计入可交易数量
+func TestBagGetTradableCount(t *testing.T) {
+	svc, _, _ := newTestBagService(t)
+	ctx := context.Background()
+
+	for _, itemID := range []int32{testTimedItemID, testDatedItemID, testNoTradeItemID} {
+		if err := svc.AddItem(ctx, testRoleID, itemID, 3); err != nil {
+			t.Fatalf("AddItem(%d) error = %v", itemID, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		itemID int32
+		want   int32
+	}{
+		{"pickup bound", testTimedItemID, 0},
+		{"tradable", testDatedItemID, 3},
+		{"config not tradable", testNoTradeItemID, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.GetTradableCount(ctx, testRoleID, tt.itemID)
+			if err != nil {
+				t.Fatalf("GetTradableCount() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetTradableCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
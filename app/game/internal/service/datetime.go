@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+)
+
+// configDateTimeLayout 配置表中日期时间字符串的格式
+const configDateTimeLayout = "2006-01-02 15:04:05"
+
+// parseConfigDateTime 按服务器本地时区解析配置表日期时间字符串
+func parseConfigDateTime(s string) (time.Time, error) {
+	t, err := time.ParseInLocation(configDateTimeLayout, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid datetime %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// dateTimeRangeFromStrings 由开始/结束日期时间字符串构造时间范围
+func dateTimeRangeFromStrings(start, end string) (*gameconfig.ConfigDateTimeRange, error) {
+	startTime, err := parseConfigDateTime(start)
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := parseConfigDateTime(end)
+	if err != nil {
+		return nil, err
+	}
+	if endTime.Before(startTime) {
+		return nil, fmt.Errorf("datetime range end %q is before start %q", end, start)
+	}
+
+	return &gameconfig.ConfigDateTimeRange{
+		StartTime: startTime.Unix(),
+		EndTime:   endTime.Unix(),
+	}, nil
+}
+
+// dateTimeRangeContains 判断时间点是否在范围内（首尾均包含）
+func dateTimeRangeContains(r *gameconfig.ConfigDateTimeRange, t time.Time) bool {
+	ts := t.Unix()
+	return ts >= r.StartTime && ts <= r.EndTime
+}
+
+// smeltActivityTimeRange 获取熔炼活动的开放时间范围
+func smeltActivityTimeRange(a *gameconfig.SmeltActivity) (*gameconfig.ConfigDateTimeRange, error) {
+	r, err := dateTimeRangeFromStrings(a.StartTime, a.EndTime)
+	if err != nil {
+		return nil, fmt.Errorf("smelt activity %d: %w", a.Id, err)
+	}
+	return r, nil
+}
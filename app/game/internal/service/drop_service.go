@@ -3,8 +3,8 @@ package service
 import (
 	"context"
 	"fmt"
-	"math/rand"
-	"time"
+	"math"
+	"math/rand/v2"
 
 	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
 	"github.com/lk2023060901/xdooria/pkg/logger"
@@ -16,23 +16,96 @@ type DropResult struct {
 	Count  int32
 }
 
+// DropRoll 一次掉落的结果及其随机种子
+// 配置不变时，用同一个种子重放可以得到完全相同的结果
+type DropRoll struct {
+	DropID  int32
+	Seed    int64
+	Results []*DropResult
+}
+
+// DropRand 掉落随机数流，同一种子必须产生相同的序列
+type DropRand interface {
+	// Int32N 返回 [0, n) 内的随机数
+	Int32N(n int32) int32
+}
+
+// SeedSource 掉落种子来源，每次掉落取一个新种子，需并发安全
+type SeedSource func() int64
+
+// RandFactory 由种子创建随机数流
+type RandFactory func(seed int64) DropRand
+
+// DropOption 掉落服务选项
+type DropOption func(*DropService)
+
+// WithSeedSource 指定掉落种子来源（如按角色或请求派生种子）
+func WithSeedSource(src SeedSource) DropOption {
+	return func(s *DropService) {
+		s.seeds = src
+	}
+}
+
+// WithRandFactory 指定随机数流实现
+func WithRandFactory(f RandFactory) DropOption {
+	return func(s *DropService) {
+		s.newRand = f
+	}
+}
+
+// pcgStream PCG 随机数流的固定序列号，与种子一起决定随机序列
+const pcgStream = 0x9e3779b97f4a7c15
+
+// NewPCGRand 创建默认的 PCG 随机数流
+func NewPCGRand(seed int64) DropRand {
+	return rand.New(rand.NewPCG(uint64(seed), pcgStream))
+}
+
 // DropService 掉落服务，负责处理通用的随机掉落逻辑
+// 每次掉落使用独立的随机数流，种子随结果返回以便离线重放
 type DropService struct {
-	logger logger.Logger
-	rand   *rand.Rand
+	logger  logger.Logger
+	seeds   SeedSource
+	newRand RandFactory
 }
 
 // NewDropService 创建掉落服务
-func NewDropService(l logger.Logger) *DropService {
-	return &DropService{
-		logger: l.Named("service.drop"),
-		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+func NewDropService(l logger.Logger, opts ...DropOption) *DropService {
+	s := &DropService{
+		logger:  l.Named("service.drop"),
+		seeds:   rand.Int64,
+		newRand: NewPCGRand,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
-// ExecuteDrop 执行掉落逻辑
+// ExecuteDrop 执行掉落逻辑，不关心种子时使用
 // dropID: 掉落ID，对应 TbDropGroup 中的 DropId
 func (s *DropService) ExecuteDrop(ctx context.Context, dropID int32) ([]*DropResult, error) {
+	roll, err := s.Roll(ctx, dropID)
+	if err != nil {
+		return nil, err
+	}
+	return roll.Results, nil
+}
+
+// Roll 取一个新种子执行掉落，返回结果与种子
+func (s *DropService) Roll(ctx context.Context, dropID int32) (*DropRoll, error) {
+	seed := s.seeds()
+	results, err := s.Replay(ctx, dropID, seed)
+	if err != nil {
+		return nil, err
+	}
+	return &DropRoll{DropID: dropID, Seed: seed, Results: results}, nil
+}
+
+// Replay 使用指定种子执行掉落，结果只由配置与种子决定
+func (s *DropService) Replay(ctx context.Context, dropID int32, seed int64) ([]*DropResult, error) {
+	rng := s.newRand(seed)
+
 	// 1. 查找所有属于该 dropID 的 DropGroup
 	var groups []*gameconfig.DropGroup
 	for _, group := range gameconfig.T.TbDropGroup.GetDataList() {
@@ -49,7 +122,7 @@ func (s *DropService) ExecuteDrop(ctx context.Context, dropID int32) ([]*DropRes
 	for _, group := range groups {
 		// 根据 RollCount 执行多次该组的掉落
 		for i := int32(0); i < group.RollCount; i++ {
-			items, err := s.executeGroupDrop(group)
+			items, err := s.executeGroupDrop(rng, group)
 			if err != nil {
 				return nil, err
 			}
@@ -63,7 +136,7 @@ func (s *DropService) ExecuteDrop(ctx context.Context, dropID int32) ([]*DropRes
 }
 
 // executeGroupDrop 执行具体的组掉落逻辑
-func (s *DropService) executeGroupDrop(group *gameconfig.DropGroup) ([]*DropResult, error) {
+func (s *DropService) executeGroupDrop(rng DropRand, group *gameconfig.DropGroup) ([]*DropResult, error) {
 	// 1. 查找该组下的所有掉落项
 	var items []*gameconfig.DropItem
 	totalWeight := int32(0)
@@ -84,20 +157,20 @@ func (s *DropService) executeGroupDrop(group *gameconfig.DropGroup) ([]*DropResu
 		if totalWeight <= 0 {
 			return nil, nil
 		}
-		r := s.rand.Int31n(totalWeight)
+		r := rng.Int32N(totalWeight)
 		current := int32(0)
 		for _, item := range items {
 			current += item.DropValue
 			if r < current {
-				return []*DropResult{s.createDropResult(item)}, nil
+				return []*DropResult{s.createDropResult(rng, item)}, nil
 			}
 		}
 
 	case gameconfig.DropType_PROBABILITY: // 独立概率（每个项按万分比独立判定是否掉落）
 		var results []*DropResult
 		for _, item := range items {
-			if s.rand.Int31n(10000) < item.DropValue {
-				results = append(results, s.createDropResult(item))
+			if rng.Int32N(10000) < item.DropValue {
+				results = append(results, s.createDropResult(rng, item))
 			}
 		}
 		return results, nil
@@ -105,7 +178,7 @@ func (s *DropService) executeGroupDrop(group *gameconfig.DropGroup) ([]*DropResu
 	case gameconfig.DropType_FIXED: // 固定掉落（组内所有项全部掉落）
 		var results []*DropResult
 		for _, item := range items {
-			results = append(results, s.createDropResult(item))
+			results = append(results, s.createDropResult(rng, item))
 		}
 		return results, nil
 	}
@@ -114,14 +187,83 @@ func (s *DropService) executeGroupDrop(group *gameconfig.DropGroup) ([]*DropResu
 }
 
 // createDropResult 根据配置项生成最终的掉落结果
-func (s *DropService) createDropResult(item *gameconfig.DropItem) *DropResult {
+func (s *DropService) createDropResult(rng DropRand, item *gameconfig.DropItem) *DropResult {
 	count := item.CountMin
 	if item.CountMax > item.CountMin {
 		// 在 [CountMin, CountMax] 范围内取随机数
-		count = item.CountMin + s.rand.Int31n(item.CountMax-item.CountMin+1)
+		count = item.CountMin + rng.Int32N(item.CountMax-item.CountMin+1)
 	}
 	return &DropResult{
 		ItemID: item.ItemId,
 		Count:  count,
 	}
 }
+
+// DropRate 某道具在一次掉落中的理论产出
+type DropRate struct {
+	ItemID      int32
+	HitRate     float64 // 每次掉落的期望命中次数
+	HitVariance float64 // 每次掉落命中次数的方差
+	CountRate   float64 // 每次掉落的期望产出数量
+}
+
+// ExpectedRates 根据 TbDropGroup/TbDropItem 配置计算掉落的理论产出，与 ExecuteDrop 的算法一致
+func (s *DropService) ExpectedRates(dropID int32) ([]*DropRate, error) {
+	var groups []*gameconfig.DropGroup
+	for _, group := range gameconfig.T.TbDropGroup.GetDataList() {
+		if group.DropId == dropID {
+			groups = append(groups, group)
+		}
+	}
+
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("drop_id %d not found in drop groups", dropID)
+	}
+
+	var rates []*DropRate
+	byItem := make(map[int32]*DropRate)
+	for _, group := range groups {
+		var items []*gameconfig.DropItem
+		totalWeight := int32(0)
+		for _, item := range gameconfig.T.TbDropItem.GetDataList() {
+			if item.GroupId == group.Id {
+				items = append(items, item)
+				totalWeight += item.DropValue
+			}
+		}
+
+		for _, item := range items {
+			// 单次判定的命中概率
+			var p float64
+			switch group.DropType {
+			case gameconfig.DropType_WEIGHT:
+				if totalWeight > 0 {
+					p = float64(item.DropValue) / float64(totalWeight)
+				}
+			case gameconfig.DropType_PROBABILITY:
+				p = math.Min(math.Max(float64(item.DropValue)/10000, 0), 1)
+			case gameconfig.DropType_FIXED:
+				p = 1
+			}
+
+			rate, ok := byItem[item.ItemId]
+			if !ok {
+				rate = &DropRate{ItemID: item.ItemId}
+				byItem[item.ItemId] = rate
+				rates = append(rates, rate)
+			}
+
+			// 各次判定相互独立，期望与方差可直接累加
+			rolls := float64(group.RollCount)
+			avgCount := float64(item.CountMin)
+			if item.CountMax > item.CountMin {
+				avgCount = float64(item.CountMin+item.CountMax) / 2
+			}
+			rate.HitRate += rolls * p
+			rate.HitVariance += rolls * p * (1 - p)
+			rate.CountRate += rolls * p * avgCount
+		}
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// fixedSeeds 按顺序返回给定种子的种子来源
+func fixedSeeds(seeds ...int64) SeedSource {
+	i := 0
+	return func() int64 {
+		seed := seeds[i%len(seeds)]
+		i++
+		return seed
+	}
+}
+
+// setupRandomDrop 将测试掉落 100 替换为带随机数量的权重 + 概率掉落
+func setupRandomDrop(t *testing.T) {
+	t.Helper()
+	setupTestConfig(t)
+
+	groups, err := gameconfig.NewTbDropGroup([]map[string]interface{}{
+		{"id": float64(1), "drop_id": float64(testDropID), "drop_type": float64(gameconfig.DropType_WEIGHT), "roll_count": float64(3)},
+		{"id": float64(2), "drop_id": float64(testDropID), "drop_type": float64(gameconfig.DropType_PROBABILITY), "roll_count": float64(1)},
+	})
+	if err != nil {
+		t.Fatalf("failed to build drop groups: %v", err)
+	}
+	items, err := gameconfig.NewTbDropItem([]map[string]interface{}{
+		{"id": float64(1), "group_id": float64(1), "item_id": float64(testRewardItemID), "count_min": float64(1), "count_max": float64(100), "drop_value": float64(1)},
+		{"id": float64(2), "group_id": float64(1), "item_id": float64(testCostItemID), "count_min": float64(1), "count_max": float64(100), "drop_value": float64(1)},
+		{"id": float64(3), "group_id": float64(2), "item_id": float64(testDollID), "count_min": float64(1), "count_max": float64(1), "drop_value": float64(5000)},
+	})
+	if err != nil {
+		t.Fatalf("failed to build drop items: %v", err)
+	}
+	gameconfig.T.TbDropGroup = groups
+	gameconfig.T.TbDropItem = items
+}
+
+// TestDropService_Replay 测试相同种子得到相同结果，不同种子得到不同结果
+func TestDropService_Replay(t *testing.T) {
+	setupRandomDrop(t)
+	ctx := context.Background()
+	svc := NewDropService(logger.Noop(), WithSeedSource(fixedSeeds(42, 43)))
+
+	roll, err := svc.Roll(ctx, testDropID)
+	if err != nil {
+		t.Fatalf("Roll() error = %v", err)
+	}
+	if roll.Seed != 42 || roll.DropID != testDropID {
+		t.Errorf("Roll() seed/drop = %d/%d, want 42/%d", roll.Seed, roll.DropID, testDropID)
+	}
+
+	// 使用另一个服务实例离线重放
+	replayed, err := NewDropService(logger.Noop()).Replay(ctx, testDropID, roll.Seed)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if !reflect.DeepEqual(replayed, roll.Results) {
+		t.Errorf("Replay() = %v, want %v", dropResultStrings(replayed), dropResultStrings(roll.Results))
+	}
+
+	next, err := svc.Roll(ctx, testDropID)
+	if err != nil {
+		t.Fatalf("Roll() error = %v", err)
+	}
+	if next.Seed != 43 || reflect.DeepEqual(next.Results, roll.Results) {
+		t.Errorf("Roll() with seed %d = %v, want a different result from seed 42", next.Seed, dropResultStrings(next.Results))
+	}
+}
+
+// TestDropService_RandFactory 测试可替换随机数流实现
+func TestDropService_RandFactory(t *testing.T) {
+	setupRandomDrop(t)
+
+	// 始终返回 0：权重组每次取第一项且数量取最小值，概率组必定命中
+	var seeds []int64
+	svc := NewDropService(logger.Noop(), WithRandFactory(func(seed int64) DropRand {
+		seeds = append(seeds, seed)
+		return zeroRand{}
+	}))
+
+	results, err := svc.Replay(context.Background(), testDropID, 7)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	want := []string{"1002:1", "1002:1", "1002:1", "2001:1"}
+	if got := dropResultStrings(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("Replay() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(seeds, []int64{7}) {
+		t.Errorf("rand factory seeds = %v, want [7]", seeds)
+	}
+}
+
+type zeroRand struct{}
+
+func (zeroRand) Int32N(n int32) int32 { return 0 }
+
+func dropResultStrings(results []*DropResult) []string {
+	out := make([]string, 0, len(results))
+	for _, r := range results {
+		out = append(out, fmt.Sprintf("%d:%d", r.ItemID, r.Count))
+	}
+	return out
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"flag"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// 模拟抽卡参数，可通过 go test -args 调整，例如:
+//
+//	go test ./app/game/internal/service -run TestGachaSimulation -args -gacha.sim.draws=10000000 -gacha.sim.seed=7
+var (
+	simDraws = flag.Int("gacha.sim.draws", 1000000, "number of simulated draws per gacha pool")
+	simSeed  = flag.Uint64("gacha.sim.seed", 20260304, "base seed of simulated draws")
+	simZMax  = flag.Float64("gacha.sim.zmax", 6, "max allowed |z-score| between realized and configured hit rates")
+)
+
+// realConfigDir 实际配置表目录
+const realConfigDir = "../../configs/data"
+
+// simulationStats 模拟抽卡的统计结果
+type simulationStats struct {
+	draws     map[int32]int64           // dropID -> 抽取次数
+	hits      map[int32]map[int32]int64 // dropID -> itemID -> 命中次数
+	pityFired map[int32]int64           // 保底配置ID -> 触发次数
+}
+
+// TestGachaSimulation 使用实际配置表模拟大量抽取，校验实际概率与配置一致、保底按配置触发
+func TestGachaSimulation(t *testing.T) {
+	if err := gameconfig.Load(realConfigDir, logger.Noop()); err != nil {
+		t.Fatalf("failed to load game config: %v", err)
+	}
+	t.Cleanup(func() { gameconfig.T = nil })
+
+	draws := *simDraws
+	if testing.Short() {
+		draws = 50000
+	}
+
+	base := rand.New(rand.NewPCG(*simSeed, 0))
+	dropSvc := NewDropService(logger.Noop(), WithSeedSource(func() int64 { return base.Int64() }))
+	svc := NewGachaService(logger.Noop(), nil, nil, dropSvc, nil, nil)
+
+	for _, pool := range gameconfig.T.TbGacha.GetDataList() {
+		t.Run(pool.Name, func(t *testing.T) {
+			stats := simulateDraws(t, svc, pool, draws)
+			assertPityTriggers(t, svc, pool, draws, stats)
+			assertDropRates(t, dropSvc, stats)
+		})
+	}
+}
+
+// simulateDraws 按 Draw 相同的保底推进逻辑执行 n 次抽取
+func simulateDraws(t *testing.T, svc *GachaService, pool *gameconfig.Gacha, n int) *simulationStats {
+	t.Helper()
+
+	stats := &simulationStats{
+		draws:     make(map[int32]int64),
+		hits:      make(map[int32]map[int32]int64),
+		pityFired: make(map[int32]int64),
+	}
+	ctx := context.Background()
+	record := &model.GachaRecord{ID: pool.Id, Type: pool.Type}
+	for i := 0; i < n; i++ {
+		dropID, isPity, pityCount := svc.nextDraw(record, pool)
+		if isPity {
+			for _, pity := range gameconfig.T.TbGachaPity.GetDataList() {
+				if pity.GachaId == pool.Id && pity.TriggerCount == pityCount {
+					stats.pityFired[pity.Id]++
+				}
+			}
+		}
+
+		roll, err := svc.dropSvc.Roll(ctx, dropID)
+		if err != nil {
+			t.Fatalf("draw %d: Roll(%d) error = %v", i, dropID, err)
+		}
+		stats.draws[dropID]++
+		if stats.hits[dropID] == nil {
+			stats.hits[dropID] = make(map[int32]int64)
+		}
+		for _, res := range roll.Results {
+			stats.hits[dropID][res.ItemID]++
+		}
+	}
+	return stats
+}
+
+// assertPityTriggers 校验每条保底配置恰好在每个保底周期的第 trigger_count 抽触发
+func assertPityTriggers(t *testing.T, svc *GachaService, pool *gameconfig.Gacha, n int, stats *simulationStats) {
+	t.Helper()
+
+	cycle := int(svc.GetMaxTriggerCount(pool.Id))
+	for _, pity := range gameconfig.T.TbGachaPity.GetDataList() {
+		if pity.GachaId != pool.Id || pity.PityDropId == 0 {
+			continue
+		}
+		want := int64(n / cycle)
+		if n%cycle >= int(pity.TriggerCount) {
+			want++
+		}
+		if got := stats.pityFired[pity.Id]; got != want {
+			t.Errorf("pity %d (trigger %d) fired %d times, want %d", pity.Id, pity.TriggerCount, got, want)
+		}
+	}
+}
+
+// assertDropRates 校验每个掉落ID下各道具的实际命中次数与理论值的偏差在 simZMax 个标准差内
+func assertDropRates(t *testing.T, dropSvc *DropService, stats *simulationStats) {
+	t.Helper()
+
+	for dropID, draws := range stats.draws {
+		rates, err := dropSvc.ExpectedRates(dropID)
+		if err != nil {
+			t.Fatalf("ExpectedRates(%d) error = %v", dropID, err)
+		}
+
+		expected := make(map[int32]bool, len(rates))
+		for _, rate := range rates {
+			expected[rate.ItemID] = true
+			hits := stats.hits[dropID][rate.ItemID]
+			mean := float64(draws) * rate.HitRate
+			sd := math.Sqrt(float64(draws) * rate.HitVariance)
+			if sd == 0 {
+				if float64(hits) != mean {
+					t.Errorf("drop %d item %d: hits = %d, want exactly %.0f", dropID, rate.ItemID, hits, mean)
+				}
+				continue
+			}
+			if z := (float64(hits) - mean) / sd; math.Abs(z) > *simZMax {
+				t.Errorf("drop %d item %d: realized rate %.6f, configured %.6f (z=%.2f over %d draws)",
+					dropID, rate.ItemID, float64(hits)/float64(draws), rate.HitRate, z, draws)
+			}
+		}
+		for itemID := range stats.hits[dropID] {
+			if !expected[itemID] {
+				t.Errorf("drop %d produced unconfigured item %d", dropID, itemID)
+			}
+		}
+	}
+}
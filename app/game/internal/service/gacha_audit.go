@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+)
+
+var (
+	// ErrGachaPoolNotFound 池子配置不存在
+	ErrGachaPoolNotFound = errors.New("gacha pool not found")
+	// ErrGachaDrawLogNotFound 抽卡流水不存在
+	ErrGachaDrawLogNotFound = errors.New("gacha draw log not found")
+)
+
+// 抽卡历史分页参数
+const (
+	defaultHistoryPageSize = 20
+	maxHistoryPageSize     = 100
+)
+
+// GachaPoolReport 池子概率审计报告：实际产出与配置理论值的对比
+type GachaPoolReport struct {
+	PoolID int32
+	From   time.Time
+	To     time.Time
+	Drops  []*GachaDropReport
+}
+
+// GachaDropReport 池子下某个掉落ID（普通掉落或保底掉落）的审计结果
+type GachaDropReport struct {
+	DropID int32
+	IsPity bool  // 是否为保底掉落
+	Draws  int64 // 使用该掉落的抽取次数
+	Items  []*GachaItemReport
+}
+
+// GachaItemReport 单个道具的实际与理论产出
+type GachaItemReport struct {
+	ItemID        int32
+	Hits          int64   // 实际命中次数
+	Total         int64   // 实际产出数量
+	ActualRate    float64 // 实际每抽命中次数
+	ExpectedRate  float64 // 理论每抽命中次数
+	ActualCount   float64 // 实际每抽产出数量
+	ExpectedCount float64 // 理论每抽产出数量
+	// ZScore 命中次数相对理论值的标准分，|ZScore| 超过 3 说明实际概率与配置明显不符
+	ZScore float64
+}
+
+// newGachaDrawLog 由一次抽取的掉落结果构造抽卡流水
+func newGachaDrawLog(roleID int64, poolID int32, roll *DropRoll, isPity bool, pityCount int32, now int64) *model.GachaDrawLog {
+	return &model.GachaDrawLog{
+		RoleID:    roleID,
+		PoolID:    poolID,
+		DropID:    roll.DropID,
+		Seed:      roll.Seed,
+		IsPity:    isPity,
+		PityCount: pityCount,
+		Results:   toGachaDrawItems(roll.Results),
+		CreatedAt: now,
+	}
+}
+
+func toGachaDrawItems(results []*DropResult) []*model.GachaDrawItem {
+	items := make([]*model.GachaDrawItem, 0, len(results))
+	for _, res := range results {
+		items = append(items, &model.GachaDrawItem{ItemID: res.ItemID, Count: res.Count})
+	}
+	return items
+}
+
+// ReplayDrawLog 用流水记录的种子重放一次抽取，返回重放结果以及是否与记录一致
+// 重放依赖当前加载的配置表，配置在抽取后被修改时结果可能不一致
+func (s *GachaService) ReplayDrawLog(ctx context.Context, log *model.GachaDrawLog) ([]*model.GachaDrawItem, bool, error) {
+	results, err := s.dropSvc.Replay(ctx, log.DropID, log.Seed)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to replay gacha draw %d: %w", log.ID, err)
+	}
+
+	replayed := toGachaDrawItems(results)
+	if len(replayed) != len(log.Results) {
+		return replayed, false, nil
+	}
+	for i, item := range replayed {
+		if *item != *log.Results[i] {
+			return replayed, false, nil
+		}
+	}
+	return replayed, true, nil
+}
+
+// ReplayDrawLogByID 加载指定ID的抽卡流水并重放，返回流水、重放结果以及是否一致
+func (s *GachaService) ReplayDrawLogByID(ctx context.Context, id int64) (*model.GachaDrawLog, []*model.GachaDrawItem, bool, error) {
+	log, err := s.playerRepo.GetGachaDrawLog(ctx, id)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to get gacha draw log %d: %w", id, err)
+	}
+	if log == nil {
+		return nil, nil, false, fmt.Errorf("%w: %d", ErrGachaDrawLogNotFound, id)
+	}
+
+	replayed, ok, err := s.ReplayDrawLog(ctx, log)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return log, replayed, ok, nil
+}
+
+// GetDrawHistory 按时间倒序分页查询玩家抽卡历史
+// poolID 为 0 时查询全部池子；page 从 1 开始，pageSize 超出范围时取默认值或上限
+func (s *GachaService) GetDrawHistory(ctx context.Context, roleID int64, poolID int32, page, pageSize int32) ([]*model.GachaDrawLog, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = defaultHistoryPageSize
+	}
+	if pageSize > maxHistoryPageSize {
+		pageSize = maxHistoryPageSize
+	}
+
+	offset := uint64(page-1) * uint64(pageSize)
+	logs, total, err := s.playerRepo.ListGachaDrawLogs(ctx, roleID, poolID, offset, uint64(pageSize))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list gacha history: %w", err)
+	}
+	return logs, total, nil
+}
+
+// GetPoolStats 统计池子在 [from, to) 内的实际产出，并与 TbDrop/TbDropGroup 配置的理论概率对比
+// 普通掉落与保底掉落分开统计，避免保底拉高普通概率
+func (s *GachaService) GetPoolStats(ctx context.Context, poolID int32, from, to time.Time) (*GachaPoolReport, error) {
+	poolCfg := gameconfig.T.TbGacha.Get(poolID)
+	if poolCfg == nil {
+		return nil, fmt.Errorf("%w: %d", ErrGachaPoolNotFound, poolID)
+	}
+
+	stats, err := s.playerRepo.GetGachaPoolStats(ctx, poolID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gacha pool stats: %w", err)
+	}
+
+	report := &GachaPoolReport{PoolID: poolID, From: from, To: to}
+	for _, stat := range stats {
+		drop, err := s.buildDropReport(stat, stat.DropID != poolCfg.DropId)
+		if err != nil {
+			return nil, err
+		}
+		report.Drops = append(report.Drops, drop)
+	}
+	return report, nil
+}
+
+// buildDropReport 合并实际产出与理论产出，配置中有但从未产出的道具也会列出
+func (s *GachaService) buildDropReport(stat *model.GachaDropStats, isPity bool) (*GachaDropReport, error) {
+	rates, err := s.dropSvc.ExpectedRates(stat.DropID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute expected rates of drop %d: %w", stat.DropID, err)
+	}
+
+	drop := &GachaDropReport{DropID: stat.DropID, IsPity: isPity, Draws: stat.Draws}
+	byItem := make(map[int32]*GachaItemReport)
+	for _, rate := range rates {
+		item := &GachaItemReport{ItemID: rate.ItemID}
+		byItem[rate.ItemID] = item
+		drop.Items = append(drop.Items, item)
+	}
+	for _, actual := range stat.Items {
+		item, ok := byItem[actual.ItemID]
+		if !ok {
+			// 配置已变更或产出异常，理论值视为 0
+			item = &GachaItemReport{ItemID: actual.ItemID}
+			byItem[actual.ItemID] = item
+			drop.Items = append(drop.Items, item)
+		}
+		item.Hits = actual.Hits
+		item.Total = actual.Total
+	}
+
+	draws := float64(stat.Draws)
+	for _, rate := range rates {
+		item := byItem[rate.ItemID]
+		item.ExpectedRate = rate.HitRate
+		item.ExpectedCount = rate.CountRate
+		if sd := math.Sqrt(draws * rate.HitVariance); sd > 0 {
+			item.ZScore = (float64(item.Hits) - draws*rate.HitRate) / sd
+		}
+	}
+	if draws > 0 {
+		for _, item := range drop.Items {
+			item.ActualRate = float64(item.Hits) / draws
+			item.ActualCount = float64(item.Total) / draws
+		}
+	}
+	return drop, nil
+}
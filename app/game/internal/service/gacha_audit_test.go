@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// testGachaNow 抽卡审计测试基准时间
+var testGachaNow = time.Date(2026, 3, 4, 12, 0, 0, 0, time.Local)
+
+const testPityDropID = 200
+
+// setupAuditDrops 将测试掉落 100 替换为权重掉落，并追加保底掉落 200
+// 掉落 100: 权重组，1002 权重 3 (数量 1~3)，1001 权重 1 (数量 1)
+// 掉落 200: 固定组，玩偶 2001 ×1
+func setupAuditDrops(t *testing.T) {
+	t.Helper()
+
+	groups, err := gameconfig.NewTbDropGroup([]map[string]interface{}{
+		{"id": float64(1), "drop_id": float64(testDropID), "drop_type": float64(gameconfig.DropType_WEIGHT), "roll_count": float64(1)},
+		{"id": float64(2), "drop_id": float64(testPityDropID), "drop_type": float64(gameconfig.DropType_FIXED), "roll_count": float64(1)},
+	})
+	if err != nil {
+		t.Fatalf("failed to build drop groups: %v", err)
+	}
+	items, err := gameconfig.NewTbDropItem([]map[string]interface{}{
+		{"id": float64(1), "group_id": float64(1), "item_id": float64(testRewardItemID), "count_min": float64(1), "count_max": float64(3), "drop_value": float64(3)},
+		{"id": float64(2), "group_id": float64(1), "item_id": float64(testCostItemID), "count_min": float64(1), "count_max": float64(1), "drop_value": float64(1)},
+		{"id": float64(3), "group_id": float64(2), "item_id": float64(testDollID), "count_min": float64(1), "count_max": float64(1), "drop_value": float64(0)},
+	})
+	if err != nil {
+		t.Fatalf("failed to build drop items: %v", err)
+	}
+	gameconfig.T.TbDropGroup = groups
+	gameconfig.T.TbDropItem = items
+}
+
+// setupPity 为测试池子配置第 triggerCount 抽触发保底掉落 200
+func setupPity(t *testing.T, svc *GachaService, triggerCount int32) {
+	t.Helper()
+
+	pity, err := gameconfig.NewTbGachaPity([]map[string]interface{}{
+		{"id": float64(1), "gacha_id": float64(testPoolID), "trigger_count": float64(triggerCount), "pity_drop_id": float64(testPityDropID),
+			"target_quality": float64(0), "extra_reward_type": float64(0), "extra_reward_id": float64(0)},
+	})
+	if err != nil {
+		t.Fatalf("failed to build pity config: %v", err)
+	}
+	gameconfig.T.TbGachaPity = pity
+	svc.maxPityMap = make(map[int32]int32)
+	svc.initMaxPityMap()
+}
+
+// TestGachaDraw_RecordsDrawLogs 测试每次抽取都记录一条流水，保底抽取记录保底掉落ID
+func TestGachaDraw_RecordsDrawLogs(t *testing.T) {
+	svc, repo, _ := newTestGachaService(t)
+	setupAuditDrops(t)
+	setupPity(t, svc, 3)
+	svc.now = func() time.Time { return testGachaNow }
+
+	results, _, err := svc.Draw(context.Background(), testRoleID, testPoolID, 4)
+	if err != nil {
+		t.Fatalf("Draw() error = %v", err)
+	}
+
+	if len(repo.state.logs) != 4 {
+		t.Fatalf("draw logs = %d, want 4", len(repo.state.logs))
+	}
+	produced := 0
+	for i, l := range repo.state.logs {
+		wantPity := i == 2
+		wantDrop := int32(testDropID)
+		if wantPity {
+			wantDrop = testPityDropID
+		}
+		if l.RoleID != testRoleID || l.PoolID != testPoolID || l.DropID != wantDrop || l.IsPity != wantPity {
+			t.Errorf("log[%d] = %+v, want drop_id=%d is_pity=%v", i, l, wantDrop, wantPity)
+		}
+		// 保底次数达到上限后清零，第 4 抽重新从 1 计数
+		if wantCount := []int32{1, 2, 3, 1}[i]; l.PityCount != wantCount {
+			t.Errorf("log[%d] pity_count = %d, want %d", i, l.PityCount, wantCount)
+		}
+		if l.CreatedAt != testGachaNow.Unix() {
+			t.Errorf("log[%d] created_at = %d, want %d", i, l.CreatedAt, testGachaNow.Unix())
+		}
+		produced += len(l.Results)
+	}
+	if produced != len(results) {
+		t.Errorf("logged results = %d, want %d", produced, len(results))
+	}
+}
+
+// TestGachaGetDrawHistory 测试抽卡历史按时间倒序分页
+func TestGachaGetDrawHistory(t *testing.T) {
+	svc, repo, _ := newTestGachaService(t)
+	if _, _, err := svc.Draw(context.Background(), testRoleID, testPoolID, 5); err != nil {
+		t.Fatalf("Draw() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		poolID   int32
+		page     int32
+		pageSize int32
+		wantIDs  []int64
+	}{
+		{"first page", testPoolID, 1, 2, []int64{5, 4}},
+		{"last page", testPoolID, 3, 2, []int64{1}},
+		{"out of range", testPoolID, 4, 2, nil},
+		{"all pools with default size", 0, 0, 0, []int64{5, 4, 3, 2, 1}},
+		{"other pool", testBrokenPoolID, 1, 10, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, total, err := svc.GetDrawHistory(context.Background(), testRoleID, tt.poolID, tt.page, tt.pageSize)
+			if err != nil {
+				t.Fatalf("GetDrawHistory() error = %v", err)
+			}
+			wantTotal := int64(len(repo.state.logs))
+			if tt.poolID == testBrokenPoolID {
+				wantTotal = 0
+			}
+			if total != wantTotal {
+				t.Errorf("GetDrawHistory() total = %d, want %d", total, wantTotal)
+			}
+			var ids []int64
+			for _, l := range logs {
+				ids = append(ids, l.ID)
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("GetDrawHistory() ids = %v, want %v", ids, tt.wantIDs)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Errorf("GetDrawHistory() ids = %v, want %v", ids, tt.wantIDs)
+					break
+				}
+			}
+		})
+	}
+}
+
+// TestDropService_ExpectedRates 测试三种掉落类型的理论产出计算
+func TestDropService_ExpectedRates(t *testing.T) {
+	setupTestConfig(t)
+	groups, err := gameconfig.NewTbDropGroup([]map[string]interface{}{
+		{"id": float64(1), "drop_id": float64(300), "drop_type": float64(gameconfig.DropType_WEIGHT), "roll_count": float64(2)},
+		{"id": float64(2), "drop_id": float64(300), "drop_type": float64(gameconfig.DropType_PROBABILITY), "roll_count": float64(1)},
+		{"id": float64(3), "drop_id": float64(300), "drop_type": float64(gameconfig.DropType_FIXED), "roll_count": float64(1)},
+	})
+	if err != nil {
+		t.Fatalf("failed to build drop groups: %v", err)
+	}
+	items, err := gameconfig.NewTbDropItem([]map[string]interface{}{
+		{"id": float64(1), "group_id": float64(1), "item_id": float64(1), "count_min": float64(1), "count_max": float64(1), "drop_value": float64(1)},
+		{"id": float64(2), "group_id": float64(1), "item_id": float64(2), "count_min": float64(2), "count_max": float64(4), "drop_value": float64(3)},
+		{"id": float64(3), "group_id": float64(2), "item_id": float64(1), "count_min": float64(1), "count_max": float64(1), "drop_value": float64(2000)},
+		{"id": float64(4), "group_id": float64(3), "item_id": float64(3), "count_min": float64(5), "count_max": float64(5), "drop_value": float64(0)},
+	})
+	if err != nil {
+		t.Fatalf("failed to build drop items: %v", err)
+	}
+	gameconfig.T.TbDropGroup = groups
+	gameconfig.T.TbDropItem = items
+
+	rates, err := NewDropService(logger.Noop()).ExpectedRates(300)
+	if err != nil {
+		t.Fatalf("ExpectedRates() error = %v", err)
+	}
+
+	want := map[int32]DropRate{
+		// 权重组 2 次 × 1/4 + 概率组 20%
+		1: {ItemID: 1, HitRate: 0.7, HitVariance: 2*0.25*0.75 + 0.2*0.8, CountRate: 0.7},
+		// 权重组 2 次 × 3/4，平均数量 3
+		2: {ItemID: 2, HitRate: 1.5, HitVariance: 2 * 0.75 * 0.25, CountRate: 4.5},
+		3: {ItemID: 3, HitRate: 1, HitVariance: 0, CountRate: 5},
+	}
+	if len(rates) != len(want) {
+		t.Fatalf("ExpectedRates() = %d items, want %d", len(rates), len(want))
+	}
+	for _, got := range rates {
+		w := want[got.ItemID]
+		if !approxEqual(got.HitRate, w.HitRate) || !approxEqual(got.HitVariance, w.HitVariance) || !approxEqual(got.CountRate, w.CountRate) {
+			t.Errorf("ExpectedRates() item %d = %+v, want %+v", got.ItemID, *got, w)
+		}
+	}
+
+	if _, err := NewDropService(logger.Noop()).ExpectedRates(999); err == nil {
+		t.Error("ExpectedRates() expected error for missing drop")
+	}
+}
+
+// TestGachaGetPoolStats 测试实际产出与理论概率对比，保底掉落单独统计
+func TestGachaGetPoolStats(t *testing.T) {
+	svc, repo, _ := newTestGachaService(t)
+	setupAuditDrops(t)
+	setupPity(t, svc, 10)
+	svc.now = func() time.Time { return testGachaNow }
+
+	const draws = 2000
+	setBagCount(repo, gameconfig.BagType_Item, testCostItemID, draws)
+	if _, _, err := svc.Draw(context.Background(), testRoleID, testPoolID, draws); err != nil {
+		t.Fatalf("Draw() error = %v", err)
+	}
+
+	// 统计区间外的抽取不应计入
+	svc.now = func() time.Time { return testGachaNow.Add(time.Hour) }
+	setBagCount(repo, gameconfig.BagType_Item, testCostItemID, 10)
+	if _, _, err := svc.Draw(context.Background(), testRoleID, testPoolID, 10); err != nil {
+		t.Fatalf("Draw() error = %v", err)
+	}
+
+	report, err := svc.GetPoolStats(context.Background(), testPoolID, testGachaNow, testGachaNow.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetPoolStats() error = %v", err)
+	}
+	if len(report.Drops) != 2 {
+		t.Fatalf("GetPoolStats() drops = %d, want 2", len(report.Drops))
+	}
+
+	for _, drop := range report.Drops {
+		switch drop.DropID {
+		case testDropID:
+			if drop.IsPity || drop.Draws != draws-draws/10 {
+				t.Errorf("normal drop = %+v, want %d non-pity draws", drop, draws-draws/10)
+			}
+			want := map[int32][2]float64{
+				testRewardItemID: {0.75, 1.5},
+				testCostItemID:   {0.25, 0.25},
+			}
+			if len(drop.Items) != len(want) {
+				t.Fatalf("normal drop items = %d, want %d", len(drop.Items), len(want))
+			}
+			for _, item := range drop.Items {
+				w := want[item.ItemID]
+				if !approxEqual(item.ExpectedRate, w[0]) || !approxEqual(item.ExpectedCount, w[1]) {
+					t.Errorf("item %d expected rate/count = %v/%v, want %v", item.ItemID, item.ExpectedRate, item.ExpectedCount, w)
+				}
+				// 2000 次抽取下 5 个标准差之外几乎不可能出现
+				if math.Abs(item.ZScore) > 5 {
+					t.Errorf("item %d actual rate %v deviates from expected %v (z=%v)", item.ItemID, item.ActualRate, item.ExpectedRate, item.ZScore)
+				}
+			}
+		case testPityDropID:
+			if !drop.IsPity || drop.Draws != draws/10 {
+				t.Errorf("pity drop = %+v, want %d pity draws", drop, draws/10)
+			}
+			if len(drop.Items) != 1 || drop.Items[0].ActualRate != 1 || drop.Items[0].ExpectedRate != 1 || drop.Items[0].ZScore != 0 {
+				t.Errorf("pity drop items = %+v, want guaranteed doll", drop.Items)
+			}
+		default:
+			t.Errorf("unexpected drop %d in report", drop.DropID)
+		}
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// TestGachaReplayDrawLog 测试用流水记录的种子重放抽取结果
+func TestGachaReplayDrawLog(t *testing.T) {
+	svc, repo, _ := newTestGachaService(t)
+	setupRandomDrop(t)
+	svc.dropSvc = NewDropService(logger.Noop(), WithSeedSource(fixedSeeds(11, 12, 13)))
+
+	if _, _, err := svc.Draw(context.Background(), testRoleID, testPoolID, 3); err != nil {
+		t.Fatalf("Draw() error = %v", err)
+	}
+
+	for i := range repo.state.logs {
+		l := repo.state.logs[i]
+		if l.Seed != int64(11+i) {
+			t.Errorf("log[%d] seed = %d, want %d", i, l.Seed, 11+i)
+		}
+		if _, ok, err := svc.ReplayDrawLog(context.Background(), &l); err != nil || !ok {
+			t.Errorf("ReplayDrawLog(log[%d]) = %v, %v, want match", i, ok, err)
+		}
+	}
+
+	// 篡改记录后重放应发现不一致
+	tampered := repo.state.logs[0]
+	tampered.Results = append(toGachaDrawItems(nil), tampered.Results[1:]...)
+	if _, ok, err := svc.ReplayDrawLog(context.Background(), &tampered); err != nil || ok {
+		t.Errorf("ReplayDrawLog(tampered) = %v, %v, want mismatch", ok, err)
+	}
+
+	// 按ID加载流水重放
+	log, _, ok, err := svc.ReplayDrawLogByID(context.Background(), repo.state.logs[1].ID)
+	if err != nil || !ok || log.ID != repo.state.logs[1].ID {
+		t.Errorf("ReplayDrawLogByID() = %v, %v, %v, want match", log, ok, err)
+	}
+	if _, _, _, err := svc.ReplayDrawLogByID(context.Background(), 999); !errors.Is(err, ErrGachaDrawLogNotFound) {
+		t.Errorf("ReplayDrawLogByID(missing) error = %v, want ErrGachaDrawLogNotFound", err)
+	}
+}
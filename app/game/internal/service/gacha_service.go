@@ -15,16 +15,20 @@ import (
 type GachaService struct {
 	logger     logger.Logger
 	playerRepo repository.PlayerRepository
+	uow        repository.UnitOfWork
 	dropSvc    *DropService
 	dollSvc    *DollService
 	bagSvc     *BagService
 	// maxPityMap 缓存每个池子的最大保底次数 {PoolID: MaxCount}
 	maxPityMap map[int32]int32
+
+	now func() time.Time
 }
 
 func NewGachaService(
 	l logger.Logger,
 	playerRepo repository.PlayerRepository,
+	uow repository.UnitOfWork,
 	dropSvc *DropService,
 	dollSvc *DollService,
 	bagSvc *BagService,
@@ -32,10 +36,12 @@ func NewGachaService(
 	s := &GachaService{
 		logger:     l.Named("service.gacha"),
 		playerRepo: playerRepo,
+		uow:        uow,
 		dropSvc:    dropSvc,
 		dollSvc:    dollSvc,
 		bagSvc:     bagSvc,
 		maxPityMap: make(map[int32]int32),
+		now:        time.Now,
 	}
 	s.initMaxPityMap()
 	return s
@@ -57,77 +63,102 @@ func (s *GachaService) GetMaxTriggerCount(poolID int32) int32 {
 }
 
 // Draw 盲盒抽取主逻辑
+// 扣费、掉落、发奖、抽卡记录与抽卡流水在同一个工作单元内完成，任一步骤失败则整体回滚
 func (s *GachaService) Draw(ctx context.Context, roleID int64, poolID int32, count int32) ([]*DropResult, int32, error) {
 	// 1. 获取配置
 	poolCfg := gameconfig.T.TbGacha.Get(poolID)
 	if poolCfg == nil {
 		return nil, 0, fmt.Errorf("gacha pool %d not found", poolID)
 	}
-
-	// 2. 扣除消耗
-	if poolCfg.CostItem > 0 && poolCfg.CostCount > 0 {
-		if err := s.bagSvc.ConsumeItem(ctx, roleID, poolCfg.CostItem, poolCfg.CostCount*count); err != nil {
-			return nil, 0, err
-		}
+	if count <= 0 {
+		return nil, 0, fmt.Errorf("invalid draw count %d", count)
 	}
 
-	// 3. 读取玩家抽卡记录
-	gachaData, err := s.playerRepo.GetGachaRecords(ctx, roleID)
-	if err != nil {
-		return nil, 0, err
-	}
-	record := s.getOrCreateRecord(gachaData, poolID, poolCfg.Type)
-	maxTrigger := s.GetMaxTriggerCount(poolID)
-
-	// 4. 执行循环抽取
-	var finalResults []*DropResult
-	for i := int32(0); i < count; i++ {
-		record.TotalCount++
-		record.LastTime = time.Now().Unix()
-
-		// 判定当前次数是否命中保底
-		dropID := poolCfg.DropId
-		if pityDropID := s.checkPity(poolID, record.TotalCount); pityDropID != 0 {
-			dropID = pityDropID
+	var (
+		finalResults []*DropResult
+		totalCount   int32
+	)
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		// 2. 扣除消耗
+		if poolCfg.CostItem > 0 && poolCfg.CostCount > 0 {
+			if err := s.bagSvc.ConsumeItem(ctx, roleID, poolCfg.CostItem, poolCfg.CostCount*count); err != nil {
+				return err
+			}
 		}
 
-		// 执行掉落库随机
-		results, err := s.dropSvc.ExecuteDrop(ctx, dropID)
+		// 3. 读取玩家抽卡记录
+		gachaData, err := s.playerRepo.GetGachaRecords(ctx, roleID)
 		if err != nil {
-			return nil, 0, err
+			return err
+		}
+		record := s.getOrCreateRecord(gachaData, poolID, poolCfg.Type)
+		now := s.now().Unix()
+		logs := make([]*model.GachaDrawLog, 0, count)
+
+		// 4. 执行循环抽取
+		for i := int32(0); i < count; i++ {
+			record.LastTime = now
+			dropID, isPity, pityCount := s.nextDraw(record, poolCfg)
+
+			// 执行掉落库随机，种子随流水保存以便重放
+			roll, err := s.dropSvc.Roll(ctx, dropID)
+			if err != nil {
+				return err
+			}
+			finalResults = append(finalResults, roll.Results...)
+			logs = append(logs, newGachaDrawLog(roleID, poolID, roll, isPity, pityCount, now))
+		}
+
+		// 5. 产出处理
+		if err := s.grantResults(ctx, roleID, finalResults); err != nil {
+			return err
 		}
-		finalResults = append(finalResults, results...)
 
-		// 只有达到最大保底次数时，才清零
-		if maxTrigger > 0 && record.TotalCount >= maxTrigger {
-			record.TotalCount = 0
+		// 6. 持久化记录与流水
+		if err := s.playerRepo.SaveGachaRecords(ctx, gachaData); err != nil {
+			return err
 		}
+		if err := s.playerRepo.AddGachaDrawLogs(ctx, logs); err != nil {
+			return err
+		}
+
+		totalCount = record.TotalCount
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
 	}
 
-	// 5. 产出处理
-	for _, res := range finalResults {
-		// 检查该物品是否为玩偶
-		if dollCfg := gameconfig.T.TbDoll.Get(res.ItemID); dollCfg != nil {
-			// 盲盒产出通常是一个个的玩偶实例
-			for c := int32(0); c < res.Count; c++ {
-				if _, err := s.dollSvc.AddDoll(ctx, roleID, res.ItemID); err != nil {
-					s.logger.Error("failed to add doll from gacha", "role_id", roleID, "doll_id", res.ItemID, "error", err)
-				}
-			}
-		} else {
-			// 普通道具入背包
-			if err := s.bagSvc.AddItem(ctx, roleID, res.ItemID, res.Count); err != nil {
-				s.logger.Error("failed to add item from gacha", "role_id", roleID, "item_id", res.ItemID, "error", err)
-			}
+	return finalResults, totalCount, nil
+}
+
+// grantResults 发放掉落产出：玩偶逐个创建实例，其余道具进背包
+func (s *GachaService) grantResults(ctx context.Context, roleID int64, results []*DropResult) error {
+	for _, res := range results {
+		if err := grantItem(ctx, s.dollSvc, s.bagSvc, roleID, res.ItemID, res.Count); err != nil {
+			return fmt.Errorf("failed to grant %d from gacha: %w", res.ItemID, err)
 		}
 	}
+	return nil
+}
 
-	// 6. 持久化记录
-	if err := s.playerRepo.SaveGachaRecords(ctx, gachaData); err != nil {
-		return nil, 0, err
+// nextDraw 推进一次抽取的保底计数，返回本次使用的掉落ID、是否触发保底以及本次的保底计数
+// 只有达到最大保底次数时计数才清零
+func (s *GachaService) nextDraw(record *model.GachaRecord, poolCfg *gameconfig.Gacha) (int32, bool, int32) {
+	record.TotalCount++
+	pityCount := record.TotalCount
+
+	// 判定当前次数是否命中保底
+	dropID := poolCfg.DropId
+	pityDropID := s.checkPity(poolCfg.Id, pityCount)
+	if pityDropID != 0 {
+		dropID = pityDropID
 	}
 
-	return finalResults, record.TotalCount, nil
+	if maxTrigger := s.GetMaxTriggerCount(poolCfg.Id); maxTrigger > 0 && record.TotalCount >= maxTrigger {
+		record.TotalCount = 0
+	}
+	return dropID, pityDropID != 0, pityCount
 }
 
 func (s *GachaService) checkPity(poolID int32, currentCount int32) int32 {
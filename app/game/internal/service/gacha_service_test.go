@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+const testRoleID int64 = 10001
+
+func newTestGachaService(t *testing.T) (*GachaService, *fakePlayerRepo, *fakeUnitOfWork) {
+	t.Helper()
+	setupTestConfig(t)
+
+	l := logger.Noop()
+	repo := newFakePlayerRepo()
+	uow := &fakeUnitOfWork{repo: repo}
+	svc := NewGachaService(l, repo, uow, NewDropService(l), NewDollService(l, repo, nil), NewBagService(l, &BagConfig{}, repo, uow))
+
+	// 初始给 10 个抽卡消耗道具
+	setBagCount(repo, gameconfig.BagType_Item, testCostItemID, 10)
+	return svc, repo, uow
+}
+
+// TestGachaDraw_Success 测试正常抽取：扣费、发奖、记录全部生效
+func TestGachaDraw_Success(t *testing.T) {
+	svc, repo, uow := newTestGachaService(t)
+
+	results, totalCount, err := svc.Draw(context.Background(), testRoleID, testPoolID, 2)
+	if err != nil {
+		t.Fatalf("Draw() error = %v", err)
+	}
+	if len(results) != 4 {
+		t.Errorf("Draw() results = %d, want 4", len(results))
+	}
+	if totalCount != 2 {
+		t.Errorf("Draw() totalCount = %d, want 2", totalCount)
+	}
+
+	wantBag := []string{"1001:8", "1002:10"}
+	if got := bagItems(repo, gameconfig.BagType_Item); !reflect.DeepEqual(got, wantBag) {
+		t.Errorf("bag = %v, want %v", got, wantBag)
+	}
+	if len(repo.state.dolls) != 2 {
+		t.Errorf("dolls = %d, want 2", len(repo.state.dolls))
+	}
+	if len(repo.state.gacha) != 1 || repo.state.gacha[0].TotalCount != 2 {
+		t.Errorf("gacha records = %+v, want one record with total_count=2", repo.state.gacha)
+	}
+	if uow.commits != 1 || uow.rollbacks != 0 {
+		t.Errorf("uow commits=%d rollbacks=%d, want 1/0", uow.commits, uow.rollbacks)
+	}
+}
+
+// TestGachaDraw_RollbackOnFailure 在每一个写步骤注入故障，验证抽取整体回滚
+func TestGachaDraw_RollbackOnFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		call   int
+	}{
+		{"consume cost", "SaveBag", 1},
+		{"load gacha records", "GetGachaRecords", 1},
+		{"grant doll", "AddDoll", 1},
+		{"grant item", "SaveBag", 2},
+		{"save gacha records", "SaveGachaRecords", 1},
+		{"save draw logs", "AddGachaDrawLogs", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo, uow := newTestGachaService(t)
+			before := repo.state.clone()
+			repo.failAt(tt.method, tt.call)
+
+			_, _, err := svc.Draw(context.Background(), testRoleID, testPoolID, 1)
+			if !errors.Is(err, errInjected) {
+				t.Fatalf("Draw() error = %v, want injected failure", err)
+			}
+
+			if !reflect.DeepEqual(repo.state, before) {
+				t.Errorf("state changed after rollback:\n got  %+v\n want %+v", repo.state, before)
+			}
+			if uow.rollbacks != 1 || uow.commits != 0 {
+				t.Errorf("uow commits=%d rollbacks=%d, want 0/1", uow.commits, uow.rollbacks)
+			}
+		})
+	}
+}
+
+// TestGachaDraw_RollbackOnDropFailure 掉落配置错误时不应扣除消耗
+func TestGachaDraw_RollbackOnDropFailure(t *testing.T) {
+	svc, repo, uow := newTestGachaService(t)
+	before := repo.state.clone()
+
+	if _, _, err := svc.Draw(context.Background(), testRoleID, testBrokenPoolID, 1); err == nil {
+		t.Fatal("Draw() expected error for missing drop config")
+	}
+	if !reflect.DeepEqual(repo.state, before) {
+		t.Errorf("state changed after rollback:\n got  %+v\n want %+v", repo.state, before)
+	}
+	if uow.rollbacks != 1 {
+		t.Errorf("uow rollbacks = %d, want 1", uow.rollbacks)
+	}
+}
+
+// TestGachaDraw_InsufficientCost 消耗不足时不产生任何变更
+func TestGachaDraw_InsufficientCost(t *testing.T) {
+	svc, repo, _ := newTestGachaService(t)
+	before := repo.state.clone()
+
+	if _, _, err := svc.Draw(context.Background(), testRoleID, testPoolID, 11); err == nil {
+		t.Fatal("Draw() expected insufficient item error")
+	}
+	if !reflect.DeepEqual(repo.state, before) {
+		t.Errorf("state changed:\n got  %+v\n want %+v", repo.state, before)
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	api "github.com/lk2023060901/xdooria-proto-api"
+	common "github.com/lk2023060901/xdooria-proto-common"
+	internal "github.com/lk2023060901/xdooria-proto-internal"
+	"github.com/lk2023060901/xdooria/app/game/internal/manager"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/network/framer"
+	grpcpkg "github.com/lk2023060901/xdooria/pkg/network/grpc"
+	"github.com/lk2023060901/xdooria/pkg/network/session"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeGateway Gateway 侧的流处理器，记录 Game 推送的消息
+type fakeGateway struct {
+	session.NopSessionHandler
+	received chan *common.Envelope
+}
+
+func (g *fakeGateway) OnMessage(s session.Session, env *common.Envelope) {
+	g.received <- env
+}
+
+// expectBroadcast 等待 Game 推送的广播并解析其中的客户端消息
+func (g *fakeGateway) expectBroadcast(t *testing.T, op api.OpCode, msg proto.Message) []int64 {
+	t.Helper()
+	select {
+	case env := <-g.received:
+		if env.Header.Op != uint32(internal.OpCode_OP_GAME_BROADCAST) {
+			t.Fatalf("gateway received op %d, want broadcast", env.Header.Op)
+		}
+		var req internal.BroadcastRequest
+		if err := proto.Unmarshal(env.Payload, &req); err != nil {
+			t.Fatalf("unmarshal broadcast: %v", err)
+		}
+		if req.Op != uint32(op) {
+			t.Fatalf("broadcast op %d, want %v", req.Op, op)
+		}
+		if err := proto.Unmarshal(req.Payload, msg); err != nil {
+			t.Fatalf("unmarshal %v: %v", op, err)
+		}
+		return slices.Sorted(slices.Values(req.RoleIds))
+	case <-time.After(time.Second):
+		t.Fatalf("gateway did not receive %v", op)
+	}
+	return nil
+}
+
+// expectNothing 确认 Game 没有推送任何消息
+func (g *fakeGateway) expectNothing(t *testing.T) {
+	t.Helper()
+	select {
+	case env := <-g.received:
+		t.Fatalf("unexpected message op %d", env.Header.Op)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// sendNotify 经流向 Game 发送上下线通知
+func sendNotify(t *testing.T, sess session.Session, op internal.OpCode, msg proto.Message) {
+	t.Helper()
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("marshal %v: %v", op, err)
+	}
+	env := &common.Envelope{Header: &common.MessageHeader{Op: uint32(op)}, Payload: payload}
+	if err := sess.Send(context.Background(), env); err != nil {
+		t.Fatalf("send %v: %v", op, err)
+	}
+}
+
+// waitConnected 等待 Game 处理完角色的上下线通知
+func waitConnected(t *testing.T, gatewayMgr *manager.GatewayManager, roleID int64, want bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for gatewayMgr.IsConnected(roleID) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("role %d connected = %v, want %v", roleID, !want, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// startGatewayStream 按 wire.go 的方式挂载 Game 的 Gateway 流，返回 Gateway 侧的流会话
+func startGatewayStream(t *testing.T, gatewayMgr *manager.GatewayManager, gw *fakeGateway) session.Session {
+	t.Helper()
+	fr, err := framer.New(&framer.Config{})
+	if err != nil {
+		t.Fatalf("create framer: %v", err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	common.RegisterCommonServiceServer(srv, grpcpkg.NewAcceptor(&session.Config{Framer: fr}, gatewayMgr))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///game",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial game: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	connector := grpcpkg.NewConnector(common.NewCommonServiceClient(conn), &session.Config{Framer: fr}, gw)
+	sess, err := connector.Connect(context.Background(), "game")
+	if err != nil {
+		t.Fatalf("connect game: %v", err)
+	}
+	t.Cleanup(func() { sess.Close() })
+	return sess
+}
+
+func TestGatewayStream_SceneNotifiesReachGateway(t *testing.T) {
+	gatewayMgr := manager.NewGatewayManager(logger.Noop())
+	roles := fakeRoles{
+		1: &model.Role{ID: 1, Nickname: "role", Level: 1},
+		2: &model.Role{ID: 2, Nickname: "role", Level: 1},
+	}
+	cfg := testSceneConfig()
+	svc := newSceneService(logger.Noop(), cfg, roles, manager.NewSceneManager(logger.Noop(), &cfg.AOI),
+		&gatewayNotifier{gatewayMgr: gatewayMgr}, nil)
+	gatewayMgr.OnRoleOffline(svc.handleRoleOffline)
+
+	gw := &fakeGateway{received: make(chan *common.Envelope, 16)}
+	stream := startGatewayStream(t, gatewayMgr, gw)
+
+	// Gateway 通知角色上线后，Game 才能向其推送
+	for _, id := range []int64{1, 2} {
+		sendNotify(t, stream, internal.OpCode_OP_GATEWAY_PLAYER_ONLINE, &internal.PlayerOnlineNotify{
+			RoleId:    id,
+			Uid:       1000 + id,
+			GatewayId: "gw-1",
+			SessionId: "s1",
+			ZoneId:    1,
+		})
+		waitConnected(t, gatewayMgr, id, true)
+	}
+
+	// 进入场景的广播经流到达 Gateway
+	mustEnterScene(t, svc, 1, 0)
+	gw.expectNothing(t)
+	mustEnterScene(t, svc, 2, 0)
+	var enter api.ScenePlayerEnterNotify
+	if ids := gw.expectBroadcast(t, api.OpCode_OP_SCENE_PLAYER_ENTER_NOTIFY, &enter); !slices.Equal(ids, []int64{1}) {
+		t.Fatalf("enter broadcast role_ids = %v, want [1]", ids)
+	}
+	if len(enter.Players) != 1 || enter.Players[0].RoleId != 2 {
+		t.Fatalf("enter notify players = %+v", enter.Players)
+	}
+
+	// 角色 2 已恢复到新会话，旧会话迟到的下线通知不解绑
+	sendNotify(t, stream, internal.OpCode_OP_GATEWAY_PLAYER_ONLINE, &internal.PlayerOnlineNotify{
+		RoleId: 2, Uid: 1002, GatewayId: "gw-1", SessionId: "s2", ZoneId: 1,
+	})
+	sendNotify(t, stream, internal.OpCode_OP_GATEWAY_PLAYER_OFFLINE, &internal.PlayerOfflineNotify{
+		RoleId: 2, GatewayId: "gw-1", SessionId: "s1",
+	})
+	gw.expectNothing(t)
+	waitConnected(t, gatewayMgr, 2, true)
+
+	// 当前会话下线后离开场景，其他玩家收到离开通知
+	sendNotify(t, stream, internal.OpCode_OP_GATEWAY_PLAYER_OFFLINE, &internal.PlayerOfflineNotify{
+		RoleId: 2, GatewayId: "gw-1", SessionId: "s2",
+	})
+	var leave api.ScenePlayerLeaveNotify
+	if ids := gw.expectBroadcast(t, api.OpCode_OP_SCENE_PLAYER_LEAVE_NOTIFY, &leave); !slices.Equal(ids, []int64{1}) {
+		t.Fatalf("leave broadcast role_ids = %v, want [1]", ids)
+	}
+	waitConnected(t, gatewayMgr, 2, false)
+}
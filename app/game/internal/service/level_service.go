@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	api "github.com/lk2023060901/xdooria-proto-api"
+	"github.com/lk2023060901/xdooria/app/game/internal/levelconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/manager"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/app/game/internal/repository"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"google.golang.org/protobuf/proto"
+)
+
+// 等级业务错误
+var (
+	ErrLevelInvalidExp     = errors.New("invalid exp amount")
+	ErrLevelConfigNotFound = errors.New("role level config not found")
+)
+
+// 背包已满时升级奖励改由邮件发放
+const (
+	levelRewardMailSender  = "系统"
+	levelRewardMailTitle   = "升级奖励"
+	levelRewardMailContent = "恭喜升到 %d 级！由于背包已满，升级奖励通过邮件发放，请及时领取。"
+)
+
+// LevelReward 升级奖励
+type LevelReward struct {
+	ItemID int32
+	Count  int32
+}
+
+// LevelUpEvent 经验变更结果（等级或 VIP 等级提升时即为升级事件）
+type LevelUpEvent struct {
+	RoleID       int64
+	Reason       string
+	OldLevel     int32
+	Level        int32
+	Exp          int64 // 当前等级内的经验值
+	VIPExp       int64 // 累计总经验值
+	OldVIPLevel  int32
+	VIPLevel     int32
+	Rewards      []*LevelReward // 本次升级获得的奖励（按升级顺序，未合并）
+	RewardByMail bool           // 背包已满，奖励已通过邮件发放
+}
+
+// LeveledUp 是否提升了等级或 VIP 等级
+func (e *LevelUpEvent) LeveledUp() bool {
+	return e.Level > e.OldLevel || e.VIPLevel > e.OldVIPLevel
+}
+
+// roleStateUpdater 在线角色状态更新（由 manager.RoleManager 实现）
+type roleStateUpdater interface {
+	UpdateRoleState(roleID int64, updateFunc func(*model.Role)) error
+}
+
+// clientPusher 向客户端推送消息（由 MessageService 经 Gateway 流实现）
+type clientPusher interface {
+	PushToRole(ctx context.Context, roleID int64, opCode uint32, msg proto.Message) error
+}
+
+// LevelService 角色等级服务：经验获取、升级、VIP 等级计算与升级奖励
+type LevelService struct {
+	logger   logger.Logger
+	roleRepo repository.RoleRepository
+	uow      repository.UnitOfWork
+	roles    roleStateUpdater
+	dollSvc  *DollService
+	bagSvc   *BagService
+	mailSvc  *MailService
+	pusher   clientPusher
+}
+
+// NewLevelService 创建等级服务
+func NewLevelService(
+	l logger.Logger,
+	roleRepo repository.RoleRepository,
+	uow repository.UnitOfWork,
+	roleMgr *manager.RoleManager,
+	dollSvc *DollService,
+	bagSvc *BagService,
+	mailSvc *MailService,
+	messageSvc *MessageService,
+) *LevelService {
+	return newLevelService(l, roleRepo, uow, roleMgr, dollSvc, bagSvc, mailSvc, messageSvc)
+}
+
+func newLevelService(
+	l logger.Logger,
+	roleRepo repository.RoleRepository,
+	uow repository.UnitOfWork,
+	roles roleStateUpdater,
+	dollSvc *DollService,
+	bagSvc *BagService,
+	mailSvc *MailService,
+	pusher clientPusher,
+) *LevelService {
+	return &LevelService{
+		logger:   l.Named("service.level"),
+		roleRepo: roleRepo,
+		uow:      uow,
+		roles:    roles,
+		dollSvc:  dollSvc,
+		bagSvc:   bagSvc,
+		mailSvc:  mailSvc,
+		pusher:   pusher,
+	}
+}
+
+// AddExp 为角色增加经验，可连续升级；升级奖励与等级变更在同一事务内发放，
+// 背包放不下时奖励改为邮件发放，经验不会因此丢失。等级变化后推送 OP_ROLE_LEVEL_UP_NOTIFY
+// 角色离线时同样生效（直接写库），在线时同步内存中的角色数据
+func (s *LevelService) AddExp(ctx context.Context, roleID int64, exp int64, reason string) (*LevelUpEvent, error) {
+	if exp <= 0 {
+		return nil, fmt.Errorf("%w: %d", ErrLevelInvalidExp, exp)
+	}
+
+	event, growth, err := s.addExp(ctx, roleID, exp, false)
+	if errors.Is(err, ErrBagFull) {
+		s.logger.Info("bag full, level rewards will be sent by mail", "role_id", roleID)
+		event, growth, err = s.addExp(ctx, roleID, exp, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+	event.Reason = reason
+
+	// 同步在线角色的内存数据（角色不在内存中时忽略）
+	_ = s.roles.UpdateRoleState(roleID, func(role *model.Role) {
+		role.ApplyGrowth(growth)
+	})
+
+	if !event.LeveledUp() {
+		return event, nil
+	}
+
+	s.logger.Info("role leveled up",
+		"role_id", roleID,
+		"reason", reason,
+		"old_level", event.OldLevel,
+		"level", event.Level,
+		"old_vip_level", event.OldVIPLevel,
+		"vip_level", event.VIPLevel,
+		"rewards", len(event.Rewards),
+		"reward_by_mail", event.RewardByMail,
+	)
+
+	s.notifyLevelUp(ctx, event)
+	return event, nil
+}
+
+// addExp 在事务中读取（并锁定）等级数据、结算升级并发放奖励
+func (s *LevelService) addExp(ctx context.Context, roleID int64, exp int64, byMail bool) (*LevelUpEvent, *model.RoleGrowth, error) {
+	var (
+		event  *LevelUpEvent
+		growth *model.RoleGrowth
+	)
+
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		g, err := s.roleRepo.GetRoleGrowth(ctx, roleID)
+		if err != nil {
+			return err
+		}
+
+		var levels []int32
+		event, growth, levels, err = applyExp(roleID, g, exp)
+		if err != nil {
+			return err
+		}
+
+		if err := s.roleRepo.SaveRoleGrowth(ctx, roleID, growth); err != nil {
+			return err
+		}
+
+		event.RewardByMail = byMail
+		for _, level := range levels {
+			rewards := levelRewards(level)
+			event.Rewards = append(event.Rewards, rewards...)
+			if err := s.grantLevelRewards(ctx, roleID, level, rewards, byMail); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to add exp: %w", err)
+	}
+
+	return event, growth, nil
+}
+
+// grantLevelRewards 发放某一等级的升级奖励（道具进背包、玩偶创建实例，或整体邮件发放）
+func (s *LevelService) grantLevelRewards(ctx context.Context, roleID int64, level int32, rewards []*LevelReward, byMail bool) error {
+	if len(rewards) == 0 {
+		return nil
+	}
+
+	if byMail {
+		attachments := make([]*model.MailAttachment, 0, len(rewards))
+		for _, r := range rewards {
+			attachments = append(attachments, &model.MailAttachment{ItemID: r.ItemID, Count: r.Count})
+		}
+		_, err := s.mailSvc.SendMail(ctx, []int64{roleID}, &MailDraft{
+			Sender:      levelRewardMailSender,
+			Title:       levelRewardMailTitle,
+			Content:     fmt.Sprintf(levelRewardMailContent, level),
+			Attachments: attachments,
+		})
+		return err
+	}
+
+	for _, r := range rewards {
+		if err := grantItem(ctx, s.dollSvc, s.bagSvc, roleID, r.ItemID, r.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyLevelUp 推送升级通知，角色不在线时推送失败只记录日志
+func (s *LevelService) notifyLevelUp(ctx context.Context, event *LevelUpEvent) {
+	rewards := make([]*api.LevelReward, 0, len(event.Rewards))
+	for _, r := range event.Rewards {
+		rewards = append(rewards, &api.LevelReward{ItemId: r.ItemID, Count: r.Count})
+	}
+
+	notify := &api.RoleLevelUpNotify{
+		OldLevel:    event.OldLevel,
+		Level:       event.Level,
+		Exp:         event.Exp,
+		VipExp:      event.VIPExp,
+		OldVipLevel: event.OldVIPLevel,
+		VipLevel:    event.VIPLevel,
+		Rewards:     rewards,
+	}
+
+	if err := s.pusher.PushToRole(ctx, event.RoleID, uint32(api.OpCode_OP_ROLE_LEVEL_UP_NOTIFY), notify); err != nil {
+		s.logger.Debug("level up notify not delivered", "role_id", event.RoleID, "error", err)
+	}
+}
+
+// applyExp 按经验曲线结算经验，返回结算结果、新的等级数据与依次升到的等级
+// 达到满级（经验配置为 0 或没有下一级配置）后当前等级经验清零，累计经验仍然增加
+func applyExp(roleID int64, g *model.RoleGrowth, exp int64) (*LevelUpEvent, *model.RoleGrowth, []int32, error) {
+	if levelconfig.T.RoleLevel(g.Level) == nil {
+		return nil, nil, nil, fmt.Errorf("%w: level %d", ErrLevelConfigNotFound, g.Level)
+	}
+
+	next := &model.RoleGrowth{
+		Level:  g.Level,
+		Exp:    g.Exp + exp,
+		VIPExp: g.VIPExp + exp,
+	}
+
+	var levels []int32
+	for {
+		cfg := levelconfig.T.RoleLevel(next.Level)
+		if cfg.Exp <= 0 || levelconfig.T.RoleLevel(next.Level+1) == nil {
+			next.Exp = 0
+			break
+		}
+		if next.Exp < cfg.Exp {
+			break
+		}
+		next.Exp -= cfg.Exp
+		next.Level++
+		levels = append(levels, next.Level)
+	}
+
+	event := &LevelUpEvent{
+		RoleID:      roleID,
+		OldLevel:    g.Level,
+		Level:       next.Level,
+		Exp:         next.Exp,
+		VIPExp:      next.VIPExp,
+		OldVIPLevel: vipLevel(g.VIPExp),
+		VIPLevel:    vipLevel(next.VIPExp),
+	}
+	return event, next, levels, nil
+}
+
+// levelRewards 获取升到指定等级时的奖励
+func levelRewards(level int32) []*LevelReward {
+	cfg := levelconfig.T.RoleLevel(level)
+	if cfg == nil {
+		return nil
+	}
+
+	n := min(len(cfg.RewardItemIds), len(cfg.RewardCounts))
+	rewards := make([]*LevelReward, 0, n)
+	for i := 0; i < n; i++ {
+		if cfg.RewardCounts[i] <= 0 {
+			continue
+		}
+		rewards = append(rewards, &LevelReward{ItemID: cfg.RewardItemIds[i], Count: cfg.RewardCounts[i]})
+	}
+	return rewards
+}
+
+// vipLevel 累计经验达到的最高 VIP 等级
+func vipLevel(vipExp int64) int32 {
+	var level int32
+	for _, cfg := range levelconfig.T.VipLevels() {
+		if vipExp >= cfg.VipExp && cfg.Id > level {
+			level = cfg.Id
+		}
+	}
+	return level
+}
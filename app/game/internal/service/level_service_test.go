@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	api "github.com/lk2023060901/xdooria-proto-api"
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/levelconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"google.golang.org/protobuf/proto"
+)
+
+// testMaxLevel 测试经验曲线的满级
+const testMaxLevel = 4
+
+// pushedMessage 推送记录
+type pushedMessage struct {
+	roleID int64
+	opCode uint32
+	msg    proto.Message
+}
+
+// fakePusher 记录推送的消息，只有 online 中的角色能收到
+type fakePusher struct {
+	online map[int64]bool
+	pushed []pushedMessage
+}
+
+func (p *fakePusher) PushToRole(ctx context.Context, roleID int64, opCode uint32, msg proto.Message) error {
+	if !p.online[roleID] {
+		return errors.New("role not connected")
+	}
+	p.pushed = append(p.pushed, pushedMessage{roleID: roleID, opCode: opCode, msg: msg})
+	return nil
+}
+
+func testLevelConfig(level int32, exp int64, rewardItemIDs []int32, rewardCounts []int32) *levelconfig.RoleLevel {
+	return &levelconfig.RoleLevel{Id: level, Exp: exp, RewardItemIds: rewardItemIDs, RewardCounts: rewardCounts}
+}
+
+func newTestLevelService(t *testing.T) (*LevelService, *fakePlayerRepo, *fakeRoleRepo, *fakeMailRepo, fakeRoles, *fakePusher) {
+	t.Helper()
+	setupTestConfig(t)
+
+	// 1 -> 2 需要 100，2 -> 3 需要 200，3 -> 4 需要 300，4 级满级
+	levelconfig.T = levelconfig.NewTables([]*levelconfig.RoleLevel{
+		testLevelConfig(1, 100, nil, nil),
+		testLevelConfig(2, 200, []int32{testRewardItemID}, []int32{2}),
+		testLevelConfig(3, 300, []int32{testDollID, testRewardItemID}, []int32{1, 3}),
+		testLevelConfig(testMaxLevel, 0, []int32{testRewardItemID}, []int32{4}),
+	}, []*levelconfig.VipLevel{
+		{Id: 0, VipExp: 0},
+		{Id: 1, VipExp: 300},
+		{Id: 2, VipExp: 1000},
+	})
+
+	l := logger.Noop()
+	repo := newFakePlayerRepo()
+	roleRepo := newFakeRoleRepo()
+	mailRepo := newFakeMailRepo()
+	uow := &fakeUnitOfWork{repo: repo, mail: mailRepo, role: roleRepo}
+
+	roleRepo.growth[testRoleID] = model.RoleGrowth{Level: 1}
+	roleRepo.growth[testOtherRoleID] = model.RoleGrowth{Level: 1}
+	roles := fakeRoles{testRoleID: {ID: testRoleID, Level: 1}}
+	pusher := &fakePusher{online: map[int64]bool{testRoleID: true}}
+
+	dollSvc := NewDollService(l, repo, nil)
+	bagSvc := NewBagService(l, &BagConfig{}, repo, uow)
+	mailSvc := newMailService(l, &MailConfig{}, mailRepo, uow, roles, dollSvc, bagSvc)
+	svc := newLevelService(l, roleRepo, uow, roles, dollSvc, bagSvc, mailSvc, pusher)
+	return svc, repo, roleRepo, mailRepo, roles, pusher
+}
+
+// TestLevelAddExp_MultiLevelUp 测试一次获得大量经验连续升级、逐级发放奖励、同步内存角色并推送通知
+func TestLevelAddExp_MultiLevelUp(t *testing.T) {
+	svc, repo, roleRepo, _, roles, pusher := newTestLevelService(t)
+	ctx := context.Background()
+
+	event, err := svc.AddExp(ctx, testRoleID, 350, "quest")
+	if err != nil {
+		t.Fatalf("AddExp() error = %v", err)
+	}
+
+	// 350 = 100 (1->2) + 200 (2->3) + 50
+	want := model.RoleGrowth{Level: 3, Exp: 50, VIPExp: 350}
+	if got := roleRepo.growth[testRoleID]; got != want {
+		t.Fatalf("saved growth = %+v, want %+v", got, want)
+	}
+	if event.OldLevel != 1 || event.Level != 3 || event.OldVIPLevel != 0 || event.VIPLevel != 1 || event.Reason != "quest" {
+		t.Errorf("AddExp() event = %+v", event)
+	}
+	wantRewards := []*LevelReward{
+		{ItemID: testRewardItemID, Count: 2},
+		{ItemID: testDollID, Count: 1},
+		{ItemID: testRewardItemID, Count: 3},
+	}
+	if !reflect.DeepEqual(event.Rewards, wantRewards) {
+		t.Errorf("AddExp() rewards = %+v, want %+v", event.Rewards, wantRewards)
+	}
+
+	// 道具进背包、玩偶创建实例
+	if got := bagItems(repo, gameconfig.BagType_Item); !reflect.DeepEqual(got, []string{"1002:5"}) {
+		t.Errorf("bag items = %v, want [1002:5]", got)
+	}
+	if len(repo.state.dolls) != 1 || repo.state.dolls[0].DollID != testDollID {
+		t.Errorf("dolls = %+v, want one doll %d", repo.state.dolls, testDollID)
+	}
+
+	if role := roles[testRoleID]; role.Level != 3 || role.Exp != 50 || role.VIPExp != 350 {
+		t.Errorf("in-memory role = level %d exp %d vip_exp %d, want 3/50/350", role.Level, role.Exp, role.VIPExp)
+	}
+
+	if len(pusher.pushed) != 1 {
+		t.Fatalf("pushed %d messages, want 1", len(pusher.pushed))
+	}
+	pushed := pusher.pushed[0]
+	notify, ok := pushed.msg.(*api.RoleLevelUpNotify)
+	if !ok || pushed.roleID != testRoleID || pushed.opCode != uint32(api.OpCode_OP_ROLE_LEVEL_UP_NOTIFY) {
+		t.Fatalf("pushed = %+v, want RoleLevelUpNotify to role %d", pushed, testRoleID)
+	}
+	if notify.OldLevel != 1 || notify.Level != 3 || notify.VipLevel != 1 || len(notify.Rewards) != 3 {
+		t.Errorf("notify = level %d -> %d, vip %d, %d rewards", notify.OldLevel, notify.Level, notify.VipLevel, len(notify.Rewards))
+	}
+
+	// 未升级时不推送
+	if event, err := svc.AddExp(ctx, testRoleID, 10, "quest"); err != nil || event.LeveledUp() {
+		t.Fatalf("AddExp(10) = %+v, %v; want no level up", event, err)
+	}
+	if len(pusher.pushed) != 1 {
+		t.Errorf("pushed %d messages after small exp gain, want 1", len(pusher.pushed))
+	}
+}
+
+// TestLevelAddExp_MaxLevel 测试满级后等级经验清零、累计经验继续增加并提升 VIP 等级
+func TestLevelAddExp_MaxLevel(t *testing.T) {
+	svc, _, roleRepo, _, _, pusher := newTestLevelService(t)
+	ctx := context.Background()
+
+	event, err := svc.AddExp(ctx, testRoleID, 900, "quest")
+	if err != nil {
+		t.Fatalf("AddExp() error = %v", err)
+	}
+	if want := (model.RoleGrowth{Level: testMaxLevel, Exp: 0, VIPExp: 900}); roleRepo.growth[testRoleID] != want {
+		t.Fatalf("saved growth = %+v, want %+v", roleRepo.growth[testRoleID], want)
+	}
+	if len(event.Rewards) != 4 {
+		t.Errorf("AddExp() rewards = %d, want 4 (levels 2-4)", len(event.Rewards))
+	}
+
+	// 满级后只有 VIP 等级变化
+	event, err = svc.AddExp(ctx, testRoleID, 200, "quest")
+	if err != nil {
+		t.Fatalf("AddExp() error = %v", err)
+	}
+	if event.Level != testMaxLevel || event.Exp != 0 || event.VIPExp != 1100 || event.OldVIPLevel != 1 || event.VIPLevel != 2 {
+		t.Errorf("AddExp() at max level event = %+v", event)
+	}
+	if len(event.Rewards) != 0 {
+		t.Errorf("AddExp() at max level rewards = %+v, want none", event.Rewards)
+	}
+	if len(pusher.pushed) != 2 {
+		t.Errorf("pushed %d messages, want 2 (level up and vip level up)", len(pusher.pushed))
+	}
+}
+
+// TestLevelAddExp_BagFullSendsMail 测试背包放不下升级奖励时经验照常结算，奖励改为按等级发送邮件
+func TestLevelAddExp_BagFullSendsMail(t *testing.T) {
+	svc, repo, roleRepo, mailRepo, _, _ := newTestLevelService(t)
+	ctx := context.Background()
+	svc.bagSvc.cfg = &BagConfig{Capacity: map[int32]int32{gameconfig.BagType_Item: 1}}
+	setBagCount(repo, gameconfig.BagType_Item, testCostItemID, 1)
+
+	before := repo.state.clone()
+	event, err := svc.AddExp(ctx, testRoleID, 300, "quest")
+	if err != nil {
+		t.Fatalf("AddExp() error = %v", err)
+	}
+	if !event.RewardByMail || event.Level != 3 {
+		t.Fatalf("AddExp() event = %+v, want level 3 with rewards by mail", event)
+	}
+	if want := (model.RoleGrowth{Level: 3, Exp: 0, VIPExp: 300}); roleRepo.growth[testRoleID] != want {
+		t.Errorf("saved growth = %+v, want %+v", roleRepo.growth[testRoleID], want)
+	}
+	if !reflect.DeepEqual(repo.state, before) {
+		t.Error("AddExp() granted items although bag was full")
+	}
+
+	mails := mailRepo.state.mails
+	if len(mails) != 2 {
+		t.Fatalf("sent %d mails, want 2 (one per level)", len(mails))
+	}
+	wantAttachments := [][]*model.MailAttachment{
+		{{ItemID: testRewardItemID, Count: 2}},
+		{{ItemID: testDollID, Count: 1}, {ItemID: testRewardItemID, Count: 3}},
+	}
+	for i, mail := range mails {
+		if mail.RoleID != testRoleID || !reflect.DeepEqual(mail.Attachments, wantAttachments[i]) {
+			t.Errorf("mail[%d] = role %d attachments %+v, want %+v", i, mail.RoleID, mail.Attachments, wantAttachments[i])
+		}
+	}
+}
+
+// TestLevelAddExp_Offline 测试离线角色同样可以获得经验，推送失败不影响结果
+func TestLevelAddExp_Offline(t *testing.T) {
+	svc, _, roleRepo, _, _, pusher := newTestLevelService(t)
+	ctx := context.Background()
+
+	event, err := svc.AddExp(ctx, testOtherRoleID, 150, "compensation")
+	if err != nil {
+		t.Fatalf("AddExp() error = %v", err)
+	}
+	if !event.LeveledUp() || roleRepo.growth[testOtherRoleID].Level != 2 {
+		t.Errorf("AddExp() offline event = %+v, saved %+v", event, roleRepo.growth[testOtherRoleID])
+	}
+	if len(pusher.pushed) != 0 {
+		t.Errorf("pushed %d messages to offline role", len(pusher.pushed))
+	}
+}
+
+// TestLevelAddExp_Invalid 测试非法经验与缺失等级配置
+func TestLevelAddExp_Invalid(t *testing.T) {
+	svc, _, roleRepo, _, _, _ := newTestLevelService(t)
+	ctx := context.Background()
+
+	for _, exp := range []int64{0, -10} {
+		if _, err := svc.AddExp(ctx, testRoleID, exp, "quest"); !errors.Is(err, ErrLevelInvalidExp) {
+			t.Errorf("AddExp(%d) error = %v, want ErrLevelInvalidExp", exp, err)
+		}
+	}
+
+	roleRepo.growth[testRoleID] = model.RoleGrowth{Level: 99}
+	if _, err := svc.AddExp(ctx, testRoleID, 10, "quest"); !errors.Is(err, ErrLevelConfigNotFound) {
+		t.Errorf("AddExp() with unknown level error = %v, want ErrLevelConfigNotFound", err)
+	}
+	if got := roleRepo.growth[testRoleID]; got.Level != 99 || got.VIPExp != 0 {
+		t.Errorf("failed AddExp() changed growth to %+v", got)
+	}
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// MailPurgeJobName 过期邮件清理任务名称
+const MailPurgeJobName = "mail.purge_expired"
+
+// MailPurgeJob 定时物理删除已过期的邮件（实现 scheduler.Job）
+type MailPurgeJob struct {
+	logger  logger.Logger
+	mailSvc *MailService
+}
+
+// NewMailPurgeJob 创建过期邮件清理任务
+func NewMailPurgeJob(l logger.Logger, mailSvc *MailService) *MailPurgeJob {
+	return &MailPurgeJob{
+		logger:  l.Named("job.mail_purge"),
+		mailSvc: mailSvc,
+	}
+}
+
+// Name 任务名称
+func (j *MailPurgeJob) Name() string {
+	return MailPurgeJobName
+}
+
+// Run 清理过期邮件
+func (j *MailPurgeJob) Run() error {
+	deleted, err := j.mailSvc.PurgeExpiredMails(context.Background())
+	if err != nil {
+		j.logger.Error("failed to purge expired mails", "error", err)
+		return err
+	}
+
+	if deleted > 0 {
+		j.logger.Info("expired mails purged", "count", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,499 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/manager"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/app/game/internal/repository"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// 邮件业务错误，Handler 据此映射错误码
+var (
+	ErrMailNotFound       = errors.New("mail not found")
+	ErrMailExpired        = errors.New("mail expired")
+	ErrMailNoAttachment   = errors.New("mail has no attachment")
+	ErrMailAlreadyClaimed = errors.New("mail attachment already claimed")
+	ErrMailNotClaimed     = errors.New("mail attachment not claimed")
+	ErrMailRoleOffline    = errors.New("role is not online")
+	ErrMailInvalid        = errors.New("invalid mail")
+)
+
+// 邮件默认配置
+const (
+	defaultMailExpire         = 30 * 24 * time.Hour
+	defaultMailboxSize        = 100
+	defaultMailMaxAttachments = 10
+)
+
+// MailConfig 邮件配置
+type MailConfig struct {
+	// DefaultExpire 未指定过期时间的邮件有效期，未配置时为 30 天
+	DefaultExpire time.Duration `mapstructure:"default_expire"`
+
+	// MailboxSize 邮箱展示的邮件数量上限（按发送时间保留最新的），未配置时为 100
+	MailboxSize int `mapstructure:"mailbox_size"`
+
+	// MaxAttachments 单封邮件的附件数量上限，未配置时为 10
+	MaxAttachments int `mapstructure:"max_attachments"`
+
+	// PurgeSpec 过期邮件清理任务的 Cron 表达式，为空时不启用（过期邮件仍不会展示、不可领取）
+	PurgeSpec string `mapstructure:"purge_spec"`
+}
+
+// MailDraft 待发送的邮件内容
+type MailDraft struct {
+	Sender      string
+	Title       string
+	Content     string
+	Attachments []*model.MailAttachment
+	ExpireTime  int64 // 过期时间 (Unix)，0 表示使用默认有效期
+}
+
+// MailClaimResult 领取结果
+type MailClaimResult struct {
+	MailIDs []int64                 // 成功领取的邮件
+	Rewards []*model.MailAttachment // 获得的物品（按物品ID合并）
+}
+
+// MailService 邮件服务
+type MailService struct {
+	logger   logger.Logger
+	cfg      *MailConfig
+	mailRepo repository.MailRepository
+	uow      repository.UnitOfWork
+	roles    roleGetter
+	dollSvc  *DollService
+	bagSvc   *BagService
+	now      func() time.Time
+}
+
+// NewMailService 创建邮件服务
+func NewMailService(
+	l logger.Logger,
+	cfg *MailConfig,
+	mailRepo repository.MailRepository,
+	uow repository.UnitOfWork,
+	roleMgr *manager.RoleManager,
+	dollSvc *DollService,
+	bagSvc *BagService,
+) *MailService {
+	return newMailService(l, cfg, mailRepo, uow, roleMgr, dollSvc, bagSvc)
+}
+
+func newMailService(
+	l logger.Logger,
+	cfg *MailConfig,
+	mailRepo repository.MailRepository,
+	uow repository.UnitOfWork,
+	roles roleGetter,
+	dollSvc *DollService,
+	bagSvc *BagService,
+) *MailService {
+	if cfg == nil {
+		cfg = &MailConfig{}
+	}
+
+	return &MailService{
+		logger:   l.Named("service.mail"),
+		cfg:      cfg,
+		mailRepo: mailRepo,
+		uow:      uow,
+		roles:    roles,
+		dollSvc:  dollSvc,
+		bagSvc:   bagSvc,
+		now:      time.Now,
+	}
+}
+
+// ============ 发送（运营后台） ============
+
+// SendMail 向指定角色发送系统邮件，角色离线时同样写入邮箱，返回发送的邮件数量
+func (s *MailService) SendMail(ctx context.Context, roleIDs []int64, draft *MailDraft) (int, error) {
+	now := s.now().Unix()
+	if err := s.validateDraft(draft, now); err != nil {
+		return 0, err
+	}
+
+	roleIDs = uniqueRoleIDs(roleIDs)
+	if len(roleIDs) == 0 {
+		return 0, fmt.Errorf("%w: no recipient", ErrMailInvalid)
+	}
+
+	expireTime := s.expireTime(draft, now)
+	mails := make([]*model.Mail, 0, len(roleIDs))
+	for _, roleID := range roleIDs {
+		mails = append(mails, &model.Mail{
+			RoleID:      roleID,
+			MailType:    model.MailTypeSystem,
+			Sender:      draft.Sender,
+			Title:       draft.Title,
+			Content:     draft.Content,
+			Attachments: draft.Attachments,
+			SendTime:    now,
+			ExpireTime:  expireTime,
+		})
+	}
+
+	if err := s.mailRepo.AddMails(ctx, mails); err != nil {
+		return 0, fmt.Errorf("failed to send mail: %w", err)
+	}
+
+	s.logger.Info("mail sent",
+		"title", draft.Title,
+		"recipients", len(mails),
+		"attachments", len(draft.Attachments),
+	)
+
+	return len(mails), nil
+}
+
+// SendGlobalMail 发送全服邮件，角色拉取邮箱时按投放目标投递
+// 发送之后创建的角色不会收到
+func (s *MailService) SendGlobalMail(ctx context.Context, draft *MailDraft, target model.MailTarget) (*model.GlobalMail, error) {
+	now := s.now().Unix()
+	if err := s.validateDraft(draft, now); err != nil {
+		return nil, err
+	}
+	if err := validateMailTarget(&target); err != nil {
+		return nil, err
+	}
+
+	mail := &model.GlobalMail{
+		Sender:      draft.Sender,
+		Title:       draft.Title,
+		Content:     draft.Content,
+		Attachments: draft.Attachments,
+		Target:      target,
+		SendTime:    now,
+		ExpireTime:  s.expireTime(draft, now),
+	}
+
+	if err := s.mailRepo.AddGlobalMail(ctx, mail); err != nil {
+		return nil, fmt.Errorf("failed to send global mail: %w", err)
+	}
+
+	s.logger.Info("global mail sent",
+		"global_id", mail.ID,
+		"title", mail.Title,
+		"target_type", target.Type,
+		"attachments", len(draft.Attachments),
+	)
+
+	return mail, nil
+}
+
+// validateDraft 校验邮件内容与附件
+func (s *MailService) validateDraft(draft *MailDraft, now int64) error {
+	if draft == nil || strings.TrimSpace(draft.Title) == "" {
+		return fmt.Errorf("%w: empty title", ErrMailInvalid)
+	}
+	if draft.ExpireTime != 0 && draft.ExpireTime <= now {
+		return fmt.Errorf("%w: expire time %d is in the past", ErrMailInvalid, draft.ExpireTime)
+	}
+
+	maxAttachments := s.cfg.MaxAttachments
+	if maxAttachments <= 0 {
+		maxAttachments = defaultMailMaxAttachments
+	}
+	if len(draft.Attachments) > maxAttachments {
+		return fmt.Errorf("%w: %d attachments exceed limit %d", ErrMailInvalid, len(draft.Attachments), maxAttachments)
+	}
+
+	for _, a := range draft.Attachments {
+		if a == nil || a.Count <= 0 {
+			return fmt.Errorf("%w: invalid attachment %+v", ErrMailInvalid, a)
+		}
+		if gameconfig.T.TbItem.Get(a.ItemID) == nil && gameconfig.T.TbDoll.Get(a.ItemID) == nil {
+			return fmt.Errorf("%w: attachment item %d not found", ErrMailInvalid, a.ItemID)
+		}
+	}
+	return nil
+}
+
+// validateMailTarget 校验全服邮件投放目标
+func validateMailTarget(target *model.MailTarget) error {
+	switch target.Type {
+	case model.MailTargetAll:
+		return nil
+	case model.MailTargetLevel:
+		if target.MinLevel < 0 || (target.MaxLevel != 0 && target.MaxLevel < target.MinLevel) {
+			return fmt.Errorf("%w: invalid level range [%d, %d]", ErrMailInvalid, target.MinLevel, target.MaxLevel)
+		}
+		return nil
+	case model.MailTargetRoles:
+		target.RoleIDs = uniqueRoleIDs(target.RoleIDs)
+		if len(target.RoleIDs) == 0 {
+			return fmt.Errorf("%w: no target role", ErrMailInvalid)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unknown target type %d", ErrMailInvalid, target.Type)
+}
+
+// expireTime 计算邮件过期时间
+func (s *MailService) expireTime(draft *MailDraft, now int64) int64 {
+	if draft.ExpireTime > 0 {
+		return draft.ExpireTime
+	}
+
+	expire := s.cfg.DefaultExpire
+	if expire <= 0 {
+		expire = defaultMailExpire
+	}
+	return now + int64(expire/time.Second)
+}
+
+// uniqueRoleIDs 去除无效与重复的角色ID，保持原有顺序
+func uniqueRoleIDs(roleIDs []int64) []int64 {
+	out := make([]int64, 0, len(roleIDs))
+	for _, roleID := range roleIDs {
+		if roleID > 0 && !slices.Contains(out, roleID) {
+			out = append(out, roleID)
+		}
+	}
+	return out
+}
+
+// ============ 邮箱（玩家） ============
+
+// ListMails 获取角色邮箱，先投递角色符合条件的全服邮件
+// 只返回未过期、未删除的最新 MailboxSize 封邮件
+func (s *MailService) ListMails(ctx context.Context, roleID int64) ([]*model.Mail, error) {
+	role, ok := s.roles.GetRole(roleID)
+	if !ok {
+		return nil, ErrMailRoleOffline
+	}
+
+	now := s.now().Unix()
+	if err := s.deliverGlobalMails(ctx, role, now); err != nil {
+		return nil, err
+	}
+
+	limit := s.cfg.MailboxSize
+	if limit <= 0 {
+		limit = defaultMailboxSize
+	}
+	return s.mailRepo.ListMails(ctx, roleID, now, uint64(limit))
+}
+
+// deliverGlobalMails 将角色尚未收到且符合投放目标的全服邮件复制到角色邮箱
+// 等级目标按投递时的角色等级判断，不符合的邮件在角色升级后拉取时仍可投递
+func (s *MailService) deliverGlobalMails(ctx context.Context, role *model.Role, now int64) error {
+	pending, err := s.mailRepo.ListPendingGlobalMails(ctx, role.ID, now)
+	if err != nil {
+		return err
+	}
+
+	var mails []*model.Mail
+	for _, g := range pending {
+		if g.Matches(role) {
+			mails = append(mails, g.ToMail(role.ID))
+		}
+	}
+	if len(mails) == 0 {
+		return nil
+	}
+
+	if err := s.mailRepo.AddMails(ctx, mails); err != nil {
+		return fmt.Errorf("failed to deliver global mails: %w", err)
+	}
+
+	s.logger.Debug("global mails delivered", "role_id", role.ID, "count", len(mails))
+	return nil
+}
+
+// getMail 获取角色的有效邮件
+func (s *MailService) getMail(ctx context.Context, roleID, mailID, now int64) (*model.Mail, error) {
+	mail, err := s.mailRepo.GetMail(ctx, roleID, mailID)
+	if err != nil {
+		return nil, err
+	}
+	if mail == nil {
+		return nil, fmt.Errorf("%w: %d", ErrMailNotFound, mailID)
+	}
+	if mail.IsExpired(now) {
+		return nil, fmt.Errorf("%w: %d", ErrMailExpired, mailID)
+	}
+	return mail, nil
+}
+
+// ReadMail 标记邮件已读
+func (s *MailService) ReadMail(ctx context.Context, roleID, mailID int64) (*model.Mail, error) {
+	var mail *model.Mail
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		mail, err = s.getMail(ctx, roleID, mailID, s.now().Unix())
+		if err != nil {
+			return err
+		}
+		if mail.IsRead {
+			return nil
+		}
+
+		mail.IsRead = true
+		return s.mailRepo.UpdateMailState(ctx, mail)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mail, nil
+}
+
+// ClaimMail 领取邮件附件，mailID 为 0 时一键领取邮箱中所有未领取的附件
+// 单封邮件的附件发放与领取状态在同一事务内完成，背包已满时整封回滚；
+// 一键领取时已领取成功的邮件保留，遇到失败即停止，至少领取一封时返回部分结果
+func (s *MailService) ClaimMail(ctx context.Context, roleID, mailID int64) (*MailClaimResult, error) {
+	result := &MailClaimResult{}
+
+	if mailID != 0 {
+		mail, err := s.claimOne(ctx, roleID, mailID)
+		if err != nil {
+			return nil, err
+		}
+		result.add(mail)
+		return result, nil
+	}
+
+	mails, err := s.ListMails(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range mails {
+		if !m.CanClaim() {
+			continue
+		}
+
+		mail, err := s.claimOne(ctx, roleID, m.ID)
+		if err != nil {
+			if len(result.MailIDs) == 0 {
+				return nil, err
+			}
+			s.logger.Warn("claim all mails stopped",
+				"role_id", roleID,
+				"mail_id", m.ID,
+				"claimed", len(result.MailIDs),
+				"error", err,
+			)
+			break
+		}
+		result.add(mail)
+	}
+
+	if len(result.MailIDs) == 0 {
+		return nil, ErrMailNoAttachment
+	}
+	return result, nil
+}
+
+// claimOne 在事务内领取一封邮件的附件
+func (s *MailService) claimOne(ctx context.Context, roleID, mailID int64) (*model.Mail, error) {
+	var mail *model.Mail
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		mail, err = s.getMail(ctx, roleID, mailID, s.now().Unix())
+		if err != nil {
+			return err
+		}
+		if !mail.HasAttachments() {
+			return fmt.Errorf("%w: %d", ErrMailNoAttachment, mailID)
+		}
+		if mail.IsClaimed {
+			return fmt.Errorf("%w: %d", ErrMailAlreadyClaimed, mailID)
+		}
+
+		for _, a := range mail.Attachments {
+			if err := grantItem(ctx, s.dollSvc, s.bagSvc, roleID, a.ItemID, a.Count); err != nil {
+				return err
+			}
+		}
+
+		mail.IsRead = true
+		mail.IsClaimed = true
+		return s.mailRepo.UpdateMailState(ctx, mail)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("mail claimed",
+		"role_id", roleID,
+		"mail_id", mailID,
+		"global_id", mail.GlobalID,
+		"attachments", len(mail.Attachments),
+	)
+
+	return mail, nil
+}
+
+// add 记录一封已领取的邮件并合并附件
+func (r *MailClaimResult) add(mail *model.Mail) {
+	r.MailIDs = append(r.MailIDs, mail.ID)
+
+next:
+	for _, a := range mail.Attachments {
+		for _, reward := range r.Rewards {
+			if reward.ItemID == a.ItemID {
+				reward.Count += a.Count
+				continue next
+			}
+		}
+		r.Rewards = append(r.Rewards, &model.MailAttachment{ItemID: a.ItemID, Count: a.Count})
+	}
+}
+
+// DeleteMail 删除邮件，附件未领取的邮件不可删除
+// mailID 为 0 时删除邮箱中所有已读且无未领取附件的邮件，返回被删除的邮件ID
+func (s *MailService) DeleteMail(ctx context.Context, roleID, mailID int64) ([]int64, error) {
+	if mailID != 0 {
+		err := s.uow.Do(ctx, func(ctx context.Context) error {
+			mail, err := s.getMail(ctx, roleID, mailID, s.now().Unix())
+			if err != nil {
+				return err
+			}
+			if mail.CanClaim() {
+				return fmt.Errorf("%w: %d", ErrMailNotClaimed, mailID)
+			}
+			return s.mailRepo.DeleteMails(ctx, roleID, []int64{mailID})
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []int64{mailID}, nil
+	}
+
+	mails, err := s.ListMails(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	mailIDs := make([]int64, 0, len(mails))
+	for _, m := range mails {
+		if m.IsRead && !m.CanClaim() {
+			mailIDs = append(mailIDs, m.ID)
+		}
+	}
+	if err := s.mailRepo.DeleteMails(ctx, roleID, mailIDs); err != nil {
+		return nil, err
+	}
+
+	return mailIDs, nil
+}
+
+// PurgeExpiredMails 物理删除已过期的邮件，返回清理的角色邮件数量
+func (s *MailService) PurgeExpiredMails(ctx context.Context) (int64, error) {
+	deleted, err := s.mailRepo.PurgeExpiredMails(ctx, s.now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired mails: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// testMailNow 邮件测试基准时间
+var testMailNow = time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// testNewRoleID 全服邮件发送之后创建的角色
+const testNewRoleID = 10004
+
+func newTestMailService(t *testing.T) (*MailService, *fakePlayerRepo, *fakeMailRepo, fakeRoles, *testClock) {
+	t.Helper()
+	setupTestConfig(t)
+
+	l := logger.Noop()
+	repo := newFakePlayerRepo()
+	mailRepo := newFakeMailRepo()
+	uow := &fakeUnitOfWork{repo: repo, mail: mailRepo}
+	created := testMailNow.Add(-24 * time.Hour)
+	roles := fakeRoles{
+		testRoleID:         {ID: testRoleID, Level: 20, CreatedAt: created},
+		testOtherRoleID:    {ID: testOtherRoleID, Level: 20, CreatedAt: created},
+		testLowLevelRoleID: {ID: testLowLevelRoleID, Level: 5, CreatedAt: created},
+		testNewRoleID:      {ID: testNewRoleID, Level: 20, CreatedAt: testMailNow.Add(time.Hour)},
+	}
+
+	svc := newMailService(l, &MailConfig{MaxAttachments: 3}, mailRepo, uow, roles, NewDollService(l, repo, nil), NewBagService(l, &BagConfig{}, repo, uow))
+	clock := &testClock{now: testMailNow}
+	svc.now = clock.Now
+	svc.bagSvc.now = clock.Now
+	return svc, repo, mailRepo, roles, clock
+}
+
+// testDraft 带附件的邮件内容
+func testDraft(attachments ...*model.MailAttachment) *MailDraft {
+	return &MailDraft{Sender: "GM", Title: "compensation", Content: "sorry", Attachments: attachments}
+}
+
+// mailIDs 返回邮件ID列表
+func mailIDs(mails []*model.Mail) []int64 {
+	ids := make([]int64, 0, len(mails))
+	for _, m := range mails {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+// TestMailSendAndClaim 测试发送给离线角色、领取附件（道具入背包，玩偶创建实例）且不可重复领取
+func TestMailSendAndClaim(t *testing.T) {
+	svc, repo, mailRepo, _, _ := newTestMailService(t)
+	ctx := context.Background()
+
+	// testOtherRoleID 重复出现只发送一封；未在线的角色同样写入邮箱
+	sent, err := svc.SendMail(ctx, []int64{testRoleID, testOtherRoleID, testOtherRoleID, 99999}, testDraft(
+		&model.MailAttachment{ItemID: testRewardItemID, Count: 5},
+		&model.MailAttachment{ItemID: testDollID, Count: 1},
+	))
+	if err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	if sent != 3 || len(mailRepo.state.mails) != 3 {
+		t.Fatalf("SendMail() sent = %d, stored %d; want 3", sent, len(mailRepo.state.mails))
+	}
+
+	mails, err := svc.ListMails(ctx, testRoleID)
+	if err != nil {
+		t.Fatalf("ListMails() error = %v", err)
+	}
+	if len(mails) != 1 {
+		t.Fatalf("ListMails() = %d mails, want 1", len(mails))
+	}
+	mail := mails[0]
+	if mail.MailType != model.MailTypeSystem || mail.ExpireTime != testMailNow.Add(defaultMailExpire).Unix() {
+		t.Errorf("mail = %+v, want system mail with default expire", mail)
+	}
+
+	result, err := svc.ClaimMail(ctx, testRoleID, mail.ID)
+	if err != nil {
+		t.Fatalf("ClaimMail() error = %v", err)
+	}
+	if !reflect.DeepEqual(result.MailIDs, []int64{mail.ID}) || len(result.Rewards) != 2 {
+		t.Errorf("ClaimMail() = %+v, want one mail with 2 rewards", result)
+	}
+	if got := bagItems(repo, gameconfig.BagType_Item); !reflect.DeepEqual(got, []string{"1002:5"}) {
+		t.Errorf("bag = %v, want [1002:5]", got)
+	}
+	if len(repo.state.dolls) != 1 {
+		t.Errorf("dolls = %d, want 1", len(repo.state.dolls))
+	}
+
+	claimed, _ := mailRepo.GetMail(ctx, testRoleID, mail.ID)
+	if !claimed.IsClaimed || !claimed.IsRead {
+		t.Errorf("mail after claim = %+v, want read and claimed", claimed)
+	}
+
+	if _, err := svc.ClaimMail(ctx, testRoleID, mail.ID); !errors.Is(err, ErrMailAlreadyClaimed) {
+		t.Errorf("ClaimMail() again error = %v, want ErrMailAlreadyClaimed", err)
+	}
+	if _, err := svc.ClaimMail(ctx, testOtherRoleID+100, mail.ID); !errors.Is(err, ErrMailNotFound) {
+		t.Errorf("ClaimMail() of another role's mail error = %v, want ErrMailNotFound", err)
+	}
+}
+
+// TestMailClaim_BagFull 测试背包已满时整封邮件回滚，已发放的玩偶一并撤销
+func TestMailClaim_BagFull(t *testing.T) {
+	svc, repo, mailRepo, _, _ := newTestMailService(t)
+	ctx := context.Background()
+	svc.bagSvc.cfg = &BagConfig{Capacity: map[int32]int32{gameconfig.BagType_Item: 1}}
+	setBagCount(repo, gameconfig.BagType_Item, testCostItemID, 1)
+
+	if _, err := svc.SendMail(ctx, []int64{testRoleID}, testDraft(
+		&model.MailAttachment{ItemID: testDollID, Count: 1},
+		&model.MailAttachment{ItemID: testRewardItemID, Count: 1},
+	)); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	mailID := mailRepo.state.mails[0].ID
+
+	before := repo.state.clone()
+	if _, err := svc.ClaimMail(ctx, testRoleID, mailID); !errors.Is(err, ErrBagFull) {
+		t.Fatalf("ClaimMail() error = %v, want ErrBagFull", err)
+	}
+	if !reflect.DeepEqual(repo.state, before) {
+		t.Error("ClaimMail() failure changed bag or dolls")
+	}
+	if mailRepo.state.mails[0].IsClaimed {
+		t.Error("ClaimMail() failure marked mail claimed")
+	}
+}
+
+// TestMailGlobalTargeting 测试全服邮件按目标投递、只投递一次、删除后不重复投递
+func TestMailGlobalTargeting(t *testing.T) {
+	svc, _, _, roles, clock := newTestMailService(t)
+	ctx := context.Background()
+
+	targets := []model.MailTarget{
+		{Type: model.MailTargetAll},
+		{Type: model.MailTargetLevel, MinLevel: 10},
+		{Type: model.MailTargetRoles, RoleIDs: []int64{testOtherRoleID}},
+	}
+	for _, target := range targets {
+		if _, err := svc.SendGlobalMail(ctx, testDraft(), target); err != nil {
+			t.Fatalf("SendGlobalMail(%+v) error = %v", target, err)
+		}
+	}
+	clock.now = testMailNow.Add(2 * time.Hour)
+
+	tests := []struct {
+		name   string
+		roleID int64
+		want   int
+	}{
+		{"all and level", testRoleID, 2},
+		{"all, level and role list", testOtherRoleID, 3},
+		{"level too low", testLowLevelRoleID, 1},
+		{"created after send", testNewRoleID, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				mails, err := svc.ListMails(ctx, tt.roleID)
+				if err != nil {
+					t.Fatalf("ListMails() error = %v", err)
+				}
+				if len(mails) != tt.want {
+					t.Fatalf("ListMails() call %d = %d mails, want %d", i+1, len(mails), tt.want)
+				}
+			}
+		})
+	}
+
+	// 升级后拉取时投递等级邮件
+	roles[testLowLevelRoleID].Level = 10
+	mails, err := svc.ListMails(ctx, testLowLevelRoleID)
+	if err != nil {
+		t.Fatalf("ListMails() error = %v", err)
+	}
+	if len(mails) != 2 {
+		t.Errorf("ListMails() after level up = %d mails, want 2", len(mails))
+	}
+
+	// 删除后不会被重新投递
+	if _, err := svc.ReadMail(ctx, testRoleID, mails[0].ID); !errors.Is(err, ErrMailNotFound) {
+		t.Errorf("ReadMail() of another role's mail error = %v, want ErrMailNotFound", err)
+	}
+	mails, _ = svc.ListMails(ctx, testRoleID)
+	if _, err := svc.DeleteMail(ctx, testRoleID, mails[0].ID); err != nil {
+		t.Fatalf("DeleteMail() error = %v", err)
+	}
+	mails, _ = svc.ListMails(ctx, testRoleID)
+	if len(mails) != 1 {
+		t.Errorf("ListMails() after delete = %d mails, want 1", len(mails))
+	}
+
+	if _, err := svc.ListMails(ctx, 99999); !errors.Is(err, ErrMailRoleOffline) {
+		t.Errorf("ListMails() of offline role error = %v, want ErrMailRoleOffline", err)
+	}
+}
+
+// TestMailClaimAllAndDelete 测试一键领取合并奖励、未领取附件的邮件不可删除、一键删除已读邮件
+func TestMailClaimAllAndDelete(t *testing.T) {
+	svc, repo, _, _, _ := newTestMailService(t)
+	ctx := context.Background()
+
+	for _, draft := range []*MailDraft{
+		testDraft(&model.MailAttachment{ItemID: testRewardItemID, Count: 2}),
+		testDraft(),
+		testDraft(&model.MailAttachment{ItemID: testRewardItemID, Count: 3}, &model.MailAttachment{ItemID: testCostItemID, Count: 1}),
+	} {
+		if _, err := svc.SendMail(ctx, []int64{testRoleID}, draft); err != nil {
+			t.Fatalf("SendMail() error = %v", err)
+		}
+	}
+	mails, _ := svc.ListMails(ctx, testRoleID)
+	noAttachment := mails[1]
+
+	if _, err := svc.DeleteMail(ctx, testRoleID, mails[0].ID); !errors.Is(err, ErrMailNotClaimed) {
+		t.Errorf("DeleteMail() with unclaimed attachment error = %v, want ErrMailNotClaimed", err)
+	}
+	if _, err := svc.ClaimMail(ctx, testRoleID, noAttachment.ID); !errors.Is(err, ErrMailNoAttachment) {
+		t.Errorf("ClaimMail() without attachment error = %v, want ErrMailNoAttachment", err)
+	}
+
+	result, err := svc.ClaimMail(ctx, testRoleID, 0)
+	if err != nil {
+		t.Fatalf("ClaimMail(all) error = %v", err)
+	}
+	if len(result.MailIDs) != 2 {
+		t.Errorf("ClaimMail(all) claimed %v, want 2 mails", result.MailIDs)
+	}
+	wantRewards := []*model.MailAttachment{{ItemID: testRewardItemID, Count: 5}, {ItemID: testCostItemID, Count: 1}}
+	if !reflect.DeepEqual(result.Rewards, wantRewards) {
+		t.Errorf("ClaimMail(all) rewards = %+v, want merged %+v", result.Rewards, wantRewards)
+	}
+	if got := bagItems(repo, gameconfig.BagType_Item); !reflect.DeepEqual(got, []string{"1001:1", "1002:5"}) {
+		t.Errorf("bag = %v, want [1001:1 1002:5]", got)
+	}
+	if _, err := svc.ClaimMail(ctx, testRoleID, 0); !errors.Is(err, ErrMailNoAttachment) {
+		t.Errorf("ClaimMail(all) with nothing left error = %v, want ErrMailNoAttachment", err)
+	}
+
+	// 未读的无附件邮件不在一键删除范围内
+	deleted, err := svc.DeleteMail(ctx, testRoleID, 0)
+	if err != nil {
+		t.Fatalf("DeleteMail(all) error = %v", err)
+	}
+	if len(deleted) != 2 {
+		t.Errorf("DeleteMail(all) = %v, want the 2 claimed mails", deleted)
+	}
+	mails, _ = svc.ListMails(ctx, testRoleID)
+	if !reflect.DeepEqual(mailIDs(mails), []int64{noAttachment.ID}) {
+		t.Errorf("ListMails() after delete = %v, want only the unread mail", mailIDs(mails))
+	}
+
+	if _, err := svc.ReadMail(ctx, testRoleID, noAttachment.ID); err != nil {
+		t.Fatalf("ReadMail() error = %v", err)
+	}
+	if deleted, _ := svc.DeleteMail(ctx, testRoleID, 0); len(deleted) != 1 {
+		t.Errorf("DeleteMail(all) after read = %v, want 1 mail", deleted)
+	}
+}
+
+// TestMailExpiry 测试过期邮件不展示、不可领取，并由清理任务删除
+func TestMailExpiry(t *testing.T) {
+	svc, _, mailRepo, _, clock := newTestMailService(t)
+	ctx := context.Background()
+
+	draft := testDraft(&model.MailAttachment{ItemID: testRewardItemID, Count: 1})
+	draft.ExpireTime = testMailNow.Add(time.Hour).Unix()
+	if _, err := svc.SendMail(ctx, []int64{testRoleID}, draft); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	if _, err := svc.SendMail(ctx, []int64{testRoleID}, testDraft()); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	expiring := mailRepo.state.mails[0].ID
+
+	clock.now = testMailNow.Add(time.Hour)
+	mails, err := svc.ListMails(ctx, testRoleID)
+	if err != nil {
+		t.Fatalf("ListMails() error = %v", err)
+	}
+	if len(mails) != 1 || mails[0].ID == expiring {
+		t.Errorf("ListMails() = %v, want expired mail hidden", mailIDs(mails))
+	}
+	if _, err := svc.ClaimMail(ctx, testRoleID, expiring); !errors.Is(err, ErrMailExpired) {
+		t.Errorf("ClaimMail() of expired mail error = %v, want ErrMailExpired", err)
+	}
+
+	job := NewMailPurgeJob(logger.Noop(), svc)
+	if job.Name() != MailPurgeJobName {
+		t.Errorf("Name() = %q, want %q", job.Name(), MailPurgeJobName)
+	}
+	if err := job.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(mailRepo.state.mails) != 1 {
+		t.Errorf("mails after purge = %d, want 1", len(mailRepo.state.mails))
+	}
+}
+
+// TestMailSend_Invalid 测试发送参数校验
+func TestMailSend_Invalid(t *testing.T) {
+	svc, _, mailRepo, _, _ := newTestMailService(t)
+	ctx := context.Background()
+
+	pastDraft := testDraft()
+	pastDraft.ExpireTime = testMailNow.Unix()
+	attachment := &model.MailAttachment{ItemID: testRewardItemID, Count: 1}
+
+	tests := []struct {
+		name    string
+		roleIDs []int64
+		draft   *MailDraft
+	}{
+		{"empty title", []int64{testRoleID}, &MailDraft{Title: " "}},
+		{"unknown item", []int64{testRoleID}, testDraft(&model.MailAttachment{ItemID: 999, Count: 1})},
+		{"zero count", []int64{testRoleID}, testDraft(&model.MailAttachment{ItemID: testRewardItemID})},
+		{"too many attachments", []int64{testRoleID}, testDraft(attachment, attachment, attachment, attachment)},
+		{"expired", []int64{testRoleID}, pastDraft},
+		{"no recipient", []int64{0}, testDraft()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.SendMail(ctx, tt.roleIDs, tt.draft); !errors.Is(err, ErrMailInvalid) {
+				t.Errorf("SendMail() error = %v, want ErrMailInvalid", err)
+			}
+		})
+	}
+
+	for _, target := range []model.MailTarget{
+		{Type: 9},
+		{Type: model.MailTargetLevel, MinLevel: 10, MaxLevel: 5},
+		{Type: model.MailTargetRoles},
+	} {
+		if _, err := svc.SendGlobalMail(ctx, testDraft(), target); !errors.Is(err, ErrMailInvalid) {
+			t.Errorf("SendGlobalMail(%+v) error = %v, want ErrMailInvalid", target, err)
+		}
+	}
+
+	if len(mailRepo.state.mails) != 0 || len(mailRepo.state.global) != 0 {
+		t.Error("invalid mails were stored")
+	}
+}
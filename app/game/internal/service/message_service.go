@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	gamerouter "github.com/lk2023060901/xdooria/app/game/internal/router"
 	"github.com/lk2023060901/xdooria/pkg/logger"
 	"github.com/lk2023060901/xdooria/pkg/router"
+	"google.golang.org/protobuf/proto"
 )
 
 // MessageService 消息服务，处理消息路由和转发
@@ -18,6 +20,7 @@ type MessageService struct {
 	logger       logger.Logger
 	roleRouter   *gamerouter.RoleRouter
 	roleMgr      *manager.RoleManager
+	gatewayMgr   *manager.GatewayManager
 	sceneService *SceneService
 	metrics      *metrics.GameMetrics
 }
@@ -27,6 +30,7 @@ func NewMessageService(
 	l logger.Logger,
 	r router.Router,
 	roleMgr *manager.RoleManager,
+	gatewayMgr *manager.GatewayManager,
 	sceneService *SceneService,
 	m *metrics.GameMetrics,
 ) *MessageService {
@@ -34,6 +38,7 @@ func NewMessageService(
 		logger:       l.Named("service.message"),
 		roleRouter:   gamerouter.NewRoleRouter(r),
 		roleMgr:      roleMgr,
+		gatewayMgr:   gatewayMgr,
 		sceneService: sceneService,
 		metrics:      m,
 	}
@@ -56,12 +61,51 @@ func (s *MessageService) registerHandlers() {
 		uint32(api.OpCode_OP_ENTER_SCENE_REQ),
 		uint32(api.OpCode_OP_ENTER_SCENE_RES),
 		s.handleEnterScene)
+	gamerouter.RegisterHandler(s.roleRouter,
+		uint32(api.OpCode_OP_SCENE_MOVE_REQ),
+		uint32(api.OpCode_OP_SCENE_MOVE_RES),
+		s.handleSceneMove)
+	gamerouter.RegisterHandler(s.roleRouter,
+		uint32(api.OpCode_OP_SCENE_LEAVE_REQ),
+		uint32(api.OpCode_OP_SCENE_LEAVE_RES),
+		s.handleSceneLeave)
 }
 
 // handleEnterScene 处理进入场景请求
 func (s *MessageService) handleEnterScene(ctx context.Context, roleID int64, req *api.EnterSceneRequest) (*api.EnterSceneResponse, error) {
 	// 调用 SceneService 处理
-	return s.sceneService.HandleEnterScene(ctx, roleID)
+	return s.sceneService.HandleEnterScene(ctx, roleID, req.MapId)
+}
+
+// handleSceneMove 处理场景内移动请求
+func (s *MessageService) handleSceneMove(ctx context.Context, roleID int64, req *api.SceneMoveRequest) (*api.SceneMoveResponse, error) {
+	pos, err := s.sceneService.Move(ctx, roleID, req.Pos)
+	if err != nil {
+		return &api.SceneMoveResponse{Code: sceneErrorCode(err)}, nil
+	}
+	return &api.SceneMoveResponse{
+		Code: api.ErrorCode_ERR_SUCCESS,
+		Pos:  pos,
+	}, nil
+}
+
+// handleSceneLeave 处理离开场景请求
+func (s *MessageService) handleSceneLeave(ctx context.Context, roleID int64, req *api.SceneLeaveRequest) (*api.SceneLeaveResponse, error) {
+	if err := s.sceneService.Leave(ctx, roleID); err != nil {
+		return &api.SceneLeaveResponse{Code: sceneErrorCode(err)}, nil
+	}
+	return &api.SceneLeaveResponse{Code: api.ErrorCode_ERR_SUCCESS}, nil
+}
+
+// sceneErrorCode 场景业务错误转换为错误码
+func sceneErrorCode(err error) api.ErrorCode {
+	switch {
+	case errors.Is(err, manager.ErrRoleNotInScene):
+		return api.ErrorCode_ERR_SCENE_NOT_IN_SCENE
+	case errors.Is(err, ErrSceneInvalidPosition):
+		return api.ErrorCode_ERR_SCENE_INVALID_POSITION
+	}
+	return api.ErrorCode_ERR_INTERNAL
 }
 
 // HandleMessage 处理从 Gateway 转发来的消息
@@ -148,9 +192,7 @@ func (s *MessageService) BroadcastToAll(ctx context.Context, opCode uint32, payl
 }
 */
 
-// TODO: SendToRole 需要在 gateway 通信实现后启用
-// SendToRole 发送消息给指定角色
-/*
+// SendToRole 通过 Gateway 流推送消息给指定角色
 func (s *MessageService) SendToRole(ctx context.Context, roleID int64, opCode uint32, payload []byte) error {
 	s.logger.Debug("sending message to role",
 		"role_id", roleID,
@@ -158,8 +200,8 @@ func (s *MessageService) SendToRole(ctx context.Context, roleID int64, opCode ui
 		"payload_size", len(payload),
 	)
 
-	if err := s.broadcastMgr.SendToRole(ctx, roleID, opCode, payload); err != nil {
-		s.logger.Error("failed to send message to role",
+	if err := s.gatewayMgr.SendToRole(ctx, roleID, opCode, payload); err != nil {
+		s.logger.Warn("failed to send message to role",
 			"role_id", roleID,
 			"op_code", opCode,
 			"error", err,
@@ -169,4 +211,53 @@ func (s *MessageService) SendToRole(ctx context.Context, roleID int64, opCode ui
 
 	return nil
 }
-*/
+
+// PushToRole 序列化消息并通过 Gateway 流推送给指定角色
+func (s *MessageService) PushToRole(ctx context.Context, roleID int64, opCode uint32, msg proto.Message) error {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return s.SendToRole(ctx, roleID, opCode, payload)
+}
+
+// BroadcastToRoles 序列化消息并通过 Gateway 流推送给多个角色（按 Gateway 合并发送）
+func (s *MessageService) BroadcastToRoles(ctx context.Context, roleIDs []int64, opCode uint32, msg proto.Message) error {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	if err := s.gatewayMgr.Broadcast(ctx, roleIDs, opCode, payload); err != nil {
+		s.logger.Warn("failed to broadcast message",
+			"op_code", opCode,
+			"roles", len(roleIDs),
+			"error", err,
+		)
+		return fmt.Errorf("failed to broadcast message: %w", err)
+	}
+
+	return nil
+}
+
+// gatewayNotifier 直接经 GatewayManager 推送消息
+// 供 MessageService 依赖的服务（如 SceneService）使用，避免循环依赖
+type gatewayNotifier struct {
+	gatewayMgr *manager.GatewayManager
+}
+
+func (n *gatewayNotifier) PushToRole(ctx context.Context, roleID int64, opCode uint32, msg proto.Message) error {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return n.gatewayMgr.SendToRole(ctx, roleID, opCode, payload)
+}
+
+func (n *gatewayNotifier) BroadcastToRoles(ctx context.Context, roleIDs []int64, opCode uint32, msg proto.Message) error {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return n.gatewayMgr.Broadcast(ctx, roleIDs, opCode, payload)
+}
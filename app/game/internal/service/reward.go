@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+)
+
+// grantItem 发放物品：玩偶逐个创建实例，其余道具进背包
+func grantItem(ctx context.Context, dollSvc *DollService, bagSvc *BagService, roleID int64, itemID int32, count int32) error {
+	// 检查该物品是否为玩偶
+	if dollCfg := gameconfig.T.TbDoll.Get(itemID); dollCfg != nil {
+		for c := int32(0); c < count; c++ {
+			if _, err := dollSvc.AddDoll(ctx, roleID, itemID); err != nil {
+				return fmt.Errorf("failed to add doll %d: %w", itemID, err)
+			}
+		}
+		return nil
+	}
+
+	// 普通道具入背包
+	if err := bagSvc.AddItem(ctx, roleID, itemID, count); err != nil {
+		return fmt.Errorf("failed to add item %d: %w", itemID, err)
+	}
+	return nil
+}
@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand/v2"
 
 	api "github.com/lk2023060901/xdooria-proto-api"
 	common "github.com/lk2023060901/xdooria-proto-common"
@@ -10,37 +13,106 @@ import (
 	"github.com/lk2023060901/xdooria/app/game/internal/metrics"
 	"github.com/lk2023060901/xdooria/app/game/internal/model"
 	"github.com/lk2023060901/xdooria/pkg/logger"
+	"google.golang.org/protobuf/proto"
 )
 
+// 场景业务错误
+var ErrSceneInvalidPosition = errors.New("invalid scene position")
+
+// 默认新手场景与出生点
+const (
+	defaultSceneMapID  int32   = 1001
+	defaultSpawnX      float32 = 100
+	defaultSpawnZ      float32 = 100
+	defaultSpawnRadius float32 = 5
+)
+
+// SceneConfig 场景配置
+type SceneConfig struct {
+	// DefaultMapID 未指定地图时进入的场景，未配置时为 1001（新手场景）
+	DefaultMapID int32 `mapstructure:"default_map_id"`
+
+	// SpawnX / SpawnY / SpawnZ 出生点，三者均未配置时为 (100, 0, 100)
+	SpawnX float32 `mapstructure:"spawn_x"`
+	SpawnY float32 `mapstructure:"spawn_y"`
+	SpawnZ float32 `mapstructure:"spawn_z"`
+
+	// SpawnRadius 出生点随机散布半径（避免所有人叠在同一点），未配置时为 5，负数表示不散布
+	SpawnRadius float32 `mapstructure:"spawn_radius"`
+
+	// MaxMoveDistance 单次移动允许的最大距离（XZ 平面），0 表示不校验
+	MaxMoveDistance float32 `mapstructure:"max_move_distance"`
+
+	// AOI 视野配置
+	AOI manager.AOIConfig `mapstructure:"aoi"`
+}
+
+// sceneNotifier 向场景内的玩家推送通知（由 gatewayNotifier 经 Gateway 流实现）
+type sceneNotifier interface {
+	PushToRole(ctx context.Context, roleID int64, opCode uint32, msg proto.Message) error
+	BroadcastToRoles(ctx context.Context, roleIDs []int64, opCode uint32, msg proto.Message) error
+}
+
 // SceneService 场景服务
+// 进入、移动、离开场景时按 AOI 视野变化只通知附近的玩家
 type SceneService struct {
 	logger   logger.Logger
-	roleMgr  *manager.RoleManager
+	cfg      *SceneConfig
+	roles    roleGetter
 	sceneMgr *manager.SceneManager
+	notifier sceneNotifier
 	metrics  *metrics.GameMetrics
+	rand     func() float64
 }
 
 // NewSceneService 创建场景服务
 func NewSceneService(
 	l logger.Logger,
+	cfg *SceneConfig,
 	roleMgr *manager.RoleManager,
 	sceneMgr *manager.SceneManager,
+	gatewayMgr *manager.GatewayManager,
 	m *metrics.GameMetrics,
 ) *SceneService {
+	s := newSceneService(l, cfg, roleMgr, sceneMgr, &gatewayNotifier{gatewayMgr: gatewayMgr}, m)
+
+	// 角色下线（Gateway 通知或 Gateway 流断开）时离开场景，避免残留在其他玩家视野中
+	gatewayMgr.OnRoleOffline(s.handleRoleOffline)
+
+	return s
+}
+
+func newSceneService(
+	l logger.Logger,
+	cfg *SceneConfig,
+	roles roleGetter,
+	sceneMgr *manager.SceneManager,
+	notifier sceneNotifier,
+	m *metrics.GameMetrics,
+) *SceneService {
+	if cfg == nil {
+		cfg = &SceneConfig{}
+	}
+
 	return &SceneService{
 		logger:   l.Named("service.scene"),
-		roleMgr:  roleMgr,
+		cfg:      cfg,
+		roles:    roles,
 		sceneMgr: sceneMgr,
+		notifier: notifier,
 		metrics:  m,
+		rand:     rand.Float64,
 	}
 }
 
 // HandleEnterScene 处理进入场景请求
-func (s *SceneService) HandleEnterScene(ctx context.Context, roleID int64) (*api.EnterSceneResponse, error) {
-	s.logger.Info("handle enter scene", "role_id", roleID)
+// mapID 为 0 时进入当前所在场景（重连），不在任何场景时进入默认场景；
+// 重新进入当前场景保留原坐标，否则在出生点附近随机放置
+func (s *SceneService) HandleEnterScene(ctx context.Context, roleID int64, mapID int32) (*api.EnterSceneResponse, error) {
+	s.logger.Info("handle enter scene", "role_id", roleID, "map_id", mapID)
 
 	// 1. 获取角色信息
-	role, ok := s.roleMgr.GetRole(roleID)
+	role, ok := s.roles.GetRole(roleID)
 	if !ok {
 		s.logger.Error("role not found", "role_id", roleID)
 		return &api.EnterSceneResponse{
@@ -56,8 +128,25 @@ func (s *SceneService) HandleEnterScene(ctx context.Context, roleID int64) (*api
 		}, nil
 	}
 
-	// 3. 获取或创建场景实例（默认进入新手场景）
-	mapID := int32(1001) // 默认新手场景
+	// 3. 确定目标场景与坐标
+	var position *api.Position
+	current, currentMapID, inScene := s.sceneMgr.GetPlayer(roleID)
+	switch {
+	case inScene && (mapID == 0 || mapID == currentMapID):
+		mapID = currentMapID
+		position = current.Position
+	case inScene:
+		// 切换场景：先通知旧场景的玩家
+		s.leave(ctx, roleID)
+	}
+	if mapID == 0 {
+		mapID = s.defaultMapID()
+	}
+	if position == nil {
+		position = s.spawnPosition()
+	}
+
+	// 4. 获取或创建场景实例
 	scene, err := s.sceneMgr.GetOrCreateScene(mapID)
 	if err != nil {
 		s.logger.Error("failed to get or create scene",
@@ -70,15 +159,9 @@ func (s *SceneService) HandleEnterScene(ctx context.Context, roleID int64) (*api
 		}, nil
 	}
 
-	// 4. 将角色加入场景
-	position := &api.Position{
-		X:        100.0, // 默认出生点
-		Y:        0.0,
-		Z:        100.0,
-		Rotation: 0.0,
-	}
-
-	if err := s.sceneMgr.EnterScene(roleID, scene.MapID, position); err != nil {
+	// 5. 将角色加入场景
+	view, err := s.sceneMgr.EnterScene(roleID, scene.MapID, position)
+	if err != nil {
 		s.logger.Error("failed to enter scene",
 			"role_id", roleID,
 			"map_id", mapID,
@@ -93,9 +176,15 @@ func (s *SceneService) HandleEnterScene(ctx context.Context, roleID int64) (*api
 		"role_id", roleID,
 		"map_id", mapID,
 		"position", fmt.Sprintf("(%.1f, %.1f, %.1f)", position.X, position.Y, position.Z),
+		"nearby", len(view.Appeared),
 	)
 
-	// 5. 返回场景信息
+	// 6. 通知视野内的玩家
+	self := s.scenePlayer(roleID, position)
+	s.broadcast(ctx, playerIDs(view.Appeared), uint32(api.OpCode_OP_SCENE_PLAYER_ENTER_NOTIFY),
+		&api.ScenePlayerEnterNotify{Players: []*api.ScenePlayer{self}})
+
+	// 7. 返回场景信息及视野内的玩家
 	return &api.EnterSceneResponse{
 		Code: common.ErrCode_ERR_CODE_OK,
 		Role: roleToProto(role),
@@ -103,9 +192,173 @@ func (s *SceneService) HandleEnterScene(ctx context.Context, roleID int64) (*api
 			MapId: mapID,
 			Pos:   position,
 		},
+		Players: s.scenePlayers(view.Appeared),
 	}, nil
 }
 
+// Move 在当前场景内移动到指定坐标，返回生效的坐标
+// 视野内一直可见的玩家收到移动通知，新进入 / 离开视野的玩家与移动者互相收到进入 / 离开通知
+func (s *SceneService) Move(ctx context.Context, roleID int64, pos *api.Position) (*api.Position, error) {
+	if !validPosition(pos) {
+		return nil, fmt.Errorf("%w: not a finite position", ErrSceneInvalidPosition)
+	}
+
+	current, _, ok := s.sceneMgr.GetPlayer(roleID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", manager.ErrRoleNotInScene, roleID)
+	}
+	if maxDist := s.cfg.MaxMoveDistance; maxDist > 0 {
+		dx, dz := pos.X-current.Position.GetX(), pos.Z-current.Position.GetZ()
+		if dx*dx+dz*dz > maxDist*maxDist {
+			return nil, fmt.Errorf("%w: move distance exceeds %.1f", ErrSceneInvalidPosition, maxDist)
+		}
+	}
+
+	// 场景持有坐标的引用，复制一份避免调用方后续修改
+	pos = &api.Position{X: pos.X, Y: pos.Y, Z: pos.Z, Rotation: pos.Rotation}
+
+	view, err := s.sceneMgr.MoveInScene(roleID, pos)
+	if err != nil {
+		return nil, err
+	}
+
+	s.broadcast(ctx, view.Watchers, uint32(api.OpCode_OP_SCENE_PLAYER_MOVE_NOTIFY),
+		&api.ScenePlayerMoveNotify{RoleId: roleID, Pos: pos})
+
+	if len(view.Appeared) > 0 {
+		s.broadcast(ctx, playerIDs(view.Appeared), uint32(api.OpCode_OP_SCENE_PLAYER_ENTER_NOTIFY),
+			&api.ScenePlayerEnterNotify{Players: []*api.ScenePlayer{s.scenePlayer(roleID, pos)}})
+		s.push(ctx, roleID, uint32(api.OpCode_OP_SCENE_PLAYER_ENTER_NOTIFY),
+			&api.ScenePlayerEnterNotify{Players: s.scenePlayers(view.Appeared)})
+	}
+
+	if len(view.Disappeared) > 0 {
+		s.broadcast(ctx, view.Disappeared, uint32(api.OpCode_OP_SCENE_PLAYER_LEAVE_NOTIFY),
+			&api.ScenePlayerLeaveNotify{RoleIds: []int64{roleID}})
+		s.push(ctx, roleID, uint32(api.OpCode_OP_SCENE_PLAYER_LEAVE_NOTIFY),
+			&api.ScenePlayerLeaveNotify{RoleIds: view.Disappeared})
+	}
+
+	return pos, nil
+}
+
+// Leave 离开当前场景并通知视野内的玩家
+func (s *SceneService) Leave(ctx context.Context, roleID int64) error {
+	if _, ok := s.sceneMgr.GetRoleScene(roleID); !ok {
+		return fmt.Errorf("%w: %d", manager.ErrRoleNotInScene, roleID)
+	}
+	s.leave(ctx, roleID)
+	return nil
+}
+
+// leave 离开场景并通知视野内的玩家（角色不在场景中时忽略）
+func (s *SceneService) leave(ctx context.Context, roleID int64) {
+	view, err := s.sceneMgr.LeaveScene(roleID)
+	if err != nil {
+		if !errors.Is(err, manager.ErrRoleNotInScene) {
+			s.logger.Warn("failed to leave scene", "role_id", roleID, "error", err)
+		}
+		return
+	}
+
+	s.broadcast(ctx, view.Disappeared, uint32(api.OpCode_OP_SCENE_PLAYER_LEAVE_NOTIFY),
+		&api.ScenePlayerLeaveNotify{RoleIds: []int64{roleID}})
+}
+
+// handleRoleOffline 角色下线时离开场景
+func (s *SceneService) handleRoleOffline(roleID int64) {
+	s.leave(context.Background(), roleID)
+}
+
+// broadcast 推送通知给多个角色，推送失败只记录日志（不影响场景状态）
+func (s *SceneService) broadcast(ctx context.Context, roleIDs []int64, opCode uint32, msg proto.Message) {
+	if len(roleIDs) == 0 {
+		return
+	}
+	if err := s.notifier.BroadcastToRoles(ctx, roleIDs, opCode, msg); err != nil {
+		s.logger.Debug("scene notify not delivered", "op", opCode, "roles", len(roleIDs), "error", err)
+	}
+}
+
+// push 推送通知给单个角色，推送失败只记录日志
+func (s *SceneService) push(ctx context.Context, roleID int64, opCode uint32, msg proto.Message) {
+	if err := s.notifier.PushToRole(ctx, roleID, opCode, msg); err != nil {
+		s.logger.Debug("scene notify not delivered", "op", opCode, "role_id", roleID, "error", err)
+	}
+}
+
+// scenePlayers 将场景玩家快照转换为协议结构
+func (s *SceneService) scenePlayers(players []*manager.PlayerInScene) []*api.ScenePlayer {
+	result := make([]*api.ScenePlayer, 0, len(players))
+	for _, p := range players {
+		result = append(result, s.scenePlayer(p.RoleID, p.Position))
+	}
+	return result
+}
+
+// scenePlayer 构造场景玩家信息（角色不在内存中时只填充 ID 与坐标）
+func (s *SceneService) scenePlayer(roleID int64, pos *api.Position) *api.ScenePlayer {
+	player := &api.ScenePlayer{RoleId: roleID, Pos: pos}
+	if role, ok := s.roles.GetRole(roleID); ok {
+		player.Nickname = role.Nickname
+		player.AvatarUrl = role.AvatarURL
+		player.Level = role.Level
+	}
+	return player
+}
+
+// defaultMapID 默认进入的场景
+func (s *SceneService) defaultMapID() int32 {
+	if s.cfg.DefaultMapID > 0 {
+		return s.cfg.DefaultMapID
+	}
+	return defaultSceneMapID
+}
+
+// spawnPosition 在出生点附近随机选取一个坐标
+func (s *SceneService) spawnPosition() *api.Position {
+	x, y, z := s.cfg.SpawnX, s.cfg.SpawnY, s.cfg.SpawnZ
+	if x == 0 && y == 0 && z == 0 {
+		x, z = defaultSpawnX, defaultSpawnZ
+	}
+
+	radius := s.cfg.SpawnRadius
+	if radius == 0 {
+		radius = defaultSpawnRadius
+	}
+	if radius > 0 {
+		// 圆内均匀分布
+		r := float64(radius) * math.Sqrt(s.rand())
+		theta := 2 * math.Pi * s.rand()
+		x += float32(r * math.Cos(theta))
+		z += float32(r * math.Sin(theta))
+	}
+
+	return &api.Position{X: x, Y: y, Z: z}
+}
+
+// validPosition 坐标是否为有限值
+func validPosition(pos *api.Position) bool {
+	if pos == nil {
+		return false
+	}
+	for _, v := range []float32{pos.X, pos.Y, pos.Z, pos.Rotation} {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// playerIDs 提取玩家 ID
+func playerIDs(players []*manager.PlayerInScene) []int64 {
+	ids := make([]int64, 0, len(players))
+	for _, p := range players {
+		ids = append(ids, p.RoleID)
+	}
+	return ids
+}
+
 // roleToProto 将 model.Role 转换为 api.RoleInfo
 func roleToProto(role *model.Role) *api.RoleInfo {
 	return &api.RoleInfo{
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	api "github.com/lk2023060901/xdooria-proto-api"
+	common "github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/app/game/internal/manager"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"google.golang.org/protobuf/proto"
+)
+
+// sceneNotice 场景通知记录（推送给单个角色的通知 roleIDs 只有一个元素）
+type sceneNotice struct {
+	roleIDs []int64
+	opCode  uint32
+	msg     proto.Message
+}
+
+// fakeSceneNotifier 记录场景通知
+type fakeSceneNotifier struct {
+	notices []sceneNotice
+}
+
+func (n *fakeSceneNotifier) PushToRole(ctx context.Context, roleID int64, opCode uint32, msg proto.Message) error {
+	n.notices = append(n.notices, sceneNotice{roleIDs: []int64{roleID}, opCode: opCode, msg: msg})
+	return nil
+}
+
+func (n *fakeSceneNotifier) BroadcastToRoles(ctx context.Context, roleIDs []int64, opCode uint32, msg proto.Message) error {
+	n.notices = append(n.notices, sceneNotice{roleIDs: slices.Sorted(slices.Values(roleIDs)), opCode: opCode, msg: msg})
+	return nil
+}
+
+// take 取出并清空已记录的通知
+func (n *fakeSceneNotifier) take() []sceneNotice {
+	notices := n.notices
+	n.notices = nil
+	return notices
+}
+
+func newTestSceneService(t *testing.T, cfg *SceneConfig, roleIDs ...int64) (*SceneService, *fakeSceneNotifier) {
+	t.Helper()
+
+	roles := fakeRoles{}
+	for _, id := range roleIDs {
+		roles[id] = &model.Role{ID: id, Nickname: "role", Level: 1}
+	}
+
+	notifier := &fakeSceneNotifier{}
+	sceneMgr := manager.NewSceneManager(logger.Noop(), &cfg.AOI)
+	return newSceneService(logger.Noop(), cfg, roles, sceneMgr, notifier, nil), notifier
+}
+
+func testSceneConfig() *SceneConfig {
+	return &SceneConfig{
+		SpawnX:      100,
+		SpawnZ:      100,
+		SpawnRadius: -1,
+		AOI:         manager.AOIConfig{CellSize: 10},
+	}
+}
+
+func mustEnterScene(t *testing.T, svc *SceneService, roleID int64, mapID int32) *api.EnterSceneResponse {
+	t.Helper()
+
+	resp, err := svc.HandleEnterScene(context.Background(), roleID, mapID)
+	if err != nil || resp.Code != common.ErrCode_ERR_CODE_OK {
+		t.Fatalf("enter scene failed: code=%v err=%v", resp.GetCode(), err)
+	}
+	return resp
+}
+
+func mustMove(t *testing.T, svc *SceneService, roleID int64, x, z float32) {
+	t.Helper()
+
+	if _, err := svc.Move(context.Background(), roleID, &api.Position{X: x, Z: z}); err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+}
+
+func TestSceneService_EnterNotifiesNearby(t *testing.T) {
+	svc, notifier := newTestSceneService(t, testSceneConfig(), 1, 2)
+
+	resp := mustEnterScene(t, svc, 1, 0)
+	if resp.Scene.MapId != defaultSceneMapID || resp.Scene.Pos.X != 100 || resp.Scene.Pos.Z != 100 {
+		t.Fatalf("unexpected scene: map=%d pos=(%v, %v)", resp.Scene.MapId, resp.Scene.Pos.X, resp.Scene.Pos.Z)
+	}
+	if len(resp.Players) != 0 || len(notifier.take()) != 0 {
+		t.Fatalf("first player should see and notify nobody")
+	}
+
+	resp = mustEnterScene(t, svc, 2, 0)
+	if len(resp.Players) != 1 || resp.Players[0].RoleId != 1 || resp.Players[0].Nickname != "role" {
+		t.Fatalf("second player should see role 1, got %+v", resp.Players)
+	}
+
+	notices := notifier.take()
+	if len(notices) != 1 || notices[0].opCode != uint32(api.OpCode_OP_SCENE_PLAYER_ENTER_NOTIFY) ||
+		!slices.Equal(notices[0].roleIDs, []int64{1}) {
+		t.Fatalf("unexpected notices: %+v", notices)
+	}
+	if notify := notices[0].msg.(*api.ScenePlayerEnterNotify); notify.Players[0].RoleId != 2 {
+		t.Fatalf("enter notify should carry role 2, got %+v", notify.Players)
+	}
+}
+
+func TestSceneService_ReenterKeepsPosition(t *testing.T) {
+	svc, _ := newTestSceneService(t, testSceneConfig(), 1)
+
+	mustEnterScene(t, svc, 1, 0)
+	mustMove(t, svc, 1, 105, 103)
+
+	resp := mustEnterScene(t, svc, 1, 0)
+	if resp.Scene.Pos.X != 105 || resp.Scene.Pos.Z != 103 {
+		t.Fatalf("re-enter should keep position, got (%v, %v)", resp.Scene.Pos.X, resp.Scene.Pos.Z)
+	}
+}
+
+func TestSceneService_SpawnScatter(t *testing.T) {
+	cfg := testSceneConfig()
+	cfg.SpawnRadius = 5
+	svc, _ := newTestSceneService(t, cfg, 1, 2)
+
+	a := mustEnterScene(t, svc, 1, 0).Scene.Pos
+	b := mustEnterScene(t, svc, 2, 0).Scene.Pos
+	if a.X == b.X && a.Z == b.Z {
+		t.Fatalf("spawn positions should be scattered, both at (%v, %v)", a.X, a.Z)
+	}
+	for _, p := range []*api.Position{a, b} {
+		dx, dz := p.X-100, p.Z-100
+		if dx*dx+dz*dz > 25 {
+			t.Fatalf("spawn (%v, %v) outside radius", p.X, p.Z)
+		}
+	}
+}
+
+func TestSceneService_MoveFanOut(t *testing.T) {
+	svc, notifier := newTestSceneService(t, testSceneConfig(), 1, 2, 3, 4)
+	ctx := context.Background()
+
+	// 场景 1001，格子边长 10：1 在 (105,105)，2 在 (95,105)，3 在 (115,105)，4 在 (125,105)
+	for _, id := range []int64{1, 2, 3, 4} {
+		mustEnterScene(t, svc, id, 0)
+	}
+	mustMove(t, svc, 2, 95, 105)
+	mustMove(t, svc, 3, 115, 105)
+	mustMove(t, svc, 4, 125, 105)
+	mustMove(t, svc, 1, 105, 105)
+	notifier.take()
+
+	// 1 移动到 (115,105)：2 离开视野，3 一直可见，4 进入视野
+	pos, err := svc.Move(ctx, 1, &api.Position{X: 115, Z: 105})
+	if err != nil || pos.X != 115 {
+		t.Fatalf("move failed: pos=%v err=%v", pos, err)
+	}
+
+	got := make(map[uint32][]sceneNotice)
+	for _, n := range notifier.take() {
+		got[n.opCode] = append(got[n.opCode], n)
+	}
+
+	moves := got[uint32(api.OpCode_OP_SCENE_PLAYER_MOVE_NOTIFY)]
+	if len(moves) != 1 || !slices.Equal(moves[0].roleIDs, []int64{3}) {
+		t.Fatalf("move notify = %+v, want to [3]", moves)
+	}
+
+	enters := got[uint32(api.OpCode_OP_SCENE_PLAYER_ENTER_NOTIFY)]
+	if len(enters) != 2 || !slices.Equal(enters[0].roleIDs, []int64{4}) || !slices.Equal(enters[1].roleIDs, []int64{1}) {
+		t.Fatalf("enter notify = %+v, want to [4] and [1]", enters)
+	}
+	if players := enters[1].msg.(*api.ScenePlayerEnterNotify).Players; len(players) != 1 || players[0].RoleId != 4 {
+		t.Fatalf("mover should see role 4 appear, got %+v", players)
+	}
+
+	leaves := got[uint32(api.OpCode_OP_SCENE_PLAYER_LEAVE_NOTIFY)]
+	if len(leaves) != 2 || !slices.Equal(leaves[0].roleIDs, []int64{2}) || !slices.Equal(leaves[1].roleIDs, []int64{1}) {
+		t.Fatalf("leave notify = %+v, want to [2] and [1]", leaves)
+	}
+	if ids := leaves[1].msg.(*api.ScenePlayerLeaveNotify).RoleIds; !slices.Equal(ids, []int64{2}) {
+		t.Fatalf("mover should see role 2 disappear, got %v", ids)
+	}
+}
+
+func TestSceneService_MoveInvalid(t *testing.T) {
+	cfg := testSceneConfig()
+	cfg.MaxMoveDistance = 10
+	svc, _ := newTestSceneService(t, cfg, 1)
+	ctx := context.Background()
+
+	if _, err := svc.Move(ctx, 1, &api.Position{X: 100, Z: 100}); !errors.Is(err, manager.ErrRoleNotInScene) {
+		t.Fatalf("move outside scene err = %v, want ErrRoleNotInScene", err)
+	}
+
+	mustEnterScene(t, svc, 1, 0)
+	if _, err := svc.Move(ctx, 1, &api.Position{X: 150, Z: 100}); !errors.Is(err, ErrSceneInvalidPosition) {
+		t.Fatalf("too far move err = %v, want ErrSceneInvalidPosition", err)
+	}
+	if _, err := svc.Move(ctx, 1, nil); !errors.Is(err, ErrSceneInvalidPosition) {
+		t.Fatalf("nil position err = %v, want ErrSceneInvalidPosition", err)
+	}
+	mustMove(t, svc, 1, 105, 105)
+}
+
+func TestSceneService_LeaveAndOffline(t *testing.T) {
+	svc, notifier := newTestSceneService(t, testSceneConfig(), 1, 2, 3)
+	ctx := context.Background()
+
+	for _, id := range []int64{1, 2, 3} {
+		mustEnterScene(t, svc, id, 0)
+	}
+	notifier.take()
+
+	if err := svc.Leave(ctx, 1); err != nil {
+		t.Fatalf("leave failed: %v", err)
+	}
+	notices := notifier.take()
+	if len(notices) != 1 || notices[0].opCode != uint32(api.OpCode_OP_SCENE_PLAYER_LEAVE_NOTIFY) ||
+		!slices.Equal(notices[0].roleIDs, []int64{2, 3}) {
+		t.Fatalf("unexpected leave notices: %+v", notices)
+	}
+	if err := svc.Leave(ctx, 1); !errors.Is(err, manager.ErrRoleNotInScene) {
+		t.Fatalf("second leave err = %v, want ErrRoleNotInScene", err)
+	}
+
+	// 下线同样离开场景，不在场景中的角色下线不产生通知
+	svc.handleRoleOffline(2)
+	svc.handleRoleOffline(1)
+	notices = notifier.take()
+	if len(notices) != 1 || !slices.Equal(notices[0].roleIDs, []int64{3}) {
+		t.Fatalf("unexpected offline notices: %+v", notices)
+	}
+}
@@ -0,0 +1,665 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+)
+
+// errInjected 测试注入的故障
+var errInjected = errors.New("injected failure")
+
+// fakeState 内存仓储的可快照状态
+type fakeState struct {
+	bags   map[int32][]model.Item // bagType -> 物品实例
+	caps   map[int32]int32        // bagType -> 容量
+	dolls  []model.Doll
+	gacha  []model.GachaRecord
+	logs   []model.GachaDrawLog
+	nextID int64
+}
+
+func (s fakeState) clone() fakeState {
+	c := fakeState{
+		bags:   make(map[int32][]model.Item, len(s.bags)),
+		caps:   make(map[int32]int32, len(s.caps)),
+		dolls:  append([]model.Doll(nil), s.dolls...),
+		gacha:  append([]model.GachaRecord(nil), s.gacha...),
+		logs:   append([]model.GachaDrawLog(nil), s.logs...),
+		nextID: s.nextID,
+	}
+	for bagType, items := range s.bags {
+		c.bags[bagType] = append([]model.Item(nil), items...)
+	}
+	for bagType, capacity := range s.caps {
+		c.caps[bagType] = capacity
+	}
+	return c
+}
+
+// fakePlayerRepo 内存版 PlayerRepository，支持在第 N 次调用某方法时注入故障
+type fakePlayerRepo struct {
+	state fakeState
+
+	failMethod string
+	failCall   int
+	calls      map[string]int
+}
+
+func newFakePlayerRepo() *fakePlayerRepo {
+	return &fakePlayerRepo{
+		state: fakeState{bags: make(map[int32][]model.Item), caps: make(map[int32]int32), nextID: 1},
+		calls: make(map[string]int),
+	}
+}
+
+// failAt 设置在第 n 次调用 method 时返回 errInjected
+func (r *fakePlayerRepo) failAt(method string, n int) {
+	r.failMethod = method
+	r.failCall = n
+}
+
+func (r *fakePlayerRepo) hit(method string) error {
+	r.calls[method]++
+	if method == r.failMethod && r.calls[method] == r.failCall {
+		return fmt.Errorf("%s: %w", method, errInjected)
+	}
+	return nil
+}
+
+func (r *fakePlayerRepo) GetDolls(ctx context.Context, playerID int64) ([]*model.Doll, error) {
+	if err := r.hit("GetDolls"); err != nil {
+		return nil, err
+	}
+	dolls := make([]*model.Doll, 0, len(r.state.dolls))
+	for i := range r.state.dolls {
+		d := r.state.dolls[i]
+		dolls = append(dolls, &d)
+	}
+	return dolls, nil
+}
+
+func (r *fakePlayerRepo) GetDollByID(ctx context.Context, playerID int64, dollID int64) (*model.Doll, error) {
+	dolls, err := r.GetDolls(ctx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dolls {
+		if d.ID == dollID {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("doll not found: player_id=%d, doll_id=%d", playerID, dollID)
+}
+
+func (r *fakePlayerRepo) AddDoll(ctx context.Context, doll *model.Doll) error {
+	if err := r.hit("AddDoll"); err != nil {
+		return err
+	}
+	doll.ID = r.state.nextID
+	r.state.nextID++
+	r.state.dolls = append(r.state.dolls, *doll)
+	return nil
+}
+
+func (r *fakePlayerRepo) AddDolls(ctx context.Context, dolls []*model.Doll) error {
+	for _, d := range dolls {
+		if err := r.AddDoll(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *fakePlayerRepo) updateDoll(method string, dollID int64, fn func(d *model.Doll)) error {
+	if err := r.hit(method); err != nil {
+		return err
+	}
+	for i := range r.state.dolls {
+		if r.state.dolls[i].ID == dollID {
+			fn(&r.state.dolls[i])
+			return nil
+		}
+	}
+	return fmt.Errorf("doll not found")
+}
+
+func (r *fakePlayerRepo) UpdateDollLock(ctx context.Context, playerID int64, dollID int64, isLocked bool) error {
+	return r.updateDoll("UpdateDollLock", dollID, func(d *model.Doll) { d.IsLocked = isLocked })
+}
+
+func (r *fakePlayerRepo) UpdateDollRedeem(ctx context.Context, playerID int64, dollID int64, isRedeemed bool) error {
+	return r.updateDoll("UpdateDollRedeem", dollID, func(d *model.Doll) { d.IsRedeemed = isRedeemed })
+}
+
+func (r *fakePlayerRepo) UpdateDollQuality(ctx context.Context, playerID int64, dollID int64, quality int16) error {
+	return r.updateDoll("UpdateDollQuality", dollID, func(d *model.Doll) { d.Quality = quality })
+}
+
+func (r *fakePlayerRepo) DeleteDoll(ctx context.Context, playerID int64, dollID int64) error {
+	return r.DeleteDolls(ctx, playerID, []int64{dollID})
+}
+
+func (r *fakePlayerRepo) DeleteDolls(ctx context.Context, playerID int64, dollIDs []int64) error {
+	if err := r.hit("DeleteDolls"); err != nil {
+		return err
+	}
+	idSet := make(map[int64]bool, len(dollIDs))
+	for _, id := range dollIDs {
+		idSet[id] = true
+	}
+	kept := r.state.dolls[:0:0]
+	for _, d := range r.state.dolls {
+		if !idSet[d.ID] {
+			kept = append(kept, d)
+		}
+	}
+	r.state.dolls = kept
+	return nil
+}
+
+func (r *fakePlayerRepo) GetGachaRecords(ctx context.Context, roleID int64) (*model.PlayerGacha, error) {
+	if err := r.hit("GetGachaRecords"); err != nil {
+		return nil, err
+	}
+	data := &model.PlayerGacha{RoleID: roleID}
+	for i := range r.state.gacha {
+		rec := r.state.gacha[i]
+		data.Records = append(data.Records, &rec)
+	}
+	return data, nil
+}
+
+func (r *fakePlayerRepo) SaveGachaRecords(ctx context.Context, gacha *model.PlayerGacha) error {
+	if err := r.hit("SaveGachaRecords"); err != nil {
+		return err
+	}
+	r.state.gacha = r.state.gacha[:0:0]
+	for _, rec := range gacha.Records {
+		r.state.gacha = append(r.state.gacha, *rec)
+	}
+	return nil
+}
+
+func (r *fakePlayerRepo) AddGachaDrawLogs(ctx context.Context, logs []*model.GachaDrawLog) error {
+	if err := r.hit("AddGachaDrawLogs"); err != nil {
+		return err
+	}
+	for _, l := range logs {
+		c := *l
+		c.ID = int64(len(r.state.logs) + 1)
+		r.state.logs = append(r.state.logs, c)
+	}
+	return nil
+}
+
+func (r *fakePlayerRepo) ListGachaDrawLogs(ctx context.Context, roleID int64, poolID int32, offset, limit uint64) ([]*model.GachaDrawLog, int64, error) {
+	var matched []*model.GachaDrawLog
+	for i := len(r.state.logs) - 1; i >= 0; i-- {
+		l := r.state.logs[i]
+		if l.RoleID == roleID && (poolID == 0 || l.PoolID == poolID) {
+			matched = append(matched, &l)
+		}
+	}
+	total := int64(len(matched))
+	if offset >= uint64(total) {
+		return []*model.GachaDrawLog{}, total, nil
+	}
+	end := offset + limit
+	if end > uint64(total) {
+		end = uint64(total)
+	}
+	return matched[offset:end], total, nil
+}
+
+func (r *fakePlayerRepo) GetGachaDrawLog(ctx context.Context, id int64) (*model.GachaDrawLog, error) {
+	for _, l := range r.state.logs {
+		if l.ID == id {
+			return &l, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakePlayerRepo) GetGachaPoolStats(ctx context.Context, poolID int32, from, to time.Time) ([]*model.GachaDropStats, error) {
+	var stats []*model.GachaDropStats
+	byDrop := make(map[int32]*model.GachaDropStats)
+	byItem := make(map[[2]int32]*model.GachaItemStats)
+	for _, l := range r.state.logs {
+		if l.PoolID != poolID || l.CreatedAt < from.Unix() || l.CreatedAt >= to.Unix() {
+			continue
+		}
+		drop, ok := byDrop[l.DropID]
+		if !ok {
+			drop = &model.GachaDropStats{DropID: l.DropID}
+			byDrop[l.DropID] = drop
+			stats = append(stats, drop)
+		}
+		drop.Draws++
+		for _, res := range l.Results {
+			key := [2]int32{l.DropID, res.ItemID}
+			item, ok := byItem[key]
+			if !ok {
+				item = &model.GachaItemStats{ItemID: res.ItemID}
+				byItem[key] = item
+				drop.Items = append(drop.Items, item)
+			}
+			item.Hits++
+			item.Total += int64(res.Count)
+		}
+	}
+	return stats, nil
+}
+
+func (r *fakePlayerRepo) GetBag(ctx context.Context, roleID int64, bagType int32) (*model.PlayerBag, error) {
+	if err := r.hit("GetBag"); err != nil {
+		return nil, err
+	}
+	bag := model.NewPlayerBag(roleID, bagType)
+	bag.Capacity = r.state.caps[bagType]
+	for _, item := range r.state.bags[bagType] {
+		item := item
+		bag.Items = append(bag.Items, &item)
+	}
+	return bag, nil
+}
+
+func (r *fakePlayerRepo) SaveBag(ctx context.Context, bag *model.PlayerBag) error {
+	if err := r.hit("SaveBag"); err != nil {
+		return err
+	}
+	items := make([]model.Item, 0, len(bag.Items))
+	for _, item := range bag.Items {
+		items = append(items, *item)
+	}
+	r.state.bags[bag.BagType] = items
+	r.state.caps[bag.BagType] = bag.Capacity
+	return nil
+}
+
+// fakeShopRepo 内存版 ShopRepository
+type fakeShopRepo struct {
+	state fakeShopState
+	calls map[string]int
+}
+
+// fakeShopState 商店仓储的可快照状态
+type fakeShopState struct {
+	shops  map[int64]model.PlayerShop
+	global map[string]int32 // "shopItemID:periodStart" -> sold
+}
+
+func (s fakeShopState) clone() fakeShopState {
+	c := fakeShopState{
+		shops:  make(map[int64]model.PlayerShop, len(s.shops)),
+		global: make(map[string]int32, len(s.global)),
+	}
+	for roleID, shop := range s.shops {
+		c.shops[roleID] = clonePlayerShop(shop)
+	}
+	for k, v := range s.global {
+		c.global[k] = v
+	}
+	return c
+}
+
+func clonePlayerShop(shop model.PlayerShop) model.PlayerShop {
+	c := model.PlayerShop{RoleID: shop.RoleID}
+	for _, p := range shop.Purchases {
+		cp := *p
+		c.Purchases = append(c.Purchases, &cp)
+	}
+	for _, m := range shop.Mystery {
+		cm := *m
+		cm.ItemIDs = append([]int32(nil), m.ItemIDs...)
+		c.Mystery = append(c.Mystery, &cm)
+	}
+	return c
+}
+
+func newFakeShopRepo() *fakeShopRepo {
+	return &fakeShopRepo{
+		state: fakeShopState{shops: make(map[int64]model.PlayerShop), global: make(map[string]int32)},
+		calls: make(map[string]int),
+	}
+}
+
+func (r *fakeShopRepo) GetPlayerShop(ctx context.Context, roleID int64) (*model.PlayerShop, error) {
+	r.calls["GetPlayerShop"]++
+	shop, ok := r.state.shops[roleID]
+	if !ok {
+		return &model.PlayerShop{RoleID: roleID}, nil
+	}
+	c := clonePlayerShop(shop)
+	return &c, nil
+}
+
+func (r *fakeShopRepo) SavePlayerShop(ctx context.Context, shop *model.PlayerShop) error {
+	r.calls["SavePlayerShop"]++
+	r.state.shops[shop.RoleID] = clonePlayerShop(*shop)
+	return nil
+}
+
+func (r *fakeShopRepo) GetGlobalSold(ctx context.Context, shopItemID int32, periodStart int64) (int32, error) {
+	return r.state.global[fmt.Sprintf("%d:%d", shopItemID, periodStart)], nil
+}
+
+func (r *fakeShopRepo) AddGlobalSold(ctx context.Context, shopItemID int32, periodStart int64, count int32, limit int32) (bool, error) {
+	key := fmt.Sprintf("%d:%d", shopItemID, periodStart)
+	if r.state.global[key]+count > limit {
+		return false, nil
+	}
+	r.state.global[key] += count
+	return true, nil
+}
+
+// fakeMailRepo 内存版 MailRepository
+type fakeMailRepo struct {
+	state fakeMailState
+}
+
+// fakeMailState 邮件仓储的可快照状态
+type fakeMailState struct {
+	mails   []model.Mail
+	deleted map[int64]bool // 已删除的邮件ID（保留记录防止全服邮件重复投递）
+	global  []model.GlobalMail
+	nextID  int64
+}
+
+func (s fakeMailState) clone() fakeMailState {
+	c := fakeMailState{
+		mails:   append([]model.Mail(nil), s.mails...),
+		deleted: make(map[int64]bool, len(s.deleted)),
+		global:  append([]model.GlobalMail(nil), s.global...),
+		nextID:  s.nextID,
+	}
+	for id := range s.deleted {
+		c.deleted[id] = true
+	}
+	return c
+}
+
+func newFakeMailRepo() *fakeMailRepo {
+	return &fakeMailRepo{state: fakeMailState{deleted: make(map[int64]bool), nextID: 1}}
+}
+
+func (r *fakeMailRepo) AddMails(ctx context.Context, mails []*model.Mail) error {
+	for _, m := range mails {
+		if m.GlobalID > 0 && r.delivered(m.RoleID, m.GlobalID) {
+			continue
+		}
+		c := *m
+		c.ID = r.state.nextID
+		r.state.nextID++
+		r.state.mails = append(r.state.mails, c)
+	}
+	return nil
+}
+
+func (r *fakeMailRepo) delivered(roleID, globalID int64) bool {
+	for _, m := range r.state.mails {
+		if m.RoleID == roleID && m.GlobalID == globalID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *fakeMailRepo) ListMails(ctx context.Context, roleID int64, now int64, limit uint64) ([]*model.Mail, error) {
+	var mails []*model.Mail
+	for i := len(r.state.mails) - 1; i >= 0 && uint64(len(mails)) < limit; i-- {
+		m := r.state.mails[i]
+		if m.RoleID == roleID && !r.state.deleted[m.ID] && !m.IsExpired(now) {
+			mails = append(mails, &m)
+		}
+	}
+	return mails, nil
+}
+
+func (r *fakeMailRepo) GetMail(ctx context.Context, roleID int64, mailID int64) (*model.Mail, error) {
+	for _, m := range r.state.mails {
+		if m.ID == mailID && m.RoleID == roleID && !r.state.deleted[m.ID] {
+			return &m, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeMailRepo) UpdateMailState(ctx context.Context, mail *model.Mail) error {
+	for i := range r.state.mails {
+		if r.state.mails[i].ID == mail.ID {
+			r.state.mails[i].IsRead = mail.IsRead
+			r.state.mails[i].IsClaimed = mail.IsClaimed
+		}
+	}
+	return nil
+}
+
+func (r *fakeMailRepo) DeleteMails(ctx context.Context, roleID int64, mailIDs []int64) error {
+	for _, m := range r.state.mails {
+		if m.RoleID == roleID && slices.Contains(mailIDs, m.ID) {
+			r.state.deleted[m.ID] = true
+		}
+	}
+	return nil
+}
+
+func (r *fakeMailRepo) AddGlobalMail(ctx context.Context, mail *model.GlobalMail) error {
+	mail.ID = r.state.nextID
+	r.state.nextID++
+	r.state.global = append(r.state.global, *mail)
+	return nil
+}
+
+func (r *fakeMailRepo) ListPendingGlobalMails(ctx context.Context, roleID int64, now int64) ([]*model.GlobalMail, error) {
+	var mails []*model.GlobalMail
+	for _, g := range r.state.global {
+		if g.SendTime <= now && (g.ExpireTime == 0 || g.ExpireTime > now) && !r.delivered(roleID, g.ID) {
+			mails = append(mails, &g)
+		}
+	}
+	return mails, nil
+}
+
+func (r *fakeMailRepo) PurgeExpiredMails(ctx context.Context, now int64) (int64, error) {
+	var deleted int64
+	kept := r.state.mails[:0:0]
+	for _, m := range r.state.mails {
+		if m.IsExpired(now) {
+			deleted++
+			continue
+		}
+		kept = append(kept, m)
+	}
+	r.state.mails = kept
+	return deleted, nil
+}
+
+// fakeRoleRepo 内存版 RoleRepository
+type fakeRoleRepo struct {
+	growth map[int64]model.RoleGrowth
+}
+
+func newFakeRoleRepo() *fakeRoleRepo {
+	return &fakeRoleRepo{growth: make(map[int64]model.RoleGrowth)}
+}
+
+func (r *fakeRoleRepo) GetRoleGrowth(ctx context.Context, roleID int64) (*model.RoleGrowth, error) {
+	g, ok := r.growth[roleID]
+	if !ok {
+		return nil, fmt.Errorf("role %d not found", roleID)
+	}
+	return &g, nil
+}
+
+func (r *fakeRoleRepo) SaveRoleGrowth(ctx context.Context, roleID int64, growth *model.RoleGrowth) error {
+	r.growth[roleID] = *growth
+	return nil
+}
+
+// fakeRoles 内存版在线角色表
+type fakeRoles map[int64]*model.Role
+
+func (r fakeRoles) GetRole(roleID int64) (*model.Role, bool) {
+	role, ok := r[roleID]
+	return role, ok
+}
+
+func (r fakeRoles) UpdateRoleState(roleID int64, updateFunc func(*model.Role)) error {
+	role, ok := r[roleID]
+	if !ok {
+		return fmt.Errorf("role %d not found in memory", roleID)
+	}
+	updateFunc(role)
+	return nil
+}
+
+// fakeUnitOfWork 通过快照/恢复内存仓储状态模拟事务提交与回滚
+type fakeUnitOfWork struct {
+	repo      *fakePlayerRepo
+	shop      *fakeShopRepo // 可选
+	mail      *fakeMailRepo // 可选
+	role      *fakeRoleRepo // 可选
+	commits   int
+	rollbacks int
+}
+
+func (u *fakeUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	snapshot := u.repo.state.clone()
+	var shopSnapshot fakeShopState
+	if u.shop != nil {
+		shopSnapshot = u.shop.state.clone()
+	}
+	var mailSnapshot fakeMailState
+	if u.mail != nil {
+		mailSnapshot = u.mail.state.clone()
+	}
+	var roleSnapshot map[int64]model.RoleGrowth
+	if u.role != nil {
+		roleSnapshot = maps.Clone(u.role.growth)
+	}
+
+	if err := fn(ctx); err != nil {
+		u.repo.state = snapshot
+		if u.shop != nil {
+			u.shop.state = shopSnapshot
+		}
+		if u.mail != nil {
+			u.mail.state = mailSnapshot
+		}
+		if u.role != nil {
+			u.role.growth = roleSnapshot
+		}
+		u.rollbacks++
+		return err
+	}
+	u.commits++
+	return nil
+}
+
+// 测试用配置 ID
+const (
+	testCostItemID   = 1001
+	testRewardItemID = 1002
+	testDollID       = 2001
+	testDropID       = 100
+	testPoolID       = 1
+	testBrokenPoolID = 2 // 掉落 ID 未配置的池子
+)
+
+func testItemConfig(id int32, itemType int32) map[string]interface{} {
+	return map[string]interface{}{
+		"id": float64(id), "name": fmt.Sprintf("item_%d", id), "type": float64(itemType), "sub_type": float64(0),
+		"rarity": float64(1), "quality": float64(1), "max_stack": float64(999),
+		"can_trade": true, "can_sell": true, "can_drop": true, "can_destroy": true,
+		"bind_type": float64(0), "use_level": float64(0), "use_vocation": float64(0),
+		"expire_type": float64(0), "expire_time": float64(0), "use_skill_ids": []interface{}{},
+		"cooldown": float64(0), "cooldown_group": float64(0), "tags": []interface{}{},
+	}
+}
+
+// setupTestConfig 构造测试所需的最小配置表
+func setupTestConfig(t *testing.T) {
+	t.Helper()
+
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("failed to build test config: %v", err)
+		}
+	}
+
+	tables := &gameconfig.Tables{}
+	var err error
+
+	tables.TbItem, err = gameconfig.NewTbItem([]map[string]interface{}{
+		testItemConfig(testCostItemID, gameconfig.ItemType_Item),
+		testItemConfig(testRewardItemID, gameconfig.ItemType_Item),
+	})
+	must(err)
+
+	tables.TbDoll, err = gameconfig.NewTbDoll([]map[string]interface{}{
+		{"id": float64(testDollID), "series": float64(1), "max_quality": float64(3),
+			"is_limited": false, "can_exchange_real": false, "real_exchange_cost": ""},
+	})
+	must(err)
+
+	tables.TbDropGroup, err = gameconfig.NewTbDropGroup([]map[string]interface{}{
+		{"id": float64(1), "drop_id": float64(testDropID), "drop_type": float64(gameconfig.DropType_FIXED), "roll_count": float64(1)},
+	})
+	must(err)
+
+	tables.TbDropItem, err = gameconfig.NewTbDropItem([]map[string]interface{}{
+		{"id": float64(1), "group_id": float64(1), "item_id": float64(testDollID), "count_min": float64(1), "count_max": float64(1), "drop_value": float64(0)},
+		{"id": float64(2), "group_id": float64(1), "item_id": float64(testRewardItemID), "count_min": float64(5), "count_max": float64(5), "drop_value": float64(0)},
+	})
+	must(err)
+
+	tables.TbGacha, err = gameconfig.NewTbGacha([]map[string]interface{}{
+		{"id": float64(testPoolID), "type": float64(1), "name": "normal", "cost_item": float64(testCostItemID), "cost_count": float64(1), "drop_id": float64(testDropID)},
+		{"id": float64(testBrokenPoolID), "type": float64(1), "name": "broken", "cost_item": float64(testCostItemID), "cost_count": float64(1), "drop_id": float64(999)},
+	})
+	must(err)
+
+	tables.TbGachaPity, err = gameconfig.NewTbGachaPity(nil)
+	must(err)
+
+	gameconfig.T = tables
+}
+
+// bagItems 返回指定背包按道具ID汇总后的数量快照（排序后便于比较）
+func bagItems(r *fakePlayerRepo, bagType int32) []string {
+	counts := make(map[int32]int32)
+	for _, item := range r.state.bags[bagType] {
+		counts[item.ConfigID] += item.Count
+	}
+	var out []string
+	for id, n := range counts {
+		out = append(out, fmt.Sprintf("%d:%d", id, n))
+	}
+	sort.Strings(out)
+	return out
+}
+
+// setBagCount 将背包中指定道具替换为一个数量为 count 的永久、未绑定槽位
+func setBagCount(r *fakePlayerRepo, bagType int32, itemID int32, count int32) {
+	items := make([]model.Item, 0, len(r.state.bags[bagType])+1)
+	slot := int32(0)
+	for _, item := range r.state.bags[bagType] {
+		if item.ConfigID == itemID {
+			continue
+		}
+		items = append(items, item)
+		slot = max(slot, item.SlotIndex+1)
+	}
+	items = append(items, model.Item{RoleID: testRoleID, ConfigID: itemID, SlotIndex: slot, Count: count})
+	r.state.bags[bagType] = items
+}
@@ -0,0 +1,542 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/manager"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/app/game/internal/repository"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// 商店业务错误，Handler 据此映射错误码
+var (
+	ErrShopItemNotFound         = errors.New("shop item not found")
+	ErrShopRoleOffline          = errors.New("role is not online")
+	ErrShopLevelNotEnough       = errors.New("role level not enough")
+	ErrShopNotOnSale            = errors.New("shop item not on sale")
+	ErrShopLimitExceeded        = errors.New("shop purchase limit exceeded")
+	ErrShopSoldOut              = errors.New("shop item sold out")
+	ErrShopInsufficientCurrency = errors.New("insufficient currency")
+)
+
+// defaultMysterySlots 神秘商店默认上架数量
+const defaultMysterySlots = 4
+
+// ShopConfig 商店配置
+type ShopConfig struct {
+	// CurrencyItems 货币类型 (gameconfig.CurrencyType_*) 到背包道具ID的映射
+	CurrencyItems map[int32]int32 `mapstructure:"currency_items"`
+
+	// GlobalStockShops 库存全服共享的商店ID，其余商店按角色限购
+	GlobalStockShops []int32 `mapstructure:"global_stock_shops"`
+
+	// RefreshTypes 神秘商店刷新类型 (gameconfig.RefreshType_*)，未配置时每日刷新
+	RefreshTypes map[int32]int32 `mapstructure:"refresh_types"`
+
+	// MysterySlots 神秘商店每次刷新上架的商品数量
+	MysterySlots int `mapstructure:"mystery_slots"`
+}
+
+// ShopItemView 商品视图
+type ShopItemView struct {
+	ID           int32
+	ItemID       int32
+	CurrencyType int32
+	Price        int32 // 原价
+	FinalPrice   int32 // 折后单价
+	Discount     int32 // 折扣（百分比，100 表示不打折）
+	StockType    int32
+	StockLimit   int32
+	Bought       int32 // 本周期已购数量（全服库存商品为全服已售数量）
+	GlobalStock  bool  // 是否全服共享库存
+	UnlockLevel  int32
+	StartTime    int64 // 限时商品开始时间，非限时商品为 0
+	EndTime      int64 // 限时商品结束时间，非限时商品为 0
+}
+
+// ShopView 商店视图
+type ShopView struct {
+	ShopID          int32
+	Items           []*ShopItemView
+	NextRefreshTime int64 // 神秘商店下次刷新时间，其他商店为 0
+}
+
+// roleGetter 在线角色查询（由 manager.RoleManager 实现）
+type roleGetter interface {
+	GetRole(roleID int64) (*model.Role, bool)
+}
+
+// ShopService 商店服务
+type ShopService struct {
+	logger   logger.Logger
+	cfg      *ShopConfig
+	shopRepo repository.ShopRepository
+	uow      repository.UnitOfWork
+	roles    roleGetter
+	dollSvc  *DollService
+	bagSvc   *BagService
+
+	globalShops map[int32]bool
+	now         func() time.Time
+}
+
+// NewShopService 创建商店服务
+func NewShopService(
+	l logger.Logger,
+	cfg *ShopConfig,
+	shopRepo repository.ShopRepository,
+	uow repository.UnitOfWork,
+	roleMgr *manager.RoleManager,
+	dollSvc *DollService,
+	bagSvc *BagService,
+) *ShopService {
+	return newShopService(l, cfg, shopRepo, uow, roleMgr, dollSvc, bagSvc)
+}
+
+func newShopService(
+	l logger.Logger,
+	cfg *ShopConfig,
+	shopRepo repository.ShopRepository,
+	uow repository.UnitOfWork,
+	roles roleGetter,
+	dollSvc *DollService,
+	bagSvc *BagService,
+) *ShopService {
+	globalShops := make(map[int32]bool, len(cfg.GlobalStockShops))
+	for _, shopID := range cfg.GlobalStockShops {
+		globalShops[shopID] = true
+	}
+
+	return &ShopService{
+		logger:      l.Named("service.shop"),
+		cfg:         cfg,
+		shopRepo:    shopRepo,
+		uow:         uow,
+		roles:       roles,
+		dollSvc:     dollSvc,
+		bagSvc:      bagSvc,
+		globalShops: globalShops,
+		now:         time.Now,
+	}
+}
+
+// ListShops 获取商店列表
+// shopID 为 0 时返回全部商店；限时商品只返回处于售卖窗口内的，神秘商店只返回当前货架
+func (s *ShopService) ListShops(ctx context.Context, roleID int64, shopID int32) ([]*ShopView, error) {
+	now := s.now()
+
+	var views []*ShopView
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		playerShop, err := s.shopRepo.GetPlayerShop(ctx, roleID)
+		if err != nil {
+			return err
+		}
+
+		dirty := false
+		viewIndex := make(map[int32]*ShopView)
+		for _, v := range gameconfig.T.TbShopItem.GetDataList() {
+			entry, ok := toShopEntry(v)
+			if !ok || (shopID != 0 && entry.ShopId != shopID) {
+				continue
+			}
+
+			view, ok := viewIndex[entry.ShopId]
+			if !ok {
+				view = &ShopView{ShopID: entry.ShopId}
+				viewIndex[entry.ShopId] = view
+				views = append(views, view)
+			}
+
+			if entry.mystery {
+				shelf, refreshed := s.ensureMysteryShelf(playerShop, entry.ShopId, now)
+				dirty = dirty || refreshed
+				view.NextRefreshTime = s.nextRefreshTime(entry.ShopId, now)
+				if !shelf.HasItem(entry.Id) {
+					continue
+				}
+			}
+			if entry.timeLimited && !entry.onSale(now) {
+				continue
+			}
+
+			itemView, err := s.buildItemView(ctx, playerShop, entry, now)
+			if err != nil {
+				return err
+			}
+			view.Items = append(view.Items, itemView)
+		}
+
+		// 货架刷新需要落库，保证玩家看到的商品与购买时一致
+		if dirty {
+			return s.shopRepo.SavePlayerShop(ctx, playerShop)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return views, nil
+}
+
+// Buy 购买商品，返回本周期累计购买数量
+// 货币扣除、库存扣减、发货与限购记录在同一个工作单元内完成
+func (s *ShopService) Buy(ctx context.Context, roleID int64, shopItemID int32, count int32) (int32, error) {
+	if count <= 0 {
+		return 0, fmt.Errorf("invalid buy count %d", count)
+	}
+
+	// 1. 校验商品配置
+	entry, ok := toShopEntry(gameconfig.T.TbShopItem.Get(shopItemID))
+	if !ok {
+		return 0, fmt.Errorf("%w: %d", ErrShopItemNotFound, shopItemID)
+	}
+
+	// 2. 校验解锁等级
+	role, ok := s.roles.GetRole(roleID)
+	if !ok {
+		return 0, fmt.Errorf("%w: %d", ErrShopRoleOffline, roleID)
+	}
+	if role.Level < entry.UnlockLevel {
+		return 0, fmt.Errorf("%w: level %d, need %d", ErrShopLevelNotEnough, role.Level, entry.UnlockLevel)
+	}
+
+	// TODO: 公会/联盟商店的等级与贡献要求需要等公会系统接入后校验
+
+	// 3. 校验限时售卖窗口
+	now := s.now()
+	if entry.timeLimited && !entry.onSale(now) {
+		return 0, fmt.Errorf("%w: %d", ErrShopNotOnSale, shopItemID)
+	}
+
+	// 4. 计算价格
+	currencyItem, ok := s.cfg.CurrencyItems[entry.CurrencyType]
+	if !ok {
+		return 0, fmt.Errorf("currency type %d not configured", entry.CurrencyType)
+	}
+	cost := entry.totalPrice(count)
+
+	var bought int32
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		playerShop, err := s.shopRepo.GetPlayerShop(ctx, roleID)
+		if err != nil {
+			return err
+		}
+
+		// 5. 神秘商店只能购买当前货架上的商品
+		if entry.mystery {
+			shelf, _ := s.ensureMysteryShelf(playerShop, entry.ShopId, now)
+			if !shelf.HasItem(entry.Id) {
+				return fmt.Errorf("%w: %d not on mystery shelf", ErrShopNotOnSale, shopItemID)
+			}
+		}
+
+		// 6. 限购检查
+		periodStart := stockPeriodStart(entry.StockType, now)
+		purchase := s.getOrResetPurchase(playerShop, entry.Id, periodStart)
+		if entry.StockType != gameconfig.StockType_Unlimited {
+			if s.globalShops[entry.ShopId] {
+				ok, err := s.shopRepo.AddGlobalSold(ctx, entry.Id, periodStart, count, entry.StockLimit)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("%w: %d", ErrShopSoldOut, shopItemID)
+				}
+			} else if purchase.Count+count > entry.StockLimit {
+				return fmt.Errorf("%w: bought %d, limit %d", ErrShopLimitExceeded, purchase.Count, entry.StockLimit)
+			}
+		}
+
+		// 7. 扣除货币
+		have, err := s.bagSvc.GetItemCount(ctx, roleID, currencyItem)
+		if err != nil {
+			return err
+		}
+		if int64(have) < cost {
+			return fmt.Errorf("%w: have %d, need %d", ErrShopInsufficientCurrency, have, cost)
+		}
+		if err := s.bagSvc.ConsumeItem(ctx, roleID, currencyItem, int32(cost)); err != nil {
+			return err
+		}
+
+		// 8. 发货
+		if err := grantItem(ctx, s.dollSvc, s.bagSvc, roleID, entry.ItemId, count); err != nil {
+			return fmt.Errorf("failed to grant shop item %d: %w", shopItemID, err)
+		}
+
+		// 9. 更新限购记录
+		purchase.Count += count
+		purchase.LastTime = now.Unix()
+		if err := s.shopRepo.SavePlayerShop(ctx, playerShop); err != nil {
+			return err
+		}
+
+		bought = purchase.Count
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.logger.Info("shop item bought",
+		"role_id", roleID,
+		"shop_item_id", shopItemID,
+		"count", count,
+		"cost", cost,
+	)
+	return bought, nil
+}
+
+// buildItemView 构建商品视图
+func (s *ShopService) buildItemView(ctx context.Context, playerShop *model.PlayerShop, entry *shopEntry, now time.Time) (*ShopItemView, error) {
+	view := &ShopItemView{
+		ID:           entry.Id,
+		ItemID:       entry.ItemId,
+		CurrencyType: entry.CurrencyType,
+		Price:        entry.Price,
+		FinalPrice:   int32(entry.totalPrice(1)),
+		Discount:     entry.Discount,
+		StockType:    entry.StockType,
+		StockLimit:   entry.StockLimit,
+		GlobalStock:  s.globalShops[entry.ShopId],
+		UnlockLevel:  entry.UnlockLevel,
+		StartTime:    entry.startTime,
+		EndTime:      entry.endTime,
+	}
+
+	if entry.StockType == gameconfig.StockType_Unlimited {
+		return view, nil
+	}
+
+	periodStart := stockPeriodStart(entry.StockType, now)
+	if view.GlobalStock {
+		sold, err := s.shopRepo.GetGlobalSold(ctx, entry.Id, periodStart)
+		if err != nil {
+			return nil, err
+		}
+		view.Bought = sold
+	} else if purchase := playerShop.GetPurchase(entry.Id); purchase != nil && purchase.PeriodStart == periodStart {
+		view.Bought = purchase.Count
+	}
+
+	return view, nil
+}
+
+// getOrResetPurchase 获取限购记录，跨周期时清零
+func (s *ShopService) getOrResetPurchase(playerShop *model.PlayerShop, shopItemID int32, periodStart int64) *model.ShopPurchase {
+	purchase := playerShop.GetPurchase(shopItemID)
+	if purchase == nil {
+		purchase = &model.ShopPurchase{ID: shopItemID, PeriodStart: periodStart}
+		playerShop.Purchases = append(playerShop.Purchases, purchase)
+	}
+	if purchase.PeriodStart != periodStart {
+		purchase.Count = 0
+		purchase.PeriodStart = periodStart
+	}
+	return purchase
+}
+
+// ensureMysteryShelf 确保神秘商店货架属于当前刷新周期，必要时重新随机上架
+// 返回货架以及是否发生了刷新
+func (s *ShopService) ensureMysteryShelf(playerShop *model.PlayerShop, shopID int32, now time.Time) (*model.MysteryShop, bool) {
+	refreshTime := periodStart(refreshPeriod(s.refreshType(shopID)), now)
+
+	shelf := playerShop.GetMystery(shopID)
+	if shelf != nil && shelf.RefreshTime == refreshTime {
+		return shelf, false
+	}
+	if shelf == nil {
+		shelf = &model.MysteryShop{ShopID: shopID}
+		playerShop.Mystery = append(playerShop.Mystery, shelf)
+	}
+
+	shelf.ItemIDs = s.rollMysteryItems(shopID)
+	shelf.RefreshTime = refreshTime
+
+	s.logger.Debug("mystery shop refreshed",
+		"role_id", playerShop.RoleID,
+		"shop_id", shopID,
+		"items", shelf.ItemIDs,
+	)
+	return shelf, true
+}
+
+// rollMysteryItems 按权重不放回地抽取神秘商店商品
+func (s *ShopService) rollMysteryItems(shopID int32) []int32 {
+	var pool []*gameconfig.ShopItemMystery
+	for _, v := range gameconfig.T.TbShopItem.GetDataList() {
+		if item, ok := v.(*gameconfig.ShopItemMystery); ok && item.ShopId == shopID && item.Weight > 0 {
+			pool = append(pool, item)
+		}
+	}
+
+	slots := s.cfg.MysterySlots
+	if slots <= 0 {
+		slots = defaultMysterySlots
+	}
+
+	result := make([]int32, 0, slots)
+	for len(result) < slots && len(pool) > 0 {
+		totalWeight := int32(0)
+		for _, item := range pool {
+			totalWeight += item.Weight
+		}
+
+		randVal := rand.Int31n(totalWeight)
+		for i, item := range pool {
+			if randVal < item.Weight {
+				result = append(result, item.Id)
+				pool = append(pool[:i], pool[i+1:]...)
+				break
+			}
+			randVal -= item.Weight
+		}
+	}
+	return result
+}
+
+// refreshType 获取神秘商店刷新类型
+func (s *ShopService) refreshType(shopID int32) int32 {
+	if refreshType, ok := s.cfg.RefreshTypes[shopID]; ok {
+		return refreshType
+	}
+	return gameconfig.RefreshType_Daily
+}
+
+// nextRefreshTime 神秘商店下次刷新时间，永不刷新时返回 0
+func (s *ShopService) nextRefreshTime(shopID int32, now time.Time) int64 {
+	return nextPeriodStart(refreshPeriod(s.refreshType(shopID)), now)
+}
+
+// ============ 商品配置适配 ============
+
+// shopEntry 统一的商品配置视图（TbShopItem 为多态表）
+type shopEntry struct {
+	gameconfig.ShopItemBase
+
+	mystery     bool
+	timeLimited bool
+	startTime   int64
+	endTime     int64
+}
+
+// toShopEntry 将 TbShopItem 中的具体商品类型转换为 shopEntry
+func toShopEntry(v interface{}) (*shopEntry, bool) {
+	switch item := v.(type) {
+	case *gameconfig.ShopItemNormal:
+		return &shopEntry{ShopItemBase: gameconfig.ShopItemBase{
+			Id: item.Id, ShopId: item.ShopId, ItemId: item.ItemId, CurrencyType: item.CurrencyType, Price: item.Price,
+			Discount: item.Discount, StockType: item.StockType, StockLimit: item.StockLimit, UnlockLevel: item.UnlockLevel,
+		}}, true
+	case *gameconfig.ShopItemMystery:
+		return &shopEntry{ShopItemBase: gameconfig.ShopItemBase{
+			Id: item.Id, ShopId: item.ShopId, ItemId: item.ItemId, CurrencyType: item.CurrencyType, Price: item.Price,
+			Discount: item.Discount, StockType: item.StockType, StockLimit: item.StockLimit, UnlockLevel: item.UnlockLevel,
+		}, mystery: true}, true
+	case *gameconfig.ShopItemGuild:
+		return &shopEntry{ShopItemBase: gameconfig.ShopItemBase{
+			Id: item.Id, ShopId: item.ShopId, ItemId: item.ItemId, CurrencyType: item.CurrencyType, Price: item.Price,
+			Discount: item.Discount, StockType: item.StockType, StockLimit: item.StockLimit, UnlockLevel: item.UnlockLevel,
+		}}, true
+	case *gameconfig.ShopItemAlliance:
+		return &shopEntry{ShopItemBase: gameconfig.ShopItemBase{
+			Id: item.Id, ShopId: item.ShopId, ItemId: item.ItemId, CurrencyType: item.CurrencyType, Price: item.Price,
+			Discount: item.Discount, StockType: item.StockType, StockLimit: item.StockLimit, UnlockLevel: item.UnlockLevel,
+		}}, true
+	case *gameconfig.ShopItemTimeLimited:
+		return &shopEntry{ShopItemBase: gameconfig.ShopItemBase{
+			Id: item.Id, ShopId: item.ShopId, ItemId: item.ItemId, CurrencyType: item.CurrencyType, Price: item.Price,
+			Discount: item.Discount, StockType: item.StockType, StockLimit: item.StockLimit, UnlockLevel: item.UnlockLevel,
+		}, timeLimited: true, startTime: item.StartTime, endTime: item.EndTime}, true
+	}
+	return nil, false
+}
+
+// onSale 是否处于限时售卖窗口内（结束时间含当秒）
+func (e *shopEntry) onSale(now time.Time) bool {
+	return dateTimeRangeContains(&gameconfig.ConfigDateTimeRange{StartTime: e.startTime, EndTime: e.endTime}, now)
+}
+
+// totalPrice 计算折后总价，不足 1 的部分向上取整
+func (e *shopEntry) totalPrice(count int32) int64 {
+	discount := int64(e.Discount)
+	if discount <= 0 || discount > 100 {
+		discount = 100
+	}
+	return (int64(e.Price)*discount*int64(count) + 99) / 100
+}
+
+// ============ 周期计算 ============
+
+// 限购/刷新周期
+const (
+	periodNone    = 0 // 无周期（终身/永不刷新）
+	periodDaily   = 1
+	periodWeekly  = 2
+	periodMonthly = 3
+)
+
+// stockPeriodStart 获取限购周期起始时间
+func stockPeriodStart(stockType int32, now time.Time) int64 {
+	switch stockType {
+	case gameconfig.StockType_Daily:
+		return periodStart(periodDaily, now)
+	case gameconfig.StockType_Weekly:
+		return periodStart(periodWeekly, now)
+	case gameconfig.StockType_Monthly:
+		return periodStart(periodMonthly, now)
+	}
+	return periodStart(periodNone, now)
+}
+
+// refreshPeriod 刷新类型对应的周期
+func refreshPeriod(refreshType int32) int {
+	switch refreshType {
+	case gameconfig.RefreshType_Daily:
+		return periodDaily
+	case gameconfig.RefreshType_Weekly:
+		return periodWeekly
+	case gameconfig.RefreshType_Monthly:
+		return periodMonthly
+	}
+	return periodNone
+}
+
+// periodStart 计算 now 所在周期的起始时间（服务器本地时区，周一为每周第一天）
+func periodStart(period int, now time.Time) int64 {
+	y, m, d := now.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+
+	switch period {
+	case periodDaily:
+		return day.Unix()
+	case periodWeekly:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset).Unix()
+	case periodMonthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, now.Location()).Unix()
+	}
+	return 0
+}
+
+// nextPeriodStart 计算下一个周期的起始时间，无周期时返回 0
+func nextPeriodStart(period int, now time.Time) int64 {
+	start := time.Unix(periodStart(period, now), 0).In(now.Location())
+
+	switch period {
+	case periodDaily:
+		return start.AddDate(0, 0, 1).Unix()
+	case periodWeekly:
+		return start.AddDate(0, 0, 7).Unix()
+	case periodMonthly:
+		return start.AddDate(0, 1, 0).Unix()
+	}
+	return 0
+}
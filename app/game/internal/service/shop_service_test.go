@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// 商店测试配置 ID
+const (
+	testCoinItemID      = 9001
+	testLowLevelRoleID  = 10002
+	testOtherRoleID     = 10003
+	testShopNormal      = 1
+	testShopMystery     = 2
+	testShopTimeLimited = 3
+
+	testShopItemDaily      = 1  // 每日限购 2 个，九折
+	testShopItemHighLevel  = 2  // 10 级解锁
+	testShopItemDoll       = 3  // 玩偶
+	testShopItemLimited    = 21 // 限时商品，全服终身限量 3 个
+	testShopItemMysteryOne = 11
+)
+
+// testShopNow 测试基准时间：2026-03-04 (周三) 12:00
+var testShopNow = time.Date(2026, 3, 4, 12, 0, 0, 0, time.Local)
+
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func shopItem(typ string, id, shopID, itemID, price, discount, stockType, stockLimit, unlockLevel int32) map[string]interface{} {
+	return map[string]interface{}{
+		"$type": typ, "id": float64(id), "shop_id": float64(shopID), "item_id": float64(itemID),
+		"currency_type": float64(gameconfig.CurrencyType_Coin), "price": float64(price), "discount": float64(discount),
+		"stock_type": float64(stockType), "stock_limit": float64(stockLimit), "unlock_level": float64(unlockLevel),
+	}
+}
+
+// setupShopConfig 在通用测试配置基础上追加商店相关配置
+func setupShopConfig(t *testing.T) {
+	t.Helper()
+	setupTestConfig(t)
+
+	items, err := gameconfig.NewTbItem([]map[string]interface{}{
+		testItemConfig(testCostItemID, gameconfig.ItemType_Item),
+		testItemConfig(testRewardItemID, gameconfig.ItemType_Item),
+		testItemConfig(testCoinItemID, gameconfig.ItemType_Item),
+	})
+	if err != nil {
+		t.Fatalf("failed to build item config: %v", err)
+	}
+	gameconfig.T.TbItem = items
+
+	mystery := func(id, weight int32) map[string]interface{} {
+		m := shopItem("ShopItemMystery", id, testShopMystery, testRewardItemID, 10, 100, gameconfig.StockType_Daily, 1, 1)
+		m["weight"] = float64(weight)
+		return m
+	}
+	limited := shopItem("ShopItemTimeLimited", testShopItemLimited, testShopTimeLimited, testRewardItemID, 10, 100, gameconfig.StockType_Lifetime, 3, 1)
+	limited["start_time"] = float64(testShopNow.Unix())
+	limited["end_time"] = float64(testShopNow.Add(24 * time.Hour).Unix())
+
+	shops, err := gameconfig.NewTbShopItem([]map[string]interface{}{
+		shopItem("ShopItemNormal", testShopItemDaily, testShopNormal, testRewardItemID, 50, 90, gameconfig.StockType_Daily, 2, 1),
+		shopItem("ShopItemNormal", testShopItemHighLevel, testShopNormal, testRewardItemID, 10, 100, gameconfig.StockType_Unlimited, 0, 10),
+		shopItem("ShopItemNormal", testShopItemDoll, testShopNormal, testDollID, 100, 100, gameconfig.StockType_Unlimited, 0, 1),
+		mystery(testShopItemMysteryOne, 10),
+		mystery(12, 20),
+		mystery(13, 30),
+		mystery(14, 40),
+		limited,
+	})
+	if err != nil {
+		t.Fatalf("failed to build shop config: %v", err)
+	}
+	gameconfig.T.TbShopItem = shops
+}
+
+func newTestShopService(t *testing.T) (*ShopService, *fakePlayerRepo, *fakeShopRepo, *testClock) {
+	t.Helper()
+	setupShopConfig(t)
+
+	l := logger.Noop()
+	repo := newFakePlayerRepo()
+	shopRepo := newFakeShopRepo()
+	uow := &fakeUnitOfWork{repo: repo, shop: shopRepo}
+	roles := fakeRoles{
+		testRoleID:         {ID: testRoleID, Level: 20},
+		testOtherRoleID:    {ID: testOtherRoleID, Level: 20},
+		testLowLevelRoleID: {ID: testLowLevelRoleID, Level: 5},
+	}
+	cfg := &ShopConfig{
+		CurrencyItems:    map[int32]int32{gameconfig.CurrencyType_Coin: testCoinItemID},
+		GlobalStockShops: []int32{testShopTimeLimited},
+		MysterySlots:     2,
+	}
+
+	svc := newShopService(l, cfg, shopRepo, uow, roles, NewDollService(l, repo, nil), NewBagService(l, &BagConfig{}, repo, uow))
+	clock := &testClock{now: testShopNow}
+	svc.now = clock.Now
+
+	setBagCount(repo, gameconfig.BagType_Item, testCoinItemID, 1000)
+	return svc, repo, shopRepo, clock
+}
+
+// TestShopBuy_Success 测试正常购买：折扣扣费、发货、限购记录
+func TestShopBuy_Success(t *testing.T) {
+	svc, repo, shopRepo, _ := newTestShopService(t)
+
+	bought, err := svc.Buy(context.Background(), testRoleID, testShopItemDaily, 2)
+	if err != nil {
+		t.Fatalf("Buy() error = %v", err)
+	}
+	if bought != 2 {
+		t.Errorf("Buy() bought = %d, want 2", bought)
+	}
+
+	// 50 * 90% * 2 = 90
+	wantBag := []string{"1002:2", "9001:910"}
+	if got := bagItems(repo, gameconfig.BagType_Item); !reflect.DeepEqual(got, wantBag) {
+		t.Errorf("bag = %v, want %v", got, wantBag)
+	}
+
+	purchase := shopRepo.state.shops[testRoleID].Purchases[0]
+	if purchase.Count != 2 || purchase.PeriodStart != periodStart(periodDaily, testShopNow) {
+		t.Errorf("purchase = %+v, want count=2 in today's period", purchase)
+	}
+}
+
+// TestShopBuy_Doll 测试购买玩偶商品
+func TestShopBuy_Doll(t *testing.T) {
+	svc, repo, _, _ := newTestShopService(t)
+
+	if _, err := svc.Buy(context.Background(), testRoleID, testShopItemDoll, 2); err != nil {
+		t.Fatalf("Buy() error = %v", err)
+	}
+	if len(repo.state.dolls) != 2 {
+		t.Errorf("dolls = %d, want 2", len(repo.state.dolls))
+	}
+}
+
+// TestShopBuy_DailyLimit 测试每日限购及跨天重置
+func TestShopBuy_DailyLimit(t *testing.T) {
+	svc, repo, shopRepo, clock := newTestShopService(t)
+	ctx := context.Background()
+
+	if _, err := svc.Buy(ctx, testRoleID, testShopItemDaily, 2); err != nil {
+		t.Fatalf("Buy() error = %v", err)
+	}
+
+	before, shopBefore := repo.state.clone(), shopRepo.state.clone()
+	if _, err := svc.Buy(ctx, testRoleID, testShopItemDaily, 1); !errors.Is(err, ErrShopLimitExceeded) {
+		t.Fatalf("Buy() error = %v, want ErrShopLimitExceeded", err)
+	}
+	if !reflect.DeepEqual(repo.state, before) || !reflect.DeepEqual(shopRepo.state, shopBefore) {
+		t.Error("state changed after rejected purchase")
+	}
+
+	// 次日限购重置
+	clock.now = clock.now.Add(24 * time.Hour)
+	bought, err := svc.Buy(ctx, testRoleID, testShopItemDaily, 1)
+	if err != nil {
+		t.Fatalf("Buy() next day error = %v", err)
+	}
+	if bought != 1 {
+		t.Errorf("Buy() next day bought = %d, want 1", bought)
+	}
+}
+
+// TestShopBuy_Rejections 测试各类前置校验失败
+func TestShopBuy_Rejections(t *testing.T) {
+	tests := []struct {
+		name       string
+		roleID     int64
+		shopItemID int32
+		count      int32
+		offset     time.Duration
+		want       error
+	}{
+		{"unknown item", testRoleID, 999, 1, 0, ErrShopItemNotFound},
+		{"role offline", 99999, testShopItemDaily, 1, 0, ErrShopRoleOffline},
+		{"level not enough", testLowLevelRoleID, testShopItemHighLevel, 1, 0, ErrShopLevelNotEnough},
+		{"before time window", testRoleID, testShopItemLimited, 1, -time.Second, ErrShopNotOnSale},
+		{"after time window", testRoleID, testShopItemLimited, 1, 24*time.Hour + time.Second, ErrShopNotOnSale},
+		{"insufficient currency", testRoleID, testShopItemHighLevel, 101, 0, ErrShopInsufficientCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo, shopRepo, clock := newTestShopService(t)
+			clock.now = clock.now.Add(tt.offset)
+			before, shopBefore := repo.state.clone(), shopRepo.state.clone()
+
+			if _, err := svc.Buy(context.Background(), tt.roleID, tt.shopItemID, tt.count); !errors.Is(err, tt.want) {
+				t.Fatalf("Buy() error = %v, want %v", err, tt.want)
+			}
+			if !reflect.DeepEqual(repo.state, before) || !reflect.DeepEqual(shopRepo.state, shopBefore) {
+				t.Error("state changed after rejected purchase")
+			}
+		})
+	}
+}
+
+// TestShopBuy_GlobalStock 测试全服共享库存
+func TestShopBuy_GlobalStock(t *testing.T) {
+	svc, _, _, _ := newTestShopService(t)
+	ctx := context.Background()
+
+	if _, err := svc.Buy(ctx, testRoleID, testShopItemLimited, 2); err != nil {
+		t.Fatalf("Buy() error = %v", err)
+	}
+
+	// 另一个角色只剩 1 个可买
+	if _, err := svc.Buy(ctx, testOtherRoleID, testShopItemLimited, 2); !errors.Is(err, ErrShopSoldOut) {
+		t.Fatalf("Buy() error = %v, want ErrShopSoldOut", err)
+	}
+	if _, err := svc.Buy(ctx, testOtherRoleID, testShopItemLimited, 1); err != nil {
+		t.Fatalf("Buy() last stock error = %v", err)
+	}
+
+	views, err := svc.ListShops(ctx, testRoleID, testShopTimeLimited)
+	if err != nil {
+		t.Fatalf("ListShops() error = %v", err)
+	}
+	if got := views[0].Items[0]; !got.GlobalStock || got.Bought != 3 {
+		t.Errorf("limited item view = %+v, want global stock sold 3", got)
+	}
+}
+
+// TestShopMysteryRefresh 测试神秘商店按刷新周期重新上架
+func TestShopMysteryRefresh(t *testing.T) {
+	svc, _, shopRepo, clock := newTestShopService(t)
+	ctx := context.Background()
+
+	views, err := svc.ListShops(ctx, testRoleID, testShopMystery)
+	if err != nil {
+		t.Fatalf("ListShops() error = %v", err)
+	}
+	if len(views) != 1 || len(views[0].Items) != 2 {
+		t.Fatalf("ListShops() = %+v, want 1 shop with 2 items", views)
+	}
+	if want := nextPeriodStart(periodDaily, testShopNow); views[0].NextRefreshTime != want {
+		t.Errorf("NextRefreshTime = %d, want %d", views[0].NextRefreshTime, want)
+	}
+	shelf := shopRepo.state.shops[testRoleID].Mystery[0]
+	if len(shelf.ItemIDs) != 2 || shelf.ItemIDs[0] == shelf.ItemIDs[1] {
+		t.Errorf("shelf items = %v, want 2 distinct items", shelf.ItemIDs)
+	}
+
+	// 同一周期内货架不变，不重复落库
+	saves := shopRepo.calls["SavePlayerShop"]
+	if _, err := svc.ListShops(ctx, testRoleID, testShopMystery); err != nil {
+		t.Fatalf("ListShops() error = %v", err)
+	}
+	if shopRepo.calls["SavePlayerShop"] != saves {
+		t.Error("shelf saved again within the same refresh period")
+	}
+
+	// 不在货架上的商品不可购买
+	var offShelf int32
+	for _, id := range []int32{11, 12, 13, 14} {
+		if !shelf.HasItem(id) {
+			offShelf = id
+			break
+		}
+	}
+	if _, err := svc.Buy(ctx, testRoleID, offShelf, 1); !errors.Is(err, ErrShopNotOnSale) {
+		t.Errorf("Buy() off-shelf error = %v, want ErrShopNotOnSale", err)
+	}
+
+	// 次日自动刷新
+	clock.now = clock.now.Add(24 * time.Hour)
+	if _, err := svc.ListShops(ctx, testRoleID, testShopMystery); err != nil {
+		t.Fatalf("ListShops() error = %v", err)
+	}
+	refreshed := shopRepo.state.shops[testRoleID].Mystery[0]
+	if refreshed.RefreshTime != periodStart(periodDaily, clock.now) {
+		t.Errorf("RefreshTime = %d, want next day's period", refreshed.RefreshTime)
+	}
+}
+
+// TestPeriodStart 测试周期起始时间计算
+func TestPeriodStart(t *testing.T) {
+	day := func(y int, m time.Month, d int) int64 {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.Local).Unix()
+	}
+
+	tests := []struct {
+		name   string
+		period int
+		now    time.Time
+		want   int64
+		next   int64
+	}{
+		{"daily", periodDaily, testShopNow, day(2026, 3, 4), day(2026, 3, 5)},
+		{"weekly wednesday", periodWeekly, testShopNow, day(2026, 3, 2), day(2026, 3, 9)},
+		{"weekly sunday", periodWeekly, time.Date(2026, 3, 8, 23, 0, 0, 0, time.Local), day(2026, 3, 2), day(2026, 3, 9)},
+		{"monthly", periodMonthly, testShopNow, day(2026, 3, 1), day(2026, 4, 1)},
+		{"none", periodNone, testShopNow, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := periodStart(tt.period, tt.now); got != tt.want {
+				t.Errorf("periodStart() = %d, want %d", got, tt.want)
+			}
+			if got := nextPeriodStart(tt.period, tt.now); got != tt.next {
+				t.Errorf("nextPeriodStart() = %d, want %d", got, tt.next)
+			}
+		})
+	}
+}
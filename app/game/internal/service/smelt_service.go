@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
 	"github.com/lk2023060901/xdooria/app/game/internal/model"
@@ -10,96 +12,200 @@ import (
 	"github.com/lk2023060901/xdooria/pkg/logger"
 )
 
+// 熔炼业务错误，Handler 据此映射错误码
+var (
+	ErrSmeltNoActivity      = errors.New("no active smelt activity")
+	ErrSmeltInvalidRecipe   = errors.New("dolls do not match any smelt recipe")
+	ErrSmeltDollUnavailable = errors.New("doll cannot be smelted")
+)
+
+// SmeltConfig 熔炼配置
+type SmeltConfig struct {
+	// Recipes 熔炼配方，投入的玩偶必须品质一致且数量与某个配方匹配
+	Recipes []SmeltRecipe `mapstructure:"recipes"`
+}
+
+// SmeltRecipe 熔炼配方
+type SmeltRecipe struct {
+	Quality int16 `mapstructure:"quality"` // 投入玩偶品质 (gameconfig.DollQuality_*)
+	Count   int   `mapstructure:"count"`   // 投入玩偶数量
+}
+
 // SmeltService 熔炼服务
 type SmeltService struct {
 	logger     logger.Logger
+	cfg        *SmeltConfig
 	playerRepo repository.PlayerRepository
+	uow        repository.UnitOfWork
 	dropSvc    *DropService
 	dollSvc    *DollService
 	bagSvc     *BagService
+
+	now func() time.Time
 }
 
 func NewSmeltService(
 	l logger.Logger,
+	cfg *SmeltConfig,
 	playerRepo repository.PlayerRepository,
+	uow repository.UnitOfWork,
 	dropSvc *DropService,
 	dollSvc *DollService,
 	bagSvc *BagService,
 ) *SmeltService {
 	return &SmeltService{
 		logger:     l.Named("service.smelt"),
+		cfg:        cfg,
 		playerRepo: playerRepo,
+		uow:        uow,
 		dropSvc:    dropSvc,
 		dollSvc:    dollSvc,
 		bagSvc:     bagSvc,
+		now:        time.Now,
 	}
 }
 
 // Smelt 熔炼核心逻辑
 // 返回产出的新玩偶、是否升品成功、以及错误
+// 掉落先于销毁执行，销毁与发奖在同一个工作单元内完成，任一步骤失败玩偶都不会丢失
 func (s *SmeltService) Smelt(ctx context.Context, roleID int64, itemUIDs []int64) (*model.Doll, bool, error) {
 	if len(itemUIDs) == 0 {
 		return nil, false, fmt.Errorf("no dolls provided for smelting")
 	}
 
-	// 1. 获取当前有效的熔炼活动（简单起见取第一个，实际应按时间判定）
-	activities := gameconfig.T.TbSmeltActivity.GetDataList()
-	if len(activities) == 0 {
-		return nil, false, fmt.Errorf("no active smelt activity")
+	// 1. 按开放时间选择当前熔炼活动
+	activity, err := s.CurrentActivity()
+	if err != nil {
+		return nil, false, err
 	}
-	activity := activities[0]
 
-	// 2. 验证玩偶状态并执行物理删除
-	for _, uid := range itemUIDs {
-		doll, err := s.playerRepo.GetDollByID(ctx, roleID, uid)
+	var (
+		newDoll  *model.Doll
+		upgraded bool
+		seed     int64
+	)
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		// 2. 校验玩偶状态与配方
+		inputQuality, err := s.validateInput(ctx, roleID, itemUIDs)
 		if err != nil {
-			return nil, false, fmt.Errorf("doll %d not found: %w", uid, err)
+			return err
 		}
 
-		if doll.IsLocked {
-			return nil, false, fmt.Errorf("doll %d is locked and cannot be smelted", uid)
-		}
-		if doll.IsRedeemed {
-			return nil, false, fmt.Errorf("doll %d is already redeemed and cannot be smelted", uid)
+		// 3. 执行熔炼掉落（销毁前执行，掉落失败不影响玩偶）
+		roll, err := s.dropSvc.Roll(ctx, activity.DropId)
+		if err != nil {
+			return err
 		}
+		seed = roll.Seed
 
-		// 销毁旧玩偶
-		if err := s.playerRepo.DeleteDoll(ctx, roleID, uid); err != nil {
-			return nil, false, fmt.Errorf("failed to destroy doll %d: %w", uid, err)
+		// 4. 销毁投入的玩偶
+		if err := s.playerRepo.DeleteDolls(ctx, roleID, itemUIDs); err != nil {
+			return fmt.Errorf("failed to destroy dolls: %w", err)
 		}
-	}
 
-	// 3. 执行熔炼掉落
-	results, err := s.dropSvc.ExecuteDrop(ctx, activity.DropId)
-	if err != nil {
-		return nil, false, err
-	}
+		// 5. 发放奖励，返回第一个新玩偶 (符合 SmeltResult proto)
+		for _, res := range roll.Results {
+			if dollCfg := gameconfig.T.TbDoll.Get(res.ItemID); dollCfg == nil {
+				// 其他道具进背包
+				if err := s.bagSvc.AddItem(ctx, roleID, res.ItemID, res.Count); err != nil {
+					return fmt.Errorf("failed to add reward item %d from smelt: %w", res.ItemID, err)
+				}
+				continue
+			}
 
-	// 4. 发放奖励并返回第一个新玩偶 (符合 SmeltResult proto)
-	var newDoll *model.Doll
-	for _, res := range results {
-		if dollCfg := gameconfig.T.TbDoll.Get(res.ItemID); dollCfg != nil {
 			// 熔炼产出新玩偶
 			for c := int32(0); c < res.Count; c++ {
 				d, err := s.dollSvc.AddDoll(ctx, roleID, res.ItemID)
 				if err != nil {
-					s.logger.Error("failed to add reward doll from smelt", "role_id", roleID, "doll_id", res.ItemID, "error", err)
-					continue
+					return fmt.Errorf("failed to add reward doll %d from smelt: %w", res.ItemID, err)
 				}
 				if newDoll == nil {
 					newDoll = d
 				}
 			}
-		} else {
-			// 其他道具进背包
-			if err := s.bagSvc.AddItem(ctx, roleID, res.ItemID, res.Count); err != nil {
-				s.logger.Error("failed to add reward item from smelt", "role_id", roleID, "item_id", res.ItemID, "error", err)
-			}
 		}
+
+		// 6. 产出品质高于投入品质即为升品
+		upgraded = newDoll != nil && newDoll.Quality > inputQuality
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.logger.Info("smelt success",
+		"role_id", roleID,
+		"activity_id", activity.Id,
+		"consumed_count", len(itemUIDs),
+		"upgraded", upgraded,
+		"drop_id", activity.DropId,
+		"seed", seed,
+	)
+	return newDoll, upgraded, nil
+}
+
+// CurrentActivity 获取当前开放的熔炼活动
+// 多个活动时间重叠时取开始时间最晚的一个
+func (s *SmeltService) CurrentActivity() (*gameconfig.SmeltActivity, error) {
+	now := s.now()
+
+	var (
+		current      *gameconfig.SmeltActivity
+		currentStart int64
+	)
+	for _, activity := range gameconfig.T.TbSmeltActivity.GetDataList() {
+		window, err := smeltActivityTimeRange(activity)
+		if err != nil {
+			s.logger.Error("invalid smelt activity time range", "activity_id", activity.Id, "error", err)
+			continue
+		}
+		if !dateTimeRangeContains(window, now) {
+			continue
+		}
+		if current == nil || window.StartTime > currentStart {
+			current = activity
+			currentStart = window.StartTime
+		}
+	}
+
+	if current == nil {
+		return nil, ErrSmeltNoActivity
 	}
+	return current, nil
+}
 
-	s.logger.Info("smelt success", "role_id", roleID, "consumed_count", len(itemUIDs))
+// validateInput 校验投入玩偶可熔炼且符合配方，返回投入品质
+func (s *SmeltService) validateInput(ctx context.Context, roleID int64, itemUIDs []int64) (int16, error) {
+	seen := make(map[int64]bool, len(itemUIDs))
+	var quality int16
+	for i, uid := range itemUIDs {
+		if seen[uid] {
+			return 0, fmt.Errorf("%w: doll %d duplicated", ErrSmeltDollUnavailable, uid)
+		}
+		seen[uid] = true
 
-	// 暂时固定返回 upgraded=false，后续可根据掉落逻辑判定
-	return newDoll, false, nil
+		doll, err := s.playerRepo.GetDollByID(ctx, roleID, uid)
+		if err != nil {
+			return 0, fmt.Errorf("%w: doll %d not found: %v", ErrSmeltDollUnavailable, uid, err)
+		}
+		if doll.IsLocked {
+			return 0, fmt.Errorf("%w: doll %d is locked", ErrSmeltDollUnavailable, uid)
+		}
+		if doll.IsRedeemed {
+			return 0, fmt.Errorf("%w: doll %d is already redeemed", ErrSmeltDollUnavailable, uid)
+		}
+
+		if i == 0 {
+			quality = doll.Quality
+		} else if doll.Quality != quality {
+			return 0, fmt.Errorf("%w: mixed qualities %d and %d", ErrSmeltInvalidRecipe, quality, doll.Quality)
+		}
+	}
+
+	for _, recipe := range s.cfg.Recipes {
+		if recipe.Quality == quality && recipe.Count == len(itemUIDs) {
+			return quality, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: quality %d x%d", ErrSmeltInvalidRecipe, quality, len(itemUIDs))
 }
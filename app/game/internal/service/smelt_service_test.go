@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/game/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/game/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// testSmeltNow 测试基准时间，处于活动 1 的开放时间内
+var testSmeltNow = time.Date(2026, 3, 4, 12, 0, 0, 0, time.Local)
+
+func smeltActivity(id int32, start, end string, dropID int32) map[string]interface{} {
+	return map[string]interface{}{
+		"id": float64(id), "name": "smelt", "start_time": start, "end_time": end, "drop_id": float64(dropID),
+	}
+}
+
+// setupSmeltConfig 在通用测试配置基础上追加熔炼活动
+func setupSmeltConfig(t *testing.T, activities ...map[string]interface{}) {
+	t.Helper()
+	setupTestConfig(t)
+
+	if len(activities) == 0 {
+		activities = append(activities, smeltActivity(1, "2026-03-01 00:00:00", "2026-03-07 23:59:59", testDropID))
+	}
+	tb, err := gameconfig.NewTbSmeltActivity(activities)
+	if err != nil {
+		t.Fatalf("failed to build smelt config: %v", err)
+	}
+	gameconfig.T.TbSmeltActivity = tb
+}
+
+func newTestSmeltService(t *testing.T) (*SmeltService, *fakePlayerRepo, *fakeUnitOfWork) {
+	t.Helper()
+
+	l := logger.Noop()
+	repo := newFakePlayerRepo()
+	uow := &fakeUnitOfWork{repo: repo}
+	cfg := &SmeltConfig{Recipes: []SmeltRecipe{
+		{Quality: gameconfig.DollQuality_Rare, Count: 3},
+		{Quality: gameconfig.DollQuality_Epic, Count: 3},
+	}}
+
+	svc := NewSmeltService(l, cfg, repo, uow, NewDropService(l), NewDollService(l, repo, nil), NewBagService(l, &BagConfig{}, repo, uow))
+	svc.now = func() time.Time { return testSmeltNow }
+	return svc, repo, uow
+}
+
+// addTestDolls 为测试角色添加玩偶，返回实例 ID
+func addTestDolls(t *testing.T, repo *fakePlayerRepo, quality int16, n int, mutate func(d *model.Doll)) []int64 {
+	t.Helper()
+	var ids []int64
+	for i := 0; i < n; i++ {
+		d := &model.Doll{PlayerID: testRoleID, DollID: testDollID, Quality: quality}
+		if mutate != nil {
+			mutate(d)
+		}
+		if err := repo.AddDoll(context.Background(), d); err != nil {
+			t.Fatalf("AddDoll() error = %v", err)
+		}
+		ids = append(ids, d.ID)
+	}
+	return ids
+}
+
+// TestSmeltCurrentActivity 测试按开放时间选择熔炼活动
+func TestSmeltCurrentActivity(t *testing.T) {
+	setupSmeltConfig(t,
+		smeltActivity(1, "2026-03-01 00:00:00", "2026-03-07 23:59:59", testDropID),
+		smeltActivity(2, "2026-03-05 00:00:00", "2026-03-10 23:59:59", testDropID),
+		smeltActivity(3, "bad time", "2026-12-31 23:59:59", testDropID),
+	)
+	svc, _, _ := newTestSmeltService(t)
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		wantID int32
+	}{
+		{"before all", time.Date(2026, 2, 28, 23, 59, 59, 0, time.Local), 0},
+		{"first start", time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local), 1},
+		{"overlap prefers latest start", time.Date(2026, 3, 6, 0, 0, 0, 0, time.Local), 2},
+		{"second only", time.Date(2026, 3, 8, 0, 0, 0, 0, time.Local), 2},
+		{"end inclusive", time.Date(2026, 3, 10, 23, 59, 59, 0, time.Local), 2},
+		{"after all", time.Date(2026, 3, 11, 0, 0, 0, 0, time.Local), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc.now = func() time.Time { return tt.now }
+			activity, err := svc.CurrentActivity()
+			if tt.wantID == 0 {
+				if !errors.Is(err, ErrSmeltNoActivity) {
+					t.Fatalf("CurrentActivity() error = %v, want ErrSmeltNoActivity", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CurrentActivity() error = %v", err)
+			}
+			if activity.Id != tt.wantID {
+				t.Errorf("CurrentActivity() = %d, want %d", activity.Id, tt.wantID)
+			}
+		})
+	}
+}
+
+// TestSmelt_Upgrade 测试熔炼消耗玩偶并根据产出品质判定升品
+func TestSmelt_Upgrade(t *testing.T) {
+	tests := []struct {
+		name         string
+		quality      int16
+		wantUpgraded bool
+	}{
+		{"rare to epic", gameconfig.DollQuality_Rare, true},
+		{"epic to epic", gameconfig.DollQuality_Epic, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupSmeltConfig(t)
+			svc, repo, uow := newTestSmeltService(t)
+			ids := addTestDolls(t, repo, tt.quality, 3, nil)
+
+			newDoll, upgraded, err := svc.Smelt(context.Background(), testRoleID, ids)
+			if err != nil {
+				t.Fatalf("Smelt() error = %v", err)
+			}
+			if newDoll == nil || newDoll.Quality != gameconfig.DollQuality_Epic {
+				t.Fatalf("Smelt() newDoll = %+v, want epic doll", newDoll)
+			}
+			if upgraded != tt.wantUpgraded {
+				t.Errorf("Smelt() upgraded = %v, want %v", upgraded, tt.wantUpgraded)
+			}
+
+			if len(repo.state.dolls) != 1 || repo.state.dolls[0].ID != newDoll.ID {
+				t.Errorf("dolls = %+v, want only the new doll", repo.state.dolls)
+			}
+			if got := bagItems(repo, gameconfig.BagType_Item); !reflect.DeepEqual(got, []string{"1002:5"}) {
+				t.Errorf("bag = %v, want [1002:5]", got)
+			}
+			if uow.commits != 1 {
+				t.Errorf("uow commits = %d, want 1", uow.commits)
+			}
+		})
+	}
+}
+
+// TestSmelt_InvalidInput 测试非法投入不会销毁任何玩偶
+func TestSmelt_InvalidInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, repo *fakePlayerRepo) []int64
+		want  error
+	}{
+		{"wrong count", func(t *testing.T, repo *fakePlayerRepo) []int64 {
+			return addTestDolls(t, repo, gameconfig.DollQuality_Rare, 2, nil)
+		}, ErrSmeltInvalidRecipe},
+		{"no recipe for quality", func(t *testing.T, repo *fakePlayerRepo) []int64 {
+			return addTestDolls(t, repo, gameconfig.DollQuality_Advanced, 3, nil)
+		}, ErrSmeltInvalidRecipe},
+		{"mixed quality", func(t *testing.T, repo *fakePlayerRepo) []int64 {
+			ids := addTestDolls(t, repo, gameconfig.DollQuality_Rare, 2, nil)
+			return append(ids, addTestDolls(t, repo, gameconfig.DollQuality_Epic, 1, nil)...)
+		}, ErrSmeltInvalidRecipe},
+		{"locked doll", func(t *testing.T, repo *fakePlayerRepo) []int64 {
+			ids := addTestDolls(t, repo, gameconfig.DollQuality_Rare, 2, nil)
+			return append(ids, addTestDolls(t, repo, gameconfig.DollQuality_Rare, 1, func(d *model.Doll) { d.IsLocked = true })...)
+		}, ErrSmeltDollUnavailable},
+		{"redeemed doll", func(t *testing.T, repo *fakePlayerRepo) []int64 {
+			ids := addTestDolls(t, repo, gameconfig.DollQuality_Rare, 2, nil)
+			return append(ids, addTestDolls(t, repo, gameconfig.DollQuality_Rare, 1, func(d *model.Doll) { d.IsRedeemed = true })...)
+		}, ErrSmeltDollUnavailable},
+		{"duplicated doll", func(t *testing.T, repo *fakePlayerRepo) []int64 {
+			ids := addTestDolls(t, repo, gameconfig.DollQuality_Rare, 2, nil)
+			return append(ids, ids[0])
+		}, ErrSmeltDollUnavailable},
+		{"missing doll", func(t *testing.T, repo *fakePlayerRepo) []int64 {
+			return append(addTestDolls(t, repo, gameconfig.DollQuality_Rare, 2, nil), 9999)
+		}, ErrSmeltDollUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupSmeltConfig(t)
+			svc, repo, _ := newTestSmeltService(t)
+			ids := tt.setup(t, repo)
+			before := repo.state.clone()
+
+			if _, _, err := svc.Smelt(context.Background(), testRoleID, ids); !errors.Is(err, tt.want) {
+				t.Fatalf("Smelt() error = %v, want %v", err, tt.want)
+			}
+			if !reflect.DeepEqual(repo.state, before) {
+				t.Errorf("state changed:\n got  %+v\n want %+v", repo.state, before)
+			}
+		})
+	}
+}
+
+// TestSmelt_FailureKeepsDolls 测试掉落或发奖失败时玩偶不会丢失
+func TestSmelt_FailureKeepsDolls(t *testing.T) {
+	tests := []struct {
+		name       string
+		dropID     int32
+		failMethod string
+		failCall   int
+	}{
+		{"drop config missing", 999, "", 0},
+		{"destroy dolls", testDropID, "DeleteDolls", 1},
+		{"grant doll", testDropID, "AddDoll", 4}, // 前 3 次为准备数据
+		{"grant item", testDropID, "SaveBag", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupSmeltConfig(t, smeltActivity(1, "2026-03-01 00:00:00", "2026-03-07 23:59:59", tt.dropID))
+			svc, repo, uow := newTestSmeltService(t)
+			ids := addTestDolls(t, repo, gameconfig.DollQuality_Rare, 3, nil)
+			before := repo.state.clone()
+			if tt.failMethod != "" {
+				repo.failAt(tt.failMethod, tt.failCall)
+			}
+
+			if _, _, err := svc.Smelt(context.Background(), testRoleID, ids); err == nil {
+				t.Fatal("Smelt() expected error")
+			}
+			if !reflect.DeepEqual(repo.state, before) {
+				t.Errorf("state changed after rollback:\n got  %+v\n want %+v", repo.state, before)
+			}
+			if uow.rollbacks != 1 {
+				t.Errorf("uow rollbacks = %d, want 1", uow.rollbacks)
+			}
+		})
+	}
+}
@@ -3,18 +3,26 @@ package main
 import (
 	"context"
 	"fmt"
+	"strconv"
 
+	common "github.com/lk2023060901/xdooria-proto-common"
 	gamepb "github.com/lk2023060901/xdooria-proto-internal/game"
+	"github.com/lk2023060901/xdooria/app/gateway/internal/game"
 	"github.com/lk2023060901/xdooria/app/gateway/internal/handler"
 	"github.com/lk2023060901/xdooria/app/gateway/internal/role"
 	gwsession "github.com/lk2023060901/xdooria/app/gateway/internal/session"
 	"github.com/lk2023060901/xdooria/pkg/app"
 	"github.com/lk2023060901/xdooria/pkg/database/postgres"
 	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/metrics/system"
 	"github.com/lk2023060901/xdooria/pkg/network/framer"
+	"github.com/lk2023060901/xdooria/pkg/network/framer/seqid"
 	grpcclient "github.com/lk2023060901/xdooria/pkg/network/grpc/client"
+	"github.com/lk2023060901/xdooria/pkg/network/kcp"
 	"github.com/lk2023060901/xdooria/pkg/network/session"
 	"github.com/lk2023060901/xdooria/pkg/network/tcp"
+	"github.com/lk2023060901/xdooria/pkg/network/websocket"
+	"github.com/lk2023060901/xdooria/pkg/prometheus"
 	"github.com/lk2023060901/xdooria/pkg/registry"
 	"github.com/lk2023060901/xdooria/pkg/registry/etcd"
 	"github.com/lk2023060901/xdooria/pkg/router"
@@ -28,15 +36,30 @@ type Config struct {
 	Log     logger.Config             `mapstructure:"log"`
 	Loggers map[string]*logger.Config `mapstructure:"loggers"`
 
+	// 所属区服
+	Zone ZoneConfig `mapstructure:"zone"`
+
+	// Gateway 实例配置
+	Gateway GatewayConfig `mapstructure:"gateway"`
+
 	// TCP 配置
 	TCP tcp.ServerConfig `mapstructure:"tcp"`
 
+	// WebSocket 配置（addr 为空时不监听）
+	WebSocket WebSocketConfig `mapstructure:"websocket"`
+
+	// KCP 配置（addr 为空时不监听）
+	KCP kcp.ServerConfig `mapstructure:"kcp"`
+
 	// Session 配置
 	Session session.Config `mapstructure:"session"`
 
 	// Framer 配置
 	Framer framer.Config `mapstructure:"framer"`
 
+	// Game 双向流会话配置（Game 经此流向客户端推送消息）
+	GameStream session.Config `mapstructure:"game_stream"`
+
 	// Registry 配置
 	Registry etcd.Config `mapstructure:"registry"`
 
@@ -45,6 +68,37 @@ type Config struct {
 
 	// Database 配置
 	Database postgres.Config `mapstructure:"database"`
+
+	// 会话恢复配置
+	Resume gwsession.ResumeConfig `mapstructure:"resume"`
+
+	// 负载上报配置（在线人数、连接数、CPU 使用率与排空状态写入服务注册元数据）
+	LoadReport gwsession.LoadReportConfig `mapstructure:"load_report"`
+
+	// Prometheus 配置
+	Prometheus prometheus.Config `mapstructure:"prometheus"`
+}
+
+// ZoneConfig 区服配置
+type ZoneConfig struct {
+	// ID 区服 ID
+	ID int32 `mapstructure:"id"`
+	// Name 区服名称
+	Name string `mapstructure:"name"`
+}
+
+// GatewayConfig Gateway 实例配置
+type GatewayConfig struct {
+	// ID Gateway 实例 ID，Game 据此将推送路由到角色所在的 Gateway，各实例必须唯一
+	ID string `mapstructure:"id"`
+}
+
+// WebSocketConfig WebSocket 监听配置
+type WebSocketConfig struct {
+	// 监听地址，如 "0.0.0.0:9001"
+	Addr string `mapstructure:"addr"`
+
+	websocket.ServerConfig `mapstructure:",squash"`
 }
 
 func main() {
@@ -62,21 +116,33 @@ func main() {
 		panic(err)
 	}
 
-	// 3. 初始化 Framer
+	// 3. 初始化 Prometheus 客户端
+	promClient, err := prometheus.New(&cfg.Prometheus)
+	if err != nil {
+		l.Error("failed to create prometheus client", "error", err)
+		return
+	}
+	defer promClient.Close()
+
+	// 4. 初始化 Framer（防重放拒绝次数上报到 Prometheus，SessionFramer 与 Game 流共用）
+	if cfg.Framer.SeqIdConfig == nil {
+		cfg.Framer.SeqIdConfig = &seqid.Config{}
+	}
+	cfg.Framer.SeqIdConfig.Observer = seqid.NewMetrics(promClient.Config().Namespace, promClient.Registry())
 	fr, err := framer.New(&cfg.Framer)
 	if err != nil {
 		l.Error("failed to create framer", "error", err)
 		return
 	}
 
-	// 4. 初始化 JWT 管理器
+	// 5. 初始化 JWT 管理器
 	jwtMgr, err := security.NewJWTManager(&cfg.JWT)
 	if err != nil {
 		l.Error("failed to create jwt manager", "error", err)
 		return
 	}
 
-	// 5. 初始化 PostgreSQL 客户端
+	// 6. 初始化 PostgreSQL 客户端
 	pgClient, err := postgres.New(&cfg.Database)
 	if err != nil {
 		l.Error("failed to create postgres client", "error", err)
@@ -84,10 +150,10 @@ func main() {
 	}
 	defer pgClient.Close()
 
-	// 6. 初始化 Role Provider
+	// 7. 初始化 Role Provider
 	roleProvider := role.NewProvider(l, pgClient)
 
-	// 7. 创建 etcd resolver 用于服务发现
+	// 8. 创建 etcd resolver 用于服务发现
 	resolver, err := etcd.NewResolver(&cfg.Registry)
 	if err != nil {
 		l.Error("failed to create resolver", "error", err)
@@ -95,7 +161,7 @@ func main() {
 	}
 	defer resolver.Close()
 
-	// 8. 解析 Game 服务地址
+	// 9. 解析 Game 服务地址
 	gameServices, err := resolver.Resolve(context.Background(), "game")
 	if err != nil || len(gameServices) == 0 {
 		l.Error("failed to resolve game service", "error", err)
@@ -104,7 +170,7 @@ func main() {
 	gameAddr := gameServices[0].Address
 	l.Info("resolved game service", "address", gameAddr)
 
-	// 9. 创建 Game gRPC 客户端
+	// 10. 创建 Game gRPC 客户端
 	gameClientCfg := &grpcclient.Config{
 		Target:      gameAddr,
 		DialTimeout: 5,
@@ -121,39 +187,78 @@ func main() {
 	conn, _ := gameConn.GetConn()
 	gameClient := gamepb.NewGameServiceClient(conn)
 
-	// 10. 初始化 Router 和 Processor
+	// 11. 初始化 Router 和 Processor
 	r := router.New()
 	processor := router.NewProcessor(r)
 
-	// 11. 初始化 Session Manager
-	sessMgr := gwsession.NewManager()
+	// 12. 初始化 Session Manager
+	sessMgr := gwsession.NewManager(&cfg.Resume)
 
-	// 12. 初始化业务 Handler（传入 gameClient）
-	gwHandler := handler.NewGatewayHandlerWithGame(l, jwtMgr, processor, sessMgr, roleProvider, gameClient)
+	// 13. 建立到 Game 的双向流：角色上线/下线时通知 Game，Game 经此流向客户端推送消息
+	if cfg.Gateway.ID == "" {
+		l.Error("gateway.id is required")
+		return
+	}
+	gameStreamCfg := cfg.GameStream
+	gameStreamCfg.Framer = fr
+	streamConnector := game.NewStreamConnector(l, common.NewCommonServiceClient(conn), &gameStreamCfg, sessMgr, cfg.Gateway.ID, cfg.Zone.ID)
+	if err := streamConnector.Connect(context.Background(), gameAddr); err != nil {
+		l.Error("failed to connect game stream", "error", err)
+		return
+	}
+	defer streamConnector.Close()
 
-	// 13. 初始化 Session 配置（注入 Framer）
+	// 14. 初始化业务 Handler（传入 gameClient）
+	gwHandler := handler.NewGatewayHandlerWithGame(l, jwtMgr, cfg.Zone.ID, processor, sessMgr, roleProvider, gameClient)
+
+	// 15. 初始化 Session 配置（注入 Framer，发送队列溢出次数上报到 Prometheus）
 	sessCfg := cfg.Session
 	sessCfg.Framer = fr
+	sessCfg.FramerConfig = &cfg.Framer
+	sessCfg.OverflowObserver = session.NewOverflowMetrics(promClient.Config().Namespace, promClient.Registry())
 
-	// 14. 初始化 Session Server
+	// 16. 初始化 Session Server
 	sessServer := session.NewServer(&session.ServerConfig{
 		Session: &sessCfg,
 		Handler: gwHandler,
 	})
 
-	// 15. 初始化 TCP Acceptor (并包装托管逻辑)
-	sessServer.Config().Acceptor = sessServer.ManagedAcceptor(func(h session.SessionHandler) session.Acceptor {
-		return tcp.NewAcceptor(&cfg.TCP, &sessCfg, h)
-	})
+	// 17. 初始化 Acceptor (并包装托管逻辑)，TCP、WebSocket、KCP 共享同一个 Handler 与会话管理
+	acceptors := []session.Acceptor{
+		sessServer.ManagedAcceptor(func(h session.SessionHandler) session.Acceptor {
+			return tcp.NewAcceptor(&cfg.TCP, &sessCfg, h)
+		}),
+	}
+	metadata := map[string]string{
+		registry.MetadataZoneID: strconv.FormatInt(int64(cfg.Zone.ID), 10),
+	}
+	if cfg.WebSocket.Addr != "" {
+		wsServer, err := websocket.NewServer(&cfg.WebSocket.ServerConfig, websocket.WithServerLogger(l.Named("websocket")))
+		if err != nil {
+			l.Error("failed to create websocket server", "error", err)
+			return
+		}
+		acceptors = append(acceptors, sessServer.ManagedAcceptor(func(h session.SessionHandler) session.Acceptor {
+			return websocket.NewAcceptor(wsServer, &sessCfg, cfg.WebSocket.Addr, h)
+		}))
+		metadata["ws_addr"] = cfg.WebSocket.Addr
+	}
+	if cfg.KCP.Addr != "" {
+		acceptors = append(acceptors, sessServer.ManagedAcceptor(func(h session.SessionHandler) session.Acceptor {
+			return kcp.NewAcceptor(&cfg.KCP, &sessCfg, h)
+		}))
+		metadata["kcp_addr"] = cfg.KCP.Addr
+	}
+	sessServer.Config().Acceptor = session.NewMultiAcceptor(acceptors...)
 
-	// 16. 创建服务注册器
+	// 18. 创建服务注册器
 	registrar, err := etcd.NewRegistrar(&cfg.Registry)
 	if err != nil {
 		l.Error("failed to create registrar", "error", err)
 		return
 	}
 
-	// 17. 创建应用并注册服务
+	// 19. 创建应用并注册服务
 	application := app.NewBaseApp(
 		app.WithName("gateway"),
 		app.WithLogger(l),
@@ -168,11 +273,19 @@ func main() {
 		info: &registry.ServiceInfo{
 			ServiceName: "gateway",
 			Address:     cfg.TCP.Addr,
-			Metadata:    make(map[string]string),
+			Metadata:    metadata,
 		},
 	})
 
-	// 18. 运行
+	// 注册后定期上报负载，Login 据此执行区服在线上限与登录排队，并按负载分配网关
+	sysCollector, err := system.New()
+	if err != nil {
+		l.Error("failed to create system collector", "error", err)
+		return
+	}
+	application.AppendServer(gwsession.NewLoadReporter(l, &cfg.LoadReport, sessMgr, sysCollector, registrar, metadata))
+
+	// 20. 运行
 	if err := application.Run(); err != nil {
 		l.Error("gateway exited with error", "error", err)
 	}
@@ -24,6 +24,9 @@ type StreamConnector struct {
 	gatewayID  string
 	zoneID     int32
 
+	// 请求/响应调用表，来自 Game 的响应在 OnMessage 之前分发
+	rpc *session.RPC
+
 	mu          sync.RWMutex
 	gameSession session.Session
 	connected   bool
@@ -45,10 +48,15 @@ func NewStreamConnector(
 		gatewayID: gatewayID,
 		zoneID:    zoneID,
 		stopCh:    make(chan struct{}),
+		rpc:       session.NewRPC(session.DefaultRPCTimeout),
 	}
 
-	// 创建 Connector，使用自定义 handler
-	sc.connector = grpcpkg.NewConnector(grpcClient, sessionConfig, sc)
+	// 创建 Connector，使用自定义 handler（RPC 响应由调用表分发）
+	sc.connector = grpcpkg.NewConnector(grpcClient, sessionConfig, sc.rpc.Handler(sc))
+
+	// 角色上线/下线时通知 Game，Game 据此将推送路由到本 Gateway
+	sessMgr.OnRoleOnline(sc.handleRoleOnline)
+	sessMgr.OnRoleOffline(sc.handleRoleOffline)
 
 	return sc
 }
@@ -104,6 +112,19 @@ func (sc *StreamConnector) Close() error {
 	return nil
 }
 
+// Call 向 Game 发送请求并等待响应，实现 session.Caller，可配合 session.Invoke 使用
+func (sc *StreamConnector) Call(ctx context.Context, op uint32, payload []byte) (*common.Envelope, error) {
+	sc.mu.RLock()
+	sess := sc.gameSession
+	connected := sc.connected
+	sc.mu.RUnlock()
+
+	if !connected || sess == nil {
+		return nil, fmt.Errorf("not connected to game")
+	}
+	return sc.rpc.Call(ctx, sess, op, payload)
+}
+
 // ForwardMessage 转发客户端消息到 Game
 func (sc *StreamConnector) ForwardMessage(ctx context.Context, roleID int64, sessionID string, clientOp uint32, clientPayload []byte) error {
 	sc.mu.RLock()
@@ -239,6 +260,34 @@ func (sc *StreamConnector) NotifyPlayerOffline(ctx context.Context, roleID int64
 	return nil
 }
 
+// handleRoleOnline 角色上线（选择角色或会话恢复），通知 Game 将角色绑定到本 Gateway
+func (sc *StreamConnector) handleRoleOnline(roleID, uid int64, sessionID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := sc.NotifyPlayerOnline(ctx, roleID, uid, sessionID); err != nil {
+		sc.logger.Warn("notify player online failed",
+			"role_id", roleID,
+			"session_id", sessionID,
+			"error", err,
+		)
+	}
+}
+
+// handleRoleOffline 角色下线（切换角色、会话注销或恢复窗口到期），通知 Game 解除绑定
+func (sc *StreamConnector) handleRoleOffline(roleID int64, sessionID string, reason gwsession.OfflineReason) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := sc.NotifyPlayerOffline(ctx, roleID, sessionID, int32(reason)); err != nil {
+		sc.logger.Warn("notify player offline failed",
+			"role_id", roleID,
+			"session_id", sessionID,
+			"error", err,
+		)
+	}
+}
+
 // heartbeatLoop 心跳循环
 func (sc *StreamConnector) heartbeatLoop() error {
 	ticker := time.NewTicker(30 * time.Second)
@@ -277,19 +326,16 @@ func (sc *StreamConnector) sendHeartbeat() {
 		return
 	}
 
-	env := &common.Envelope{
-		Header: &common.MessageHeader{
-			Op: uint32(internal.OpCode_OP_GATEWAY_HEARTBEAT),
-		},
-		Payload: payload,
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := sess.Send(ctx, env); err != nil {
-		sc.logger.Warn("send heartbeat failed", "error", err)
+	// 以 RPC 发出，等待 Game 的应答以确认其仍在处理消息
+	start := time.Now()
+	if _, err := sc.rpc.Call(ctx, sess, uint32(internal.OpCode_OP_GATEWAY_HEARTBEAT), payload); err != nil {
+		sc.logger.Warn("heartbeat failed", "error", err)
+		return
 	}
+	sc.logger.Debug("heartbeat acked", "rtt", time.Since(start))
 }
 
 // ============================================================================
@@ -0,0 +1,176 @@
+package game
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	common "github.com/lk2023060901/xdooria-proto-common"
+	internal "github.com/lk2023060901/xdooria-proto-internal"
+	gwsession "github.com/lk2023060901/xdooria/app/gateway/internal/session"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/network/framer"
+	grpcpkg "github.com/lk2023060901/xdooria/pkg/network/grpc"
+	"github.com/lk2023060901/xdooria/pkg/network/session"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeGame Game 侧的流处理器，记录 Gateway 发来的消息并可主动推送
+type fakeGame struct {
+	session.NopSessionHandler
+
+	mu       sync.Mutex
+	sess     session.Session
+	received chan *common.Envelope
+}
+
+func newFakeGame() *fakeGame {
+	return &fakeGame{received: make(chan *common.Envelope, 16)}
+}
+
+func (g *fakeGame) OnOpened(s session.Session) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sess = s
+}
+
+func (g *fakeGame) OnMessage(s session.Session, env *common.Envelope) {
+	g.received <- env
+}
+
+// expect 等待 Gateway 发来指定 op 的消息并解析 Payload
+func (g *fakeGame) expect(t *testing.T, op internal.OpCode, msg proto.Message) {
+	t.Helper()
+	select {
+	case env := <-g.received:
+		if env.Header.Op != uint32(op) {
+			t.Fatalf("game received op %d, want %d", env.Header.Op, op)
+		}
+		if err := proto.Unmarshal(env.Payload, msg); err != nil {
+			t.Fatalf("unmarshal %v: %v", op, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("game did not receive %v", op)
+	}
+}
+
+// push 经流向 Gateway 发送消息
+func (g *fakeGame) push(t *testing.T, op internal.OpCode, msg proto.Message) {
+	t.Helper()
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("marshal %v: %v", op, err)
+	}
+	g.mu.Lock()
+	sess := g.sess
+	g.mu.Unlock()
+	env := &common.Envelope{Header: &common.MessageHeader{Op: uint32(op)}, Payload: payload}
+	if err := sess.Send(context.Background(), env); err != nil {
+		t.Fatalf("push %v: %v", op, err)
+	}
+}
+
+// clientSession 客户端连接，记录 Gateway 下发的消息
+type clientSession struct {
+	session.Session
+	id   string
+	sent chan *common.Envelope
+}
+
+func newClientSession(id string) *clientSession {
+	return &clientSession{id: id, sent: make(chan *common.Envelope, 16)}
+}
+
+func (s *clientSession) ID() string               { return s.id }
+func (s *clientSession) Context() context.Context { return context.Background() }
+func (s *clientSession) Close() error             { return nil }
+
+func (s *clientSession) Send(ctx context.Context, env *common.Envelope) error {
+	s.sent <- env
+	return nil
+}
+
+// newStreamConfig 返回流会话配置，两端使用相同的 Framer 配置
+func newStreamConfig(t *testing.T) *session.Config {
+	t.Helper()
+	fr, err := framer.New(&framer.Config{})
+	if err != nil {
+		t.Fatalf("create framer: %v", err)
+	}
+	return &session.Config{Framer: fr}
+}
+
+// startGame 启动挂载 CommonService 流的 gRPC 服务，返回到该服务的连接
+func startGame(t *testing.T, game *fakeGame) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	common.RegisterCommonServiceServer(srv, grpcpkg.NewAcceptor(newStreamConfig(t), game))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///game",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial game: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestStreamConnector_PushReachesClient(t *testing.T) {
+	game := newFakeGame()
+	conn := startGame(t, game)
+
+	sessMgr := gwsession.NewManager(&gwsession.ResumeConfig{})
+	sc := NewStreamConnector(logger.Default(), common.NewCommonServiceClient(conn), newStreamConfig(t), sessMgr, "gw-1", 1)
+	if err := sc.Connect(context.Background(), "game"); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { sc.Close() })
+
+	// 选择角色后通知 Game 上线
+	client := newClientSession("c1")
+	sessMgr.Register(client)
+	sessMgr.UpdateAuthState("c1", 1001)
+	if err := sessMgr.UpdateRoleState("c1", 42); err != nil {
+		t.Fatalf("select role: %v", err)
+	}
+	var online internal.PlayerOnlineNotify
+	game.expect(t, internal.OpCode_OP_GATEWAY_PLAYER_ONLINE, &online)
+	if online.RoleId != 42 || online.Uid != 1001 || online.SessionId != "c1" || online.GatewayId != "gw-1" || online.ZoneId != 1 {
+		t.Fatalf("online notify = %+v", &online)
+	}
+
+	// Game 推送的消息到达客户端
+	game.push(t, internal.OpCode_OP_GAME_SEND_TO_CLIENT, &internal.SendToClientRequest{
+		RoleId:  42,
+		Op:      5001,
+		Payload: []byte("level up"),
+	})
+	select {
+	case env := <-client.sent:
+		if env.Header.Op != 5001 || string(env.Payload) != "level up" {
+			t.Fatalf("client received op %d payload %q", env.Header.Op, env.Payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("push did not reach the client")
+	}
+
+	// 连接断开后通知 Game 下线
+	sessMgr.Detach("c1")
+	var offline internal.PlayerOfflineNotify
+	game.expect(t, internal.OpCode_OP_GATEWAY_PLAYER_OFFLINE, &offline)
+	if offline.RoleId != 42 || offline.SessionId != "c1" || offline.GatewayId != "gw-1" || offline.Reason != int32(gwsession.OfflineDisconnect) {
+		t.Fatalf("offline notify = %+v", &offline)
+	}
+}
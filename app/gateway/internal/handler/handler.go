@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 
 	api "github.com/lk2023060901/xdooria-proto-api"
@@ -31,6 +32,7 @@ type GatewayHandler struct {
 	session.NopSessionHandler
 	logger        logger.Logger
 	jwtMgr        *security.JWTManager
+	zoneID        int32          // 所属区服，只接受本区服的 LoginToken
 	sessionRouter *SessionRouter // Gateway 专用的 Session Router
 	processor     router.Processor
 	sessMgr       *gwsession.Manager
@@ -41,6 +43,7 @@ type GatewayHandler struct {
 func NewGatewayHandler(
 	l logger.Logger,
 	jwtMgr *security.JWTManager,
+	zoneID int32,
 	p router.Processor,
 	sessMgr *gwsession.Manager,
 	roleProvider RoleProvider,
@@ -48,6 +51,7 @@ func NewGatewayHandler(
 	h := &GatewayHandler{
 		logger:        l.Named("gateway.handler"),
 		jwtMgr:        jwtMgr,
+		zoneID:        zoneID,
 		sessionRouter: NewSessionRouter(),
 		processor:     p,
 		sessMgr:       sessMgr,
@@ -63,6 +67,7 @@ func NewGatewayHandler(
 func NewGatewayHandlerWithGame(
 	l logger.Logger,
 	jwtMgr *security.JWTManager,
+	zoneID int32,
 	p router.Processor,
 	sessMgr *gwsession.Manager,
 	roleProvider RoleProvider,
@@ -71,6 +76,7 @@ func NewGatewayHandlerWithGame(
 	h := &GatewayHandler{
 		logger:        l.Named("gateway.handler"),
 		jwtMgr:        jwtMgr,
+		zoneID:        zoneID,
 		sessionRouter: NewSessionRouter(),
 		processor:     p,
 		sessMgr:       sessMgr,
@@ -103,8 +109,8 @@ func (h *GatewayHandler) OnOpened(s session.Session) {
 }
 
 func (h *GatewayHandler) OnClosed(s session.Session, err error) {
-	// 从 Session 管理器注销
-	h.sessMgr.Unregister(s.ID())
+	// 从 Session 管理器注销（启用会话恢复时已认证的会话在恢复窗口内保留）
+	h.sessMgr.Detach(s.ID())
 	h.logger.Info("client disconnected", "id", s.ID(), "error", err)
 }
 
@@ -117,12 +123,13 @@ func (h *GatewayHandler) OnMessage(s session.Session, env *common.Envelope) {
 	// 优先使用 SessionRouter 处理 Gateway 特定消息（认证、角色相关）
 	respOp, respPayload, err := h.sessionRouter.Dispatch(s.Context(), s, op, payload)
 	if err == nil {
-		// SessionRouter 成功处理，发送响应
-		respEnv := &common.Envelope{
-			Header:  &common.MessageHeader{Op: respOp},
-			Payload: respPayload,
+		// SessionRouter 成功处理，发送响应（带回请求的 RPC 关联 ID，经 GatewaySession 发送以计入下行 seq）
+		respEnv := session.Reply(env, respOp, respPayload)
+		var sender session.Session = s
+		if gwSess, ok := h.sessMgr.Get(s.ID()); ok {
+			sender = gwSess
 		}
-		if err := s.Send(s.Context(), respEnv); err != nil {
+		if err := sender.Send(s.Context(), respEnv); err != nil {
 			h.logger.Error("send response failed", "id", s.ID(), "error", err)
 		}
 		return
@@ -168,11 +175,8 @@ func (h *GatewayHandler) forwardToGame(s *gwsession.GatewaySession, env *common.
 			return
 		}
 
-		// 发送响应
-		respEnv := &common.Envelope{
-			Header:  &common.MessageHeader{Op: op + 1}, // 响应 op = 请求 op + 1
-			Payload: resp.Payload,
-		}
+		// 发送响应（响应 op = 请求 op + 1）
+		respEnv := session.Reply(env, op+1, resp.Payload)
 		if err := s.Send(s.Context(), respEnv); err != nil {
 			h.logger.Error("send response failed", "id", s.ID(), "error", err)
 		}
@@ -188,10 +192,7 @@ func (h *GatewayHandler) forwardToGame(s *gwsession.GatewaySession, env *common.
 	}
 
 	// 发送响应
-	respEnv := &common.Envelope{
-		Header:  &common.MessageHeader{Op: respOp},
-		Payload: respPayload,
-	}
+	respEnv := session.Reply(env, respOp, respPayload)
 	if err := s.Send(s.Context(), respEnv); err != nil {
 		h.logger.Error("send response failed", "id", s.ID(), "error", err)
 	}
@@ -211,16 +212,16 @@ func (h *GatewayHandler) handleAuth(ctx context.Context, s session.Session, req
 	}
 
 	// 从 JWT 中提取 uid
-	uidStr, ok := claims.Get("uid").(string)
-	if !ok {
-		h.logger.Error("uid not found in token", "id", s.ID())
+	uid, err := uidFromClaims(claims)
+	if err != nil {
+		h.logger.Error("failed to get uid from token", "id", s.ID(), "error", err)
 		return &api.AuthResponse{Code: uint32(api.ErrorCode_ERR_INTERNAL)}, nil
 	}
 
-	uid, err := strconv.ParseInt(uidStr, 10, 64)
-	if err != nil {
-		h.logger.Error("failed to parse uid", "id", s.ID(), "uid_str", uidStr, "error", err)
-		return &api.AuthResponse{Code: uint32(api.ErrorCode_ERR_INTERNAL)}, nil
+	// Token 绑定了其他区服时拒绝，避免绕过区服的登录排队
+	if err := h.checkZone(claims); err != nil {
+		h.logger.Warn("token zone mismatch", "id", s.ID(), "uid", uid, "error", err)
+		return &api.AuthResponse{Code: uint32(api.ErrorCode_ERR_TOKEN_INVALID)}, nil
 	}
 
 	// 查询该用户的所有角色
@@ -234,9 +235,7 @@ func (h *GatewayHandler) handleAuth(ctx context.Context, s session.Session, req
 	}
 
 	// 生成 Gateway 的 SessionToken（使用 Gateway 配置的过期时间）
-	sessionToken, err := h.jwtMgr.GenerateToken(&security.Claims{
-		Payload: claims.Payload, // 继承 uid 等信息
-	})
+	sessionToken, err := h.generateSessionToken(s, claims)
 	if err != nil {
 		h.logger.Error("failed to generate session token", "id", s.ID(), "error", err)
 		return &api.AuthResponse{Code: uint32(api.ErrorCode_ERR_INTERNAL)}, nil
@@ -259,6 +258,8 @@ func (h *GatewayHandler) handleAuth(ctx context.Context, s session.Session, req
 }
 
 // handleReconnect 处理重连请求
+// 客户端请求恢复会话时，将断线会话的认证状态、角色与下行缓冲恢复到新连接，
+// 并在响应之前补发 last_seq 之后的消息；无法恢复时只恢复认证状态
 func (h *GatewayHandler) handleReconnect(ctx context.Context, s session.Session, req *api.ReconnectRequest) (*api.ReconnectResponse, error) {
 	// 验证 SessionToken
 	claims, err := h.jwtMgr.ValidateToken(req.Token)
@@ -271,22 +272,97 @@ func (h *GatewayHandler) handleReconnect(ctx context.Context, s session.Session,
 		return &api.ReconnectResponse{Code: code}, nil
 	}
 
-	// 续期：生成新的 SessionToken
-	newToken, err := h.jwtMgr.GenerateToken(&security.Claims{
-		Payload: claims.Payload,
-	})
+	uid, err := uidFromClaims(claims)
+	if err != nil {
+		h.logger.Error("failed to get uid from token", "id", s.ID(), "error", err)
+		return &api.ReconnectResponse{Code: uint32(api.ErrorCode_ERR_INTERNAL)}, nil
+	}
+	if err := h.checkZone(claims); err != nil {
+		h.logger.Warn("reconnect token zone mismatch", "id", s.ID(), "uid", uid, "error", err)
+		return &api.ReconnectResponse{Code: uint32(api.ErrorCode_ERR_TOKEN_INVALID)}, nil
+	}
+
+	// 恢复会话，失败时按新会话处理
+	resumed := false
+	if req.Resume && h.sessMgr.ResumeEnabled() {
+		oldID, _ := claims.Get(claimSessionID).(string)
+		replayed, err := h.sessMgr.Resume(oldID, s.ID(), uid, req.LastSeq)
+		if err != nil {
+			h.logger.Info("session resume failed", "id", s.ID(), "old_id", oldID, "uid", uid, "last_seq", req.LastSeq, "error", err)
+		} else {
+			resumed = true
+			h.logger.Info("session resumed", "id", s.ID(), "old_id", oldID, "uid", uid, "replayed", replayed)
+		}
+	}
+	if !resumed {
+		h.sessMgr.UpdateAuthState(s.ID(), uid)
+	}
+
+	// 续期：生成新的 SessionToken（绑定新连接的会话 ID）
+	newToken, err := h.generateSessionToken(s, claims)
 	if err != nil {
 		h.logger.Error("failed to generate new session token", "id", s.ID(), "error", err)
 		return &api.ReconnectResponse{Code: uint32(api.ErrorCode_ERR_INTERNAL)}, nil
 	}
 
-	h.logger.Info("client reconnected", "id", s.ID(), "uid", claims.Get("uid"))
+	h.logger.Info("client reconnected", "id", s.ID(), "uid", uid, "resumed", resumed)
 	return &api.ReconnectResponse{
-		Code:  uint32(api.ErrorCode_ERR_SUCCESS),
-		Token: newToken,
+		Code:    uint32(api.ErrorCode_ERR_SUCCESS),
+		Token:   newToken,
+		Resumed: resumed,
 	}, nil
 }
 
+// claimSessionID SessionToken 中记录签发时会话 ID 的字段，用于重连时恢复会话
+const claimSessionID = "session_id"
+
+// generateSessionToken 生成绑定当前会话的 SessionToken，继承 uid 等信息
+func (h *GatewayHandler) generateSessionToken(s session.Session, claims *security.Claims) (string, error) {
+	payload := make(map[string]any, len(claims.Payload)+1)
+	for k, v := range claims.Payload {
+		payload[k] = v
+	}
+	payload[claimSessionID] = s.ID()
+
+	return h.jwtMgr.GenerateToken(&security.Claims{
+		Payload: payload,
+	})
+}
+
+// claimZoneID Login 签发的 Token 中记录分配区服的字段，SessionToken 继承该字段
+const claimZoneID = "zone_id"
+
+// checkZone 校验 Token 绑定的区服，未绑定区服（0 或缺失，Login 未启用排队）时不限制
+func (h *GatewayHandler) checkZone(claims *security.Claims) error {
+	zoneStr, ok := claims.Get(claimZoneID).(string)
+	if !ok {
+		return nil
+	}
+
+	zoneID, err := strconv.ParseInt(zoneStr, 10, 32)
+	if err != nil {
+		return fmt.Errorf("failed to parse zone_id %q: %w", zoneStr, err)
+	}
+	if zoneID != 0 && int32(zoneID) != h.zoneID {
+		return fmt.Errorf("token issued for zone %d, gateway zone %d", zoneID, h.zoneID)
+	}
+	return nil
+}
+
+// uidFromClaims 从 Token 中提取 uid
+func uidFromClaims(claims *security.Claims) (int64, error) {
+	uidStr, ok := claims.Get("uid").(string)
+	if !ok {
+		return 0, fmt.Errorf("uid not found in token")
+	}
+
+	uid, err := strconv.ParseInt(uidStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse uid %q: %w", uidStr, err)
+	}
+	return uid, nil
+}
+
 // handleGetRoles 处理获取角色列表请求
 func (h *GatewayHandler) handleGetRoles(ctx context.Context, s session.Session, req *api.GetRolesRequest) (*api.GetRolesResponse, error) {
 	// 获取 Gateway Session
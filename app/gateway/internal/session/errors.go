@@ -17,4 +17,16 @@ var (
 
 	// ErrInvalidRole 无效的角色
 	ErrInvalidRole = errors.New("invalid role")
+
+	// ErrResumeNotFound 待恢复的会话不存在或已超过恢复窗口
+	ErrResumeNotFound = errors.New("resume session not found")
+
+	// ErrResumeUIDMismatch 待恢复的会话属于其他用户
+	ErrResumeUIDMismatch = errors.New("resume session uid mismatch")
+
+	// ErrResumeSeqInvalid 客户端确认的 seq 超过了已发送的最大 seq
+	ErrResumeSeqInvalid = errors.New("resume seq invalid")
+
+	// ErrResumeBufferOverflow 断线期间的消息超出缓冲上限，已无法完整补发
+	ErrResumeBufferOverflow = errors.New("resume buffer overflow")
 )
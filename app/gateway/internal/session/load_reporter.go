@@ -0,0 +1,171 @@
+package session
+
+import (
+	"context"
+	"maps"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lk2023060901/xdooria/pkg/config"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/metrics/system"
+	"github.com/lk2023060901/xdooria/pkg/registry"
+	"github.com/lk2023060901/xdooria/pkg/util/conc"
+)
+
+// LoadReportConfig 负载上报配置
+type LoadReportConfig struct {
+	// Interval 上报间隔，未配置时为 5s
+	Interval time.Duration `mapstructure:"interval" json:"interval" yaml:"interval"`
+	// MaxSessions 连接数达到该值时标记为排空，0 表示不限制
+	MaxSessions int `mapstructure:"max_sessions" json:"max_sessions" yaml:"max_sessions"`
+	// MaxCPUPercent CPU 使用率（0-100）达到该值时标记为排空，0 表示不限制
+	MaxCPUPercent float64 `mapstructure:"max_cpu_percent" json:"max_cpu_percent" yaml:"max_cpu_percent"`
+	// DrainFile 该文件存在时标记为排空（运维手动排空，如停机维护前），为空时不检查
+	DrainFile string `mapstructure:"drain_file" json:"drain_file" yaml:"drain_file"`
+}
+
+// DefaultLoadReportConfig 返回默认负载上报配置
+func DefaultLoadReportConfig() *LoadReportConfig {
+	return &LoadReportConfig{
+		Interval: 5 * time.Second,
+	}
+}
+
+// LoadReporter 定期将在线人数、连接数、CPU 使用率与排空状态写入服务注册元数据，
+// Login 据此执行区服在线上限与登录排队，并按负载分配网关、跳过排空中的网关。
+// 实现 app.Server 接口，需在服务注册之后启动。
+type LoadReporter struct {
+	cfg       *LoadReportConfig
+	mgr       *Manager
+	sys       *system.Collector
+	registrar registry.Registrar
+	metadata  map[string]string // 注册时的静态元数据（区服、WebSocket/KCP 地址），每次上报时合并
+	draining  bool              // 最近一次上报的排空状态
+	logger    logger.Logger
+	stopCh    chan struct{}
+	runFuture *conc.Future[struct{}]
+}
+
+// NewLoadReporter 创建负载上报器，sys 为 nil 时不上报 CPU 使用率
+func NewLoadReporter(
+	l logger.Logger,
+	cfg *LoadReportConfig,
+	mgr *Manager,
+	sys *system.Collector,
+	registrar registry.Registrar,
+	metadata map[string]string,
+) *LoadReporter {
+	reportCfg, err := config.MergeConfig(DefaultLoadReportConfig(), cfg)
+	if err != nil {
+		reportCfg = DefaultLoadReportConfig()
+	}
+
+	return &LoadReporter{
+		cfg:       reportCfg,
+		mgr:       mgr,
+		sys:       sys,
+		registrar: registrar,
+		metadata:  metadata,
+		logger:    l.Named("gateway.load"),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start 启动系统指标采集，立即上报一次并启动定时上报
+func (r *LoadReporter) Start() error {
+	if r.sys != nil {
+		r.sys.Start(r.cfg.Interval)
+	}
+	r.report()
+	r.runFuture = conc.Go(func() (struct{}, error) {
+		r.run()
+		return struct{}{}, nil
+	})
+	return nil
+}
+
+// Stop 停止定时上报，等待上报协程退出
+func (r *LoadReporter) Stop() error {
+	close(r.stopCh)
+	if r.runFuture != nil {
+		_ = r.runFuture.Err()
+	}
+	if r.sys != nil {
+		r.sys.Stop()
+	}
+	return nil
+}
+
+func (r *LoadReporter) run() {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.report()
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+// report 执行一次上报，失败只记录日志，下个周期重试
+func (r *LoadReporter) report() {
+	online := r.mgr.OnlineUserCount()
+	sessions := r.mgr.Count()
+	var cpuPercent float64
+	if r.sys != nil {
+		cpuPercent = r.sys.GetCPUIntervalPercent()
+	}
+	draining := r.shouldDrain(sessions, cpuPercent)
+
+	metadata := maps.Clone(r.metadata)
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata[registry.MetadataOnline] = strconv.Itoa(online)
+	metadata[registry.MetadataSessions] = strconv.Itoa(sessions)
+	if r.sys != nil {
+		metadata[registry.MetadataCPUPercent] = strconv.FormatFloat(cpuPercent, 'f', 2, 64)
+	}
+	metadata[registry.MetadataDraining] = strconv.FormatBool(draining)
+	metadata[registry.MetadataUpdatedAt] = time.Now().Format(time.RFC3339)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := r.registrar.UpdateMetadata(ctx, metadata); err != nil {
+		r.logger.Warn("failed to report load", "error", err)
+		return
+	}
+	if draining != r.draining {
+		r.draining = draining
+		r.logger.Info("gateway draining state changed", "draining", draining, "sessions", sessions, "cpu_percent", cpuPercent)
+	}
+	r.logger.Debug("load reported",
+		"online", online,
+		"sessions", sessions,
+		"cpu_percent", cpuPercent,
+		"draining", draining,
+	)
+}
+
+// shouldDrain 判断是否应标记为排空：存在排空文件，或连接数、CPU 使用率达到上限。
+// 负载回落到上限以下后自动恢复接收新的客户端。
+func (r *LoadReporter) shouldDrain(sessions int, cpuPercent float64) bool {
+	if r.cfg.DrainFile != "" {
+		if _, err := os.Stat(r.cfg.DrainFile); err == nil {
+			return true
+		}
+	}
+	if r.cfg.MaxSessions > 0 && sessions >= r.cfg.MaxSessions {
+		return true
+	}
+	if r.cfg.MaxCPUPercent > 0 && cpuPercent >= r.cfg.MaxCPUPercent {
+		return true
+	}
+	return false
+}
@@ -2,7 +2,9 @@ package session
 
 import (
 	"sync"
+	"time"
 
+	"github.com/lk2023060901/xdooria/pkg/config"
 	"github.com/lk2023060901/xdooria/pkg/network/session"
 )
 
@@ -16,14 +18,55 @@ type Manager struct {
 
 	// RoleID 索引，用于快速查找角色对应的会话
 	roleIndex map[int64]*GatewaySession // roleID -> GatewaySession
+
+	// 会话恢复：断线后等待重连的会话，仍保留在 UID/RoleID 索引中，期间的下行消息只缓存
+	resume   *ResumeConfig
+	detached map[string]*detachedSession // sessionID -> 断线会话
+
+	// 角色上线/下线监听（选择角色、会话恢复、会话注销或恢复窗口到期时触发）
+	onlineListeners  []func(roleID, uid int64, sessionID string)
+	offlineListeners []func(roleID int64, sessionID string, reason OfflineReason)
+}
+
+// OfflineReason 角色下线原因
+type OfflineReason int32
+
+const (
+	// OfflineDisconnect 连接断开且会话未保留（未启用会话恢复、未认证或恢复失败）
+	OfflineDisconnect OfflineReason = iota + 1
+	// OfflineResumeExpired 断线后恢复窗口到期
+	OfflineResumeExpired
+	// OfflineSwitchRole 同一会话切换了角色
+	OfflineSwitchRole
+)
+
+// roleOffline 待通知的角色下线事件
+type roleOffline struct {
+	roleID    int64
+	sessionID string
+	reason    OfflineReason
+}
+
+// detachedSession 断线等待恢复的会话
+type detachedSession struct {
+	gwSess *GatewaySession
+	timer  *time.Timer // 恢复窗口到期后清理
 }
 
 // NewManager 创建 Session 管理器
-func NewManager() *Manager {
+func NewManager(cfg *ResumeConfig) *Manager {
+	// 使用 MergeConfig 确保配置完整
+	resumeCfg, err := config.MergeConfig(DefaultResumeConfig(), cfg)
+	if err != nil {
+		resumeCfg = DefaultResumeConfig()
+	}
+
 	return &Manager{
 		sessions:  make(map[string]*GatewaySession),
 		uidIndex:  make(map[int64]map[string]*GatewaySession),
 		roleIndex: make(map[int64]*GatewaySession),
+		resume:    resumeCfg,
+		detached:  make(map[string]*detachedSession),
 	}
 }
 
@@ -33,6 +76,9 @@ func (m *Manager) Register(base session.Session) *GatewaySession {
 	defer m.mu.Unlock()
 
 	gwSess := NewGatewaySession(base)
+	if m.resume.Enabled {
+		gwSess.outbound = newOutboundBuffer(base, m.resume)
+	}
 	m.sessions[base.ID()] = gwSess
 	return gwSess
 }
@@ -40,17 +86,160 @@ func (m *Manager) Register(base session.Session) *GatewaySession {
 // Unregister 注销 Session
 func (m *Manager) Unregister(sessionID string) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	gwSess, ok := m.sessions[sessionID]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+
+	offline := m.removeIndexes(sessionID, gwSess, OfflineDisconnect)
+
+	// 从主索引中移除
+	delete(m.sessions, sessionID)
+	m.mu.Unlock()
 
+	m.notifyOffline(offline)
+}
+
+// Detach 连接断开时调用：已认证的会话在恢复窗口内保留状态并缓存下行消息，
+// 未启用会话恢复或未认证的会话直接注销
+func (m *Manager) Detach(sessionID string) {
+	m.mu.Lock()
 	gwSess, ok := m.sessions[sessionID]
 	if !ok {
+		m.mu.Unlock()
 		return
 	}
+	delete(m.sessions, sessionID)
+
+	if !m.resume.Enabled || !gwSess.IsAuthenticated() {
+		offline := m.removeIndexes(sessionID, gwSess, OfflineDisconnect)
+		m.mu.Unlock()
+		m.notifyOffline(offline)
+		return
+	}
+	m.detach(sessionID, gwSess)
+	m.mu.Unlock()
+}
+
+// Resume 将断线会话 oldID 的认证状态、角色与下行缓冲恢复到新连接 newID 上，
+// 并在新连接上补发 lastSeq 之后的消息，返回补发条数
+// 旧连接仍在线（客户端先于服务端发现断线）时由新连接接管并关闭旧连接
+func (m *Manager) Resume(oldID, newID string, uid int64, lastSeq uint64) (int, error) {
+	// 1. 取出待恢复的会话
+	m.mu.Lock()
+	gwSess, ok := m.sessions[newID]
+	if !ok {
+		m.mu.Unlock()
+		return 0, ErrSessionNotFound
+	}
+	old, live, err := m.takeResumable(oldID, newID, uid)
+	m.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	if live {
+		old.Session.Close()
+	}
+
+	// 2. 补发断线期间的消息（锁外进行，补发完成前的新消息只进入缓冲）
+	replayed, err := old.outbound.attach(gwSess.Session, lastSeq)
+	if err != nil {
+		m.mu.Lock()
+		offline := m.removeIndexes(oldID, old, OfflineDisconnect)
+		m.mu.Unlock()
+		m.notifyOffline(offline)
+		return 0, err
+	}
+
+	// 3. 新连接接管会话状态与索引
+	m.mu.Lock()
+	if m.sessions[newID] != gwSess {
+		// 新连接在恢复过程中已断开，旧会话重新进入恢复窗口
+		old.outbound.detach()
+		m.detach(oldID, old)
+		m.mu.Unlock()
+		return 0, ErrSessionNotFound
+	}
+
+	gwSess.adopt(old)
+	// 旧会话的索引立即由新连接接管，不通知下线
+	m.removeIndexes(oldID, old, OfflineDisconnect)
+	m.uidIndexAdd(uid, newID, gwSess)
+	roleSelected, roleID := gwSess.IsRoleSelected(), gwSess.GetRoleID()
+	if roleSelected {
+		m.roleIndex[roleID] = gwSess
+	}
+	m.mu.Unlock()
+
+	// 角色改由新连接承载，重新上线以更新会话 ID
+	if roleSelected {
+		m.notifyOnline(roleID, uid, newID)
+	}
+	return replayed, nil
+}
+
+// takeResumable 从断线会话或在线会话中取出 oldID（必须持有 mu）
+// live 表示旧连接仍在线，需要调用方关闭
+func (m *Manager) takeResumable(oldID, newID string, uid int64) (old *GatewaySession, live bool, err error) {
+	if !m.resume.Enabled || oldID == "" || oldID == newID {
+		return nil, false, ErrResumeNotFound
+	}
+
+	if d, ok := m.detached[oldID]; ok {
+		if d.gwSess.GetUID() != uid {
+			return nil, false, ErrResumeUIDMismatch
+		}
+		d.timer.Stop()
+		delete(m.detached, oldID)
+		return d.gwSess, false, nil
+	}
+
+	if gwSess, ok := m.sessions[oldID]; ok && gwSess.outbound != nil {
+		if !gwSess.IsAuthenticated() || gwSess.GetUID() != uid {
+			return nil, false, ErrResumeUIDMismatch
+		}
+		delete(m.sessions, oldID)
+		gwSess.outbound.detach()
+		return gwSess, true, nil
+	}
 
+	return nil, false, ErrResumeNotFound
+}
+
+// detach 将会话放入恢复窗口（必须持有 mu）
+func (m *Manager) detach(sessionID string, gwSess *GatewaySession) {
+	gwSess.outbound.detach()
+	m.detached[sessionID] = &detachedSession{
+		gwSess: gwSess,
+		timer: time.AfterFunc(m.resume.Window, func() {
+			m.expire(sessionID, gwSess)
+		}),
+	}
+}
+
+// expire 恢复窗口到期，清理断线会话
+func (m *Manager) expire(sessionID string, gwSess *GatewaySession) {
+	var offline *roleOffline
+
+	m.mu.Lock()
+	if d, ok := m.detached[sessionID]; ok && d.gwSess == gwSess {
+		delete(m.detached, sessionID)
+		offline = m.removeIndexes(sessionID, gwSess, OfflineResumeExpired)
+	}
+	m.mu.Unlock()
+
+	m.notifyOffline(offline)
+}
+
+// removeIndexes 从 UID、RoleID 索引中移除会话（必须持有 mu）
+// 索引已指向其他会话（例如已被恢复到新连接）时不做修改
+// 角色因此下线时返回下线事件，由调用方在释放 mu 后通知
+func (m *Manager) removeIndexes(sessionID string, gwSess *GatewaySession, reason OfflineReason) *roleOffline {
 	// 从 UID 索引中移除
 	if gwSess.IsAuthenticated() {
 		uid := gwSess.GetUID()
-		if uidSessions, exists := m.uidIndex[uid]; exists {
+		if uidSessions, exists := m.uidIndex[uid]; exists && uidSessions[sessionID] == gwSess {
 			delete(uidSessions, sessionID)
 			if len(uidSessions) == 0 {
 				delete(m.uidIndex, uid)
@@ -61,11 +250,25 @@ func (m *Manager) Unregister(sessionID string) {
 	// 从 RoleID 索引中移除
 	if gwSess.IsRoleSelected() {
 		roleID := gwSess.GetRoleID()
-		delete(m.roleIndex, roleID)
+		if m.roleIndex[roleID] == gwSess {
+			delete(m.roleIndex, roleID)
+			return &roleOffline{roleID: roleID, sessionID: sessionID, reason: reason}
+		}
+	}
+	return nil
+}
+
+// uidIndexAdd 添加 UID 索引（必须持有 mu）
+func (m *Manager) uidIndexAdd(uid int64, sessionID string, gwSess *GatewaySession) {
+	if _, exists := m.uidIndex[uid]; !exists {
+		m.uidIndex[uid] = make(map[string]*GatewaySession)
 	}
+	m.uidIndex[uid][sessionID] = gwSess
+}
 
-	// 从主索引中移除
-	delete(m.sessions, sessionID)
+// ResumeEnabled 是否启用会话恢复
+func (m *Manager) ResumeEnabled() bool {
+	return m.resume.Enabled
 }
 
 // Get 获取 Session
@@ -92,30 +295,28 @@ func (m *Manager) UpdateAuthState(sessionID string, uid int64) {
 	gwSess.SetAuthenticated(true)
 
 	// 更新 UID 索引
-	if _, exists := m.uidIndex[uid]; !exists {
-		m.uidIndex[uid] = make(map[string]*GatewaySession)
-	}
-	m.uidIndex[uid][sessionID] = gwSess
+	m.uidIndexAdd(uid, sessionID, gwSess)
 }
 
 // UpdateRoleState 更新角色状态（选择角色后调用）
 func (m *Manager) UpdateRoleState(sessionID string, roleID int64) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	gwSess, ok := m.sessions[sessionID]
 	if !ok {
+		m.mu.Unlock()
 		return ErrSessionNotFound
 	}
 
 	if !gwSess.IsAuthenticated() {
+		m.mu.Unlock()
 		return ErrNotAuthenticated
 	}
 
-	// 如果之前选择过角色，先清除旧的索引
-	if gwSess.IsRoleSelected() {
-		oldRoleID := gwSess.GetRoleID()
+	// 如果之前选择过其他角色，先清除旧的索引
+	var offline *roleOffline
+	if oldRoleID := gwSess.GetRoleID(); gwSess.IsRoleSelected() && oldRoleID != roleID && m.roleIndex[oldRoleID] == gwSess {
 		delete(m.roleIndex, oldRoleID)
+		offline = &roleOffline{roleID: oldRoleID, sessionID: sessionID, reason: OfflineSwitchRole}
 	}
 
 	// 设置新的角色
@@ -124,10 +325,57 @@ func (m *Manager) UpdateRoleState(sessionID string, roleID int64) error {
 
 	// 更新 RoleID 索引
 	m.roleIndex[roleID] = gwSess
+	uid := gwSess.GetUID()
+	m.mu.Unlock()
 
+	m.notifyOffline(offline)
+	m.notifyOnline(roleID, uid, sessionID)
 	return nil
 }
 
+// OnRoleOnline 注册角色上线监听（选择角色、会话恢复到新连接后触发），需在接受连接前注册
+func (m *Manager) OnRoleOnline(fn func(roleID, uid int64, sessionID string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.onlineListeners = append(m.onlineListeners, fn)
+}
+
+// OnRoleOffline 注册角色下线监听（切换角色、会话注销或恢复窗口到期后触发），需在接受连接前注册
+// 角色已在同一 Gateway 的其他会话上线时不触发
+func (m *Manager) OnRoleOffline(fn func(roleID int64, sessionID string, reason OfflineReason)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.offlineListeners = append(m.offlineListeners, fn)
+}
+
+// notifyOnline 通知角色上线监听（不持有锁调用）
+func (m *Manager) notifyOnline(roleID, uid int64, sessionID string) {
+	m.mu.RLock()
+	listeners := m.onlineListeners
+	m.mu.RUnlock()
+
+	for _, fn := range listeners {
+		fn(roleID, uid, sessionID)
+	}
+}
+
+// notifyOffline 通知角色下线监听（不持有锁调用），offline 为 nil 时忽略
+func (m *Manager) notifyOffline(offline *roleOffline) {
+	if offline == nil {
+		return
+	}
+
+	m.mu.RLock()
+	listeners := m.offlineListeners
+	m.mu.RUnlock()
+
+	for _, fn := range listeners {
+		fn(offline.roleID, offline.sessionID, offline.reason)
+	}
+}
+
 // GetByUID 根据 UID 获取所有会话
 func (m *Manager) GetByUID(uid int64) []*GatewaySession {
 	m.mu.RLock()
@@ -0,0 +1,159 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	common "github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/pkg/network/session"
+)
+
+// ResumeConfig 会话恢复配置
+type ResumeConfig struct {
+	// Enabled 是否启用会话恢复（断线后保留会话状态并缓存下行消息）
+	// 启用后发送队列已满时不再按 overflow_policy 丢弃消息，而是断开连接等待客户端恢复
+	Enabled bool `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+	// Window 断线后保留会话的时间，超时未重连则清理
+	Window time.Duration `mapstructure:"window" json:"window" yaml:"window"`
+	// BufferSize 每个会话缓存的下行消息条数上限
+	BufferSize int `mapstructure:"buffer_size" json:"buffer_size" yaml:"buffer_size"`
+	// BufferBytes 每个会话缓存的下行消息 Payload 字节数上限
+	BufferBytes int `mapstructure:"buffer_bytes" json:"buffer_bytes" yaml:"buffer_bytes"`
+}
+
+// DefaultResumeConfig 返回默认会话恢复配置
+func DefaultResumeConfig() *ResumeConfig {
+	return &ResumeConfig{
+		Enabled:     false,
+		Window:      60 * time.Second,
+		BufferSize:  256,
+		BufferBytes: 256 * 1024,
+	}
+}
+
+// outboundBuffer 下行消息环形缓冲
+// 每条下行消息按发送顺序分配从 1 开始递增的 seq，保留最近的消息用于重连后补发。
+// 客户端按接收顺序计数即可得到每条消息的 seq，重连时携带最后收到的 seq。
+type outboundBuffer struct {
+	mu      sync.Mutex
+	entries []*common.Envelope // 环形队列，容量为 BufferSize
+	head    int                // 最旧消息的下标
+	count   int                // 当前缓存条数
+	bytes   int                // 当前缓存的 Payload 字节数
+	seq     uint64             // 最后分配的 seq
+	limit   int                // Payload 字节数上限
+	sink    session.Session    // 当前连接，断线期间为 nil
+}
+
+// newOutboundBuffer 创建下行缓冲并绑定到连接
+func newOutboundBuffer(s session.Session, cfg *ResumeConfig) *outboundBuffer {
+	return &outboundBuffer{
+		entries: make([]*common.Envelope, cfg.BufferSize),
+		limit:   cfg.BufferBytes,
+		sink:    s,
+	}
+}
+
+// send 分配 seq 并缓存消息，连接在线时同时发送；断线期间只缓存
+// 控制消息（心跳、握手、踢下线通知）走连接的优先队列，可能先于已编号的消息到达，因此不编号也不缓存
+func (b *outboundBuffer) send(ctx context.Context, env *common.Envelope) error {
+	if session.IsControl(env) {
+		b.mu.Lock()
+		sink := b.sink
+		b.mu.Unlock()
+		if sink == nil {
+			return nil
+		}
+		return sink.Send(ctx, env)
+	}
+
+	b.mu.Lock()
+	b.seq++
+	b.push(env)
+	sink := b.sink
+	if sink == nil {
+		b.mu.Unlock()
+		return nil
+	}
+	err := enqueue(sink, env)
+	if err != nil {
+		b.sink = nil
+	}
+	b.mu.Unlock()
+
+	// 消息未能进入发送队列：按断线处理，消息已缓存，客户端重连后补发
+	// 关闭在锁外进行，连接关闭回调会进入 detach
+	if err != nil {
+		sink.Close()
+	}
+	return nil
+}
+
+// enqueue 将已编号的消息压入连接的发送队列，队列已满时返回错误而不是按溢出策略处理
+// drop_oldest 会静默丢弃已编号的消息，drop_newest 与 block 超时会丢弃当前消息，都会让客户端的计数与 seq 错位
+func enqueue(s session.Session, env *common.Envelope) error {
+	q, ok := s.(interface{ TryEnqueue(*common.Envelope) bool })
+	if !ok {
+		return s.Send(s.Context(), env)
+	}
+	if !q.TryEnqueue(env) {
+		return session.ErrSendQueueFull
+	}
+	return nil
+}
+
+// push 追加消息，超过条数或字节数上限时淘汰最旧的消息
+func (b *outboundBuffer) push(env *common.Envelope) {
+	if len(b.entries) == 0 {
+		return
+	}
+	if b.count == len(b.entries) {
+		b.evict()
+	}
+	b.entries[(b.head+b.count)%len(b.entries)] = env
+	b.count++
+	b.bytes += len(env.Payload)
+
+	for b.bytes > b.limit && b.count > 1 {
+		b.evict()
+	}
+}
+
+// evict 淘汰最旧的消息
+func (b *outboundBuffer) evict() {
+	b.bytes -= len(b.entries[b.head].Payload)
+	b.entries[b.head] = nil
+	b.head = (b.head + 1) % len(b.entries)
+	b.count--
+}
+
+// detach 连接断开，之后的消息只缓存
+func (b *outboundBuffer) detach() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sink = nil
+}
+
+// attach 绑定新连接，补发 lastSeq 之后的消息，之后的消息直接发送
+// 补发在锁内完成，保证补发的消息先于任何新消息进入发送队列
+func (b *outboundBuffer) attach(s session.Session, lastSeq uint64) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if lastSeq > b.seq {
+		return 0, ErrResumeSeqInvalid
+	}
+	missed := int(b.seq - lastSeq)
+	if missed > b.count {
+		return 0, ErrResumeBufferOverflow
+	}
+
+	for i := b.count - missed; i < b.count; i++ {
+		if err := enqueue(s, b.entries[(b.head+i)%len(b.entries)]); err != nil {
+			return 0, err
+		}
+	}
+	b.sink = s
+	return missed, nil
+}
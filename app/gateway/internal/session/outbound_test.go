@@ -0,0 +1,332 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	common "github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/pkg/network/session"
+)
+
+// fakeSession 记录发送的消息
+type fakeSession struct {
+	session.Session
+	id string
+
+	mu     sync.Mutex
+	sent   []*common.Envelope
+	closed bool
+}
+
+func newFakeSession(id string) *fakeSession {
+	return &fakeSession{id: id}
+}
+
+func (s *fakeSession) ID() string               { return s.id }
+func (s *fakeSession) Context() context.Context { return context.Background() }
+
+func (s *fakeSession) Send(ctx context.Context, env *common.Envelope) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, env)
+	return nil
+}
+
+func (s *fakeSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// payloads 返回已发送消息的 Payload
+func (s *fakeSession) payloads() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, 0, len(s.sent))
+	for _, env := range s.sent {
+		out = append(out, string(env.Payload))
+	}
+	return out
+}
+
+// queueSession 模拟有界发送队列，队列已满时 TryEnqueue 失败
+type queueSession struct {
+	*fakeSession
+	capacity int
+}
+
+func (s *queueSession) TryEnqueue(env *common.Envelope) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sent) >= s.capacity {
+		return false
+	}
+	s.sent = append(s.sent, env)
+	return true
+}
+
+func newEnvelope(payload string) *common.Envelope {
+	return &common.Envelope{Payload: []byte(payload)}
+}
+
+func assertPayloads(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("payloads = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("payloads = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestOutboundBuffer_Replay(t *testing.T) {
+	first := newFakeSession("s1")
+	b := newOutboundBuffer(first, &ResumeConfig{BufferSize: 4, BufferBytes: 1024})
+
+	for _, p := range []string{"a", "b", "c"} {
+		if err := b.send(context.Background(), newEnvelope(p)); err != nil {
+			t.Fatalf("send failed: %v", err)
+		}
+	}
+	assertPayloads(t, first.payloads(), "a", "b", "c")
+
+	// 断线期间只缓存
+	b.detach()
+	b.send(context.Background(), newEnvelope("d"))
+
+	// 客户端收到了 a，补发 b、c、d
+	second := newFakeSession("s2")
+	n, err := b.attach(second, 1)
+	if err != nil {
+		t.Fatalf("attach failed: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("replayed = %d, want 3", n)
+	}
+	b.send(context.Background(), newEnvelope("e"))
+	assertPayloads(t, second.payloads(), "b", "c", "d", "e")
+}
+
+func TestOutboundBuffer_Limits(t *testing.T) {
+	b := newOutboundBuffer(nil, &ResumeConfig{BufferSize: 3, BufferBytes: 4})
+
+	// 条数上限淘汰最旧的消息
+	for _, p := range []string{"a", "b", "c", "d"} {
+		b.send(context.Background(), newEnvelope(p))
+	}
+	if _, err := b.attach(newFakeSession("s"), 0); !errors.Is(err, ErrResumeBufferOverflow) {
+		t.Fatalf("attach beyond buffered range = %v, want overflow", err)
+	}
+	if _, err := b.attach(newFakeSession("s"), 5); !errors.Is(err, ErrResumeSeqInvalid) {
+		t.Fatalf("attach with future seq = %v, want invalid", err)
+	}
+
+	// 字节数上限淘汰最旧的消息
+	b.send(context.Background(), newEnvelope("wxyz"))
+	s := newFakeSession("s")
+	if _, err := b.attach(s, 3); !errors.Is(err, ErrResumeBufferOverflow) {
+		t.Fatalf("attach beyond byte limit = %v, want overflow", err)
+	}
+	if _, err := b.attach(s, 4); err != nil {
+		t.Fatalf("attach failed: %v", err)
+	}
+	assertPayloads(t, s.payloads(), "wxyz")
+}
+
+func TestOutboundBuffer_QueueFull(t *testing.T) {
+	conn := &queueSession{fakeSession: newFakeSession("s1"), capacity: 2}
+	b := newOutboundBuffer(conn, &ResumeConfig{BufferSize: 8, BufferBytes: 1024})
+
+	// 发送队列已满时关闭连接，消息保留在缓冲中，不丢失也不占用错位的 seq
+	for _, p := range []string{"a", "b", "c"} {
+		if err := b.send(context.Background(), newEnvelope(p)); err != nil {
+			t.Fatalf("send failed: %v", err)
+		}
+	}
+	if !conn.closed {
+		t.Fatalf("connection should be closed when the send queue is full")
+	}
+	b.send(context.Background(), newEnvelope("d"))
+	assertPayloads(t, conn.payloads(), "a", "b")
+
+	second := newFakeSession("s2")
+	if _, err := b.attach(second, 2); err != nil {
+		t.Fatalf("attach failed: %v", err)
+	}
+	assertPayloads(t, second.payloads(), "c", "d")
+}
+
+func TestOutboundBuffer_ControlNotCounted(t *testing.T) {
+	conn := newFakeSession("s1")
+	b := newOutboundBuffer(conn, &ResumeConfig{BufferSize: 8, BufferBytes: 1024})
+
+	kick := &common.Envelope{
+		Header:  &common.MessageHeader{Op: uint32(common.OpCode_OP_KICK_NOTICE)},
+		Payload: []byte("kick"),
+	}
+	b.send(context.Background(), newEnvelope("a"))
+	b.send(context.Background(), kick)
+	b.send(context.Background(), newEnvelope("b"))
+	assertPayloads(t, conn.payloads(), "a", "kick", "b")
+
+	// 踢下线通知走优先队列，不编号也不补发
+	if _, err := b.attach(newFakeSession("s"), 3); !errors.Is(err, ErrResumeSeqInvalid) {
+		t.Fatalf("attach with seq counting control message = %v, want invalid", err)
+	}
+	second := newFakeSession("s2")
+	if _, err := b.attach(second, 0); err != nil {
+		t.Fatalf("attach failed: %v", err)
+	}
+	assertPayloads(t, second.payloads(), "a", "b")
+}
+
+func TestManager_Resume(t *testing.T) {
+	m := NewManager(&ResumeConfig{Enabled: true, Window: time.Minute, BufferSize: 16, BufferBytes: 1024})
+
+	oldConn := newFakeSession("old")
+	old := m.Register(oldConn)
+	m.UpdateAuthState("old", 1001)
+	if err := m.UpdateRoleState("old", 42); err != nil {
+		t.Fatalf("update role failed: %v", err)
+	}
+	old.Send(context.Background(), newEnvelope("a"))
+
+	// 断线后推送的消息进入缓冲，角色索引仍然有效
+	m.Detach("old")
+	pushed, ok := m.GetByRoleID(42)
+	if !ok {
+		t.Fatalf("detached session should stay in the role index")
+	}
+	pushed.Send(context.Background(), newEnvelope("b"))
+
+	newConn := newFakeSession("new")
+	m.Register(newConn)
+	if _, err := m.Resume("old", "new", 2002, 1); !errors.Is(err, ErrResumeUIDMismatch) {
+		t.Fatalf("resume with another uid = %v, want mismatch", err)
+	}
+	if _, err := m.Resume("missing", "new", 1001, 1); !errors.Is(err, ErrResumeNotFound) {
+		t.Fatalf("resume unknown session = %v, want not found", err)
+	}
+
+	n, err := m.Resume("old", "new", 1001, 1)
+	if err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("replayed = %d, want 1", n)
+	}
+	assertPayloads(t, newConn.payloads(), "b")
+
+	gwSess, ok := m.GetByRoleID(42)
+	if !ok || gwSess.ID() != "new" || gwSess.GetUID() != 1001 {
+		t.Fatalf("role index should point to the resumed session")
+	}
+	if sessions := m.GetByUID(1001); len(sessions) != 1 {
+		t.Fatalf("uid index has %d sessions, want 1", len(sessions))
+	}
+
+	// 恢复后的消息沿用同一 seq 序列
+	gwSess.Send(context.Background(), newEnvelope("c"))
+	assertPayloads(t, newConn.payloads(), "b", "c")
+}
+
+func TestManager_ResumeLive(t *testing.T) {
+	m := NewManager(&ResumeConfig{Enabled: true})
+
+	oldConn := newFakeSession("old")
+	m.Register(oldConn)
+	m.UpdateAuthState("old", 1001)
+
+	// 服务端尚未发现旧连接断开时，新连接接管并关闭旧连接
+	m.Register(newFakeSession("new"))
+	if _, err := m.Resume("old", "new", 1001, 0); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if !oldConn.closed {
+		t.Fatalf("live session should be closed after takeover")
+	}
+	if _, ok := m.Get("old"); ok {
+		t.Fatalf("old session should be removed")
+	}
+}
+
+func TestManager_DetachExpire(t *testing.T) {
+	m := NewManager(&ResumeConfig{Enabled: true, Window: 10 * time.Millisecond})
+
+	m.Register(newFakeSession("s"))
+	m.UpdateAuthState("s", 1001)
+	m.Detach("s")
+	if len(m.GetByUID(1001)) != 1 {
+		t.Fatalf("detached session should stay in the uid index")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(m.GetByUID(1001)) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("detached session should expire after the resume window")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestManager_RoleListeners(t *testing.T) {
+	m := NewManager(&ResumeConfig{Enabled: true, Window: 10 * time.Millisecond})
+
+	var mu sync.Mutex
+	var events []string
+	record := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, fmt.Sprintf(format, args...))
+	}
+	m.OnRoleOnline(func(roleID, uid int64, sessionID string) {
+		record("online %d %d %s", roleID, uid, sessionID)
+	})
+	m.OnRoleOffline(func(roleID int64, sessionID string, reason OfflineReason) {
+		record("offline %d %s %d", roleID, sessionID, reason)
+	})
+
+	// 选择角色、切换角色
+	m.Register(newFakeSession("a"))
+	m.UpdateAuthState("a", 1001)
+	m.UpdateRoleState("a", 1)
+	m.UpdateRoleState("a", 2)
+
+	// 断线保留会话，恢复到新连接后以新会话 ID 重新上线
+	m.Detach("a")
+	m.Register(newFakeSession("b"))
+	if _, err := m.Resume("a", "b", 1001, 0); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+
+	// 再次断线，恢复窗口到期后下线
+	m.Detach("b")
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(events)
+		mu.Unlock()
+		if n == 5 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("events = %v, want resume expiry", events)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	assertPayloads(t, events,
+		"online 1 1001 a",
+		fmt.Sprintf("offline 1 a %d", OfflineSwitchRole),
+		"online 2 1001 a",
+		"online 2 1001 b",
+		fmt.Sprintf("offline 2 b %d", OfflineResumeExpired),
+	)
+}
@@ -23,6 +23,9 @@ type GatewaySession struct {
 	// 消息串行处理
 	taskCh chan *common.Envelope // 玩家私有的待转发消息队列
 	cancel context.CancelFunc    // 停止处理循环
+
+	// 下行消息缓冲（启用会话恢复时由 Manager 设置），重连恢复后转移到新会话
+	outbound *outboundBuffer
 }
 
 // NewGatewaySession 创建 Gateway Session
@@ -33,6 +36,18 @@ func NewGatewaySession(base session.Session) *GatewaySession {
 	}
 }
 
+// Send 发送下行消息，启用会话恢复时同时缓存以便重连后补发
+func (s *GatewaySession) Send(ctx context.Context, env *common.Envelope) error {
+	s.mu.RLock()
+	outbound := s.outbound
+	s.mu.RUnlock()
+
+	if outbound == nil {
+		return s.Session.Send(ctx, env)
+	}
+	return outbound.send(ctx, env)
+}
+
 // StartProcessor 启动该玩家的消息串行处理器
 func (s *GatewaySession) StartProcessor(ctx context.Context, handler func(*common.Envelope)) {
 	s.mu.Lock()
@@ -125,6 +140,23 @@ func (s *GatewaySession) IsRoleSelected() bool {
 	return s.roleSelected
 }
 
+// adopt 接管断线会话的认证状态与下行缓冲（会话恢复）
+func (s *GatewaySession) adopt(old *GatewaySession) {
+	old.mu.RLock()
+	uid, roleID := old.uid, old.roleID
+	authenticated, roleSelected := old.authenticated, old.roleSelected
+	outbound := old.outbound
+	old.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uid = uid
+	s.roleID = roleID
+	s.authenticated = authenticated
+	s.roleSelected = roleSelected
+	s.outbound = outbound
+}
+
 // Reset 重置认证状态（用于断线重连等场景）
 func (s *GatewaySession) Reset() {
 	s.mu.Lock()
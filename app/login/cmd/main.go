@@ -1,8 +1,13 @@
 package main
 
 import (
+	"github.com/lk2023060901/xdooria/app/login/internal/handler"
+	"github.com/lk2023060901/xdooria/app/login/internal/manager"
 	"github.com/lk2023060901/xdooria/app/login/internal/metrics"
+	"github.com/lk2023060901/xdooria/component/auth/oidc"
 	"github.com/lk2023060901/xdooria/pkg/app"
+	"github.com/lk2023060901/xdooria/pkg/database/postgres"
+	"github.com/lk2023060901/xdooria/pkg/database/redis"
 	"github.com/lk2023060901/xdooria/pkg/logger"
 	"github.com/lk2023060901/xdooria/pkg/network/framer"
 	"github.com/lk2023060901/xdooria/pkg/network/session"
@@ -25,6 +30,27 @@ type Config struct {
 	// 游戏配置表
 	GameConfig GameConfigConfig `mapstructure:"gameconfig"`
 
+	// Database 配置（本地账号）
+	Database postgres.Config `mapstructure:"database"`
+
+	// 本地账号配置
+	Account manager.AccountConfig `mapstructure:"account"`
+
+	// 平台身份绑定配置
+	Identity manager.IdentityConfig `mapstructure:"identity"`
+
+	// Redis 配置（登录排队）
+	Redis redis.Config `mapstructure:"redis"`
+
+	// 登录排队配置
+	Queue manager.QueueConfig `mapstructure:"queue"`
+
+	// OIDC/OAuth2 登录平台配置，每个平台一项
+	OIDC []oidc.Config `mapstructure:"oidc"`
+
+	// 运营后台配置
+	Admin handler.AdminConfig `mapstructure:"admin"`
+
 	// JWT 配置
 	JWT security.JWTConfig `mapstructure:"jwt"`
 
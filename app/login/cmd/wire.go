@@ -5,18 +5,26 @@ package main
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/wire"
 	"github.com/lk2023060901/xdooria/app/login/internal/dao"
+	"github.com/lk2023060901/xdooria/app/login/internal/gameconfig"
 	"github.com/lk2023060901/xdooria/app/login/internal/handler"
 	"github.com/lk2023060901/xdooria/app/login/internal/manager"
 	"github.com/lk2023060901/xdooria/app/login/internal/metrics"
+	"github.com/lk2023060901/xdooria/app/login/internal/repository"
 	"github.com/lk2023060901/xdooria/app/login/internal/service"
 	"github.com/lk2023060901/xdooria/component/auth"
+	"github.com/lk2023060901/xdooria/component/auth/oidc"
 	"github.com/lk2023060901/xdooria/pkg/app"
 	"github.com/lk2023060901/xdooria/pkg/balancer"
+	"github.com/lk2023060901/xdooria/pkg/database/postgres"
+	"github.com/lk2023060901/xdooria/pkg/database/redis"
+	"github.com/lk2023060901/xdooria/pkg/idgen"
 	"github.com/lk2023060901/xdooria/pkg/logger"
 	"github.com/lk2023060901/xdooria/pkg/network/framer"
+	"github.com/lk2023060901/xdooria/pkg/network/framer/seqid"
 	"github.com/lk2023060901/xdooria/pkg/network/session"
 	"github.com/lk2023060901/xdooria/pkg/network/tcp"
 	"github.com/lk2023060901/xdooria/pkg/prometheus"
@@ -24,6 +32,7 @@ import (
 	"github.com/lk2023060901/xdooria/pkg/registry/etcd"
 	"github.com/lk2023060901/xdooria/pkg/router"
 	"github.com/lk2023060901/xdooria/pkg/security"
+	"github.com/lk2023060901/xdooria/pkg/web"
 )
 
 func InitApp(cfg *Config, l logger.Logger) (app.Application, func(), error) {
@@ -35,7 +44,7 @@ func InitApp(cfg *Config, l logger.Logger) (app.Application, func(), error) {
 		router.ProviderSet,
 
 		// 3. Framer (安全信封)
-		wire.FieldsOf(new(*Config), "Framer"),
+		provideFramerConfig,
 		framer.New,
 
 		// 4. Session 配置
@@ -58,8 +67,32 @@ func InitApp(cfg *Config, l logger.Logger) (app.Application, func(), error) {
 		provideGameConfigConfig,
 		dao.NewConfigDAO,
 
+		// PostgreSQL 配置和客户端（本地账号）
+		providePostgresConfig,
+		postgres.New,
+		dao.NewAccountDAO,
+		repository.NewAccountRepository,
+		dao.NewIdentityDAO,
+		repository.NewIdentityRepository,
+
+		// Redis 配置和客户端（登录排队）
+		provideRedisConfig,
+		redis.NewClient,
+		dao.NewQueueDAO,
+		repository.NewQueueRepository,
+
 		// 10. 逻辑层 (Authenticator)
+		provideAccountConfig,
+		manager.NewAccountManager,
 		manager.NewLocalAuthenticator,
+		provideIdentityConfig,
+		provideIDGenerator,
+		manager.NewIdentityManager,
+		manager.NewGuestAuthenticator,
+		provideOIDCAuthenticators,
+		manager.NewGatewayManager,
+		provideQueueConfig,
+		manager.NewQueueManager,
 
 		// 11. 安全层 (JWT)
 		wire.FieldsOf(new(*Config), "JWT"),
@@ -70,6 +103,10 @@ func InitApp(cfg *Config, l logger.Logger) (app.Application, func(), error) {
 
 		// 13. 接口层 (Service)
 		service.NewLoginService,
+		service.NewAccountService,
+		service.NewIdentityService,
+		provideAdminConfig,
+		handler.NewAdminHandler,
 
 		// 13. Prometheus 客户端
 		providePrometheusConfig,
@@ -109,6 +146,54 @@ func provideMetricsConfig(cfg *Config) *metrics.Config {
 	return &cfg.Metrics
 }
 
+// providePostgresConfig 提供 PostgreSQL 配置
+func providePostgresConfig(cfg *Config) *postgres.Config {
+	return &cfg.Database
+}
+
+// provideAccountConfig 提供本地账号配置
+func provideAccountConfig(cfg *Config) *manager.AccountConfig {
+	return &cfg.Account
+}
+
+// provideIdentityConfig 提供平台身份绑定配置
+func provideIdentityConfig(cfg *Config) *manager.IdentityConfig {
+	return &cfg.Identity
+}
+
+// provideIDGenerator 提供内部 UID 生成器
+func provideIDGenerator(cfg *manager.IdentityConfig) (idgen.Generator, error) {
+	return idgen.NewSonyflake(cfg.MachineID)
+}
+
+// provideRedisConfig 提供 Redis 配置
+func provideRedisConfig(cfg *Config) *redis.Config {
+	return &cfg.Redis
+}
+
+// provideQueueConfig 提供登录排队配置
+func provideQueueConfig(cfg *Config) *manager.QueueConfig {
+	return &cfg.Queue
+}
+
+// provideOIDCAuthenticators 按配置创建 OIDC/OAuth2 登录平台认证器
+func provideOIDCAuthenticators(cfg *Config, l logger.Logger) ([]*oidc.Authenticator, error) {
+	authenticators := make([]*oidc.Authenticator, 0, len(cfg.OIDC))
+	for i := range cfg.OIDC {
+		a, err := oidc.New(l, &cfg.OIDC[i])
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, a)
+	}
+	return authenticators, nil
+}
+
+// provideAdminConfig 提供运营后台配置
+func provideAdminConfig(cfg *Config) *handler.AdminConfig {
+	return &cfg.Admin
+}
+
 // provideGameConfigConfig 提供游戏配置表加载配置
 func provideGameConfigConfig(cfg *Config) *dao.GameConfigConfig {
 	return &dao.GameConfigConfig{
@@ -126,10 +211,22 @@ func provideMetricsReporter(
 	return metrics.NewReporter(&cfg.Reporter, m, registrar, l)
 }
 
-// provideSessionConfig 提供 Session 配置（注入 Framer）
-func provideSessionConfig(cfg *Config, fr framer.Framer) *session.Config {
+// provideFramerConfig 提供 Framer 配置（防重放拒绝次数上报到 Prometheus）
+func provideFramerConfig(cfg *Config, promClient *prometheus.Client) *framer.Config {
+	fc := &cfg.Framer
+	if fc.SeqIdConfig == nil {
+		fc.SeqIdConfig = &seqid.Config{}
+	}
+	fc.SeqIdConfig.Observer = seqid.NewMetrics(promClient.Config().Namespace, promClient.Registry())
+	return fc
+}
+
+// provideSessionConfig 提供 Session 配置（注入 Framer，启用会话密钥协商时按会话包装；发送队列溢出次数上报到 Prometheus）
+func provideSessionConfig(cfg *Config, fr framer.Framer, promClient *prometheus.Client) *session.Config {
 	sessCfg := cfg.Session
 	sessCfg.Framer = fr
+	sessCfg.FramerConfig = &cfg.Framer
+	sessCfg.OverflowObserver = session.NewOverflowMetrics(promClient.Config().Namespace, promClient.Registry())
 	return &sessCfg
 }
 
@@ -163,8 +260,16 @@ func provideAppComponents(
 	baseApp *app.BaseApp,
 	sessServer *session.Server,
 	loginSvc *service.LoginService,
+	accountSvc *service.AccountService,
+	identitySvc *service.IdentityService,
 	authMgr *auth.Manager,
 	localAuth *manager.LocalAuthenticator,
+	guestAuth *manager.GuestAuthenticator,
+	oidcAuths []*oidc.Authenticator,
+	accountMgr *manager.AccountManager,
+	adminHandler *handler.AdminHandler,
+	postgresClient *postgres.Client,
+	redisClient *redis.Client,
 	r router.Router,
 	promClient *prometheus.Client,
 	loginMetrics *metrics.LoginMetrics,
@@ -177,9 +282,17 @@ func provideAppComponents(
 ) app.AppComponents {
 	// 注册认证器插件
 	authMgr.Register(localAuth)
+	if cfg.Identity.GuestEnabled {
+		authMgr.Register(guestAuth)
+	}
+	for _, a := range oidcAuths {
+		authMgr.Register(a)
+	}
 
 	// 初始化路由映射 (OpCode -> Handler)
 	loginSvc.Init(r)
+	accountSvc.Init(r)
+	identitySvc.Init(r)
 
 	// 注册 Login 指标到 Prometheus
 	_ = loginMetrics.Register(promClient.Registry())
@@ -195,20 +308,51 @@ func provideAppComponents(
 		logger:      baseApp.AppLogger(),
 	}
 
-	return app.AppComponents{
-		Servers: []app.Server{
-			sessServer, // Session Server 实现了 app.Server
-			serviceStarter,
+	servers := []app.Server{
+		// 先导入配置表账号，再开始接受登录
+		&accountImporter{
+			enabled:    cfg.Account.ImportConfigTable,
+			accountMgr: accountMgr,
+			logger:     baseApp.AppLogger(),
 		},
+		sessServer, // Session Server 实现了 app.Server
+		serviceStarter,
+	}
+
+	// 运营后台 HTTP 服务（重置密码、解除锁定）
+	if cfg.Admin.Enabled {
+		adminWeb := web.NewServer(&cfg.Admin.Web, baseApp.AppLogger())
+		adminHandler.Register(adminWeb.Router())
+		servers = append(servers, &adminServer{
+			server: adminWeb,
+			logger: baseApp.AppLogger(),
+		})
+	}
+
+	return app.AppComponents{
+		Servers: servers,
 		Closers: []app.Closer{
+			&loginServiceCloser{svc: loginSvc},
 			&metricsCloser{reporter: reporter},
 			promClient,
 			&registrarCloser{registrar: registrar},
 			resolver,
+			&postgresCloser{client: postgresClient},
+			redisClient,
 		},
 	}
 }
 
+// loginServiceCloser 登录服务关闭器（停止网关监听与排队放行循环）
+type loginServiceCloser struct {
+	svc *service.LoginService
+}
+
+func (c *loginServiceCloser) Close() error {
+	c.svc.Close()
+	return nil
+}
+
 // metricsCloser 指标上报器关闭器
 type metricsCloser struct {
 	reporter *metrics.Reporter
@@ -228,6 +372,73 @@ func (c *registrarCloser) Close() error {
 	return c.registrar.Deregister(context.Background())
 }
 
+// postgresCloser PostgreSQL 客户端关闭器
+type postgresCloser struct {
+	client *postgres.Client
+}
+
+func (c *postgresCloser) Close() error {
+	c.client.Close()
+	return nil
+}
+
+// accountImporter 启动时导入 TbAccount 配置表中的账号，实现 app.Server 接口
+type accountImporter struct {
+	enabled    bool
+	accountMgr *manager.AccountManager
+	logger     logger.Logger
+}
+
+func (s *accountImporter) Start() error {
+	if !s.enabled {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if _, err := s.accountMgr.ImportConfigAccounts(ctx, gameconfig.T.TbAccount.GetDataList()); err != nil {
+		s.logger.Error("failed to import config accounts", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (s *accountImporter) Stop() error {
+	return nil
+}
+
+// adminServer 运营后台 HTTP 服务启动器，实现 app.Server 接口
+type adminServer struct {
+	server *web.Server
+	logger logger.Logger
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (s *adminServer) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		if err := s.server.Run(ctx); err != nil {
+			s.logger.Error("admin server exited with error", "error", err)
+		}
+	}()
+	return nil
+}
+
+func (s *adminServer) Stop() error {
+	if s.cancel == nil {
+		return nil
+	}
+	// 等待 HTTP 服务优雅关闭
+	s.cancel()
+	<-s.done
+	return nil
+}
+
 // serviceRegistrar 服务注册启动器，实现 app.Server 接口
 type serviceRegistrar struct {
 	registrar   *etcd.Registrar
@@ -254,7 +465,7 @@ func (s *serviceRegistrar) Stop() error {
 	return nil // Deregister 由 registrarCloser 处理
 }
 
-// provideBalancer 提供负载均衡器
+// provideBalancer 提供负载均衡器（按网关上报的连接数与 CPU 使用率选择）
 func provideBalancer() balancer.Balancer {
-	return balancer.New(balancer.RoundRobinName)
+	return balancer.New(balancer.LeastLoadName)
 }
@@ -0,0 +1,294 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/lk2023060901/xdooria/app/login/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/database/postgres"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// accountColumns accounts 表的查询列，顺序与 scanAccount 一致
+var accountColumns = []string{
+	"id", "username", "password_hash", "status",
+	"failed_attempts", "locked_until",
+	"password_changed_at", "last_login_at", "created_at", "updated_at",
+}
+
+// AccountDAO 账号数据访问对象
+type AccountDAO struct {
+	db     *postgres.Client
+	logger logger.Logger
+}
+
+// NewAccountDAO 创建账号 DAO
+func NewAccountDAO(db *postgres.Client, l logger.Logger) *AccountDAO {
+	return &AccountDAO{
+		db:     db,
+		logger: l.Named("dao.account"),
+	}
+}
+
+// GetByUsername 根据用户名获取账号，不存在时返回 nil
+func (d *AccountDAO) GetByUsername(ctx context.Context, username string) (*model.Account, error) {
+	query, args, err := squirrel.
+		Select(accountColumns...).
+		From("accounts").
+		Where(squirrel.Eq{"username": username}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	acc, err := scanAccount(d.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if isNoRows(err) {
+			return nil, nil
+		}
+		d.logger.Error("failed to get account by username",
+			"username", username,
+			"error", err,
+		)
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+
+	return acc, nil
+}
+
+// Create 创建账号，用户名已存在时返回 false
+func (d *AccountDAO) Create(ctx context.Context, acc *model.Account) (bool, error) {
+	query, args, err := squirrel.
+		Insert("accounts").
+		Columns("username", "password_hash", "status").
+		Values(acc.Username, acc.PasswordHash, acc.Status).
+		Suffix("ON CONFLICT (username) DO NOTHING RETURNING id, password_changed_at, created_at, updated_at").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return false, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if err := d.db.QueryRow(ctx, query, args...).Scan(
+		&acc.ID,
+		&acc.PasswordChangedAt,
+		&acc.CreatedAt,
+		&acc.UpdatedAt,
+	); err != nil {
+		if isNoRows(err) {
+			return false, nil
+		}
+		d.logger.Error("failed to create account",
+			"username", acc.Username,
+			"error", err,
+		)
+		return false, fmt.Errorf("failed to create account: %w", err)
+	}
+
+	d.logger.Info("account created",
+		"account_id", acc.ID,
+		"username", acc.Username,
+	)
+
+	return true, nil
+}
+
+// UpdatePassword 更新密码哈希，同时清除登录失败次数与锁定
+func (d *AccountDAO) UpdatePassword(ctx context.Context, accountID int64, passwordHash string, now time.Time) error {
+	query, args, err := squirrel.
+		Update("accounts").
+		Set("password_hash", passwordHash).
+		Set("password_changed_at", now).
+		Set("failed_attempts", 0).
+		Set("locked_until", nil).
+		Where(squirrel.Eq{"id": accountID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := d.db.Exec(ctx, query, args...); err != nil {
+		d.logger.Error("failed to update password",
+			"account_id", accountID,
+			"error", err,
+		)
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return nil
+}
+
+// ReserveLoginAttempt 校验密码前预占一次登录尝试：累加失败次数，达到 maxAttempts 时同时锁定到 lockUntil
+// 锁定已到期的账号从 1 重新计数；账号锁定中时不做更新并返回 nil
+// 判断与累加在一条语句内完成，并发登录在一个锁定周期内最多 maxAttempts 次进入密码校验
+func (d *AccountDAO) ReserveLoginAttempt(ctx context.Context, accountID int64, maxAttempts int32, now, lockUntil time.Time) (*model.Account, error) {
+	const attempts = "CASE WHEN locked_until IS NULL THEN failed_attempts + 1 ELSE 1 END"
+
+	query, args, err := squirrel.
+		Update("accounts").
+		Set("failed_attempts", squirrel.Expr(attempts)).
+		Set("locked_until", squirrel.Expr("CASE WHEN "+attempts+" >= ? THEN ?::timestamptz ELSE NULL END", maxAttempts, lockUntil)).
+		Where(squirrel.Eq{"id": accountID}).
+		Where(squirrel.Or{squirrel.Eq{"locked_until": nil}, squirrel.LtOrEq{"locked_until": now}}).
+		Suffix("RETURNING failed_attempts, locked_until").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	acc := &model.Account{ID: accountID}
+	if err := d.db.QueryRow(ctx, query, args...).Scan(&acc.FailedAttempts, &acc.LockedUntil); err != nil {
+		if isNoRows(err) {
+			return nil, nil
+		}
+		d.logger.Error("failed to reserve login attempt",
+			"account_id", accountID,
+			"error", err,
+		)
+		return nil, fmt.Errorf("failed to reserve login attempt: %w", err)
+	}
+
+	return acc, nil
+}
+
+// RecordLoginSuccess 登录成功：清除登录失败次数与锁定，更新最后登录时间
+func (d *AccountDAO) RecordLoginSuccess(ctx context.Context, accountID int64, now time.Time) error {
+	query, args, err := squirrel.
+		Update("accounts").
+		Set("failed_attempts", 0).
+		Set("locked_until", nil).
+		Set("last_login_at", now).
+		Where(squirrel.Eq{"id": accountID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := d.db.Exec(ctx, query, args...); err != nil {
+		d.logger.Error("failed to record login success",
+			"account_id", accountID,
+			"error", err,
+		)
+		return fmt.Errorf("failed to record login success: %w", err)
+	}
+
+	return nil
+}
+
+// Unlock 解除账号锁定
+func (d *AccountDAO) Unlock(ctx context.Context, accountID int64) error {
+	query, args, err := squirrel.
+		Update("accounts").
+		Set("failed_attempts", 0).
+		Set("locked_until", nil).
+		Where(squirrel.Eq{"id": accountID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := d.db.Exec(ctx, query, args...); err != nil {
+		d.logger.Error("failed to unlock account",
+			"account_id", accountID,
+			"error", err,
+		)
+		return fmt.Errorf("failed to unlock account: %w", err)
+	}
+
+	return nil
+}
+
+// importBatchSize 导入时每条 INSERT 的账号数，4 列 × 1000 行远低于 PostgreSQL 单条语句 65535 个绑定参数的上限
+const importBatchSize = 1000
+
+// Import 按原有 ID 导入账号，ID 或用户名已存在的账号跳过，返回实际导入的数量
+// 在一个事务内分批插入，全部插入后将 ID 序列推进到当前最大 ID，之后注册的账号不会与导入的 ID 冲突
+func (d *AccountDAO) Import(ctx context.Context, accounts []*model.Account) (int64, error) {
+	if len(accounts) == 0 {
+		return 0, nil
+	}
+
+	var imported int64
+	err := d.db.WithTx(ctx, func(tx postgres.Tx) error {
+		for batch := range slices.Chunk(accounts, importBatchSize) {
+			builder := squirrel.
+				Insert("accounts").
+				Columns("id", "username", "password_hash", "status").
+				Suffix("ON CONFLICT DO NOTHING").
+				PlaceholderFormat(squirrel.Dollar)
+
+			for _, acc := range batch {
+				builder = builder.Values(acc.ID, acc.Username, acc.PasswordHash, acc.Status)
+			}
+
+			query, args, err := builder.ToSql()
+			if err != nil {
+				return fmt.Errorf("failed to build query: %w", err)
+			}
+
+			n, err := tx.Exec(ctx, query, args...)
+			if err != nil {
+				return fmt.Errorf("failed to import accounts: %w", err)
+			}
+			imported += n
+		}
+
+		if _, err := tx.Exec(ctx,
+			"SELECT setval(pg_get_serial_sequence('accounts', 'id'), GREATEST((SELECT MAX(id) FROM accounts), 1))",
+		); err != nil {
+			return fmt.Errorf("failed to advance account id sequence: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		d.logger.Error("failed to import accounts",
+			"count", len(accounts),
+			"error", err,
+		)
+		return 0, err
+	}
+
+	return imported, nil
+}
+
+// scanAccount 扫描一行账号数据，列顺序与 accountColumns 一致
+func scanAccount(row pgx.Row) (*model.Account, error) {
+	var acc model.Account
+	if err := row.Scan(
+		&acc.ID,
+		&acc.Username,
+		&acc.PasswordHash,
+		&acc.Status,
+		&acc.FailedAttempts,
+		&acc.LockedUntil,
+		&acc.PasswordChangedAt,
+		&acc.LastLoginAt,
+		&acc.CreatedAt,
+		&acc.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+// isNoRows 判断是否为未查询到记录
+// QueryRow().Scan() 返回的是 pgx.ErrNoRows，这里同时兼容 postgres.ErrNoRows
+func isNoRows(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows) || errors.Is(err, postgres.ErrNoRows)
+}
@@ -0,0 +1,314 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/lk2023060901/xdooria/app/login/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/database/postgres"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// identityColumns user_identities 表的查询列，顺序与 scanIdentity 一致
+var identityColumns = []string{
+	"login_type", "platform_uid", "uid",
+	"nickname", "avatar_url",
+	"bound_at", "last_login_at",
+}
+
+// errIdentityConflict 事务内检测到绑定冲突，用于回滚事务，不对外返回
+var errIdentityConflict = errors.New("identity conflict")
+
+// IdentityDAO 平台身份绑定数据访问对象
+type IdentityDAO struct {
+	db     *postgres.Client
+	logger logger.Logger
+}
+
+// NewIdentityDAO 创建平台身份绑定 DAO
+func NewIdentityDAO(db *postgres.Client, l logger.Logger) *IdentityDAO {
+	return &IdentityDAO{
+		db:     db,
+		logger: l.Named("dao.identity"),
+	}
+}
+
+// Get 根据平台身份获取绑定，不存在时返回 nil
+func (d *IdentityDAO) Get(ctx context.Context, loginType int32, platformUID string) (*model.UserIdentity, error) {
+	query, args, err := squirrel.
+		Select(identityColumns...).
+		From("user_identities").
+		Where(squirrel.Eq{"login_type": loginType, "platform_uid": platformUID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	ident, err := scanIdentity(d.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if isNoRows(err) {
+			return nil, nil
+		}
+		d.logger.Error("failed to get identity",
+			"login_type", loginType,
+			"platform_uid", platformUID,
+			"error", err,
+		)
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	return ident, nil
+}
+
+// ListByUID 获取 UID 绑定的所有平台身份，按绑定时间排序
+func (d *IdentityDAO) ListByUID(ctx context.Context, uid int64) ([]*model.UserIdentity, error) {
+	query, args, err := squirrel.
+		Select(identityColumns...).
+		From("user_identities").
+		Where(squirrel.Eq{"uid": uid}).
+		OrderBy("bound_at", "login_type").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := d.db.Query(ctx, query, args...)
+	if err != nil {
+		d.logger.Error("failed to list identities",
+			"uid", uid,
+			"error", err,
+		)
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	defer rows.Close()
+
+	var identities []*model.UserIdentity
+	for rows.Next() {
+		ident, err := scanIdentity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan identity: %w", err)
+		}
+		identities = append(identities, ident)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+
+	return identities, nil
+}
+
+// Create 创建绑定；平台身份已被绑定，或该 UID 已绑定同类型的平台身份时返回 false
+func (d *IdentityDAO) Create(ctx context.Context, ident *model.UserIdentity) (bool, error) {
+	query, args, err := insertIdentity(ident)
+	if err != nil {
+		return false, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if err := d.db.QueryRow(ctx, query, args...).Scan(&ident.BoundAt); err != nil {
+		if isNoRows(err) {
+			return false, nil
+		}
+		d.logger.Error("failed to create identity",
+			"login_type", ident.LoginType,
+			"platform_uid", ident.PlatformUID,
+			"uid", ident.UID,
+			"error", err,
+		)
+		return false, fmt.Errorf("failed to create identity: %w", err)
+	}
+
+	d.logger.Info("identity bound",
+		"login_type", ident.LoginType,
+		"platform_uid", ident.PlatformUID,
+		"uid", ident.UID,
+	)
+
+	return true, nil
+}
+
+// RecordLogin 登录成功：更新平台资料与最后登录时间
+func (d *IdentityDAO) RecordLogin(ctx context.Context, loginType int32, platformUID, nickname, avatarURL string, now time.Time) error {
+	query, args, err := squirrel.
+		Update("user_identities").
+		Set("nickname", nickname).
+		Set("avatar_url", avatarURL).
+		Set("last_login_at", now).
+		Where(squirrel.Eq{"login_type": loginType, "platform_uid": platformUID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := d.db.Exec(ctx, query, args...); err != nil {
+		d.logger.Error("failed to record identity login",
+			"login_type", loginType,
+			"platform_uid", platformUID,
+			"error", err,
+		)
+		return fmt.Errorf("failed to record identity login: %w", err)
+	}
+
+	return nil
+}
+
+// Delete 解除 UID 在 loginType 上的绑定
+// 返回 deleted=false 表示未绑定该类型，last=true 表示这是 UID 唯一的平台身份而拒绝解除
+// 事务内按 UID 加锁，并发解绑不会把 UID 的平台身份全部解除
+func (d *IdentityDAO) Delete(ctx context.Context, uid int64, loginType int32) (deleted, last bool, err error) {
+	err = d.db.WithTx(ctx, func(tx postgres.Tx) error {
+		if err := lockUID(ctx, tx, uid); err != nil {
+			return err
+		}
+
+		var total, matched int64
+		if err := tx.QueryRow(ctx,
+			"SELECT COUNT(*), COUNT(*) FILTER (WHERE login_type = $2) FROM user_identities WHERE uid = $1",
+			uid, loginType,
+		).Scan(&total, &matched); err != nil {
+			return fmt.Errorf("failed to count identities: %w", err)
+		}
+		if matched == 0 {
+			return nil
+		}
+		if total <= 1 {
+			last = true
+			return nil
+		}
+
+		query, args, err := squirrel.
+			Delete("user_identities").
+			Where(squirrel.Eq{"uid": uid, "login_type": loginType}).
+			PlaceholderFormat(squirrel.Dollar).
+			ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+		if _, err := tx.Exec(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to delete identity: %w", err)
+		}
+		deleted = true
+		return nil
+	})
+	if err != nil {
+		d.logger.Error("failed to unbind identity",
+			"uid", uid,
+			"login_type", loginType,
+			"error", err,
+		)
+		return false, false, err
+	}
+
+	if deleted {
+		d.logger.Info("identity unbound",
+			"uid", uid,
+			"login_type", loginType,
+		)
+	}
+
+	return deleted, last, nil
+}
+
+// Replace 在一个事务内解除 UID 在 fromType 上的绑定并绑定新的平台身份（游客升级）
+// UID 未绑定 fromType，或新的平台身份无法绑定时不做任何修改并返回 false
+func (d *IdentityDAO) Replace(ctx context.Context, fromType int32, ident *model.UserIdentity) (bool, error) {
+	err := d.db.WithTx(ctx, func(tx postgres.Tx) error {
+		if err := lockUID(ctx, tx, ident.UID); err != nil {
+			return err
+		}
+
+		query, args, err := squirrel.
+			Delete("user_identities").
+			Where(squirrel.Eq{"uid": ident.UID, "login_type": fromType}).
+			PlaceholderFormat(squirrel.Dollar).
+			ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+		removed, err := tx.Exec(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to delete identity: %w", err)
+		}
+		if removed == 0 {
+			return errIdentityConflict
+		}
+
+		query, args, err = insertIdentity(ident)
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+		if err := tx.QueryRow(ctx, query, args...).Scan(&ident.BoundAt); err != nil {
+			if isNoRows(err) {
+				return errIdentityConflict
+			}
+			return fmt.Errorf("failed to create identity: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, errIdentityConflict) {
+		return false, nil
+	}
+	if err != nil {
+		d.logger.Error("failed to replace identity",
+			"uid", ident.UID,
+			"from_login_type", fromType,
+			"login_type", ident.LoginType,
+			"error", err,
+		)
+		return false, err
+	}
+
+	d.logger.Info("identity replaced",
+		"uid", ident.UID,
+		"from_login_type", fromType,
+		"login_type", ident.LoginType,
+		"platform_uid", ident.PlatformUID,
+	)
+
+	return true, nil
+}
+
+// insertIdentity 构建插入绑定的语句，任一唯一约束冲突时不插入
+func insertIdentity(ident *model.UserIdentity) (string, []any, error) {
+	return squirrel.
+		Insert("user_identities").
+		Columns("login_type", "platform_uid", "uid", "nickname", "avatar_url", "last_login_at").
+		Values(ident.LoginType, ident.PlatformUID, ident.UID, ident.Nickname, ident.AvatarURL, ident.LastLoginAt).
+		Suffix("ON CONFLICT DO NOTHING RETURNING bound_at").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
+
+// lockUID 事务级咨询锁，串行化同一 UID 的解绑与升级
+func lockUID(ctx context.Context, tx postgres.Tx, uid int64) error {
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", uid); err != nil {
+		return fmt.Errorf("failed to lock uid %d: %w", uid, err)
+	}
+	return nil
+}
+
+// scanIdentity 扫描一行绑定数据，列顺序与 identityColumns 一致
+func scanIdentity(row pgx.Row) (*model.UserIdentity, error) {
+	var ident model.UserIdentity
+	if err := row.Scan(
+		&ident.LoginType,
+		&ident.PlatformUID,
+		&ident.UID,
+		&ident.Nickname,
+		&ident.AvatarURL,
+		&ident.BoundAt,
+		&ident.LastLoginAt,
+	); err != nil {
+		return nil, err
+	}
+	return &ident, nil
+}
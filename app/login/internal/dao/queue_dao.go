@@ -0,0 +1,393 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/login/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/database/redis"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+const (
+	// queuePriorityKey 排队通道指定（uid -> 通道名），由运营后台维护，所有区服共用
+	queuePriorityKey = "login:queue:priority"
+
+	// queueKeyTTL 票据的保底过期时间，正常情况下票据由放行、取消或放弃清理删除
+	queueKeyTTL = 24 * time.Hour
+)
+
+// queueLuaCommon 各脚本共用的函数，KEYS[1] 为区服 key 前缀
+// 区服的所有 key 使用同一个 hash tag，集群模式下位于同一个 slot
+//
+//	lane:<i>     ZSET 排队通道，ticket -> 入队序号
+//	seq          入队序号计数器
+//	ticket:<t>   HASH 票据（uid、nickname、lane、status）
+//	uid:<uid>    STRING uid 当前的票据，重复登录时沿用
+//	alive        ZSET ticket -> 续期截止时间（毫秒），超时视为放弃排队
+//	admitted     ZSET uid -> 放行时间（毫秒），宽限期内计入在线人数
+//	total        累计放行人数，用于估算等待时间
+const queueLuaCommon = `
+local P = KEYS[1]
+
+local function lane_key(i) return P .. 'lane:' .. i end
+local function ticket_key(t) return P .. 'ticket:' .. t end
+
+local function remove_ticket(t, lanes)
+	local key = ticket_key(t)
+	local uid = redis.call('HGET', key, 'uid')
+	redis.call('DEL', key)
+	if uid and redis.call('GET', P .. 'uid:' .. uid) == t then
+		redis.call('DEL', P .. 'uid:' .. uid)
+	end
+	redis.call('ZREM', P .. 'alive', t)
+	for i = 0, lanes - 1 do
+		redis.call('ZREM', lane_key(i), t)
+	end
+end
+
+local function cleanup(lanes, now, grace)
+	local expired = redis.call('ZRANGEBYSCORE', P .. 'alive', '-inf', now, 'LIMIT', 0, 1000)
+	for _, t in ipairs(expired) do
+		remove_ticket(t, lanes)
+	end
+	redis.call('ZREMRANGEBYSCORE', P .. 'admitted', '-inf', now - grace)
+end
+
+local function waiting(lanes)
+	local n = 0
+	for i = 0, lanes - 1 do
+		n = n + redis.call('ZCARD', lane_key(i))
+	end
+	return n
+end
+
+-- {ticket, uid, nickname, lane, admitted, position, length}，票据不存在时返回空表
+local function status(t, lanes)
+	local h = redis.call('HMGET', ticket_key(t), 'uid', 'nickname', 'lane', 'status')
+	if not h[1] then
+		return {}
+	end
+	local lane = tonumber(h[3])
+	local length = waiting(lanes)
+	if h[4] == 'admitted' then
+		return {t, h[1], h[2], lane, 1, 0, length}
+	end
+	local rank = redis.call('ZRANK', lane_key(lane), t)
+	if not rank then
+		return {}
+	end
+	local pos = rank + 1
+	for i = 0, lane - 1 do
+		pos = pos + redis.call('ZCARD', lane_key(i))
+	end
+	return {t, h[1], h[2], lane, 0, pos, length}
+end
+`
+
+// queueEnterScript 入队：已有票据时沿用并续期；无人排队且有空位时直接放行；否则按通道入队
+const queueEnterScript = queueLuaCommon + `
+local uid, nickname, lane, lanes = ARGV[1], ARGV[2], tonumber(ARGV[3]), tonumber(ARGV[4])
+local now, grace, abandon = tonumber(ARGV[5]), tonumber(ARGV[6]), tonumber(ARGV[7])
+local max_online, online = tonumber(ARGV[8]), tonumber(ARGV[9])
+local ticket, key_ttl = ARGV[10], tonumber(ARGV[11])
+
+cleanup(lanes, now, grace)
+
+local existing = redis.call('GET', P .. 'uid:' .. uid)
+if existing then
+	local s = status(existing, lanes)
+	if #s > 0 then
+		redis.call('ZADD', P .. 'alive', now + abandon, existing)
+		return s
+	end
+end
+
+if waiting(lanes) == 0 and max_online - online - redis.call('ZCARD', P .. 'admitted') > 0 then
+	redis.call('ZADD', P .. 'admitted', now, uid)
+	redis.call('INCR', P .. 'total')
+	return {'', uid, nickname, lane, 1, 0, 0}
+end
+
+local key = ticket_key(ticket)
+redis.call('HSET', key, 'uid', uid, 'nickname', nickname, 'lane', lane, 'status', 'waiting')
+redis.call('PEXPIRE', key, key_ttl)
+redis.call('SET', P .. 'uid:' .. uid, ticket, 'PX', key_ttl)
+redis.call('ZADD', lane_key(lane), redis.call('INCR', P .. 'seq'), ticket)
+redis.call('ZADD', P .. 'alive', now + abandon, ticket)
+return status(ticket, lanes)
+`
+
+// queueAdmitScript 放行：清理放弃的票据，按通道优先级从队首放行到在线上限
+// 返回 {本次放行人数, 累计放行人数, 剩余排队人数}
+const queueAdmitScript = queueLuaCommon + `
+local lanes, now, grace = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local max_online, online, max_admit = tonumber(ARGV[4]), tonumber(ARGV[5]), tonumber(ARGV[6])
+
+cleanup(lanes, now, grace)
+
+local free = max_online - online - redis.call('ZCARD', P .. 'admitted')
+if free > max_admit then
+	free = max_admit
+end
+
+local admitted = 0
+for i = 0, lanes - 1 do
+	while free > 0 do
+		local popped = redis.call('ZPOPMIN', lane_key(i), free)
+		if #popped == 0 then
+			break
+		end
+		for j = 1, #popped, 2 do
+			local key = ticket_key(popped[j])
+			local uid = redis.call('HGET', key, 'uid')
+			if uid then
+				redis.call('HSET', key, 'status', 'admitted')
+				redis.call('ZADD', P .. 'admitted', now, uid)
+				admitted = admitted + 1
+				free = free - 1
+			end
+		end
+	end
+end
+
+local total = redis.call('INCRBY', P .. 'total', admitted)
+return {admitted, total, waiting(lanes)}
+`
+
+// queueStatusScript 批量查询票据状态，keepalive=1 时为仍存在的票据续期
+const queueStatusScript = queueLuaCommon + `
+local lanes, now, abandon, keepalive = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+
+local result = {}
+for i = 5, #ARGV do
+	local t = ARGV[i]
+	local s = status(t, lanes)
+	if #s > 0 and keepalive == 1 then
+		redis.call('ZADD', P .. 'alive', 'XX', now + abandon, t)
+	end
+	result[#result + 1] = s
+end
+return result
+`
+
+// queueRemoveScript 删除票据（已下发 Token 或主动取消），返回票据是否存在
+const queueRemoveScript = queueLuaCommon + `
+local lanes, t = tonumber(ARGV[1]), ARGV[2]
+local existed = redis.call('EXISTS', ticket_key(t))
+remove_ticket(t, lanes)
+return existed
+`
+
+// errInvalidQueueResult 脚本返回值格式不符
+var errInvalidQueueResult = errors.New("invalid queue script result")
+
+// QueueDAO 登录排队数据访问对象（Redis）
+type QueueDAO struct {
+	redis  *redis.Client
+	logger logger.Logger
+}
+
+// NewQueueDAO 创建登录排队 DAO
+func NewQueueDAO(rdb *redis.Client, l logger.Logger) *QueueDAO {
+	return &QueueDAO{
+		redis:  rdb,
+		logger: l.Named("dao.queue"),
+	}
+}
+
+// Enter 入队，返回实际生效的票据：
+// uid 已在排队时沿用原票据；无人排队且有空位时直接放行（Ticket 为空）；否则以 t.Ticket 入队
+func (d *QueueDAO) Enter(ctx context.Context, t *model.QueueTicket, limit *model.QueueLimit) (*model.QueueTicket, error) {
+	res, err := d.redis.Eval(ctx, queueEnterScript, []string{queueKeyPrefix(t.ZoneID)},
+		t.UID,
+		t.Nickname,
+		t.Lane,
+		limit.Lanes,
+		limit.Now.UnixMilli(),
+		limit.Grace.Milliseconds(),
+		limit.AbandonTimeout.Milliseconds(),
+		limit.MaxOnline,
+		limit.Online,
+		t.Ticket,
+		queueKeyTTL.Milliseconds(),
+	).Result()
+	if err != nil {
+		d.logger.Error("failed to enter queue",
+			"zone_id", t.ZoneID,
+			"uid", t.UID,
+			"error", err,
+		)
+		return nil, fmt.Errorf("failed to enter queue: %w", err)
+	}
+
+	entered, err := parseQueueTicket(t.ZoneID, res)
+	if err != nil {
+		return nil, err
+	}
+	if entered == nil {
+		return nil, fmt.Errorf("failed to enter queue: %w", errInvalidQueueResult)
+	}
+	return entered, nil
+}
+
+// Admit 按在线上限放行区服队首的票据，返回本次放行人数、累计放行人数与剩余排队人数
+func (d *QueueDAO) Admit(ctx context.Context, zoneID int32, limit *model.QueueLimit) (admitted int, total, waiting int64, err error) {
+	res, err := d.redis.Eval(ctx, queueAdmitScript, []string{queueKeyPrefix(zoneID)},
+		limit.Lanes,
+		limit.Now.UnixMilli(),
+		limit.Grace.Milliseconds(),
+		limit.MaxOnline,
+		limit.Online,
+		limit.MaxAdmit,
+	).Result()
+	if err != nil {
+		d.logger.Error("failed to admit queue",
+			"zone_id", zoneID,
+			"error", err,
+		)
+		return 0, 0, 0, fmt.Errorf("failed to admit queue: %w", err)
+	}
+
+	values, ok := res.([]any)
+	if !ok || len(values) != 3 {
+		return 0, 0, 0, fmt.Errorf("failed to admit queue: %w", errInvalidQueueResult)
+	}
+	n, _ := values[0].(int64)
+	total, _ = values[1].(int64)
+	waiting, _ = values[2].(int64)
+	return int(n), total, waiting, nil
+}
+
+// Status 批量查询票据状态，结果与 tickets 一一对应，票据不存在时为 nil；
+// keepalive 为 true 时为仍存在的票据续期 abandonTimeout
+func (d *QueueDAO) Status(ctx context.Context, zoneID int32, tickets []string, lanes int, keepalive bool, abandonTimeout time.Duration, now time.Time) ([]*model.QueueTicket, error) {
+	if len(tickets) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, 0, len(tickets)+4)
+	args = append(args, lanes, now.UnixMilli(), abandonTimeout.Milliseconds(), boolToInt(keepalive))
+	for _, t := range tickets {
+		args = append(args, t)
+	}
+
+	res, err := d.redis.Eval(ctx, queueStatusScript, []string{queueKeyPrefix(zoneID)}, args...).Result()
+	if err != nil {
+		d.logger.Error("failed to get queue status",
+			"zone_id", zoneID,
+			"tickets", len(tickets),
+			"error", err,
+		)
+		return nil, fmt.Errorf("failed to get queue status: %w", err)
+	}
+
+	values, ok := res.([]any)
+	if !ok || len(values) != len(tickets) {
+		return nil, fmt.Errorf("failed to get queue status: %w", errInvalidQueueResult)
+	}
+	result := make([]*model.QueueTicket, len(values))
+	for i, v := range values {
+		if result[i], err = parseQueueTicket(zoneID, v); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Remove 删除票据，返回票据是否存在
+func (d *QueueDAO) Remove(ctx context.Context, zoneID int32, ticket string, lanes int) (bool, error) {
+	res, err := d.redis.Eval(ctx, queueRemoveScript, []string{queueKeyPrefix(zoneID)}, lanes, ticket).Result()
+	if err != nil {
+		d.logger.Error("failed to remove queue ticket",
+			"zone_id", zoneID,
+			"ticket", ticket,
+			"error", err,
+		)
+		return false, fmt.Errorf("failed to remove queue ticket: %w", err)
+	}
+
+	existed, _ := res.(int64)
+	return existed == 1, nil
+}
+
+// GetPriority 获取 uid 指定的排队通道，未指定时返回空串
+func (d *QueueDAO) GetPriority(ctx context.Context, uid int64) (string, error) {
+	lane, err := d.redis.HGet(ctx, queuePriorityKey, strconv.FormatInt(uid, 10))
+	if err != nil {
+		if errors.Is(err, redis.ErrNil) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get queue priority: %w", err)
+	}
+	return lane, nil
+}
+
+// SetPriority 指定 uid 的排队通道
+func (d *QueueDAO) SetPriority(ctx context.Context, uid int64, lane string) error {
+	if _, err := d.redis.HSet(ctx, queuePriorityKey, strconv.FormatInt(uid, 10), lane); err != nil {
+		return fmt.Errorf("failed to set queue priority: %w", err)
+	}
+	return nil
+}
+
+// DeletePriority 取消 uid 的通道指定，恢复默认通道
+func (d *QueueDAO) DeletePriority(ctx context.Context, uid int64) error {
+	if _, err := d.redis.HDel(ctx, queuePriorityKey, strconv.FormatInt(uid, 10)); err != nil {
+		return fmt.Errorf("failed to delete queue priority: %w", err)
+	}
+	return nil
+}
+
+// queueKeyPrefix 区服排队 key 前缀，hash tag 保证集群模式下位于同一个 slot
+func queueKeyPrefix(zoneID int32) string {
+	return fmt.Sprintf("login:queue:{%d}:", zoneID)
+}
+
+// parseQueueTicket 解析脚本返回的票据状态，空表表示票据不存在
+func parseQueueTicket(zoneID int32, v any) (*model.QueueTicket, error) {
+	fields, ok := v.([]any)
+	if !ok {
+		return nil, errInvalidQueueResult
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	if len(fields) != 7 {
+		return nil, errInvalidQueueResult
+	}
+
+	ticket, _ := fields[0].(string)
+	uidStr, _ := fields[1].(string)
+	nickname, _ := fields[2].(string)
+	lane, _ := fields[3].(int64)
+	admitted, _ := fields[4].(int64)
+	position, _ := fields[5].(int64)
+	length, _ := fields[6].(int64)
+
+	uid, err := strconv.ParseInt(uidStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: uid %q", errInvalidQueueResult, uidStr)
+	}
+
+	return &model.QueueTicket{
+		Ticket:      ticket,
+		ZoneID:      zoneID,
+		UID:         uid,
+		Nickname:    nickname,
+		Lane:        int(lane),
+		Admitted:    admitted == 1,
+		Position:    position,
+		QueueLength: length,
+	}, nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
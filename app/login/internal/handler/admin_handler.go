@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lk2023060901/xdooria/app/login/internal/manager"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/web"
+)
+
+// AdminConfig 运营后台 HTTP 接口配置
+type AdminConfig struct {
+	// Enabled 是否启动运营后台 HTTP 服务
+	Enabled bool `mapstructure:"enabled"`
+
+	// Token 访问令牌，请求需携带 "Authorization: Bearer <token>"；为空时拒绝所有请求
+	Token string `mapstructure:"token"`
+
+	// Web HTTP 服务配置
+	Web web.Config `mapstructure:"web"`
+}
+
+// AdminHandler 运营后台 HTTP 接口（重置密码、解除锁定、指定排队通道）
+type AdminHandler struct {
+	logger   logger.Logger
+	token    string
+	accounts *manager.AccountManager
+	queue    *manager.QueueManager
+}
+
+// NewAdminHandler 创建运营后台处理器
+func NewAdminHandler(l logger.Logger, cfg *AdminConfig, accounts *manager.AccountManager, queue *manager.QueueManager) *AdminHandler {
+	return &AdminHandler{
+		logger:   l.Named("handler.admin"),
+		token:    cfg.Token,
+		accounts: accounts,
+		queue:    queue,
+	}
+}
+
+// AdminResetPasswordRequest 重置密码请求
+type AdminResetPasswordRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// AdminUnlockRequest 解除锁定请求
+type AdminUnlockRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+// AdminQueuePriorityRequest 指定排队通道请求
+type AdminQueuePriorityRequest struct {
+	UID  int64  `json:"uid" binding:"required"`
+	Lane string `json:"lane"` // 通道名（如 vip），为空时恢复默认通道
+}
+
+// Register 注册路由
+func (h *AdminHandler) Register(r *gin.Engine) {
+	admin := r.Group("/admin/v1", h.auth)
+	{
+		admin.POST("/accounts/password", h.ResetPassword)
+		admin.POST("/accounts/unlock", h.Unlock)
+		admin.POST("/queue/priority", h.SetQueuePriority)
+	}
+}
+
+// auth 校验访问令牌
+func (h *AdminHandler) auth(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || h.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		h.logger.Warn("admin request unauthorized", "path", c.Request.URL.Path, "client_ip", c.ClientIP())
+		web.AbortWithError(c, http.StatusUnauthorized, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	c.Next()
+}
+
+// ResetPassword 重置账号密码（不校验旧密码，同时解除锁定）
+// @Router /admin/v1/accounts/password [post]
+func (h *AdminHandler) ResetPassword(c *gin.Context) {
+	var req AdminResetPasswordRequest
+	if !web.BindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.accounts.ResetPassword(c.Request.Context(), req.Username, req.Password); err != nil {
+		h.writeError(c, "reset password failed", err)
+		return
+	}
+
+	h.logger.Info("admin password reset", "username", req.Username, "client_ip", c.ClientIP())
+	web.Success(c, nil)
+}
+
+// Unlock 解除因连续登录失败导致的账号锁定
+// @Router /admin/v1/accounts/unlock [post]
+func (h *AdminHandler) Unlock(c *gin.Context) {
+	var req AdminUnlockRequest
+	if !web.BindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.accounts.Unlock(c.Request.Context(), req.Username); err != nil {
+		h.writeError(c, "unlock account failed", err)
+		return
+	}
+
+	h.logger.Info("admin account unlocked", "username", req.Username, "client_ip", c.ClientIP())
+	web.Success(c, nil)
+}
+
+// SetQueuePriority 指定玩家的登录排队通道（如 VIP），下次入队时生效
+// @Router /admin/v1/queue/priority [post]
+func (h *AdminHandler) SetQueuePriority(c *gin.Context) {
+	var req AdminQueuePriorityRequest
+	if !web.BindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.queue.SetPriority(c.Request.Context(), req.UID, req.Lane); err != nil {
+		h.writeError(c, "set queue priority failed", err)
+		return
+	}
+
+	h.logger.Info("admin queue priority set", "uid", req.UID, "lane", req.Lane, "client_ip", c.ClientIP())
+	web.Success(c, nil)
+}
+
+// writeError 账号不存在返回 404，参数错误返回 400，其余返回 500
+func (h *AdminHandler) writeError(c *gin.Context, msg string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrAccountNotFound):
+		h.logger.Warn(msg, "error", err)
+		web.Error(c, http.StatusNotFound, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, manager.ErrPasswordInvalid), errors.Is(err, manager.ErrLaneUnknown):
+		h.logger.Warn(msg, "error", err)
+		web.Error(c, http.StatusBadRequest, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.logger.Error(msg, "error", err)
+	web.Error(c, http.StatusInternalServerError, http.StatusInternalServerError, "internal error")
+}
@@ -38,18 +38,16 @@ func (h *LoginHandler) OnMessage(s session.Session, env *common.Envelope) {
 
 	h.logger.Debug("received message", "id", s.ID(), "op", op)
 
-	// 使用 Processor 路由到对应的 Handler
-	respOp, respPayload, err := h.processor.Process(context.Background(), op, payload)
+	// 使用 Processor 路由到对应的 Handler（Context 携带 Session，供排队位置推送使用）
+	ctx := session.NewContext(context.Background(), s)
+	respOp, respPayload, err := h.processor.Process(ctx, op, payload)
 	if err != nil {
 		h.logger.Error("process message failed", "id", s.ID(), "op", op, "error", err)
 		return
 	}
 
-	// 发送响应，writeLoop 会自动 Encode
-	respEnv := &common.Envelope{
-		Header:  &common.MessageHeader{Op: respOp},
-		Payload: respPayload,
-	}
+	// 发送响应（带回请求的 RPC 关联 ID），writeLoop 会自动 Encode
+	respEnv := session.Reply(env, respOp, respPayload)
 	if err := s.Send(s.Context(), respEnv); err != nil {
 		h.logger.Error("send response failed", "id", s.ID(), "error", err)
 	}
@@ -0,0 +1,284 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/login/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/login/internal/model"
+	"github.com/lk2023060901/xdooria/app/login/internal/repository"
+	"github.com/lk2023060901/xdooria/pkg/crypto"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 账号业务错误，Service 据此映射错误码
+var (
+	ErrUsernameInvalid    = errors.New("invalid username")
+	ErrPasswordInvalid    = errors.New("invalid password")
+	ErrAccountExists      = errors.New("account already exists")
+	ErrAccountNotFound    = errors.New("account not found")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrAccountLocked      = errors.New("account locked")
+	ErrAccountDisabled    = errors.New("account disabled")
+)
+
+// 账号配置默认值
+const (
+	defaultMaxFailedAttempts = 5
+	defaultLockDuration      = 15 * time.Minute
+	defaultMinPasswordLength = 8
+
+	// maxPasswordLength bcrypt 只使用密码的前 72 字节，更长的密码直接拒绝
+	maxPasswordLength = 72
+)
+
+// usernamePattern 用户名：4-32 位字母、数字或下划线
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{4,32}$`)
+
+// AccountConfig 本地账号配置
+type AccountConfig struct {
+	// MaxFailedAttempts 连续登录失败多少次后锁定账号，未配置时为 5
+	MaxFailedAttempts int `mapstructure:"max_failed_attempts"`
+
+	// LockDuration 账号锁定时长，未配置时为 15 分钟
+	LockDuration time.Duration `mapstructure:"lock_duration"`
+
+	// BcryptCost 密码哈希的 bcrypt 工作因子 (4-31)，未配置时为 10
+	BcryptCost int `mapstructure:"bcrypt_cost"`
+
+	// MinPasswordLength 密码最小长度，未配置时为 8
+	MinPasswordLength int `mapstructure:"min_password_length"`
+
+	// ImportConfigTable 启动时将 TbAccount 配置表中的账号导入数据库（按原 ID，已存在的跳过）
+	ImportConfigTable bool `mapstructure:"import_config_table"`
+}
+
+// AccountManager 本地账号管理：注册、登录校验（失败锁定）、修改与重置密码
+type AccountManager struct {
+	logger logger.Logger
+	cfg    *AccountConfig
+	repo   repository.AccountRepository
+	hasher *crypto.BcryptHasher
+	now    func() time.Time
+
+	// dummyHash 账号不存在时仍做一次哈希校验，避免通过响应时间判断账号是否存在
+	dummyHash string
+}
+
+// NewAccountManager 创建账号管理器
+func NewAccountManager(l logger.Logger, cfg *AccountConfig, repo repository.AccountRepository) (*AccountManager, error) {
+	if cfg == nil {
+		cfg = &AccountConfig{}
+	}
+
+	cost := cfg.BcryptCost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("invalid bcrypt cost %d", cost)
+	}
+
+	m := &AccountManager{
+		logger: l.Named("manager.account"),
+		cfg:    cfg,
+		repo:   repo,
+		hasher: crypto.NewBcryptHasher(crypto.WithCost(cost)),
+		now:    time.Now,
+	}
+
+	dummy, err := m.hasher.Hash("dummy-password")
+	if err != nil {
+		return nil, err
+	}
+	m.dummyHash = dummy
+
+	return m, nil
+}
+
+// Register 注册账号
+func (m *AccountManager) Register(ctx context.Context, username, password string) (*model.Account, error) {
+	if !usernamePattern.MatchString(username) {
+		return nil, ErrUsernameInvalid
+	}
+	hash, err := m.hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	acc := &model.Account{
+		Username:     username,
+		PasswordHash: hash,
+		Status:       model.AccountStatusNormal,
+	}
+	created, err := m.repo.CreateAccount(ctx, acc)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrAccountExists
+	}
+
+	return acc, nil
+}
+
+// Authenticate 校验用户名与密码
+// 校验密码前先预占一次尝试（计入失败次数，登录成功后清零），连续失败达到上限后账号被锁定，
+// 锁定期间即使密码正确也返回 ErrAccountLocked；并发请求无法绕过次数限制多做密码校验
+func (m *AccountManager) Authenticate(ctx context.Context, username, password string) (*model.Account, error) {
+	acc, err := m.repo.GetAccount(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if acc == nil {
+		m.hasher.IsMatch(password, m.dummyHash)
+		return nil, ErrInvalidCredentials
+	}
+
+	now := m.now()
+	if acc.IsDisabled() {
+		return nil, ErrAccountDisabled
+	}
+
+	state, err := m.repo.ReserveLoginAttempt(ctx, acc.ID, m.maxFailedAttempts(), now, now.Add(m.lockDuration()))
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		if acc.IsLocked(now) {
+			return nil, fmt.Errorf("%w until %s", ErrAccountLocked, acc.LockedUntil.Time.Format(time.RFC3339))
+		}
+		return nil, ErrAccountLocked
+	}
+
+	if !m.hasher.IsMatch(password, acc.PasswordHash) {
+		if state.IsLocked(now) {
+			m.logger.Warn("account locked after repeated login failures",
+				"account_id", acc.ID,
+				"username", acc.Username,
+				"locked_until", state.LockedUntil.Time,
+			)
+			return nil, fmt.Errorf("%w until %s", ErrAccountLocked, state.LockedUntil.Time.Format(time.RFC3339))
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	// 记录失败不影响本次登录
+	if err := m.repo.RecordLoginSuccess(ctx, acc.ID, now); err != nil {
+		m.logger.Warn("failed to record login success",
+			"account_id", acc.ID,
+			"error", err,
+		)
+	}
+
+	return acc, nil
+}
+
+// ChangePassword 校验旧密码后修改密码（旧密码错误同样计入登录失败次数）
+func (m *AccountManager) ChangePassword(ctx context.Context, username, oldPassword, newPassword string) error {
+	acc, err := m.Authenticate(ctx, username, oldPassword)
+	if err != nil {
+		return err
+	}
+	return m.setPassword(ctx, acc, newPassword)
+}
+
+// ResetPassword 运营重置密码，不校验旧密码，同时解除锁定
+func (m *AccountManager) ResetPassword(ctx context.Context, username, newPassword string) error {
+	acc, err := m.repo.GetAccount(ctx, username)
+	if err != nil {
+		return err
+	}
+	if acc == nil {
+		return ErrAccountNotFound
+	}
+	return m.setPassword(ctx, acc, newPassword)
+}
+
+// Unlock 运营解除账号锁定
+func (m *AccountManager) Unlock(ctx context.Context, username string) error {
+	acc, err := m.repo.GetAccount(ctx, username)
+	if err != nil {
+		return err
+	}
+	if acc == nil {
+		return ErrAccountNotFound
+	}
+	return m.repo.Unlock(ctx, acc.ID)
+}
+
+// ImportConfigAccounts 将 TbAccount 配置表中的账号导入数据库：保留原 ID（即 UID），明文密码哈希后保存
+// 已存在的 ID 或用户名跳过，可重复执行；返回实际导入的数量
+func (m *AccountManager) ImportConfigAccounts(ctx context.Context, rows []*gameconfig.Account) (int64, error) {
+	accounts := make([]*model.Account, 0, len(rows))
+	for _, row := range rows {
+		hash, err := m.hasher.Hash(row.Password)
+		if err != nil {
+			return 0, fmt.Errorf("hash password of account %d: %w", row.Id, err)
+		}
+		accounts = append(accounts, &model.Account{
+			ID:           int64(row.Id),
+			Username:     row.UserName,
+			PasswordHash: hash,
+			Status:       model.AccountStatusNormal,
+		})
+	}
+
+	imported, err := m.repo.ImportAccounts(ctx, accounts)
+	if err != nil {
+		return 0, err
+	}
+
+	m.logger.Info("config accounts imported",
+		"total", len(rows),
+		"imported", imported,
+	)
+	return imported, nil
+}
+
+// setPassword 校验新密码并保存哈希
+func (m *AccountManager) setPassword(ctx context.Context, acc *model.Account, password string) error {
+	hash, err := m.hashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := m.repo.UpdatePassword(ctx, acc.ID, hash, m.now()); err != nil {
+		return err
+	}
+
+	m.logger.Info("account password changed", "account_id", acc.ID)
+	return nil
+}
+
+// hashPassword 校验密码长度后计算哈希
+func (m *AccountManager) hashPassword(password string) (string, error) {
+	if len(password) < m.minPasswordLength() || len(password) > maxPasswordLength {
+		return "", fmt.Errorf("%w: length must be between %d and %d", ErrPasswordInvalid, m.minPasswordLength(), maxPasswordLength)
+	}
+	return m.hasher.Hash(password)
+}
+
+func (m *AccountManager) maxFailedAttempts() int32 {
+	if m.cfg.MaxFailedAttempts > 0 {
+		return int32(m.cfg.MaxFailedAttempts)
+	}
+	return defaultMaxFailedAttempts
+}
+
+func (m *AccountManager) lockDuration() time.Duration {
+	if m.cfg.LockDuration > 0 {
+		return m.cfg.LockDuration
+	}
+	return defaultLockDuration
+}
+
+func (m *AccountManager) minPasswordLength() int {
+	if m.cfg.MinPasswordLength > 0 {
+		return m.cfg.MinPasswordLength
+	}
+	return defaultMinPasswordLength
+}
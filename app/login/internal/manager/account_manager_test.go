@@ -0,0 +1,282 @@
+package manager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/login/internal/gameconfig"
+	"github.com/lk2023060901/xdooria/app/login/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeAccountRepo 内存版 AccountRepository
+type fakeAccountRepo struct {
+	accounts map[string]*model.Account
+	nextID   int64
+}
+
+func newFakeAccountRepo() *fakeAccountRepo {
+	return &fakeAccountRepo{accounts: make(map[string]*model.Account), nextID: 1}
+}
+
+func (r *fakeAccountRepo) byID(id int64) *model.Account {
+	for _, acc := range r.accounts {
+		if acc.ID == id {
+			return acc
+		}
+	}
+	return nil
+}
+
+func (r *fakeAccountRepo) GetAccount(_ context.Context, username string) (*model.Account, error) {
+	acc, ok := r.accounts[username]
+	if !ok {
+		return nil, nil
+	}
+	cp := *acc
+	return &cp, nil
+}
+
+func (r *fakeAccountRepo) CreateAccount(_ context.Context, acc *model.Account) (bool, error) {
+	if _, ok := r.accounts[acc.Username]; ok {
+		return false, nil
+	}
+	acc.ID = r.nextID
+	r.nextID++
+	cp := *acc
+	r.accounts[acc.Username] = &cp
+	return true, nil
+}
+
+func (r *fakeAccountRepo) UpdatePassword(_ context.Context, id int64, hash string, now time.Time) error {
+	acc := r.byID(id)
+	acc.PasswordHash = hash
+	acc.PasswordChangedAt = now
+	acc.FailedAttempts = 0
+	acc.LockedUntil = sql.NullTime{}
+	return nil
+}
+
+func (r *fakeAccountRepo) ReserveLoginAttempt(_ context.Context, id int64, maxAttempts int32, now, lockUntil time.Time) (*model.Account, error) {
+	acc := r.byID(id)
+	if acc.IsLocked(now) {
+		return nil, nil
+	}
+	if acc.LockedUntil.Valid {
+		acc.FailedAttempts = 1
+	} else {
+		acc.FailedAttempts++
+	}
+	acc.LockedUntil = sql.NullTime{}
+	if acc.FailedAttempts >= maxAttempts {
+		acc.LockedUntil = sql.NullTime{Time: lockUntil, Valid: true}
+	}
+	return &model.Account{ID: id, FailedAttempts: acc.FailedAttempts, LockedUntil: acc.LockedUntil}, nil
+}
+
+func (r *fakeAccountRepo) RecordLoginSuccess(_ context.Context, id int64, now time.Time) error {
+	acc := r.byID(id)
+	acc.FailedAttempts = 0
+	acc.LockedUntil = sql.NullTime{}
+	acc.LastLoginAt = sql.NullTime{Time: now, Valid: true}
+	return nil
+}
+
+func (r *fakeAccountRepo) Unlock(_ context.Context, id int64) error {
+	acc := r.byID(id)
+	acc.FailedAttempts = 0
+	acc.LockedUntil = sql.NullTime{}
+	return nil
+}
+
+func (r *fakeAccountRepo) ImportAccounts(_ context.Context, accounts []*model.Account) (int64, error) {
+	var imported int64
+	for _, acc := range accounts {
+		if _, ok := r.accounts[acc.Username]; ok || r.byID(acc.ID) != nil {
+			continue
+		}
+		cp := *acc
+		r.accounts[acc.Username] = &cp
+		imported++
+		r.nextID = max(r.nextID, acc.ID+1)
+	}
+	return imported, nil
+}
+
+// newTestAccountManager 使用最小 bcrypt 工作因子与可控时钟
+func newTestAccountManager(t *testing.T) (*AccountManager, *fakeAccountRepo, *time.Time) {
+	t.Helper()
+	repo := newFakeAccountRepo()
+	m, err := NewAccountManager(logger.Default(), &AccountConfig{
+		MaxFailedAttempts: 3,
+		LockDuration:      time.Minute,
+		BcryptCost:        bcrypt.MinCost,
+	}, repo)
+	if err != nil {
+		t.Fatalf("failed to create account manager: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	m.now = func() time.Time { return now }
+	return m, repo, &now
+}
+
+func TestAccountManager_Register(t *testing.T) {
+	m, repo, _ := newTestAccountManager(t)
+	ctx := context.Background()
+
+	acc, err := m.Register(ctx, "player_01", "secret-pass")
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if acc.ID == 0 || repo.accounts["player_01"].PasswordHash == "secret-pass" {
+		t.Fatalf("account should be created with a hashed password")
+	}
+
+	cases := []struct {
+		username, password string
+		want               error
+	}{
+		{"player_01", "secret-pass", ErrAccountExists},
+		{"ab", "secret-pass", ErrUsernameInvalid},
+		{"bad name", "secret-pass", ErrUsernameInvalid},
+		{"player_02", "short", ErrPasswordInvalid},
+	}
+	for _, c := range cases {
+		if _, err := m.Register(ctx, c.username, c.password); !errors.Is(err, c.want) {
+			t.Fatalf("register(%q, %q) err = %v, want %v", c.username, c.password, err, c.want)
+		}
+	}
+}
+
+func TestAccountManager_Lockout(t *testing.T) {
+	m, repo, now := newTestAccountManager(t)
+	ctx := context.Background()
+	if _, err := m.Register(ctx, "player_01", "secret-pass"); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+
+	// 未知账号与密码错误返回同一错误
+	if _, err := m.Authenticate(ctx, "nobody", "secret-pass"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("unknown account err = %v, want ErrInvalidCredentials", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := m.Authenticate(ctx, "player_01", "wrong-pass"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d err = %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+
+	// 第 3 次失败锁定账号，锁定期间正确密码也无法登录
+	if _, err := m.Authenticate(ctx, "player_01", "wrong-pass"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("third failure err = %v, want ErrAccountLocked", err)
+	}
+	if _, err := m.Authenticate(ctx, "player_01", "secret-pass"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("locked login err = %v, want ErrAccountLocked", err)
+	}
+	if acc := repo.accounts["player_01"]; acc.FailedAttempts != 3 {
+		t.Fatalf("locked account failed attempts = %d, want 3", acc.FailedAttempts)
+	}
+
+	// 锁定到期后可以登录，失败次数清零
+	*now = now.Add(time.Minute)
+	if _, err := m.Authenticate(ctx, "player_01", "secret-pass"); err != nil {
+		t.Fatalf("login after lock expired failed: %v", err)
+	}
+	acc := repo.accounts["player_01"]
+	if acc.FailedAttempts != 0 || acc.LockedUntil.Valid || !acc.LastLoginAt.Valid {
+		t.Fatalf("login success should reset lock state: %+v", acc)
+	}
+
+	// 运营解除锁定
+	for i := 0; i < 3; i++ {
+		_, _ = m.Authenticate(ctx, "player_01", "wrong-pass")
+	}
+	if err := m.Unlock(ctx, "player_01"); err != nil {
+		t.Fatalf("unlock failed: %v", err)
+	}
+	if _, err := m.Authenticate(ctx, "player_01", "secret-pass"); err != nil {
+		t.Fatalf("login after unlock failed: %v", err)
+	}
+
+	// 并发请求已预占全部尝试次数时，后续请求在密码校验前被拒绝
+	for i := 0; i < 3; i++ {
+		if _, err := repo.ReserveLoginAttempt(ctx, acc.ID, 3, *now, now.Add(time.Minute)); err != nil {
+			t.Fatalf("reserve attempt failed: %v", err)
+		}
+	}
+	if _, err := m.Authenticate(ctx, "player_01", "secret-pass"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("login with all attempts in flight err = %v, want ErrAccountLocked", err)
+	}
+}
+
+func TestAccountManager_Password(t *testing.T) {
+	m, _, _ := newTestAccountManager(t)
+	ctx := context.Background()
+	if _, err := m.Register(ctx, "player_01", "secret-pass"); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+
+	if err := m.ChangePassword(ctx, "player_01", "wrong-pass", "new-secret"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("change with wrong password err = %v, want ErrInvalidCredentials", err)
+	}
+	if err := m.ChangePassword(ctx, "player_01", "secret-pass", "short"); !errors.Is(err, ErrPasswordInvalid) {
+		t.Fatalf("change to short password err = %v, want ErrPasswordInvalid", err)
+	}
+	if err := m.ChangePassword(ctx, "player_01", "secret-pass", "new-secret"); err != nil {
+		t.Fatalf("change password failed: %v", err)
+	}
+	if _, err := m.Authenticate(ctx, "player_01", "new-secret"); err != nil {
+		t.Fatalf("login with new password failed: %v", err)
+	}
+
+	// 重置密码不校验旧密码，并解除锁定
+	for i := 0; i < 3; i++ {
+		_, _ = m.Authenticate(ctx, "player_01", "wrong-pass")
+	}
+	if err := m.ResetPassword(ctx, "player_01", "reset-secret"); err != nil {
+		t.Fatalf("reset password failed: %v", err)
+	}
+	if _, err := m.Authenticate(ctx, "player_01", "reset-secret"); err != nil {
+		t.Fatalf("login after reset failed: %v", err)
+	}
+	if err := m.ResetPassword(ctx, "nobody", "reset-secret"); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("reset unknown account err = %v, want ErrAccountNotFound", err)
+	}
+}
+
+func TestAccountManager_ImportConfigAccounts(t *testing.T) {
+	m, _, _ := newTestAccountManager(t)
+	ctx := context.Background()
+
+	rows := []*gameconfig.Account{
+		{Id: 1, UserName: "test0001", Password: "12345678"},
+		{Id: 2, UserName: "test0002", Password: "12345678"},
+	}
+	imported, err := m.ImportConfigAccounts(ctx, rows)
+	if err != nil || imported != 2 {
+		t.Fatalf("import = %d, %v, want 2", imported, err)
+	}
+
+	// 重复导入跳过已存在的账号
+	if imported, err := m.ImportConfigAccounts(ctx, rows); err != nil || imported != 0 {
+		t.Fatalf("second import = %d, %v, want 0", imported, err)
+	}
+
+	// 导入的账号保留原 ID，使用原密码登录
+	acc, err := m.Authenticate(ctx, "test0002", "12345678")
+	if err != nil {
+		t.Fatalf("login imported account failed: %v", err)
+	}
+	if acc.ID != 2 {
+		t.Fatalf("imported account id = %d, want 2", acc.ID)
+	}
+
+	// 之后注册的账号不与导入的 ID 冲突
+	created, err := m.Register(ctx, "player_01", "secret-pass")
+	if err != nil || created.ID != 3 {
+		t.Fatalf("register after import = %+v, %v, want id 3", created, err)
+	}
+}
@@ -0,0 +1,167 @@
+package manager
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/lk2023060901/xdooria/pkg/balancer"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/registry"
+	"github.com/lk2023060901/xdooria/pkg/util/conc"
+)
+
+// gatewayServiceName 网关在服务注册中的名称
+const gatewayServiceName = "gateway"
+
+// GatewayManager 网关节点缓存：监听服务注册中的网关，按区服与负载分配网关并汇总网关上报的在线人数
+type GatewayManager struct {
+	logger   logger.Logger
+	resolver registry.Resolver
+	balancer balancer.Balancer
+
+	mu          sync.RWMutex
+	nodes       []*balancer.Node
+	watchCancel context.CancelFunc
+}
+
+// NewGatewayManager 创建网关管理器，b 为 nil 时按网关上报的负载选择（least_load）
+func NewGatewayManager(l logger.Logger, r registry.Resolver, b balancer.Balancer) *GatewayManager {
+	if b == nil {
+		b = balancer.New(balancer.LeastLoadName)
+	}
+	return &GatewayManager{
+		logger:   l.Named("manager.gateway"),
+		resolver: r,
+		balancer: b,
+	}
+}
+
+// Start 同步获取一次网关列表并启动监听
+func (m *GatewayManager) Start() {
+	if m.resolver == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.watchCancel = cancel
+
+	// 先同步获取一次
+	if gateways, err := m.resolver.Resolve(ctx, gatewayServiceName); err == nil {
+		m.update(gateways)
+	} else {
+		m.logger.Warn("failed to resolve gateways", "error", err)
+	}
+
+	// 启动 Watch
+	ch, err := m.resolver.Watch(ctx, gatewayServiceName)
+	if err != nil {
+		m.logger.Warn("failed to watch gateways", "error", err)
+		return
+	}
+
+	conc.Go(func() (struct{}, error) {
+		for {
+			select {
+			case <-ctx.Done():
+				return struct{}{}, nil
+			case gateways, ok := <-ch:
+				if !ok {
+					return struct{}{}, nil
+				}
+				m.update(gateways)
+			}
+		}
+	})
+}
+
+// Close 停止监听
+func (m *GatewayManager) Close() {
+	if m.watchCancel != nil {
+		m.watchCancel()
+	}
+}
+
+// update 更新网关节点缓存
+func (m *GatewayManager) update(gateways []*registry.ServiceInfo) {
+	nodes := make([]*balancer.Node, len(gateways))
+	for i, gw := range gateways {
+		nodes[i] = &balancer.Node{
+			Address:  gw.Address,
+			Metadata: gw.Metadata,
+		}
+	}
+
+	m.mu.Lock()
+	m.nodes = nodes
+	m.mu.Unlock()
+}
+
+// Pick 为区服选择网关，zoneID 为 0 时在所有网关中选择；排空中的网关不参与选择，没有可用网关时返回空串
+func (m *GatewayManager) Pick(zoneID int32) string {
+	nodes := m.zoneNodes(zoneID)
+	available := make([]*balancer.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if node.Metadata[registry.MetadataDraining] != "true" {
+			available = append(available, node)
+		}
+	}
+	if len(available) == 0 {
+		if len(nodes) > 0 {
+			m.logger.Warn("all gateways are draining", "zone_id", zoneID, "gateways", len(nodes))
+		}
+		return ""
+	}
+	nodes = available
+
+	if node := m.balancer.Pick(nodes, balancer.PickInfo{}); node != nil {
+		return node.Address
+	}
+	return ""
+}
+
+// ZoneOnline 汇总区服各网关上报的在线人数，zoneID 为 0 时汇总所有网关；未上报的网关按 0 计
+func (m *GatewayManager) ZoneOnline(zoneID int32) int {
+	online := 0
+	for _, node := range m.zoneNodes(zoneID) {
+		if n, err := strconv.Atoi(node.Metadata[registry.MetadataOnline]); err == nil && n > 0 {
+			online += n
+		}
+	}
+	return online
+}
+
+// Zones 返回网关上报的所有区服
+func (m *GatewayManager) Zones() []int32 {
+	m.mu.RLock()
+	nodes := m.nodes
+	m.mu.RUnlock()
+
+	zones := make([]int32, 0, len(nodes))
+	for _, node := range nodes {
+		if zoneID, err := strconv.ParseInt(node.Metadata[registry.MetadataZoneID], 10, 32); err == nil && zoneID != 0 {
+			zones = append(zones, int32(zoneID))
+		}
+	}
+	return zones
+}
+
+// zoneNodes 返回属于区服的网关节点
+func (m *GatewayManager) zoneNodes(zoneID int32) []*balancer.Node {
+	m.mu.RLock()
+	nodes := m.nodes
+	m.mu.RUnlock()
+
+	if zoneID == 0 {
+		return nodes
+	}
+
+	zone := strconv.FormatInt(int64(zoneID), 10)
+	matched := make([]*balancer.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if node.Metadata[registry.MetadataZoneID] == zone {
+			matched = append(matched, node)
+		}
+	}
+	return matched
+}
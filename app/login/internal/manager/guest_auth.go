@@ -0,0 +1,39 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/component/auth"
+	"google.golang.org/protobuf/proto"
+)
+
+// GuestAuthenticator 游客认证，以客户端设备 ID 作为平台唯一标识
+type GuestAuthenticator struct{}
+
+func NewGuestAuthenticator() *GuestAuthenticator {
+	return &GuestAuthenticator{}
+}
+
+func (a *GuestAuthenticator) Type() pb.LoginType {
+	return pb.LoginType_LOGIN_TYPE_GUEST
+}
+
+func (a *GuestAuthenticator) Authenticate(ctx context.Context, cred []byte) (*auth.Identity, error) {
+	// 1. 解析凭证
+	var guestCred pb.GuestCredential
+	if err := proto.Unmarshal(cred, &guestCred); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal guest credentials: %w", err)
+	}
+
+	// 2. 校验设备 ID
+	if err := validatePlatformUID(guestCred.DeviceId); err != nil {
+		return nil, fmt.Errorf("invalid device id: %w", err)
+	}
+
+	// 3. 返回身份信息
+	return &auth.Identity{
+		UID: guestCred.DeviceId,
+	}, nil
+}
@@ -0,0 +1,255 @@
+package manager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	pb "github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/app/login/internal/model"
+	"github.com/lk2023060901/xdooria/app/login/internal/repository"
+	"github.com/lk2023060901/xdooria/component/auth"
+	"github.com/lk2023060901/xdooria/pkg/idgen"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// 身份绑定业务错误，Service 据此映射错误码
+var (
+	ErrIdentityBound    = errors.New("platform identity already bound to another account")
+	ErrPlatformBound    = errors.New("account already bound to this login type")
+	ErrIdentityNotBound = errors.New("login type not bound")
+	ErrLastIdentity     = errors.New("cannot unbind the last identity")
+	ErrNotGuest         = errors.New("account is not a guest account")
+)
+
+// maxPlatformUIDLength 平台唯一标识最大长度，与 user_identities.platform_uid 一致
+const maxPlatformUIDLength = 128
+
+// IdentityConfig 平台身份绑定配置
+type IdentityConfig struct {
+	// MachineID 分配内部 UID 的 Sonyflake 机器 ID，多个 Login 实例必须配置不同的值
+	MachineID uint16 `mapstructure:"machine_id"`
+
+	// GuestEnabled 是否允许游客登录（LOGIN_TYPE_GUEST）
+	GuestEnabled bool `mapstructure:"guest_enabled"`
+}
+
+// IdentityManager 平台身份与内部 UID 的映射：登录时查找或分配 UID，绑定/解绑平台身份，游客升级
+type IdentityManager struct {
+	logger logger.Logger
+	repo   repository.IdentityRepository
+	idGen  idgen.Generator
+	now    func() time.Time
+}
+
+// NewIdentityManager 创建平台身份管理器
+func NewIdentityManager(l logger.Logger, repo repository.IdentityRepository, idGen idgen.Generator) *IdentityManager {
+	return &IdentityManager{
+		logger: l.Named("manager.identity"),
+		repo:   repo,
+		idGen:  idGen,
+		now:    time.Now,
+	}
+}
+
+// Resolve 登录时将平台身份映射为内部 UID，首次登录时分配新 UID 并创建绑定
+func (m *IdentityManager) Resolve(ctx context.Context, loginType pb.LoginType, identity *auth.Identity) (*model.UserIdentity, error) {
+	if err := validatePlatformUID(identity.UID); err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	existing, err := m.repo.GetIdentity(ctx, int32(loginType), identity.UID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		// 记录失败不影响本次登录
+		if err := m.repo.RecordIdentityLogin(ctx, existing.LoginType, existing.PlatformUID, identity.Nickname, identity.AvatarUrl, now); err != nil {
+			m.logger.Warn("failed to record identity login",
+				"uid", existing.UID,
+				"login_type", loginType.String(),
+				"error", err,
+			)
+		}
+		return existing, nil
+	}
+
+	uid, err := m.allocateUID(loginType, identity)
+	if err != nil {
+		return nil, err
+	}
+	ident := newUserIdentity(uid, loginType, identity, now)
+	created, err := m.repo.CreateIdentity(ctx, ident)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		// 同一平台身份并发首次登录，以先创建的绑定为准
+		existing, err := m.repo.GetIdentity(ctx, int32(loginType), identity.UID)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return nil, fmt.Errorf("%w: uid %d", ErrPlatformBound, uid)
+		}
+		return existing, nil
+	}
+
+	m.logger.Info("new uid allocated",
+		"uid", uid,
+		"login_type", loginType.String(),
+		"platform_uid", identity.UID,
+	)
+	return ident, nil
+}
+
+// Bind 为 uid 绑定新的平台身份，每种登录类型最多绑定一个；重复绑定同一身份视为成功
+func (m *IdentityManager) Bind(ctx context.Context, uid int64, loginType pb.LoginType, identity *auth.Identity) error {
+	if err := validatePlatformUID(identity.UID); err != nil {
+		return err
+	}
+
+	created, err := m.repo.CreateIdentity(ctx, newUserIdentity(uid, loginType, identity, m.now()))
+	if err != nil {
+		return err
+	}
+	if !created {
+		return m.bindConflict(ctx, uid, loginType, identity.UID)
+	}
+
+	m.logger.Info("identity bound",
+		"uid", uid,
+		"login_type", loginType.String(),
+		"platform_uid", identity.UID,
+	)
+	return nil
+}
+
+// Unbind 解除 uid 在 loginType 上的绑定，不允许解除最后一个平台身份
+func (m *IdentityManager) Unbind(ctx context.Context, uid int64, loginType pb.LoginType) error {
+	deleted, last, err := m.repo.DeleteIdentity(ctx, uid, int32(loginType))
+	if err != nil {
+		return err
+	}
+	if last {
+		return ErrLastIdentity
+	}
+	if !deleted {
+		return ErrIdentityNotBound
+	}
+
+	m.logger.Info("identity unbound",
+		"uid", uid,
+		"login_type", loginType.String(),
+	)
+	return nil
+}
+
+// UpgradeGuest 游客升级：将平台身份绑定到游客的 uid 并解除游客身份，角色等数据随 uid 保留
+// 升级后该设备再次游客登录会得到新的 uid
+func (m *IdentityManager) UpgradeGuest(ctx context.Context, uid int64, loginType pb.LoginType, identity *auth.Identity) error {
+	if loginType == pb.LoginType_LOGIN_TYPE_GUEST {
+		return fmt.Errorf("%w: cannot upgrade to guest", ErrPlatformBound)
+	}
+	if err := validatePlatformUID(identity.UID); err != nil {
+		return err
+	}
+
+	replaced, err := m.repo.ReplaceIdentity(ctx, int32(pb.LoginType_LOGIN_TYPE_GUEST), newUserIdentity(uid, loginType, identity, m.now()))
+	if err != nil {
+		return err
+	}
+	if !replaced {
+		identities, err := m.repo.ListIdentities(ctx, uid)
+		if err != nil {
+			return err
+		}
+		if findIdentity(identities, pb.LoginType_LOGIN_TYPE_GUEST) == nil {
+			return ErrNotGuest
+		}
+		if err := m.bindConflict(ctx, uid, loginType, identity.UID); err != nil {
+			return err
+		}
+		// 平台身份此前已绑定到该 uid，只需解除游客身份
+		return m.Unbind(ctx, uid, pb.LoginType_LOGIN_TYPE_GUEST)
+	}
+
+	m.logger.Info("guest upgraded",
+		"uid", uid,
+		"login_type", loginType.String(),
+		"platform_uid", identity.UID,
+	)
+	return nil
+}
+
+// ListIdentities 获取 uid 绑定的所有平台身份
+func (m *IdentityManager) ListIdentities(ctx context.Context, uid int64) ([]*model.UserIdentity, error) {
+	return m.repo.ListIdentities(ctx, uid)
+}
+
+// bindConflict 绑定被唯一约束拒绝后判断原因：平台身份已绑定到 uid 时视为成功
+func (m *IdentityManager) bindConflict(ctx context.Context, uid int64, loginType pb.LoginType, platformUID string) error {
+	existing, err := m.repo.GetIdentity(ctx, int32(loginType), platformUID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case existing == nil:
+		return ErrPlatformBound
+	case existing.UID != uid:
+		return ErrIdentityBound
+	}
+	return nil
+}
+
+// allocateUID 为首次登录的平台身份分配 UID
+// 本地账号沿用账号 ID 作为 UID，与已有角色数据保持一致；其余登录类型由 ID 生成器分配
+func (m *IdentityManager) allocateUID(loginType pb.LoginType, identity *auth.Identity) (int64, error) {
+	if loginType == pb.LoginType_LOGIN_TYPE_LOCAL {
+		uid, err := strconv.ParseInt(identity.UID, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid local account id %q: %w", identity.UID, err)
+		}
+		return uid, nil
+	}
+
+	uid, err := m.idGen.NextID()
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate uid: %w", err)
+	}
+	return uid, nil
+}
+
+// newUserIdentity 构造平台身份绑定
+func newUserIdentity(uid int64, loginType pb.LoginType, identity *auth.Identity, now time.Time) *model.UserIdentity {
+	return &model.UserIdentity{
+		LoginType:   int32(loginType),
+		PlatformUID: identity.UID,
+		UID:         uid,
+		Nickname:    identity.Nickname,
+		AvatarURL:   identity.AvatarUrl,
+		LastLoginAt: sql.NullTime{Time: now, Valid: true},
+	}
+}
+
+// findIdentity 在绑定列表中查找指定登录类型
+func findIdentity(identities []*model.UserIdentity, loginType pb.LoginType) *model.UserIdentity {
+	for _, ident := range identities {
+		if ident.LoginType == int32(loginType) {
+			return ident
+		}
+	}
+	return nil
+}
+
+// validatePlatformUID 校验认证器返回的平台唯一标识
+func validatePlatformUID(platformUID string) error {
+	if platformUID == "" || len(platformUID) > maxPlatformUIDLength {
+		return fmt.Errorf("invalid platform uid length %d", len(platformUID))
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/app/login/internal/model"
+	"github.com/lk2023060901/xdooria/component/auth"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// fakeIdentityRepo 内存版 IdentityRepository，唯一约束与 user_identities 表一致
+type fakeIdentityRepo struct {
+	identities []*model.UserIdentity
+}
+
+func (r *fakeIdentityRepo) find(match func(*model.UserIdentity) bool) int {
+	for i, ident := range r.identities {
+		if match(ident) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *fakeIdentityRepo) GetIdentity(_ context.Context, loginType int32, platformUID string) (*model.UserIdentity, error) {
+	i := r.find(func(ident *model.UserIdentity) bool {
+		return ident.LoginType == loginType && ident.PlatformUID == platformUID
+	})
+	if i < 0 {
+		return nil, nil
+	}
+	cp := *r.identities[i]
+	return &cp, nil
+}
+
+func (r *fakeIdentityRepo) ListIdentities(_ context.Context, uid int64) ([]*model.UserIdentity, error) {
+	var list []*model.UserIdentity
+	for _, ident := range r.identities {
+		if ident.UID == uid {
+			cp := *ident
+			list = append(list, &cp)
+		}
+	}
+	return list, nil
+}
+
+func (r *fakeIdentityRepo) CreateIdentity(_ context.Context, ident *model.UserIdentity) (bool, error) {
+	conflict := r.find(func(e *model.UserIdentity) bool {
+		return e.LoginType == ident.LoginType && (e.PlatformUID == ident.PlatformUID || e.UID == ident.UID)
+	})
+	if conflict >= 0 {
+		return false, nil
+	}
+	cp := *ident
+	r.identities = append(r.identities, &cp)
+	return true, nil
+}
+
+func (r *fakeIdentityRepo) DeleteIdentity(_ context.Context, uid int64, loginType int32) (bool, bool, error) {
+	list, _ := r.ListIdentities(context.Background(), uid)
+	i := r.find(func(e *model.UserIdentity) bool { return e.UID == uid && e.LoginType == loginType })
+	if i < 0 {
+		return false, false, nil
+	}
+	if len(list) <= 1 {
+		return false, true, nil
+	}
+	r.identities = append(r.identities[:i], r.identities[i+1:]...)
+	return true, false, nil
+}
+
+func (r *fakeIdentityRepo) ReplaceIdentity(ctx context.Context, fromType int32, ident *model.UserIdentity) (bool, error) {
+	i := r.find(func(e *model.UserIdentity) bool { return e.UID == ident.UID && e.LoginType == fromType })
+	if i < 0 {
+		return false, nil
+	}
+	removed := r.identities[i]
+	r.identities = append(r.identities[:i], r.identities[i+1:]...)
+	if created, _ := r.CreateIdentity(ctx, ident); !created {
+		r.identities = append(r.identities, removed)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (r *fakeIdentityRepo) RecordIdentityLogin(_ context.Context, loginType int32, platformUID, nickname, avatarURL string, now time.Time) error {
+	i := r.find(func(e *model.UserIdentity) bool { return e.LoginType == loginType && e.PlatformUID == platformUID })
+	if i >= 0 {
+		r.identities[i].Nickname = nickname
+		r.identities[i].AvatarURL = avatarURL
+		r.identities[i].LastLoginAt.Time, r.identities[i].LastLoginAt.Valid = now, true
+	}
+	return nil
+}
+
+// fakeIDGen 顺序分配 ID
+type fakeIDGen struct {
+	next int64
+}
+
+func (g *fakeIDGen) NextID() (int64, error) {
+	g.next++
+	return g.next, nil
+}
+
+func newTestIdentityManager() (*IdentityManager, *fakeIdentityRepo) {
+	repo := &fakeIdentityRepo{}
+	return NewIdentityManager(logger.Default(), repo, &fakeIDGen{next: 1 << 40}), repo
+}
+
+const (
+	loginLocal  = pb.LoginType_LOGIN_TYPE_LOCAL
+	loginGuest  = pb.LoginType_LOGIN_TYPE_GUEST
+	loginTypeX  = pb.LoginType(100) // 第三方平台
+	loginTypeX2 = pb.LoginType(101) // 另一个第三方平台
+)
+
+func TestIdentityManager_Resolve(t *testing.T) {
+	m, repo := newTestIdentityManager()
+	ctx := context.Background()
+
+	// 第三方平台首次登录分配新 UID，再次登录返回同一 UID 并更新昵称
+	first, err := m.Resolve(ctx, loginTypeX, &auth.Identity{UID: "openid-a", Nickname: "a"})
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if first.UID != 1<<40+1 {
+		t.Fatalf("uid = %d, want allocated by generator", first.UID)
+	}
+	again, err := m.Resolve(ctx, loginTypeX, &auth.Identity{UID: "openid-a", Nickname: "a2"})
+	if err != nil || again.UID != first.UID {
+		t.Fatalf("second resolve = %+v, %v, want uid %d", again, err, first.UID)
+	}
+	if repo.identities[0].Nickname != "a2" {
+		t.Fatalf("nickname not updated on login: %q", repo.identities[0].Nickname)
+	}
+
+	// 同一 OpenID 在不同平台是不同的身份
+	other, err := m.Resolve(ctx, loginTypeX2, &auth.Identity{UID: "openid-a"})
+	if err != nil || other.UID == first.UID {
+		t.Fatalf("resolve on another platform = %+v, %v, want a new uid", other, err)
+	}
+
+	// 本地账号沿用账号 ID 作为 UID
+	local, err := m.Resolve(ctx, loginLocal, &auth.Identity{UID: "42"})
+	if err != nil || local.UID != 42 {
+		t.Fatalf("resolve local = %+v, %v, want uid 42", local, err)
+	}
+
+	if _, err := m.Resolve(ctx, loginTypeX, &auth.Identity{}); err == nil {
+		t.Fatalf("empty platform uid should be rejected")
+	}
+}
+
+func TestIdentityManager_BindUnbind(t *testing.T) {
+	m, _ := newTestIdentityManager()
+	ctx := context.Background()
+
+	owner, _ := m.Resolve(ctx, loginTypeX, &auth.Identity{UID: "openid-a"})
+	stranger, _ := m.Resolve(ctx, loginTypeX2, &auth.Identity{UID: "openid-b"})
+
+	// 绑定第二个平台后，用任一平台登录都得到同一 UID
+	if err := m.Bind(ctx, owner.UID, loginTypeX2, &auth.Identity{UID: "openid-c"}); err != nil {
+		t.Fatalf("bind failed: %v", err)
+	}
+	if err := m.Bind(ctx, owner.UID, loginTypeX2, &auth.Identity{UID: "openid-c"}); err != nil {
+		t.Fatalf("rebinding the same identity should succeed: %v", err)
+	}
+	resolved, _ := m.Resolve(ctx, loginTypeX2, &auth.Identity{UID: "openid-c"})
+	if resolved.UID != owner.UID {
+		t.Fatalf("bound identity resolved to %d, want %d", resolved.UID, owner.UID)
+	}
+
+	cases := []struct {
+		name      string
+		loginType pb.LoginType
+		platform  string
+		want      error
+	}{
+		{"identity owned by another uid", loginTypeX2, "openid-b", ErrIdentityBound},
+		{"login type already bound", loginTypeX2, "openid-d", ErrPlatformBound},
+	}
+	for _, c := range cases {
+		if err := m.Bind(ctx, owner.UID, c.loginType, &auth.Identity{UID: c.platform}); !errors.Is(err, c.want) {
+			t.Fatalf("%s: err = %v, want %v", c.name, err, c.want)
+		}
+	}
+
+	// 解绑后可以再次解绑其余身份，但不能解绑最后一个
+	if err := m.Unbind(ctx, owner.UID, loginTypeX2); err != nil {
+		t.Fatalf("unbind failed: %v", err)
+	}
+	if err := m.Unbind(ctx, owner.UID, loginTypeX2); !errors.Is(err, ErrIdentityNotBound) {
+		t.Fatalf("unbind missing err = %v, want ErrIdentityNotBound", err)
+	}
+	if err := m.Unbind(ctx, owner.UID, loginTypeX); !errors.Is(err, ErrLastIdentity) {
+		t.Fatalf("unbind last err = %v, want ErrLastIdentity", err)
+	}
+	if err := m.Unbind(ctx, stranger.UID, loginTypeX2); !errors.Is(err, ErrLastIdentity) {
+		t.Fatalf("unbind other account's last identity err = %v, want ErrLastIdentity", err)
+	}
+}
+
+func TestIdentityManager_UpgradeGuest(t *testing.T) {
+	m, _ := newTestIdentityManager()
+	ctx := context.Background()
+
+	guest, _ := m.Resolve(ctx, loginGuest, &auth.Identity{UID: "device-1"})
+	taken, _ := m.Resolve(ctx, loginTypeX, &auth.Identity{UID: "openid-taken"})
+
+	// 平台身份已属于其他账号时不修改游客绑定
+	if err := m.UpgradeGuest(ctx, guest.UID, loginTypeX, &auth.Identity{UID: "openid-taken"}); !errors.Is(err, ErrIdentityBound) {
+		t.Fatalf("upgrade to taken identity err = %v, want ErrIdentityBound", err)
+	}
+	if still, _ := m.Resolve(ctx, loginGuest, &auth.Identity{UID: "device-1"}); still.UID != guest.UID {
+		t.Fatalf("failed upgrade should keep guest binding")
+	}
+
+	// 升级后平台身份登录得到游客的 UID，游客身份已解除
+	if err := m.UpgradeGuest(ctx, guest.UID, loginTypeX, &auth.Identity{UID: "openid-new"}); err != nil {
+		t.Fatalf("upgrade failed: %v", err)
+	}
+	upgraded, _ := m.Resolve(ctx, loginTypeX, &auth.Identity{UID: "openid-new"})
+	if upgraded.UID != guest.UID {
+		t.Fatalf("upgraded identity resolved to %d, want guest uid %d", upgraded.UID, guest.UID)
+	}
+	identities, _ := m.ListIdentities(ctx, guest.UID)
+	if len(identities) != 1 || identities[0].LoginType != int32(loginTypeX) {
+		t.Fatalf("identities after upgrade = %+v, want only the platform identity", identities)
+	}
+
+	// 同一设备再次游客登录得到新的 UID
+	fresh, _ := m.Resolve(ctx, loginGuest, &auth.Identity{UID: "device-1"})
+	if fresh.UID == guest.UID {
+		t.Fatalf("guest login after upgrade should allocate a new uid")
+	}
+
+	if err := m.UpgradeGuest(ctx, taken.UID, loginTypeX2, &auth.Identity{UID: "openid-x"}); !errors.Is(err, ErrNotGuest) {
+		t.Fatalf("upgrade non-guest err = %v, want ErrNotGuest", err)
+	}
+	if err := m.UpgradeGuest(ctx, fresh.UID, loginGuest, &auth.Identity{UID: "device-2"}); !errors.Is(err, ErrPlatformBound) {
+		t.Fatalf("upgrade to guest err = %v, want ErrPlatformBound", err)
+	}
+}
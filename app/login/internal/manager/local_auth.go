@@ -5,15 +5,17 @@ import (
 	"fmt"
 
 	"github.com/lk2023060901/xdooria/component/auth"
-	"github.com/lk2023060901/xdooria/app/login/internal/gameconfig"
 	pb "github.com/lk2023060901/xdooria-proto-common"
 	"google.golang.org/protobuf/proto"
 )
 
-type LocalAuthenticator struct{}
+// LocalAuthenticator 本地账号密码认证，账号保存在数据库中
+type LocalAuthenticator struct {
+	accounts *AccountManager
+}
 
-func NewLocalAuthenticator() *LocalAuthenticator {
-	return &LocalAuthenticator{}
+func NewLocalAuthenticator(accounts *AccountManager) *LocalAuthenticator {
+	return &LocalAuthenticator{accounts: accounts}
 }
 
 func (a *LocalAuthenticator) Type() pb.LoginType {
@@ -27,27 +29,15 @@ func (a *LocalAuthenticator) Authenticate(ctx context.Context, cred []byte) (*au
 		return nil, fmt.Errorf("failed to unmarshal local credentials: %w", err)
 	}
 
-	// 2. 在全局配置表中查找账号
-	var foundAccount *gameconfig.Account
-	for _, acc := range gameconfig.T.TbAccount.GetDataList() {
-		if acc.UserName == localCred.Username {
-			foundAccount = acc
-			break
-		}
-	}
-
-	if foundAccount == nil {
-		return nil, fmt.Errorf("account not found: %s", localCred.Username)
-	}
-
-	// 3. 校验密码 (实际开发中应使用加密/哈希)
-	if foundAccount.Password != localCred.Password {
-		return nil, fmt.Errorf("invalid password")
+	// 2. 校验账号密码（连续失败会锁定账号）
+	acc, err := a.accounts.Authenticate(ctx, localCred.Username, localCred.Password)
+	if err != nil {
+		return nil, err
 	}
 
-	// 4. 返回身份信息
+	// 3. 返回身份信息
 	return &auth.Identity{
-		UID:      fmt.Sprintf("%d", foundAccount.Id),
-		Nickname: foundAccount.UserName,
+		UID:      fmt.Sprintf("%d", acc.ID),
+		Nickname: acc.Username,
 	}, nil
 }
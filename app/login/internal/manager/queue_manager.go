@@ -0,0 +1,434 @@
+package manager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/login/internal/model"
+	"github.com/lk2023060901/xdooria/app/login/internal/repository"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// 登录排队业务错误，Service 据此映射错误码
+var (
+	ErrTicketInvalid = errors.New("queue ticket invalid")
+	ErrLaneUnknown   = errors.New("unknown queue lane")
+	ErrZoneInvalid   = errors.New("login zone invalid")
+)
+
+// 登录排队配置默认值
+const (
+	defaultQueueAdmitInterval  = time.Second
+	defaultQueueNotifyInterval = 5 * time.Second
+	defaultQueueAbandonTimeout = time.Minute
+	defaultQueueAdmitGrace     = 30 * time.Second
+	defaultQueueMaxAdmit       = 100
+
+	// queueRateSmoothing 放行速率的指数平滑系数，越小越平稳
+	queueRateSmoothing = 0.1
+)
+
+// defaultQueueLanes 默认排队通道
+var defaultQueueLanes = []string{"vip", "normal"}
+
+// QueueConfig 登录排队配置
+type QueueConfig struct {
+	// Enabled 是否启用登录排队，未启用时登录直接分配网关
+	Enabled bool `mapstructure:"enabled"`
+
+	// DefaultMaxOnline 未单独配置的区服的在线上限，0 表示不限制（不排队）
+	// 启用排队时客户端必须指定已配置或有网关上报的区服，否则拒绝登录，避免绕过区服上限
+	DefaultMaxOnline int `mapstructure:"default_max_online"`
+
+	// Zones 各区服的在线上限
+	Zones []ZoneQueueConfig `mapstructure:"zones"`
+
+	// Lanes 排队通道名，按优先级从高到低，最后一个为默认通道；未配置时为 [vip, normal]
+	// 玩家的通道由运营后台指定，高优先级通道排空后才放行低优先级通道
+	Lanes []string `mapstructure:"lanes"`
+
+	// AdmitInterval 放行检查间隔，未配置时为 1 秒
+	AdmitInterval time.Duration `mapstructure:"admit_interval"`
+
+	// NotifyInterval 向排队中的客户端推送位置的间隔，未配置时为 5 秒
+	NotifyInterval time.Duration `mapstructure:"notify_interval"`
+
+	// AbandonTimeout 客户端断开后保留票据的时间，期间可凭票据恢复排队，未配置时为 1 分钟
+	AbandonTimeout time.Duration `mapstructure:"abandon_timeout"`
+
+	// AdmitGrace 放行后计入在线人数的时长，覆盖客户端连接网关与网关上报的延迟，未配置时为 30 秒
+	AdmitGrace time.Duration `mapstructure:"admit_grace"`
+
+	// MaxAdmitPerTick 每个区服单次最多放行人数，避免瞬间涌入网关，未配置时为 100
+	MaxAdmitPerTick int `mapstructure:"max_admit_per_tick"`
+}
+
+// ZoneQueueConfig 区服在线上限
+type ZoneQueueConfig struct {
+	// ZoneID 区服 ID
+	ZoneID int32 `mapstructure:"zone_id"`
+
+	// MaxOnline 在线上限，0 表示不限制
+	MaxOnline int `mapstructure:"max_online"`
+}
+
+func (c *QueueConfig) lanes() []string {
+	if len(c.Lanes) > 0 {
+		return c.Lanes
+	}
+	return defaultQueueLanes
+}
+
+func (c *QueueConfig) admitInterval() time.Duration {
+	if c.AdmitInterval > 0 {
+		return c.AdmitInterval
+	}
+	return defaultQueueAdmitInterval
+}
+
+func (c *QueueConfig) notifyInterval() time.Duration {
+	if c.NotifyInterval > 0 {
+		return c.NotifyInterval
+	}
+	return defaultQueueNotifyInterval
+}
+
+func (c *QueueConfig) abandonTimeout() time.Duration {
+	if c.AbandonTimeout > 0 {
+		return c.AbandonTimeout
+	}
+	return defaultQueueAbandonTimeout
+}
+
+func (c *QueueConfig) admitGrace() time.Duration {
+	if c.AdmitGrace > 0 {
+		return c.AdmitGrace
+	}
+	return defaultQueueAdmitGrace
+}
+
+func (c *QueueConfig) maxAdmitPerTick() int {
+	if c.MaxAdmitPerTick > 0 {
+		return c.MaxAdmitPerTick
+	}
+	return defaultQueueMaxAdmit
+}
+
+// maxOnline 区服在线上限，0 表示不限制
+func (c *QueueConfig) maxOnline(zoneID int32) int {
+	for _, z := range c.Zones {
+		if z.ZoneID == zoneID {
+			return z.MaxOnline
+		}
+	}
+	return c.DefaultMaxOnline
+}
+
+// zoneRate 区服放行速率，用于估算等待时间
+type zoneRate struct {
+	total int64     // 上次观察到的累计放行人数
+	at    time.Time // 上次观察时间
+	rate  float64   // 每秒放行人数（指数平滑）
+}
+
+// QueueManager 登录排队：区服在线人数达到上限时按通道优先级先进先出排队，
+// 网关上报的在线人数回落后按队首放行。队列存储在 Redis，多个 Login 实例共享。
+type QueueManager struct {
+	logger   logger.Logger
+	cfg      *QueueConfig
+	repo     repository.QueueRepository
+	gateways *GatewayManager
+	now      func() time.Time
+
+	rateMu sync.Mutex
+	rates  map[int32]*zoneRate
+}
+
+// NewQueueManager 创建登录排队管理器
+func NewQueueManager(l logger.Logger, cfg *QueueConfig, repo repository.QueueRepository, gateways *GatewayManager) *QueueManager {
+	return &QueueManager{
+		logger:   l.Named("manager.queue"),
+		cfg:      cfg,
+		repo:     repo,
+		gateways: gateways,
+		now:      time.Now,
+		rates:    make(map[int32]*zoneRate),
+	}
+}
+
+// Enabled 是否启用登录排队
+func (m *QueueManager) Enabled() bool {
+	return m.cfg.Enabled
+}
+
+// AdmitInterval 放行检查间隔
+func (m *QueueManager) AdmitInterval() time.Duration {
+	return m.cfg.admitInterval()
+}
+
+// NotifyInterval 排队位置推送间隔
+func (m *QueueManager) NotifyInterval() time.Duration {
+	return m.cfg.notifyInterval()
+}
+
+// Capped 区服是否设置了在线上限（需要经过排队放行）
+func (m *QueueManager) Capped(zoneID int32) bool {
+	return m.cfg.Enabled && m.cfg.maxOnline(zoneID) > 0
+}
+
+// Enter 登录认证通过后入队：无人排队且未达在线上限时直接放行（Admitted 为 true，Ticket 为空），
+// 否则返回排队票据；uid 已在排队时沿用原票据与位置。启用排队时区服未知返回 ErrZoneInvalid
+func (m *QueueManager) Enter(ctx context.Context, zoneID int32, uid int64, nickname string) (*model.QueueTicket, error) {
+	if m.cfg.Enabled && !m.knownZone(zoneID) {
+		return nil, fmt.Errorf("%w: %d", ErrZoneInvalid, zoneID)
+	}
+	if !m.Capped(zoneID) {
+		return &model.QueueTicket{
+			ZoneID:   zoneID,
+			UID:      uid,
+			Nickname: nickname,
+			Admitted: true,
+		}, nil
+	}
+
+	ticket, err := newTicket(zoneID)
+	if err != nil {
+		return nil, err
+	}
+	entered, err := m.repo.EnterQueue(ctx, &model.QueueTicket{
+		Ticket:   ticket,
+		ZoneID:   zoneID,
+		UID:      uid,
+		Nickname: nickname,
+		Lane:     m.lane(ctx, uid),
+	}, m.limit(zoneID))
+	if err != nil {
+		return nil, err
+	}
+
+	if !entered.Admitted {
+		m.logger.Info("login queued",
+			"zone_id", zoneID,
+			"uid", uid,
+			"lane", entered.Lane,
+			"position", entered.Position,
+			"queue_length", entered.QueueLength,
+		)
+	}
+	return entered, nil
+}
+
+// Admit 对所有设置了在线上限的区服按空位放行队首票据，多个 Login 实例同时执行也不会超发
+func (m *QueueManager) Admit(ctx context.Context) {
+	for _, zoneID := range m.zones() {
+		admitted, total, waiting, err := m.repo.AdmitQueue(ctx, zoneID, m.limit(zoneID))
+		if err != nil {
+			m.logger.Warn("failed to admit queue", "zone_id", zoneID, "error", err)
+			continue
+		}
+		m.observe(zoneID, total)
+
+		if admitted > 0 {
+			m.logger.Info("login queue admitted",
+				"zone_id", zoneID,
+				"admitted", admitted,
+				"waiting", waiting,
+			)
+		}
+	}
+}
+
+// Status 批量查询票据状态，结果以票据为 key，票据无效或已清理时不在结果中；
+// keepalive 为 true 时为票据续期（客户端仍在等待）
+func (m *QueueManager) Status(ctx context.Context, tickets []string, keepalive bool) (map[string]*model.QueueTicket, error) {
+	byZone := make(map[int32][]string)
+	for _, t := range tickets {
+		if zoneID, ok := parseTicketZone(t); ok {
+			byZone[zoneID] = append(byZone[zoneID], t)
+		}
+	}
+
+	now := m.now()
+	result := make(map[string]*model.QueueTicket, len(tickets))
+	for zoneID, zoneTickets := range byZone {
+		statuses, err := m.repo.QueueStatus(ctx, zoneID, zoneTickets, len(m.cfg.lanes()), keepalive, m.cfg.abandonTimeout(), now)
+		if err != nil {
+			return nil, err
+		}
+		for i, st := range statuses {
+			if st != nil {
+				result[zoneTickets[i]] = st
+			}
+		}
+	}
+	return result, nil
+}
+
+// Get 查询单个票据状态并续期，票据无效或已清理时返回 ErrTicketInvalid
+func (m *QueueManager) Get(ctx context.Context, ticket string) (*model.QueueTicket, error) {
+	statuses, err := m.Status(ctx, []string{ticket}, true)
+	if err != nil {
+		return nil, err
+	}
+	st, ok := statuses[ticket]
+	if !ok {
+		return nil, ErrTicketInvalid
+	}
+	return st, nil
+}
+
+// Leave 删除票据（已下发 Token 或客户端取消排队），票据无效或已清理时返回 ErrTicketInvalid
+func (m *QueueManager) Leave(ctx context.Context, ticket string) error {
+	zoneID, ok := parseTicketZone(ticket)
+	if !ok {
+		return ErrTicketInvalid
+	}
+	existed, err := m.repo.RemoveTicket(ctx, zoneID, ticket, len(m.cfg.lanes()))
+	if err != nil {
+		return err
+	}
+	if !existed {
+		return ErrTicketInvalid
+	}
+	return nil
+}
+
+// EstimatedWait 按区服近期的放行速率估算排队等待时间，无法估算时返回 0
+func (m *QueueManager) EstimatedWait(t *model.QueueTicket) time.Duration {
+	if t.Admitted || t.Position <= 0 {
+		return 0
+	}
+
+	m.rateMu.Lock()
+	defer m.rateMu.Unlock()
+
+	r, ok := m.rates[t.ZoneID]
+	if !ok || r.rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(t.Position) / r.rate * float64(time.Second))
+}
+
+// SetPriority 指定 uid 的排队通道（下次入队时生效），lane 为空时恢复默认通道
+func (m *QueueManager) SetPriority(ctx context.Context, uid int64, lane string) error {
+	if lane == "" {
+		return m.repo.DeletePriority(ctx, uid)
+	}
+	if !slices.Contains(m.cfg.lanes(), lane) {
+		return fmt.Errorf("%w: %q", ErrLaneUnknown, lane)
+	}
+	return m.repo.SetPriority(ctx, uid, lane)
+}
+
+// lane 返回 uid 的排队通道下标，未指定或查询失败时为默认通道（最后一个）
+func (m *QueueManager) lane(ctx context.Context, uid int64) int {
+	lanes := m.cfg.lanes()
+	name, err := m.repo.GetPriority(ctx, uid)
+	if err != nil {
+		m.logger.Warn("failed to get queue priority", "uid", uid, "error", err)
+	}
+	if i := slices.Index(lanes, name); name != "" && i >= 0 {
+		return i
+	}
+	return len(lanes) - 1
+}
+
+// limit 构造区服放行参数，在线人数取自网关上报
+func (m *QueueManager) limit(zoneID int32) *model.QueueLimit {
+	return &model.QueueLimit{
+		MaxOnline:      m.cfg.maxOnline(zoneID),
+		Online:         m.gateways.ZoneOnline(zoneID),
+		Lanes:          len(m.cfg.lanes()),
+		MaxAdmit:       m.cfg.maxAdmitPerTick(),
+		Grace:          m.cfg.admitGrace(),
+		AbandonTimeout: m.cfg.abandonTimeout(),
+		Now:            m.now(),
+	}
+}
+
+// knownZone 区服是否单独配置过或有网关上报，未指定区服（0）与负数区服无效
+func (m *QueueManager) knownZone(zoneID int32) bool {
+	if zoneID <= 0 {
+		return false
+	}
+	return slices.ContainsFunc(m.cfg.Zones, func(z ZoneQueueConfig) bool { return z.ZoneID == zoneID }) ||
+		slices.Contains(m.gateways.Zones(), zoneID)
+}
+
+// zones 需要放行检查的区服：配置了上限的区服与网关所属的区服
+func (m *QueueManager) zones() []int32 {
+	if !m.cfg.Enabled {
+		return nil
+	}
+
+	var candidates []int32
+	for _, z := range m.cfg.Zones {
+		candidates = append(candidates, z.ZoneID)
+	}
+	candidates = append(candidates, m.gateways.Zones()...)
+	slices.Sort(candidates)
+
+	zones := make([]int32, 0, len(candidates))
+	for _, zoneID := range slices.Compact(candidates) {
+		if m.cfg.maxOnline(zoneID) > 0 {
+			zones = append(zones, zoneID)
+		}
+	}
+	return zones
+}
+
+// observe 根据累计放行人数更新区服放行速率
+func (m *QueueManager) observe(zoneID int32, total int64) {
+	now := m.now()
+
+	m.rateMu.Lock()
+	defer m.rateMu.Unlock()
+
+	r, ok := m.rates[zoneID]
+	if !ok {
+		m.rates[zoneID] = &zoneRate{total: total, at: now}
+		return
+	}
+
+	elapsed := now.Sub(r.at).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	current := float64(max(total-r.total, 0)) / elapsed
+	if r.rate == 0 {
+		r.rate = current
+	} else {
+		r.rate += queueRateSmoothing * (current - r.rate)
+	}
+	r.total, r.at = total, now
+}
+
+// newTicket 生成排队票据，前缀为区服 ID（区服的 Redis key 按区服分组）
+func newTicket(zoneID int32) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate queue ticket: %w", err)
+	}
+	return strconv.FormatInt(int64(zoneID), 10) + "-" + hex.EncodeToString(b), nil
+}
+
+// parseTicketZone 从票据中解析区服 ID，随机部分为十六进制不含 "-"，按最后一个 "-" 切分
+func parseTicketZone(ticket string) (int32, bool) {
+	i := strings.LastIndexByte(ticket, '-')
+	if i < 0 || i == len(ticket)-1 {
+		return 0, false
+	}
+	zoneID, err := strconv.ParseInt(ticket[:i], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return int32(zoneID), true
+}
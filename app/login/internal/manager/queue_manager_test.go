@@ -0,0 +1,340 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/login/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/registry"
+)
+
+// fakeQueueRepo 内存版 QueueRepository，按 Redis 脚本的语义排队与放行
+type fakeQueueRepo struct {
+	tickets    map[string]*model.QueueTicket
+	order      []string // 入队顺序
+	priorities map[int64]string
+
+	enterCalls  int
+	lastLimit   *model.QueueLimit
+	admitZones  []int32
+	admitTotals []int64 // 依次返回的累计放行人数
+}
+
+func newFakeQueueRepo() *fakeQueueRepo {
+	return &fakeQueueRepo{
+		tickets:    make(map[string]*model.QueueTicket),
+		priorities: make(map[int64]string),
+	}
+}
+
+func (r *fakeQueueRepo) EnterQueue(_ context.Context, t *model.QueueTicket, limit *model.QueueLimit) (*model.QueueTicket, error) {
+	r.enterCalls++
+	r.lastLimit = limit
+	if len(r.order) == 0 && limit.MaxOnline-limit.Online > 0 {
+		return &model.QueueTicket{ZoneID: t.ZoneID, UID: t.UID, Nickname: t.Nickname, Lane: t.Lane, Admitted: true}, nil
+	}
+	cp := *t
+	r.tickets[t.Ticket] = &cp
+	r.order = append(r.order, t.Ticket)
+	cp.Position, cp.QueueLength = int64(len(r.order)), int64(len(r.order))
+	return &cp, nil
+}
+
+func (r *fakeQueueRepo) AdmitQueue(_ context.Context, zoneID int32, _ *model.QueueLimit) (int, int64, int64, error) {
+	r.admitZones = append(r.admitZones, zoneID)
+	var total int64
+	if len(r.admitTotals) > 0 {
+		total, r.admitTotals = r.admitTotals[0], r.admitTotals[1:]
+	}
+	return 0, total, int64(len(r.order)), nil
+}
+
+func (r *fakeQueueRepo) QueueStatus(_ context.Context, _ int32, tickets []string, _ int, _ bool, _ time.Duration, _ time.Time) ([]*model.QueueTicket, error) {
+	result := make([]*model.QueueTicket, len(tickets))
+	for i, t := range tickets {
+		if st, ok := r.tickets[t]; ok {
+			cp := *st
+			result[i] = &cp
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeQueueRepo) RemoveTicket(_ context.Context, _ int32, ticket string, _ int) (bool, error) {
+	if _, ok := r.tickets[ticket]; !ok {
+		return false, nil
+	}
+	delete(r.tickets, ticket)
+	r.order = slices.DeleteFunc(r.order, func(t string) bool { return t == ticket })
+	return true, nil
+}
+
+func (r *fakeQueueRepo) GetPriority(_ context.Context, uid int64) (string, error) {
+	return r.priorities[uid], nil
+}
+
+func (r *fakeQueueRepo) SetPriority(_ context.Context, uid int64, lane string) error {
+	r.priorities[uid] = lane
+	return nil
+}
+
+func (r *fakeQueueRepo) DeletePriority(_ context.Context, uid int64) error {
+	delete(r.priorities, uid)
+	return nil
+}
+
+// newTestGateways 创建带有网关节点的网关管理器，每个节点为 {zone_id, online}
+func newTestGateways(nodes ...[2]string) *GatewayManager {
+	gm := NewGatewayManager(logger.Default(), nil, nil)
+	infos := make([]*registry.ServiceInfo, len(nodes))
+	for i, n := range nodes {
+		infos[i] = &registry.ServiceInfo{
+			ServiceName: gatewayServiceName,
+			Address:     "gw-" + n[0] + "-" + n[1],
+			Metadata: map[string]string{
+				registry.MetadataZoneID: n[0],
+				registry.MetadataOnline: n[1],
+			},
+		}
+	}
+	gm.update(infos)
+	return gm
+}
+
+func TestQueueManager_Enter(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeQueueRepo()
+	gateways := newTestGateways([2]string{"1001", "60"}, [2]string{"1001", "40"}, [2]string{"1002", "500"})
+	m := NewQueueManager(logger.Default(), &QueueConfig{
+		Enabled: true,
+		Zones:   []ZoneQueueConfig{{ZoneID: 1001, MaxOnline: 101}},
+	}, repo, gateways)
+
+	// 未指定区服或区服未知时拒绝，避免绕过区服上限
+	for _, zoneID := range []int32{0, -1, 9999} {
+		if _, err := m.Enter(ctx, zoneID, 1, "a"); !errors.Is(err, ErrZoneInvalid) {
+			t.Fatalf("enter zone %d err = %v, want ErrZoneInvalid", zoneID, err)
+		}
+	}
+
+	// 未设置上限的区服不经过排队
+	free, err := m.Enter(ctx, 1002, 1, "a")
+	if err != nil || !free.Admitted || repo.enterCalls != 0 {
+		t.Fatalf("uncapped zone = %+v, %v, enter calls %d; want admitted without queue", free, err, repo.enterCalls)
+	}
+
+	// 在线人数取该区服网关上报之和，有空位时直接放行
+	admitted, err := m.Enter(ctx, 1001, 1, "a")
+	if err != nil || !admitted.Admitted || admitted.Ticket != "" {
+		t.Fatalf("enter with free slot = %+v, %v, want admitted", admitted, err)
+	}
+	if repo.lastLimit.Online != 100 || repo.lastLimit.MaxOnline != 101 || repo.lastLimit.Lanes != 2 {
+		t.Fatalf("limit = %+v, want online 100 of 101 with 2 lanes", repo.lastLimit)
+	}
+
+	// 满员后排队，未指定通道的进入默认（最后一个）通道，指定了 VIP 的进入优先通道
+	m.gateways = newTestGateways([2]string{"1001", "101"})
+	queued, err := m.Enter(ctx, 1001, 2, "b")
+	if err != nil || queued.Admitted || queued.Lane != 1 || queued.Position != 1 {
+		t.Fatalf("enter when full = %+v, %v, want queued in normal lane at 1", queued, err)
+	}
+	if zoneID, ok := parseTicketZone(queued.Ticket); !ok || zoneID != 1001 || !strings.HasPrefix(queued.Ticket, "1001-") {
+		t.Fatalf("ticket %q should carry zone 1001", queued.Ticket)
+	}
+
+	if err := m.SetPriority(ctx, 3, "vip"); err != nil {
+		t.Fatalf("set priority failed: %v", err)
+	}
+	vip, err := m.Enter(ctx, 1001, 3, "c")
+	if err != nil || vip.Lane != 0 {
+		t.Fatalf("vip enter = %+v, %v, want lane 0", vip, err)
+	}
+	if err := m.SetPriority(ctx, 3, "gold"); !errors.Is(err, ErrLaneUnknown) {
+		t.Fatalf("unknown lane err = %v, want ErrLaneUnknown", err)
+	}
+}
+
+func TestQueueManager_AdmitZones(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeQueueRepo()
+	gateways := newTestGateways([2]string{"1001", "0"}, [2]string{"1003", "0"})
+	cfg := &QueueConfig{
+		Enabled: true,
+		Zones: []ZoneQueueConfig{
+			{ZoneID: 1001, MaxOnline: 100},
+			{ZoneID: 1002, MaxOnline: 0},
+		},
+	}
+	m := NewQueueManager(logger.Default(), cfg, repo, gateways)
+
+	m.Admit(ctx)
+	if !slices.Equal(repo.admitZones, []int32{1001}) {
+		t.Fatalf("admit zones = %v, want only the capped zone", repo.admitZones)
+	}
+
+	// 默认上限覆盖网关上报的区服，单独配置为 0 的区服不排队
+	cfg.DefaultMaxOnline = 50
+	repo.admitZones = nil
+	m.Admit(ctx)
+	if !slices.Equal(repo.admitZones, []int32{1001, 1003}) {
+		t.Fatalf("admit zones = %v, want [1001 1003]", repo.admitZones)
+	}
+
+	cfg.Enabled = false
+	repo.admitZones = nil
+	m.Admit(ctx)
+	if len(repo.admitZones) != 0 {
+		t.Fatalf("disabled queue admitted zones %v", repo.admitZones)
+	}
+}
+
+func TestQueueManager_StatusAndLeave(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeQueueRepo()
+	m := NewQueueManager(logger.Default(), &QueueConfig{Enabled: true, DefaultMaxOnline: 1}, repo, newTestGateways([2]string{"7", "1"}))
+
+	queued, err := m.Enter(ctx, 7, 1, "a")
+	if err != nil || queued.Admitted {
+		t.Fatalf("enter = %+v, %v, want queued", queued, err)
+	}
+
+	statuses, err := m.Status(ctx, []string{queued.Ticket, "7-missing", "not-a-ticket", "bad"}, true)
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if len(statuses) != 1 || statuses[queued.Ticket] == nil {
+		t.Fatalf("statuses = %v, want only the queued ticket", statuses)
+	}
+
+	if _, err := m.Get(ctx, "7-missing"); !errors.Is(err, ErrTicketInvalid) {
+		t.Fatalf("get missing err = %v, want ErrTicketInvalid", err)
+	}
+	if err := m.Leave(ctx, queued.Ticket); err != nil {
+		t.Fatalf("leave failed: %v", err)
+	}
+	if err := m.Leave(ctx, queued.Ticket); !errors.Is(err, ErrTicketInvalid) {
+		t.Fatalf("second leave err = %v, want ErrTicketInvalid", err)
+	}
+	if err := m.Leave(ctx, "garbage"); !errors.Is(err, ErrTicketInvalid) {
+		t.Fatalf("leave garbage err = %v, want ErrTicketInvalid", err)
+	}
+}
+
+func TestParseTicketZone(t *testing.T) {
+	cases := []struct {
+		ticket string
+		zoneID int32
+		ok     bool
+	}{
+		{"7-abcd", 7, true},
+		{"-1-abcd", -1, true},
+		{"7-", 0, false},
+		{"abcd", 0, false},
+		{"x-abcd", 0, false},
+	}
+	for _, c := range cases {
+		zoneID, ok := parseTicketZone(c.ticket)
+		if zoneID != c.zoneID || ok != c.ok {
+			t.Fatalf("parseTicketZone(%q) = %d, %v; want %d, %v", c.ticket, zoneID, ok, c.zoneID, c.ok)
+		}
+	}
+}
+
+func TestQueueManager_EstimatedWait(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeQueueRepo()
+	m := NewQueueManager(logger.Default(), &QueueConfig{
+		Enabled: true,
+		Zones:   []ZoneQueueConfig{{ZoneID: 1, MaxOnline: 10}},
+	}, repo, newTestGateways())
+
+	now := time.Unix(1700000000, 0)
+	m.now = func() time.Time { return now }
+	ticket := &model.QueueTicket{ZoneID: 1, Position: 50}
+
+	// 第一次观察只记录基线
+	repo.admitTotals = []int64{100, 110, 110}
+	m.Admit(ctx)
+	if wait := m.EstimatedWait(ticket); wait != 0 {
+		t.Fatalf("wait without rate = %v, want 0", wait)
+	}
+
+	// 1 秒放行 10 人，第 50 位约等待 5 秒
+	now = now.Add(time.Second)
+	m.Admit(ctx)
+	if wait := m.EstimatedWait(ticket); wait != 5*time.Second {
+		t.Fatalf("wait = %v, want 5s", wait)
+	}
+
+	// 放行停滞后速率下降，估算时间变长
+	now = now.Add(time.Second)
+	m.Admit(ctx)
+	if wait := m.EstimatedWait(ticket); wait <= 5*time.Second {
+		t.Fatalf("wait after stall = %v, want longer than 5s", wait)
+	}
+
+	if wait := m.EstimatedWait(&model.QueueTicket{ZoneID: 1, Admitted: true}); wait != 0 {
+		t.Fatalf("admitted wait = %v, want 0", wait)
+	}
+}
+
+func TestGatewayManager_Zone(t *testing.T) {
+	gm := newTestGateways([2]string{"1", "10"}, [2]string{"2", "20"}, [2]string{"2", "oops"})
+
+	if got := gm.ZoneOnline(2); got != 20 {
+		t.Fatalf("zone 2 online = %d, want 20 (unreported counts as 0)", got)
+	}
+	if got := gm.ZoneOnline(0); got != 30 {
+		t.Fatalf("all online = %d, want 30", got)
+	}
+	if addr := gm.Pick(1); addr != "gw-1-10" {
+		t.Fatalf("pick zone 1 = %q, want gw-1-10", addr)
+	}
+	if addr := gm.Pick(3); addr != "" {
+		t.Fatalf("pick zone without gateways = %q, want empty", addr)
+	}
+	if zones := gm.Zones(); !slices.Equal(zones, []int32{1, 2, 2}) {
+		t.Fatalf("zones = %v", zones)
+	}
+}
+
+func TestGatewayManager_PickDraining(t *testing.T) {
+	gm := NewGatewayManager(logger.Default(), nil, nil)
+	gm.update([]*registry.ServiceInfo{
+		{
+			ServiceName: gatewayServiceName,
+			Address:     "gw-busy",
+			Metadata:    map[string]string{registry.MetadataZoneID: "1", registry.MetadataSessions: "900"},
+		},
+		{
+			ServiceName: gatewayServiceName,
+			Address:     "gw-draining",
+			Metadata:    map[string]string{registry.MetadataZoneID: "1", registry.MetadataSessions: "0", registry.MetadataDraining: "true"},
+		},
+		{
+			ServiceName: gatewayServiceName,
+			Address:     "gw-idle",
+			Metadata:    map[string]string{registry.MetadataZoneID: "1", registry.MetadataSessions: "100"},
+		},
+		{
+			ServiceName: gatewayServiceName,
+			Address:     "gw-2-draining",
+			Metadata:    map[string]string{registry.MetadataZoneID: "2", registry.MetadataDraining: "true"},
+		},
+	})
+
+	// 排空中的网关不参与选择，其余两个中选择负载低的
+	for i := 0; i < 20; i++ {
+		if addr := gm.Pick(1); addr != "gw-idle" {
+			t.Fatalf("pick %d = %q, want gw-idle", i, addr)
+		}
+	}
+	if addr := gm.Pick(2); addr != "" {
+		t.Fatalf("pick zone with only draining gateways = %q, want empty", addr)
+	}
+}
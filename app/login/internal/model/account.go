@@ -0,0 +1,40 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 账号状态
+const (
+	AccountStatusNormal   = 0 // 正常
+	AccountStatusDisabled = 1 // 停用
+)
+
+// Account 账号模型，对应 accounts 表
+type Account struct {
+	ID           int64  `db:"id"` // 账号ID，即 UID
+	Username     string `db:"username"`
+	PasswordHash string `db:"password_hash"`
+	Status       int16  `db:"status"`
+
+	// 登录失败锁定
+	FailedAttempts int32        `db:"failed_attempts"`
+	LockedUntil    sql.NullTime `db:"locked_until"`
+
+	// 时间戳
+	PasswordChangedAt time.Time    `db:"password_changed_at"`
+	LastLoginAt       sql.NullTime `db:"last_login_at"`
+	CreatedAt         time.Time    `db:"created_at"`
+	UpdatedAt         time.Time    `db:"updated_at"`
+}
+
+// IsLocked 判断账号在 now 时是否处于锁定中
+func (a *Account) IsLocked(now time.Time) bool {
+	return a.LockedUntil.Valid && now.Before(a.LockedUntil.Time)
+}
+
+// IsDisabled 判断账号是否已停用
+func (a *Account) IsDisabled() bool {
+	return a.Status == AccountStatusDisabled
+}
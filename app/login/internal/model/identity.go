@@ -0,0 +1,21 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// UserIdentity 平台身份绑定，对应 user_identities 表
+type UserIdentity struct {
+	LoginType   int32  `db:"login_type"`   // 登录类型（common.LoginType）
+	PlatformUID string `db:"platform_uid"` // 平台唯一标识
+	UID         int64  `db:"uid"`          // 内部 UID
+
+	// 平台资料
+	Nickname  string `db:"nickname"`
+	AvatarURL string `db:"avatar_url"`
+
+	// 时间戳
+	BoundAt     time.Time    `db:"bound_at"`
+	LastLoginAt sql.NullTime `db:"last_login_at"`
+}
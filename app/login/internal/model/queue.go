@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// QueueTicket 登录排队票据，存储在 Redis
+type QueueTicket struct {
+	Ticket   string // 票据，格式 "<zone_id>-<随机串>"，客户端凭票据恢复或取消排队
+	ZoneID   int32  // 区服 ID
+	UID      int64  // 内部 UID
+	Nickname string // 昵称（放行后随 Token 下发）
+	Lane     int    // 排队通道下标，0 优先级最高
+
+	// 排队状态
+	Admitted    bool  // 已放行，等待下发 Token
+	Position    int64 // 排队位置（从 1 开始，已放行时为 0）
+	QueueLength int64 // 区服排队总人数
+}
+
+// QueueLimit 区服放行参数
+type QueueLimit struct {
+	MaxOnline      int           // 在线上限
+	Online         int           // 网关上报的在线人数
+	Lanes          int           // 排队通道数
+	MaxAdmit       int           // 单次最多放行人数
+	Grace          time.Duration // 放行后计入在线人数的时长（网关上报存在延迟）
+	AbandonTimeout time.Duration // 票据无人续期超过该时长视为放弃排队
+	Now            time.Time
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/login/internal/dao"
+	"github.com/lk2023060901/xdooria/app/login/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// AccountRepository 账号仓储接口
+type AccountRepository interface {
+	// ===== 账号 =====
+	GetAccount(ctx context.Context, username string) (*model.Account, error)
+	CreateAccount(ctx context.Context, acc *model.Account) (bool, error)
+	UpdatePassword(ctx context.Context, accountID int64, passwordHash string, now time.Time) error
+
+	// ===== 登录失败锁定 =====
+	ReserveLoginAttempt(ctx context.Context, accountID int64, maxAttempts int32, now, lockUntil time.Time) (*model.Account, error)
+	RecordLoginSuccess(ctx context.Context, accountID int64, now time.Time) error
+	Unlock(ctx context.Context, accountID int64) error
+
+	// ===== 迁移 =====
+	ImportAccounts(ctx context.Context, accounts []*model.Account) (int64, error)
+}
+
+// accountRepositoryImpl 账号仓储实现（账号数据量小、读写都在登录路径上，直接查库）
+type accountRepositoryImpl struct {
+	accountDAO *dao.AccountDAO
+	logger     logger.Logger
+}
+
+// NewAccountRepository 创建账号仓储
+func NewAccountRepository(accountDAO *dao.AccountDAO, l logger.Logger) AccountRepository {
+	return &accountRepositoryImpl{
+		accountDAO: accountDAO,
+		logger:     l.Named("repository.account"),
+	}
+}
+
+// GetAccount 根据用户名获取账号，不存在时返回 nil
+func (r *accountRepositoryImpl) GetAccount(ctx context.Context, username string) (*model.Account, error) {
+	return r.accountDAO.GetByUsername(ctx, username)
+}
+
+// CreateAccount 创建账号，用户名已存在时返回 false
+func (r *accountRepositoryImpl) CreateAccount(ctx context.Context, acc *model.Account) (bool, error) {
+	return r.accountDAO.Create(ctx, acc)
+}
+
+// UpdatePassword 更新密码哈希，同时解除锁定
+func (r *accountRepositoryImpl) UpdatePassword(ctx context.Context, accountID int64, passwordHash string, now time.Time) error {
+	return r.accountDAO.UpdatePassword(ctx, accountID, passwordHash, now)
+}
+
+// ReserveLoginAttempt 预占一次登录尝试，返回更新后的失败次数与锁定时间，账号锁定中时返回 nil
+func (r *accountRepositoryImpl) ReserveLoginAttempt(ctx context.Context, accountID int64, maxAttempts int32, now, lockUntil time.Time) (*model.Account, error) {
+	return r.accountDAO.ReserveLoginAttempt(ctx, accountID, maxAttempts, now, lockUntil)
+}
+
+// RecordLoginSuccess 清除登录失败次数并更新最后登录时间
+func (r *accountRepositoryImpl) RecordLoginSuccess(ctx context.Context, accountID int64, now time.Time) error {
+	return r.accountDAO.RecordLoginSuccess(ctx, accountID, now)
+}
+
+// Unlock 解除账号锁定
+func (r *accountRepositoryImpl) Unlock(ctx context.Context, accountID int64) error {
+	return r.accountDAO.Unlock(ctx, accountID)
+}
+
+// ImportAccounts 按原有 ID 导入账号，已存在的跳过
+func (r *accountRepositoryImpl) ImportAccounts(ctx context.Context, accounts []*model.Account) (int64, error) {
+	return r.accountDAO.Import(ctx, accounts)
+}
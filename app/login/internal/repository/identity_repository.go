@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/login/internal/dao"
+	"github.com/lk2023060901/xdooria/app/login/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// IdentityRepository 平台身份绑定仓储接口
+type IdentityRepository interface {
+	// ===== 查询 =====
+	GetIdentity(ctx context.Context, loginType int32, platformUID string) (*model.UserIdentity, error)
+	ListIdentities(ctx context.Context, uid int64) ([]*model.UserIdentity, error)
+
+	// ===== 绑定 =====
+	CreateIdentity(ctx context.Context, ident *model.UserIdentity) (bool, error)
+	DeleteIdentity(ctx context.Context, uid int64, loginType int32) (deleted, last bool, err error)
+	ReplaceIdentity(ctx context.Context, fromType int32, ident *model.UserIdentity) (bool, error)
+
+	// ===== 登录 =====
+	RecordIdentityLogin(ctx context.Context, loginType int32, platformUID, nickname, avatarURL string, now time.Time) error
+}
+
+// identityRepositoryImpl 平台身份绑定仓储实现（每次登录查一次主键，直接查库）
+type identityRepositoryImpl struct {
+	identityDAO *dao.IdentityDAO
+	logger      logger.Logger
+}
+
+// NewIdentityRepository 创建平台身份绑定仓储
+func NewIdentityRepository(identityDAO *dao.IdentityDAO, l logger.Logger) IdentityRepository {
+	return &identityRepositoryImpl{
+		identityDAO: identityDAO,
+		logger:      l.Named("repository.identity"),
+	}
+}
+
+// GetIdentity 根据平台身份获取绑定，不存在时返回 nil
+func (r *identityRepositoryImpl) GetIdentity(ctx context.Context, loginType int32, platformUID string) (*model.UserIdentity, error) {
+	return r.identityDAO.Get(ctx, loginType, platformUID)
+}
+
+// ListIdentities 获取 UID 绑定的所有平台身份
+func (r *identityRepositoryImpl) ListIdentities(ctx context.Context, uid int64) ([]*model.UserIdentity, error) {
+	return r.identityDAO.ListByUID(ctx, uid)
+}
+
+// CreateIdentity 创建绑定，与已有绑定冲突时返回 false
+func (r *identityRepositoryImpl) CreateIdentity(ctx context.Context, ident *model.UserIdentity) (bool, error) {
+	return r.identityDAO.Create(ctx, ident)
+}
+
+// DeleteIdentity 解除绑定，UID 只剩这一个平台身份时拒绝解除（last=true）
+func (r *identityRepositoryImpl) DeleteIdentity(ctx context.Context, uid int64, loginType int32) (bool, bool, error) {
+	return r.identityDAO.Delete(ctx, uid, loginType)
+}
+
+// ReplaceIdentity 原子地把 UID 在 fromType 上的绑定替换为新的平台身份
+func (r *identityRepositoryImpl) ReplaceIdentity(ctx context.Context, fromType int32, ident *model.UserIdentity) (bool, error) {
+	return r.identityDAO.Replace(ctx, fromType, ident)
+}
+
+// RecordIdentityLogin 更新平台资料与最后登录时间
+func (r *identityRepositoryImpl) RecordIdentityLogin(ctx context.Context, loginType int32, platformUID, nickname, avatarURL string, now time.Time) error {
+	return r.identityDAO.RecordLogin(ctx, loginType, platformUID, nickname, avatarURL, now)
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/lk2023060901/xdooria/app/login/internal/dao"
+	"github.com/lk2023060901/xdooria/app/login/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// QueueRepository 登录排队仓储接口
+type QueueRepository interface {
+	// ===== 排队 =====
+	EnterQueue(ctx context.Context, t *model.QueueTicket, limit *model.QueueLimit) (*model.QueueTicket, error)
+	AdmitQueue(ctx context.Context, zoneID int32, limit *model.QueueLimit) (admitted int, total, waiting int64, err error)
+	QueueStatus(ctx context.Context, zoneID int32, tickets []string, lanes int, keepalive bool, abandonTimeout time.Duration, now time.Time) ([]*model.QueueTicket, error)
+	RemoveTicket(ctx context.Context, zoneID int32, ticket string, lanes int) (bool, error)
+
+	// ===== 通道指定 =====
+	GetPriority(ctx context.Context, uid int64) (string, error)
+	SetPriority(ctx context.Context, uid int64, lane string) error
+	DeletePriority(ctx context.Context, uid int64) error
+}
+
+// queueRepositoryImpl 登录排队仓储实现（状态全部在 Redis，多个 Login 实例共享同一队列）
+type queueRepositoryImpl struct {
+	queueDAO *dao.QueueDAO
+	logger   logger.Logger
+}
+
+// NewQueueRepository 创建登录排队仓储
+func NewQueueRepository(queueDAO *dao.QueueDAO, l logger.Logger) QueueRepository {
+	return &queueRepositoryImpl{
+		queueDAO: queueDAO,
+		logger:   l.Named("repository.queue"),
+	}
+}
+
+// EnterQueue 入队，返回实际生效的票据（沿用的原票据、直接放行或新入队）
+func (r *queueRepositoryImpl) EnterQueue(ctx context.Context, t *model.QueueTicket, limit *model.QueueLimit) (*model.QueueTicket, error) {
+	return r.queueDAO.Enter(ctx, t, limit)
+}
+
+// AdmitQueue 按在线上限放行区服队首的票据
+func (r *queueRepositoryImpl) AdmitQueue(ctx context.Context, zoneID int32, limit *model.QueueLimit) (int, int64, int64, error) {
+	return r.queueDAO.Admit(ctx, zoneID, limit)
+}
+
+// QueueStatus 批量查询票据状态，票据不存在时对应位置为 nil
+func (r *queueRepositoryImpl) QueueStatus(ctx context.Context, zoneID int32, tickets []string, lanes int, keepalive bool, abandonTimeout time.Duration, now time.Time) ([]*model.QueueTicket, error) {
+	return r.queueDAO.Status(ctx, zoneID, tickets, lanes, keepalive, abandonTimeout, now)
+}
+
+// RemoveTicket 删除票据，返回票据是否存在
+func (r *queueRepositoryImpl) RemoveTicket(ctx context.Context, zoneID int32, ticket string, lanes int) (bool, error) {
+	return r.queueDAO.Remove(ctx, zoneID, ticket, lanes)
+}
+
+// GetPriority 获取 uid 指定的排队通道，未指定时返回空串
+func (r *queueRepositoryImpl) GetPriority(ctx context.Context, uid int64) (string, error) {
+	return r.queueDAO.GetPriority(ctx, uid)
+}
+
+// SetPriority 指定 uid 的排队通道
+func (r *queueRepositoryImpl) SetPriority(ctx context.Context, uid int64, lane string) error {
+	return r.queueDAO.SetPriority(ctx, uid, lane)
+}
+
+// DeletePriority 取消 uid 的通道指定
+func (r *queueRepositoryImpl) DeletePriority(ctx context.Context, uid int64) error {
+	return r.queueDAO.DeletePriority(ctx, uid)
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	api "github.com/lk2023060901/xdooria-proto-api"
+	"github.com/lk2023060901/xdooria/app/login/internal/manager"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/router"
+)
+
+// AccountService 账号注册与修改密码
+type AccountService struct {
+	logger   logger.Logger
+	accounts *manager.AccountManager
+}
+
+func NewAccountService(l logger.Logger, accounts *manager.AccountManager) *AccountService {
+	return &AccountService{
+		logger:   l.Named("service.account"),
+		accounts: accounts,
+	}
+}
+
+// Init 注册路由
+func (s *AccountService) Init(r router.Router) {
+	router.RegisterHandler(r, uint32(api.OpCode_OP_REGISTER_REQ), uint32(api.OpCode_OP_REGISTER_RES), s.Register)
+	router.RegisterHandler(r, uint32(api.OpCode_OP_CHANGE_PASSWORD_REQ), uint32(api.OpCode_OP_CHANGE_PASSWORD_RES), s.ChangePassword)
+}
+
+// Register 注册本地账号
+func (s *AccountService) Register(ctx context.Context, req *api.RegisterRequest) (*api.RegisterResponse, error) {
+	acc, err := s.accounts.Register(ctx, req.Username, req.Password)
+	if err != nil {
+		s.logger.Warn("register failed", "username", req.Username, "error", err)
+		return &api.RegisterResponse{Code: accountErrorCode(err)}, nil
+	}
+
+	return &api.RegisterResponse{
+		Code: api.ErrorCode_ERR_SUCCESS,
+		Uid:  uint64(acc.ID),
+	}, nil
+}
+
+// ChangePassword 校验旧密码后修改密码
+func (s *AccountService) ChangePassword(ctx context.Context, req *api.ChangePasswordRequest) (*api.ChangePasswordResponse, error) {
+	if err := s.accounts.ChangePassword(ctx, req.Username, req.OldPassword, req.NewPassword); err != nil {
+		s.logger.Warn("change password failed", "username", req.Username, "error", err)
+		return &api.ChangePasswordResponse{Code: accountErrorCode(err)}, nil
+	}
+
+	return &api.ChangePasswordResponse{Code: api.ErrorCode_ERR_SUCCESS}, nil
+}
+
+// accountErrorCode 账号业务错误映射为错误码
+func accountErrorCode(err error) api.ErrorCode {
+	switch {
+	case errors.Is(err, manager.ErrUsernameInvalid):
+		return api.ErrorCode_ERR_USERNAME_INVALID
+	case errors.Is(err, manager.ErrPasswordInvalid):
+		return api.ErrorCode_ERR_PASSWORD_INVALID
+	case errors.Is(err, manager.ErrAccountExists):
+		return api.ErrorCode_ERR_ALREADY_EXISTS
+	case errors.Is(err, manager.ErrInvalidCredentials):
+		return api.ErrorCode_ERR_INVALID_CREDENTIALS
+	case errors.Is(err, manager.ErrAccountLocked):
+		return api.ErrorCode_ERR_ACCOUNT_LOCKED
+	case errors.Is(err, manager.ErrAccountDisabled):
+		return api.ErrorCode_ERR_ACCOUNT_DISABLED
+	}
+	return api.ErrorCode_ERR_INTERNAL
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	api "github.com/lk2023060901/xdooria-proto-api"
+	pb "github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/app/login/internal/manager"
+	"github.com/lk2023060901/xdooria/app/login/internal/model"
+	"github.com/lk2023060901/xdooria/component/auth"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/router"
+	"github.com/lk2023060901/xdooria/pkg/security"
+)
+
+// errCredentialsInvalid 待绑定平台的凭证校验失败
+var errCredentialsInvalid = errors.New("credentials invalid")
+
+// IdentityService 平台身份绑定：查询、绑定、解绑与游客升级
+// 请求携带登录返回的 LoginToken 标识当前账号
+type IdentityService struct {
+	logger     logger.Logger
+	authMgr    *auth.Manager
+	jwtMgr     *security.JWTManager
+	identities *manager.IdentityManager
+}
+
+func NewIdentityService(l logger.Logger, authMgr *auth.Manager, jwtMgr *security.JWTManager, identities *manager.IdentityManager) *IdentityService {
+	return &IdentityService{
+		logger:     l.Named("service.identity"),
+		authMgr:    authMgr,
+		jwtMgr:     jwtMgr,
+		identities: identities,
+	}
+}
+
+// Init 注册路由
+func (s *IdentityService) Init(r router.Router) {
+	router.RegisterHandler(r, uint32(api.OpCode_OP_LIST_IDENTITIES_REQ), uint32(api.OpCode_OP_LIST_IDENTITIES_RES), s.ListIdentities)
+	router.RegisterHandler(r, uint32(api.OpCode_OP_BIND_IDENTITY_REQ), uint32(api.OpCode_OP_BIND_IDENTITY_RES), s.BindIdentity)
+	router.RegisterHandler(r, uint32(api.OpCode_OP_UNBIND_IDENTITY_REQ), uint32(api.OpCode_OP_UNBIND_IDENTITY_RES), s.UnbindIdentity)
+	router.RegisterHandler(r, uint32(api.OpCode_OP_UPGRADE_GUEST_REQ), uint32(api.OpCode_OP_UPGRADE_GUEST_RES), s.UpgradeGuest)
+}
+
+// ListIdentities 查询当前账号绑定的平台身份
+func (s *IdentityService) ListIdentities(ctx context.Context, req *api.ListIdentitiesRequest) (*api.ListIdentitiesResponse, error) {
+	uid, err := s.uidFromToken(req.Token)
+	if err != nil {
+		return &api.ListIdentitiesResponse{Code: identityErrorCode(err)}, nil
+	}
+
+	code, infos := s.listIdentities(ctx, uid)
+	return &api.ListIdentitiesResponse{Code: code, Identities: infos}, nil
+}
+
+// BindIdentity 校验平台凭证后将平台身份绑定到当前账号
+func (s *IdentityService) BindIdentity(ctx context.Context, req *api.BindIdentityRequest) (*api.BindIdentityResponse, error) {
+	uid, identity, err := s.verify(ctx, req.Token, req.LoginType, req.Credentials)
+	if err == nil {
+		err = s.identities.Bind(ctx, uid, req.LoginType, identity)
+	}
+	if err != nil {
+		s.logger.Warn("bind identity failed", "uid", uid, "login_type", req.LoginType.String(), "error", err)
+		return &api.BindIdentityResponse{Code: identityErrorCode(err)}, nil
+	}
+
+	code, infos := s.listIdentities(ctx, uid)
+	return &api.BindIdentityResponse{Code: code, Identities: infos}, nil
+}
+
+// UnbindIdentity 解除当前账号在某个登录类型上的绑定
+func (s *IdentityService) UnbindIdentity(ctx context.Context, req *api.UnbindIdentityRequest) (*api.UnbindIdentityResponse, error) {
+	uid, err := s.uidFromToken(req.Token)
+	if err == nil {
+		err = s.identities.Unbind(ctx, uid, req.LoginType)
+	}
+	if err != nil {
+		s.logger.Warn("unbind identity failed", "uid", uid, "login_type", req.LoginType.String(), "error", err)
+		return &api.UnbindIdentityResponse{Code: identityErrorCode(err)}, nil
+	}
+
+	code, infos := s.listIdentities(ctx, uid)
+	return &api.UnbindIdentityResponse{Code: code, Identities: infos}, nil
+}
+
+// UpgradeGuest 游客账号绑定平台身份并解除游客身份
+func (s *IdentityService) UpgradeGuest(ctx context.Context, req *api.UpgradeGuestRequest) (*api.UpgradeGuestResponse, error) {
+	uid, identity, err := s.verify(ctx, req.Token, req.LoginType, req.Credentials)
+	if err == nil {
+		err = s.identities.UpgradeGuest(ctx, uid, req.LoginType, identity)
+	}
+	if err != nil {
+		s.logger.Warn("upgrade guest failed", "uid", uid, "login_type", req.LoginType.String(), "error", err)
+		return &api.UpgradeGuestResponse{Code: identityErrorCode(err)}, nil
+	}
+
+	code, infos := s.listIdentities(ctx, uid)
+	return &api.UpgradeGuestResponse{Code: code, Identities: infos}, nil
+}
+
+// verify 校验 LoginToken 与待绑定平台的凭证
+func (s *IdentityService) verify(ctx context.Context, token string, loginType pb.LoginType, cred []byte) (int64, *auth.Identity, error) {
+	uid, err := s.uidFromToken(token)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	identity, err := s.authMgr.Verify(ctx, loginType, cred)
+	if err != nil {
+		return uid, nil, fmt.Errorf("%w: %w", errCredentialsInvalid, err)
+	}
+	return uid, identity, nil
+}
+
+// uidFromToken 从 LoginToken 中提取内部 UID
+func (s *IdentityService) uidFromToken(token string) (int64, error) {
+	claims, err := s.jwtMgr.ValidateToken(token)
+	if err != nil {
+		if errors.Is(err, security.ErrTokenExpired) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w: %v", security.ErrTokenInvalid, err)
+	}
+
+	uidStr, ok := claims.Get("uid").(string)
+	if !ok {
+		return 0, fmt.Errorf("%w: uid not found in token", security.ErrTokenInvalid)
+	}
+	uid, err := strconv.ParseInt(uidStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid uid %q", security.ErrTokenInvalid, uidStr)
+	}
+	return uid, nil
+}
+
+// listIdentities 查询绑定列表，用于各响应返回绑定后的最新状态
+func (s *IdentityService) listIdentities(ctx context.Context, uid int64) (api.ErrorCode, []*api.IdentityInfo) {
+	identities, err := s.identities.ListIdentities(ctx, uid)
+	if err != nil {
+		s.logger.Error("list identities failed", "uid", uid, "error", err)
+		return api.ErrorCode_ERR_INTERNAL, nil
+	}
+
+	infos := make([]*api.IdentityInfo, 0, len(identities))
+	for _, ident := range identities {
+		infos = append(infos, toIdentityInfo(ident))
+	}
+	return api.ErrorCode_ERR_SUCCESS, infos
+}
+
+// toIdentityInfo 转换为协议结构，不下发平台唯一标识
+func toIdentityInfo(ident *model.UserIdentity) *api.IdentityInfo {
+	return &api.IdentityInfo{
+		LoginType: pb.LoginType(ident.LoginType),
+		Nickname:  ident.Nickname,
+		AvatarUrl: ident.AvatarURL,
+		BoundAt:   ident.BoundAt.Unix(),
+	}
+}
+
+// identityErrorCode 身份绑定业务错误映射为错误码
+func identityErrorCode(err error) api.ErrorCode {
+	switch {
+	case errors.Is(err, security.ErrTokenExpired):
+		return api.ErrorCode_ERR_TOKEN_EXPIRED
+	case errors.Is(err, security.ErrTokenInvalid):
+		return api.ErrorCode_ERR_TOKEN_INVALID
+	case errors.Is(err, manager.ErrIdentityBound):
+		return api.ErrorCode_ERR_IDENTITY_BOUND
+	case errors.Is(err, manager.ErrPlatformBound):
+		return api.ErrorCode_ERR_PLATFORM_BOUND
+	case errors.Is(err, manager.ErrIdentityNotBound):
+		return api.ErrorCode_ERR_NOT_FOUND
+	case errors.Is(err, manager.ErrLastIdentity):
+		return api.ErrorCode_ERR_LAST_IDENTITY
+	case errors.Is(err, manager.ErrNotGuest):
+		return api.ErrorCode_ERR_NOT_GUEST
+	}
+
+	// 本地账号凭证沿用账号错误码（锁定、停用等），其余平台凭证错误统一返回 ERR_INVALID_CREDENTIALS
+	if code := accountErrorCode(err); code != api.ErrorCode_ERR_INTERNAL {
+		return code
+	}
+	if errors.Is(err, errCredentialsInvalid) {
+		return api.ErrorCode_ERR_INVALID_CREDENTIALS
+	}
+	return api.ErrorCode_ERR_INTERNAL
+}
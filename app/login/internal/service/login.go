@@ -2,49 +2,55 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
 	api "github.com/lk2023060901/xdooria-proto-api"
+	"github.com/lk2023060901/xdooria/app/login/internal/manager"
 	"github.com/lk2023060901/xdooria/app/login/internal/metrics"
 	"github.com/lk2023060901/xdooria/component/auth"
-	"github.com/lk2023060901/xdooria/pkg/balancer"
-	"github.com/lk2023060901/xdooria/pkg/registry"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"github.com/lk2023060901/xdooria/pkg/network/session"
 	"github.com/lk2023060901/xdooria/pkg/router"
 	"github.com/lk2023060901/xdooria/pkg/security"
-	"github.com/lk2023060901/xdooria/pkg/util/conc"
 )
 
 type LoginService struct {
-	authMgr  *auth.Manager
-	jwtMgr   *security.JWTManager
-	metrics  *metrics.LoginMetrics
-	resolver registry.Resolver
-	balancer balancer.Balancer
-
-	// 网关缓存
-	gatewayMu    sync.RWMutex
-	gatewayNodes []*balancer.Node
-	watchCancel  context.CancelFunc
+	logger     logger.Logger
+	authMgr    *auth.Manager
+	identities *manager.IdentityManager
+	jwtMgr     *security.JWTManager
+	metrics    *metrics.LoginMetrics
+	gateways   *manager.GatewayManager
+	queue      *manager.QueueManager
+
+	// 本实例上等待放行的客户端（票据 -> 等待者）
+	waitersMu   sync.Mutex
+	waiters     map[string]*queueWaiter
+	queueCancel context.CancelFunc
 }
 
 func NewLoginService(
+	l logger.Logger,
 	authMgr *auth.Manager,
+	identities *manager.IdentityManager,
 	jwtMgr *security.JWTManager,
 	m *metrics.LoginMetrics,
-	r registry.Resolver,
-	b balancer.Balancer,
+	gateways *manager.GatewayManager,
+	queue *manager.QueueManager,
 ) *LoginService {
-	if b == nil {
-		b = balancer.New(balancer.RoundRobinName)
-	}
 	return &LoginService{
-		authMgr:  authMgr,
-		jwtMgr:   jwtMgr,
-		metrics:  m,
-		resolver: r,
-		balancer: b,
+		logger:     l.Named("service.login"),
+		authMgr:    authMgr,
+		identities: identities,
+		jwtMgr:     jwtMgr,
+		metrics:    m,
+		gateways:   gateways,
+		queue:      queue,
+		waiters:    make(map[string]*queueWaiter),
 	}
 }
 
@@ -53,79 +59,17 @@ func (s *LoginService) Init(r router.Router) {
 	router.RegisterHandler(r, uint32(api.OpCode_OP_LOGIN_REQ), uint32(api.OpCode_OP_LOGIN_RES), s.Login)
 
 	// 启动网关服务监听
-	s.startGatewayWatch()
-}
-
-// startGatewayWatch 启动网关服务监听
-func (s *LoginService) startGatewayWatch() {
-	if s.resolver == nil {
-		return
-	}
-
-	ctx, cancel := context.WithCancel(context.Background())
-	s.watchCancel = cancel
-
-	// 先同步获取一次
-	if gateways, err := s.resolver.Resolve(ctx, "gateway"); err == nil {
-		s.updateGatewayNodes(gateways)
-	}
+	s.gateways.Start()
 
-	// 启动 Watch
-	ch, err := s.resolver.Watch(ctx, "gateway")
-	if err != nil {
-		return
-	}
-
-	conc.Go(func() (struct{}, error) {
-		for {
-			select {
-			case <-ctx.Done():
-				return struct{}{}, nil
-			case gateways, ok := <-ch:
-				if !ok {
-					return struct{}{}, nil
-				}
-				s.updateGatewayNodes(gateways)
-			}
-		}
-	})
-}
-
-// updateGatewayNodes 更新网关节点缓存
-func (s *LoginService) updateGatewayNodes(gateways []*registry.ServiceInfo) {
-	nodes := make([]*balancer.Node, len(gateways))
-	for i, gw := range gateways {
-		nodes[i] = &balancer.Node{
-			Address:  gw.Address,
-			Metadata: gw.Metadata,
-		}
-	}
-
-	s.gatewayMu.Lock()
-	s.gatewayNodes = nodes
-	s.gatewayMu.Unlock()
-}
-
-// pickGateway 选择网关
-func (s *LoginService) pickGateway() string {
-	s.gatewayMu.RLock()
-	nodes := s.gatewayNodes
-	s.gatewayMu.RUnlock()
-
-	if len(nodes) == 0 {
-		return ""
-	}
-
-	if node := s.balancer.Pick(nodes, balancer.PickInfo{}); node != nil {
-		return node.Address
-	}
-	return ""
+	// 登录排队
+	s.initQueue(r)
 }
 
 // Close 关闭服务
 func (s *LoginService) Close() {
-	if s.watchCancel != nil {
-		s.watchCancel()
+	s.gateways.Close()
+	if s.queueCancel != nil {
+		s.queueCancel()
 	}
 }
 
@@ -143,35 +87,79 @@ func (s *LoginService) Login(ctx context.Context, req *api.LoginRequest) (*api.L
 		return nil, fmt.Errorf("authentication failed: %w", err)
 	}
 
-	// 2. 生成 JWT Token
-	claims := &security.Claims{
-		Payload: map[string]any{
-			"uid": identity.UID,
-		},
+	// 2. 平台身份映射为内部 UID（首次登录时分配）
+	ident, err := s.identities.Resolve(ctx, loginType, identity)
+	if err != nil {
+		duration := time.Since(start).Seconds()
+		s.metrics.RecordLogin(loginTypeStr, false, duration)
+		s.metrics.RecordAuthFailure(loginTypeStr, "identity_resolve_failed")
+		return nil, fmt.Errorf("failed to resolve identity: %w", err)
 	}
-	token, err := s.jwtMgr.GenerateToken(claims)
+
+	// 3. 区服达到在线上限时排队，返回票据，放行后推送 Token 与网关
+	entered, err := s.queue.Enter(ctx, req.ZoneId, ident.UID, identity.Nickname)
 	if err != nil {
+		reason := "queue_enter_failed"
+		if errors.Is(err, manager.ErrZoneInvalid) {
+			reason = "zone_invalid"
+		}
 		duration := time.Since(start).Seconds()
 		s.metrics.RecordLogin(loginTypeStr, false, duration)
-		s.metrics.RecordAuthFailure(loginTypeStr, "token_generate_failed")
-		return nil, fmt.Errorf("failed to generate token: %w", err)
+		s.metrics.RecordAuthFailure(loginTypeStr, reason)
+		return nil, fmt.Errorf("failed to enter login queue: %w", err)
 	}
+	if !entered.Admitted {
+		duration := time.Since(start).Seconds()
+		s.metrics.RecordLogin(loginTypeStr, true, duration)
 
-	// 3. 分配网关（从缓存中轮询选择）
-	gatewayAddr := s.pickGateway()
+		if sess, ok := session.FromContext(ctx); ok {
+			s.attach(sess, entered.Ticket)
+		}
+		return &api.LoginResponse{
+			Uid:      uint64(ident.UID),
+			Nickname: identity.Nickname,
+			Queue:    s.queueStatus(entered),
+		}, nil
+	}
+	// 排队中重复登录且已被放行，直接下发并删除原票据
+	if entered.Ticket != "" {
+		s.leave(ctx, entered.Ticket)
+	}
+
+	// 4. 生成 JWT Token 并分配区服网关
+	resp, err := s.issue(ident.UID, identity.Nickname, req.ZoneId)
+	if err != nil {
+		duration := time.Since(start).Seconds()
+		s.metrics.RecordLogin(loginTypeStr, false, duration)
+		s.metrics.RecordAuthFailure(loginTypeStr, "token_generate_failed")
+		return nil, err
+	}
 
 	// 记录成功指标
 	duration := time.Since(start).Seconds()
 	s.metrics.RecordLogin(loginTypeStr, true, duration)
 
-	// 4. 返回结果
-	var uid uint64
-	fmt.Sscanf(identity.UID, "%d", &uid)
+	// 5. 返回结果
+	return resp, nil
+}
+
+// issue 为放行的玩家生成 JWT Token 并分配区服网关，Token 绑定区服，Gateway 只接受本区服的 Token
+func (s *LoginService) issue(uid int64, nickname string, zoneID int32) (*api.LoginResponse, error) {
+	claims := &security.Claims{
+		Payload: map[string]any{
+			"uid":     strconv.FormatInt(uid, 10),
+			"zone_id": strconv.FormatInt(int64(zoneID), 10),
+		},
+	}
+	token, err := s.jwtMgr.GenerateToken(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
 
 	return &api.LoginResponse{
 		Token:       token,
-		Uid:         uid,
-		Nickname:    identity.Nickname,
-		GatewayAddr: gatewayAddr,
+		Uid:         uint64(uid),
+		Nickname:    nickname,
+		GatewayAddr: s.gateways.Pick(zoneID),
 	}, nil
 }
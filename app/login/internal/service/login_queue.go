@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	api "github.com/lk2023060901/xdooria-proto-api"
+	common "github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/app/login/internal/manager"
+	"github.com/lk2023060901/xdooria/app/login/internal/model"
+	"github.com/lk2023060901/xdooria/pkg/network/session"
+	"github.com/lk2023060901/xdooria/pkg/router"
+	"github.com/lk2023060901/xdooria/pkg/util/conc"
+	"google.golang.org/protobuf/proto"
+)
+
+// queueWaiter 本实例上等待放行的客户端
+type queueWaiter struct {
+	sess       session.Session
+	notifiedAt time.Time // 最近一次推送排队位置的时间
+}
+
+// initQueue 注册排队路由并启动放行循环
+func (s *LoginService) initQueue(r router.Router) {
+	router.RegisterHandler(r, uint32(api.OpCode_OP_LOGIN_QUEUE_RESUME_REQ), uint32(api.OpCode_OP_LOGIN_QUEUE_RESUME_RES), s.ResumeQueue)
+	router.RegisterHandler(r, uint32(api.OpCode_OP_LOGIN_QUEUE_CANCEL_REQ), uint32(api.OpCode_OP_LOGIN_QUEUE_CANCEL_RES), s.CancelQueue)
+
+	if !s.queue.Enabled() {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.queueCancel = cancel
+
+	conc.Go(func() (struct{}, error) {
+		ticker := time.NewTicker(s.queue.AdmitInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return struct{}{}, nil
+			case <-ticker.C:
+				s.processQueue(ctx)
+			}
+		}
+	})
+}
+
+// ResumeQueue 客户端断线重连后凭票据恢复排队；票据已放行时直接返回登录结果
+func (s *LoginService) ResumeQueue(ctx context.Context, req *api.LoginQueueResumeRequest) (*api.LoginQueueResumeResponse, error) {
+	st, err := s.queue.Get(ctx, req.Ticket)
+	if err != nil {
+		s.logger.Warn("resume login queue failed", "error", err)
+		return &api.LoginQueueResumeResponse{Code: queueErrorCode(err)}, nil
+	}
+
+	if st.Admitted {
+		resp, err := s.issue(st.UID, st.Nickname, st.ZoneID)
+		if err != nil {
+			s.logger.Error("failed to issue admitted login", "uid", st.UID, "error", err)
+			return &api.LoginQueueResumeResponse{Code: api.ErrorCode_ERR_INTERNAL}, nil
+		}
+		s.leave(ctx, req.Ticket)
+		return &api.LoginQueueResumeResponse{Code: api.ErrorCode_ERR_SUCCESS, Result: resp}, nil
+	}
+
+	if sess, ok := session.FromContext(ctx); ok {
+		s.attach(sess, req.Ticket)
+	}
+	return &api.LoginQueueResumeResponse{Code: api.ErrorCode_ERR_SUCCESS, Queue: s.queueStatus(st)}, nil
+}
+
+// CancelQueue 客户端主动放弃排队
+func (s *LoginService) CancelQueue(ctx context.Context, req *api.LoginQueueCancelRequest) (*api.LoginQueueCancelResponse, error) {
+	s.detach(req.Ticket, nil)
+	if err := s.queue.Leave(ctx, req.Ticket); err != nil {
+		s.logger.Warn("cancel login queue failed", "error", err)
+		return &api.LoginQueueCancelResponse{Code: queueErrorCode(err)}, nil
+	}
+	return &api.LoginQueueCancelResponse{Code: api.ErrorCode_ERR_SUCCESS}, nil
+}
+
+// processQueue 放行队首，然后处理本实例上的等待者：已放行的推送 Token 与网关，其余按间隔推送排队位置
+func (s *LoginService) processQueue(ctx context.Context) {
+	s.queue.Admit(ctx)
+
+	waiters := s.activeWaiters()
+	if len(waiters) == 0 {
+		return
+	}
+
+	tickets := make([]string, 0, len(waiters))
+	for ticket := range waiters {
+		tickets = append(tickets, ticket)
+	}
+	// 查询同时为票据续期，客户端断开后不再续期，超过放弃时间由 Redis 脚本清理
+	statuses, err := s.queue.Status(ctx, tickets, true)
+	if err != nil {
+		s.logger.Warn("failed to get login queue status", "waiters", len(waiters), "error", err)
+		return
+	}
+
+	now := time.Now()
+	for ticket, w := range waiters {
+		st, ok := statuses[ticket]
+		switch {
+		case !ok:
+			// 票据已取消或被清理
+			s.detach(ticket, w)
+		case st.Admitted:
+			s.deliver(ctx, ticket, w, st)
+		case now.Sub(w.notifiedAt) >= s.queue.NotifyInterval():
+			if err := s.push(w.sess, &api.LoginQueueNotify{Queue: s.queueStatus(st)}); err != nil {
+				s.logger.Debug("failed to push login queue position", "session_id", w.sess.ID(), "error", err)
+				continue
+			}
+			w.notifiedAt = now
+		}
+	}
+}
+
+// deliver 向已放行的客户端推送 Token 与网关，成功后删除票据；推送失败时保留票据，客户端可凭票据恢复
+func (s *LoginService) deliver(ctx context.Context, ticket string, w *queueWaiter, st *model.QueueTicket) {
+	resp, err := s.issue(st.UID, st.Nickname, st.ZoneID)
+	if err != nil {
+		s.logger.Error("failed to issue admitted login", "uid", st.UID, "error", err)
+		return
+	}
+	if err := s.push(w.sess, &api.LoginQueueNotify{Result: resp}); err != nil {
+		s.logger.Warn("failed to push admitted login", "uid", st.UID, "session_id", w.sess.ID(), "error", err)
+		return
+	}
+
+	s.detach(ticket, w)
+	s.leave(ctx, ticket)
+	s.logger.Debug("admitted login delivered", "uid", st.UID, "zone_id", st.ZoneID)
+}
+
+// push 向客户端推送排队通知
+func (s *LoginService) push(sess session.Session, notify *api.LoginQueueNotify) error {
+	payload, err := proto.Marshal(notify)
+	if err != nil {
+		return fmt.Errorf("marshal login queue notify failed: %w", err)
+	}
+
+	env := &common.Envelope{
+		Header: &common.MessageHeader{
+			Op: uint32(api.OpCode_OP_LOGIN_QUEUE_NOTIFY),
+		},
+		Payload: payload,
+	}
+	return sess.Send(sess.Context(), env)
+}
+
+// attach 登记等待放行的客户端，同一票据在新连接上恢复时替换旧连接
+func (s *LoginService) attach(sess session.Session, ticket string) {
+	s.waitersMu.Lock()
+	defer s.waitersMu.Unlock()
+	s.waiters[ticket] = &queueWaiter{
+		sess:       sess,
+		notifiedAt: time.Now(),
+	}
+}
+
+// detach 移除等待者，w 非 nil 时只在仍是同一等待者时移除（避免误删恢复后的新连接）
+func (s *LoginService) detach(ticket string, w *queueWaiter) {
+	s.waitersMu.Lock()
+	defer s.waitersMu.Unlock()
+	if cur, ok := s.waiters[ticket]; ok && (w == nil || cur == w) {
+		delete(s.waiters, ticket)
+	}
+}
+
+// activeWaiters 返回连接仍然存活的等待者，已断开的移除（票据保留到放弃时间，期间可恢复）
+func (s *LoginService) activeWaiters() map[string]*queueWaiter {
+	s.waitersMu.Lock()
+	defer s.waitersMu.Unlock()
+
+	active := make(map[string]*queueWaiter, len(s.waiters))
+	for ticket, w := range s.waiters {
+		if w.sess.Context().Err() != nil {
+			delete(s.waiters, ticket)
+			continue
+		}
+		active[ticket] = w
+	}
+	return active
+}
+
+// leave 删除已下发 Token 的票据，失败时由放弃时间兜底清理
+func (s *LoginService) leave(ctx context.Context, ticket string) {
+	if err := s.queue.Leave(ctx, ticket); err != nil && !errors.Is(err, manager.ErrTicketInvalid) {
+		s.logger.Warn("failed to remove login queue ticket", "error", err)
+	}
+}
+
+// queueStatus 构造排队状态
+func (s *LoginService) queueStatus(st *model.QueueTicket) *api.LoginQueueStatus {
+	return &api.LoginQueueStatus{
+		Ticket:        st.Ticket,
+		ZoneId:        st.ZoneID,
+		Position:      st.Position,
+		QueueLength:   st.QueueLength,
+		EstimatedWait: int32(s.queue.EstimatedWait(st) / time.Second),
+	}
+}
+
+// queueErrorCode 将排队错误映射为错误码
+func queueErrorCode(err error) api.ErrorCode {
+	if errors.Is(err, manager.ErrTicketInvalid) {
+		return api.ErrorCode_ERR_QUEUE_TICKET_INVALID
+	}
+	return api.ErrorCode_ERR_INTERNAL
+}
@@ -14,8 +14,8 @@ import (
 	"github.com/lk2023060901/xdooria/app/robot/internal/client"
 	"github.com/lk2023060901/xdooria/pkg/logger"
 	"github.com/lk2023060901/xdooria/pkg/network/framer"
+	"github.com/lk2023060901/xdooria/pkg/network/session"
 	"github.com/lk2023060901/xdooria/pkg/security"
-	"google.golang.org/protobuf/proto"
 )
 
 var (
@@ -24,6 +24,7 @@ var (
 	createRole  = flag.Bool("create", false, "是否创建新角色")
 	nickname    = flag.String("nickname", "TestRobot", "角色昵称")
 	jwtSecret   = flag.String("jwt-secret", "xdooria-secret-key-123456", "JWT 密钥（需与 Gateway 配置一致）")
+	keyExchange = flag.Bool("key-exchange", false, "是否协商会话密钥（需与 Gateway 配置一致）")
 )
 
 func main() {
@@ -44,6 +45,7 @@ func main() {
 		EnableEncrypt:    false,
 		EnableCompress:   false,
 		CompressMinBytes: 1024,
+		KeyExchange:      framer.KeyExchangeConfig{Enabled: *keyExchange},
 	}
 
 	robot, err := client.NewRobot(*gatewayAddr, framerCfg)
@@ -127,21 +129,13 @@ func runFullWorkflow(ctx context.Context, robot *client.Robot, l logger.Logger,
 	// 2. 发送认证请求
 	l.Info("发送认证请求", "token", loginToken)
 	authReq := &api.AuthRequest{LoginToken: loginToken}
-	payload, _ := proto.Marshal(authReq)
-	if err := robot.Send(uint32(api.OpCode_OP_AUTH_REQ), payload); err != nil {
-		return fmt.Errorf("发送认证请求失败: %w", err)
-	}
 
-	// 3. 等待认证响应
-	env, err := robot.Recv(10 * time.Second)
+	// 3. 等待认证响应（请求与响应通过关联 ID 匹配）
+	authResp, err := session.Invoke[api.AuthResponse](ctx, robot,
+		uint32(api.OpCode_OP_AUTH_REQ), uint32(api.OpCode_OP_AUTH_RES), authReq)
 	if err != nil {
-		return fmt.Errorf("接收认证响应失败: %w", err)
-	}
-	if env.Header.Op != uint32(api.OpCode_OP_AUTH_RES) {
-		return fmt.Errorf("收到意外的响应: op=%d", env.Header.Op)
+		return fmt.Errorf("认证请求失败: %w", err)
 	}
-	authResp := &api.AuthResponse{}
-	proto.Unmarshal(env.Payload, authResp)
 	if authResp.Code != uint32(common.ErrCode_ERR_CODE_OK) {
 		return fmt.Errorf("认证失败: code=%d", authResp.Code)
 	}
@@ -150,20 +144,11 @@ func runFullWorkflow(ctx context.Context, robot *client.Robot, l logger.Logger,
 	// 4. 获取角色列表
 	l.Info("发送获取角色列表请求")
 	getRolesReq := &api.GetRolesRequest{}
-	payload, _ = proto.Marshal(getRolesReq)
-	if err := robot.Send(uint32(api.OpCode_OP_GET_ROLES_REQ), payload); err != nil {
-		return fmt.Errorf("发送获取角色列表请求失败: %w", err)
-	}
-
-	env, err = robot.Recv(10 * time.Second)
+	getRolesResp, err := session.Invoke[api.GetRolesResponse](ctx, robot,
+		uint32(api.OpCode_OP_GET_ROLES_REQ), uint32(api.OpCode_OP_GET_ROLES_RES), getRolesReq)
 	if err != nil {
-		return fmt.Errorf("接收角色列表响应失败: %w", err)
+		return fmt.Errorf("获取角色列表请求失败: %w", err)
 	}
-	if env.Header.Op != uint32(api.OpCode_OP_GET_ROLES_RES) {
-		return fmt.Errorf("收到意外的响应: op=%d", env.Header.Op)
-	}
-	getRolesResp := &api.GetRolesResponse{}
-	proto.Unmarshal(env.Payload, getRolesResp)
 	if getRolesResp.Code != uint32(common.ErrCode_ERR_CODE_OK) {
 		return fmt.Errorf("获取角色列表失败: code=%d", getRolesResp.Code)
 	}
@@ -180,20 +165,11 @@ func runFullWorkflow(ctx context.Context, robot *client.Robot, l logger.Logger,
 			Gender:     1,
 			Appearance: "{}",
 		}
-		payload, _ = proto.Marshal(createReq)
-		if err := robot.Send(uint32(api.OpCode_OP_CREATE_ROLE_REQ), payload); err != nil {
-			return fmt.Errorf("发送创建角色请求失败: %w", err)
-		}
-
-		env, err = robot.Recv(10 * time.Second)
+		createResp, err := session.Invoke[api.CreateRoleResponse](ctx, robot,
+			uint32(api.OpCode_OP_CREATE_ROLE_REQ), uint32(api.OpCode_OP_CREATE_ROLE_RES), createReq)
 		if err != nil {
-			return fmt.Errorf("接收创建角色响应失败: %w", err)
+			return fmt.Errorf("创建角色请求失败: %w", err)
 		}
-		if env.Header.Op != uint32(api.OpCode_OP_CREATE_ROLE_RES) {
-			return fmt.Errorf("收到意外的响应: op=%d", env.Header.Op)
-		}
-		createResp := &api.CreateRoleResponse{}
-		proto.Unmarshal(env.Payload, createResp)
 		if createResp.Code != uint32(common.ErrCode_ERR_CODE_OK) {
 			return fmt.Errorf("创建角色失败: code=%d", createResp.Code)
 		}
@@ -207,20 +183,11 @@ func runFullWorkflow(ctx context.Context, robot *client.Robot, l logger.Logger,
 
 	// 发送选择角色请求
 	selectReq := &api.SelectRoleRequest{RoleId: roleID}
-	payload, _ = proto.Marshal(selectReq)
-	if err := robot.Send(uint32(api.OpCode_OP_SELECT_ROLE_REQ), payload); err != nil {
-		return fmt.Errorf("发送选择角色请求失败: %w", err)
-	}
-
-	env, err = robot.Recv(10 * time.Second)
+	selectResp, err := session.Invoke[api.SelectRoleResponse](ctx, robot,
+		uint32(api.OpCode_OP_SELECT_ROLE_REQ), uint32(api.OpCode_OP_SELECT_ROLE_RES), selectReq)
 	if err != nil {
-		return fmt.Errorf("接收选择角色响应失败: %w", err)
-	}
-	if env.Header.Op != uint32(api.OpCode_OP_SELECT_ROLE_RES) {
-		return fmt.Errorf("收到意外的响应: op=%d", env.Header.Op)
+		return fmt.Errorf("选择角色请求失败: %w", err)
 	}
-	selectResp := &api.SelectRoleResponse{}
-	proto.Unmarshal(env.Payload, selectResp)
 	if selectResp.Code != uint32(common.ErrCode_ERR_CODE_OK) {
 		return fmt.Errorf("选择角色失败: code=%d", selectResp.Code)
 	}
@@ -229,20 +196,11 @@ func runFullWorkflow(ctx context.Context, robot *client.Robot, l logger.Logger,
 	// 6. 进入场景
 	l.Info("发送进入场景请求")
 	enterReq := &api.EnterSceneRequest{}
-	payload, _ = proto.Marshal(enterReq)
-	if err := robot.Send(uint32(api.OpCode_OP_ENTER_SCENE_REQ), payload); err != nil {
-		return fmt.Errorf("发送进入场景请求失败: %w", err)
-	}
-
-	env, err = robot.Recv(10 * time.Second)
+	enterResp, err := session.Invoke[api.EnterSceneResponse](ctx, robot,
+		uint32(api.OpCode_OP_ENTER_SCENE_REQ), uint32(api.OpCode_OP_ENTER_SCENE_RES), enterReq)
 	if err != nil {
-		return fmt.Errorf("接收进入场景响应失败: %w", err)
-	}
-	if env.Header.Op != uint32(api.OpCode_OP_ENTER_SCENE_RES) {
-		return fmt.Errorf("收到意外的响应: op=%d", env.Header.Op)
+		return fmt.Errorf("进入场景请求失败: %w", err)
 	}
-	enterResp := &api.EnterSceneResponse{}
-	proto.Unmarshal(env.Payload, enterResp)
 	if enterResp.Code != common.ErrCode_ERR_CODE_OK {
 		return fmt.Errorf("进入场景失败: code=%d", enterResp.Code)
 	}
@@ -1,7 +1,10 @@
 package client
 
 import (
+	"context"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"net"
 	"sync"
 	"time"
@@ -9,19 +12,27 @@ import (
 	common "github.com/lk2023060901/xdooria-proto-common"
 	"github.com/lk2023060901/xdooria/pkg/logger"
 	"github.com/lk2023060901/xdooria/pkg/network/framer"
+	"github.com/lk2023060901/xdooria/pkg/network/session"
+	"github.com/lk2023060901/xdooria/pkg/network/tcp"
 	"google.golang.org/protobuf/proto"
 )
 
 // Robot TCP 客户端
 type Robot struct {
-	logger  logger.Logger
-	framer  framer.Framer
-	addr    string
-
-	mu       sync.RWMutex
-	conn     net.Conn
+	logger    logger.Logger
+	base      framer.Framer // 进程级 Framer（静态密钥）
+	framer    framer.Framer // 当前连接使用的 Framer
+	framerCfg *framer.Config
+	codec     *tcp.Codec
+	addr      string
+
+	mu        sync.RWMutex
+	conn      net.Conn
 	connected bool
 
+	// 请求/响应调用表，响应不进入接收队列
+	rpc *session.RPC
+
 	// 接收队列
 	recvChan chan *common.Envelope
 	stopCh   chan struct{}
@@ -35,11 +46,15 @@ func NewRobot(addr string, framerCfg *framer.Config) (*Robot, error) {
 	}
 
 	return &Robot{
-		logger:   logger.Default().Named("robot.client"),
-		framer:   f,
-		addr:     addr,
-		recvChan: make(chan *common.Envelope, 100),
-		stopCh:   make(chan struct{}),
+		logger:    logger.Default().Named("robot.client"),
+		base:      f,
+		framer:    f,
+		framerCfg: framerCfg,
+		codec:     tcp.NewCodec(0),
+		addr:      addr,
+		rpc:       session.NewRPC(session.DefaultRPCTimeout),
+		recvChan:  make(chan *common.Envelope, 100),
+		stopCh:    make(chan struct{}),
 	}, nil
 }
 
@@ -60,13 +75,55 @@ func (r *Robot) Connect() error {
 	r.conn = conn
 	r.connected = true
 
+	// 每个连接使用独立的序列号与防重放窗口；启用会话密钥协商时还使用独立的会话密钥
+	fr, err := framer.ForSession(r.base)
+	if err != nil {
+		r.conn.Close()
+		r.connected = false
+		return fmt.Errorf("create session framer failed: %w", err)
+	}
+	r.framer = fr
+	var sf *framer.SessionFramer
+	if r.framerCfg != nil && r.framerCfg.KeyExchange.Enabled {
+		sf = framer.NewSession(fr, r.framerCfg)
+		r.framer = sf
+	}
+
 	// 启动接收循环
-	go r.recvLoop()
+	go r.recvLoop(conn)
+
+	if sf != nil {
+		if err := r.handshake(sf); err != nil {
+			r.conn.Close()
+			r.connected = false
+			return fmt.Errorf("handshake failed: %w", err)
+		}
+	}
 
 	r.logger.Info("connected to server", "addr", r.addr)
 	return nil
 }
 
+// handshake 发送握手请求并等待响应，完成后才能发送业务消息（必须在持有 mu 锁时调用）
+func (r *Robot) handshake(sf *framer.SessionFramer) error {
+	clientPub, err := sf.Initiate()
+	if err != nil {
+		return err
+	}
+	if err := r.writeMessage(r.conn, uint32(common.OpCode_OP_HANDSHAKE_REQ), 0, clientPub); err != nil {
+		return err
+	}
+
+	select {
+	case <-sf.Done():
+		return nil
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("handshake timeout")
+	case <-r.stopCh:
+		return fmt.Errorf("connection closed")
+	}
+}
+
 // Close 关闭连接
 func (r *Robot) Close() error {
 	r.mu.Lock()
@@ -81,6 +138,7 @@ func (r *Robot) Close() error {
 	if r.conn != nil {
 		r.conn.Close()
 	}
+	r.rpc.Fail(rpcSender{r})
 
 	r.connected = false
 	r.logger.Info("disconnected from server")
@@ -90,6 +148,16 @@ func (r *Robot) Close() error {
 
 // Send 发送消息
 func (r *Robot) Send(op uint32, payload []byte) error {
+	return r.send(op, 0, payload)
+}
+
+// Call 发送请求并等待对应的响应，实现 session.Caller，可配合 session.Invoke 使用
+func (r *Robot) Call(ctx context.Context, op uint32, payload []byte) (*common.Envelope, error) {
+	return r.rpc.Call(ctx, rpcSender{r}, op, payload)
+}
+
+// send 发送消息，rpcId 为 0 表示普通消息
+func (r *Robot) send(op, rpcId uint32, payload []byte) error {
 	r.mu.RLock()
 	conn := r.conn
 	connected := r.connected
@@ -99,8 +167,27 @@ func (r *Robot) Send(op uint32, payload []byte) error {
 		return fmt.Errorf("not connected")
 	}
 
+	if err := r.writeMessage(conn, op, rpcId, payload); err != nil {
+		return err
+	}
+
+	r.logger.Debug("sent message", "op", op, "rpc_id", rpcId, "len", len(payload))
+	return nil
+}
+
+// rpcSender 将 Robot 适配为 session.Sender，供调用表发送带关联 ID 的请求
+type rpcSender struct {
+	r *Robot
+}
+
+func (s rpcSender) Send(_ context.Context, env *common.Envelope) error {
+	return s.r.send(env.GetHeader().GetOp(), env.GetHeader().GetRpcId(), env.GetPayload())
+}
+
+// writeMessage 编码消息并写入连接
+func (r *Robot) writeMessage(conn net.Conn, op, rpcId uint32, payload []byte) error {
 	// 使用 Framer 编码消息
-	env, err := r.framer.Encode(op, payload)
+	env, err := framer.EncodeRPC(r.framer, op, rpcId, payload)
 	if err != nil {
 		return fmt.Errorf("encode failed: %w", err)
 	}
@@ -111,12 +198,14 @@ func (r *Robot) Send(op uint32, payload []byte) error {
 		return fmt.Errorf("marshal failed: %w", err)
 	}
 
-	// 直接发送数据（不需要长度前缀，gnet 会处理分包）
-	if _, err := conn.Write(data); err != nil {
+	// 添加长度头，与 Gateway 的 TCP 帧格式一致
+	frame, err := r.codec.Encode(data)
+	if err != nil {
+		return fmt.Errorf("encode frame failed: %w", err)
+	}
+	if _, err := conn.Write(frame); err != nil {
 		return fmt.Errorf("write data failed: %w", err)
 	}
-
-	r.logger.Debug("sent message", "op", op, "len", len(payload))
 	return nil
 }
 
@@ -140,7 +229,8 @@ func (r *Robot) IsConnected() bool {
 }
 
 // recvLoop 接收循环
-func (r *Robot) recvLoop() {
+// conn 由 Connect 传入：握手期间 Connect 持有 mu 锁，这里不能再通过锁读取连接
+func (r *Robot) recvLoop(conn net.Conn) {
 	defer func() {
 		r.mu.Lock()
 		r.connected = false
@@ -148,12 +238,11 @@ func (r *Robot) recvLoop() {
 			r.conn.Close()
 		}
 		r.mu.Unlock()
+		// 连接断开后等待中的调用不会再收到响应
+		r.rpc.Fail(rpcSender{r})
 	}()
 
-	// 使用缓冲区读取 protobuf 数据
-	// 注意：由于 protobuf 是自描述格式，我们需要先peek长度或使用分隔符
-	// 这里简化处理：假设每次读取一个完整消息
-	buf := make([]byte, 65536) // 64KB 缓冲区
+	header := make([]byte, tcp.FrameHeaderSize)
 
 	for {
 		select {
@@ -162,25 +251,24 @@ func (r *Robot) recvLoop() {
 		default:
 		}
 
-		r.mu.RLock()
-		conn := r.conn
-		r.mu.RUnlock()
-
-		if conn == nil {
-			return
-		}
-
 		// 设置读取超时
 		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 
-		// 读取数据
-		n, err := conn.Read(buf)
-		if err != nil {
+		// 按长度头读取一个完整的帧
+		if _, err := io.ReadFull(conn, header); err != nil {
+			r.logger.Warn("read failed", "error", err)
+			return
+		}
+		size := binary.BigEndian.Uint32(header)
+		if uint64(size) > uint64(r.codec.MaxMessageSize()) {
+			r.logger.Error("frame too large", "size", size)
+			return
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(conn, data); err != nil {
 			r.logger.Warn("read failed", "error", err)
 			return
 		}
-
-		data := buf[:n]
 
 		// 反序列化 Envelope
 		env := &common.Envelope{}
@@ -196,21 +284,43 @@ func (r *Robot) recvLoop() {
 			continue
 		}
 
-		// 构建解码后的 Envelope
-		decodedEnv := &common.Envelope{
-			Header:  &common.MessageHeader{Op: op},
-			Payload: payload,
+		// 握手响应由 Framer 处理，不交给业务
+		if common.OpCode(op) == common.OpCode_OP_HANDSHAKE_RES {
+			if sf, ok := r.framer.(*framer.SessionFramer); ok {
+				if err := sf.Complete(payload); err != nil {
+					r.logger.Error("handshake failed", "error", err)
+					return
+				}
+			}
+			continue
 		}
 
-		r.logger.Info("received message", "op", op, "len", len(payload))
+		// 构建解码后的 Envelope，批量消息展开为多条
+		envs, err := session.Unbatch(&common.Envelope{
+			Header:  &common.MessageHeader{Op: op, RpcId: env.Header.RpcId},
+			Payload: payload,
+		})
+		if err != nil {
+			r.logger.Error("unbatch failed", "error", err)
+			continue
+		}
 
-		// 放入接收队列
-		select {
-		case r.recvChan <- decodedEnv:
-		case <-r.stopCh:
-			return
-		default:
-			r.logger.Warn("recv channel full, dropping message")
+		for _, decodedEnv := range envs {
+			r.logger.Info("received message", "op", decodedEnv.Header.Op, "len", len(decodedEnv.Payload))
+
+			// RPC 响应交给等待中的调用
+			if r.rpc.Deliver(rpcSender{r}, decodedEnv) {
+				continue
+			}
+
+			// 放入接收队列
+			select {
+			case r.recvChan <- decodedEnv:
+			case <-r.stopCh:
+				return
+			default:
+				r.logger.Warn("recv channel full, dropping message")
+			}
 		}
 	}
 }
@@ -8,7 +8,7 @@ import (
 
 // Identity 认证后的统一身份标识
 type Identity struct {
-	UID       string            // 平台唯一标识 (OpenID/UnionID)
+	UID       string            // 平台唯一标识 (OpenID/UnionID)，由 Login 服务映射为内部 UID
 	Nickname  string            // 平台昵称
 	AvatarUrl string            // 平台头像 URL
 	Extra     map[string]string // 平台特定扩展数据
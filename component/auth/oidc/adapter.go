@@ -0,0 +1,56 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// Adapter 平台适配器，处理各平台与标准 OIDC 流程的差异
+type Adapter interface {
+	// PrepareTokenRequest 在 code 换取 token 的请求发出前修改表单（如 Apple 的 client_secret 需为签名 JWT）
+	PrepareTokenRequest(ctx context.Context, form url.Values) error
+
+	// TransformClaims 在 claim 映射前调整 claims（如合并或改写平台专有字段），不需要时原样返回
+	TransformClaims(claims map[string]any) (map[string]any, error)
+}
+
+// AdapterFactory 根据平台配置创建适配器
+type AdapterFactory func(cfg *Config) (Adapter, error)
+
+var (
+	adaptersMu sync.RWMutex
+	adapters   = map[string]AdapterFactory{
+		defaultAdapter: func(*Config) (Adapter, error) { return standardAdapter{}, nil },
+		appleAdapter:   newAppleAdapter,
+	}
+)
+
+// RegisterAdapter 注册平台适配器，配置中通过 adapter 字段引用；同名时覆盖
+func RegisterAdapter(name string, factory AdapterFactory) {
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
+	adapters[name] = factory
+}
+
+// newAdapter 按名称创建适配器
+func newAdapter(cfg *Config) (Adapter, error) {
+	adaptersMu.RLock()
+	factory, ok := adapters[cfg.adapter()]
+	adaptersMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("oidc %q: adapter %q not registered", cfg.Name, cfg.adapter())
+	}
+	return factory(cfg)
+}
+
+// standardAdapter 标准 OIDC，不做任何调整
+type standardAdapter struct{}
+
+func (standardAdapter) PrepareTokenRequest(context.Context, url.Values) error { return nil }
+
+func (standardAdapter) TransformClaims(claims map[string]any) (map[string]any, error) {
+	return claims, nil
+}
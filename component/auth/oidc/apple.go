@@ -0,0 +1,109 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Sign in with Apple 适配器
+const (
+	appleAdapter = "apple"
+
+	// appleAudience client_secret JWT 的 aud
+	appleAudience = "https://appleid.apple.com"
+
+	// appleSecretTTL client_secret 有效期（Apple 允许最长 6 个月），提前 5 分钟重新签发
+	appleSecretTTL    = time.Hour
+	appleSecretRenew  = 5 * time.Minute
+	appleOptionTeamID = "team_id"
+	appleOptionKeyID  = "key_id"
+	appleOptionKey    = "private_key_file"
+)
+
+// appleAdapterImpl Apple 不使用固定的 client_secret，而是用开发者私钥签发的 ES256 JWT
+// options: team_id（开发者团队 ID）、key_id（私钥 ID）、private_key_file（.p8 私钥文件）
+type appleAdapterImpl struct {
+	teamID   string
+	keyID    string
+	clientID string
+	key      *ecdsa.PrivateKey
+	now      func() time.Time
+
+	mu        sync.Mutex
+	secret    string
+	expiresAt time.Time
+}
+
+func newAppleAdapter(cfg *Config) (Adapter, error) {
+	teamID, keyID, keyFile := cfg.Options[appleOptionTeamID], cfg.Options[appleOptionKeyID], cfg.Options[appleOptionKey]
+	if teamID == "" || keyID == "" || keyFile == "" {
+		return nil, fmt.Errorf("oidc %q: apple adapter requires options %s, %s and %s", cfg.Name, appleOptionTeamID, appleOptionKeyID, appleOptionKey)
+	}
+
+	pemBytes, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("oidc %q: failed to read apple private key: %w", cfg.Name, err)
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("oidc %q: failed to parse apple private key: %w", cfg.Name, err)
+	}
+
+	return &appleAdapterImpl{
+		teamID:   teamID,
+		keyID:    keyID,
+		clientID: cfg.ClientID,
+		key:      key,
+		now:      time.Now,
+	}, nil
+}
+
+// PrepareTokenRequest 使用签名 JWT 作为 client_secret
+func (a *appleAdapterImpl) PrepareTokenRequest(_ context.Context, form url.Values) error {
+	secret, err := a.clientSecret()
+	if err != nil {
+		return err
+	}
+	form.Set("client_secret", secret)
+	return nil
+}
+
+// TransformClaims Apple 的 ID Token 不含昵称与头像，使用标准映射即可
+func (a *appleAdapterImpl) TransformClaims(claims map[string]any) (map[string]any, error) {
+	return claims, nil
+}
+
+// clientSecret 返回缓存的 client_secret，临近过期时重新签发
+func (a *appleAdapterImpl) clientSecret() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	if a.secret != "" && now.Add(appleSecretRenew).Before(a.expiresAt) {
+		return a.secret, nil
+	}
+
+	expiresAt := now.Add(appleSecretTTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.RegisteredClaims{
+		Issuer:    a.teamID,
+		Subject:   a.clientID,
+		Audience:  jwt.ClaimStrings{appleAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	})
+	token.Header["kid"] = a.keyID
+
+	secret, err := token.SignedString(a.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign apple client secret: %w", err)
+	}
+	a.secret, a.expiresAt = secret, expiresAt
+	return secret, nil
+}
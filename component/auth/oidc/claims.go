@@ -0,0 +1,67 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lk2023060901/xdooria/component/auth"
+)
+
+// toIdentity 按 claim 映射构造身份，UID 为空时返回 ErrUIDClaimMissing
+func (m *ClaimMapping) toIdentity(claims map[string]any) (*auth.Identity, error) {
+	uid := claimString(claims, m.uid())
+	if uid == "" {
+		return nil, fmt.Errorf("%w: %q", ErrUIDClaimMissing, m.uid())
+	}
+
+	identity := &auth.Identity{
+		UID:       uid,
+		Nickname:  claimString(claims, m.nickname()),
+		AvatarUrl: claimString(claims, m.avatar()),
+	}
+	for key, path := range m.Extra {
+		if v := claimString(claims, path); v != "" {
+			if identity.Extra == nil {
+				identity.Extra = make(map[string]string, len(m.Extra))
+			}
+			identity.Extra[key] = v
+		}
+	}
+	return identity, nil
+}
+
+// claimValue 按 "." 分隔的路径读取 claim
+func claimValue(claims map[string]any, path string) (any, bool) {
+	var cur any = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// claimString 读取 claim 并转换为字符串，数字不使用科学计数法，对象与数组返回空串
+func claimString(claims map[string]any, path string) string {
+	v, ok := claimValue(claims, path)
+	if !ok {
+		return ""
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
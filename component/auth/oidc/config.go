@@ -0,0 +1,192 @@
+package oidc
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	pb "github.com/lk2023060901/xdooria-proto-common"
+)
+
+// 配置默认值
+const (
+	defaultAdapter             = "oidc"
+	defaultClockSkew           = time.Minute
+	defaultJWKSRefreshInterval = time.Hour
+	defaultHTTPTimeout         = 10 * time.Second
+
+	// TokenAuthMethodPost client_id/client_secret 放在表单中
+	TokenAuthMethodPost = "client_secret_post"
+	// TokenAuthMethodBasic client_id/client_secret 使用 HTTP Basic 认证
+	TokenAuthMethodBasic = "client_secret_basic"
+)
+
+// defaultAlgorithms 未配置时允许的 ID Token 签名算法
+var defaultAlgorithms = []string{"RS256", "ES256"}
+
+// Config 单个 OIDC/OAuth2 登录平台的配置
+type Config struct {
+	// Name 平台名称，用于日志
+	Name string `mapstructure:"name"`
+
+	// LoginType 对应的登录类型，填写 common.LoginType 枚举名（如 LOGIN_TYPE_GOOGLE）或数值
+	LoginType string `mapstructure:"login_type"`
+
+	// Adapter 平台适配器，未配置时为 "oidc"（标准 OIDC）
+	Adapter string `mapstructure:"adapter"`
+
+	// Issuer ID Token 签发者；token_url 或 jwks_url 未配置时从 {issuer}/.well-known/openid-configuration 发现
+	Issuer string `mapstructure:"issuer"`
+
+	// TokenURL code 换取 token 的端点
+	TokenURL string `mapstructure:"token_url"`
+
+	// UserInfoURL 用户信息端点；没有 ID Token 的 OAuth2 平台通过它获取用户标识
+	UserInfoURL string `mapstructure:"userinfo_url"`
+
+	// JWKSURL ID Token 签名公钥集合
+	JWKSURL string `mapstructure:"jwks_url"`
+
+	// ClientID / ClientSecret 在平台申请的应用凭证
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+
+	// TokenAuthMethod 换取 token 时的客户端认证方式：client_secret_post（默认）或 client_secret_basic
+	TokenAuthMethod string `mapstructure:"token_auth_method"`
+
+	// RedirectURL 换取 token 时的 redirect_uri，凭证中携带时以凭证为准
+	RedirectURL string `mapstructure:"redirect_url"`
+
+	// Audiences ID Token 允许的 aud，未配置时为 client_id（多个客户端使用不同 client_id 时需全部列出）
+	Audiences []string `mapstructure:"audiences"`
+
+	// Algorithms 允许的 ID Token 签名算法，未配置时为 RS256、ES256
+	Algorithms []string `mapstructure:"algorithms"`
+
+	// AllowIDToken 是否接受客户端 SDK 直接提交的 ID Token（不经过 code 换取）
+	AllowIDToken bool `mapstructure:"allow_id_token"`
+
+	// ClockSkew 校验 exp/iat/nbf 时允许的时钟偏差，未配置时为 1 分钟
+	ClockSkew time.Duration `mapstructure:"clock_skew"`
+
+	// JWKSRefreshInterval 公钥缓存时长，未配置时为 1 小时；遇到未知 kid 时提前刷新
+	JWKSRefreshInterval time.Duration `mapstructure:"jwks_refresh_interval"`
+
+	// HTTPTimeout 请求平台接口的超时，未配置时为 10 秒
+	HTTPTimeout time.Duration `mapstructure:"http_timeout"`
+
+	// Claims claim 到 auth.Identity 的映射
+	Claims ClaimMapping `mapstructure:"claims"`
+
+	// Options 适配器专用配置（如 apple 的 team_id、key_id、private_key_file）
+	Options map[string]string `mapstructure:"options"`
+}
+
+// ClaimMapping claim 到 auth.Identity 的映射，claim 名支持用 "." 访问嵌套字段（如 "data.openid"）
+type ClaimMapping struct {
+	// UID 平台唯一标识，未配置时为 sub
+	UID string `mapstructure:"uid"`
+
+	// Nickname 昵称，未配置时为 name
+	Nickname string `mapstructure:"nickname"`
+
+	// Avatar 头像 URL，未配置时为 picture
+	Avatar string `mapstructure:"avatar"`
+
+	// Extra 写入 Identity.Extra 的字段：Extra 键 -> claim 名
+	Extra map[string]string `mapstructure:"extra"`
+}
+
+// Validate 校验配置
+func (c *Config) Validate() error {
+	if c.ClientID == "" {
+		return fmt.Errorf("oidc %q: client_id is required", c.Name)
+	}
+	if c.Issuer == "" && c.TokenURL == "" {
+		return fmt.Errorf("oidc %q: issuer or token_url is required", c.Name)
+	}
+	switch c.TokenAuthMethod {
+	case "", TokenAuthMethodPost, TokenAuthMethodBasic:
+	default:
+		return fmt.Errorf("oidc %q: unsupported token_auth_method %q", c.Name, c.TokenAuthMethod)
+	}
+	if _, err := c.loginType(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// loginType 解析登录类型
+func (c *Config) loginType() (pb.LoginType, error) {
+	if v, ok := pb.LoginType_value[c.LoginType]; ok {
+		return pb.LoginType(v), nil
+	}
+	v, err := strconv.ParseInt(c.LoginType, 10, 32)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("oidc %q: invalid login_type %q", c.Name, c.LoginType)
+	}
+	return pb.LoginType(v), nil
+}
+
+func (c *Config) adapter() string {
+	if c.Adapter != "" {
+		return c.Adapter
+	}
+	return defaultAdapter
+}
+
+func (c *Config) audiences() []string {
+	if len(c.Audiences) > 0 {
+		return c.Audiences
+	}
+	return []string{c.ClientID}
+}
+
+func (c *Config) algorithms() []string {
+	if len(c.Algorithms) > 0 {
+		return c.Algorithms
+	}
+	return defaultAlgorithms
+}
+
+func (c *Config) clockSkew() time.Duration {
+	if c.ClockSkew > 0 {
+		return c.ClockSkew
+	}
+	return defaultClockSkew
+}
+
+func (c *Config) jwksRefreshInterval() time.Duration {
+	if c.JWKSRefreshInterval > 0 {
+		return c.JWKSRefreshInterval
+	}
+	return defaultJWKSRefreshInterval
+}
+
+func (c *Config) httpTimeout() time.Duration {
+	if c.HTTPTimeout > 0 {
+		return c.HTTPTimeout
+	}
+	return defaultHTTPTimeout
+}
+
+func (m *ClaimMapping) uid() string {
+	if m.UID != "" {
+		return m.UID
+	}
+	return "sub"
+}
+
+func (m *ClaimMapping) nickname() string {
+	if m.Nickname != "" {
+		return m.Nickname
+	}
+	return "name"
+}
+
+func (m *ClaimMapping) avatar() string {
+	if m.Avatar != "" {
+		return m.Avatar
+	}
+	return "picture"
+}
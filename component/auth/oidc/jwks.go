@@ -0,0 +1,204 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minJWKSRefetchInterval 遇到未知 kid 时两次刷新公钥的最小间隔，避免伪造 kid 的请求打满平台接口
+const minJWKSRefetchInterval = time.Minute
+
+// jwk 单个 JSON Web Key（RFC 7517），只解析签名校验需要的字段
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// keySet 带缓存的 JWKS：缓存过期或遇到未知 kid 时刷新，刷新失败时继续使用旧公钥
+type keySet struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+	now             func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+func newKeySet(url string, client *http.Client, refreshInterval time.Duration, now func() time.Time) *keySet {
+	return &keySet{
+		url:             url,
+		client:          client,
+		refreshInterval: refreshInterval,
+		now:             now,
+	}
+}
+
+// get 根据 kid 获取公钥；kid 为空且只有一个公钥时返回该公钥
+func (s *keySet) get(ctx context.Context, kid string) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	key, found := s.lookup(kid)
+	stale := s.keys == nil || now.Sub(s.fetchedAt) >= s.refreshInterval
+	if found && !stale {
+		return key, nil
+	}
+	if !found && !stale && now.Sub(s.fetchedAt) < minJWKSRefetchInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	if err := s.refresh(ctx, now); err != nil {
+		if found {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	if key, found := s.lookup(kid); found {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// lookup 在缓存中查找公钥，调用方持有锁
+func (s *keySet) lookup(kid string) (any, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// refresh 拉取并替换公钥集合，调用方持有锁
+func (s *keySet) refresh(ctx context.Context, now time.Time) error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := getJSON(ctx, s.client, s.url, "", &set); err != nil {
+		return fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// 跳过不支持的公钥，不影响其余公钥
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("jwks %s contains no usable signing keys", s.url)
+	}
+
+	s.keys = keys
+	s.fetchedAt = now
+	return nil
+}
+
+// publicKey 解析 RSA 或 EC 公钥
+func (k *jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URL(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid rsa key %q", k.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URL(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, fmt.Errorf("invalid ec key %q", k.Kid)
+		}
+		point := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// getJSON 发起 GET 请求并解析 JSON 响应，accessToken 非空时携带 Bearer 认证
+func getJSON(ctx context.Context, client *http.Client, url, accessToken string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", url, resp.StatusCode)
+	}
+	dec := json.NewDecoder(limitBody(resp))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("GET %s: failed to decode response: %w", url, err)
+	}
+	return nil
+}
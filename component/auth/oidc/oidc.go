@@ -0,0 +1,311 @@
+// Package oidc 通用 OAuth2/OIDC 认证器：code 换取 token、ID Token 校验（缓存 JWKS）、
+// 可配置的 claim 映射，以及处理平台差异的适配器。接入新的 OIDC/OAuth2 平台只需增加配置。
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	pb "github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/component/auth"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+	"google.golang.org/protobuf/proto"
+)
+
+// 认证错误
+var (
+	ErrCredentialMissing = errors.New("oidc: credential has neither code nor id token")
+	ErrIDTokenNotAllowed = errors.New("oidc: id token login not allowed")
+	ErrIDTokenInvalid    = errors.New("oidc: invalid id token")
+	ErrTokenExchange     = errors.New("oidc: token exchange failed")
+	ErrUserInfo          = errors.New("oidc: userinfo request failed")
+	ErrUIDClaimMissing   = errors.New("oidc: uid claim missing")
+)
+
+// maxResponseSize 平台接口响应体上限
+const maxResponseSize = 1 << 20
+
+// endpoints 平台端点，字段名与 OIDC Discovery 文档一致
+type endpoints struct {
+	Issuer      string `json:"issuer"`
+	TokenURL    string `json:"token_endpoint"`
+	UserInfoURL string `json:"userinfo_endpoint"`
+	JWKSURL     string `json:"jwks_uri"`
+}
+
+// tokenResponse code 换取 token 的响应
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Authenticator 通用 OIDC/OAuth2 认证器，实现 auth.Authenticator
+type Authenticator struct {
+	cfg       *Config
+	loginType pb.LoginType
+	adapter   Adapter
+	client    *http.Client
+	logger    logger.Logger
+	now       func() time.Time
+
+	// 端点与公钥在首次认证时发现，失败时下次认证重试，不影响服务启动
+	mu        sync.Mutex
+	endpoints *endpoints
+	keys      *keySet
+}
+
+// New 创建 OIDC 认证器
+func New(l logger.Logger, cfg *Config) (*Authenticator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	loginType, err := cfg.loginType()
+	if err != nil {
+		return nil, err
+	}
+	adapter, err := newAdapter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{
+		cfg:       cfg,
+		loginType: loginType,
+		adapter:   adapter,
+		client:    &http.Client{Timeout: cfg.httpTimeout()},
+		logger:    l.Named("auth.oidc").WithFields("provider", cfg.Name),
+		now:       time.Now,
+	}, nil
+}
+
+func (a *Authenticator) Type() pb.LoginType {
+	return a.loginType
+}
+
+func (a *Authenticator) Authenticate(ctx context.Context, cred []byte) (*auth.Identity, error) {
+	var oauthCred pb.OAuthCredential
+	if err := proto.Unmarshal(cred, &oauthCred); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal oauth credentials: %w", err)
+	}
+	return a.AuthenticateCredential(ctx, &oauthCred)
+}
+
+// AuthenticateCredential 使用授权码或 ID Token 认证
+// 授权码：换取 token，校验其中的 ID Token，配置了 userinfo 端点时补充用户信息
+// ID Token：需启用 allow_id_token，直接校验客户端 SDK 提交的 ID Token
+func (a *Authenticator) AuthenticateCredential(ctx context.Context, cred *pb.OAuthCredential) (*auth.Identity, error) {
+	// 1. 发现端点
+	ep, keys, err := a.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 获取 ID Token / Access Token
+	var idToken, accessToken string
+	switch {
+	case cred.Code != "":
+		tok, err := a.exchange(ctx, ep, cred)
+		if err != nil {
+			return nil, err
+		}
+		idToken, accessToken = tok.IDToken, tok.AccessToken
+	case cred.IdToken != "":
+		if !a.cfg.AllowIDToken {
+			return nil, ErrIDTokenNotAllowed
+		}
+		idToken = cred.IdToken
+	default:
+		return nil, ErrCredentialMissing
+	}
+
+	// 3. 校验 ID Token
+	claims := make(map[string]any)
+	if idToken != "" {
+		if claims, err = a.verifyIDToken(ctx, ep, keys, idToken, cred.Nonce); err != nil {
+			return nil, err
+		}
+	}
+
+	// 4. 用户信息（ID Token 中已有的字段不覆盖）
+	if accessToken != "" && ep.UserInfoURL != "" {
+		info, err := a.userInfo(ctx, ep, accessToken)
+		if err != nil {
+			return nil, err
+		}
+		if sub, infoSub := claimString(claims, "sub"), claimString(info, "sub"); sub != "" && infoSub != "" && sub != infoSub {
+			return nil, fmt.Errorf("%w: subject mismatch", ErrUserInfo)
+		}
+		maps.Copy(info, claims)
+		claims = info
+	}
+
+	// 5. 平台适配与 claim 映射
+	if claims, err = a.adapter.TransformClaims(claims); err != nil {
+		return nil, err
+	}
+	return a.cfg.Claims.toIdentity(claims)
+}
+
+// resolve 返回平台端点与公钥集合，按需通过 Discovery 补全未配置的端点
+func (a *Authenticator) resolve(ctx context.Context) (*endpoints, *keySet, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.endpoints != nil {
+		return a.endpoints, a.keys, nil
+	}
+
+	ep := &endpoints{
+		Issuer:      a.cfg.Issuer,
+		TokenURL:    a.cfg.TokenURL,
+		UserInfoURL: a.cfg.UserInfoURL,
+		JWKSURL:     a.cfg.JWKSURL,
+	}
+	if ep.Issuer != "" && (ep.TokenURL == "" || ep.JWKSURL == "") {
+		var doc endpoints
+		discoveryURL := strings.TrimSuffix(ep.Issuer, "/") + "/.well-known/openid-configuration"
+		if err := getJSON(ctx, a.client, discoveryURL, "", &doc); err != nil {
+			return nil, nil, fmt.Errorf("oidc discovery failed: %w", err)
+		}
+		if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(ep.Issuer, "/") {
+			return nil, nil, fmt.Errorf("oidc discovery issuer mismatch: %q", doc.Issuer)
+		}
+		ep.TokenURL = firstNonEmpty(ep.TokenURL, doc.TokenURL)
+		ep.UserInfoURL = firstNonEmpty(ep.UserInfoURL, doc.UserInfoURL)
+		ep.JWKSURL = firstNonEmpty(ep.JWKSURL, doc.JWKSURL)
+	}
+
+	a.endpoints = ep
+	if ep.JWKSURL != "" {
+		a.keys = newKeySet(ep.JWKSURL, a.client, a.cfg.jwksRefreshInterval(), a.now)
+	}
+	a.logger.Info("oidc endpoints resolved",
+		"token_url", ep.TokenURL,
+		"userinfo_url", ep.UserInfoURL,
+		"jwks_url", ep.JWKSURL,
+	)
+	return a.endpoints, a.keys, nil
+}
+
+// exchange 使用授权码换取 token
+func (a *Authenticator) exchange(ctx context.Context, ep *endpoints, cred *pb.OAuthCredential) (*tokenResponse, error) {
+	if ep.TokenURL == "" {
+		return nil, fmt.Errorf("%w: token endpoint not configured", ErrTokenExchange)
+	}
+
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {cred.Code},
+		"redirect_uri": {firstNonEmpty(cred.RedirectUri, a.cfg.RedirectURL)},
+		"client_id":    {a.cfg.ClientID},
+	}
+	if cred.CodeVerifier != "" {
+		form.Set("code_verifier", cred.CodeVerifier)
+	}
+	if a.cfg.ClientSecret != "" && a.cfg.TokenAuthMethod != TokenAuthMethodBasic {
+		form.Set("client_secret", a.cfg.ClientSecret)
+	}
+	if err := a.adapter.PrepareTokenRequest(ctx, form); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTokenExchange, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTokenExchange, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if a.cfg.TokenAuthMethod == TokenAuthMethodBasic {
+		req.SetBasicAuth(url.QueryEscape(a.cfg.ClientID), url.QueryEscape(a.cfg.ClientSecret))
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTokenExchange, err)
+	}
+	defer resp.Body.Close()
+
+	var tok tokenResponse
+	if err := json.NewDecoder(limitBody(resp)).Decode(&tok); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("%w: failed to decode response: %w", ErrTokenExchange, err)
+	}
+	if resp.StatusCode != http.StatusOK || tok.Error != "" {
+		a.logger.Warn("token exchange rejected",
+			"status", resp.StatusCode,
+			"error", tok.Error,
+			"error_description", tok.ErrorDescription,
+		)
+		return nil, fmt.Errorf("%w: status %d: %s", ErrTokenExchange, resp.StatusCode, tok.Error)
+	}
+	if tok.IDToken == "" && tok.AccessToken == "" {
+		return nil, fmt.Errorf("%w: response has neither id_token nor access_token", ErrTokenExchange)
+	}
+	return &tok, nil
+}
+
+// verifyIDToken 校验 ID Token 的签名、签发者、受众与有效期，nonce 非空时校验 nonce
+func (a *Authenticator) verifyIDToken(ctx context.Context, ep *endpoints, keys *keySet, raw, nonce string) (map[string]any, error) {
+	if keys == nil {
+		return nil, fmt.Errorf("%w: jwks endpoint not configured", ErrIDTokenInvalid)
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(a.cfg.algorithms()),
+		jwt.WithAudience(a.cfg.audiences()...),
+		jwt.WithLeeway(a.cfg.clockSkew()),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithJSONNumber(),
+		jwt.WithTimeFunc(a.now),
+	}
+	if ep.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(ep.Issuer))
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.NewParser(opts...).ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return keys.get(ctx, kid)
+	}); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrIDTokenInvalid, err)
+	}
+
+	if nonce != "" && claimString(claims, "nonce") != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrIDTokenInvalid)
+	}
+	return claims, nil
+}
+
+// userInfo 使用 access token 获取用户信息
+func (a *Authenticator) userInfo(ctx context.Context, ep *endpoints, accessToken string) (map[string]any, error) {
+	info := make(map[string]any)
+	if err := getJSON(ctx, a.client, ep.UserInfoURL, accessToken, &info); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUserInfo, err)
+	}
+	return info, nil
+}
+
+func limitBody(resp *http.Response) io.Reader {
+	return io.LimitReader(resp.Body, maxResponseSize)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,419 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	pb "github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/pkg/logger"
+)
+
+// mockIdP 本地模拟身份提供方：Discovery、JWKS、token 与 userinfo 端点
+type mockIdP struct {
+	t      *testing.T
+	server *httptest.Server
+
+	kid       string
+	key       *rsa.PrivateKey
+	jwksCalls atomic.Int32
+
+	// lastForm 最近一次 token 请求的表单
+	lastForm url.Values
+	// claims token 端点签发的 ID Token 额外 claims
+	claims jwt.MapClaims
+	// omitIDToken token 端点只返回 access token（纯 OAuth2 平台）
+	omitIDToken bool
+	userInfo    map[string]any
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	idp := &mockIdP{t: t}
+	idp.rotateKey("key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":            idp.server.URL,
+			"token_endpoint":    idp.server.URL + "/token",
+			"userinfo_endpoint": idp.server.URL + "/userinfo",
+			"jwks_uri":          idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.jwksCalls.Add(1)
+		pub := idp.key.PublicKey
+		writeJSON(w, map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": idp.kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse token form: %v", err)
+		}
+		idp.lastForm = r.PostForm
+		if r.PostForm.Get("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		resp := map[string]string{"access_token": "access-1", "token_type": "Bearer"}
+		if !idp.omitIDToken {
+			resp["id_token"] = idp.sign(idp.claims)
+		}
+		writeJSON(w, resp)
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, idp.userInfo)
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdP) rotateKey(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		idp.t.Fatalf("generate rsa key: %v", err)
+	}
+	idp.kid, idp.key = kid, key
+}
+
+// sign 签发 ID Token，默认 claims 可被 extra 覆盖，值为 nil 的 claim 会被删除
+func (idp *mockIdP) sign(extra jwt.MapClaims) string {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":  idp.server.URL,
+		"aud":  "client-1",
+		"sub":  "user-123",
+		"name": "Alice",
+		"iat":  now.Unix(),
+		"exp":  now.Add(time.Hour).Unix(),
+	}
+	for k, v := range extra {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = idp.kid
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		idp.t.Fatalf("sign id token: %v", err)
+	}
+	return signed
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func newTestAuthenticator(t *testing.T, cfg *Config) *Authenticator {
+	t.Helper()
+	if cfg.LoginType == "" {
+		cfg.LoginType = "100"
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = "client-1"
+	}
+	a, err := New(logger.Default(), cfg)
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+	return a
+}
+
+func TestAuthenticator_CodeExchange(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.claims = jwt.MapClaims{"email": "alice@example.com", "nonce": "n-1"}
+	idp.userInfo = map[string]any{"sub": "user-123", "name": "ignored", "picture": "https://cdn/alice.png"}
+
+	a := newTestAuthenticator(t, &Config{
+		Name:         "mock",
+		Issuer:       idp.server.URL,
+		ClientSecret: "secret-1",
+		RedirectURL:  "https://game/callback",
+		Claims:       ClaimMapping{Extra: map[string]string{"email": "email"}},
+	})
+	if a.Type() != pb.LoginType(100) {
+		t.Fatalf("login type = %v, want 100", a.Type())
+	}
+
+	identity, err := a.AuthenticateCredential(context.Background(), &pb.OAuthCredential{
+		Code:         "good-code",
+		CodeVerifier: "verifier-1",
+		Nonce:        "n-1",
+	})
+	if err != nil {
+		t.Fatalf("authenticate failed: %v", err)
+	}
+
+	// ID Token 中的字段优先，userinfo 补充缺失字段
+	if identity.UID != "user-123" || identity.Nickname != "Alice" || identity.AvatarUrl != "https://cdn/alice.png" {
+		t.Fatalf("identity = %+v", identity)
+	}
+	if identity.Extra["email"] != "alice@example.com" {
+		t.Fatalf("extra = %v, want email mapped", identity.Extra)
+	}
+
+	form := idp.lastForm
+	if form.Get("client_id") != "client-1" || form.Get("client_secret") != "secret-1" ||
+		form.Get("redirect_uri") != "https://game/callback" || form.Get("code_verifier") != "verifier-1" ||
+		form.Get("grant_type") != "authorization_code" {
+		t.Fatalf("token request form = %v", form)
+	}
+
+	// 授权码无效
+	if _, err := a.AuthenticateCredential(context.Background(), &pb.OAuthCredential{Code: "bad-code"}); !errors.Is(err, ErrTokenExchange) {
+		t.Fatalf("bad code err = %v, want ErrTokenExchange", err)
+	}
+	// nonce 不一致
+	if _, err := a.AuthenticateCredential(context.Background(), &pb.OAuthCredential{Code: "good-code", Nonce: "other"}); !errors.Is(err, ErrIDTokenInvalid) {
+		t.Fatalf("nonce mismatch err = %v, want ErrIDTokenInvalid", err)
+	}
+	if _, err := a.AuthenticateCredential(context.Background(), &pb.OAuthCredential{}); !errors.Is(err, ErrCredentialMissing) {
+		t.Fatalf("empty credential err = %v, want ErrCredentialMissing", err)
+	}
+}
+
+func TestAuthenticator_IDToken(t *testing.T) {
+	idp := newMockIdP(t)
+	ctx := context.Background()
+
+	cfg := &Config{Name: "mock", Issuer: idp.server.URL}
+	if _, err := newTestAuthenticator(t, cfg).AuthenticateCredential(ctx, &pb.OAuthCredential{IdToken: idp.sign(nil)}); !errors.Is(err, ErrIDTokenNotAllowed) {
+		t.Fatalf("id token without allow_id_token err = %v, want ErrIDTokenNotAllowed", err)
+	}
+
+	a := newTestAuthenticator(t, &Config{
+		Name:         "mock",
+		Issuer:       idp.server.URL,
+		AllowIDToken: true,
+		Audiences:    []string{"client-1", "ios-client"},
+	})
+	identity, err := a.AuthenticateCredential(ctx, &pb.OAuthCredential{IdToken: idp.sign(jwt.MapClaims{"aud": "ios-client"})})
+	if err != nil || identity.UID != "user-123" {
+		t.Fatalf("id token login = %+v, %v", identity, err)
+	}
+
+	now := time.Now()
+	cases := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{"wrong audience", jwt.MapClaims{"aud": "other-client"}},
+		{"wrong issuer", jwt.MapClaims{"iss": "https://evil.example.com"}},
+		{"expired", jwt.MapClaims{"exp": now.Add(-time.Hour).Unix()}},
+		{"missing exp", jwt.MapClaims{"exp": nil}},
+		{"issued in future", jwt.MapClaims{"iat": now.Add(time.Hour).Unix()}},
+		{"missing sub", jwt.MapClaims{"sub": nil}},
+	}
+	for _, c := range cases {
+		if _, err := a.AuthenticateCredential(ctx, &pb.OAuthCredential{IdToken: idp.sign(c.claims)}); err == nil {
+			t.Fatalf("%s: expected error", c.name)
+		}
+	}
+
+	// 不在允许列表中的签名算法（HS256 使用公钥字节作为密钥的经典攻击）
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": idp.server.URL, "aud": "client-1", "sub": "attacker",
+		"iat": now.Unix(), "exp": now.Add(time.Hour).Unix(),
+	})
+	raw, _ := forged.SignedString(x509.MarshalPKCS1PublicKey(&idp.key.PublicKey))
+	if _, err := a.AuthenticateCredential(ctx, &pb.OAuthCredential{IdToken: raw}); !errors.Is(err, ErrIDTokenInvalid) {
+		t.Fatalf("hs256 token err = %v, want ErrIDTokenInvalid", err)
+	}
+}
+
+func TestAuthenticator_JWKSCache(t *testing.T) {
+	idp := newMockIdP(t)
+	ctx := context.Background()
+
+	a := newTestAuthenticator(t, &Config{Name: "mock", Issuer: idp.server.URL, AllowIDToken: true})
+	now := time.Now()
+	a.now = func() time.Time { return now }
+
+	login := func() error {
+		token := idp.sign(jwt.MapClaims{"iat": now.Unix(), "exp": now.Add(time.Hour).Unix()})
+		_, err := a.AuthenticateCredential(ctx, &pb.OAuthCredential{IdToken: token})
+		return err
+	}
+
+	// 公钥缓存期内只拉取一次
+	for i := 0; i < 3; i++ {
+		if err := login(); err != nil {
+			t.Fatalf("login %d failed: %v", i, err)
+		}
+	}
+	if got := idp.jwksCalls.Load(); got != 1 {
+		t.Fatalf("jwks fetched %d times, want 1", got)
+	}
+
+	// 平台轮换公钥：未知 kid 在最小间隔内不刷新，之后刷新一次
+	idp.rotateKey("key-2")
+	if err := login(); err == nil {
+		t.Fatalf("unknown kid within refetch interval should fail")
+	}
+	now = now.Add(minJWKSRefetchInterval)
+	if err := login(); err != nil {
+		t.Fatalf("login after key rotation failed: %v", err)
+	}
+	if got := idp.jwksCalls.Load(); got != 2 {
+		t.Fatalf("jwks fetched %d times, want 2", got)
+	}
+
+	// 缓存过期后刷新，刷新失败时继续使用旧公钥
+	idp.server.Config.Handler = http.NotFoundHandler()
+	now = now.Add(defaultJWKSRefreshInterval)
+	if err := login(); err != nil {
+		t.Fatalf("login with stale keys failed: %v", err)
+	}
+}
+
+func TestAuthenticator_OAuth2UserInfo(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.omitIDToken = true
+	idp.userInfo = map[string]any{
+		"data": map[string]any{"openid": 12345678901234567, "nick": "Bob"},
+	}
+
+	a := newTestAuthenticator(t, &Config{
+		Name:            "oauth2",
+		TokenURL:        idp.server.URL + "/token",
+		UserInfoURL:     idp.server.URL + "/userinfo",
+		TokenAuthMethod: TokenAuthMethodBasic,
+		ClientSecret:    "secret-1",
+		Claims:          ClaimMapping{UID: "data.openid", Nickname: "data.nick"},
+	})
+
+	identity, err := a.AuthenticateCredential(context.Background(), &pb.OAuthCredential{Code: "good-code"})
+	if err != nil {
+		t.Fatalf("authenticate failed: %v", err)
+	}
+	// 数字 ID 保持精度，不使用科学计数法
+	if identity.UID != "12345678901234567" || identity.Nickname != "Bob" {
+		t.Fatalf("identity = %+v", identity)
+	}
+	if idp.lastForm.Get("client_secret") != "" {
+		t.Fatalf("client_secret_basic should not put secret in form: %v", idp.lastForm)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  Config
+		ok   bool
+	}{
+		{"issuer", Config{ClientID: "c", Issuer: "https://idp", LoginType: "LOGIN_TYPE_LOCAL"}, true},
+		{"token url", Config{ClientID: "c", TokenURL: "https://idp/token", LoginType: "7"}, true},
+		{"missing client id", Config{Issuer: "https://idp", LoginType: "7"}, false},
+		{"missing endpoints", Config{ClientID: "c", LoginType: "7"}, false},
+		{"unknown login type", Config{ClientID: "c", Issuer: "https://idp", LoginType: "LOGIN_TYPE_NOPE"}, false},
+		{"bad auth method", Config{ClientID: "c", Issuer: "https://idp", LoginType: "7", TokenAuthMethod: "private_key_jwt"}, false},
+	}
+	for _, c := range cases {
+		if err := c.cfg.Validate(); (err == nil) != c.ok {
+			t.Fatalf("%s: validate err = %v, want ok=%v", c.name, err, c.ok)
+		}
+	}
+}
+
+func TestAppleAdapter(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "AuthKey.p8")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	cfg := &Config{Name: "apple", ClientID: "com.example.game", Adapter: "apple"}
+	if _, err := newAdapter(cfg); err == nil {
+		t.Fatalf("apple adapter without options should fail")
+	}
+	cfg.Options = map[string]string{"team_id": "TEAM123", "key_id": "KEY123", "private_key_file": keyFile}
+	adapter, err := newAdapter(cfg)
+	if err != nil {
+		t.Fatalf("create apple adapter: %v", err)
+	}
+
+	form := url.Values{}
+	if err := adapter.PrepareTokenRequest(context.Background(), form); err != nil {
+		t.Fatalf("prepare token request: %v", err)
+	}
+	claims := jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(form.Get("client_secret"), &claims, func(*jwt.Token) (any, error) {
+		return &key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithAudience(appleAudience), jwt.WithIssuer("TEAM123"))
+	if err != nil {
+		t.Fatalf("client secret invalid: %v", err)
+	}
+	if token.Header["kid"] != "KEY123" || claims.Subject != "com.example.game" {
+		t.Fatalf("client secret header = %v, claims = %+v", token.Header, claims)
+	}
+
+	// 有效期内复用
+	again := url.Values{}
+	_ = adapter.PrepareTokenRequest(context.Background(), again)
+	if again.Get("client_secret") != form.Get("client_secret") {
+		t.Fatalf("client secret should be cached")
+	}
+}
+
+func TestRegisterAdapter(t *testing.T) {
+	RegisterAdapter("test-upper", func(*Config) (Adapter, error) { return renameAdapter{}, nil })
+
+	idp := newMockIdP(t)
+	a := newTestAuthenticator(t, &Config{Name: "custom", Issuer: idp.server.URL, AllowIDToken: true, Adapter: "test-upper"})
+	identity, err := a.AuthenticateCredential(context.Background(), &pb.OAuthCredential{IdToken: idp.sign(jwt.MapClaims{"player_id": "p-1"})})
+	if err != nil || identity.UID != "p-1" {
+		t.Fatalf("custom adapter identity = %+v, %v", identity, err)
+	}
+
+	if _, err := New(logger.Default(), &Config{Name: "x", ClientID: "c", Issuer: "https://idp", LoginType: "7", Adapter: "missing"}); err == nil {
+		t.Fatalf("unknown adapter should fail")
+	}
+}
+
+// renameAdapter 使用平台专有的 player_id 作为 sub
+type renameAdapter struct{ standardAdapter }
+
+func (renameAdapter) TransformClaims(claims map[string]any) (map[string]any, error) {
+	claims["sub"] = claims["player_id"]
+	return claims, nil
+}
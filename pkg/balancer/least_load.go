@@ -0,0 +1,139 @@
+package balancer
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lk2023060901/xdooria/pkg/registry"
+)
+
+const LeastLoadName = "least_load"
+
+// pickStaleAfter 分配计数超过该时长未被访问即清理。节点持续上报时计数在下次上报后本就清零，
+// 长时间未被抽中的节点（已下线、排空或所在区服长期无人分配）的计数没有保留意义
+const pickStaleAfter = 10 * time.Minute
+
+type leastLoadBuilder struct{}
+
+func NewLeastLoadBuilder() Builder {
+	return &leastLoadBuilder{}
+}
+
+func (b *leastLoadBuilder) Build() Balancer {
+	return &leastLoadBalancer{
+		picks: make(map[string]*nodePicks),
+		now:   time.Now,
+	}
+}
+
+func (b *leastLoadBuilder) Name() string {
+	return LeastLoadName
+}
+
+// leastLoadBalancer 按节点上报的负载实现二选一（Power of Two Choices）算法
+// 算法说明：
+// 1. 随机取两个不同的节点
+// 2. 负载 = 元数据中的连接数（sessions）+ 自该次上报以来本实例分配给该节点的次数
+// 3. 选择负载较低的节点，相同时选择 CPU 使用率（cpu_percent）较低的节点
+//
+// 负载按间隔上报，数据存在滞后；直接选最小负载会在两次上报之间把新连接全部分配给同一节点，
+// 二选一加上本地分配计数可以避免这种集中。未上报负载的节点按 0 计。
+type leastLoadBalancer struct {
+	mu       sync.Mutex
+	picks    map[string]*nodePicks // address -> 上报后的分配次数
+	now      func() time.Time
+	prunedAt time.Time
+}
+
+// nodePicks 节点最近一次上报之后的分配次数
+type nodePicks struct {
+	updatedAt string // 对应的上报时间，节点重新上报后清零
+	count     int
+	seenAt    time.Time // 最近一次被抽中比较的时间
+}
+
+func (b *leastLoadBalancer) Pick(nodes []*Node, _ PickInfo) *Node {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	chosen := nodes[0]
+	if len(nodes) > 1 {
+		i := rand.Intn(len(nodes))
+		j := rand.Intn(len(nodes) - 1)
+		if j >= i {
+			j++
+		}
+		chosen = nodes[i]
+		if b.less(nodes[j], nodes[i]) {
+			chosen = nodes[j]
+		}
+	}
+
+	b.pickCount(chosen).count++
+	b.prune()
+	return chosen
+}
+
+// prune 删除长时间未被访问的分配计数，避免节点上下线后计数无限累积。
+// 调用方每次只传入部分节点（如单个区服、未排空的节点），不能按本次的节点列表清理
+func (b *leastLoadBalancer) prune() {
+	now := b.now()
+	if now.Sub(b.prunedAt) < pickStaleAfter {
+		return
+	}
+	b.prunedAt = now
+
+	for addr, p := range b.picks {
+		if now.Sub(p.seenAt) > pickStaleAfter {
+			delete(b.picks, addr)
+		}
+	}
+}
+
+// less 判断节点 x 的负载是否低于 y
+func (b *leastLoadBalancer) less(x, y *Node) bool {
+	lx, ly := b.load(x), b.load(y)
+	if lx != ly {
+		return lx < ly
+	}
+	return metadataFloat(x, registry.MetadataCPUPercent) < metadataFloat(y, registry.MetadataCPUPercent)
+}
+
+// load 返回节点的估算连接数
+func (b *leastLoadBalancer) load(node *Node) int {
+	sessions, err := strconv.Atoi(node.Metadata[registry.MetadataSessions])
+	if err != nil || sessions < 0 {
+		sessions = 0
+	}
+	return sessions + b.pickCount(node).count
+}
+
+// pickCount 返回节点当前上报周期内的分配计数，节点重新上报后重置
+func (b *leastLoadBalancer) pickCount(node *Node) *nodePicks {
+	updatedAt := node.Metadata[registry.MetadataUpdatedAt]
+	p, ok := b.picks[node.Address]
+	if !ok {
+		p = &nodePicks{updatedAt: updatedAt}
+		b.picks[node.Address] = p
+	} else if p.updatedAt != updatedAt {
+		p.updatedAt = updatedAt
+		p.count = 0
+	}
+	p.seenAt = b.now()
+	return p
+}
+
+// metadataFloat 读取节点元数据中的浮点数，缺失或格式错误时为 0
+func metadataFloat(node *Node, key string) float64 {
+	v, err := strconv.ParseFloat(node.Metadata[key], 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
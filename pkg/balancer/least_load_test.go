@@ -0,0 +1,147 @@
+package balancer
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria/pkg/registry"
+)
+
+func newLoadNode(addr string, sessions int, cpu string, updatedAt string) *Node {
+	return &Node{
+		Address: addr,
+		Metadata: map[string]string{
+			registry.MetadataSessions:   strconv.Itoa(sessions),
+			registry.MetadataCPUPercent: cpu,
+			registry.MetadataUpdatedAt:  updatedAt,
+		},
+	}
+}
+
+func TestLeastLoadBalancer_Pick(t *testing.T) {
+	b := New(LeastLoadName)
+	if b == nil {
+		t.Fatal("least_load balancer not registered")
+	}
+
+	if node := b.Pick(nil, PickInfo{}); node != nil {
+		t.Errorf("expected nil for empty nodes, got %v", node)
+	}
+
+	// 两个节点时每次都比较这两个，始终选择负载低的
+	nodes := []*Node{
+		newLoadNode("gw-1", 500, "10", "t1"),
+		newLoadNode("gw-2", 100, "90", "t1"),
+	}
+	for i := 0; i < 100; i++ {
+		if node := b.Pick(nodes, PickInfo{}); node.Address != "gw-2" {
+			t.Fatalf("pick %d = %s, want gw-2", i, node.Address)
+		}
+	}
+}
+
+func TestLeastLoadBalancer_LocalPicks(t *testing.T) {
+	b := New(LeastLoadName)
+	nodes := []*Node{
+		newLoadNode("gw-1", 0, "", "t1"),
+		newLoadNode("gw-2", 10, "", "t1"),
+	}
+
+	// 上报之间本地分配计数累加，gw-1 分到 10 个后开始交替
+	counts := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		counts[b.Pick(nodes, PickInfo{}).Address]++
+	}
+	if counts["gw-1"] != 20 || counts["gw-2"] != 10 {
+		t.Fatalf("counts = %v, want gw-1: 20, gw-2: 10", counts)
+	}
+
+	// 节点重新上报后计数清零，以上报的连接数为准
+	nodes = []*Node{
+		newLoadNode("gw-1", 20, "", "t2"),
+		newLoadNode("gw-2", 19, "", "t2"),
+	}
+	if node := b.Pick(nodes, PickInfo{}); node.Address != "gw-2" {
+		t.Fatalf("pick after report = %s, want gw-2", node.Address)
+	}
+}
+
+func TestLeastLoadBalancer_PrunePicks(t *testing.T) {
+	b := New(LeastLoadName).(*leastLoadBalancer)
+	now := time.Unix(1700000000, 0)
+	b.now = func() time.Time { return now }
+	b.Pick([]*Node{newLoadNode("gw-1", 0, "", "t1"), newLoadNode("gw-2", 0, "", "t1")}, PickInfo{})
+
+	// gw-2 下线、gw-3 上线后，gw-2 的计数在长时间未被访问后清理
+	nodes := []*Node{newLoadNode("gw-1", 0, "", "t1"), newLoadNode("gw-3", 0, "", "t1")}
+	b.Pick(nodes, PickInfo{})
+	if _, ok := b.picks["gw-2"]; !ok {
+		t.Fatalf("picks = %v, gw-2 should be kept before going stale", b.picks)
+	}
+
+	now = now.Add(pickStaleAfter + time.Second)
+	b.Pick(nodes, PickInfo{})
+	if _, ok := b.picks["gw-2"]; ok || len(b.picks) != len(nodes) {
+		t.Fatalf("picks = %v, want only current nodes", b.picks)
+	}
+}
+
+func TestLeastLoadBalancer_ZonesKeepPicks(t *testing.T) {
+	b := New(LeastLoadName)
+	zone1 := []*Node{
+		newLoadNode("gw-1-a", 0, "", "t1"),
+		newLoadNode("gw-1-b", 10, "", "t1"),
+	}
+	zone2 := []*Node{
+		newLoadNode("gw-2-a", 0, "", "t1"),
+		newLoadNode("gw-2-b", 10, "", "t1"),
+	}
+
+	// 每次只传入一个区服的节点，交替分配时两个区服的本地计数都应保留
+	counts := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		counts[b.Pick(zone1, PickInfo{}).Address]++
+		counts[b.Pick(zone2, PickInfo{}).Address]++
+	}
+	for _, zone := range []string{"gw-1", "gw-2"} {
+		if counts[zone+"-a"] != 20 || counts[zone+"-b"] != 10 {
+			t.Fatalf("counts = %v, want 20/10 in each zone", counts)
+		}
+	}
+}
+
+func TestLeastLoadBalancer_CPUTieBreak(t *testing.T) {
+	b := New(LeastLoadName)
+	nodes := []*Node{
+		newLoadNode("gw-1", 0, "80.5", "t1"),
+		newLoadNode("gw-2", 0, "20.0", "t1"),
+	}
+	if node := b.Pick(nodes, PickInfo{}); node.Address != "gw-2" {
+		t.Fatalf("pick = %s, want gw-2 (lower cpu)", node.Address)
+	}
+}
+
+func TestLeastLoadBalancer_PowerOfTwo(t *testing.T) {
+	b := New(LeastLoadName)
+	nodes := []*Node{
+		newLoadNode("gw-1", 0, "", ""),
+		newLoadNode("gw-2", 0, "", ""),
+		newLoadNode("gw-3", 0, "", ""),
+		newLoadNode("gw-4", 1000, "", ""),
+	}
+
+	// 负载最高的节点只有在两次抽样都是它时才会被选中，而两次抽样总是不同的节点
+	counts := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		counts[b.Pick(nodes, PickInfo{}).Address]++
+	}
+	if counts["gw-4"] != 0 {
+		t.Errorf("overloaded node picked %d times", counts["gw-4"])
+	}
+	for _, addr := range []string{"gw-1", "gw-2", "gw-3"} {
+		if counts[addr] < 50 {
+			t.Errorf("%s picked %d times, want roughly even spread", addr, counts[addr])
+		}
+	}
+}
@@ -13,6 +13,7 @@ func init() {
 	Register(NewRoundRobinBuilder())
 	Register(NewWeightedBuilder())
 	Register(NewConsistentHashBuilder())
+	Register(NewLeastLoadBuilder())
 }
 
 // Register 注册负载均衡器构建器
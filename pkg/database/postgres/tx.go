@@ -14,6 +14,8 @@ type Tx interface {
 	QueryOne(ctx context.Context, dest any, sql string, args ...any) error
 	// QueryAll 查询多条记录（通过 dest 参数接收结果）
 	QueryAll(ctx context.Context, dest any, sql string, args ...any) error
+	// QueryRow 查询单行（由调用方自行 Scan）
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 	// Exec 执行写操作
 	Exec(ctx context.Context, sql string, args ...any) (int64, error)
 	// Exists 检查记录是否存在
@@ -82,6 +84,12 @@ func (t *txWrapper) QueryAll(ctx context.Context, dest any, sql string, args ...
 	return scanRowsToSlice(rows, dest)
 }
 
+// QueryRow 查询单行
+// 注意：pgx.Row 延迟到 Scan 时才读取结果，因此这里不应用查询超时
+func (t *txWrapper) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return t.tx.QueryRow(ctx, sql, args...)
+}
+
 // Exec 执行写操作
 func (t *txWrapper) Exec(ctx context.Context, sql string, args ...any) (int64, error) {
 	ctx, cancel := t.applyQueryTimeout(ctx)
@@ -27,6 +27,8 @@ type Collector struct {
 type Stats struct {
 	// CPU 使用率 (0-100)
 	CPUPercent float64 `json:"cpu_percent"`
+	// 最近一个采集周期内的 CPU 使用率，按核数归一化 (0-100)，首次采集为 0
+	CPUIntervalPercent float64 `json:"cpu_interval_percent"`
 	// 内存使用率 (0-100)
 	MemoryPercent float64 `json:"memory_percent"`
 	// 内存使用字节数
@@ -105,6 +107,9 @@ func (c *Collector) collect() {
 	if cpuPercent, err := c.proc.CPUPercent(); err == nil {
 		stats.CPUPercent = cpuPercent
 	}
+	if cpuPercent, err := c.proc.Percent(0); err == nil {
+		stats.CPUIntervalPercent = cpuPercent / float64(runtime.NumCPU())
+	}
 
 	// 内存使用（进程级别）
 	if memInfo, err := c.proc.MemoryInfo(); err == nil {
@@ -139,6 +144,13 @@ func (c *Collector) GetCPUPercent() float64 {
 	return c.stats.CPUPercent
 }
 
+// GetCPUIntervalPercent 获取最近一个采集周期内按核数归一化的 CPU 使用率
+func (c *Collector) GetCPUIntervalPercent() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stats.CPUIntervalPercent
+}
+
 // GetMemoryPercent 获取内存使用率
 func (c *Collector) GetMemoryPercent() float64 {
 	c.mu.RLock()
@@ -0,0 +1,94 @@
+// framer/cipher.go
+// 加密套件：AEAD 加密并将 Header 绑定为关联数据
+package framer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	pb "github.com/lk2023060901/xdooria-proto-common"
+)
+
+// CipherSuite 加密套件
+type CipherSuite string
+
+const (
+	// CipherSuiteLegacy AES-256-GCM 加密 payload 后再对 Header+Payload 做 HMAC-SHA256 签名（旧版客户端）
+	CipherSuiteLegacy CipherSuite = "aes-256-gcm+hmac-sha256"
+
+	// CipherSuiteAESGCM AES-256-GCM，Header 作为关联数据，不再单独签名
+	CipherSuiteAESGCM CipherSuite = "aes-256-gcm"
+
+	// CipherSuiteChaCha20Poly1305 ChaCha20-Poly1305，Header 作为关联数据，适合没有 AES 硬件加速的低端移动设备
+	CipherSuiteChaCha20Poly1305 CipherSuite = "chacha20-poly1305"
+)
+
+// aeadFlagsMask 所有 AEAD 套件的标志位，标志位为 0 表示旧版套件
+const aeadFlagsMask = uint32(pb.MessageFlags_MESSAGE_FLAGS_AEAD_AES_GCM) |
+	uint32(pb.MessageFlags_MESSAGE_FLAGS_AEAD_CHACHA20)
+
+// cipherSuiteIDs 握手中使用的套件编号
+var cipherSuiteIDs = map[CipherSuite]byte{
+	CipherSuiteLegacy:           0,
+	CipherSuiteAESGCM:           1,
+	CipherSuiteChaCha20Poly1305: 2,
+}
+
+// cipherSuiteByID 按握手编号查找套件
+func cipherSuiteByID(id byte) (CipherSuite, bool) {
+	for suite, suiteID := range cipherSuiteIDs {
+		if suiteID == id {
+			return suite, true
+		}
+	}
+	return "", false
+}
+
+// flag 返回套件在 MessageHeader.Flags 中的标志位，旧版套件返回 0
+func (s CipherSuite) flag() uint32 {
+	switch s {
+	case CipherSuiteAESGCM:
+		return uint32(pb.MessageFlags_MESSAGE_FLAGS_AEAD_AES_GCM)
+	case CipherSuiteChaCha20Poly1305:
+		return uint32(pb.MessageFlags_MESSAGE_FLAGS_AEAD_CHACHA20)
+	}
+	return 0
+}
+
+// validate 检查套件是否受支持
+func (s CipherSuite) validate() error {
+	if _, ok := cipherSuiteIDs[s]; !ok {
+		return fmt.Errorf("unsupported cipher suite: %q", s)
+	}
+	return nil
+}
+
+// newAEAD 使用 32 字节密钥创建 AEAD，旧版套件返回 nil
+func newAEAD(suite CipherSuite, key []byte) (cipher.AEAD, error) {
+	switch suite {
+	case CipherSuiteAESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case CipherSuiteChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	}
+	return nil, nil
+}
+
+// negotiateCipherSuite 按本端偏好顺序选择对端也支持的第一个套件，没有交集时使用旧版套件
+func negotiateCipherSuite(local, peer []CipherSuite) CipherSuite {
+	for _, s := range local {
+		for _, p := range peer {
+			if s == p {
+				return s
+			}
+		}
+	}
+	return CipherSuiteLegacy
+}
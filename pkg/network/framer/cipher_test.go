@@ -0,0 +1,224 @@
+package framer
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	pb "github.com/lk2023060901/xdooria-proto-common"
+)
+
+var testEncryptKey = bytes.Repeat([]byte{0x42}, 32)
+
+var allCipherSuites = []CipherSuite{CipherSuiteLegacy, CipherSuiteAESGCM, CipherSuiteChaCha20Poly1305}
+
+func newTestFramer(t testing.TB, suites ...CipherSuite) Framer {
+	t.Helper()
+
+	f, err := New(&Config{
+		SignKey:       []byte("static-sign-key"),
+		EncryptKey:    testEncryptKey,
+		EnableEncrypt: true,
+		CipherSuites:  suites,
+	})
+	if err != nil {
+		t.Fatalf("failed to create framer: %v", err)
+	}
+	return f
+}
+
+func TestCipherSuites_RoundTrip(t *testing.T) {
+	for _, suite := range allCipherSuites {
+		t.Run(string(suite), func(t *testing.T) {
+			sender := newTestFramer(t, suite)
+			receiver := newTestFramer(t, allCipherSuites...)
+
+			for _, payload := range [][]byte{{}, []byte("hello"), bytes.Repeat([]byte{7}, 4096)} {
+				env, err := sender.Encode(testOp, payload)
+				if err != nil {
+					t.Fatalf("encode failed: %v", err)
+				}
+				if got := env.Header.Flags & aeadFlagsMask; got != suite.flag() {
+					t.Fatalf("suite flags = %#x, want %#x", got, suite.flag())
+				}
+				// AEAD 套件不再单独签名
+				if (len(env.Header.Sign) == 0) != (suite != CipherSuiteLegacy) {
+					t.Fatalf("unexpected sign length %d", len(env.Header.Sign))
+				}
+
+				op, got, err := receiver.Decode(env)
+				if err != nil || op != testOp || !bytes.Equal(got, payload) {
+					t.Fatalf("decode = (%d, %d bytes, %v)", op, len(got), err)
+				}
+			}
+		})
+	}
+}
+
+func TestCipherSuites_HeaderBound(t *testing.T) {
+	tamper := map[string]func(h *pb.MessageHeader){
+		"op":        func(h *pb.MessageHeader) { h.Op++ },
+		"seq":       func(h *pb.MessageHeader) { h.SeqId++ },
+		"timestamp": func(h *pb.MessageHeader) { h.Timestamp-- },
+		"flags":     func(h *pb.MessageHeader) { h.Flags |= uint32(pb.MessageFlags_MESSAGE_FLAGS_KEY_UPDATE) },
+	}
+
+	for _, suite := range []CipherSuite{CipherSuiteAESGCM, CipherSuiteChaCha20Poly1305} {
+		for name, fn := range tamper {
+			t.Run(string(suite)+"/"+name, func(t *testing.T) {
+				f := newTestFramer(t, suite)
+				env, err := f.Encode(testOp, []byte("payload"))
+				if err != nil {
+					t.Fatalf("encode failed: %v", err)
+				}
+				fn(env.Header)
+				if _, _, err := f.Decode(env); err == nil {
+					t.Fatalf("tampered header should fail authentication")
+				}
+			})
+		}
+	}
+}
+
+func TestCipherSuites_Compatibility(t *testing.T) {
+	oldFramer := newTestFramer(t)
+	newFramer := newTestFramer(t, CipherSuiteLegacy, CipherSuiteChaCha20Poly1305)
+
+	// 旧客户端的消息没有套件标志位，新服务端按旧版套件解码
+	if _, got := transfer(t, oldFramer, newFramer, testOp, []byte("old")); string(got) != "old" {
+		t.Fatalf("payload = %q", got)
+	}
+	// 服务端首选旧版套件时旧客户端可以解码
+	if _, got := transfer(t, newFramer, oldFramer, testOp, []byte("new")); string(got) != "new" {
+		t.Fatalf("payload = %q", got)
+	}
+
+	// 接收方未启用的套件被拒绝
+	env, err := newTestFramer(t, CipherSuiteChaCha20Poly1305).Encode(testOp, []byte("x"))
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if _, _, err := oldFramer.Decode(env); err == nil {
+		t.Fatalf("disabled suite should be rejected")
+	}
+
+	if _, err := New(&Config{CipherSuites: []CipherSuite{"rot13"}}); err == nil {
+		t.Fatalf("unknown suite should be rejected")
+	}
+}
+
+func TestSessionFramer_NegotiateCipherSuite(t *testing.T) {
+	cases := []struct {
+		name   string
+		client []CipherSuite
+		server []CipherSuite
+		legacy bool // 客户端不支持套件协商（只发送公钥）
+		want   CipherSuite
+	}{
+		{"server preference", []CipherSuite{CipherSuiteChaCha20Poly1305, CipherSuiteAESGCM}, []CipherSuite{CipherSuiteAESGCM, CipherSuiteChaCha20Poly1305}, false, CipherSuiteAESGCM},
+		{"common suite", []CipherSuite{CipherSuiteChaCha20Poly1305}, []CipherSuite{CipherSuiteAESGCM, CipherSuiteChaCha20Poly1305}, false, CipherSuiteChaCha20Poly1305},
+		{"no overlap", []CipherSuite{CipherSuiteChaCha20Poly1305}, []CipherSuite{CipherSuiteAESGCM}, false, CipherSuiteLegacy},
+		{"old client", nil, []CipherSuite{CipherSuiteChaCha20Poly1305}, true, CipherSuiteLegacy},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			kx := KeyExchangeConfig{Enabled: true, RotateMessages: 2}
+			client := newTestSessionFramer(t, &Config{CipherSuites: tc.client, KeyExchange: kx})
+			server := newTestSessionFramer(t, &Config{CipherSuites: tc.server, KeyExchange: kx})
+
+			request, err := client.Initiate()
+			if err != nil {
+				t.Fatalf("initiate failed: %v", err)
+			}
+			if tc.legacy {
+				request = request[:32]
+			}
+			_, request = transfer(t, client, server, uint32(pb.OpCode_OP_HANDSHAKE_REQ), request)
+			response, err := server.Accept(request)
+			if err != nil {
+				t.Fatalf("accept failed: %v", err)
+			}
+			if tc.legacy && len(response) != 32 {
+				t.Fatalf("old client should receive bare public key, got %d bytes", len(response))
+			}
+			_, response = transfer(t, server, client, uint32(pb.OpCode_OP_HANDSHAKE_RES), response)
+			if err := client.Complete(response); err != nil {
+				t.Fatalf("complete failed: %v", err)
+			}
+
+			if client.CipherSuite() != tc.want || server.CipherSuite() != tc.want {
+				t.Fatalf("suite = %q/%q, want %q", client.CipherSuite(), server.CipherSuite(), tc.want)
+			}
+
+			// 跨越多次密钥轮换仍能正常通信
+			for i := 0; i < 5; i++ {
+				env, err := client.Encode(testOp, []byte{byte(i)})
+				if err != nil {
+					t.Fatalf("encode failed: %v", err)
+				}
+				if got := env.Header.Flags & aeadFlagsMask; got != tc.want.flag() {
+					t.Fatalf("suite flags = %#x, want %#x", got, tc.want.flag())
+				}
+				if _, got, err := server.Decode(env); err != nil || got[0] != byte(i) {
+					t.Fatalf("message %d decode failed: %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+// acceptAllSeqIds 不做重放检测的 SeqId 管理器
+type acceptAllSeqIds struct{}
+
+func (acceptAllSeqIds) Next() uint32 { return 1 }
+
+func (acceptAllSeqIds) Validate(uint32, uint64) bool { return true }
+
+// BenchmarkFramer_Encode 对比旧版（AES-GCM + HMAC）与 AEAD 套件的编码开销
+func BenchmarkFramer_Encode(b *testing.B) {
+	for _, suite := range allCipherSuites {
+		for _, size := range []int{64, 1024, 16384} {
+			b.Run(fmt.Sprintf("%s/%d", suite, size), func(b *testing.B) {
+				f := newTestFramer(b, suite)
+				payload := bytes.Repeat([]byte{1}, size)
+
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := f.Encode(testOp, payload); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkFramer_Decode 对比旧版（AES-GCM + HMAC）与 AEAD 套件的解码开销
+func BenchmarkFramer_Decode(b *testing.B) {
+	for _, suite := range allCipherSuites {
+		for _, size := range []int{64, 1024, 16384} {
+			b.Run(fmt.Sprintf("%s/%d", suite, size), func(b *testing.B) {
+				f := newTestFramer(b, suite)
+				env, err := f.Encode(testOp, bytes.Repeat([]byte{1}, size))
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				// 同一条消息重复解码会被判定为重放；SeqId 校验与套件无关，基准中跳过
+				f.(*frameImpl).seqIdMgr = acceptAllSeqIds{}
+
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, _, err := f.Decode(env); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
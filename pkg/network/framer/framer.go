@@ -4,6 +4,8 @@ package framer
 
 import (
 	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
 	"encoding/binary"
 	"fmt"
 	"time"
@@ -28,31 +30,69 @@ type Framer interface {
 	Decode(envelope *pb.Envelope) (op uint32, payload []byte, err error)
 }
 
+// RPCEncoder 支持在 Header 中携带 RPC 关联 ID 的 Framer
+type RPCEncoder interface {
+	// EncodeRPC 编码消息为 Envelope，关联 ID 写入 Header.RpcId 并参与签名（或 AEAD 认证）
+	EncodeRPC(op uint32, rpcId uint32, payload []byte) (*pb.Envelope, error)
+}
+
+// EncodeRPC 使用 f 编码携带 RPC 关联 ID 的消息，rpcId 为 0 时等同于 f.Encode
+func EncodeRPC(f Framer, op uint32, rpcId uint32, payload []byte) (*pb.Envelope, error) {
+	if rpcId == 0 {
+		return f.Encode(op, payload)
+	}
+	e, ok := f.(RPCEncoder)
+	if !ok {
+		return nil, fmt.Errorf("framer %T does not support rpc id", f)
+	}
+	return e.EncodeRPC(op, rpcId, payload)
+}
+
 // Config Framer 配置
 type Config struct {
 	// 签名密钥
-	SignKey []byte
+	SignKey []byte `mapstructure:"sign_key" json:"sign_key" yaml:"sign_key"`
 
 	// 加密密钥（AES-256，32字节）
-	EncryptKey []byte
+	EncryptKey []byte `mapstructure:"encrypt_key" json:"encrypt_key" yaml:"encrypt_key"`
 
 	// 压缩算法类型
-	CompressType compress.Type
+	CompressType compress.Type `mapstructure:"compress_type" json:"compress_type" yaml:"compress_type"`
 
-	// SeqId 管理器配置
-	SeqIdConfig *seqid.Config
+	// SeqId 管理器配置（每个会话独立的序列号与防重放窗口）
+	SeqIdConfig *seqid.Config `mapstructure:"seq_id" json:"seq_id" yaml:"seq_id"`
 
 	// 是否启用加密
-	EnableEncrypt bool
+	EnableEncrypt bool `mapstructure:"enable_encrypt" json:"enable_encrypt" yaml:"enable_encrypt"`
 
 	// 是否启用压缩
-	EnableCompress bool
+	EnableCompress bool `mapstructure:"enable_compress" json:"enable_compress" yaml:"enable_compress"`
 
 	// 压缩最小字节数（小于此值不压缩）
-	CompressMinBytes int
+	CompressMinBytes int `mapstructure:"compress_min_bytes" json:"compress_min_bytes" yaml:"compress_min_bytes"`
 
 	// 时间戳容差（秒），防重放攻击
-	TimestampTolerance time.Duration
+	TimestampTolerance time.Duration `mapstructure:"timestamp_tolerance" json:"timestamp_tolerance" yaml:"timestamp_tolerance"`
+
+	// 加密套件（按偏好排序）：发送使用第一个，接收接受列表中的任意套件
+	// 为空时使用旧版套件；旧版套件（无 AEAD 标志位的消息）始终可以接收，保证旧客户端可用
+	CipherSuites []CipherSuite `mapstructure:"cipher_suites" json:"cipher_suites" yaml:"cipher_suites"`
+
+	// 会话密钥协商配置
+	KeyExchange KeyExchangeConfig `mapstructure:"key_exchange" json:"key_exchange" yaml:"key_exchange"`
+}
+
+// KeyExchangeConfig 会话密钥协商配置
+// 启用后每个会话通过 X25519 握手派生独立的加密与签名密钥，静态密钥只用于握手消息
+type KeyExchangeConfig struct {
+	// 是否启用
+	Enabled bool `mapstructure:"enabled" json:"enabled" yaml:"enabled"`
+
+	// 发送多少条消息后轮换密钥，0 表示不按消息数轮换
+	RotateMessages uint64 `mapstructure:"rotate_messages" json:"rotate_messages" yaml:"rotate_messages"`
+
+	// 密钥使用多久后轮换，0 表示不按时间轮换
+	RotateInterval time.Duration `mapstructure:"rotate_interval" json:"rotate_interval" yaml:"rotate_interval"`
 }
 
 // DefaultConfig 默认配置
@@ -74,9 +114,15 @@ type frameImpl struct {
 	// 签名器
 	signer signer.Signer
 
-	// 加密器
+	// 加密器（旧版套件）
 	aes *crypto.AES
 
+	// 发送使用的加密套件
+	suite CipherSuite
+
+	// AEAD 套件，按 Flags 中的套件标志位索引
+	aeads map[uint32]cipher.AEAD
+
 	// 压缩器
 	compressor compress.Compressor
 
@@ -111,6 +157,11 @@ func New(cfg *Config) (Framer, error) {
 		f.aes = aes
 	}
 
+	// 初始化加密套件
+	if err := f.initCipherSuites(); err != nil {
+		return nil, err
+	}
+
 	// 初始化压缩器（始终初始化，使用 TypeNone 作为透传）
 	compressor, err := compress.New(newCfg.CompressType)
 	if err != nil {
@@ -128,10 +179,65 @@ func New(cfg *Config) (Framer, error) {
 	return f, nil
 }
 
+// ForSession 为单个会话派生 Framer：共享密钥、加密套件与压缩器，使用独立的 SeqId 管理器
+// 不同会话的序列号互不干扰，防重放窗口也不再在所有会话间共享一把锁
+// base 不是由 New 创建时原样返回
+func ForSession(base Framer) (Framer, error) {
+	f, ok := base.(*frameImpl)
+	if !ok {
+		return base, nil
+	}
+
+	seqIdMgr, err := seqid.New(f.config.SeqIdConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create seqid manager: %w", err)
+	}
+
+	forked := *f
+	forked.seqIdMgr = seqIdMgr
+	return &forked, nil
+}
+
 // Encode 编码消息为 Envelope
 func (f *frameImpl) Encode(op uint32, payload []byte) (*pb.Envelope, error) {
+	return f.encode(op, 0, payload, uint32(pb.MessageFlags_MESSAGE_FLAGS_NONE))
+}
+
+// EncodeRPC 编码携带 RPC 关联 ID 的消息
+func (f *frameImpl) EncodeRPC(op uint32, rpcId uint32, payload []byte) (*pb.Envelope, error) {
+	return f.encode(op, rpcId, payload, uint32(pb.MessageFlags_MESSAGE_FLAGS_NONE))
+}
+
+// initCipherSuites 按配置创建 AEAD 套件（未启用加密或没有密钥时只能使用旧版套件）
+func (f *frameImpl) initCipherSuites() error {
+	suites := f.config.CipherSuites
+	if len(suites) == 0 {
+		suites = []CipherSuite{CipherSuiteLegacy}
+	}
+	f.suite = suites[0]
+	f.aeads = make(map[uint32]cipher.AEAD, len(suites))
+
+	for _, suite := range suites {
+		if err := suite.validate(); err != nil {
+			return err
+		}
+		if !f.config.EnableEncrypt || len(f.config.EncryptKey) == 0 {
+			continue
+		}
+		aead, err := newAEAD(suite, f.config.EncryptKey)
+		if err != nil {
+			return fmt.Errorf("failed to create %s cipher: %w", suite, err)
+		}
+		if aead != nil {
+			f.aeads[suite.flag()] = aead
+		}
+	}
+	return nil
+}
+
+// encode 编码消息，rpcId 为 RPC 关联 ID（0 表示不是 RPC 消息），flags 为调用方附加的标志位（均参与签名）
+func (f *frameImpl) encode(op uint32, rpcId uint32, payload []byte, flags uint32) (*pb.Envelope, error) {
 	processedPayload := payload
-	var flags uint32 = uint32(pb.MessageFlags_MESSAGE_FLAGS_NONE)
 
 	// 1. 压缩（如果启用且满足最小字节数）
 	if f.config.EnableCompress && len(processedPayload) >= f.config.CompressMinBytes {
@@ -143,6 +249,11 @@ func (f *frameImpl) Encode(op uint32, payload []byte) (*pb.Envelope, error) {
 		flags |= uint32(pb.MessageFlags_MESSAGE_FLAGS_COMPRESSED) // 压缩成功才设置标志位
 	}
 
+	// AEAD 套件：加密同时认证 Header，不再单独签名
+	if aead := f.aeads[f.suite.flag()]; aead != nil {
+		return f.encodeAEAD(op, rpcId, processedPayload, flags|f.suite.flag(), aead)
+	}
+
 	// 2. 加密（如果启用）
 	if f.config.EnableEncrypt && f.aes != nil {
 		encrypted, err := f.aes.EncryptBytes(processedPayload)
@@ -163,6 +274,7 @@ func (f *frameImpl) Encode(op uint32, payload []byte) (*pb.Envelope, error) {
 		Size:      uint32(len(processedPayload)),
 		Flags:     flags,
 		Timestamp: uint64(time.Now().Unix()),
+		RpcId:     rpcId,
 		Sign:      nil, // 先不设置签名
 	}
 
@@ -208,6 +320,11 @@ func (f *frameImpl) Decode(envelope *pb.Envelope) (op uint32, payload []byte, er
 			msgTime, now, tolerance)
 	}
 
+	// AEAD 套件：解密即完成 Header 与 Payload 的认证
+	if header.Flags&aeadFlagsMask != 0 {
+		return f.decodeAEAD(header, processedPayload)
+	}
+
 	// 2. 验证签名
 	if f.signer != nil {
 		if len(header.Sign) == 0 {
@@ -255,12 +372,83 @@ func (f *frameImpl) Decode(envelope *pb.Envelope) (op uint32, payload []byte, er
 	return header.Op, processedPayload, nil
 }
 
+// encodeAEAD 使用 AEAD 加密 payload，Header（不含签名）作为关联数据
+// Payload 格式: nonce + ciphertext(含认证标签)
+func (f *frameImpl) encodeAEAD(op uint32, rpcId uint32, payload []byte, flags uint32, aead cipher.AEAD) (*pb.Envelope, error) {
+	flags |= uint32(pb.MessageFlags_MESSAGE_FLAGS_ENCRYPTED)
+
+	header := &pb.MessageHeader{
+		Op:        op,
+		SeqId:     f.seqIdMgr.Next(),
+		Size:      uint32(aead.NonceSize() + len(payload) + aead.Overhead()),
+		Flags:     flags,
+		Timestamp: uint64(time.Now().Unix()),
+		RpcId:     rpcId,
+	}
+
+	sealed := make([]byte, aead.NonceSize(), header.Size)
+	if _, err := rand.Read(sealed); err != nil {
+		return nil, fmt.Errorf("generate nonce failed: %w", err)
+	}
+
+	ad, err := f.marshalHeaderWithoutSign(header, nil)
+	if err != nil {
+		return nil, fmt.Errorf("marshal header for aead failed: %w", err)
+	}
+	sealed = aead.Seal(sealed, sealed, payload, ad.Bytes())
+	bytebuff.Put(ad)
+
+	return &pb.Envelope{
+		Header:  header,
+		Payload: sealed,
+	}, nil
+}
+
+// decodeAEAD 解密并认证 AEAD 消息（时间戳已由调用方校验）
+func (f *frameImpl) decodeAEAD(header *pb.MessageHeader, payload []byte) (uint32, []byte, error) {
+	aead := f.aeads[header.Flags&aeadFlagsMask]
+	if aead == nil {
+		return 0, nil, fmt.Errorf("unsupported cipher suite flags: %#x", header.Flags&aeadFlagsMask)
+	}
+	if int(header.Size) != len(payload) || len(payload) < aead.NonceSize()+aead.Overhead() {
+		return 0, nil, fmt.Errorf("invalid aead payload size: %d", len(payload))
+	}
+
+	// 1. 解密并认证（Header 作为关联数据，任何字段被篡改都会失败）
+	ad, err := f.marshalHeaderWithoutSign(header, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("marshal header for aead failed: %w", err)
+	}
+	nonce, ciphertext := payload[:aead.NonceSize()], payload[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, ad.Bytes())
+	bytebuff.Put(ad)
+	if err != nil {
+		return 0, nil, fmt.Errorf("decrypt failed: %w", err)
+	}
+
+	// 2. 验证 SeqId（防重放）
+	if !f.seqIdMgr.Validate(header.SeqId, header.Timestamp) {
+		return 0, nil, fmt.Errorf("invalid or duplicate seqId: %d", header.SeqId)
+	}
+
+	// 3. 解压（如果配置启用且消息有压缩标志）
+	if f.config.EnableCompress && header.Flags&uint32(pb.MessageFlags_MESSAGE_FLAGS_COMPRESSED) != 0 {
+		if plaintext, err = f.compressor.Decompress(plaintext); err != nil {
+			return 0, nil, fmt.Errorf("decompress failed: %w", err)
+		}
+	}
+
+	return header.Op, plaintext, nil
+}
+
 // signHeaderSize 签名 header 固定大小: op(4) + seqId(4) + size(4) + flags(4) + timestamp(8) = 24 bytes
+// 携带 RPC 关联 ID 时再追加 rpcId(4)
 const signHeaderSize = 24
 
 // marshalHeaderWithoutSign 将 Header（不含签名）和 Payload 序列化用于签名
 // 返回 buffer 需要调用方通过 bytebuff.Put 归还
-// 格式: BigEndian(op) + BigEndian(seqId) + BigEndian(size) + BigEndian(flags) + BigEndian(timestamp) + payload
+// 格式: BigEndian(op) + BigEndian(seqId) + BigEndian(size) + BigEndian(flags) + BigEndian(timestamp) [+ BigEndian(rpcId)] + payload
+// rpcId 为 0 时不写入，与不支持 RPC 的旧版本签名保持一致
 func (f *frameImpl) marshalHeaderWithoutSign(header *pb.MessageHeader, payload []byte) (*bytes.Buffer, error) {
 	buf := bytebuff.Get(signHeaderSize + len(payload))
 
@@ -285,6 +473,12 @@ func (f *frameImpl) marshalHeaderWithoutSign(header *pb.MessageHeader, payload [
 		bytebuff.Put(buf)
 		return nil, err
 	}
+	if header.RpcId != 0 {
+		if err := binary.Write(buf, binary.BigEndian, header.RpcId); err != nil {
+			bytebuff.Put(buf)
+			return nil, err
+		}
+	}
 
 	// 写入 payload
 	if _, err := buf.Write(payload); err != nil {
@@ -0,0 +1,73 @@
+package framer
+
+import (
+	"testing"
+)
+
+func TestForSession(t *testing.T) {
+	base, err := New(&Config{SignKey: []byte("static-sign-key")})
+	if err != nil {
+		t.Fatalf("failed to create framer: %v", err)
+	}
+
+	// 两个客户端各自从相同的序列号开始发送
+	client1, _ := ForSession(base)
+	client2, _ := ForSession(base)
+	server1, _ := ForSession(base)
+	server2, _ := ForSession(base)
+
+	env1, err := client1.Encode(testOp, []byte("a"))
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	env2, err := client2.Encode(testOp, []byte("b"))
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if env1.Header.SeqId != env2.Header.SeqId {
+		t.Fatalf("forked framers should have independent sequence counters")
+	}
+
+	// 序列号重叠的两个会话互不影响
+	if _, _, err := server1.Decode(env1); err != nil {
+		t.Fatalf("session 1 decode failed: %v", err)
+	}
+	if _, _, err := server2.Decode(env2); err != nil {
+		t.Fatalf("session 2 decode failed: %v", err)
+	}
+
+	// 同一会话内的重放被拒绝
+	if _, _, err := server1.Decode(env1); err == nil {
+		t.Fatalf("replayed message should be rejected")
+	}
+}
+
+func TestEncodeRPC(t *testing.T) {
+	for _, suite := range []CipherSuite{CipherSuiteLegacy, CipherSuiteAESGCM} {
+		t.Run(string(suite), func(t *testing.T) {
+			sender, _ := ForSession(newTestFramer(t, suite))
+			receiver, _ := ForSession(newTestFramer(t, suite))
+
+			env, err := EncodeRPC(sender, testOp, 7, []byte("req"))
+			if err != nil {
+				t.Fatalf("encode failed: %v", err)
+			}
+			if env.Header.RpcId != 7 {
+				t.Fatalf("rpc id = %d, want 7", env.Header.RpcId)
+			}
+			if op, payload, err := receiver.Decode(env); err != nil || op != testOp || string(payload) != "req" {
+				t.Fatalf("decode = (%d, %q, %v)", op, payload, err)
+			}
+
+			// 关联 ID 参与签名（或 AEAD 认证），篡改后无法解码
+			env, err = EncodeRPC(sender, testOp, 8, []byte("req"))
+			if err != nil {
+				t.Fatalf("encode failed: %v", err)
+			}
+			env.Header.RpcId = 9
+			if _, _, err := receiver.Decode(env); err == nil {
+				t.Fatalf("tampered rpc id should be rejected")
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// framer/keyexchange/keyexchange.go
+// 基于 X25519 的会话密钥协商与轮换
+package keyexchange
+
+import (
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+)
+
+// KeySize 派生密钥长度（AES-256 与 HMAC-SHA256 均使用 32 字节）
+const KeySize = 32
+
+// PublicKeySize X25519 公钥长度
+const PublicKeySize = 32
+
+// HKDF info 标签，区分不同用途的派生结果
+const (
+	infoClientToServer = "xdooria framer c2s"
+	infoServerToClient = "xdooria framer s2c"
+	infoEncrypt        = "xdooria framer encrypt"
+	infoSign           = "xdooria framer sign"
+	infoNext           = "xdooria framer next"
+)
+
+// KeyPair 一次握手使用的临时密钥对，用完即丢弃
+type KeyPair struct {
+	private *ecdh.PrivateKey
+}
+
+// GenerateKeyPair 生成临时 X25519 密钥对
+func GenerateKeyPair() (*KeyPair, error) {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate x25519 key failed: %w", err)
+	}
+	return &KeyPair{private: private}, nil
+}
+
+// PublicKey 返回公钥，发送给对端
+func (k *KeyPair) PublicKey() []byte {
+	return k.private.PublicKey().Bytes()
+}
+
+// Secrets 握手派生出的双向密钥链根，两个方向独立轮换
+type Secrets struct {
+	// Send 本端发送方向
+	Send []byte
+	// Recv 本端接收方向
+	Recv []byte
+}
+
+// Exchange 与对端公钥协商共享密钥，派生双向密钥链根
+// isClient 表示本端是否为握手发起方，决定 Send/Recv 对应的方向
+func (k *KeyPair) Exchange(peerPublicKey []byte, isClient bool) (*Secrets, error) {
+	peer, err := ecdh.X25519().NewPublicKey(peerPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer public key: %w", err)
+	}
+	shared, err := k.private.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("ecdh failed: %w", err)
+	}
+
+	// 以双方公钥作为 salt，把共享密钥绑定到本次握手
+	clientPub, serverPub := k.PublicKey(), peerPublicKey
+	if !isClient {
+		clientPub, serverPub = serverPub, clientPub
+	}
+	salt := make([]byte, 0, len(clientPub)+len(serverPub))
+	salt = append(salt, clientPub...)
+	salt = append(salt, serverPub...)
+
+	prk, err := hkdf.Extract(sha256.New, shared, salt)
+	if err != nil {
+		return nil, fmt.Errorf("hkdf extract failed: %w", err)
+	}
+	c2s, err := hkdf.Expand(sha256.New, prk, infoClientToServer, KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("hkdf expand failed: %w", err)
+	}
+	s2c, err := hkdf.Expand(sha256.New, prk, infoServerToClient, KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("hkdf expand failed: %w", err)
+	}
+
+	if isClient {
+		return &Secrets{Send: c2s, Recv: s2c}, nil
+	}
+	return &Secrets{Send: s2c, Recv: c2s}, nil
+}
+
+// Keys 从密钥链当前节点派生 AES 加密密钥与 HMAC 签名密钥
+func Keys(secret []byte) (encryptKey, signKey []byte, err error) {
+	if encryptKey, err = hkdf.Expand(sha256.New, secret, infoEncrypt, KeySize); err != nil {
+		return nil, nil, fmt.Errorf("derive encrypt key failed: %w", err)
+	}
+	if signKey, err = hkdf.Expand(sha256.New, secret, infoSign, KeySize); err != nil {
+		return nil, nil, fmt.Errorf("derive sign key failed: %w", err)
+	}
+	return encryptKey, signKey, nil
+}
+
+// Next 单向推进密钥链，用于密钥轮换
+// 旧节点无法由新节点推出，轮换后泄露的密钥不会暴露之前的流量
+func Next(secret []byte) ([]byte, error) {
+	next, err := hkdf.Expand(sha256.New, secret, infoNext, KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("ratchet secret failed: %w", err)
+	}
+	return next, nil
+}
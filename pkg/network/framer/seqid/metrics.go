@@ -0,0 +1,47 @@
+// framer/seqid/metrics.go
+// 防重放 Prometheus 指标
+package seqid
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics 防重放指标，实现 Observer
+type Metrics struct {
+	// 被拒绝的消息数（按原因）
+	rejected *prometheus.CounterVec
+}
+
+// NewMetrics 创建防重放指标并注册到 registerer（为空时使用默认注册器）
+func NewMetrics(namespace string, registerer prometheus.Registerer) *Metrics {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &Metrics{
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "framer",
+			Name:      "replay_rejected_total",
+			Help:      "Total number of messages rejected by anti-replay checks",
+		}, []string{"reason"}), // reason: expired/too_old/duplicate
+	}
+
+	registerer.MustRegister(m.rejected)
+
+	return m
+}
+
+// OnRejected 记录一次拒绝
+func (m *Metrics) OnRejected(reason RejectReason) {
+	m.rejected.WithLabelValues(string(reason)).Inc()
+}
+
+// Unregister 取消注册所有指标
+func (m *Metrics) Unregister(registerer prometheus.Registerer) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	registerer.Unregister(m.rejected)
+}
@@ -14,25 +14,49 @@ import (
 // Config SeqId 管理器配置
 type Config struct {
 	// 初始 SeqId（0 表示随机生成）
-	InitialSeqId uint32
+	InitialSeqId uint32 `mapstructure:"initial_seq_id" json:"initial_seq_id" yaml:"initial_seq_id"`
 
-	// LRU 缓存大小（用于检测重复）
-	CacheSize int
+	// 防重放窗口大小，即允许乱序到达的最大序列号距离（向上取整到 64 的倍数）
+	// 落后最高 SeqId 超过此距离的消息被拒绝；每个会话固定占用 WindowSize/8 字节
+	WindowSize int `mapstructure:"window_size" json:"window_size" yaml:"window_size"`
 
 	// 时间窗口（秒），超过此时间的 seqId 被视为过期
-	TimeWindow int64
+	TimeWindow int64 `mapstructure:"time_window" json:"time_window" yaml:"time_window"`
+
+	// 拒绝事件观察者（如 Prometheus 指标），可为空
+	Observer Observer `mapstructure:"-" json:"-" yaml:"-"`
 }
 
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
-		InitialSeqId: 1,     // 从 1 开始递增
-		CacheSize:    10000, // 缓存最近 10000 个 seqId
-		TimeWindow:   300,   // 5 分钟
+		InitialSeqId: 1,    // 从 1 开始递增
+		WindowSize:   1024, // 允许落后最高 SeqId 1024 以内的乱序消息
+		TimeWindow:   300,  // 5 分钟
 	}
 }
 
+// RejectReason 消息被拒绝的原因
+type RejectReason string
+
+const (
+	// RejectExpired 时间戳超出时间窗口
+	RejectExpired RejectReason = "expired"
+
+	// RejectTooOld SeqId 落后于防重放窗口
+	RejectTooOld RejectReason = "too_old"
+
+	// RejectDuplicate 窗口内已收到过相同的 SeqId
+	RejectDuplicate RejectReason = "duplicate"
+)
+
+// Observer 防重放拒绝事件观察者，同一进程内所有会话共享，实现需并发安全
+type Observer interface {
+	OnRejected(reason RejectReason)
+}
+
 // Manager SeqId 管理器接口
+// 每个会话使用独立的 Manager：发送序列号与接收方向的防重放窗口都按会话隔离
 type Manager interface {
 	// Next 生成下一个 SeqId
 	Next() uint32
@@ -42,11 +66,6 @@ type Manager interface {
 	Validate(seqId uint32, timestamp uint64) bool
 }
 
-// seqEntry 序列号条目
-type seqEntry struct {
-	timestamp int64 // Unix 时间戳（秒）
-}
-
 // managerImpl SeqId 管理器实现
 type managerImpl struct {
 	config *Config
@@ -54,13 +73,9 @@ type managerImpl struct {
 	// 当前序列号（原子操作）
 	current atomic.Uint32
 
-	// 已见过的 seqId（用于防重放）
-	mu   sync.RWMutex
-	seen map[uint32]*seqEntry
-
-	// LRU 队列（用于淘汰过期条目）
-	lruMu   sync.Mutex
-	lruList []uint32
+	// 接收方向的防重放窗口（只在本会话的解码路径上竞争）
+	mu     sync.Mutex
+	window *window
 }
 
 // New 创建新的 SeqId 管理器
@@ -70,11 +85,13 @@ func New(cfg *Config) (Manager, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to merge config: %w", err)
 	}
+	if mergedCfg.WindowSize < 0 {
+		return nil, fmt.Errorf("invalid window size: %d", mergedCfg.WindowSize)
+	}
 
 	m := &managerImpl{
-		config:  mergedCfg,
-		seen:    make(map[uint32]*seqEntry),
-		lruList: make([]uint32, 0, mergedCfg.CacheSize),
+		config: mergedCfg,
+		window: newWindow(mergedCfg.WindowSize),
 	}
 
 	// 初始化序列号（从配置的初始值开始，默认为 1）
@@ -101,59 +118,25 @@ func (m *managerImpl) Validate(seqId uint32, timestamp uint64) bool {
 
 	// 1. 检查时间窗口
 	if now-msgTime > m.config.TimeWindow || msgTime-now > m.config.TimeWindow {
+		m.reject(RejectExpired)
 		return false
 	}
 
-	// 2. 检查是否重复
+	// 2. 检查滑动窗口（重复或过旧的 SeqId 被拒绝）
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if entry, exists := m.seen[seqId]; exists {
-		// 已存在，检查时间戳是否相同（可能是重放）
-		if entry.timestamp == msgTime {
-			return false // 完全相同的 seqId + timestamp，判定为重放
-		}
-		// 时间戳不同，允许通过（SeqId 可能复用，但时间戳应不同）
-	}
-
-	// 3. 记录到 seen
-	m.seen[seqId] = &seqEntry{
-		timestamp: msgTime,
-	}
-
-	// 4. 添加到 LRU 队列
-	m.lruMu.Lock()
-	m.lruList = append(m.lruList, seqId)
+	reason := m.window.check(seqId)
+	m.mu.Unlock()
 
-	// 5. 如果超过缓存大小，清理旧条目
-	if len(m.lruList) > m.config.CacheSize {
-		m.evictOldEntries()
+	if reason != "" {
+		m.reject(reason)
+		return false
 	}
-	m.lruMu.Unlock()
-
 	return true
 }
 
-// evictOldEntries 清理过期条目（必须在持有 lruMu 锁时调用）
-func (m *managerImpl) evictOldEntries() {
-	now := time.Now().Unix()
-	evictCount := len(m.lruList) - m.config.CacheSize
-
-	if evictCount <= 0 {
-		return
-	}
-
-	// 从队列头部移除旧条目
-	toRemove := m.lruList[:evictCount]
-	m.lruList = m.lruList[evictCount:]
-
-	// 从 seen map 中删除（需要检查时间戳）
-	for _, seqId := range toRemove {
-		if entry, exists := m.seen[seqId]; exists {
-			// 只删除超过时间窗口的条目
-			if now-entry.timestamp > m.config.TimeWindow {
-				delete(m.seen, seqId)
-			}
-		}
+// reject 通知观察者消息被拒绝
+func (m *managerImpl) reject(reason RejectReason) {
+	if m.config.Observer != nil {
+		m.config.Observer.OnRejected(reason)
 	}
 }
@@ -0,0 +1,134 @@
+package seqid
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingObserver 按原因统计拒绝次数
+type countingObserver struct {
+	mu     sync.Mutex
+	counts map[RejectReason]int
+}
+
+func (o *countingObserver) OnRejected(reason RejectReason) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.counts == nil {
+		o.counts = make(map[RejectReason]int)
+	}
+	o.counts[reason]++
+}
+
+func newTestManager(t *testing.T, cfg *Config) Manager {
+	t.Helper()
+
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	return m
+}
+
+func TestWindow(t *testing.T) {
+	w := newWindow(100) // 向上取整为 128
+
+	steps := []struct {
+		seq  uint32
+		want RejectReason
+	}{
+		{10, ""},
+		{10, RejectDuplicate},
+		{12, ""},
+		{11, ""}, // 乱序到达
+		{11, RejectDuplicate},
+		{139, ""},
+		{13, ""},              // 空洞补收
+		{12, RejectDuplicate}, // 距最高 127，仍在窗口内
+		{11, RejectTooOld},    // 距最高 128，滑出窗口
+		{1139, ""},            // 跳跃超过窗口，位图整体清空
+		{139, RejectTooOld},   // 跳跃前的消息全部过旧
+		{1138, ""},            // 跳跃中间的空洞可以补收
+		{1138, RejectDuplicate},
+	}
+	for i, step := range steps {
+		if got := w.check(step.seq); got != step.want {
+			t.Fatalf("step %d: check(%d) = %q, want %q", i, step.seq, got, step.want)
+		}
+	}
+}
+
+func TestWindow_Wraparound(t *testing.T) {
+	w := newWindow(64)
+
+	for _, seq := range []uint32{math.MaxUint32 - 1, math.MaxUint32, 1, 2} {
+		if got := w.check(seq); got != "" {
+			t.Fatalf("check(%d) = %q across wraparound", seq, got)
+		}
+	}
+	if got := w.check(math.MaxUint32); got != RejectDuplicate {
+		t.Fatalf("replay before wraparound = %q, want duplicate", got)
+	}
+}
+
+func TestManager_Validate(t *testing.T) {
+	obs := &countingObserver{}
+	m := newTestManager(t, &Config{WindowSize: 64, Observer: obs})
+	now := uint64(time.Now().Unix())
+
+	if !m.Validate(5, now) || m.Validate(5, now) {
+		t.Fatalf("second delivery of the same seqId should be rejected")
+	}
+	// 重复判定只看 SeqId，修改时间戳不能绕过
+	if m.Validate(5, now-1) {
+		t.Fatalf("replay with a different timestamp should be rejected")
+	}
+	if m.Validate(6, now-3600) {
+		t.Fatalf("expired timestamp should be rejected")
+	}
+	if !m.Validate(6, now) {
+		t.Fatalf("seqId rejected for an expired timestamp should still be accepted later")
+	}
+	if !m.Validate(100, now) || m.Validate(6, now) {
+		t.Fatalf("seqId behind the window should be rejected")
+	}
+
+	want := map[RejectReason]int{RejectDuplicate: 2, RejectExpired: 1, RejectTooOld: 1}
+	for reason, n := range want {
+		if obs.counts[reason] != n {
+			t.Fatalf("%s rejections = %d, want %d", reason, obs.counts[reason], n)
+		}
+	}
+}
+
+func TestManager_Isolated(t *testing.T) {
+	a := newTestManager(t, nil)
+	b := newTestManager(t, nil)
+	now := uint64(time.Now().Unix())
+
+	// 两个会话的序列号重叠互不影响
+	for seq := uint32(1); seq <= 10; seq++ {
+		if !a.Validate(seq, now) || !b.Validate(seq, now) {
+			t.Fatalf("seqId %d rejected by an independent manager", seq)
+		}
+	}
+
+	if _, err := New(&Config{WindowSize: -1}); err == nil {
+		t.Fatalf("negative window size should be rejected")
+	}
+}
+
+func BenchmarkManager_Validate(b *testing.B) {
+	m, err := New(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	now := uint64(time.Now().Unix())
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Validate(uint32(i+1), now)
+	}
+}
@@ -0,0 +1,81 @@
+// framer/seqid/window.go
+// 滑动窗口防重放位图（参考 IPsec RFC 4303 / RFC 6479）
+package seqid
+
+// window 防重放滑动窗口
+// 记录已收到的最高 SeqId 以及它之前 size 个 SeqId 的接收情况，内存占用固定
+type window struct {
+	// 窗口大小（位），64 的整数倍
+	size uint32
+
+	// 环形位图，SeqId s 对应第 s % size 位
+	bits []uint64
+
+	// 已收到的最高 SeqId
+	top uint32
+
+	// 是否已收到第一条消息
+	started bool
+}
+
+// newWindow 创建滑动窗口，size 向上取整到 64 的倍数
+func newWindow(size int) *window {
+	words := (size + 63) / 64
+	if words < 1 {
+		words = 1
+	}
+	return &window{
+		size: uint32(words * 64),
+		bits: make([]uint64, words),
+	}
+}
+
+// check 检查 SeqId 并记录，接受时返回空字符串
+// 序列号按 RFC 1982 比较，兼容 uint32 回绕
+func (w *window) check(seq uint32) RejectReason {
+	if !w.started {
+		w.started = true
+		w.top = seq
+		w.set(seq)
+		return ""
+	}
+
+	diff := int32(seq - w.top)
+	if diff > 0 {
+		w.advance(seq, uint32(diff))
+		return ""
+	}
+
+	// 落后于最高 SeqId：窗口之外的无法判断是否重复，直接拒绝
+	if uint32(-int64(diff)) >= w.size {
+		return RejectTooOld
+	}
+	if w.test(seq) {
+		return RejectDuplicate
+	}
+	w.set(seq)
+	return ""
+}
+
+// advance 将窗口前移到 seq，清除滑出窗口的位
+func (w *window) advance(seq, diff uint32) {
+	if diff >= w.size {
+		clear(w.bits)
+	} else {
+		for s := w.top + 1; s != seq; s++ {
+			w.bits[(s%w.size)/64] &^= 1 << (s % 64)
+		}
+	}
+	w.top = seq
+	w.set(seq)
+}
+
+// test 返回 seq 对应的位是否已置位
+func (w *window) test(seq uint32) bool {
+	return w.bits[(seq%w.size)/64]&(1<<(seq%64)) != 0
+}
+
+// set 置位 seq 对应的位
+func (w *window) set(seq uint32) {
+	w.bits[(seq%w.size)/64] |= 1 << (seq % 64)
+}
@@ -0,0 +1,378 @@
+// framer/session_framer.go
+// 会话级 Framer：握手协商会话密钥并定期轮换
+package framer
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lk2023060901/xdooria/pkg/compress"
+	"github.com/lk2023060901/xdooria/pkg/config"
+	"github.com/lk2023060901/xdooria/pkg/crypto"
+	"github.com/lk2023060901/xdooria/pkg/network/framer/keyexchange"
+	"github.com/lk2023060901/xdooria/pkg/network/framer/seqid"
+	"github.com/lk2023060901/xdooria/pkg/network/framer/signer"
+
+	pb "github.com/lk2023060901/xdooria-proto-common"
+)
+
+var (
+	// ErrHandshakePending 客户端已发起握手但尚未完成，此时不能发送其他消息
+	ErrHandshakePending = errors.New("key exchange handshake pending")
+	// ErrHandshakeState 握手消息与当前状态不符（重复握手或未发起握手就收到响应）
+	ErrHandshakeState = errors.New("unexpected key exchange handshake message")
+)
+
+// 握手状态
+const (
+	handshakeNone     = iota // 未握手，使用静态密钥
+	handshakeAwaiting        // 客户端已发送请求，等待响应
+	handshakeAccepted        // 服务端已处理请求，等待发出响应后切换发送密钥
+	handshakeDone            // 双向均已切换到会话密钥
+)
+
+// SessionFramer 会话级 Framer
+//
+// 握手完成前使用进程级 Framer（静态密钥）编解码；握手消息本身也由静态密钥保护。
+// 握手流程（每个方向都是有序流，双方在同一条消息处切换密钥）：
+//
+//	客户端                                           服务端
+//	OP_HANDSHAKE_REQ(客户端公钥+支持的套件)  ───────▶  接收方向切换到会话密钥
+//	接收、发送方向切换到会话密钥  ◀───────  OP_HANDSHAKE_RES(服务端公钥+选定的套件)，发出后发送方向切换
+//
+// 套件编号各占 1 字节；只有公钥的请求来自不支持套件协商的旧客户端，服务端使用旧版套件并只回复公钥。
+//
+// 客户端在收到响应前不能发送其他消息（Encode 返回 ErrHandshakePending）。
+// 握手完成后，发送方按消息数或时间在某条消息上附加 MESSAGE_FLAGS_KEY_UPDATE，
+// 该消息之后双方各自推进该方向的密钥链，无需额外的往返。
+type SessionFramer struct {
+	base   Framer
+	config *Config
+
+	mu      sync.Mutex
+	state   int
+	suite   CipherSuite // 协商出的加密套件
+	keyPair *keyexchange.KeyPair
+	pending []byte // 服务端：响应发出后启用的发送密钥链根
+	send    *keyEpoch
+	recv    *keyEpoch
+	done    chan struct{}
+
+	// 所有密钥代共享，延迟到握手时创建
+	compressor compress.Compressor
+	seqIdMgr   seqid.Manager
+}
+
+// keyEpoch 单个方向上的一代密钥
+type keyEpoch struct {
+	secret []byte
+	framer *frameImpl
+	count  uint64
+	since  time.Time
+}
+
+// NewSession 创建会话级 Framer
+// base 为握手完成前使用的 Framer（通常由 ForSession 派生）；cfg 为创建 base 的配置，会话密钥沿用其压缩等设置
+func NewSession(base Framer, cfg *Config) *SessionFramer {
+	return &SessionFramer{
+		base:   base,
+		config: cfg,
+		done:   make(chan struct{}),
+	}
+}
+
+// Initiate 客户端发起握手，返回 OP_HANDSHAKE_REQ 的负载（客户端公钥+支持的套件）
+func (f *SessionFramer) Initiate() ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.state != handshakeNone {
+		return nil, ErrHandshakeState
+	}
+	keyPair, err := keyexchange.GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	f.keyPair = keyPair
+	f.state = handshakeAwaiting
+
+	payload := keyPair.PublicKey()
+	for _, suite := range f.cipherSuites() {
+		payload = append(payload, cipherSuiteIDs[suite])
+	}
+	return payload, nil
+}
+
+// Accept 服务端处理 OP_HANDSHAKE_REQ，返回 OP_HANDSHAKE_RES 的负载（服务端公钥+选定的套件）
+// 接收方向立即切换到会话密钥；发送方向在响应编码之后切换
+func (f *SessionFramer) Accept(request []byte) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.state != handshakeNone {
+		return nil, ErrHandshakeState
+	}
+	clientPublicKey, offered, err := splitHandshakePayload(request)
+	if err != nil {
+		return nil, err
+	}
+	f.suite = negotiateCipherSuite(f.cipherSuites(), offered)
+
+	keyPair, err := keyexchange.GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := keyPair.Exchange(clientPublicKey, false)
+	if err != nil {
+		return nil, err
+	}
+	if f.recv, err = f.newEpoch(secrets.Recv); err != nil {
+		return nil, err
+	}
+	f.pending = secrets.Send
+	f.state = handshakeAccepted
+
+	response := keyPair.PublicKey()
+	if len(request) > keyexchange.PublicKeySize {
+		response = append(response, cipherSuiteIDs[f.suite])
+	}
+	return response, nil
+}
+
+// Complete 客户端处理 OP_HANDSHAKE_RES，双向切换到会话密钥
+func (f *SessionFramer) Complete(response []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.state != handshakeAwaiting {
+		return ErrHandshakeState
+	}
+	serverPublicKey, selected, err := splitHandshakePayload(response)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(selected) == 0:
+		// 服务端不支持套件协商；旧版套件始终可用
+		f.suite = CipherSuiteLegacy
+	case len(selected) == 1 && (selected[0] == CipherSuiteLegacy || slices.Contains(f.cipherSuites(), selected[0])):
+		f.suite = selected[0]
+	default:
+		return fmt.Errorf("%w: server selected unsupported cipher suite", ErrHandshakeState)
+	}
+
+	secrets, err := f.keyPair.Exchange(serverPublicKey, true)
+	if err != nil {
+		return err
+	}
+	if f.recv, err = f.newEpoch(secrets.Recv); err != nil {
+		return err
+	}
+	if f.send, err = f.newEpoch(secrets.Send); err != nil {
+		return err
+	}
+	f.keyPair = nil
+	f.finish()
+	return nil
+}
+
+// CipherSuite 返回协商出的加密套件，握手完成前返回空字符串
+func (f *SessionFramer) CipherSuite() CipherSuite {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.suite
+}
+
+// cipherSuites 返回本端支持的套件（按偏好排序）
+func (f *SessionFramer) cipherSuites() []CipherSuite {
+	if f.config == nil || len(f.config.CipherSuites) == 0 {
+		return []CipherSuite{CipherSuiteLegacy}
+	}
+	return f.config.CipherSuites
+}
+
+// splitHandshakePayload 拆分握手负载：公钥 + 套件编号列表（未知编号忽略）
+func splitHandshakePayload(payload []byte) ([]byte, []CipherSuite, error) {
+	if len(payload) < keyexchange.PublicKeySize {
+		return nil, nil, fmt.Errorf("invalid handshake payload size: %d", len(payload))
+	}
+	var suites []CipherSuite
+	for _, id := range payload[keyexchange.PublicKeySize:] {
+		if suite, ok := cipherSuiteByID(id); ok {
+			suites = append(suites, suite)
+		}
+	}
+	return payload[:keyexchange.PublicKeySize], suites, nil
+}
+
+// Done 握手完成时关闭
+func (f *SessionFramer) Done() <-chan struct{} {
+	return f.done
+}
+
+// Established 是否已切换到会话密钥
+func (f *SessionFramer) Established() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state == handshakeDone
+}
+
+// finish 标记握手完成（必须在持有 mu 锁时调用）
+func (f *SessionFramer) finish() {
+	f.state = handshakeDone
+	close(f.done)
+}
+
+// Encode 编码消息为 Envelope
+func (f *SessionFramer) Encode(op uint32, payload []byte) (*pb.Envelope, error) {
+	return f.EncodeRPC(op, 0, payload)
+}
+
+// EncodeRPC 编码携带 RPC 关联 ID 的消息
+func (f *SessionFramer) EncodeRPC(op uint32, rpcId uint32, payload []byte) (*pb.Envelope, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.send == nil {
+		if f.state == handshakeAwaiting && pb.OpCode(op) != pb.OpCode_OP_HANDSHAKE_REQ {
+			return nil, ErrHandshakePending
+		}
+		env, err := EncodeRPC(f.base, op, rpcId, payload)
+		if err != nil {
+			return nil, err
+		}
+		// 响应使用静态密钥发出，之后的消息使用会话密钥
+		if f.state == handshakeAccepted && pb.OpCode(op) == pb.OpCode_OP_HANDSHAKE_RES {
+			if f.send, err = f.newEpoch(f.pending); err != nil {
+				return nil, err
+			}
+			f.pending = nil
+			f.finish()
+		}
+		return env, nil
+	}
+
+	rotate := f.rotateDue(f.send)
+	flags := uint32(pb.MessageFlags_MESSAGE_FLAGS_NONE)
+	if rotate {
+		flags |= uint32(pb.MessageFlags_MESSAGE_FLAGS_KEY_UPDATE)
+	}
+	env, err := f.send.framer.encode(op, rpcId, payload, flags)
+	if err != nil {
+		return nil, err
+	}
+	f.send.count++
+
+	if rotate {
+		if f.send, err = f.nextEpoch(f.send); err != nil {
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+// Decode 解码 Envelope 并验证
+func (f *SessionFramer) Decode(envelope *pb.Envelope) (op uint32, payload []byte, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.recv == nil {
+		return f.base.Decode(envelope)
+	}
+
+	op, payload, err = f.recv.framer.Decode(envelope)
+	if err != nil {
+		return 0, nil, err
+	}
+	// 签名（或 AEAD 认证）已校验，标志位可信：对端在这条消息之后切换到下一代密钥
+	if envelope.Header.Flags&uint32(pb.MessageFlags_MESSAGE_FLAGS_KEY_UPDATE) != 0 {
+		if f.recv, err = f.nextEpoch(f.recv); err != nil {
+			return 0, nil, err
+		}
+	}
+	return op, payload, nil
+}
+
+// rotateDue 发送方向是否应在本条消息后轮换密钥
+func (f *SessionFramer) rotateDue(e *keyEpoch) bool {
+	kx := f.config.KeyExchange
+	if kx.RotateMessages > 0 && e.count+1 >= kx.RotateMessages {
+		return true
+	}
+	return kx.RotateInterval > 0 && time.Since(e.since) >= kx.RotateInterval
+}
+
+// nextEpoch 推进密钥链，生成下一代密钥
+func (f *SessionFramer) nextEpoch(e *keyEpoch) (*keyEpoch, error) {
+	secret, err := keyexchange.Next(e.secret)
+	if err != nil {
+		return nil, err
+	}
+	return f.newEpoch(secret)
+}
+
+// newEpoch 使用密钥链节点派生的密钥创建一代 Framer（必须在持有 mu 锁时调用）
+func (f *SessionFramer) newEpoch(secret []byte) (*keyEpoch, error) {
+	if f.compressor == nil {
+		if err := f.initShared(); err != nil {
+			return nil, err
+		}
+	}
+
+	encryptKey, signKey, err := keyexchange.Keys(secret)
+	if err != nil {
+		return nil, err
+	}
+	aes, err := crypto.NewAES(encryptKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES: %w", err)
+	}
+
+	// 会话密钥始终启用加密与认证，使用协商出的套件
+	cfg := *f.config
+	cfg.EnableEncrypt = true
+	cfg.EncryptKey = encryptKey
+	cfg.SignKey = signKey
+	cfg.CipherSuites = []CipherSuite{f.suite}
+
+	fr := &frameImpl{
+		config:     &cfg,
+		signer:     signer.NewHMACSigner(crypto.NewHMACHasher(signKey)),
+		aes:        aes,
+		compressor: f.compressor,
+		seqIdMgr:   f.seqIdMgr,
+	}
+	if err := fr.initCipherSuites(); err != nil {
+		return nil, err
+	}
+
+	return &keyEpoch{
+		secret: secret,
+		since:  time.Now(),
+		framer: fr,
+	}, nil
+}
+
+// initShared 合并配置并创建各代密钥共享的压缩器与 SeqId 管理器
+func (f *SessionFramer) initShared() error {
+	newCfg, err := config.MergeConfig(DefaultConfig(), f.config)
+	if err != nil {
+		return fmt.Errorf("failed to merge config: %w", err)
+	}
+	compressor, err := compress.New(newCfg.CompressType)
+	if err != nil {
+		return fmt.Errorf("failed to create compressor: %w", err)
+	}
+	seqIdMgr, err := seqid.New(newCfg.SeqIdConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create seqid manager: %w", err)
+	}
+
+	f.config = newCfg
+	f.compressor = compressor
+	f.seqIdMgr = seqIdMgr
+	return nil
+}
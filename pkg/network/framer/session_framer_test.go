@@ -0,0 +1,171 @@
+package framer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	pb "github.com/lk2023060901/xdooria-proto-common"
+)
+
+const testOp = 2000
+
+func newTestSessionFramer(t *testing.T, cfg *Config) *SessionFramer {
+	t.Helper()
+
+	base, err := New(cfg)
+	if err != nil {
+		t.Fatalf("failed to create framer: %v", err)
+	}
+	return NewSession(base, cfg)
+}
+
+// handshake 在内存中完成一次握手，返回客户端与服务端 Framer
+func handshake(t *testing.T, cfg *Config) (client, server *SessionFramer) {
+	t.Helper()
+
+	client = newTestSessionFramer(t, cfg)
+	server = newTestSessionFramer(t, cfg)
+
+	clientPub, err := client.Initiate()
+	if err != nil {
+		t.Fatalf("initiate failed: %v", err)
+	}
+	op, payload := transfer(t, client, server, uint32(pb.OpCode_OP_HANDSHAKE_REQ), clientPub)
+	if op != uint32(pb.OpCode_OP_HANDSHAKE_REQ) {
+		t.Fatalf("op = %d, want handshake request", op)
+	}
+
+	// 等待响应期间客户端不能发送业务消息
+	if _, err := client.Encode(testOp, []byte("early")); !errors.Is(err, ErrHandshakePending) {
+		t.Fatalf("encode before handshake err = %v, want ErrHandshakePending", err)
+	}
+
+	serverPub, err := server.Accept(payload)
+	if err != nil {
+		t.Fatalf("accept failed: %v", err)
+	}
+	_, payload = transfer(t, server, client, uint32(pb.OpCode_OP_HANDSHAKE_RES), serverPub)
+	if err := client.Complete(payload); err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+
+	if !client.Established() || !server.Established() {
+		t.Fatalf("handshake not established: client=%v server=%v", client.Established(), server.Established())
+	}
+	return client, server
+}
+
+// transfer 由 from 编码、to 解码一条消息
+func transfer(t *testing.T, from, to Framer, op uint32, payload []byte) (uint32, []byte) {
+	t.Helper()
+
+	env, err := from.Encode(op, payload)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	gotOp, got, err := to.Decode(env)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	return gotOp, got
+}
+
+func TestSessionFramer_Handshake(t *testing.T) {
+	cfg := &Config{SignKey: []byte("static-sign-key"), KeyExchange: KeyExchangeConfig{Enabled: true}}
+	client, server := handshake(t, cfg)
+
+	for i := 0; i < 3; i++ {
+		msg := []byte(fmt.Sprintf("c2s-%d", i))
+		if _, got := transfer(t, client, server, testOp, msg); !bytes.Equal(got, msg) {
+			t.Fatalf("c2s payload = %q, want %q", got, msg)
+		}
+		msg = []byte(fmt.Sprintf("s2c-%d", i))
+		if _, got := transfer(t, server, client, testOp, msg); !bytes.Equal(got, msg) {
+			t.Fatalf("s2c payload = %q, want %q", got, msg)
+		}
+	}
+
+	// 会话密钥加密，持有静态密钥无法解码
+	env, err := client.Encode(testOp, []byte("secret"))
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if env.Header.Flags&uint32(pb.MessageFlags_MESSAGE_FLAGS_ENCRYPTED) == 0 || bytes.Contains(env.Payload, []byte("secret")) {
+		t.Fatalf("session traffic should be encrypted")
+	}
+	static, _ := New(cfg)
+	if _, _, err := static.Decode(env); err == nil {
+		t.Fatalf("static keys should not decode session traffic")
+	}
+
+	// 另一个会话的密钥不同
+	_, other := handshake(t, cfg)
+	if _, _, err := other.Decode(env); err == nil {
+		t.Fatalf("another session should not decode this session's traffic")
+	}
+}
+
+func TestSessionFramer_Rotation(t *testing.T) {
+	cfg := &Config{KeyExchange: KeyExchangeConfig{Enabled: true, RotateMessages: 3}}
+	client, server := handshake(t, cfg)
+
+	first := client.send.secret
+	for i := 1; i <= 10; i++ {
+		env, err := client.Encode(testOp, []byte{byte(i)})
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		rotated := env.Header.Flags&uint32(pb.MessageFlags_MESSAGE_FLAGS_KEY_UPDATE) != 0
+		if rotated != (i%3 == 0) {
+			t.Fatalf("message %d key update flag = %v", i, rotated)
+		}
+		if _, got, err := server.Decode(env); err != nil || got[0] != byte(i) {
+			t.Fatalf("message %d decode failed: %v", i, err)
+		}
+	}
+	if bytes.Equal(client.send.secret, first) || !bytes.Equal(client.send.secret, server.recv.secret) {
+		t.Fatalf("both sides should have ratcheted to the same key")
+	}
+
+	// 轮换之后另一个方向不受影响
+	if _, got := transfer(t, server, client, testOp, []byte("ok")); string(got) != "ok" {
+		t.Fatalf("s2c payload = %q", got)
+	}
+}
+
+func TestSessionFramer_TamperedKeyUpdate(t *testing.T) {
+	cfg := &Config{KeyExchange: KeyExchangeConfig{Enabled: true}}
+	client, server := handshake(t, cfg)
+
+	// 篡改标志位会导致签名校验失败，不能诱导接收方错误地推进密钥
+	env, err := client.Encode(testOp, []byte("x"))
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	env.Header.Flags |= uint32(pb.MessageFlags_MESSAGE_FLAGS_KEY_UPDATE)
+	if _, _, err := server.Decode(env); err == nil {
+		t.Fatalf("tampered flags should fail verification")
+	}
+	if _, got := transfer(t, client, server, testOp, []byte("y")); string(got) != "y" {
+		t.Fatalf("payload = %q after rejected message", got)
+	}
+}
+
+func TestSessionFramer_HandshakeState(t *testing.T) {
+	cfg := &Config{KeyExchange: KeyExchangeConfig{Enabled: true}}
+	client, server := handshake(t, cfg)
+
+	if _, err := server.Accept(make([]byte, 32)); !errors.Is(err, ErrHandshakeState) {
+		t.Fatalf("repeated accept err = %v, want ErrHandshakeState", err)
+	}
+	if err := client.Complete(make([]byte, 32)); !errors.Is(err, ErrHandshakeState) {
+		t.Fatalf("repeated complete err = %v, want ErrHandshakeState", err)
+	}
+
+	fresh := newTestSessionFramer(t, cfg)
+	if _, err := fresh.Accept([]byte("short")); err == nil {
+		t.Fatalf("invalid public key should be rejected")
+	}
+}
@@ -56,16 +56,22 @@ func (c *Connector) Connect(ctx context.Context, addr string) (session.Session,
 				c.handler.OnError(s, err)
 				continue
 			}
-			// 构建解码后的 Envelope
-			decodedEnv := &common.Envelope{
-				Header:  &common.MessageHeader{Op: op},
+			// 构建解码后的 Envelope，批量消息展开为多条
+			envs, err := session.Unbatch(&common.Envelope{
+				Header:  &common.MessageHeader{Op: op, RpcId: envelope.Header.RpcId},
 				Payload: payload,
-			}
-			if err := s.PushRecv(decodedEnv); err != nil {
+			})
+			if err != nil {
 				c.handler.OnError(s, err)
 				continue
 			}
-			c.handler.OnMessage(s, decodedEnv)
+			for _, decodedEnv := range envs {
+				if err := s.PushRecv(decodedEnv); err != nil {
+					c.handler.OnError(s, err)
+					continue
+				}
+				c.handler.OnMessage(s, decodedEnv)
+			}
 		}
 	})
 
@@ -57,15 +57,21 @@ func (a *Acceptor) Stream(stream common.CommonService_StreamServer) error {
 			a.handler.OnError(s, err)
 			continue
 		}
-		// 构建解码后的 Envelope
-		decodedEnv := &common.Envelope{
-			Header:  &common.MessageHeader{Op: op},
+		// 构建解码后的 Envelope，批量消息展开为多条
+		envs, err := session.Unbatch(&common.Envelope{
+			Header:  &common.MessageHeader{Op: op, RpcId: envelope.Header.RpcId},
 			Payload: payload,
-		}
-		if err := s.PushRecv(decodedEnv); err != nil {
+		})
+		if err != nil {
 			a.handler.OnError(s, err)
 			continue
 		}
-		a.handler.OnMessage(s, decodedEnv)
+		for _, decodedEnv := range envs {
+			if err := s.PushRecv(decodedEnv); err != nil {
+				a.handler.OnError(s, err)
+				continue
+			}
+			a.handler.OnMessage(s, decodedEnv)
+		}
 	}
 }
\ No newline at end of file
@@ -0,0 +1,168 @@
+package kcp
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lk2023060901/xdooria/pkg/network/session"
+	"github.com/lk2023060901/xdooria/pkg/util/conc"
+	kcpgo "github.com/xtaci/kcp-go/v5"
+)
+
+// Acceptor 实现 session.Acceptor 接口，基于 kcp-go 监听 UDP 端口。
+type Acceptor struct {
+	config        *ServerConfig
+	sessionConfig *session.Config
+	handler       session.SessionHandler
+	codec         *Codec
+	idle          session.IdlePolicy
+	sessions      sync.Map // sessionID -> *KCPSession，用于定时检查读写超时
+
+	mu       sync.Mutex
+	listener *kcpgo.Listener
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewAcceptor 创建一个新的 KCP 监听器。
+func NewAcceptor(cfg *ServerConfig, sessCfg *session.Config, handler session.SessionHandler) *Acceptor {
+	if handler == nil {
+		handler = &session.NopSessionHandler{}
+	}
+	return &Acceptor{
+		config:        cfg,
+		sessionConfig: sessCfg,
+		handler:       handler,
+		codec:         NewCodec(cfg.MaxMessageSize),
+		idle: session.IdlePolicy{
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			HeartbeatInterval: cfg.HeartbeatInterval,
+		},
+	}
+}
+
+// Start 开始监听，接受连接与超时检查在后台协程中进行。
+func (a *Acceptor) Start() error {
+	if err := a.config.Validate(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.listener != nil {
+		return ErrServerAlreadyStarted
+	}
+
+	p := &a.config.Protocol
+	l, err := kcpgo.ListenWithOptions(a.config.Addr, nil, p.DataShards, p.ParityShards)
+	if err != nil {
+		return fmt.Errorf("kcp listen %s failed: %w", a.config.Addr, err)
+	}
+	if a.config.ReadBufferSize > 0 {
+		_ = l.SetReadBuffer(a.config.ReadBufferSize)
+	}
+	if a.config.WriteBufferSize > 0 {
+		_ = l.SetWriteBuffer(a.config.WriteBufferSize)
+	}
+
+	a.listener = l
+	a.done = make(chan struct{})
+
+	a.wg.Add(2)
+	conc.Go(func() (struct{}, error) {
+		defer a.wg.Done()
+		a.acceptLoop(l, a.done)
+		return struct{}{}, nil
+	})
+	conc.Go(func() (struct{}, error) {
+		defer a.wg.Done()
+		a.tickLoop(a.done)
+		return struct{}{}, nil
+	})
+	return nil
+}
+
+// Stop 停止监听并关闭所有会话，等待 OnClosed 回调完成。
+func (a *Acceptor) Stop() error {
+	a.mu.Lock()
+	l := a.listener
+	if l == nil {
+		a.mu.Unlock()
+		return nil
+	}
+	a.listener = nil
+	close(a.done)
+	a.mu.Unlock()
+
+	// 监听关闭不会关闭已接受的会话，需要逐个关闭
+	err := l.Close()
+	a.sessions.Range(func(_, v any) bool {
+		_ = v.(*KCPSession).Close()
+		return true
+	})
+	a.wg.Wait()
+	return err
+}
+
+// Addr 返回实际监听的地址，未启动时返回 nil。
+func (a *Acceptor) Addr() net.Addr {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.listener == nil {
+		return nil
+	}
+	return a.listener.Addr()
+}
+
+// acceptLoop 接受新会话，每个会话由独立的协程读取。
+func (a *Acceptor) acceptLoop(l *kcpgo.Listener, done <-chan struct{}) {
+	for {
+		// 只有监听关闭时才会返回错误
+		conn, err := l.AcceptKCP()
+		if err != nil {
+			return
+		}
+		applyProtocol(conn, &a.config.Protocol)
+
+		s := NewKCPSession(conn, a.sessionConfig, a.codec)
+		a.sessions.Store(s.ID(), s)
+		a.handler.OnOpened(s)
+		select {
+		case <-done:
+			// Stop 可能已经遍历过会话列表，由这里关闭停止期间接受的会话
+			_ = s.Close()
+		default:
+		}
+
+		a.wg.Add(1)
+		conc.Go(func() (struct{}, error) {
+			defer a.wg.Done()
+			err := s.serve(a.handler)
+			a.sessions.Delete(s.ID())
+			a.handler.OnClosed(s, err)
+			return struct{}{}, nil
+		})
+	}
+}
+
+// tickLoop 定期检查所有会话的读写超时并发送心跳。
+func (a *Acceptor) tickLoop(done <-chan struct{}) {
+	ticker := time.NewTicker(session.IdleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			a.sessions.Range(func(_, v any) bool {
+				session.CheckIdle(v.(*KCPSession), now, a.idle)
+				return true
+			})
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package kcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/pkg/network/framer"
+	"github.com/lk2023060901/xdooria/pkg/network/session"
+)
+
+// closeEvent 会话关闭事件
+type closeEvent struct {
+	sess session.Session
+	err  error
+}
+
+// recordHandler 记录会话事件，收到的消息原样回显给对端
+type recordHandler struct {
+	session.NopSessionHandler
+	echo     bool
+	opened   chan session.Session
+	closed   chan closeEvent
+	messages chan *common.Envelope
+}
+
+func newRecordHandler(echo bool) *recordHandler {
+	return &recordHandler{
+		echo:     echo,
+		opened:   make(chan session.Session, 4),
+		closed:   make(chan closeEvent, 4),
+		messages: make(chan *common.Envelope, 16),
+	}
+}
+
+func (h *recordHandler) OnOpened(s session.Session) {
+	h.opened <- s
+}
+
+func (h *recordHandler) OnClosed(s session.Session, err error) {
+	h.closed <- closeEvent{sess: s, err: err}
+}
+
+func (h *recordHandler) OnMessage(s session.Session, env *common.Envelope) {
+	h.messages <- env
+	if h.echo {
+		_ = s.Send(s.Context(), env)
+	}
+}
+
+// newSessionConfig 返回启用会话密钥协商的会话配置
+func newSessionConfig(t *testing.T) *session.Config {
+	t.Helper()
+
+	cfg := &framer.Config{KeyExchange: framer.KeyExchangeConfig{Enabled: true}}
+	f, err := framer.New(cfg)
+	if err != nil {
+		t.Fatalf("failed to create framer: %v", err)
+	}
+	return &session.Config{Framer: f, FramerConfig: cfg}
+}
+
+// startAcceptor 在本机随机端口启动 KCP 监听
+func startAcceptor(t *testing.T, cfg *ServerConfig, handler session.SessionHandler) string {
+	t.Helper()
+
+	cfg.Addr = "127.0.0.1:0"
+	a := NewAcceptor(cfg, newSessionConfig(t), handler)
+	if err := a.Start(); err != nil {
+		t.Fatalf("failed to start acceptor: %v", err)
+	}
+	t.Cleanup(func() { _ = a.Stop() })
+	return a.Addr().String()
+}
+
+// dial 连接到 KCP 监听并完成会话密钥握手
+func dial(t *testing.T, addr string, cfg *ClientConfig, handler session.SessionHandler) session.Session {
+	t.Helper()
+
+	cfg.DialTimeout = 2 * time.Second
+	s, err := NewConnector(cfg, newSessionConfig(t), handler).Connect(context.Background(), addr)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestKCP_HandshakeAndEcho(t *testing.T) {
+	server := newRecordHandler(true)
+	addr := startAcceptor(t, &ServerConfig{}, server)
+
+	client := newRecordHandler(false)
+	s := dial(t, addr, &ClientConfig{}, client)
+
+	// 握手完成后业务消息经会话密钥加密传输，握手消息本身不交给业务处理器
+	env := &common.Envelope{Header: &common.MessageHeader{Op: 1001}, Payload: []byte("hello")}
+	if err := s.Send(context.Background(), env); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	for name, h := range map[string]*recordHandler{"server": server, "client": client} {
+		select {
+		case got := <-h.messages:
+			if got.Header.Op != 1001 || string(got.Payload) != "hello" {
+				t.Fatalf("%s received op %d payload %q", name, got.Header.Op, got.Payload)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s did not receive the message", name)
+		}
+	}
+}
+
+func TestKCP_HeartbeatAndReadTimeout(t *testing.T) {
+	server := newRecordHandler(false)
+	addr := startAcceptor(t, &ServerConfig{ReadTimeout: 2500 * time.Millisecond}, server)
+
+	// 主动发送心跳的客户端保持连接，不发送任何数据的客户端因读超时被断开
+	alive := dial(t, addr, &ClientConfig{HeartbeatInterval: 500 * time.Millisecond}, newRecordHandler(false))
+	dial(t, addr, &ClientConfig{}, newRecordHandler(false))
+
+	serverSessions := make(map[session.Session]bool)
+	for range 2 {
+		select {
+		case s := <-server.opened:
+			serverSessions[s] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("server did not open both sessions")
+		}
+	}
+
+	// UDP 没有断开通知，服务端靠读超时发现客户端离线，关闭原因经 OnClosed 传递
+	select {
+	case ev := <-server.closed:
+		if !errors.Is(ev.err, session.ErrReadTimeout) {
+			t.Fatalf("server close reason = %v, want ErrReadTimeout", ev.err)
+		}
+		if !serverSessions[ev.sess] {
+			t.Fatalf("closed session %s was never opened", ev.sess.ID())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("silent session was not closed by read timeout")
+	}
+
+	// 心跳保持的会话仍然可用
+	select {
+	case ev := <-server.closed:
+		t.Fatalf("heartbeat session closed: %v", ev.err)
+	default:
+	}
+	if alive.Context().Err() != nil {
+		t.Fatalf("heartbeat client session closed")
+	}
+}
@@ -0,0 +1,79 @@
+package kcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// FrameHeaderSize 帧头（长度字段）字节数
+//
+// KCP 以流模式传输，帧格式与 TCP 相同：
+//
+//	+----------------------+---------------------------+
+//	| Length (4B, 大端)     | Body (Envelope protobuf)  |
+//	+----------------------+---------------------------+
+//
+// Length 为 Body 的字节数，不包含帧头本身
+const FrameHeaderSize = 4
+
+// DefaultMaxMessageSize 默认单帧最大字节数（不含帧头）
+const DefaultMaxMessageSize = 1024 * 1024
+
+// Codec 长度前缀流式编解码器
+// KCP 消息模式下单条消息最多 255 个分片（约 340KB），因此使用流模式并按长度头切分
+type Codec struct {
+	maxMessageSize int
+}
+
+// NewCodec 创建编解码器，maxMessageSize <= 0 时使用 DefaultMaxMessageSize
+func NewCodec(maxMessageSize int) *Codec {
+	if maxMessageSize <= 0 {
+		maxMessageSize = DefaultMaxMessageSize
+	}
+	return &Codec{maxMessageSize: maxMessageSize}
+}
+
+// MaxMessageSize 返回单帧最大字节数
+func (c *Codec) MaxMessageSize() int {
+	return c.maxMessageSize
+}
+
+// Encode 为消息体添加长度头
+func (c *Codec) Encode(body []byte) ([]byte, error) {
+	if len(body) > c.maxMessageSize {
+		return nil, fmt.Errorf("%w: %d > %d", ErrMessageTooBig, len(body), c.maxMessageSize)
+	}
+
+	frame := make([]byte, FrameHeaderSize+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(len(body)))
+	copy(frame[FrameHeaderSize:], body)
+	return frame, nil
+}
+
+// ReadFrame 从流中读取一个完整的帧，返回消息体
+// 消息体复用 buf 的内存（容量不足时重新分配），调用方可以将返回值作为下一次调用的 buf
+// 返回 ErrPacketTooLarge 时已无法定位后续帧的边界，调用方应关闭连接
+func (c *Codec) ReadFrame(r io.Reader, buf []byte) ([]byte, error) {
+	if cap(buf) < FrameHeaderSize {
+		buf = make([]byte, FrameHeaderSize)
+	}
+	header := buf[:FrameHeaderSize]
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header)
+	if uint64(size) > uint64(c.maxMessageSize) {
+		return nil, fmt.Errorf("%w: %d > %d", ErrPacketTooLarge, size, c.maxMessageSize)
+	}
+
+	if cap(buf) < int(size) {
+		buf = make([]byte, size)
+	}
+	body := buf[:size]
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
@@ -0,0 +1,67 @@
+package kcp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func encodeAll(t testing.TB, c *Codec, bodies ...[]byte) []byte {
+	t.Helper()
+
+	var stream []byte
+	for _, body := range bodies {
+		frame, err := c.Encode(body)
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		stream = append(stream, frame...)
+	}
+	return stream
+}
+
+func TestCodec_ReadFrame(t *testing.T) {
+	c := NewCodec(1024)
+	bodies := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte("x"), 1024), []byte("world")}
+
+	// 每次只读 1 字节，模拟帧被拆分到多个 KCP 分片中
+	r := iotest.OneByteReader(bytes.NewReader(encodeAll(t, c, bodies...)))
+
+	var buf []byte
+	for i, want := range bodies {
+		body, err := c.ReadFrame(r, buf)
+		if err != nil {
+			t.Fatalf("frame %d: read failed: %v", i, err)
+		}
+		if !bytes.Equal(body, want) {
+			t.Fatalf("frame %d: got %d bytes, want %d", i, len(body), len(want))
+		}
+		buf = body
+	}
+
+	if _, err := c.ReadFrame(r, buf); !errors.Is(err, io.EOF) {
+		t.Fatalf("read after last frame = %v, want EOF", err)
+	}
+}
+
+func TestCodec_Limits(t *testing.T) {
+	c := NewCodec(4)
+
+	if _, err := c.Encode([]byte("12345")); !errors.Is(err, ErrMessageTooBig) {
+		t.Fatalf("encode oversized body = %v, want ErrMessageTooBig", err)
+	}
+
+	// 长度头超过上限时不读取消息体
+	stream := encodeAll(t, NewCodec(0), []byte("12345"))
+	if _, err := c.ReadFrame(bytes.NewReader(stream), nil); !errors.Is(err, ErrPacketTooLarge) {
+		t.Fatalf("read oversized frame = %v, want ErrPacketTooLarge", err)
+	}
+
+	// 帧不完整时返回 ErrUnexpectedEOF
+	frame := encodeAll(t, c, []byte("1234"))
+	if _, err := c.ReadFrame(bytes.NewReader(frame[:len(frame)-1]), nil); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("read truncated frame = %v, want ErrUnexpectedEOF", err)
+	}
+}
@@ -0,0 +1,179 @@
+package kcp
+
+import (
+	"fmt"
+	"time"
+)
+
+// ProtocolConfig KCP 协议参数，服务端与客户端应保持一致
+type ProtocolConfig struct {
+	// 是否启用 nodelay 模式（RTO 不翻倍、最小 RTO 更低）
+	NoDelay bool `mapstructure:"no_delay" json:"no_delay" yaml:"no_delay"`
+
+	// 协议内部刷新间隔，越小延迟越低、CPU 占用越高
+	Interval time.Duration `mapstructure:"interval" json:"interval" yaml:"interval"`
+
+	// 快速重传阈值：被跳过多少次 ACK 后立即重传，0 表示不启用快速重传
+	Resend int `mapstructure:"resend" json:"resend" yaml:"resend"`
+
+	// 是否关闭拥塞控制
+	NoCongestion bool `mapstructure:"no_congestion" json:"no_congestion" yaml:"no_congestion"`
+
+	// 发送窗口大小（包数）
+	SendWindow int `mapstructure:"send_window" json:"send_window" yaml:"send_window"`
+
+	// 接收窗口大小（包数）
+	RecvWindow int `mapstructure:"recv_window" json:"recv_window" yaml:"recv_window"`
+
+	// 最大传输单元（UDP 包字节数）
+	MTU int `mapstructure:"mtu" json:"mtu" yaml:"mtu"`
+
+	// 是否立即发送 ACK（不等待下一次刷新）
+	AckNoDelay bool `mapstructure:"ack_no_delay" json:"ack_no_delay" yaml:"ack_no_delay"`
+
+	// 前向纠错（FEC）数据分片数，0 表示不启用 FEC
+	DataShards int `mapstructure:"data_shards" json:"data_shards" yaml:"data_shards"`
+
+	// 前向纠错（FEC）校验分片数
+	ParityShards int `mapstructure:"parity_shards" json:"parity_shards" yaml:"parity_shards"`
+}
+
+// DefaultProtocolConfig 返回默认协议参数（快速模式）
+func DefaultProtocolConfig() ProtocolConfig {
+	return ProtocolConfig{
+		NoDelay:      true,
+		Interval:     10 * time.Millisecond,
+		Resend:       2,
+		NoCongestion: true,
+		SendWindow:   256,
+		RecvWindow:   256,
+		MTU:          1350,
+	}
+}
+
+// validate 补全未设置的协议参数
+func (c *ProtocolConfig) validate() error {
+	if c.Interval <= 0 {
+		c.Interval = 10 * time.Millisecond
+	}
+	if c.SendWindow <= 0 {
+		c.SendWindow = 256
+	}
+	if c.RecvWindow <= 0 {
+		c.RecvWindow = 256
+	}
+	if c.MTU <= 0 {
+		c.MTU = 1350
+	}
+	if c.DataShards < 0 || c.ParityShards < 0 {
+		return fmt.Errorf("%w: fec shards must not be negative", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// ServerConfig 服务端配置
+type ServerConfig struct {
+	// 监听地址，如 "0.0.0.0:9100"
+	Addr string `mapstructure:"addr" json:"addr" yaml:"addr"`
+
+	// UDP Socket 读缓冲区大小
+	ReadBufferSize int `mapstructure:"read_buffer_size" json:"read_buffer_size" yaml:"read_buffer_size"`
+
+	// UDP Socket 写缓冲区大小
+	WriteBufferSize int `mapstructure:"write_buffer_size" json:"write_buffer_size" yaml:"write_buffer_size"`
+
+	// 最大消息大小（单帧消息体字节数，不含 4 字节长度头），收到超长帧时断开连接，超长的待发送消息直接丢弃
+	MaxMessageSize int `mapstructure:"max_message_size" json:"max_message_size" yaml:"max_message_size"`
+
+	// 读超时（空闲超时）：超过该时间没有收到任何数据则关闭连接，0 表示不检测
+	// UDP 没有断开通知，客户端离线只能靠读超时发现
+	ReadTimeout time.Duration `mapstructure:"read_timeout" json:"read_timeout" yaml:"read_timeout"`
+
+	// 写超时：有待发送的数据且超过该时间没有写出进展（发送窗口一直是满的）则关闭连接，0 表示不检测
+	WriteTimeout time.Duration `mapstructure:"write_timeout" json:"write_timeout" yaml:"write_timeout"`
+
+	// 心跳间隔：超过该时间没有收到数据时主动发送 Ping，0 表示不主动发送（只应答对端的 Ping）
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval" json:"heartbeat_interval" yaml:"heartbeat_interval"`
+
+	// KCP 协议参数
+	Protocol ProtocolConfig `mapstructure:"protocol" json:"protocol" yaml:"protocol"`
+}
+
+// DefaultServerConfig 返回默认服务端配置
+func DefaultServerConfig() *ServerConfig {
+	return &ServerConfig{
+		Addr:            "0.0.0.0:9100",
+		ReadBufferSize:  4 * 1024 * 1024,
+		WriteBufferSize: 4 * 1024 * 1024,
+		MaxMessageSize:  DefaultMaxMessageSize,
+		ReadTimeout:     60 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		Protocol:        DefaultProtocolConfig(),
+	}
+}
+
+// Validate 验证服务端配置
+func (c *ServerConfig) Validate() error {
+	if c == nil {
+		return ErrInvalidConfig
+	}
+	if c.Addr == "" {
+		return fmt.Errorf("%w: addr is required", ErrInvalidConfig)
+	}
+	if c.MaxMessageSize <= 0 {
+		c.MaxMessageSize = DefaultMaxMessageSize
+	}
+	return c.Protocol.validate()
+}
+
+// ClientConfig 客户端配置
+type ClientConfig struct {
+	// UDP Socket 读缓冲区大小
+	ReadBufferSize int `mapstructure:"read_buffer_size" json:"read_buffer_size" yaml:"read_buffer_size"`
+
+	// UDP Socket 写缓冲区大小
+	WriteBufferSize int `mapstructure:"write_buffer_size" json:"write_buffer_size" yaml:"write_buffer_size"`
+
+	// 最大消息大小（单帧消息体字节数，不含 4 字节长度头），收到超长帧时断开连接，超长的待发送消息直接丢弃
+	MaxMessageSize int `mapstructure:"max_message_size" json:"max_message_size" yaml:"max_message_size"`
+
+	// 连接超时：KCP 无需建连，用于限制会话密钥握手的等待时间
+	DialTimeout time.Duration `mapstructure:"dial_timeout" json:"dial_timeout" yaml:"dial_timeout"`
+
+	// 读超时（空闲超时）：超过该时间没有收到任何数据则关闭连接，0 表示不检测
+	ReadTimeout time.Duration `mapstructure:"read_timeout" json:"read_timeout" yaml:"read_timeout"`
+
+	// 写超时：有待发送的数据且超过该时间没有写出进展（发送窗口一直是满的）则关闭连接，0 表示不检测
+	WriteTimeout time.Duration `mapstructure:"write_timeout" json:"write_timeout" yaml:"write_timeout"`
+
+	// 心跳间隔：超过该时间没有收到数据时主动发送 Ping，0 表示不主动发送（只应答对端的 Ping）
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval" json:"heartbeat_interval" yaml:"heartbeat_interval"`
+
+	// KCP 协议参数
+	Protocol ProtocolConfig `mapstructure:"protocol" json:"protocol" yaml:"protocol"`
+}
+
+// DefaultClientConfig 返回默认客户端配置
+func DefaultClientConfig() *ClientConfig {
+	return &ClientConfig{
+		ReadBufferSize:    1024 * 1024,
+		WriteBufferSize:   1024 * 1024,
+		MaxMessageSize:    DefaultMaxMessageSize,
+		DialTimeout:       10 * time.Second,
+		ReadTimeout:       60 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		HeartbeatInterval: 20 * time.Second,
+		Protocol:          DefaultProtocolConfig(),
+	}
+}
+
+// Validate 验证客户端配置
+func (c *ClientConfig) Validate() error {
+	if c == nil {
+		return ErrInvalidConfig
+	}
+	if c.MaxMessageSize <= 0 {
+		c.MaxMessageSize = DefaultMaxMessageSize
+	}
+	return c.Protocol.validate()
+}
@@ -0,0 +1,99 @@
+package kcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lk2023060901/xdooria/pkg/network/session"
+	"github.com/lk2023060901/xdooria/pkg/util/conc"
+	kcpgo "github.com/xtaci/kcp-go/v5"
+)
+
+// Connector 实现 session.Connector 接口，基于 kcp-go 客户端。
+type Connector struct {
+	config        *ClientConfig
+	sessionConfig *session.Config
+	handler       session.SessionHandler
+	codec         *Codec
+	idle          session.IdlePolicy
+}
+
+// NewConnector 创建一个新的 KCP 连接器。
+func NewConnector(cfg *ClientConfig, sessCfg *session.Config, handler session.SessionHandler) *Connector {
+	if handler == nil {
+		handler = &session.NopSessionHandler{}
+	}
+	return &Connector{
+		config:        cfg,
+		sessionConfig: sessCfg,
+		handler:       handler,
+		codec:         NewCodec(cfg.MaxMessageSize),
+		idle: session.IdlePolicy{
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			HeartbeatInterval: cfg.HeartbeatInterval,
+		},
+	}
+}
+
+// Connect 发起连接并返回会话。
+// KCP 没有建连过程，服务端收到第一个数据包时才创建会话；
+// 启用会话密钥协商时，握手完成即说明服务端可达。
+func (c *Connector) Connect(ctx context.Context, addr string) (session.Session, error) {
+	if err := c.config.Validate(); err != nil {
+		return nil, err
+	}
+
+	p := &c.config.Protocol
+	conn, err := kcpgo.DialWithOptions(addr, nil, p.DataShards, p.ParityShards)
+	if err != nil {
+		return nil, fmt.Errorf("kcp dial %s failed: %w", addr, err)
+	}
+	if c.config.ReadBufferSize > 0 {
+		_ = conn.SetReadBuffer(c.config.ReadBufferSize)
+	}
+	if c.config.WriteBufferSize > 0 {
+		_ = conn.SetWriteBuffer(c.config.WriteBufferSize)
+	}
+	applyProtocol(conn, p)
+
+	s := NewKCPSession(conn, c.sessionConfig, c.codec)
+	conc.Go(func() (struct{}, error) {
+		c.handler.OnClosed(s, s.serve(c.handler))
+		return struct{}{}, nil
+	})
+	conc.Go(func() (struct{}, error) {
+		c.tickLoop(s)
+		return struct{}{}, nil
+	})
+
+	// 启用会话密钥协商时，握手完成后才能发送业务消息
+	if c.config.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.DialTimeout)
+		defer cancel()
+	}
+	if err := session.Handshake(ctx, s); err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("handshake failed: %w", err)
+	}
+	c.handler.OnOpened(s)
+
+	return s, nil
+}
+
+// tickLoop 定期检查会话的读写超时并发送心跳，会话关闭后退出。
+func (c *Connector) tickLoop(s *KCPSession) {
+	ticker := time.NewTicker(session.IdleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			session.CheckIdle(s, now, c.idle)
+		case <-s.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package kcp
+
+import "errors"
+
+var (
+	// 配置错误
+	ErrInvalidConfig = errors.New("kcp: invalid config")
+
+	// 服务器错误
+	ErrServerAlreadyStarted = errors.New("kcp: server already started")
+
+	// 编解码错误
+	ErrMessageTooBig  = errors.New("kcp: message too big")
+	ErrPacketTooLarge = errors.New("kcp: packet too large")
+)
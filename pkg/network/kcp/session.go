@@ -0,0 +1,149 @@
+package kcp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/pkg/network/framer"
+	"github.com/lk2023060901/xdooria/pkg/network/session"
+	"github.com/lk2023060901/xdooria/pkg/util/conc"
+	kcpgo "github.com/xtaci/kcp-go/v5"
+)
+
+// KCPSession 基于 KCP 连接的会话实现，服务端和客户端通用。
+type KCPSession struct {
+	*session.BaseSession
+	conn  *kcpgo.UDPSession
+	codec *Codec
+}
+
+// NewKCPSession 创建一个新的 KCP 会话。
+func NewKCPSession(conn *kcpgo.UDPSession, cfg *session.Config, codec *Codec) *KCPSession {
+	id := uuid.New().String()
+	s := &KCPSession{
+		BaseSession: session.NewBaseSession(id, conn.RemoteAddr().String(), cfg),
+		conn:        conn,
+		codec:       codec,
+	}
+	conc.Go(func() (struct{}, error) {
+		s.writeLoop()
+		return struct{}{}, nil
+	})
+	return s
+}
+
+// applyProtocol 设置 KCP 协议参数。
+// 加密、签名与压缩由 Framer 负责，KCP 层不加密；使用流模式以支持超过分片上限的消息。
+func applyProtocol(conn *kcpgo.UDPSession, p *ProtocolConfig) {
+	conn.SetStreamMode(true)
+	conn.SetWriteDelay(false)
+	conn.SetNoDelay(boolToInt(p.NoDelay), int(p.Interval/time.Millisecond), p.Resend, boolToInt(p.NoCongestion))
+	conn.SetWindowSize(p.SendWindow, p.RecvWindow)
+	conn.SetMtu(p.MTU)
+	conn.SetACKNoDelay(p.AckNoDelay)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Send 发送消息信封，按会话配置的溢出策略压入发送队列。
+func (s *KCPSession) Send(ctx context.Context, env *common.Envelope) error {
+	return session.SendQueued(ctx, s, env)
+}
+
+func (s *KCPSession) writeLoop() {
+	f := s.Framer()
+	for {
+		batch, ok := s.NextBatch()
+		if !ok {
+			return
+		}
+		// 一批消息作为整体签名、压缩、加密，只写出一次
+		signedEnv, err := session.EncodeBatch(f, batch)
+		if err != nil {
+			continue
+		}
+		data, err := framer.Marshal(signedEnv)
+		if err != nil {
+			continue
+		}
+		// 超过 MaxMessageSize 的消息对端无法接收，直接丢弃
+		frame, err := s.codec.Encode(data)
+		if err != nil {
+			continue
+		}
+		// 发送窗口已满时 Write 会阻塞，期间由写超时检测判定对端是否还在接收
+		s.Idle().WriteStarted(time.Now())
+		_, err = s.conn.Write(frame)
+		s.Idle().WriteDone(time.Now())
+		if err != nil {
+			return
+		}
+	}
+}
+
+// serve 读取并分发消息，直到连接关闭，返回应传给 OnClosed 的原因。
+func (s *KCPSession) serve(handler session.SessionHandler) error {
+	err := s.readLoop(handler)
+	if errors.Is(err, ErrPacketTooLarge) {
+		// 帧长度非法，无法再定位后续帧的边界，只能断开连接
+		handler.OnError(s, err)
+	}
+
+	_ = s.conn.Close()
+	return s.Closed(err)
+}
+
+// readLoop 按长度头依次读取帧并交给 handler，返回读取失败的原因。
+func (s *KCPSession) readLoop(handler session.SessionHandler) error {
+	r := &activityReader{r: s.conn, idle: s.Idle()}
+	var buf []byte
+	for {
+		body, err := s.codec.ReadFrame(r, buf)
+		if err != nil {
+			return err
+		}
+		buf = body
+
+		envs, err := session.DecodeFrame(s, body)
+		for _, env := range envs {
+			handler.OnMessage(s, env)
+		}
+		if err != nil {
+			handler.OnError(s, err)
+		}
+	}
+}
+
+// activityReader 读取到任何数据（包括不完整的帧）都视为活跃。
+type activityReader struct {
+	r    io.Reader
+	idle *session.IdleTracker
+}
+
+func (a *activityReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	if n > 0 {
+		a.idle.MarkRead(time.Now())
+	}
+	return n, err
+}
+
+// Close 关闭会话。
+func (s *KCPSession) Close() error {
+	_ = s.BaseSession.Close()
+	return s.conn.Close()
+}
+
+// Conn 返回底层 KCP 连接。
+func (s *KCPSession) Conn() *kcpgo.UDPSession {
+	return s.conn
+}
@@ -0,0 +1,38 @@
+package session
+
+import "errors"
+
+// multiAcceptor 将多个 Acceptor 组合为一个，用于同时监听多种传输协议（TCP、WebSocket、KCP 等）。
+type multiAcceptor struct {
+	acceptors []Acceptor
+}
+
+// NewMultiAcceptor 组合多个 Acceptor，按顺序启动、逆序停止。
+// 与 Server.ManagedAcceptor 搭配使用时，所有协议的会话共享同一个 SessionManager 与 SessionHandler。
+func NewMultiAcceptor(acceptors ...Acceptor) Acceptor {
+	return &multiAcceptor{acceptors: acceptors}
+}
+
+// Start 依次启动所有 Acceptor，任意一个失败时停止已启动的 Acceptor。
+func (m *multiAcceptor) Start() error {
+	for i, a := range m.acceptors {
+		if err := a.Start(); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				_ = m.acceptors[j].Stop()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop 逆序停止所有 Acceptor，返回所有失败的原因。
+func (m *multiAcceptor) Stop() error {
+	var errs []error
+	for i := len(m.acceptors) - 1; i >= 0; i-- {
+		if err := m.acceptors[i].Stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,64 @@
+package session
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// recordingAcceptor 记录启动与停止顺序
+type recordingAcceptor struct {
+	name     string
+	startErr error
+	events   *[]string
+}
+
+func (a *recordingAcceptor) Start() error {
+	*a.events = append(*a.events, "start "+a.name)
+	return a.startErr
+}
+
+func (a *recordingAcceptor) Stop() error {
+	*a.events = append(*a.events, "stop "+a.name)
+	return nil
+}
+
+func TestMultiAcceptor(t *testing.T) {
+	var events []string
+	m := NewMultiAcceptor(
+		&recordingAcceptor{name: "tcp", events: &events},
+		&recordingAcceptor{name: "ws", events: &events},
+	)
+
+	if err := m.Start(); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	if err := m.Stop(); err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+
+	want := []string{"start tcp", "start ws", "stop ws", "stop tcp"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
+
+func TestMultiAcceptor_StartFailure(t *testing.T) {
+	var events []string
+	errBind := errors.New("address in use")
+	m := NewMultiAcceptor(
+		&recordingAcceptor{name: "tcp", events: &events},
+		&recordingAcceptor{name: "kcp", startErr: errBind, events: &events},
+		&recordingAcceptor{name: "ws", events: &events},
+	)
+
+	if err := m.Start(); !errors.Is(err, errBind) {
+		t.Fatalf("start = %v, want %v", err, errBind)
+	}
+
+	// 已启动的 Acceptor 被停止，失败之后的不再启动
+	want := []string{"start tcp", "start kcp", "stop tcp"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
@@ -0,0 +1,164 @@
+package session
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/pkg/network/framer"
+)
+
+// ErrInvalidBatch 批量消息格式错误
+var ErrInvalidBatch = errors.New("invalid batch")
+
+// batchEntryHeaderSize 批量消息中每条消息的头部长度：Op(4) + RpcId(4) + Length(4)
+const batchEntryHeaderSize = 12
+
+// BatchConfig 发送批量合并配置。
+// 写协程一次取出多条普通消息，打包为一个 OP_BATCH 消息后整体签名、压缩、加密并写出，
+// 减少场景广播等大量小消息的系统调用次数与每条消息的头部开销。对端需要支持 OP_BATCH 才能启用。
+type BatchConfig struct {
+	// MaxMessages 每批最多合并的消息数，小于等于 1 表示不合并。
+	MaxMessages int `mapstructure:"max_messages" json:"max_messages" yaml:"max_messages"`
+	// MaxBytes 每批合并前负载的总字节数上限，超出的消息进入下一批；单条消息超过上限时单独发送。
+	MaxBytes int `mapstructure:"max_bytes" json:"max_bytes" yaml:"max_bytes"`
+	// Linger 队列中的消息不足 MaxMessages 时最多等待多久再发送，0 表示只合并已在队列中的消息。
+	Linger time.Duration `mapstructure:"linger" json:"linger" yaml:"linger"`
+}
+
+// enabled 是否启用批量合并。
+func (c *BatchConfig) enabled() bool {
+	return c.MaxMessages > 1
+}
+
+// IsBatch 判断是否为批量消息（OP_BATCH）。
+func IsBatch(env *common.Envelope) bool {
+	return common.OpCode(env.GetHeader().GetOp()) == common.OpCode_OP_BATCH
+}
+
+// PackBatch 将多条消息打包为 OP_BATCH 的负载。
+// 格式为依次排列的 [Op(4) | RpcId(4) | Length(4) | Payload]，整数均为大端序。
+func PackBatch(envs []*common.Envelope) []byte {
+	size := 0
+	for _, env := range envs {
+		size += batchEntryHeaderSize + len(env.GetPayload())
+	}
+
+	buf := make([]byte, 0, size)
+	for _, env := range envs {
+		buf = binary.BigEndian.AppendUint32(buf, env.GetHeader().GetOp())
+		buf = binary.BigEndian.AppendUint32(buf, env.GetHeader().GetRpcId())
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(env.GetPayload())))
+		buf = append(buf, env.GetPayload()...)
+	}
+	return buf
+}
+
+// UnpackBatch 解析 OP_BATCH 的负载，返回的消息负载引用 data 的内存。
+func UnpackBatch(data []byte) ([]*common.Envelope, error) {
+	var envs []*common.Envelope
+	for len(data) > 0 {
+		if len(data) < batchEntryHeaderSize {
+			return nil, fmt.Errorf("%w: truncated entry header", ErrInvalidBatch)
+		}
+		op := binary.BigEndian.Uint32(data[0:4])
+		rpcId := binary.BigEndian.Uint32(data[4:8])
+		n := binary.BigEndian.Uint32(data[8:12])
+		data = data[batchEntryHeaderSize:]
+		if uint64(n) > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: entry length %d exceeds remaining %d bytes", ErrInvalidBatch, n, len(data))
+		}
+		if common.OpCode(op) == common.OpCode_OP_BATCH {
+			return nil, fmt.Errorf("%w: nested batch", ErrInvalidBatch)
+		}
+		envs = append(envs, &common.Envelope{
+			Header:  &common.MessageHeader{Op: op, RpcId: rpcId},
+			Payload: data[:n:n],
+		})
+		data = data[n:]
+	}
+	return envs, nil
+}
+
+// Unbatch 展开解码后的消息：OP_BATCH 返回其中的每条消息，其他消息原样返回。
+// 需在 Framer.Decode 之后、握手与心跳处理之前调用。
+func Unbatch(env *common.Envelope) ([]*common.Envelope, error) {
+	if !IsBatch(env) {
+		return []*common.Envelope{env}, nil
+	}
+	return UnpackBatch(env.GetPayload())
+}
+
+// EncodeBatch 使用 Framer 编码一批消息：单条消息直接编码，多条消息打包为 OP_BATCH 后作为一个整体编码。
+// 消息的 RPC 关联 ID 随消息一起编码。
+func EncodeBatch(f framer.Framer, batch []*common.Envelope) (*common.Envelope, error) {
+	if len(batch) == 1 {
+		h := batch[0].GetHeader()
+		return framer.EncodeRPC(f, h.GetOp(), h.GetRpcId(), batch[0].GetPayload())
+	}
+	return f.Encode(uint32(common.OpCode_OP_BATCH), PackBatch(batch))
+}
+
+// NextBatch 取出下一批待发送的消息，会话关闭时返回 false。供传输层的写协程调用，不能并发调用。
+// 控制消息总是单独成批并优先发送；未启用批量合并时每批只有一条消息。
+// 返回的切片在下一次调用前有效。
+func (s *BaseSession) NextBatch() ([]*common.Envelope, bool) {
+	first := s.pending
+	s.pending = nil
+	if first == nil {
+		env, ok := s.NextSend()
+		if !ok {
+			return nil, false
+		}
+		first = env
+	}
+
+	batch := append(s.batchBuf[:0], first)
+	defer func() { s.batchBuf = batch[:0] }()
+	if !s.batch.enabled() || s.isControl(first) {
+		return batch, true
+	}
+
+	size := len(first.GetPayload())
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for len(batch) < s.batch.MaxMessages {
+		var env *common.Envelope
+		select {
+		case env = <-s.sendCh:
+		default:
+			if s.batch.Linger <= 0 {
+				return batch, true
+			}
+			if timer == nil {
+				timer = time.NewTimer(s.batch.Linger)
+			}
+			select {
+			case env = <-s.sendCh:
+			case ctl := <-s.controlCh:
+				// 控制消息不等待凑批，先发出当前批次
+				s.pending = ctl
+				return batch, true
+			case <-timer.C:
+				return batch, true
+			case <-s.ctx.Done():
+				return batch, true
+			}
+		}
+
+		if s.batch.MaxBytes > 0 && size+len(env.GetPayload()) > s.batch.MaxBytes {
+			s.pending = env
+			return batch, true
+		}
+		batch = append(batch, env)
+		size += len(env.GetPayload())
+	}
+	return batch, true
+}
@@ -0,0 +1,264 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/pkg/compress"
+	"github.com/lk2023060901/xdooria/pkg/network/framer"
+)
+
+func newBatchSession(cfg BatchConfig) *testSession {
+	return &testSession{BaseSession: NewBaseSession("test", "127.0.0.1:0", &Config{
+		SendChannelSize: 16,
+		RecvChannelSize: 1,
+		Batch:           cfg,
+	})}
+}
+
+func payloadMsg(op uint32, n int) *common.Envelope {
+	return &common.Envelope{Header: &common.MessageHeader{Op: op}, Payload: bytes.Repeat([]byte{byte(op)}, n)}
+}
+
+// batchOps 取出下一批消息的 OpCode
+func batchOps(t *testing.T, s *testSession) []uint32 {
+	t.Helper()
+	batch, ok := s.NextBatch()
+	if !ok {
+		t.Fatalf("session closed unexpectedly")
+	}
+	ops := make([]uint32, 0, len(batch))
+	for _, env := range batch {
+		ops = append(ops, env.Header.Op)
+	}
+	return ops
+}
+
+func TestPackUnpackBatch(t *testing.T) {
+	envs := []*common.Envelope{payloadMsg(1, 3), payloadMsg(2, 0), payloadMsg(3, 5)}
+
+	got, err := UnpackBatch(PackBatch(envs))
+	if err != nil {
+		t.Fatalf("unpack failed: %v", err)
+	}
+	if len(got) != len(envs) {
+		t.Fatalf("got %d messages, want %d", len(got), len(envs))
+	}
+	for i := range envs {
+		if got[i].Header.Op != envs[i].Header.Op || !bytes.Equal(got[i].Payload, envs[i].Payload) {
+			t.Fatalf("message %d = %d/%x, want %d/%x", i, got[i].Header.Op, got[i].Payload, envs[i].Header.Op, envs[i].Payload)
+		}
+	}
+
+	// 非批量消息原样返回
+	single := payloadMsg(1, 3)
+	if got, err := Unbatch(single); err != nil || len(got) != 1 || got[0] != single {
+		t.Fatalf("unbatch of plain message = %v, %v", got, err)
+	}
+}
+
+func TestUnpackBatch_Invalid(t *testing.T) {
+	packed := PackBatch([]*common.Envelope{payloadMsg(1, 4)})
+	nested := PackBatch([]*common.Envelope{{Header: &common.MessageHeader{Op: uint32(common.OpCode_OP_BATCH)}}})
+
+	cases := map[string][]byte{
+		"truncated header":  packed[:5],
+		"truncated payload": packed[:len(packed)-1],
+		"nested batch":      nested,
+	}
+	for name, data := range cases {
+		if _, err := UnpackBatch(data); !errors.Is(err, ErrInvalidBatch) {
+			t.Fatalf("%s: err = %v, want ErrInvalidBatch", name, err)
+		}
+	}
+}
+
+func TestNextBatch(t *testing.T) {
+	s := newBatchSession(BatchConfig{MaxMessages: 3, MaxBytes: 10})
+	ctx := context.Background()
+
+	for _, env := range []*common.Envelope{
+		payloadMsg(1, 3), payloadMsg(2, 3), payloadMsg(3, 3), payloadMsg(4, 3), // 超过 MaxMessages
+		payloadMsg(5, 8), // 与上一条合计超过 MaxBytes
+	} {
+		if err := s.Enqueue(ctx, env); err != nil {
+			t.Fatalf("enqueue failed: %v", err)
+		}
+	}
+	if err := s.Enqueue(ctx, NewPing(nil)); err != nil {
+		t.Fatalf("enqueue ping failed: %v", err)
+	}
+
+	want := [][]uint32{
+		{uint32(common.OpCode_OP_PING)}, // 控制消息单独成批并优先发送
+		{1, 2, 3},
+		{4},
+		{5},
+	}
+	for i, w := range want {
+		if got := batchOps(t, s); fmt.Sprint(got) != fmt.Sprint(w) {
+			t.Fatalf("batch %d = %v, want %v", i, got, w)
+		}
+	}
+}
+
+func TestNextBatch_Disabled(t *testing.T) {
+	s := newBatchSession(BatchConfig{})
+	for op := uint32(1); op <= 2; op++ {
+		if err := s.Enqueue(context.Background(), payloadMsg(op, 1)); err != nil {
+			t.Fatalf("enqueue failed: %v", err)
+		}
+	}
+	for op := uint32(1); op <= 2; op++ {
+		if got := batchOps(t, s); len(got) != 1 || got[0] != op {
+			t.Fatalf("batch = %v, want [%d]", got, op)
+		}
+	}
+
+	_ = s.Close()
+	if _, ok := s.NextBatch(); ok {
+		t.Fatalf("NextBatch should report closed session")
+	}
+}
+
+func TestNextBatch_Linger(t *testing.T) {
+	s := newBatchSession(BatchConfig{MaxMessages: 4, Linger: time.Second})
+	ctx := context.Background()
+
+	// 等待期间到达的消息合并到同一批
+	_ = s.Enqueue(ctx, payloadMsg(1, 1))
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		_ = s.Enqueue(ctx, payloadMsg(2, 1))
+		time.Sleep(5 * time.Millisecond)
+		// 控制消息到达时立即结束等待
+		_ = s.Enqueue(ctx, NewPing(nil))
+	}()
+
+	start := time.Now()
+	if got := batchOps(t, s); fmt.Sprint(got) != "[1 2]" {
+		t.Fatalf("batch = %v, want [1 2]", got)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("control message should cut linger short, waited %v", elapsed)
+	}
+	if got := batchOps(t, s); len(got) != 1 || got[0] != uint32(common.OpCode_OP_PING) {
+		t.Fatalf("batch = %v, want ping", got)
+	}
+}
+
+func TestEncodeBatch(t *testing.T) {
+	base, err := framer.New(&framer.Config{
+		SignKey:          []byte("batch-sign-key"),
+		EnableCompress:   true,
+		CompressType:     compress.TypeSnappy,
+		CompressMinBytes: 64,
+	})
+	if err != nil {
+		t.Fatalf("failed to create framer: %v", err)
+	}
+	sender, _ := framer.ForSession(base)
+	receiver, _ := framer.ForSession(base)
+
+	batch := sceneBroadcast(32)
+	signed, err := EncodeBatch(sender, batch)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if !IsBatch(signed) {
+		t.Fatalf("op = %d, want OP_BATCH", signed.Header.Op)
+	}
+	if signed.Header.Flags&uint32(common.MessageFlags_MESSAGE_FLAGS_COMPRESSED) == 0 {
+		t.Fatalf("batch should be compressed as a unit")
+	}
+
+	op, payload, err := receiver.Decode(signed)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	got, err := Unbatch(&common.Envelope{Header: &common.MessageHeader{Op: op}, Payload: payload})
+	if err != nil {
+		t.Fatalf("unbatch failed: %v", err)
+	}
+	if len(got) != len(batch) {
+		t.Fatalf("got %d messages, want %d", len(got), len(batch))
+	}
+	for i := range batch {
+		if got[i].Header.Op != batch[i].Header.Op || !bytes.Equal(got[i].Payload, batch[i].Payload) {
+			t.Fatalf("message %d mismatch", i)
+		}
+	}
+
+	// 单条消息不打包
+	signed, err = EncodeBatch(sender, batch[:1])
+	if err != nil || signed.Header.Op != batch[0].Header.Op {
+		t.Fatalf("single message: op=%d err=%v", signed.GetHeader().GetOp(), err)
+	}
+}
+
+// sceneBroadcast 模拟一次场景广播：n 条玩家移动通知（玩家 ID + 坐标 + 朝向）
+func sceneBroadcast(n int) []*common.Envelope {
+	envs := make([]*common.Envelope, n)
+	for i := range envs {
+		payload := make([]byte, 0, 32)
+		payload = binary.BigEndian.AppendUint64(payload, uint64(100000+i))
+		for _, v := range []uint32{uint32(1000 + i*3), 0, uint32(2000 + i*7), uint32(i % 8)} {
+			payload = binary.BigEndian.AppendUint32(payload, v)
+		}
+		envs[i] = &common.Envelope{
+			Header:  &common.MessageHeader{Op: 1059}, // OP_SCENE_PLAYER_MOVE_NOTIFY
+			Payload: payload,
+		}
+	}
+	return envs
+}
+
+// BenchmarkSceneBroadcast 对比逐条发送与批量合并发送一次场景广播（32 条移动通知）的写出次数与字节数。
+// writes/op 对应传输层的写调用（TCP 下即系统调用）次数，wire_B/op 为含 4 字节长度头的帧总字节数。
+func BenchmarkSceneBroadcast(b *testing.B) {
+	const messages = 32
+	broadcast := sceneBroadcast(messages)
+
+	for _, compressed := range []bool{false, true} {
+		base, err := framer.New(&framer.Config{
+			SignKey:          []byte("bench-sign-key"),
+			EnableCompress:   compressed,
+			CompressType:     compress.TypeSnappy,
+			CompressMinBytes: 256,
+		})
+		if err != nil {
+			b.Fatalf("failed to create framer: %v", err)
+		}
+
+		for _, batchSize := range []int{1, messages} {
+			name := fmt.Sprintf("compress=%v/batch=%d", compressed, batchSize)
+			b.Run(name, func(b *testing.B) {
+				f, _ := framer.ForSession(base)
+				var writes, wireBytes int
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					for start := 0; start < messages; start += batchSize {
+						signed, err := EncodeBatch(f, broadcast[start:start+batchSize])
+						if err != nil {
+							b.Fatalf("encode failed: %v", err)
+						}
+						data, err := framer.Marshal(signed)
+						if err != nil {
+							b.Fatalf("marshal failed: %v", err)
+						}
+						writes++
+						wireBytes += 4 + len(data)
+					}
+				}
+				b.ReportMetric(float64(writes)/float64(b.N), "writes/op")
+				b.ReportMetric(float64(wireBytes)/float64(b.N), "wire_B/op")
+			})
+		}
+	}
+}
@@ -11,15 +11,28 @@ type Client struct {
 	connector Connector
 	session   Session
 	handler   SessionHandler
+	rpc       *RPC
 }
 
 func NewClient(connector Connector, handler SessionHandler) *Client {
 	return &Client{
 		connector: connector,
 		handler:   handler,
+		rpc:       NewRPC(DefaultRPCTimeout),
 	}
 }
 
+// ManagedConnector 使用包装了 RPC 响应分发的 SessionHandler 创建 Connector，并作为客户端的连接器。
+// 通过 Call 发起的调用需要以这种方式创建 Connector 才能收到响应。
+func (c *Client) ManagedConnector(factory func(SessionHandler) Connector) Connector {
+	var handler SessionHandler = &NopSessionHandler{}
+	if c.handler != nil {
+		handler = c.handler
+	}
+	c.connector = factory(c.rpc.Handler(handler))
+	return c.connector
+}
+
 // Connect 连接到服务端。
 func (c *Client) Connect(ctx context.Context, addr string) (Session, error) {
 	s, err := c.connector.Connect(ctx, addr)
@@ -45,6 +58,7 @@ func (c *Client) Session() Session {
 // Close 关闭客户端。
 func (c *Client) Close() error {
 	if c.session != nil {
+		c.rpc.Fail(c.session)
 		return c.session.Close()
 	}
 	return nil
@@ -56,4 +70,12 @@ func (c *Client) Send(ctx context.Context, env *common.Envelope) error {
 		return ErrSessionNotFound
 	}
 	return c.session.Send(ctx, env)
-}
\ No newline at end of file
+}
+
+// Call 发送请求并等待响应，可配合 Invoke 使用泛型调用。
+func (c *Client) Call(ctx context.Context, op uint32, payload []byte) (*common.Envelope, error) {
+	if c.session == nil {
+		return nil, ErrSessionNotFound
+	}
+	return c.rpc.Call(ctx, c.session, op, payload)
+}
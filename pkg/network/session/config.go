@@ -2,6 +2,7 @@ package session
 
 import (
 	"errors"
+	"time"
 
 	"github.com/lk2023060901/xdooria/pkg/network/framer"
 )
@@ -12,8 +13,22 @@ type Config struct {
 	SendChannelSize int `mapstructure:"send_channel_size" json:"send_channel_size" yaml:"send_channel_size"`
 	// RecvChannelSize 接收队列大小。
 	RecvChannelSize int `mapstructure:"recv_channel_size" json:"recv_channel_size" yaml:"recv_channel_size"`
-	// Framer 消息帧处理器，用于签名、加密、压缩。
+	// ControlChannelSize 控制消息队列大小；心跳、握手、踢下线等控制消息走独立队列，优先于普通消息发送。
+	ControlChannelSize int `mapstructure:"control_channel_size" json:"control_channel_size" yaml:"control_channel_size"`
+	// ControlOps 除内置控制消息外，额外走控制队列的 OpCode。
+	ControlOps []uint32 `mapstructure:"control_ops" json:"control_ops" yaml:"control_ops"`
+	// OverflowPolicy 发送队列已满时的处理策略：block、drop_oldest、drop_newest、disconnect。
+	OverflowPolicy OverflowPolicy `mapstructure:"overflow_policy" json:"overflow_policy" yaml:"overflow_policy"`
+	// BlockTimeout block 策略下等待队列腾出空间的最长时间，0 表示一直等待（直到调用方 ctx 取消或会话关闭）。
+	BlockTimeout time.Duration `mapstructure:"block_timeout" json:"block_timeout" yaml:"block_timeout"`
+	// Batch 发送批量合并配置。
+	Batch BatchConfig `mapstructure:"batch" json:"batch" yaml:"batch"`
+	// OverflowObserver 发送队列溢出观察者，用于上报指标。
+	OverflowObserver OverflowObserver `json:"-" yaml:"-"`
+	// Framer 消息帧处理器，用于签名、加密、压缩；每个会话通过 framer.ForSession 派生独立的防重放状态。
 	Framer framer.Framer `json:"-" yaml:"-"`
+	// FramerConfig 创建 Framer 的配置，启用会话密钥协商时每个会话在 Framer 之上包装独立的 framer.SessionFramer。
+	FramerConfig *framer.Config `json:"-" yaml:"-"`
 }
 
 // ServerConfig 服务端配置。
@@ -30,8 +45,13 @@ type ServerConfig struct {
 // DefaultConfig 返回默认会话参数。
 func DefaultConfig() *Config {
 	return &Config{
-		SendChannelSize: 1024,
-		RecvChannelSize: 1024,
+		SendChannelSize:    1024,
+		RecvChannelSize:    1024,
+		ControlChannelSize: 64,
+		OverflowPolicy:     OverflowBlock,
+		Batch: BatchConfig{
+			MaxBytes: 64 * 1024,
+		},
 	}
 }
 
@@ -52,6 +72,17 @@ func (c *Config) Validate() error {
 	if c.RecvChannelSize <= 0 {
 		return errors.New("recv_channel_size must be greater than 0")
 	}
+	if c.ControlChannelSize < 0 {
+		return errors.New("control_channel_size must not be negative")
+	}
+	if c.Batch.MaxBytes < 0 || c.Batch.Linger < 0 {
+		return errors.New("batch max_bytes and linger must not be negative")
+	}
+	if c.OverflowPolicy != "" {
+		if err := c.OverflowPolicy.validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,107 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/pkg/network/framer"
+)
+
+// IdleCheckInterval 传输层检查读写超时与心跳的周期
+const IdleCheckInterval = time.Second
+
+// IdlePolicy 连接空闲检测参数，0 表示不启用对应检测
+type IdlePolicy struct {
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	HeartbeatInterval time.Duration
+}
+
+// ConnSession 基于 BaseSession 的传输层会话（TCP、KCP、WebSocket、gRPC 流）。
+// Close 由传输层实现，同时关闭底层连接。
+type ConnSession interface {
+	Session
+	Framer() framer.Framer
+	Idle() *IdleTracker
+	Enqueue(ctx context.Context, env *common.Envelope) error
+	PushRecv(env *common.Envelope) error
+	SetCloseReason(err error)
+}
+
+// SendQueued 按会话配置的溢出策略将消息压入发送队列，供传输层的 Send 调用。
+// 溢出策略为断开连接时关闭会话（慢消费者），原因通过 OnClosed 传递。
+func SendQueued(ctx context.Context, s ConnSession, env *common.Envelope) error {
+	err := s.Enqueue(ctx, env)
+	if errors.Is(err, ErrSlowConsumer) {
+		_ = s.Close()
+	}
+	return err
+}
+
+// CloseWithReason 记录关闭原因并关闭会话，原因会通过 SessionHandler.OnClosed 传递。
+func CloseWithReason(s ConnSession, err error) error {
+	s.SetCloseReason(err)
+	return s.Close()
+}
+
+// CheckIdle 检查读写超时，超时则带原因关闭会话；读空闲超过心跳间隔时主动发送 Ping。
+// 由传输层的定时检查以 IdleCheckInterval 为周期调用。
+func CheckIdle(s ConnSession, now time.Time, p IdlePolicy) {
+	if s.Context().Err() != nil {
+		return
+	}
+	if err := s.Idle().Check(now, p.ReadTimeout, p.WriteTimeout); err != nil {
+		_ = CloseWithReason(s, err)
+		return
+	}
+
+	if s.Idle().PingDue(now, p.HeartbeatInterval) {
+		TrySend(s, NewPing(nil))
+	}
+}
+
+// DecodeFrame 解析一帧数据：反序列化 Envelope、验证签名并解密/解压，展开批量消息后压入接收队列。
+// 会话密钥握手与协议层心跳（Ping/Pong）在此直接处理，不交给业务处理器。
+// 返回应交给业务处理器的消息；出错时仍返回出错前已入队的消息。
+// frame 只在调用期间有效，反序列化会复制所需的数据。
+func DecodeFrame(s ConnSession, frame []byte) ([]*common.Envelope, error) {
+	env, err := framer.Unmarshal(frame)
+	if err != nil {
+		return nil, err
+	}
+	// 验证签名并解密/解压
+	op, payload, err := s.Framer().Decode(env)
+	if err != nil {
+		return nil, err
+	}
+	// 构建解码后的 Envelope，批量消息展开为多条
+	envs, err := Unbatch(&common.Envelope{
+		Header:  &common.MessageHeader{Op: op, RpcId: env.Header.RpcId},
+		Payload: payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := envs[:0]
+	for _, decodedEnv := range envs {
+		if handled, err := HandleHandshake(s, decodedEnv); handled {
+			if err != nil {
+				// 握手失败后双方密钥不一致，后续消息都无法解码
+				_ = CloseWithReason(s, err)
+				return msgs, err
+			}
+			continue
+		}
+		if HandleHeartbeat(s, decodedEnv) {
+			continue
+		}
+		if err := s.PushRecv(decodedEnv); err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, decodedEnv)
+	}
+	return msgs, nil
+}
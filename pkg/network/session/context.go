@@ -0,0 +1,17 @@
+package session
+
+import "context"
+
+// sessionContextKey Context 中存储 Session 的 key
+type sessionContextKey struct{}
+
+// NewContext 将处理消息的 Session 放入 Context，业务 Handler 可据此向该会话主动推送消息。
+func NewContext(ctx context.Context, s Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, s)
+}
+
+// FromContext 从 Context 中取出 NewContext 放入的 Session。
+func FromContext(ctx context.Context) (Session, bool) {
+	s, ok := ctx.Value(sessionContextKey{}).(Session)
+	return s, ok
+}
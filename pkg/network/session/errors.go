@@ -3,8 +3,14 @@ package session
 import "errors"
 
 var (
-	ErrSessionNotFound  = errors.New("session not found")
-	ErrConnectionClosed = errors.New("connection closed")
-	ErrBroadcastFailed  = errors.New("broadcast partially failed")
-	ErrCloseFailed      = errors.New("close partially failed")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrConnectionClosed   = errors.New("connection closed")
+	ErrBroadcastFailed    = errors.New("broadcast partially failed")
+	ErrCloseFailed        = errors.New("close partially failed")
+	ErrReadTimeout        = errors.New("read timeout")
+	ErrWriteTimeout       = errors.New("write timeout")
+	ErrSendQueueFull      = errors.New("send queue full")
+	ErrSlowConsumer       = errors.New("slow consumer")
+	ErrRPCTimeout         = errors.New("rpc timeout")
+	ErrUnexpectedResponse = errors.New("unexpected response")
 )
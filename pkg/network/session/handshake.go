@@ -0,0 +1,91 @@
+package session
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/pkg/network/framer"
+)
+
+// ErrKeyExchangeDisabled 本端未启用会话密钥协商，却收到了握手消息
+var ErrKeyExchangeDisabled = errors.New("key exchange disabled")
+
+// sessionFramer 返回会话级 Framer，未启用会话密钥协商时返回 nil。
+func sessionFramer(s Session) *framer.SessionFramer {
+	fs, ok := s.(interface{ Framer() framer.Framer })
+	if !ok {
+		return nil
+	}
+	sf, _ := fs.Framer().(*framer.SessionFramer)
+	return sf
+}
+
+// IsHandshake 判断是否为会话密钥协商消息（OP_HANDSHAKE_REQ / OP_HANDSHAKE_RES）。
+func IsHandshake(env *common.Envelope) bool {
+	switch common.OpCode(env.GetHeader().GetOp()) {
+	case common.OpCode_OP_HANDSHAKE_REQ, common.OpCode_OP_HANDSHAKE_RES:
+		return true
+	}
+	return false
+}
+
+// HandleHandshake 处理会话密钥协商消息，需在 Framer.Decode 之后、交给业务处理器之前调用。
+// 服务端收到 OP_HANDSHAKE_REQ 时回复 OP_HANDSHAKE_RES；客户端收到 OP_HANDSHAKE_RES 时完成握手。
+// 返回 true 表示消息是握手消息且已处理（err 非 nil 时说明握手失败，调用方应关闭连接）。
+func HandleHandshake(s Session, env *common.Envelope) (bool, error) {
+	if !IsHandshake(env) {
+		return false, nil
+	}
+
+	sf := sessionFramer(s)
+	if sf == nil {
+		return true, ErrKeyExchangeDisabled
+	}
+
+	if common.OpCode(env.GetHeader().GetOp()) == common.OpCode_OP_HANDSHAKE_RES {
+		return true, sf.Complete(env.GetPayload())
+	}
+
+	serverPub, err := sf.Accept(env.GetPayload())
+	if err != nil {
+		return true, err
+	}
+	// 响应必须送达，否则双方密钥不一致；无法入队时由调用方关闭连接
+	if !TrySend(s, &common.Envelope{
+		Header:  &common.MessageHeader{Op: uint32(common.OpCode_OP_HANDSHAKE_RES)},
+		Payload: serverPub,
+	}) {
+		return true, ErrConnectionClosed
+	}
+	return true, nil
+}
+
+// Handshake 客户端发起会话密钥协商并等待完成，未启用会话密钥协商时直接返回。
+// 握手完成前发送的其他消息会被 Framer 拒绝，因此应在连接建立后、发送业务消息前调用。
+func Handshake(ctx context.Context, s Session) error {
+	sf := sessionFramer(s)
+	if sf == nil {
+		return nil
+	}
+
+	clientPub, err := sf.Initiate()
+	if err != nil {
+		return err
+	}
+	if err := s.Send(ctx, &common.Envelope{
+		Header:  &common.MessageHeader{Op: uint32(common.OpCode_OP_HANDSHAKE_REQ)},
+		Payload: clientPub,
+	}); err != nil {
+		return err
+	}
+
+	select {
+	case <-sf.Done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.Context().Done():
+		return ErrConnectionClosed
+	}
+}
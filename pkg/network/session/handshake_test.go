@@ -0,0 +1,103 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria-proto-common"
+	"github.com/lk2023060901/xdooria/pkg/network/framer"
+)
+
+func newKeyExchangeSession(t *testing.T, enabled bool) *testSession {
+	t.Helper()
+
+	cfg := &framer.Config{KeyExchange: framer.KeyExchangeConfig{Enabled: enabled}}
+	base, err := framer.New(cfg)
+	if err != nil {
+		t.Fatalf("failed to create framer: %v", err)
+	}
+	return &testSession{BaseSession: NewBaseSession("test", "127.0.0.1:0", &Config{
+		SendChannelSize: 4,
+		RecvChannelSize: 1,
+		Framer:          base,
+		FramerConfig:    cfg,
+	})}
+}
+
+// deliver 取出 from 发送队列中的一条消息，经双方 Framer 编解码后交给 to 的握手处理
+func deliver(t *testing.T, from, to *testSession) (bool, error) {
+	t.Helper()
+
+	var env *common.Envelope
+	select {
+	case env = <-from.controlCh:
+	case env = <-from.sendCh:
+	case <-time.After(time.Second):
+		t.Fatalf("no message to deliver")
+	}
+
+	encoded, err := from.Framer().Encode(env.Header.Op, env.Payload)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	op, payload, err := to.Framer().Decode(encoded)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	return HandleHandshake(to, &common.Envelope{Header: &common.MessageHeader{Op: op}, Payload: payload})
+}
+
+func TestHandshake(t *testing.T) {
+	client := newKeyExchangeSession(t, true)
+	server := newKeyExchangeSession(t, true)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Handshake(context.Background(), client)
+	}()
+
+	if handled, err := deliver(t, client, server); !handled || err != nil {
+		t.Fatalf("server handshake: handled=%v err=%v", handled, err)
+	}
+	if handled, err := deliver(t, server, client); !handled || err != nil {
+		t.Fatalf("client handshake: handled=%v err=%v", handled, err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+
+	// 握手后的业务消息使用会话密钥，不作为握手消息处理
+	client.SendChan() <- &common.Envelope{Header: &common.MessageHeader{Op: 2000}, Payload: []byte("hi")}
+	if handled, err := deliver(t, client, server); handled || err != nil {
+		t.Fatalf("business message: handled=%v err=%v", handled, err)
+	}
+}
+
+func TestHandshake_Disabled(t *testing.T) {
+	client := newKeyExchangeSession(t, false)
+
+	// 未启用时不发起握手
+	if err := Handshake(context.Background(), client); err != nil {
+		t.Fatalf("disabled handshake err = %v", err)
+	}
+	if _, ok := client.sent(); ok {
+		t.Fatalf("disabled handshake should not send anything")
+	}
+
+	req := &common.Envelope{Header: &common.MessageHeader{Op: uint32(common.OpCode_OP_HANDSHAKE_REQ)}}
+	if handled, err := HandleHandshake(client, req); !handled || !errors.Is(err, ErrKeyExchangeDisabled) {
+		t.Fatalf("handled=%v err=%v, want ErrKeyExchangeDisabled", handled, err)
+	}
+}
+
+func TestHandshake_Timeout(t *testing.T) {
+	client := newKeyExchangeSession(t, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := Handshake(ctx, client); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,138 @@
+package session
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/lk2023060901/xdooria-proto-common"
+)
+
+// IsHeartbeat 判断是否为协议层心跳消息（OP_PING / OP_PONG）。
+func IsHeartbeat(env *common.Envelope) bool {
+	switch common.OpCode(env.GetHeader().GetOp()) {
+	case common.OpCode_OP_PING, common.OpCode_OP_PONG:
+		return true
+	}
+	return false
+}
+
+// HandleHeartbeat 处理协议层心跳：收到 OP_PING 时原样回显负载回复 OP_PONG（对端可据此计算 RTT），
+// 收到 OP_PONG 时忽略（活跃时间已由传输层记录）。
+// 返回 true 表示消息是心跳且已处理，不应再交给业务 SessionHandler。
+func HandleHeartbeat(s Session, env *common.Envelope) bool {
+	if !IsHeartbeat(env) {
+		return false
+	}
+
+	if common.OpCode(env.GetHeader().GetOp()) == common.OpCode_OP_PING {
+		// 发送队列已满说明连接正忙，对端会因收到其他数据而保持活跃，丢弃本次 Pong 即可
+		TrySend(s, NewPong(env.GetPayload()))
+	}
+	return true
+}
+
+// NewPing 创建 Ping 消息，payload 会被对端原样回显。
+func NewPing(payload []byte) *common.Envelope {
+	return &common.Envelope{
+		Header:  &common.MessageHeader{Op: uint32(common.OpCode_OP_PING)},
+		Payload: payload,
+	}
+}
+
+// NewPong 创建 Pong 消息。
+func NewPong(payload []byte) *common.Envelope {
+	return &common.Envelope{
+		Header:  &common.MessageHeader{Op: uint32(common.OpCode_OP_PONG)},
+		Payload: payload,
+	}
+}
+
+// TrySend 非阻塞地将消息压入发送队列，队列已满或会话已关闭时返回 false。
+// 用于事件循环等不能阻塞的上下文；基于 BaseSession 的会话会将控制消息放入优先队列。
+func TrySend(s Session, env *common.Envelope) bool {
+	if q, ok := s.(interface{ TryEnqueue(*common.Envelope) bool }); ok {
+		return q.TryEnqueue(env)
+	}
+
+	select {
+	case <-s.Context().Done():
+		return false
+	default:
+	}
+
+	select {
+	case s.SendChan() <- env:
+		return true
+	default:
+		return false
+	}
+}
+
+// IdleTracker 记录会话的读写活跃时间，用于读超时、写超时与心跳判定。
+// 所有方法都是原子操作，可在事件循环、写协程与定时检查中并发调用。
+type IdleTracker struct {
+	lastRead      atomic.Int64 // 最后一次收到数据的时间（UnixNano）
+	lastWrite     atomic.Int64 // 最后一次写出进展的时间（UnixNano）
+	pendingWrites atomic.Int64 // 已提交但尚未写出的数据块数量
+	lastPing      atomic.Int64 // 最后一次主动发送 Ping 的时间（UnixNano）
+}
+
+// NewIdleTracker 创建活跃时间跟踪器，初始活跃时间为 now。
+func NewIdleTracker(now time.Time) *IdleTracker {
+	t := &IdleTracker{}
+	t.lastRead.Store(now.UnixNano())
+	t.lastWrite.Store(now.UnixNano())
+	return t
+}
+
+// MarkRead 记录收到数据（包括不完整的帧）。
+func (t *IdleTracker) MarkRead(now time.Time) {
+	t.lastRead.Store(now.UnixNano())
+}
+
+// WriteStarted 记录提交了一块待写数据，从无积压变为有积压时开始计算写超时。
+func (t *IdleTracker) WriteStarted(now time.Time) {
+	if t.pendingWrites.Add(1) == 1 {
+		t.lastWrite.Store(now.UnixNano())
+	}
+}
+
+// WriteDone 记录一块数据已写出（或写失败）。
+func (t *IdleTracker) WriteDone(now time.Time) {
+	t.pendingWrites.Add(-1)
+	t.lastWrite.Store(now.UnixNano())
+}
+
+// LastRead 返回最后一次收到数据的时间。
+func (t *IdleTracker) LastRead() time.Time {
+	return time.Unix(0, t.lastRead.Load())
+}
+
+// ReadIdle 返回距最后一次收到数据的时长。
+func (t *IdleTracker) ReadIdle(now time.Time) time.Duration {
+	return now.Sub(t.LastRead())
+}
+
+// PingDue 判断是否应主动发送 Ping：读空闲与距上次 Ping 都达到心跳间隔时返回 true 并记录本次 Ping 时间。
+// interval 为 0 表示不发送心跳。
+func (t *IdleTracker) PingDue(now time.Time, interval time.Duration) bool {
+	if interval <= 0 || t.ReadIdle(now) < interval ||
+		now.Sub(time.Unix(0, t.lastPing.Load())) < interval {
+		return false
+	}
+	t.lastPing.Store(now.UnixNano())
+	return true
+}
+
+// Check 检查是否超时：readTimeout 内没有收到任何数据返回 ErrReadTimeout，
+// 有待写数据且 writeTimeout 内没有任何写出进展返回 ErrWriteTimeout。超时参数为 0 表示不检查。
+func (t *IdleTracker) Check(now time.Time, readTimeout, writeTimeout time.Duration) error {
+	if readTimeout > 0 && t.ReadIdle(now) > readTimeout {
+		return ErrReadTimeout
+	}
+	if writeTimeout > 0 && t.pendingWrites.Load() > 0 &&
+		now.Sub(time.Unix(0, t.lastWrite.Load())) > writeTimeout {
+		return ErrWriteTimeout
+	}
+	return nil
+}
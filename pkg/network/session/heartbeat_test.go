@@ -0,0 +1,181 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria-proto-common"
+)
+
+// testSession 基于 BaseSession 的最小 Session 实现
+type testSession struct {
+	*BaseSession
+}
+
+func (s *testSession) Send(ctx context.Context, env *common.Envelope) error {
+	return s.Enqueue(ctx, env)
+}
+
+// sent 非阻塞地取出下一条待发送的消息，控制消息优先
+func (s *testSession) sent() (*common.Envelope, bool) {
+	select {
+	case env := <-s.controlCh:
+		return env, true
+	default:
+	}
+	select {
+	case env := <-s.sendCh:
+		return env, true
+	default:
+		return nil, false
+	}
+}
+
+func newTestSession(sendSize int) *testSession {
+	return &testSession{BaseSession: NewBaseSession("test", "127.0.0.1:0", &Config{
+		SendChannelSize: sendSize,
+		RecvChannelSize: 1,
+	})}
+}
+
+func TestHandleHeartbeat(t *testing.T) {
+	s := newTestSession(1)
+
+	if !HandleHeartbeat(s, NewPing([]byte("ts"))) {
+		t.Fatalf("ping should be handled")
+	}
+	env, ok := s.sent()
+	if !ok {
+		t.Fatalf("ping should be answered with pong")
+	}
+	if common.OpCode(env.Header.Op) != common.OpCode_OP_PONG || string(env.Payload) != "ts" {
+		t.Fatalf("unexpected reply: op=%d payload=%q", env.Header.Op, env.Payload)
+	}
+
+	if !HandleHeartbeat(s, NewPong(nil)) {
+		t.Fatalf("pong should be handled")
+	}
+	if _, ok := s.sent(); ok {
+		t.Fatalf("pong should not be answered")
+	}
+
+	biz := &common.Envelope{Header: &common.MessageHeader{Op: 1000}}
+	if HandleHeartbeat(s, biz) {
+		t.Fatalf("business message should not be handled as heartbeat")
+	}
+}
+
+func TestTrySend(t *testing.T) {
+	s := newTestSession(1)
+	biz := &common.Envelope{Header: &common.MessageHeader{Op: 1000}}
+
+	if !TrySend(s, biz) {
+		t.Fatalf("first send should succeed")
+	}
+	// 队列已满时不能阻塞
+	if TrySend(s, biz) {
+		t.Fatalf("send to full queue should fail")
+	}
+	// 控制消息走独立队列，不受普通消息积压影响
+	if !TrySend(s, NewPing(nil)) {
+		t.Fatalf("control message should bypass full queue")
+	}
+
+	<-s.SendChan()
+	_ = s.Close()
+	if TrySend(s, NewPing(nil)) {
+		t.Fatalf("send to closed session should fail")
+	}
+}
+
+func TestIdleTracker_Check(t *testing.T) {
+	start := time.Unix(1000, 0)
+	tr := NewIdleTracker(start)
+
+	if err := tr.Check(start.Add(59*time.Second), time.Minute, 10*time.Second); err != nil {
+		t.Fatalf("unexpected err before deadline: %v", err)
+	}
+	if err := tr.Check(start.Add(61*time.Second), time.Minute, 10*time.Second); !errors.Is(err, ErrReadTimeout) {
+		t.Fatalf("err = %v, want ErrReadTimeout", err)
+	}
+	if err := tr.Check(start.Add(time.Hour), 0, 0); err != nil {
+		t.Fatalf("zero timeouts should disable checks, got %v", err)
+	}
+
+	// 收到数据后重新计时
+	tr.MarkRead(start.Add(50 * time.Second))
+	if err := tr.Check(start.Add(100*time.Second), time.Minute, 10*time.Second); err != nil {
+		t.Fatalf("unexpected err after read: %v", err)
+	}
+	if got := tr.ReadIdle(start.Add(100 * time.Second)); got != 50*time.Second {
+		t.Fatalf("read idle = %v, want 50s", got)
+	}
+}
+
+func TestIdleTracker_PingDue(t *testing.T) {
+	start := time.Unix(1000, 0)
+	tr := NewIdleTracker(start)
+
+	if tr.PingDue(start.Add(5*time.Second), 10*time.Second) {
+		t.Fatalf("ping before heartbeat interval")
+	}
+	if !tr.PingDue(start.Add(10*time.Second), 10*time.Second) {
+		t.Fatalf("no ping after heartbeat interval")
+	}
+	// 对端仍无数据时每个心跳间隔只发送一次
+	if tr.PingDue(start.Add(15*time.Second), 10*time.Second) {
+		t.Fatalf("ping again within heartbeat interval")
+	}
+	if !tr.PingDue(start.Add(20*time.Second), 10*time.Second) {
+		t.Fatalf("no ping after another heartbeat interval")
+	}
+	if tr.PingDue(start.Add(time.Hour), 0) {
+		t.Fatalf("zero interval should disable heartbeat")
+	}
+}
+
+func TestIdleTracker_WriteTimeout(t *testing.T) {
+	start := time.Unix(1000, 0)
+	tr := NewIdleTracker(start)
+
+	// 没有待写数据时不存在写超时
+	if err := tr.Check(start.Add(time.Minute), 0, 10*time.Second); err != nil {
+		t.Fatalf("idle writer should not time out, got %v", err)
+	}
+
+	// 写积压从提交时开始计时
+	tr.WriteStarted(start.Add(time.Minute))
+	tr.WriteStarted(start.Add(time.Minute + 5*time.Second))
+	if err := tr.Check(start.Add(time.Minute+9*time.Second), 0, 10*time.Second); err != nil {
+		t.Fatalf("unexpected err before deadline: %v", err)
+	}
+
+	// 有写出进展时重新计时
+	tr.WriteDone(start.Add(time.Minute + 8*time.Second))
+	if err := tr.Check(start.Add(time.Minute+15*time.Second), 0, 10*time.Second); err != nil {
+		t.Fatalf("unexpected err after progress: %v", err)
+	}
+	if err := tr.Check(start.Add(time.Minute+19*time.Second), 0, 10*time.Second); !errors.Is(err, ErrWriteTimeout) {
+		t.Fatalf("err = %v, want ErrWriteTimeout", err)
+	}
+
+	tr.WriteDone(start.Add(2 * time.Minute))
+	if err := tr.Check(start.Add(time.Hour), 0, 10*time.Second); err != nil {
+		t.Fatalf("drained writer should not time out, got %v", err)
+	}
+}
+
+func TestBaseSession_CloseReason(t *testing.T) {
+	s := newTestSession(1)
+	if s.CloseReason() != nil {
+		t.Fatalf("close reason should be nil before close")
+	}
+
+	s.SetCloseReason(ErrReadTimeout)
+	s.SetCloseReason(ErrWriteTimeout)
+	if !errors.Is(s.CloseReason(), ErrReadTimeout) {
+		t.Fatalf("close reason = %v, first reason should win", s.CloseReason())
+	}
+}
@@ -0,0 +1,47 @@
+// network/session/metrics.go
+// 发送队列 Prometheus 指标
+package session
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// OverflowMetrics 发送队列溢出指标，实现 OverflowObserver
+type OverflowMetrics struct {
+	// 发送队列已满时执行的动作次数（按动作）
+	overflow *prometheus.CounterVec
+}
+
+// NewOverflowMetrics 创建发送队列溢出指标并注册到 registerer（为空时使用默认注册器）
+func NewOverflowMetrics(namespace string, registerer prometheus.Registerer) *OverflowMetrics {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &OverflowMetrics{
+		overflow: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "session",
+			Name:      "send_overflow_total",
+			Help:      "Total number of actions taken when a session send queue is full",
+		}, []string{"action"}), // action: blocked/timeout/drop_oldest/drop_newest/disconnect
+	}
+
+	registerer.MustRegister(m.overflow)
+
+	return m
+}
+
+// OnOverflow 记录一次溢出动作
+func (m *OverflowMetrics) OnOverflow(action OverflowAction) {
+	m.overflow.WithLabelValues(string(action)).Inc()
+}
+
+// Unregister 取消注册所有指标
+func (m *OverflowMetrics) Unregister(registerer prometheus.Registerer) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	registerer.Unregister(m.overflow)
+}
@@ -0,0 +1,196 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lk2023060901/xdooria-proto-common"
+)
+
+// OverflowPolicy 发送队列已满时的处理策略。
+type OverflowPolicy string
+
+const (
+	// OverflowBlock 等待队列腾出空间，超过 BlockTimeout 返回 ErrSendQueueFull（BlockTimeout 为 0 时一直等待）。
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropOldest 丢弃队列中最旧的消息，为新消息腾出空间。
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+	// OverflowDropNewest 丢弃新消息并返回 ErrSendQueueFull。
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+	// OverflowDisconnect 断开慢消费者并返回 ErrSlowConsumer。
+	OverflowDisconnect OverflowPolicy = "disconnect"
+)
+
+// validate 检查策略是否合法。
+func (p OverflowPolicy) validate() error {
+	switch p {
+	case OverflowBlock, OverflowDropOldest, OverflowDropNewest, OverflowDisconnect:
+		return nil
+	}
+	return fmt.Errorf("unknown overflow_policy %q", p)
+}
+
+// OverflowAction 发送队列已满时实际执行的动作。
+type OverflowAction string
+
+const (
+	// ActionBlocked 队列已满，发送方开始等待（block 策略）。
+	ActionBlocked OverflowAction = "blocked"
+	// ActionTimeout 等待超时，消息未入队（block 策略）。
+	ActionTimeout OverflowAction = "timeout"
+	// ActionDropOldest 丢弃了队列中最旧的一条消息。
+	ActionDropOldest OverflowAction = "drop_oldest"
+	// ActionDropNewest 丢弃了新消息。
+	ActionDropNewest OverflowAction = "drop_newest"
+	// ActionDisconnect 断开了慢消费者。
+	ActionDisconnect OverflowAction = "disconnect"
+)
+
+// OverflowObserver 发送队列溢出观察者，用于上报指标。
+type OverflowObserver interface {
+	// OnOverflow 发送队列已满时调用。
+	OnOverflow(action OverflowAction)
+}
+
+// IsControl 判断是否为控制消息（心跳、会话密钥握手、踢下线通知）。
+// 控制消息走独立的优先队列，不会被普通消息的积压阻塞或丢弃。
+func IsControl(env *common.Envelope) bool {
+	if IsHeartbeat(env) || IsHandshake(env) {
+		return true
+	}
+	return common.OpCode(env.GetHeader().GetOp()) == common.OpCode_OP_KICK_NOTICE
+}
+
+// isControl 判断消息是否走控制队列（内置控制消息与 Config.ControlOps）。
+func (s *BaseSession) isControl(env *common.Envelope) bool {
+	if IsControl(env) {
+		return true
+	}
+	_, ok := s.controlOps[env.GetHeader().GetOp()]
+	return ok
+}
+
+// Enqueue 按会话的溢出策略将消息压入发送队列，供传输层的 Send 调用。
+// 返回 ErrSlowConsumer 时已记录关闭原因，调用方应关闭连接。
+func (s *BaseSession) Enqueue(ctx context.Context, env *common.Envelope) error {
+	if s.ctx.Err() != nil {
+		return ErrConnectionClosed
+	}
+
+	ch := s.sendCh
+	if s.isControl(env) {
+		ch = s.controlCh
+	}
+	select {
+	case ch <- env:
+		return nil
+	default:
+	}
+
+	// 控制队列只在短时间内积压，始终等待
+	if ch == s.controlCh {
+		return s.wait(ctx, ch, env)
+	}
+
+	switch s.overflowPolicy {
+	case OverflowDropOldest:
+		return s.dropOldest(env)
+	case OverflowDropNewest:
+		s.observe(ActionDropNewest)
+		return ErrSendQueueFull
+	case OverflowDisconnect:
+		s.observe(ActionDisconnect)
+		s.SetCloseReason(ErrSlowConsumer)
+		return ErrSlowConsumer
+	default:
+		return s.wait(ctx, ch, env)
+	}
+}
+
+// TryEnqueue 非阻塞地将消息压入发送队列，队列已满或会话已关闭时返回 false。
+func (s *BaseSession) TryEnqueue(env *common.Envelope) bool {
+	if s.ctx.Err() != nil {
+		return false
+	}
+
+	ch := s.sendCh
+	if s.isControl(env) {
+		ch = s.controlCh
+	}
+	select {
+	case ch <- env:
+		return true
+	default:
+		return false
+	}
+}
+
+// NextSend 取出下一条待发送的消息，控制消息优先；会话关闭时返回 false。
+// 供传输层的写协程调用。
+func (s *BaseSession) NextSend() (*common.Envelope, bool) {
+	select {
+	case env := <-s.controlCh:
+		return env, true
+	default:
+	}
+
+	select {
+	case env := <-s.controlCh:
+		return env, true
+	case env := <-s.sendCh:
+		return env, true
+	case <-s.ctx.Done():
+		return nil, false
+	}
+}
+
+// wait 等待队列腾出空间，超过 BlockTimeout 返回 ErrSendQueueFull。
+func (s *BaseSession) wait(ctx context.Context, ch chan *common.Envelope, env *common.Envelope) error {
+	s.observe(ActionBlocked)
+
+	var timeout <-chan time.Time
+	if s.blockTimeout > 0 {
+		timer := time.NewTimer(s.blockTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case ch <- env:
+		return nil
+	case <-timeout:
+		s.observe(ActionTimeout)
+		return ErrSendQueueFull
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ctx.Done():
+		return ErrConnectionClosed
+	}
+}
+
+// dropOldest 丢弃最旧的普通消息直到新消息入队。
+func (s *BaseSession) dropOldest(env *common.Envelope) error {
+	for {
+		select {
+		case <-s.sendCh:
+			s.observe(ActionDropOldest)
+		default:
+		}
+
+		select {
+		case s.sendCh <- env:
+			return nil
+		case <-s.ctx.Done():
+			return ErrConnectionClosed
+		default:
+		}
+	}
+}
+
+// observe 上报溢出动作。
+func (s *BaseSession) observe(action OverflowAction) {
+	if s.overflowObserver != nil {
+		s.overflowObserver.OnOverflow(action)
+	}
+}
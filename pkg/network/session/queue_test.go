@@ -0,0 +1,195 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria-proto-common"
+)
+
+// countingObserver 记录各溢出动作的次数
+type countingObserver struct {
+	mu     sync.Mutex
+	counts map[OverflowAction]int
+}
+
+func (o *countingObserver) OnOverflow(action OverflowAction) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.counts == nil {
+		o.counts = make(map[OverflowAction]int)
+	}
+	o.counts[action]++
+}
+
+func (o *countingObserver) count(action OverflowAction) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.counts[action]
+}
+
+func newQueueSession(policy OverflowPolicy, obs OverflowObserver) *testSession {
+	return &testSession{BaseSession: NewBaseSession("test", "127.0.0.1:0", &Config{
+		SendChannelSize:  2,
+		RecvChannelSize:  1,
+		OverflowPolicy:   policy,
+		BlockTimeout:     20 * time.Millisecond,
+		ControlOps:       []uint32{3000},
+		OverflowObserver: obs,
+	})}
+}
+
+func bizMsg(op uint32) *common.Envelope {
+	return &common.Envelope{Header: &common.MessageHeader{Op: op}}
+}
+
+// fill 填满普通消息队列
+func fill(t *testing.T, s *testSession) {
+	t.Helper()
+	for i := uint32(1); i <= 2; i++ {
+		if err := s.Enqueue(context.Background(), bizMsg(1000+i)); err != nil {
+			t.Fatalf("enqueue %d failed: %v", i, err)
+		}
+	}
+}
+
+func TestEnqueue_Block(t *testing.T) {
+	obs := &countingObserver{}
+	s := newQueueSession(OverflowBlock, obs)
+	fill(t, s)
+
+	if err := s.Enqueue(context.Background(), bizMsg(2000)); !errors.Is(err, ErrSendQueueFull) {
+		t.Fatalf("err = %v, want ErrSendQueueFull", err)
+	}
+	if obs.count(ActionBlocked) != 1 || obs.count(ActionTimeout) != 1 {
+		t.Fatalf("counts = %v, want one blocked and one timeout", obs.counts)
+	}
+
+	// 写协程取走消息后，等待中的发送方成功入队
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		s.NextSend()
+	}()
+	if err := s.Enqueue(context.Background(), bizMsg(2001)); err != nil {
+		t.Fatalf("enqueue after drain failed: %v", err)
+	}
+}
+
+func TestEnqueue_DropOldest(t *testing.T) {
+	obs := &countingObserver{}
+	s := newQueueSession(OverflowDropOldest, obs)
+	fill(t, s)
+
+	if err := s.Enqueue(context.Background(), bizMsg(2000)); err != nil {
+		t.Fatalf("enqueue failed: %v", err)
+	}
+	if obs.count(ActionDropOldest) != 1 {
+		t.Fatalf("drop_oldest count = %d, want 1", obs.count(ActionDropOldest))
+	}
+
+	var ops []uint32
+	for {
+		env, ok := s.sent()
+		if !ok {
+			break
+		}
+		ops = append(ops, env.Header.Op)
+	}
+	if len(ops) != 2 || ops[0] != 1002 || ops[1] != 2000 {
+		t.Fatalf("queued ops = %v, want [1002 2000]", ops)
+	}
+}
+
+func TestEnqueue_DropNewest(t *testing.T) {
+	obs := &countingObserver{}
+	s := newQueueSession(OverflowDropNewest, obs)
+	fill(t, s)
+
+	if err := s.Enqueue(context.Background(), bizMsg(2000)); !errors.Is(err, ErrSendQueueFull) {
+		t.Fatalf("err = %v, want ErrSendQueueFull", err)
+	}
+	if obs.count(ActionDropNewest) != 1 {
+		t.Fatalf("drop_newest count = %d, want 1", obs.count(ActionDropNewest))
+	}
+	if env, _ := s.sent(); env.Header.Op != 1001 {
+		t.Fatalf("head op = %d, want 1001", env.Header.Op)
+	}
+}
+
+func TestEnqueue_Disconnect(t *testing.T) {
+	obs := &countingObserver{}
+	s := newQueueSession(OverflowDisconnect, obs)
+	fill(t, s)
+
+	if err := s.Enqueue(context.Background(), bizMsg(2000)); !errors.Is(err, ErrSlowConsumer) {
+		t.Fatalf("err = %v, want ErrSlowConsumer", err)
+	}
+	if !errors.Is(s.CloseReason(), ErrSlowConsumer) {
+		t.Fatalf("close reason = %v, want ErrSlowConsumer", s.CloseReason())
+	}
+	if obs.count(ActionDisconnect) != 1 {
+		t.Fatalf("disconnect count = %d, want 1", obs.count(ActionDisconnect))
+	}
+}
+
+func TestEnqueue_ControlLane(t *testing.T) {
+	obs := &countingObserver{}
+	s := newQueueSession(OverflowDisconnect, obs)
+	fill(t, s)
+
+	// 普通消息积压时，控制消息与配置的 ControlOps 仍可入队且不触发溢出策略
+	if err := s.Enqueue(context.Background(), NewPing(nil)); err != nil {
+		t.Fatalf("enqueue ping failed: %v", err)
+	}
+	if err := s.Enqueue(context.Background(), bizMsg(3000)); err != nil {
+		t.Fatalf("enqueue control op failed: %v", err)
+	}
+	if s.CloseReason() != nil || obs.count(ActionDisconnect) != 0 {
+		t.Fatalf("control messages should not trigger overflow policy")
+	}
+
+	// 控制消息先于普通消息发出
+	want := []uint32{uint32(common.OpCode_OP_PING), 3000, 1001, 1002}
+	for i, op := range want {
+		env, ok := s.NextSend()
+		if !ok || env.Header.Op != op {
+			t.Fatalf("message %d: op=%v ok=%v, want %d", i, env.GetHeader().GetOp(), ok, op)
+		}
+	}
+
+	_ = s.Close()
+	if _, ok := s.NextSend(); ok {
+		t.Fatalf("NextSend should report closed session")
+	}
+	if err := s.Enqueue(context.Background(), NewPing(nil)); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("err = %v, want ErrConnectionClosed", err)
+	}
+}
+
+func TestPushRecv_KeepsLatest(t *testing.T) {
+	s := newQueueSession(OverflowBlock, nil)
+
+	// 接收队列无人读取时保留最近的消息，不拒绝新消息
+	for i := uint32(1); i <= 3; i++ {
+		if err := s.PushRecv(bizMsg(1000 + i)); err != nil {
+			t.Fatalf("push recv %d failed: %v", i, err)
+		}
+	}
+	if env := <-s.RecvChan(); env.Header.Op != 1003 {
+		t.Fatalf("recv op = %d, want 1003", env.Header.Op)
+	}
+}
+
+func TestConfig_ValidateOverflowPolicy(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("default config invalid: %v", err)
+	}
+	cfg.OverflowPolicy = "drop_all"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("unknown policy should be rejected")
+	}
+}
@@ -0,0 +1,248 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lk2023060901/xdooria-proto-common"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultRPCTimeout 调用方未设置截止时间时的默认调用超时。
+const DefaultRPCTimeout = 10 * time.Second
+
+// rpcResponseBit RpcId 的最高位表示响应，请求的关联 ID 只使用低 31 位，
+// 通信双方可以同时向对方发起调用而不会混淆彼此的 ID。
+const rpcResponseBit uint32 = 1 << 31
+
+// Sender 发送消息信封，Session 实现了该接口。
+type Sender interface {
+	Send(ctx context.Context, env *common.Envelope) error
+}
+
+// Caller 请求/响应调用接口。
+type Caller interface {
+	// Call 发送请求并等待对应的响应。
+	Call(ctx context.Context, op uint32, payload []byte) (*common.Envelope, error)
+}
+
+// IsRequest 判断是否为等待响应的 RPC 请求。
+func IsRequest(env *common.Envelope) bool {
+	id := env.GetHeader().GetRpcId()
+	return id != 0 && id&rpcResponseBit == 0
+}
+
+// IsResponse 判断是否为 RPC 响应。
+func IsResponse(env *common.Envelope) bool {
+	return env.GetHeader().GetRpcId()&rpcResponseBit != 0
+}
+
+// Reply 构造对 req 的响应，带回请求的关联 ID；req 不是 RPC 请求时构造普通消息。
+func Reply(req *common.Envelope, op uint32, payload []byte) *common.Envelope {
+	header := &common.MessageHeader{Op: op}
+	if IsRequest(req) {
+		header.RpcId = req.GetHeader().GetRpcId() | rpcResponseBit
+	}
+	return &common.Envelope{Header: header, Payload: payload}
+}
+
+// pendingCall 等待响应的调用
+type pendingCall struct {
+	sender Sender
+	ch     chan *common.Envelope
+}
+
+// RPC 基于会话的请求/响应调用表。
+// 请求在 MessageHeader.RpcId 中携带关联 ID，对端使用 Reply 构造响应带回同一 ID，
+// 收到的响应通过 Deliver（或 Handler 包装的 SessionHandler）交给等待中的调用。
+// 一个 RPC 可以同时服务多个会话。
+type RPC struct {
+	timeout time.Duration
+	nextId  atomic.Uint32
+
+	mu      sync.Mutex
+	pending map[uint32]*pendingCall
+}
+
+// NewRPC 创建调用表，timeout 为 ctx 未设置截止时间时每次调用的超时，0 表示只受 ctx 控制。
+func NewRPC(timeout time.Duration) *RPC {
+	return &RPC{
+		timeout: timeout,
+		pending: make(map[uint32]*pendingCall),
+	}
+}
+
+// Call 通过 s 发送请求并等待响应。
+// 超时返回 ErrRPCTimeout，ctx 取消返回 ctx.Err()，会话关闭返回 ErrConnectionClosed；
+// 之后才到达的响应会被丢弃。
+func (r *RPC) Call(ctx context.Context, s Sender, op uint32, payload []byte) (*common.Envelope, error) {
+	if _, ok := ctx.Deadline(); !ok && r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	id, call := r.register(s)
+	defer r.remove(id)
+
+	req := &common.Envelope{
+		Header:  &common.MessageHeader{Op: op, RpcId: id},
+		Payload: payload,
+	}
+	if err := s.Send(ctx, req); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp, ok := <-call.ch:
+		if !ok {
+			return nil, ErrConnectionClosed
+		}
+		return resp, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: op=%d", ErrRPCTimeout, op)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Bind 返回通过 s 发起调用的 Caller。
+func (r *RPC) Bind(s Sender) Caller {
+	return &boundCaller{rpc: r, sender: s}
+}
+
+// Deliver 将从 s 收到的响应交给等待中的调用，返回 true 表示消息是 RPC 响应（包括已超时的调用的响应）。
+// 关联 ID 在所有会话间共享，只有经发起调用的同一会话到达的响应才会交给该调用，其他会话的响应被丢弃。
+// 在 SessionHandler.OnMessage 中先于业务处理调用。
+func (r *RPC) Deliver(s Sender, env *common.Envelope) bool {
+	if !IsResponse(env) {
+		return false
+	}
+
+	id := env.GetHeader().GetRpcId() &^ rpcResponseBit
+	r.mu.Lock()
+	call, ok := r.pending[id]
+	ok = ok && call.sender == s
+	if ok {
+		delete(r.pending, id)
+	}
+	r.mu.Unlock()
+
+	if ok {
+		call.ch <- env
+	}
+	return true
+}
+
+// Fail 使通过 s 发起、仍在等待的调用返回 ErrConnectionClosed，在会话关闭时调用。
+func (r *RPC) Fail(s Sender) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, call := range r.pending {
+		if call.sender == s {
+			delete(r.pending, id)
+			close(call.ch)
+		}
+	}
+}
+
+// Handler 包装 SessionHandler：RPC 响应交给等待中的调用，不再传给 next；会话关闭时结束该会话上的调用。
+func (r *RPC) Handler(next SessionHandler) SessionHandler {
+	return &rpcHandler{rpc: r, next: next}
+}
+
+// register 分配关联 ID 并登记等待中的调用
+func (r *RPC) register(s Sender) (uint32, *pendingCall) {
+	call := &pendingCall{sender: s, ch: make(chan *common.Envelope, 1)}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		id := r.nextId.Add(1) &^ rpcResponseBit
+		if _, used := r.pending[id]; id == 0 || used {
+			continue
+		}
+		r.pending[id] = call
+		return id, call
+	}
+}
+
+// remove 移除调用（已被 Deliver 或 Fail 移除时无操作）
+func (r *RPC) remove(id uint32) {
+	r.mu.Lock()
+	delete(r.pending, id)
+	r.mu.Unlock()
+}
+
+// boundCaller 绑定到会话的 Caller
+type boundCaller struct {
+	rpc    *RPC
+	sender Sender
+}
+
+func (c *boundCaller) Call(ctx context.Context, op uint32, payload []byte) (*common.Envelope, error) {
+	return c.rpc.Call(ctx, c.sender, op, payload)
+}
+
+// rpcHandler 分发 RPC 响应的 SessionHandler
+type rpcHandler struct {
+	rpc  *RPC
+	next SessionHandler
+}
+
+func (h *rpcHandler) OnOpened(s Session) {
+	h.next.OnOpened(s)
+}
+
+func (h *rpcHandler) OnClosed(s Session, err error) {
+	h.rpc.Fail(s)
+	h.next.OnClosed(s, err)
+}
+
+func (h *rpcHandler) OnMessage(s Session, env *common.Envelope) {
+	if h.rpc.Deliver(s, env) {
+		return
+	}
+	h.next.OnMessage(s, env)
+}
+
+func (h *rpcHandler) OnError(s Session, err error) {
+	h.next.OnError(s, err)
+}
+
+// --- 泛型支持部分 ---
+
+// Invoke 使用泛型发起调用，自动处理 Proto 序列化和反序列化（与 router.RegisterHandler 对应）
+// respOp: 期望的响应操作码，不一致时返回 ErrUnexpectedResponse
+func Invoke[T any, PT interface {
+	*T
+	proto.Message
+}](ctx context.Context, c Caller, reqOp uint32, respOp uint32, req proto.Message) (PT, error) {
+	// 1. 序列化请求
+	payload, err := proto.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request failed: %w", err)
+	}
+
+	// 2. 发起调用
+	env, err := c.Call(ctx, reqOp, payload)
+	if err != nil {
+		return nil, err
+	}
+	if op := env.GetHeader().GetOp(); op != respOp {
+		return nil, fmt.Errorf("%w: op=%d, want %d", ErrUnexpectedResponse, op, respOp)
+	}
+
+	// 3. 反序列化响应
+	resp := PT(new(T))
+	if err := proto.Unmarshal(env.GetPayload(), resp); err != nil {
+		return nil, fmt.Errorf("unmarshal response failed: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,215 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lk2023060901/xdooria-proto-common"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// recordingHandler 记录传给业务处理器的消息与关闭事件
+type recordingHandler struct {
+	NopSessionHandler
+	messages []*common.Envelope
+	closed   bool
+}
+
+func (h *recordingHandler) OnMessage(_ Session, env *common.Envelope) {
+	h.messages = append(h.messages, env)
+}
+
+func (h *recordingHandler) OnClosed(Session, error) {
+	h.closed = true
+}
+
+// serve 模拟对端：取出 n 条请求，按 respond 构造响应后逆序交给 deliver
+func serve(t *testing.T, s *testSession, n int, respond func(req *common.Envelope) *common.Envelope, deliver func(*common.Envelope)) {
+	t.Helper()
+	go func() {
+		var replies []*common.Envelope
+		for len(replies) < n {
+			req, ok := s.NextSend()
+			if !ok {
+				return
+			}
+			replies = append(replies, respond(req))
+		}
+		for i := len(replies) - 1; i >= 0; i-- {
+			deliver(replies[i])
+		}
+	}()
+}
+
+func echo(req *common.Envelope) *common.Envelope {
+	return Reply(req, req.Header.Op+1, req.Payload)
+}
+
+func TestRPC_Call(t *testing.T) {
+	s := newTestSession(8)
+	r := NewRPC(time.Second)
+
+	// 响应逆序到达，按关联 ID 交给对应的调用
+	serve(t, s, 3, echo, func(env *common.Envelope) { r.Deliver(s, env) })
+
+	type result struct {
+		payload string
+		err     error
+	}
+	results := make(chan result, 3)
+	for _, p := range []string{"a", "b", "c"} {
+		go func(p string) {
+			resp, err := r.Call(context.Background(), s, 1000, []byte(p))
+			if err != nil {
+				results <- result{err: err}
+				return
+			}
+			if resp.Header.Op != 1001 || !IsResponse(resp) {
+				results <- result{err: errors.New("unexpected response header")}
+				return
+			}
+			results <- result{payload: string(resp.Payload) + "=" + p}
+		}(p)
+	}
+	for range 3 {
+		res := <-results
+		if res.err != nil {
+			t.Fatalf("call failed: %v", res.err)
+		}
+		if res.payload[0] != res.payload[2] {
+			t.Fatalf("response %q delivered to wrong call", res.payload)
+		}
+	}
+	if len(r.pending) != 0 {
+		t.Fatalf("pending calls left: %d", len(r.pending))
+	}
+}
+
+func TestRPC_Timeout(t *testing.T) {
+	s := newTestSession(8)
+	r := NewRPC(20 * time.Millisecond)
+
+	if _, err := r.Call(context.Background(), s, 1000, nil); !errors.Is(err, ErrRPCTimeout) {
+		t.Fatalf("err = %v, want ErrRPCTimeout", err)
+	}
+
+	// 超时后到达的响应被丢弃，不交给业务处理器
+	req, _ := s.sent()
+	if !r.Deliver(s, echo(req)) {
+		t.Fatalf("late response should still be recognized as a response")
+	}
+
+	// ctx 取消
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.Call(ctx, s, 1000, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestRPC_DeliverOtherSession(t *testing.T) {
+	s := newTestSession(8)
+	other := newTestSession(8)
+	r := NewRPC(time.Second)
+
+	// 其他会话带回相同关联 ID 的响应被丢弃，调用继续等待本会话的响应
+	serve(t, s, 1, echo, func(env *common.Envelope) {
+		forged := &common.Envelope{Header: &common.MessageHeader{Op: 9999, RpcId: env.Header.RpcId}}
+		if !r.Deliver(other, forged) {
+			t.Errorf("response from another session should still be recognized as a response")
+		}
+		r.Deliver(s, env)
+	})
+	resp, err := r.Call(context.Background(), s, 1000, []byte("x"))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if resp.Header.Op != 1001 {
+		t.Fatalf("op = %d, want response from the calling session", resp.Header.Op)
+	}
+}
+
+func TestRPC_Handler(t *testing.T) {
+	s := newTestSession(8)
+	r := NewRPC(time.Second)
+	next := &recordingHandler{}
+	h := r.Handler(next)
+
+	// 请求与普通消息交给业务处理器
+	notify := &common.Envelope{Header: &common.MessageHeader{Op: 2000}}
+	req := &common.Envelope{Header: &common.MessageHeader{Op: 2002, RpcId: 5}}
+	h.OnMessage(s, notify)
+	h.OnMessage(s, req)
+	if len(next.messages) != 2 || !IsRequest(next.messages[1]) {
+		t.Fatalf("handler got %d messages, want notify and request", len(next.messages))
+	}
+
+	// 响应交给等待中的调用
+	serve(t, s, 1, echo, func(env *common.Envelope) { h.OnMessage(s, env) })
+	if _, err := r.Call(context.Background(), s, 1000, []byte("x")); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if len(next.messages) != 2 {
+		t.Fatalf("response should not reach the business handler")
+	}
+
+	// 会话关闭时等待中的调用立即返回
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := r.Call(context.Background(), s, 1000, nil)
+		errCh <- err
+	}()
+	if _, ok := s.NextSend(); !ok {
+		t.Fatalf("request was not sent")
+	}
+	h.OnClosed(s, nil)
+	if err := <-errCh; !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("err = %v, want ErrConnectionClosed", err)
+	}
+	if !next.closed {
+		t.Fatalf("OnClosed should be passed through")
+	}
+}
+
+func TestReply(t *testing.T) {
+	req := &common.Envelope{Header: &common.MessageHeader{Op: 1000, RpcId: 42}}
+	resp := Reply(req, 1001, nil)
+	if !IsResponse(resp) || IsRequest(resp) || resp.Header.RpcId&^rpcResponseBit != 42 {
+		t.Fatalf("rpc id = %#x, want response to 42", resp.Header.RpcId)
+	}
+
+	// 普通消息的响应不带关联 ID
+	if resp := Reply(&common.Envelope{Header: &common.MessageHeader{Op: 1000}}, 1001, nil); resp.Header.RpcId != 0 {
+		t.Fatalf("rpc id = %d, want 0", resp.Header.RpcId)
+	}
+}
+
+func TestInvoke(t *testing.T) {
+	s := newTestSession(8)
+	r := NewRPC(time.Second)
+	caller := r.Bind(s)
+
+	serve(t, s, 1, func(req *common.Envelope) *common.Envelope {
+		var in wrapperspb.StringValue
+		_ = proto.Unmarshal(req.Payload, &in)
+		out, _ := proto.Marshal(wrapperspb.Int64(int64(len(in.GetValue()))))
+		return Reply(req, 1001, out)
+	}, func(env *common.Envelope) { r.Deliver(s, env) })
+
+	resp, err := Invoke[wrapperspb.Int64Value](context.Background(), caller, 1000, 1001, wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatalf("invoke failed: %v", err)
+	}
+	if resp.GetValue() != 5 {
+		t.Fatalf("value = %d, want 5", resp.GetValue())
+	}
+
+	// 响应操作码与预期不一致
+	serve(t, s, 1, echo, func(env *common.Envelope) { r.Deliver(s, env) })
+	if _, err := Invoke[wrapperspb.Int64Value](context.Background(), caller, 1000, 1005, wrapperspb.String("x")); !errors.Is(err, ErrUnexpectedResponse) {
+		t.Fatalf("err = %v, want ErrUnexpectedResponse", err)
+	}
+}
@@ -3,7 +3,8 @@ package session
 
 import (
 	"context"
-	"errors"
+	"sync"
+	"time"
 
 	"github.com/lk2023060901/xdooria-proto-common"
 	"github.com/lk2023060901/xdooria/pkg/config"
@@ -38,6 +39,22 @@ type BaseSession struct {
 	sendCh     chan *common.Envelope
 	recvCh     chan *common.Envelope
 	framer     framer.Framer
+	idle       *IdleTracker
+
+	// 发送队列：控制消息优先，普通消息按溢出策略处理
+	controlCh        chan *common.Envelope
+	controlOps       map[uint32]struct{}
+	overflowPolicy   OverflowPolicy
+	blockTimeout     time.Duration
+	overflowObserver OverflowObserver
+
+	// 批量合并，只在写协程中访问
+	batch    BatchConfig
+	batchBuf []*common.Envelope
+	pending  *common.Envelope
+
+	closeMu     sync.Mutex
+	closeReason error
 }
 
 // NewBaseSession 创建一个新的基础会话。
@@ -45,15 +62,36 @@ func NewBaseSession(id string, remoteAddr string, cfg *Config) *BaseSession {
 	// 使用 MergeConfig 确保配置完整
 	newCfg, _ := config.MergeConfig(DefaultConfig(), cfg)
 
+	// 每个会话使用独立的序列号与防重放窗口
+	fr := newCfg.Framer
+	if forked, err := framer.ForSession(fr); err == nil {
+		fr = forked
+	}
+	if fc := newCfg.FramerConfig; fr != nil && fc != nil && fc.KeyExchange.Enabled {
+		fr = framer.NewSession(fr, fc)
+	}
+
+	controlOps := make(map[uint32]struct{}, len(newCfg.ControlOps))
+	for _, op := range newCfg.ControlOps {
+		controlOps[op] = struct{}{}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	return &BaseSession{
-		id:         id,
-		remoteAddr: remoteAddr,
-		ctx:        ctx,
-		cancel:     cancel,
-		sendCh:     make(chan *common.Envelope, newCfg.SendChannelSize),
-		recvCh:     make(chan *common.Envelope, newCfg.RecvChannelSize),
-		framer:     newCfg.Framer,
+		id:               id,
+		remoteAddr:       remoteAddr,
+		ctx:              ctx,
+		cancel:           cancel,
+		sendCh:           make(chan *common.Envelope, newCfg.SendChannelSize),
+		recvCh:           make(chan *common.Envelope, newCfg.RecvChannelSize),
+		framer:           fr,
+		idle:             NewIdleTracker(time.Now()),
+		controlCh:        make(chan *common.Envelope, newCfg.ControlChannelSize),
+		controlOps:       controlOps,
+		overflowPolicy:   newCfg.OverflowPolicy,
+		blockTimeout:     newCfg.BlockTimeout,
+		overflowObserver: newCfg.OverflowObserver,
+		batch:            newCfg.Batch,
 	}
 }
 
@@ -78,7 +116,7 @@ func (s *BaseSession) Close() error {
 	return nil
 }
 
-// SendChan 返回发送通道。
+// SendChan 返回普通消息的发送通道（控制消息见 Enqueue / NextSend）。
 func (s *BaseSession) SendChan() chan *common.Envelope {
 	return s.sendCh
 }
@@ -89,18 +127,56 @@ func (s *BaseSession) RecvChan() chan *common.Envelope {
 }
 
 // PushRecv 将接收到的消息信封压入接收队列。
+// 接收队列只保留最近的消息：队列已满时丢弃最旧的一条，避免无人读取时后续消息全部被拒绝。
 func (s *BaseSession) PushRecv(env *common.Envelope) error {
-	select {
-	case s.recvCh <- env:
-		return nil
-	case <-s.ctx.Done():
-		return s.ctx.Err()
-	default:
-		return errors.New("recv channel full")
+	for {
+		select {
+		case s.recvCh <- env:
+			return nil
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		default:
+		}
+
+		select {
+		case <-s.recvCh:
+		default:
+		}
 	}
 }
 
 // Framer 返回消息帧处理器。
 func (s *BaseSession) Framer() framer.Framer {
 	return s.framer
-}
\ No newline at end of file
+}
+
+// Idle 返回会话的读写活跃时间跟踪器。
+func (s *BaseSession) Idle() *IdleTracker {
+	return s.idle
+}
+
+// SetCloseReason 记录会话关闭原因（如读超时），只保留第一次设置的原因。
+// 传输层在 OnClosed 回调时优先使用该原因。
+func (s *BaseSession) SetCloseReason(err error) {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+	if s.closeReason == nil {
+		s.closeReason = err
+	}
+}
+
+// CloseReason 返回会话关闭原因，未设置时返回 nil。
+func (s *BaseSession) CloseReason() error {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+	return s.closeReason
+}
+
+// Closed 传输层连接已关闭时调用：停止写协程，返回应传给 OnClosed 的原因（优先使用 SetCloseReason 记录的原因）。
+func (s *BaseSession) Closed(err error) error {
+	_ = s.Close()
+	if reason := s.CloseReason(); reason != nil {
+		return reason
+	}
+	return err
+}
@@ -3,10 +3,9 @@ package tcp
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
-	common "github.com/lk2023060901/xdooria-proto-common"
-	"github.com/lk2023060901/xdooria/pkg/network/framer"
 	"github.com/lk2023060901/xdooria/pkg/network/session"
 	"github.com/lk2023060901/xdooria/pkg/util/conc"
 	"github.com/panjf2000/gnet/v2"
@@ -18,6 +17,9 @@ type Acceptor struct {
 	config        *ServerConfig
 	sessionConfig *session.Config
 	handler       session.SessionHandler
+	codec         *Codec
+	idle          session.IdlePolicy
+	sessions      sync.Map // sessionID -> *TCPSession，用于定时检查读写超时
 	engine        gnet.Engine
 	started       bool
 }
@@ -30,6 +32,12 @@ func NewAcceptor(cfg *ServerConfig, sessCfg *session.Config, handler session.Ses
 		config:        cfg,
 		sessionConfig: sessCfg,
 		handler:       handler,
+		codec:         NewCodec(cfg.MaxMessageSize),
+		idle: session.IdlePolicy{
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			HeartbeatInterval: cfg.HeartbeatInterval,
+		},
 	}
 }
 
@@ -40,6 +48,7 @@ func (a *Acceptor) Start() error {
 		gnet.WithReuseAddr(a.config.ReuseAddr),
 		gnet.WithTCPKeepAlive(a.config.TCPKeepAlive),
 		gnet.WithTCPNoDelay(gnet.TCPNoDelay),
+		gnet.WithTicker(true),
 	}
 	if a.config.NumEventLoop > 0 {
 		opts = append(opts, gnet.WithNumEventLoop(a.config.NumEventLoop))
@@ -78,8 +87,9 @@ func (a *Acceptor) OnBoot(eng gnet.Engine) (action gnet.Action) {
 
 // OnOpen 实现 gnet.EventHandler。
 func (a *Acceptor) OnOpen(c gnet.Conn) (out []byte, action gnet.Action) {
-	s := NewTCPSession(c, a.sessionConfig)
+	s := NewTCPSession(c, a.sessionConfig, a.codec)
 	c.SetContext(s)
+	a.sessions.Store(s.ID(), s)
 	a.handler.OnOpened(s)
 	return nil, gnet.None
 }
@@ -87,36 +97,45 @@ func (a *Acceptor) OnOpen(c gnet.Conn) (out []byte, action gnet.Action) {
 // OnClose 实现 gnet.EventHandler。
 func (a *Acceptor) OnClose(c gnet.Conn, err error) (action gnet.Action) {
 	if s, ok := c.Context().(*TCPSession); ok {
-		a.handler.OnClosed(s, err)
+		a.sessions.Delete(s.ID())
+		a.handler.OnClosed(s, s.Closed(err))
 	}
 	return
 }
 
+// OnTick 实现 gnet.EventHandler，定期检查所有连接的读写超时并发送心跳。
+func (a *Acceptor) OnTick() (delay time.Duration, action gnet.Action) {
+	now := time.Now()
+	a.sessions.Range(func(_, v any) bool {
+		session.CheckIdle(v.(*TCPSession), now, a.idle)
+		return true
+	})
+	return session.IdleCheckInterval, gnet.None
+}
+
 // OnTraffic 实现 gnet.EventHandler。
+// 一次读取可能包含多个帧或半个帧，不完整的帧留在 gnet 缓冲区中等待后续数据。
 func (a *Acceptor) OnTraffic(c gnet.Conn) gnet.Action {
-	data, _ := c.Next(-1)
-	if s, ok := c.Context().(*TCPSession); ok {
-		env, err := framer.Unmarshal(data)
-		if err != nil {
-			a.handler.OnError(s, err)
-			return gnet.None
+	s, ok := c.Context().(*TCPSession)
+	if !ok {
+		return gnet.Close
+	}
+	// 收到任何数据（包括不完整的帧）都视为活跃
+	s.Idle().MarkRead(time.Now())
+
+	err := a.codec.DecodeFrames(c, func(frame []byte) {
+		envs, err := session.DecodeFrame(s, frame)
+		for _, env := range envs {
+			a.handler.OnMessage(s, env)
 		}
-		// 验证签名并解密/解压
-		op, payload, err := s.Framer().Decode(env)
 		if err != nil {
 			a.handler.OnError(s, err)
-			return gnet.None
-		}
-		// 构建解码后的 Envelope
-		decodedEnv := &common.Envelope{
-			Header:  &common.MessageHeader{Op: op},
-			Payload: payload,
-		}
-		if err := s.PushRecv(decodedEnv); err != nil {
-			a.handler.OnError(s, err)
-			return gnet.None
 		}
-		a.handler.OnMessage(s, decodedEnv)
+	})
+	if err != nil {
+		// 帧长度非法，无法再定位后续帧的边界，只能断开连接
+		a.handler.OnError(s, err)
+		return gnet.Close
 	}
 	return gnet.None
-}
\ No newline at end of file
+}
@@ -0,0 +1,92 @@
+package tcp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// FrameHeaderSize 帧头（长度字段）字节数
+//
+// 帧格式：
+//
+//	+----------------------+---------------------------+
+//	| Length (4B, 大端)     | Body (Envelope protobuf)  |
+//	+----------------------+---------------------------+
+//
+// Length 为 Body 的字节数，不包含帧头本身
+const FrameHeaderSize = 4
+
+// DefaultMaxMessageSize 默认单帧最大字节数（不含帧头）
+const DefaultMaxMessageSize = 1024 * 1024
+
+// inboundBuffer 可预读的入站缓冲区（gnet.Conn 实现）
+// 未消费的数据留在缓冲区中，下次 OnTraffic 时与新到达的数据拼接
+type inboundBuffer interface {
+	InboundBuffered() int
+	Peek(n int) ([]byte, error)
+	Discard(n int) (int, error)
+}
+
+// Codec 长度前缀流式编解码器
+// TCP 是字节流，一次读取可能包含多个帧，也可能只有半个帧，必须按长度头切分
+type Codec struct {
+	maxMessageSize int
+}
+
+// NewCodec 创建编解码器，maxMessageSize <= 0 时使用 DefaultMaxMessageSize
+func NewCodec(maxMessageSize int) *Codec {
+	if maxMessageSize <= 0 {
+		maxMessageSize = DefaultMaxMessageSize
+	}
+	return &Codec{maxMessageSize: maxMessageSize}
+}
+
+// MaxMessageSize 返回单帧最大字节数
+func (c *Codec) MaxMessageSize() int {
+	return c.maxMessageSize
+}
+
+// Encode 为消息体添加长度头
+func (c *Codec) Encode(body []byte) ([]byte, error) {
+	if len(body) > c.maxMessageSize {
+		return nil, fmt.Errorf("%w: %d > %d", ErrMessageTooBig, len(body), c.maxMessageSize)
+	}
+
+	frame := make([]byte, FrameHeaderSize+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(len(body)))
+	copy(frame[FrameHeaderSize:], body)
+	return frame, nil
+}
+
+// DecodeFrames 依次取出缓冲区中所有完整的帧交给 fn 处理，不完整的帧保留在缓冲区等待后续数据
+// body 直接引用缓冲区内存，只在 fn 执行期间有效，需要保留时由 fn 自行复制
+// 返回 ErrPacketTooLarge 时已无法定位后续帧的边界，调用方应关闭连接
+func (c *Codec) DecodeFrames(buf inboundBuffer, fn func(body []byte)) error {
+	for buf.InboundBuffered() >= FrameHeaderSize {
+		header, err := buf.Peek(FrameHeaderSize)
+		if err != nil {
+			return err
+		}
+
+		size := binary.BigEndian.Uint32(header)
+		if uint64(size) > uint64(c.maxMessageSize) {
+			return fmt.Errorf("%w: %d > %d", ErrPacketTooLarge, size, c.maxMessageSize)
+		}
+
+		frameSize := FrameHeaderSize + int(size)
+		if buf.InboundBuffered() < frameSize {
+			return nil
+		}
+
+		frame, err := buf.Peek(frameSize)
+		if err != nil {
+			return err
+		}
+		fn(frame[FrameHeaderSize:])
+
+		if _, err := buf.Discard(frameSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
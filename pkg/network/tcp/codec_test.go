@@ -0,0 +1,270 @@
+package tcp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+// fakeInbound 模拟 gnet 的入站缓冲区：write 追加一次读取到的数据，未消费的数据保留到下次
+type fakeInbound struct {
+	buf []byte
+}
+
+func (b *fakeInbound) write(data []byte) {
+	b.buf = append(b.buf, data...)
+}
+
+func (b *fakeInbound) InboundBuffered() int {
+	return len(b.buf)
+}
+
+func (b *fakeInbound) Peek(n int) ([]byte, error) {
+	if n > len(b.buf) {
+		return b.buf, io.ErrShortBuffer
+	}
+	return b.buf[:n], nil
+}
+
+func (b *fakeInbound) Discard(n int) (int, error) {
+	n = min(n, len(b.buf))
+	// 模拟缓冲区复用：已消费的数据被覆盖，回调中保留的引用会读到脏数据
+	for i := range b.buf[:n] {
+		b.buf[i] = 0xEE
+	}
+	b.buf = b.buf[n:]
+	return n, nil
+}
+
+// feed 按 chunks 指定的长度分片写入 data，每次写入后解码，返回解出的所有帧
+func feed(t testing.TB, c *Codec, data []byte, chunks []int) ([][]byte, error) {
+	t.Helper()
+
+	in := &fakeInbound{}
+	var frames [][]byte
+	collect := func(body []byte) {
+		frames = append(frames, bytes.Clone(body))
+	}
+
+	for len(data) > 0 {
+		n := len(data)
+		if len(chunks) > 0 {
+			n = min(max(chunks[0], 1), len(data))
+			chunks = chunks[1:]
+		}
+		in.write(data[:n])
+		data = data[n:]
+
+		if err := c.DecodeFrames(in, collect); err != nil {
+			return frames, err
+		}
+	}
+
+	if in.InboundBuffered() != 0 {
+		return frames, ErrIncompleteFrame
+	}
+	return frames, nil
+}
+
+func encodeAll(t testing.TB, c *Codec, bodies ...[]byte) []byte {
+	t.Helper()
+
+	var stream []byte
+	for _, body := range bodies {
+		frame, err := c.Encode(body)
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		stream = append(stream, frame...)
+	}
+	return stream
+}
+
+func TestCodec_Encode(t *testing.T) {
+	c := NewCodec(8)
+
+	frame, err := c.Encode([]byte("hello"))
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if want := append([]byte{0, 0, 0, 5}, "hello"...); !bytes.Equal(frame, want) {
+		t.Fatalf("frame = %v, want %v", frame, want)
+	}
+
+	if _, err := c.Encode(make([]byte, 9)); !errors.Is(err, ErrMessageTooBig) {
+		t.Fatalf("oversized encode err = %v, want ErrMessageTooBig", err)
+	}
+	if _, err := c.Encode(make([]byte, 8)); err != nil {
+		t.Fatalf("encode at limit failed: %v", err)
+	}
+
+	if NewCodec(0).MaxMessageSize() != DefaultMaxMessageSize {
+		t.Fatalf("zero max size should use default")
+	}
+}
+
+func TestCodec_MultipleFramesInOneRead(t *testing.T) {
+	c := NewCodec(0)
+	bodies := [][]byte{[]byte("a"), {}, []byte("hello"), bytes.Repeat([]byte{7}, 300)}
+
+	frames, err := feed(t, c, encodeAll(t, c, bodies...), nil)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(frames) != len(bodies) {
+		t.Fatalf("got %d frames, want %d", len(frames), len(bodies))
+	}
+	for i := range bodies {
+		if !bytes.Equal(frames[i], bodies[i]) {
+			t.Fatalf("frame %d = %v, want %v", i, frames[i], bodies[i])
+		}
+	}
+}
+
+func TestCodec_Fragmented(t *testing.T) {
+	c := NewCodec(0)
+	bodies := [][]byte{[]byte("first"), []byte("second message"), bytes.Repeat([]byte{1, 2, 3}, 100)}
+	stream := encodeAll(t, c, bodies...)
+
+	cases := map[string][]int{
+		"byte by byte":       repeatInt(1, len(stream)),
+		"split header":       {2, 3, 10},
+		"header then body":   {FrameHeaderSize, 5, FrameHeaderSize},
+		"frame plus partial": {FrameHeaderSize + 5 + 6, 3},
+		"odd chunks":         repeatInt(7, len(stream)),
+	}
+
+	for name, chunks := range cases {
+		t.Run(name, func(t *testing.T) {
+			frames, err := feed(t, c, stream, chunks)
+			if err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if len(frames) != len(bodies) {
+				t.Fatalf("got %d frames, want %d", len(frames), len(bodies))
+			}
+			for i := range bodies {
+				if !bytes.Equal(frames[i], bodies[i]) {
+					t.Fatalf("frame %d mismatch", i)
+				}
+			}
+		})
+	}
+}
+
+func TestCodec_PartialFrameNotConsumed(t *testing.T) {
+	c := NewCodec(0)
+	frame, _ := c.Encode([]byte("hello"))
+
+	in := &fakeInbound{}
+	in.write(frame[:FrameHeaderSize+2])
+
+	called := false
+	if err := c.DecodeFrames(in, func([]byte) { called = true }); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if called || in.InboundBuffered() != FrameHeaderSize+2 {
+		t.Fatalf("partial frame should stay buffered: called=%v buffered=%d", called, in.InboundBuffered())
+	}
+}
+
+func TestCodec_TooLarge(t *testing.T) {
+	c := NewCodec(16)
+	good, _ := c.Encode([]byte("ok"))
+
+	header := make([]byte, FrameHeaderSize)
+	binary.BigEndian.PutUint32(header, 17)
+
+	// 超长帧之前的完整帧仍然交付，且只凭帧头即可判定超长，无需等待消息体
+	frames, err := feed(t, c, append(good, header...), nil)
+	if !errors.Is(err, ErrPacketTooLarge) {
+		t.Fatalf("err = %v, want ErrPacketTooLarge", err)
+	}
+	if len(frames) != 1 || string(frames[0]) != "ok" {
+		t.Fatalf("frames before oversized frame = %q", frames)
+	}
+
+	binary.BigEndian.PutUint32(header, 0xFFFFFFFF)
+	if _, err := feed(t, c, header, nil); !errors.Is(err, ErrPacketTooLarge) {
+		t.Fatalf("max uint32 length err = %v, want ErrPacketTooLarge", err)
+	}
+}
+
+func repeatInt(v, n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = v
+	}
+	return s
+}
+
+// FuzzCodec_RoundTrip 任意消息体按任意方式分片，解码结果都应与原始消息一致
+func FuzzCodec_RoundTrip(f *testing.F) {
+	f.Add([]byte("hello"), []byte("world"), []byte{1, 2, 3})
+	f.Add([]byte{}, []byte{}, []byte{})
+	f.Add(bytes.Repeat([]byte("x"), 1000), []byte{0}, []byte{255, 1})
+
+	f.Fuzz(func(t *testing.T, a, b, splits []byte) {
+		c := NewCodec(4096)
+		bodies := [][]byte{a, b, a}
+		for _, body := range bodies {
+			if len(body) > c.MaxMessageSize() {
+				return
+			}
+		}
+
+		chunks := make([]int, len(splits))
+		for i, s := range splits {
+			chunks[i] = int(s)
+		}
+
+		frames, err := feed(t, c, encodeAll(t, c, bodies...), chunks)
+		if err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		if len(frames) != len(bodies) {
+			t.Fatalf("got %d frames, want %d", len(frames), len(bodies))
+		}
+		for i := range bodies {
+			if !bytes.Equal(frames[i], bodies[i]) {
+				t.Fatalf("frame %d mismatch", i)
+			}
+		}
+	})
+}
+
+// FuzzCodec_Decode 任意输入都不应 panic，解出的帧不超过上限，分片方式不影响结果
+func FuzzCodec_Decode(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 1, 'a', 0, 0}, []byte{1})
+	f.Add([]byte{0xFF, 0xFF, 0xFF, 0xFF}, []byte{})
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0, 0}, []byte{3, 3})
+
+	f.Fuzz(func(t *testing.T, data, splits []byte) {
+		c := NewCodec(64)
+
+		whole, wholeErr := feed(t, c, bytes.Clone(data), nil)
+
+		chunks := make([]int, len(splits))
+		for i, s := range splits {
+			chunks[i] = int(s)
+		}
+		split, splitErr := feed(t, c, bytes.Clone(data), chunks)
+
+		if errors.Is(wholeErr, ErrPacketTooLarge) != errors.Is(splitErr, ErrPacketTooLarge) {
+			t.Fatalf("errors differ: %v vs %v", wholeErr, splitErr)
+		}
+		if len(whole) != len(split) {
+			t.Fatalf("frame count differs: %d vs %d", len(whole), len(split))
+		}
+		for i := range whole {
+			if len(whole[i]) > c.MaxMessageSize() {
+				t.Fatalf("frame %d exceeds max size", i)
+			}
+			if !bytes.Equal(whole[i], split[i]) {
+				t.Fatalf("frame %d differs", i)
+			}
+		}
+	})
+}
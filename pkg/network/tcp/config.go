@@ -31,16 +31,16 @@ type ServerConfig struct {
 	// 写缓冲区大小
 	WriteBufferSize int `mapstructure:"write_buffer_size" json:"write_buffer_size" yaml:"write_buffer_size"`
 
-	// 最大消息大小
+	// 最大消息大小（单帧消息体字节数，不含 4 字节长度头），收到超长帧时断开连接，超长的待发送消息直接丢弃
 	MaxMessageSize int `mapstructure:"max_message_size" json:"max_message_size" yaml:"max_message_size"`
 
 	// 发送队列大小
 	SendQueueSize int `mapstructure:"send_queue_size" json:"send_queue_size" yaml:"send_queue_size"`
 
-	// 读超时
+	// 读超时（空闲超时）：超过该时间没有收到任何数据则关闭连接，0 表示不检测
 	ReadTimeout time.Duration `mapstructure:"read_timeout" json:"read_timeout" yaml:"read_timeout"`
 
-	// 写超时
+	// 写超时：有待发送的数据且超过该时间没有写出进展则关闭连接，0 表示不检测
 	WriteTimeout time.Duration `mapstructure:"write_timeout" json:"write_timeout" yaml:"write_timeout"`
 
 	// TCP KeepAlive 间隔
@@ -48,6 +48,9 @@ type ServerConfig struct {
 
 	// 是否禁用 Nagle 算法（启用 TCP_NODELAY）
 	TCPNoDelay bool `mapstructure:"tcp_no_delay" json:"tcp_no_delay" yaml:"tcp_no_delay"`
+
+	// 心跳间隔：超过该时间没有收到数据时主动发送 Ping，0 表示不主动发送（只应答对端的 Ping）
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval" json:"heartbeat_interval" yaml:"heartbeat_interval"`
 }
 
 // DefaultServerConfig 返回默认服务端配置
@@ -88,7 +91,7 @@ func (c *ServerConfig) Validate() error {
 		c.WriteBufferSize = 64 * 1024
 	}
 	if c.MaxMessageSize <= 0 {
-		c.MaxMessageSize = 1024 * 1024
+		c.MaxMessageSize = DefaultMaxMessageSize
 	}
 	if c.SendQueueSize <= 0 {
 		c.SendQueueSize = 256
@@ -110,7 +113,7 @@ type ClientConfig struct {
 	// 写缓冲区大小
 	WriteBufferSize int `mapstructure:"write_buffer_size" json:"write_buffer_size" yaml:"write_buffer_size"`
 
-	// 最大消息大小
+	// 最大消息大小（单帧消息体字节数，不含 4 字节长度头），收到超长帧时断开连接，超长的待发送消息直接丢弃
 	MaxMessageSize int `mapstructure:"max_message_size" json:"max_message_size" yaml:"max_message_size"`
 
 	// 发送队列大小
@@ -119,10 +122,10 @@ type ClientConfig struct {
 	// 连接超时
 	DialTimeout time.Duration `mapstructure:"dial_timeout" json:"dial_timeout" yaml:"dial_timeout"`
 
-	// 读超时
+	// 读超时（空闲超时）：超过该时间没有收到任何数据则关闭连接，0 表示不检测
 	ReadTimeout time.Duration `mapstructure:"read_timeout" json:"read_timeout" yaml:"read_timeout"`
 
-	// 写超时
+	// 写超时：有待发送的数据且超过该时间没有写出进展则关闭连接，0 表示不检测
 	WriteTimeout time.Duration `mapstructure:"write_timeout" json:"write_timeout" yaml:"write_timeout"`
 
 	// TCP KeepAlive 间隔
@@ -131,6 +134,9 @@ type ClientConfig struct {
 	// 是否禁用 Nagle 算法（启用 TCP_NODELAY）
 	TCPNoDelay bool `mapstructure:"tcp_no_delay" json:"tcp_no_delay" yaml:"tcp_no_delay"`
 
+	// 心跳间隔：超过该时间没有收到数据时主动发送 Ping，0 表示不主动发送（只应答对端的 Ping）
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval" json:"heartbeat_interval" yaml:"heartbeat_interval"`
+
 	// 重连配置
 	Reconnect ReconnectConfig `mapstructure:"reconnect" json:"reconnect" yaml:"reconnect"`
 }
@@ -138,17 +144,18 @@ type ClientConfig struct {
 // DefaultClientConfig 返回默认客户端配置
 func DefaultClientConfig() *ClientConfig {
 	return &ClientConfig{
-		Network:         "tcp",
-		ReadBufferSize:  64 * 1024,
-		WriteBufferSize: 64 * 1024,
-		MaxMessageSize:  1024 * 1024,
-		SendQueueSize:   256,
-		DialTimeout:     10 * time.Second,
-		ReadTimeout:     60 * time.Second,
-		WriteTimeout:    10 * time.Second,
-		TCPKeepAlive:    30 * time.Second,
-		TCPNoDelay:      true,
-		Reconnect:       DefaultReconnectConfig(),
+		Network:           "tcp",
+		ReadBufferSize:    64 * 1024,
+		WriteBufferSize:   64 * 1024,
+		MaxMessageSize:    1024 * 1024,
+		SendQueueSize:     256,
+		DialTimeout:       10 * time.Second,
+		ReadTimeout:       60 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		TCPKeepAlive:      30 * time.Second,
+		TCPNoDelay:        true,
+		HeartbeatInterval: 20 * time.Second,
+		Reconnect:         DefaultReconnectConfig(),
 	}
 }
 
@@ -170,7 +177,7 @@ func (c *ClientConfig) Validate() error {
 		c.WriteBufferSize = 64 * 1024
 	}
 	if c.MaxMessageSize <= 0 {
-		c.MaxMessageSize = 1024 * 1024
+		c.MaxMessageSize = DefaultMaxMessageSize
 	}
 	if c.SendQueueSize <= 0 {
 		c.SendQueueSize = 256
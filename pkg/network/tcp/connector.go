@@ -2,11 +2,10 @@ package tcp
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
-	common "github.com/lk2023060901/xdooria-proto-common"
-	"github.com/lk2023060901/xdooria/pkg/network/framer"
 	"github.com/lk2023060901/xdooria/pkg/network/session"
 	"github.com/panjf2000/gnet/v2"
 )
@@ -16,6 +15,8 @@ type Connector struct {
 	config        *ClientConfig
 	sessionConfig *session.Config
 	handler       session.SessionHandler
+	codec         *Codec
+	idle          session.IdlePolicy
 
 	client  *gnet.Client
 	session *TCPSession
@@ -33,6 +34,12 @@ func NewConnector(cfg *ClientConfig, sessCfg *session.Config, handler session.Se
 		config:        cfg,
 		sessionConfig: sessCfg,
 		handler:       handler,
+		codec:         NewCodec(cfg.MaxMessageSize),
+		idle: session.IdlePolicy{
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			HeartbeatInterval: cfg.HeartbeatInterval,
+		},
 	}
 }
 
@@ -51,6 +58,7 @@ func (c *Connector) Start() error {
 		gnet.WithWriteBufferCap(c.config.WriteBufferSize),
 		gnet.WithTCPKeepAlive(c.config.TCPKeepAlive),
 		gnet.WithTCPNoDelay(gnet.TCPNoDelay),
+		gnet.WithTicker(true),
 	)
 	if err != nil {
 		return err
@@ -92,14 +100,30 @@ func (c *Connector) Connect(ctx context.Context, addr string) (session.Session,
 		return nil, err
 	}
 
-	c.session = NewTCPSession(conn, c.sessionConfig)
-	c.handler.OnOpened(c.session)
+	s := NewTCPSession(conn, c.sessionConfig, c.codec)
+	c.mu.Lock()
+	c.session = s
+	c.mu.Unlock()
+
+	// 启用会话密钥协商时，握手完成后才能发送业务消息
+	if c.config.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.DialTimeout)
+		defer cancel()
+	}
+	if err := session.Handshake(ctx, s); err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("handshake failed: %w", err)
+	}
+	c.handler.OnOpened(s)
 
-	return c.session, nil
+	return s, nil
 }
 
 // Session 返回当前会话。
 func (c *Connector) Session() *TCPSession {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.session
 }
 
@@ -119,49 +143,44 @@ func (c *Connector) OnOpen(conn gnet.Conn) (out []byte, action gnet.Action) {
 
 // OnClose 实现 gnet.EventHandler。
 func (c *Connector) OnClose(conn gnet.Conn, err error) gnet.Action {
-	if c.session != nil {
-		c.handler.OnClosed(c.session, err)
+	if s := c.Session(); s != nil {
+		c.handler.OnClosed(s, s.Closed(err))
 	}
 	return gnet.None
 }
 
 // OnTraffic 实现 gnet.EventHandler。
+// 一次读取可能包含多个帧或半个帧，不完整的帧留在 gnet 缓冲区中等待后续数据。
 func (c *Connector) OnTraffic(conn gnet.Conn) gnet.Action {
-	if c.session == nil {
+	s := c.Session()
+	if s == nil {
 		return gnet.None
 	}
+	// 收到任何数据（包括不完整的帧）都视为活跃
+	s.Idle().MarkRead(time.Now())
 
-	data, _ := conn.Next(-1)
-	if len(data) == 0 {
-		return gnet.None
-	}
-
-	env, err := framer.Unmarshal(data)
-	if err != nil {
-		c.handler.OnError(c.session, err)
-		return gnet.None
-	}
-	// 验证签名并解密/解压
-	op, payload, err := c.session.Framer().Decode(env)
+	err := c.codec.DecodeFrames(conn, func(frame []byte) {
+		envs, err := session.DecodeFrame(s, frame)
+		for _, env := range envs {
+			c.handler.OnMessage(s, env)
+		}
+		if err != nil {
+			c.handler.OnError(s, err)
+		}
+	})
 	if err != nil {
-		c.handler.OnError(c.session, err)
-		return gnet.None
+		// 帧长度非法，无法再定位后续帧的边界，只能断开连接
+		c.handler.OnError(s, err)
+		return gnet.Close
 	}
-	// 构建解码后的 Envelope
-	decodedEnv := &common.Envelope{
-		Header:  &common.MessageHeader{Op: op},
-		Payload: payload,
-	}
-	if err := c.session.PushRecv(decodedEnv); err != nil {
-		c.handler.OnError(c.session, err)
-		return gnet.None
-	}
-	c.handler.OnMessage(c.session, decodedEnv)
 
 	return gnet.None
 }
 
-// OnTick 实现 gnet.EventHandler。
+// OnTick 实现 gnet.EventHandler，定期检查连接的读写超时并发送心跳。
 func (c *Connector) OnTick() (delay time.Duration, action gnet.Action) {
-	return 0, gnet.None
+	if s := c.Session(); s != nil {
+		session.CheckIdle(s, time.Now(), c.idle)
+	}
+	return session.IdleCheckInterval, gnet.None
 }
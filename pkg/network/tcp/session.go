@@ -2,6 +2,7 @@ package tcp
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/lk2023060901/xdooria-proto-common"
@@ -14,15 +15,17 @@ import (
 // TCPSession 基于 gnet 连接的会话实现，服务端和客户端通用。
 type TCPSession struct {
 	*session.BaseSession
-	conn gnet.Conn
+	conn  gnet.Conn
+	codec *Codec
 }
 
 // NewTCPSession 创建一个新的 gnet TCP 会话。
-func NewTCPSession(conn gnet.Conn, cfg *session.Config) *TCPSession {
+func NewTCPSession(conn gnet.Conn, cfg *session.Config, codec *Codec) *TCPSession {
 	id := uuid.New().String()
 	s := &TCPSession{
 		BaseSession: session.NewBaseSession(id, conn.RemoteAddr().String(), cfg),
 		conn:        conn,
+		codec:       codec,
 	}
 	conc.Go(func() (struct{}, error) {
 		s.writeLoop()
@@ -31,39 +34,45 @@ func NewTCPSession(conn gnet.Conn, cfg *session.Config) *TCPSession {
 	return s
 }
 
-// Send 发送消息信封，压入发送队列。
+// Send 发送消息信封，按会话配置的溢出策略压入发送队列。
 func (s *TCPSession) Send(ctx context.Context, env *common.Envelope) error {
-	select {
-	case s.SendChan() <- env:
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-s.Context().Done():
-		return session.ErrConnectionClosed
-	}
+	return session.SendQueued(ctx, s, env)
 }
 
 func (s *TCPSession) writeLoop() {
-	ctx := s.Context()
 	f := s.Framer()
 	for {
-		select {
-		case env := <-s.SendChan():
-			signedEnv, err := f.Encode(env.Header.Op, env.Payload)
-			if err != nil {
-				continue
-			}
-			data, err := framer.Marshal(signedEnv)
-			if err != nil {
-				continue
-			}
-			_ = s.conn.AsyncWrite(data, nil)
-		case <-ctx.Done():
+		batch, ok := s.NextBatch()
+		if !ok {
 			return
 		}
+		// 一批消息作为整体签名、压缩、加密，只写出一次
+		signedEnv, err := session.EncodeBatch(f, batch)
+		if err != nil {
+			continue
+		}
+		data, err := framer.Marshal(signedEnv)
+		if err != nil {
+			continue
+		}
+		// 超过 MaxMessageSize 的消息对端无法接收，直接丢弃
+		frame, err := s.codec.Encode(data)
+		if err != nil {
+			continue
+		}
+		s.Idle().WriteStarted(time.Now())
+		if err := s.conn.AsyncWrite(frame, s.onWritten); err != nil {
+			s.Idle().WriteDone(time.Now())
+		}
 	}
 }
 
+// onWritten 数据写出（或写失败）后由 gnet 回调，用于写超时判定。
+func (s *TCPSession) onWritten(_ gnet.Conn, _ error) error {
+	s.Idle().WriteDone(time.Now())
+	return nil
+}
+
 // Close 关闭会话。
 func (s *TCPSession) Close() error {
 	_ = s.BaseSession.Close()
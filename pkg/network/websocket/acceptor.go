@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"net"
 	"net/http"
 
 	common "github.com/lk2023060901/xdooria-proto-common"
@@ -15,6 +16,7 @@ type Acceptor struct {
 	sessionConfig *session.Config
 	handler       session.SessionHandler
 	addr          string
+	httpServer    *http.Server
 }
 
 func NewAcceptor(server *Server, sessCfg *session.Config, addr string, handler session.SessionHandler) *Acceptor {
@@ -55,16 +57,31 @@ func (a *Acceptor) Start() error {
 					a.handler.OnError(s, err)
 					return nil
 				}
-				// 构建解码后的 Envelope
-				decodedEnv := &common.Envelope{
-					Header:  &common.MessageHeader{Op: op},
+				// 构建解码后的 Envelope，批量消息展开为多条
+				envs, err := session.Unbatch(&common.Envelope{
+					Header:  &common.MessageHeader{Op: op, RpcId: env.Header.RpcId},
 					Payload: payload,
-				}
-				if err := s.PushRecv(decodedEnv); err != nil {
+				})
+				if err != nil {
 					a.handler.OnError(s, err)
 					return nil
 				}
-				a.handler.OnMessage(s, decodedEnv)
+				for _, decodedEnv := range envs {
+					if handled, err := session.HandleHandshake(s, decodedEnv); handled {
+						if err != nil {
+							// 握手失败后双方密钥不一致，后续消息都无法解码
+							a.handler.OnError(s, err)
+							_ = s.Close()
+							return err
+						}
+						continue
+					}
+					if err := s.PushRecv(decodedEnv); err != nil {
+						a.handler.OnError(s, err)
+						continue
+					}
+					a.handler.OnMessage(s, decodedEnv)
+				}
 				return nil
 			})
 			a.handler.OnClosed(s, conn.CloseError())
@@ -72,9 +89,22 @@ func (a *Acceptor) Start() error {
 		})
 	})
 	
-	return http.ListenAndServe(a.addr, mux)
+	// 先同步监听，端口占用等错误直接返回；之后在后台处理请求，便于与其他 Acceptor 同时运行
+	ln, err := net.Listen("tcp", a.addr)
+	if err != nil {
+		return err
+	}
+	a.httpServer = &http.Server{Handler: mux}
+	conc.Go(func() (struct{}, error) {
+		_ = a.httpServer.Serve(ln)
+		return struct{}{}, nil
+	})
+	return nil
 }
 
 func (a *Acceptor) Stop() error {
+	if a.httpServer != nil {
+		_ = a.httpServer.Close()
+	}
 	return a.server.Close()
 }
\ No newline at end of file
@@ -2,6 +2,7 @@ package websocket
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/gorilla/websocket"
@@ -52,8 +53,6 @@ func (c *Connector) Connect(ctx context.Context, addr string) (session.Session,
 	conn := NewConnection(wsConn)
 	s := NewWebSocketSession(conn, c.sessionConfig)
 
-	c.handler.OnOpened(s)
-
 	// 驱动读取
 	conc.Go(func() (struct{}, error) {
 		conn.ReadLoop(func(c_ *Connection, m *Message) error {
@@ -68,21 +67,48 @@ func (c *Connector) Connect(ctx context.Context, addr string) (session.Session,
 				c.handler.OnError(s, err)
 				return nil
 			}
-			// 构建解码后的 Envelope
-			decodedEnv := &common.Envelope{
-				Header:  &common.MessageHeader{Op: op},
+			// 构建解码后的 Envelope，批量消息展开为多条
+			envs, err := session.Unbatch(&common.Envelope{
+				Header:  &common.MessageHeader{Op: op, RpcId: env.Header.RpcId},
 				Payload: payload,
-			}
-			if err := s.PushRecv(decodedEnv); err != nil {
+			})
+			if err != nil {
 				c.handler.OnError(s, err)
 				return nil
 			}
-			c.handler.OnMessage(s, decodedEnv)
+			for _, decodedEnv := range envs {
+				if handled, err := session.HandleHandshake(s, decodedEnv); handled {
+					if err != nil {
+						// 握手失败后双方密钥不一致，后续消息都无法解码
+						c.handler.OnError(s, err)
+						_ = s.Close()
+						return err
+					}
+					continue
+				}
+				if err := s.PushRecv(decodedEnv); err != nil {
+					c.handler.OnError(s, err)
+					continue
+				}
+				c.handler.OnMessage(s, decodedEnv)
+			}
 			return nil
 		})
 		c.handler.OnClosed(s, conn.CloseError())
 		return struct{}{}, nil
 	})
 
+	// 启用会话密钥协商时，握手完成后才能发送业务消息
+	if c.config.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.DialTimeout)
+		defer cancel()
+	}
+	if err := session.Handshake(ctx, s); err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("handshake failed: %w", err)
+	}
+	c.handler.OnOpened(s)
+
 	return s, nil
-}
\ No newline at end of file
+}
@@ -28,36 +28,28 @@ func NewWebSocketSession(conn *Connection, cfg *session.Config) *WebSocketSessio
 	return s
 }
 
-// Send 发送消息信封，压入发送队列。
+// Send 发送消息信封，按会话配置的溢出策略压入发送队列。
 func (s *WebSocketSession) Send(ctx context.Context, env *common.Envelope) error {
-	select {
-	case s.SendChan() <- env:
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-s.Context().Done():
-		return session.ErrConnectionClosed
-	}
+	return session.SendQueued(ctx, s, env)
 }
 
 func (s *WebSocketSession) writeLoop() {
-	ctx := s.Context()
 	f := s.Framer()
 	for {
-		select {
-		case env := <-s.SendChan():
-			signedEnv, err := f.Encode(env.Header.Op, env.Payload)
-			if err != nil {
-				continue
-			}
-			data, err := framer.Marshal(signedEnv)
-			if err != nil {
-				continue
-			}
-			_ = s.conn.SendAsync(NewMessage(data))
-		case <-ctx.Done():
+		batch, ok := s.NextBatch()
+		if !ok {
 			return
 		}
+		// 一批消息作为整体签名、压缩、加密，只写出一次
+		signedEnv, err := session.EncodeBatch(f, batch)
+		if err != nil {
+			continue
+		}
+		data, err := framer.Marshal(signedEnv)
+		if err != nil {
+			continue
+		}
+		_ = s.conn.SendAsync(NewMessage(data))
 	}
 }
 
@@ -2,6 +2,22 @@ package registry
 
 import "context"
 
+// 服务上报的通用元数据 key
+const (
+	// MetadataZoneID 所属区服 ID
+	MetadataZoneID = "zone_id"
+	// MetadataOnline 当前在线人数
+	MetadataOnline = "online"
+	// MetadataSessions 当前连接（会话）数
+	MetadataSessions = "sessions"
+	// MetadataCPUPercent 进程 CPU 使用率（0-100）
+	MetadataCPUPercent = "cpu_percent"
+	// MetadataDraining 为 "true" 时表示节点正在排空，不再接收新的客户端
+	MetadataDraining = "draining"
+	// MetadataUpdatedAt 最近一次上报时间（RFC3339）
+	MetadataUpdatedAt = "updated_at"
+)
+
 // ServiceInfo 服务信息
 type ServiceInfo struct {
 	// ServiceName 服务名称
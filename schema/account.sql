@@ -0,0 +1,52 @@
+-- 账号表（Login 服务本地账号）
+CREATE TABLE IF NOT EXISTS accounts (
+    -- 基础信息
+    id                  BIGSERIAL PRIMARY KEY,              -- 账号ID（即 UID）
+    username            VARCHAR(32) NOT NULL,               -- 用户名
+    password_hash       VARCHAR(128) NOT NULL,              -- 密码哈希（bcrypt）
+
+    -- 状态
+    status              SMALLINT NOT NULL DEFAULT 0,        -- 状态: 0正常 1停用
+
+    -- 登录失败锁定
+    failed_attempts     INT NOT NULL DEFAULT 0,             -- 连续登录失败次数
+    locked_until        TIMESTAMPTZ,                        -- 锁定到期时间，NULL=未锁定
+
+    -- 时间戳
+    password_changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(), -- 密码修改时间
+    last_login_at       TIMESTAMPTZ,                        -- 最后登录时间
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(), -- 创建时间
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()  -- 更新时间
+);
+
+-- 索引
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_username ON accounts(username);
+CREATE INDEX IF NOT EXISTS idx_accounts_created_at ON accounts(created_at);
+
+-- 更新时间触发器
+CREATE OR REPLACE FUNCTION update_accounts_updated_at()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_accounts_updated_at ON accounts;
+CREATE TRIGGER trigger_accounts_updated_at
+    BEFORE UPDATE ON accounts
+    FOR EACH ROW
+    EXECUTE FUNCTION update_accounts_updated_at();
+
+-- 注释
+COMMENT ON TABLE accounts IS '账号表';
+COMMENT ON COLUMN accounts.id IS '账号ID，即登录后签发 Token 中的 UID';
+COMMENT ON COLUMN accounts.username IS '用户名';
+COMMENT ON COLUMN accounts.password_hash IS '密码哈希（bcrypt）';
+COMMENT ON COLUMN accounts.status IS '状态: 0正常 1停用';
+COMMENT ON COLUMN accounts.failed_attempts IS '连续登录失败次数，登录成功或重置密码后清零';
+COMMENT ON COLUMN accounts.locked_until IS '锁定到期时间，NULL表示未锁定';
+COMMENT ON COLUMN accounts.password_changed_at IS '密码修改时间';
+COMMENT ON COLUMN accounts.last_login_at IS '最后登录时间';
+COMMENT ON COLUMN accounts.created_at IS '创建时间';
+COMMENT ON COLUMN accounts.updated_at IS '更新时间';
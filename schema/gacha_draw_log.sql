@@ -0,0 +1,24 @@
+-- 抽卡流水表 (只追加，用于玩家抽卡历史与概率审计)
+CREATE TABLE IF NOT EXISTS gacha_draw_logs (
+    id          BIGSERIAL PRIMARY KEY,
+    role_id     BIGINT NOT NULL,                -- 角色ID
+    pool_id     INT NOT NULL,                   -- 池子ID (TbGacha.id)
+    drop_id     INT NOT NULL,                   -- 本次实际使用的掉落ID
+    seed        BIGINT NOT NULL DEFAULT 0,      -- 掉落随机种子
+    is_pity     BOOLEAN NOT NULL DEFAULT FALSE, -- 是否触发保底
+    pity_count  INT NOT NULL DEFAULT 0,         -- 本次抽取时的保底计数
+    results     JSONB NOT NULL DEFAULT '[]',    -- 掉落结果列表
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_gacha_draw_logs_role ON gacha_draw_logs (role_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_gacha_draw_logs_pool ON gacha_draw_logs (pool_id, created_at);
+
+COMMENT ON TABLE gacha_draw_logs IS '抽卡流水表';
+COMMENT ON COLUMN gacha_draw_logs.role_id IS '角色ID';
+COMMENT ON COLUMN gacha_draw_logs.pool_id IS '池子ID';
+COMMENT ON COLUMN gacha_draw_logs.drop_id IS '本次实际使用的掉落ID (保底时为保底掉落ID)';
+COMMENT ON COLUMN gacha_draw_logs.seed IS '掉落随机种子，配合 drop_id 可离线重放本次结果';
+COMMENT ON COLUMN gacha_draw_logs.is_pity IS '是否触发保底';
+COMMENT ON COLUMN gacha_draw_logs.pity_count IS '本次抽取时的保底计数';
+COMMENT ON COLUMN gacha_draw_logs.results IS '掉落结果列表: [{item_id, count}]';
@@ -0,0 +1,52 @@
+-- 全服邮件表 (运营发送，角色拉取邮箱时按投放目标投递到 role_mails)
+CREATE TABLE IF NOT EXISTS global_mails (
+    id           BIGSERIAL PRIMARY KEY,
+    sender       VARCHAR(64) NOT NULL DEFAULT '',  -- 发件人
+    title        VARCHAR(128) NOT NULL,            -- 标题
+    content      TEXT NOT NULL DEFAULT '',         -- 正文
+    attachments  JSONB NOT NULL DEFAULT '[]',      -- 附件列表
+    target_type  INT NOT NULL DEFAULT 0,           -- 投放目标: 0全部 1等级区间 2角色列表
+    min_level    INT NOT NULL DEFAULT 0,           -- 最低等级（含）
+    max_level    INT NOT NULL DEFAULT 0,           -- 最高等级（含），0 不限
+    role_ids     BIGINT[] NOT NULL DEFAULT '{}',   -- 目标角色列表
+    send_time    BIGINT NOT NULL,                  -- 发送时间 (Unix)
+    expire_time  BIGINT NOT NULL DEFAULT 0,        -- 过期时间 (Unix)，0 永不过期
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_global_mails_expire ON global_mails (expire_time);
+
+COMMENT ON TABLE global_mails IS '全服邮件表';
+COMMENT ON COLUMN global_mails.attachments IS '附件列表: [{item_id, count}]';
+COMMENT ON COLUMN global_mails.target_type IS '投放目标: 0全部 1等级区间 2角色列表';
+COMMENT ON COLUMN global_mails.send_time IS '发送时间 (Unix)，之后创建的角色不会收到';
+COMMENT ON COLUMN global_mails.expire_time IS '过期时间 (Unix)，0 表示永不过期';
+
+-- 角色邮件表 (系统邮件直接写入，全服邮件在角色拉取邮箱时写入副本)
+CREATE TABLE IF NOT EXISTS role_mails (
+    id           BIGSERIAL PRIMARY KEY,
+    role_id      BIGINT NOT NULL,                  -- 收件角色ID
+    global_id    BIGINT NOT NULL DEFAULT 0,        -- 来源全服邮件ID，系统邮件为 0
+    mail_type    INT NOT NULL,                     -- 邮件类型: 1系统 2全服
+    sender       VARCHAR(64) NOT NULL DEFAULT '',
+    title        VARCHAR(128) NOT NULL,
+    content      TEXT NOT NULL DEFAULT '',
+    attachments  JSONB NOT NULL DEFAULT '[]',
+    is_read      BOOLEAN NOT NULL DEFAULT FALSE,   -- 是否已读
+    is_claimed   BOOLEAN NOT NULL DEFAULT FALSE,   -- 附件是否已领取
+    is_deleted   BOOLEAN NOT NULL DEFAULT FALSE,   -- 是否已删除（保留记录防止全服邮件重复投递）
+    send_time    BIGINT NOT NULL,
+    expire_time  BIGINT NOT NULL DEFAULT 0,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- 同一封全服邮件每个角色只投递一次
+CREATE UNIQUE INDEX IF NOT EXISTS uk_role_mails_global ON role_mails (role_id, global_id) WHERE global_id > 0;
+CREATE INDEX IF NOT EXISTS idx_role_mails_role ON role_mails (role_id, send_time DESC);
+CREATE INDEX IF NOT EXISTS idx_role_mails_expire ON role_mails (expire_time);
+
+COMMENT ON TABLE role_mails IS '角色邮件表';
+COMMENT ON COLUMN role_mails.global_id IS '来源全服邮件ID，系统邮件为 0';
+COMMENT ON COLUMN role_mails.attachments IS '附件列表: [{item_id, count}]';
+COMMENT ON COLUMN role_mails.is_deleted IS '是否已删除，过期后与邮件一起清理';
+COMMENT ON COLUMN role_mails.expire_time IS '过期时间 (Unix)，0 表示永不过期';
@@ -1,15 +1,33 @@
--- 玩家背包表 (按类型切分，使用 Protobuf 序列化存储)
+-- 玩家背包表 (按类型切分，物品实例以 JSONB 按槽位存储)
 CREATE TABLE IF NOT EXISTS player_bags (
     role_id     BIGINT NOT NULL,
-    bag_type    INT NOT NULL,        -- 背包类型
-    data        BYTEA NOT NULL,      -- 序列化后的 PB 字节流 (ItemBag 或 DollBag)
+    bag_type    INT NOT NULL,                   -- 背包类型
+    capacity    INT NOT NULL DEFAULT 0,         -- 背包容量 (0 表示使用默认容量)
+    items       JSONB NOT NULL DEFAULT '[]',    -- 物品实例列表 (含槽位、数量、绑定状态、过期时间)
+    data        BYTEA,                          -- 玩偶背包 (Costume) 的玩偶列表 JSON
     updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     PRIMARY KEY (role_id, bag_type)
 );
 
+-- 旧版本的表只有 data 列：补齐新列，并把道具背包的 JSON 数据迁移到 items
+ALTER TABLE player_bags ADD COLUMN IF NOT EXISTS capacity INT NOT NULL DEFAULT 0;
+ALTER TABLE player_bags ADD COLUMN IF NOT EXISTS items JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE player_bags ADD COLUMN IF NOT EXISTS data BYTEA;
+ALTER TABLE player_bags ALTER COLUMN data DROP NOT NULL;
+
+-- 旧格式 {配置ID: 数量} 原样迁移，读取时自动转换为物品实例；玩偶背包 (bag_type = 1) 继续使用 data
+UPDATE player_bags
+SET items = convert_from(data, 'UTF8')::jsonb
+WHERE bag_type <> 1
+  AND data IS NOT NULL
+  AND items = '[]'::jsonb
+  AND substring(data FROM 1 FOR 1) IN ('\x5b'::bytea, '\x7b'::bytea);
+
 CREATE INDEX IF NOT EXISTS idx_player_bags_role_id ON player_bags(role_id);
 
 COMMENT ON TABLE player_bags IS '玩家背包表';
 COMMENT ON COLUMN player_bags.role_id IS '角色ID';
 COMMENT ON COLUMN player_bags.bag_type IS '背包类型';
-COMMENT ON COLUMN player_bags.data IS 'Protobuf 序列化数据 (ItemBag 或 DollBag)';
+COMMENT ON COLUMN player_bags.capacity IS '背包容量，0 表示使用服务配置的默认容量';
+COMMENT ON COLUMN player_bags.items IS '物品实例 JSON 数组 (旧格式 {配置ID: 数量} 读取时自动转换)';
+COMMENT ON COLUMN player_bags.data IS '玩偶背包的玩偶列表 JSON，其他背包类型不使用';
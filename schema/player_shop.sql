@@ -0,0 +1,26 @@
+-- 玩家商店数据表 (限购记录 + 神秘商店货架)
+CREATE TABLE IF NOT EXISTS player_shop (
+    role_id     BIGINT PRIMARY KEY,
+    purchases   JSONB NOT NULL DEFAULT '[]', -- 角色限购记录列表
+    mystery     JSONB NOT NULL DEFAULT '[]', -- 神秘商店货架列表
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE player_shop IS '玩家商店数据表';
+COMMENT ON COLUMN player_shop.role_id IS '角色ID';
+COMMENT ON COLUMN player_shop.purchases IS '限购记录列表: [{id, count, period_start, last_time}]';
+COMMENT ON COLUMN player_shop.mystery IS '神秘商店货架列表: [{shop_id, item_ids, refresh_time}]';
+
+-- 商品全服库存表 (按限购周期累计全服售出数量)
+CREATE TABLE IF NOT EXISTS shop_global_stock (
+    shop_item_id  INT NOT NULL,                  -- 商品ID (TbShopItem.id)
+    period_start  BIGINT NOT NULL,               -- 限购周期起始时间 (Unix)，终身限购为 0
+    sold          INT NOT NULL DEFAULT 0,        -- 本周期全服已售数量
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (shop_item_id, period_start)
+);
+
+COMMENT ON TABLE shop_global_stock IS '商品全服库存表';
+COMMENT ON COLUMN shop_global_stock.shop_item_id IS '商品ID';
+COMMENT ON COLUMN shop_global_stock.period_start IS '限购周期起始时间 (Unix)';
+COMMENT ON COLUMN shop_global_stock.sold IS '本周期全服已售数量';
@@ -0,0 +1,32 @@
+-- 平台身份绑定表（Login 服务：平台身份 -> 内部 UID）
+CREATE TABLE IF NOT EXISTS user_identities (
+    -- 平台身份
+    login_type          INT NOT NULL,                       -- 登录类型（common.LoginType）
+    platform_uid        VARCHAR(128) NOT NULL,              -- 平台唯一标识（OpenID/UnionID、设备ID、本地账号ID）
+
+    -- 内部账号
+    uid                 BIGINT NOT NULL,                    -- 内部 UID，即 Token 中的 uid
+
+    -- 平台资料
+    nickname            VARCHAR(64) NOT NULL DEFAULT '',    -- 平台昵称
+    avatar_url          VARCHAR(512) NOT NULL DEFAULT '',   -- 平台头像
+
+    -- 时间戳
+    bound_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(), -- 绑定时间
+    last_login_at       TIMESTAMPTZ,                        -- 最后登录时间
+
+    PRIMARY KEY (login_type, platform_uid)
+);
+
+-- 索引（同一 UID 每种登录类型只能绑定一个平台身份）
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identities_uid_login_type ON user_identities(uid, login_type);
+
+-- 注释
+COMMENT ON TABLE user_identities IS '平台身份绑定表';
+COMMENT ON COLUMN user_identities.login_type IS '登录类型（common.LoginType）';
+COMMENT ON COLUMN user_identities.platform_uid IS '平台唯一标识：第三方平台为 OpenID/UnionID，游客为设备ID，本地账号为账号ID';
+COMMENT ON COLUMN user_identities.uid IS '内部 UID，一个 UID 可绑定多个平台身份';
+COMMENT ON COLUMN user_identities.nickname IS '平台昵称，每次登录时更新';
+COMMENT ON COLUMN user_identities.avatar_url IS '平台头像 URL，每次登录时更新';
+COMMENT ON COLUMN user_identities.bound_at IS '绑定时间';
+COMMENT ON COLUMN user_identities.last_login_at IS '最后登录时间';